@@ -0,0 +1,96 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuditEventSpec records who did what, and when
+type AuditEventSpec struct {
+	// Time is when the action happened
+	Time metav1.Time `json:"time"`
+
+	// User is the username that performed the action. For a failed login it is the
+	// username that was tried; actions of the operator itself are recorded as
+	// system:llmcloud-operator
+	User string `json:"user"`
+
+	// SourceIP is the address the request came from
+	// +optional
+	SourceIP string `json:"sourceIP,omitempty"`
+
+	// Action is what was done: login, logout, create, update, delete, or an action
+	// such as start or stop
+	Action string `json:"action"`
+
+	// Resource is the type of the object acted on, e.g. virtualmachines or users
+	// +optional
+	Resource string `json:"resource,omitempty"`
+
+	// Namespace of the object acted on
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the object acted on
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Path is the API request path
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// StatusCode is the HTTP status the request was answered with
+	// +optional
+	StatusCode int32 `json:"statusCode,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Time",type="date",JSONPath=".spec.time"
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Action",type="string",JSONPath=".spec.action"
+// +kubebuilder:printcolumn:name="Resource",type="string",JSONPath=".spec.resource"
+// +kubebuilder:printcolumn:name="Name",type="string",JSONPath=".spec.name"
+// +kubebuilder:printcolumn:name="Status",type="integer",JSONPath=".spec.statusCode"
+
+// AuditEvent is the Schema for the auditevents API. Each one records a single action
+// taken through the API server or by the operator
+type AuditEvent struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec is the recorded action
+	// +required
+	Spec AuditEventSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// AuditEventList contains a list of AuditEvent
+type AuditEventList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AuditEvent `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AuditEvent{}, &AuditEventList{})
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Roles a ClusterNode joins the k0s cluster with
+const (
+	ClusterNodeRoleController = "controller"
+	ClusterNodeRoleWorker     = "worker"
+)
+
+// States a ClusterNode can be asked to be in
+const (
+	// ClusterNodeStateJoined runs k0s on the host and keeps the node schedulable
+	ClusterNodeStateJoined = "Joined"
+	// ClusterNodeStateDrained keeps the node in the cluster, cordoned and emptied
+	ClusterNodeStateDrained = "Drained"
+	// ClusterNodeStateRemoved drains the node, resets k0s on the host and deletes it
+	ClusterNodeStateRemoved = "Removed"
+)
+
+// Phases of a ClusterNode
+const (
+	ClusterNodePhasePending  = "Pending"
+	ClusterNodePhaseJoining  = "Joining"
+	ClusterNodePhaseJoined   = "Joined"
+	ClusterNodePhaseDraining = "Draining"
+	ClusterNodePhaseDrained  = "Drained"
+	ClusterNodePhaseRemoving = "Removing"
+	ClusterNodePhaseRemoved  = "Removed"
+	ClusterNodePhaseFailed   = "Failed"
+)
+
+// Keys of the credentials secret of a ClusterNode
+const (
+	// ClusterNodePrivateKeyKey holds the PEM private key to log in to the host with
+	ClusterNodePrivateKeyKey = corev1.SSHAuthPrivateKey
+	// ClusterNodePasswordKey holds the password to log in to the host with
+	ClusterNodePasswordKey = "password"
+)
+
+// ClusterNodeSpec defines a host of the k0s cluster and the state it should be in
+type ClusterNodeSpec struct {
+	// Host is the SSH destination of the machine, as [user@]host[:port]. It may only be
+	// empty for nodes that are removed, which are then deleted from Kubernetes without
+	// logging in to them
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Role is the k0s role the host joins with
+	// +kubebuilder:validation:Enum=controller;worker
+	// +kubebuilder:default=worker
+	// +optional
+	Role string `json:"role,omitempty"`
+
+	// CredentialsSecret holds the ssh-privatekey and/or password to log in to the host
+	// with. The operator's SSH settings are used when it is unset
+	// +optional
+	CredentialsSecret *corev1.SecretReference `json:"credentialsSecret,omitempty"`
+
+	// NodeName is the name of the Kubernetes node the host registers as. Defaults to
+	// the host name of Host
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// State is the desired state of the node
+	// +kubebuilder:validation:Enum=Joined;Drained;Removed
+	// +kubebuilder:default=Joined
+	// +optional
+	State string `json:"state,omitempty"`
+
+	// DrainTimeout is how long a drain waits for VMs to live-migrate off the node before
+	// it stops those still on it. VMs that KubeVirt cannot live-migrate, such as VMs on
+	// ReadWriteOnce disks or with host devices, are stopped right away. Defaults to
+	// DefaultDrainTimeout
+	// +optional
+	DrainTimeout *metav1.Duration `json:"drainTimeout,omitempty"`
+}
+
+// DefaultDrainTimeout is how long a drain waits for VMs to migrate when a ClusterNode
+// does not set DrainTimeout
+const DefaultDrainTimeout = 30 * time.Minute
+
+// EffectiveDrainTimeout returns DrainTimeout, or DefaultDrainTimeout when it is unset
+func (s *ClusterNodeSpec) EffectiveDrainTimeout() time.Duration {
+	if s.DrainTimeout != nil {
+		return s.DrainTimeout.Duration
+	}
+	return DefaultDrainTimeout
+}
+
+// ClusterNodeStatus defines the observed state of ClusterNode
+type ClusterNodeStatus struct {
+	// Phase is the step of joining, draining or removing the node that was reached
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// NodeName is the Kubernetes node managed
+	// +optional
+	NodeName string `json:"nodeName,omitempty"`
+
+	// Message tells what the node is waiting for, or why the last step failed
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is when Phase last changed
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status describes
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// StoppedVMs are the VMs, as namespace/name, that the drain stopped because they
+	// could not be migrated off the node
+	// +optional
+	StoppedVMs []string `json:"stoppedVMs,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Host",type="string",JSONPath=".spec.host"
+// +kubebuilder:printcolumn:name="Role",type="string",JSONPath=".spec.role"
+// +kubebuilder:printcolumn:name="State",type="string",JSONPath=".spec.state"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Node",type="string",JSONPath=".status.nodeName"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",priority=1
+
+// ClusterNode is the Schema for the clusternodes API. It is a machine the operator joins
+// to the k0s cluster over SSH, and drains and removes again when asked to
+type ClusterNode struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the host and its desired state
+	// +required
+	Spec ClusterNodeSpec `json:"spec"`
+
+	// status defines the observed state of ClusterNode
+	// +optional
+	Status ClusterNodeStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// ClusterNodeList contains a list of ClusterNode
+type ClusterNodeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterNode `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterNode{}, &ClusterNodeList{})
+}
+
+// KubernetesNodeName is the name of the Kubernetes node of n: NodeName, or else the
+// host name of Host
+func (n *ClusterNode) KubernetesNodeName() string {
+	if n.Spec.NodeName != "" {
+		return n.Spec.NodeName
+	}
+	host := n.Spec.Host
+	if idx := strings.LastIndex(host, "@"); idx != -1 {
+		host = host[idx+1:]
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// DesiredState is the State of n, Joined when unset
+func (n *ClusterNode) DesiredState() string {
+	if n.Spec.State == "" {
+		return ClusterNodeStateJoined
+	}
+	return n.Spec.State
+}
+
+// ValidateClusterNodeSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateClusterNodeSpec(spec *ClusterNodeSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.Host == "" && spec.State != ClusterNodeStateRemoved {
+		allErrs = append(allErrs, field.Required(fldPath.Child("host"), "the host to join is required"))
+	}
+	if strings.ContainsAny(spec.Host, " \t\n") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), spec.Host, "must be [user@]host[:port]"))
+	}
+	if spec.Host == "" && spec.NodeName == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("nodeName"), "the node is required when there is no host"))
+	}
+	if spec.NodeName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.NodeName) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("nodeName"), spec.NodeName, msg))
+		}
+	}
+	if d := spec.DrainTimeout; d != nil && d.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("drainTimeout"), d.Duration.String(), "must not be negative"))
+	}
+	if ref := spec.CredentialsSecret; ref != nil {
+		if ref.Name == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("credentialsSecret", "name"), ""))
+		}
+		if ref.Namespace == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("credentialsSecret", "namespace"), ""))
+		}
+	}
+	return allErrs
+}
@@ -0,0 +1,22 @@
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestClusterNodeKubernetesNodeName(t *testing.T) {
+	for host, want := range map[string]string{
+		"ubuntu@GPU-1.example.com:2222": "gpu-1.example.com",
+		"10.0.0.21":                     "10.0.0.21",
+		"root@[fd00::21]:22":            "fd00::21",
+	} {
+		n := &ClusterNode{Spec: ClusterNodeSpec{Host: host}}
+		if got := n.KubernetesNodeName(); got != want {
+			t.Errorf("KubernetesNodeName() for %s = %q, want %q", host, got, want)
+		}
+	}
+	n := &ClusterNode{Spec: ClusterNodeSpec{Host: "10.0.0.21", NodeName: "gpu-1"}}
+	if got := n.KubernetesNodeName(); got != "gpu-1" {
+		t.Errorf("Expected the node name to take precedence, got %q", got)
+	}
+}
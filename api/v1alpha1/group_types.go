@@ -0,0 +1,96 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// GroupSpec defines the users in a group
+type GroupSpec struct {
+	// Description tells admins what the group is for
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Members are the usernames of the users in the group
+	// +listType=set
+	// +optional
+	Members []string `json:"members,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description",priority=1
+
+// Group is the Schema for the groups API. A project member named group:<name> makes
+// every user in the group a member of the project with the member's role
+type Group struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the users in the group
+	// +required
+	Spec GroupSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// GroupList contains a list of Group
+type GroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Group `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Group{}, &GroupList{})
+}
+
+// HasMember reports whether the user named username is in the group
+func (g *Group) HasMember(username string) bool {
+	return slices.Contains(g.Spec.Members, username)
+}
+
+// ValidateGroupSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath. Groups hold users only, so a member
+// cannot be another group
+func ValidateGroupSpec(spec *GroupSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	members := map[string]bool{}
+	for i, member := range spec.Members {
+		idxPath := fldPath.Child("members").Index(i)
+		switch {
+		case member == "":
+			allErrs = append(allErrs, field.Required(idxPath, ""))
+		case strings.HasPrefix(member, GroupMemberPrefix):
+			allErrs = append(allErrs, field.Invalid(idxPath, member, "groups cannot be nested"))
+		case members[member]:
+			allErrs = append(allErrs, field.Duplicate(idxPath, member))
+		}
+		members[member] = true
+	}
+	return allErrs
+}
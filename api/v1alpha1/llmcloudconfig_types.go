@@ -0,0 +1,337 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// LLMCloudConfigName is the name of the one LLMCloudConfig the operator reads
+const LLMCloudConfigName = "default"
+
+// DefaultStorageClass is the storage class of VM disks when neither the VM nor the
+// LLMCloudConfig sets one
+const DefaultStorageClass = "local-path"
+
+// DefaultOSImageRegistry is the registry the container disk images in OSImageMap are
+// published to
+const DefaultOSImageRegistry = "quay.io/containerdisks"
+
+// LLMCloudConfigSpec defines the settings of the operator and API server that can be
+// changed at runtime. Unset fields keep the built-in defaults and startup flags
+type LLMCloudConfigSpec struct {
+	// DefaultStorageClass is the storage class of VM disks that do not set one
+	// (defaults to local-path)
+	// +optional
+	DefaultStorageClass string `json:"defaultStorageClass,omitempty"`
+
+	// OSImageRegistry replaces quay.io/containerdisks in the OS images of VMs, for a
+	// mirror of its container disks (e.g., "mirror.local:5000/containerdisks")
+	// +optional
+	OSImageRegistry string `json:"osImageRegistry,omitempty"`
+
+	// TokenTTL is how long login and refreshed API tokens are valid, overriding the
+	// --token-ttl flag
+	// +optional
+	TokenTTL *metav1.Duration `json:"tokenTTL,omitempty"`
+
+	// AllowedOS limits the operating systems new VMs may use. All are allowed when empty
+	// +listType=set
+	// +optional
+	AllowedOS []string `json:"allowedOS,omitempty"`
+
+	// ModelCatalog adds presets to the model catalog, or replaces the built-in preset
+	// with the same name
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ModelCatalog []ModelPreset `json:"modelCatalog,omitempty"`
+
+	// CORSOrigins are the origins browsers may call the API from (e.g.,
+	// "https://console.example.com"). Any origin may when empty
+	// +listType=set
+	// +optional
+	CORSOrigins []string `json:"corsOrigins,omitempty"`
+
+	// CORSMethods are the methods browsers may call the API with from other origins
+	// (defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS)
+	// +listType=set
+	// +optional
+	CORSMethods []string `json:"corsMethods,omitempty"`
+
+	// CORSHeaders are the request headers browsers may send to the API from other
+	// origins (defaults to Content-Type, Authorization, If-Match and X-API-Key)
+	// +listType=set
+	// +optional
+	CORSHeaders []string `json:"corsHeaders,omitempty"`
+
+	// CORSAllowCredentials lets browsers send cookies and HTTP authentication to the API
+	// from the CORSOrigins, which must then list the origins rather than allow any
+	// +optional
+	CORSAllowCredentials bool `json:"corsAllowCredentials,omitempty"`
+
+	// ContentSecurityPolicy replaces the Content-Security-Policy header the web UI is
+	// served with, which only allows the UI's own origin
+	// +optional
+	ContentSecurityPolicy string `json:"contentSecurityPolicy,omitempty"`
+
+	// PasswordPolicy sets the requirements of the passwords of local users. Passwords
+	// are not checked when unset
+	// +optional
+	PasswordPolicy *PasswordPolicy `json:"passwordPolicy,omitempty"`
+}
+
+// PasswordPolicy is what the passwords of local users must meet when they are set,
+// and how long they may be used
+type PasswordPolicy struct {
+	// MinLength is the minimum number of characters of a password
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=72
+	// +optional
+	MinLength int32 `json:"minLength,omitempty"`
+
+	// RequireUppercase requires an upper case letter
+	// +optional
+	RequireUppercase bool `json:"requireUppercase,omitempty"`
+
+	// RequireLowercase requires a lower case letter
+	// +optional
+	RequireLowercase bool `json:"requireLowercase,omitempty"`
+
+	// RequireDigit requires a digit
+	// +optional
+	RequireDigit bool `json:"requireDigit,omitempty"`
+
+	// RequireSymbol requires a character that is neither a letter nor a digit
+	// +optional
+	RequireSymbol bool `json:"requireSymbol,omitempty"`
+
+	// MaxAge is how long a password may be used; a user with an older password must
+	// change it at the next login. Passwords do not expire when unset
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// Check returns an error naming every requirement of the policy password does not
+// meet. A nil policy accepts any password
+func (p *PasswordPolicy) Check(password string) error {
+	if p == nil {
+		return nil
+	}
+	var unmet []string
+	if n := utf8.RuneCountInString(password); n < int(p.MinLength) {
+		unmet = append(unmet, fmt.Sprintf("have at least %d characters", p.MinLength))
+	}
+	requirements := []struct {
+		required bool
+		has      func(rune) bool
+		what     string
+	}{
+		{p.RequireUppercase, unicode.IsUpper, "an upper case letter"},
+		{p.RequireLowercase, unicode.IsLower, "a lower case letter"},
+		{p.RequireDigit, unicode.IsDigit, "a digit"},
+		{p.RequireSymbol, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }, "a symbol"},
+	}
+	for _, req := range requirements {
+		if req.required && !strings.ContainsFunc(password, req.has) {
+			unmet = append(unmet, "contain "+req.what)
+		}
+	}
+	if len(unmet) > 0 {
+		return fmt.Errorf("password must %s", strings.Join(unmet, ", "))
+	}
+	return nil
+}
+
+// Expired reports whether a password set at setAt is older than MaxAge at now
+func (p *PasswordPolicy) Expired(setAt, now time.Time) bool {
+	return p != nil && p.MaxAge != nil && !now.Before(setAt.Add(p.MaxAge.Duration))
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// LLMCloudConfig is the Schema for the llmcloudconfigs API. Only the one named
+// "default" is used; the operator and API server reload it whenever it changes
+type LLMCloudConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the runtime settings
+	// +required
+	Spec LLMCloudConfigSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// LLMCloudConfigList contains a list of LLMCloudConfig
+type LLMCloudConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LLMCloudConfig `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LLMCloudConfig{}, &LLMCloudConfigList{})
+}
+
+// DefaultCORSMethods are the methods browsers may call the API with from other origins
+// when the LLMCloudConfig sets no corsMethods
+var DefaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// DefaultCORSHeaders are the request headers browsers may send to the API from other
+// origins when the LLMCloudConfig sets no corsHeaders
+var DefaultCORSHeaders = []string{"Content-Type", "Authorization", "If-Match", "X-API-Key"}
+
+// corsMethods are the methods CORSMethods may list
+var corsMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// headerNamePattern matches HTTP header names
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// runtimeConfig holds the spec of the LLMCloudConfig in effect
+var runtimeConfig atomic.Pointer[LLMCloudConfigSpec]
+
+// SetRuntimeConfig puts spec into effect, or the built-in defaults when spec is nil
+func SetRuntimeConfig(spec *LLMCloudConfigSpec) {
+	if spec != nil {
+		spec = spec.DeepCopy()
+	}
+	runtimeConfig.Store(spec)
+}
+
+// RuntimeConfig returns the settings in effect, which are empty without an
+// LLMCloudConfig. The result must not be modified
+func RuntimeConfig() *LLMCloudConfigSpec {
+	if spec := runtimeConfig.Load(); spec != nil {
+		return spec
+	}
+	return &LLMCloudConfigSpec{}
+}
+
+// OSAllowed reports whether new VMs may use os under the settings in effect
+func OSAllowed(os string) bool {
+	allowed := RuntimeConfig().AllowedOS
+	return len(allowed) == 0 || slices.Contains(allowed, os)
+}
+
+// ValidateLLMCloudConfig returns every violation in config that the CRD schema cannot
+// express, including a name other than LLMCloudConfigName
+func ValidateLLMCloudConfig(config *LLMCloudConfig) field.ErrorList {
+	var allErrs field.ErrorList
+	if config.Name != LLMCloudConfigName {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("metadata", "name"), config.Name, []string{LLMCloudConfigName}))
+	}
+	return append(allErrs, ValidateLLMCloudConfigSpec(&config.Spec, field.NewPath("spec"))...)
+}
+
+// ValidateLLMCloudConfigSpec returns every violation in spec that the CRD schema
+// cannot express, with field paths under fldPath
+func ValidateLLMCloudConfigSpec(spec *LLMCloudConfigSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.DefaultStorageClass != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.DefaultStorageClass) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("defaultStorageClass"), spec.DefaultStorageClass, msg))
+		}
+	}
+	if registry := spec.OSImageRegistry; strings.Contains(registry, "://") || strings.HasSuffix(registry, "/") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("osImageRegistry"), registry,
+			"must be a registry host and path without a scheme or trailing slash"))
+	}
+	if spec.TokenTTL != nil && spec.TokenTTL.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("tokenTTL"), spec.TokenTTL.Duration.String(), "must be positive"))
+	}
+	for i, os := range spec.AllowedOS {
+		if _, known := OSImageMap[os]; !known {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("allowedOS").Index(i), os, supportedOSes()))
+		}
+	}
+	for i, preset := range spec.ModelCatalog {
+		idxPath := fldPath.Child("modelCatalog").Index(i)
+		for _, msg := range validation.IsDNS1123Subdomain(preset.Name) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), preset.Name, msg))
+		}
+		if preset.ModelName == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("modelName"), ""))
+		}
+		if preset.Image == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("image"), ""))
+		}
+		allErrs = append(allErrs, validatePositiveQuantity(preset.Resources.CPU, idxPath.Child("resources", "cpu"))...)
+		allErrs = append(allErrs, validatePositiveQuantity(preset.Resources.Memory, idxPath.Child("resources", "memory"))...)
+	}
+	if policy := spec.PasswordPolicy; policy != nil && policy.MaxAge != nil && policy.MaxAge.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("passwordPolicy", "maxAge"), policy.MaxAge.Duration.String(), "must be positive"))
+	}
+	for i, origin := range spec.CORSOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			origin != u.Scheme+"://"+u.Host {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("corsOrigins").Index(i), origin,
+				`must be "*" or a scheme and host such as https://console.example.com`))
+		}
+	}
+	for i, method := range spec.CORSMethods {
+		if !slices.Contains(corsMethods, method) {
+			allErrs = append(allErrs, field.NotSupported(fldPath.Child("corsMethods").Index(i), method, corsMethods))
+		}
+	}
+	for i, header := range spec.CORSHeaders {
+		if !headerNamePattern.MatchString(header) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("corsHeaders").Index(i), header, "must be an HTTP header name"))
+		}
+	}
+	if spec.CORSAllowCredentials && (len(spec.CORSOrigins) == 0 || slices.Contains(spec.CORSOrigins, "*")) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("corsAllowCredentials"), true,
+			"requires corsOrigins to list the origins, as browsers do not send credentials to any origin"))
+	}
+	if strings.ContainsAny(spec.ContentSecurityPolicy, "\r\n") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("contentSecurityPolicy"), spec.ContentSecurityPolicy,
+			"must be a single line"))
+	}
+	return allErrs
+}
+
+// supportedOSes returns the OSes in OSImageMap, sorted
+func supportedOSes() []string {
+	var oses []string
+	for os := range OSImageMap {
+		if !strings.Contains(os, ":") {
+			oses = append(oses, os)
+		}
+	}
+	slices.Sort(oses)
+	return oses
+}
@@ -0,0 +1,165 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateLLMCloudConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config LLMCloudConfig
+		fields []string
+	}{
+		{name: "empty", config: LLMCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}}},
+		{
+			name: "valid",
+			config: LLMCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: LLMCloudConfigSpec{
+				DefaultStorageClass: "fast-ssd",
+				OSImageRegistry:     "mirror.local:5000/containerdisks",
+				TokenTTL:            &metav1.Duration{Duration: time.Hour},
+				AllowedOS:           []string{"ubuntu", "debian"},
+				ModelCatalog:        []ModelPreset{{Name: "qwen2-7b", ModelName: "qwen2", Image: "ollama/ollama:latest"}},
+				CORSOrigins:         []string{"https://console.example.com", "http://localhost:3000"},
+				PasswordPolicy:      &PasswordPolicy{MinLength: 12, MaxAge: &metav1.Duration{Duration: 90 * 24 * time.Hour}},
+			}},
+		},
+		{
+			name: "cors with credentials",
+			config: LLMCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: LLMCloudConfigSpec{
+				CORSOrigins:           []string{"https://console.example.com"},
+				CORSMethods:           []string{"GET", "POST"},
+				CORSHeaders:           []string{"Authorization", "X-API-Key"},
+				CORSAllowCredentials:  true,
+				ContentSecurityPolicy: "default-src 'self'",
+			}},
+		},
+		{name: "not the default", config: LLMCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "other"}}, fields: []string{"metadata.name"}},
+		{
+			name: "invalid settings",
+			config: LLMCloudConfig{ObjectMeta: metav1.ObjectMeta{Name: "default"}, Spec: LLMCloudConfigSpec{
+				DefaultStorageClass:   "Fast SSD",
+				OSImageRegistry:       "https://mirror.local/",
+				TokenTTL:              &metav1.Duration{},
+				AllowedOS:             []string{"ubuntu", "windows"},
+				ModelCatalog:          []ModelPreset{{Name: "qwen2-7b", Resources: ResourceRequirements{CPU: "lots"}}},
+				CORSOrigins:           []string{"*", "console.example.com", "https://console.example.com/app"},
+				PasswordPolicy:        &PasswordPolicy{MaxAge: &metav1.Duration{}},
+				CORSMethods:           []string{"GET", "TRACE"},
+				CORSHeaders:           []string{"X-API-Key", "Bad Header"},
+				CORSAllowCredentials:  true,
+				ContentSecurityPolicy: "default-src 'self'\r\nSet-Cookie: x",
+			}},
+			fields: []string{
+				"spec.defaultStorageClass", "spec.osImageRegistry", "spec.tokenTTL", "spec.allowedOS[1]",
+				"spec.modelCatalog[0].modelName", "spec.modelCatalog[0].image", "spec.modelCatalog[0].resources.cpu",
+				"spec.passwordPolicy.maxAge", "spec.corsOrigins[1]", "spec.corsOrigins[2]", "spec.corsMethods[1]",
+				"spec.corsHeaders[1]", "spec.corsAllowCredentials", "spec.contentSecurityPolicy",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateLLMCloudConfig(&tt.config)
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected errors on %v, got %v", tt.fields, errs)
+			}
+			for i, err := range errs {
+				if err.Field != tt.fields[i] {
+					t.Errorf("Error %d on %s, want %s", i, err.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 10, RequireUppercase: true, RequireLowercase: true, RequireDigit: true, RequireSymbol: true}
+	for password, want := range map[string]string{
+		"Correct-horse-1": "",
+		"Ünïcödé-pass-9":  "",
+		"short":           "password must have at least 10 characters, contain an upper case letter, contain a digit, contain a symbol",
+		"ALLUPPERCASE-1":  "password must contain a lower case letter",
+		"Lettersonly-pw":  "password must contain a digit",
+	} {
+		err := policy.Check(password)
+		if got := ""; err != nil {
+			got = err.Error()
+			if got != want {
+				t.Errorf("Expected %q for %q, got %q", want, password, got)
+			}
+		} else if want != "" {
+			t.Errorf("Expected %q to be refused with %q", password, want)
+		}
+	}
+	if err := (*PasswordPolicy)(nil).Check(""); err != nil {
+		t.Errorf("Expected no policy to accept any password, got %v", err)
+	}
+
+	now := time.Now()
+	policy.MaxAge = &metav1.Duration{Duration: 24 * time.Hour}
+	if policy.Expired(now.Add(-time.Hour), now) || !policy.Expired(now.Add(-25*time.Hour), now) {
+		t.Error("Expected only the password set over a day ago to have expired")
+	}
+	if (&PasswordPolicy{}).Expired(time.Time{}, now) {
+		t.Error("Expected passwords not to expire without a MaxAge")
+	}
+}
+
+func TestRuntimeConfig(t *testing.T) {
+	t.Cleanup(func() { SetRuntimeConfig(nil) })
+
+	spec := &LLMCloudConfigSpec{
+		OSImageRegistry: "mirror.local:5000/containerdisks",
+		AllowedOS:       []string{"ubuntu"},
+		ModelCatalog: []ModelPreset{
+			{Name: "llama3-8b-q4", ModelName: "llama3", Image: "mirror.local:5000/ollama:latest"},
+			{Name: "qwen2-7b", ModelName: "qwen2", Image: "ollama/ollama:latest"},
+		},
+	}
+	SetRuntimeConfig(spec)
+	spec.AllowedOS[0] = "fedora"
+
+	if got := GetImageForOS("debian", "12"); got != "mirror.local:5000/containerdisks/debian:12" {
+		t.Errorf("Expected the OS image from the mirror, got %q", got)
+	}
+	if got := GetImageForOS("cirros", ""); got != OSImageMap["cirros"] {
+		t.Errorf("Expected images from other registries to be kept, got %q", got)
+	}
+	if !OSAllowed("ubuntu") || OSAllowed("fedora") {
+		t.Errorf("Expected only ubuntu to be allowed, got %v", RuntimeConfig().AllowedOS)
+	}
+	presets := ModelPresets()
+	if len(presets) != len(ModelCatalog)+1 {
+		t.Fatalf("Expected one preset to be added, got %d presets", len(presets))
+	}
+	if preset, _ := LookupModelPreset("llama3-8b-q4"); preset.Image != "mirror.local:5000/ollama:latest" {
+		t.Errorf("Expected the preset to be replaced, got %+v", preset)
+	}
+	if _, ok := LookupModelPreset("qwen2-7b"); !ok {
+		t.Error("Expected the added preset to be found")
+	}
+	if ModelCatalog[0].Image == "mirror.local:5000/ollama:latest" {
+		t.Error("Expected the built-in catalog to be unchanged")
+	}
+
+	// An existing VM may keep an OS that is no longer allowed, but not switch to one
+	fedora := &VirtualMachineSpec{OS: "fedora"}
+	if errs := ValidateVirtualMachineSpec(fedora, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.os" {
+		t.Errorf("Expected new fedora VMs to be rejected, got %v", errs)
+	}
+	if errs := ValidateVirtualMachineUpdate(fedora, fedora, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected an existing fedora VM to be updatable, got %v", errs)
+	}
+	if errs := ValidateVirtualMachineUpdate(fedora, &VirtualMachineSpec{OS: "ubuntu"}, field.NewPath("spec")); len(errs) != 1 {
+		t.Errorf("Expected a VM not to switch to fedora, got %v", errs)
+	}
+
+	SetRuntimeConfig(nil)
+	if got := GetImageForOS("debian", "12"); got != "quay.io/containerdisks/debian:12" || !OSAllowed("fedora") {
+		t.Errorf("Expected the defaults once the config is removed, got %q", got)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ModelPreset is a curated model in ModelCatalog, with the image, resources and
+// context length it runs well with
+type ModelPreset struct {
+	// Name is what an LLMModel sets as its preset (e.g., "mistral-7b-q4")
+	Name string `json:"name"`
+	// Description is a short summary of the model for the catalog
+	Description string `json:"description,omitempty"`
+
+	ModelName     string               `json:"modelName"`
+	ModelSize     string               `json:"modelSize,omitempty"`
+	Quantization  string               `json:"quantization,omitempty"`
+	Provider      string               `json:"provider,omitempty"`
+	Image         string               `json:"image"`
+	Resources     ResourceRequirements `json:"resources,omitempty"`
+	ContextLength int32                `json:"contextLength,omitempty"`
+}
+
+// ollamaImage is the model server the presets run on
+const ollamaImage = "ollama/ollama:latest"
+
+// ModelCatalog lists the models an LLMModel can be created from by preset name
+var ModelCatalog = []ModelPreset{
+	{
+		Name:        "llama3-8b-q4",
+		Description: "Meta Llama 3 8B, 4-bit",
+		ModelName:   "llama3", ModelSize: "8b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "4", Memory: "8Gi"},
+		ContextLength: 8192,
+	},
+	{
+		Name:        "llama3-70b-q4",
+		Description: "Meta Llama 3 70B, 4-bit",
+		ModelName:   "llama3", ModelSize: "70b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "16", Memory: "48Gi", GPU: 2},
+		ContextLength: 8192,
+	},
+	{
+		Name:        "mistral-7b-q4",
+		Description: "Mistral 7B, 4-bit",
+		ModelName:   "mistral", ModelSize: "7b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "4", Memory: "8Gi"},
+		ContextLength: 32768,
+	},
+	{
+		Name:        "mistral-7b-q8",
+		Description: "Mistral 7B, 8-bit",
+		ModelName:   "mistral", ModelSize: "7b", Quantization: "q8_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "4", Memory: "12Gi"},
+		ContextLength: 32768,
+	},
+	{
+		Name:        "phi3-3.8b-q4",
+		Description: "Microsoft Phi-3 Mini 3.8B, 4-bit",
+		ModelName:   "phi3", ModelSize: "3.8b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "2", Memory: "4Gi"},
+		ContextLength: 4096,
+	},
+	{
+		Name:        "gemma2-9b-q4",
+		Description: "Google Gemma 2 9B, 4-bit",
+		ModelName:   "gemma2", ModelSize: "9b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "4", Memory: "10Gi"},
+		ContextLength: 8192,
+	},
+	{
+		Name:        "codellama-13b-q4",
+		Description: "Code Llama 13B, 4-bit",
+		ModelName:   "codellama", ModelSize: "13b", Quantization: "q4_0",
+		Provider: ProviderOllama, Image: ollamaImage,
+		Resources:     ResourceRequirements{CPU: "8", Memory: "16Gi"},
+		ContextLength: 16384,
+	},
+}
+
+// ModelPresets returns the presets in ModelCatalog, with those in the ModelCatalog of
+// the LLMCloudConfig in effect replacing the ones with the same name or added after them
+func ModelPresets() []ModelPreset {
+	overrides := RuntimeConfig().ModelCatalog
+	if len(overrides) == 0 {
+		return ModelCatalog
+	}
+	presets := slices.Clone(ModelCatalog)
+	for _, override := range overrides {
+		i := slices.IndexFunc(presets, func(p ModelPreset) bool { return p.Name == override.Name })
+		if i >= 0 {
+			presets[i] = override
+		} else {
+			presets = append(presets, override)
+		}
+	}
+	return presets
+}
+
+// LookupModelPreset returns the preset in ModelPresets called name
+func LookupModelPreset(name string) (ModelPreset, bool) {
+	for _, preset := range ModelPresets() {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return ModelPreset{}, false
+}
+
+// modelPresetNames returns the names of the presets in ModelPresets
+func modelPresetNames() []string {
+	presets := ModelPresets()
+	names := make([]string, 0, len(presets))
+	for _, preset := range presets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// ApplyModelPreset fills the fields spec leaves empty from its preset. The resource
+// shorthand and GPUs are filled individually; explicit requests and limits are kept
+// as they are. A spec without a preset is left unchanged
+func ApplyModelPreset(spec *LLMModelSpec) error {
+	if spec.Preset == "" {
+		return nil
+	}
+	preset, ok := LookupModelPreset(spec.Preset)
+	if !ok {
+		return fmt.Errorf("unknown model preset %q", spec.Preset)
+	}
+	fill := func(value *string, preset string) {
+		if *value == "" {
+			*value = preset
+		}
+	}
+	fill(&spec.ModelName, preset.ModelName)
+	fill(&spec.ModelSize, preset.ModelSize)
+	fill(&spec.Quantization, preset.Quantization)
+	fill(&spec.Provider, preset.Provider)
+	fill(&spec.Image, preset.Image)
+	fill(&spec.Resources.CPU, preset.Resources.CPU)
+	fill(&spec.Resources.Memory, preset.Resources.Memory)
+	if spec.Resources.GPU == 0 {
+		spec.Resources.GPU = preset.Resources.GPU
+	}
+	if spec.ContextLength == 0 {
+		spec.ContextLength = preset.ContextLength
+	}
+	return nil
+}
@@ -17,18 +17,46 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // LLMModel phase constants
 const (
 	LLMModelPhasePending = "Pending"
+	LLMModelPhaseRunning = "Running"
+	LLMModelPhaseFailed  = "Failed"
+	// LLMModelPhaseStopped is the phase of a model scaled down with Stopped
+	LLMModelPhaseStopped = "Stopped"
+)
+
+// Model serving protocols
+const (
+	ServeProtocolOpenAI = "openai"
+	ServeProtocolOllama = "ollama"
 )
 
 // LLMModelSpec defines the desired state of LLMModel
 type LLMModelSpec struct {
-	// ModelName is the name of the model (e.g., "llama2", "mistral")
-	ModelName string `json:"modelName"`
+	// Preset names a model in the catalog (e.g., "mistral-7b-q4"). The fields the
+	// model leaves empty are taken from the preset
+	// +optional
+	Preset string `json:"preset,omitempty"`
+
+	// ModelName is the name of the model (e.g., "llama2", "mistral"). It is required
+	// unless Preset is set
+	// +optional
+	ModelName string `json:"modelName,omitempty"`
 
 	// ModelSize is the size variant (e.g., "7b", "13b", "70b")
 	// +optional
@@ -38,6 +66,13 @@ type LLMModelSpec struct {
 	// +optional
 	Provider string `json:"provider,omitempty"`
 
+	// ServeProtocol is the API the model server speaks. It selects the readiness probe
+	// path and the proxy route prefix. Defaults to ollama for the ollama provider and
+	// openai otherwise
+	// +kubebuilder:validation:Enum=openai;ollama
+	// +optional
+	ServeProtocol string `json:"serveProtocol,omitempty"`
+
 	// Quantization level (e.g., "q4_0", "q8_0")
 	// +optional
 	Quantization string `json:"quantization,omitempty"`
@@ -50,12 +85,220 @@ type LLMModelSpec struct {
 	// +optional
 	Resources ResourceRequirements `json:"resources,omitempty"`
 
-	// Replicas is the number of model instances
+	// ContextLength is the context window, in tokens, the model server allocates for
+	// each request. Only ollama servers are configured with it
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ContextLength int32 `json:"contextLength,omitempty"`
+
+	// Replicas is the number of model instances. It is ignored when Autoscaling is set
 	// +optional
 	Replicas int32 `json:"replicas,omitempty"`
+
+	// Stopped scales the model down to no instances, keeping its weights, until it is
+	// started again
+	// +optional
+	Stopped bool `json:"stopped,omitempty"`
+
+	// Autoscaling scales the model between MinReplicas and MaxReplicas with a
+	// HorizontalPodAutoscaler, based on CPU utilization, request load or GPU utilization
+	// +optional
+	Autoscaling *ModelAutoscaling `json:"autoscaling,omitempty"`
+
+	// WeightsAccessMode is the access mode of the volume the model's weights are kept
+	// on, ReadWriteOnce when empty. Running more than one replica requires
+	// ReadWriteMany, as a ReadWriteOnce volume is only mounted on one node. It does not
+	// apply to huggingface models, which share the model cache of their namespace
+	// +kubebuilder:validation:Enum=ReadWriteOnce;ReadWriteMany
+	// +optional
+	WeightsAccessMode corev1.PersistentVolumeAccessMode `json:"weightsAccessMode,omitempty"`
+
+	// NodeSelector restricts the model's pods to nodes with these labels
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations let the model's pods run on nodes with matching taints. Models
+	// requesting GPUs also tolerate the nvidia.com/gpu taint
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// Rollout splits the requests the inference gateway serves for the model between
+	// it and a canary model running a new version
+	// +optional
+	Rollout *ModelRollout `json:"rollout,omitempty"`
+
+	// TTL deletes the model this long after it was created (e.g., "24h"). The model is
+	// never deleted when unset
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// HealthCheck configures how the model server is probed. The kubelet restarts a
+	// server that stops answering its health path, and with PromptTimeout set the
+	// operator also restarts pods that stop answering prompts
+	// +optional
+	HealthCheck *ModelHealthCheck `json:"healthCheck,omitempty"`
+}
+
+// Defaults of a ModelHealthCheck, for fields left empty
+const (
+	DefaultHealthCheckInterval         = time.Minute
+	DefaultHealthCheckFailureThreshold = 3
+)
+
+// ModelHealthCheck configures the probes of a model server
+type ModelHealthCheck struct {
+	// Path is the HTTP path probed for liveness and readiness. Defaults to the path
+	// listing the served models
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Port is the container port probed. Defaults to the port the model is served on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// PromptTimeout is how long each ready pod has to answer a one-token prompt (e.g.,
+	// "30s"). Pods are not prompted when unset
+	// +optional
+	PromptTimeout *metav1.Duration `json:"promptTimeout,omitempty"`
+
+	// Interval is how often each ready pod is prompted. Defaults to 1m
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// FailureThreshold is the number of prompts in a row a pod may fail to answer
+	// before it is restarted. Defaults to 3
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// Model rollout strategies
+const (
+	// RolloutStrategyCanary promotes or rolls back the canary by its error rate once it
+	// has served MinRequests
+	RolloutStrategyCanary = "Canary"
+	// RolloutStrategyABTest splits the requests until the rollout is removed
+	RolloutStrategyABTest = "ABTest"
+)
+
+// Model rollout phases
+const (
+	RolloutPhaseProgressing = "Progressing"
+	RolloutPhasePromoted    = "Promoted"
+	RolloutPhaseRolledBack  = "RolledBack"
+)
+
+// Defaults of a ModelRollout, for fields left empty
+const (
+	DefaultRolloutWeight      = 10
+	DefaultRolloutMinRequests = 100
+)
+
+// ModelRollout sends a share of the requests for a model to a canary: another
+// LLMModel in its namespace, serving the new version. A promoted canary serves all the
+// requests for the model, and a rolled back one none
+type ModelRollout struct {
+	// Strategy is Canary, which promotes or rolls back the canary automatically, or
+	// ABTest, which keeps splitting the requests
+	// +kubebuilder:validation:Enum=Canary;ABTest
+	// +kubebuilder:default=Canary
+	// +optional
+	Strategy string `json:"strategy,omitempty"`
+
+	// Canary is the name of the LLMModel serving the new version
+	// +kubebuilder:validation:MinLength=1
+	Canary string `json:"canary"`
+
+	// Weight is the percentage of the requests sent to the canary
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=10
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+
+	// MinRequests is the number of requests the canary serves before it is promoted or
+	// rolled back
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=100
+	// +optional
+	MinRequests int64 `json:"minRequests,omitempty"`
+
+	// MaxErrorRate is the highest percentage of failed canary requests with which the
+	// canary is promoted. Requests fail when the model server errors or is unreachable
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=5
+	// +optional
+	MaxErrorRate int32 `json:"maxErrorRate,omitempty"`
+}
+
+// ModelAutoscaling configures the HorizontalPodAutoscaler of a model. CPU utilization
+// is served by metrics-server; the concurrency and GPU targets are per-pod metrics
+// served by the custom metrics API (e.g., by prometheus-adapter). Without a target the
+// model is scaled at DefaultTargetCPUUtilization, and with several the autoscaler
+// follows whichever asks for the most replicas
+type ModelAutoscaling struct {
+	// MinReplicas is the fewest replicas the model is scaled down to
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+
+	// MaxReplicas is the most replicas the model is scaled up to
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// TargetConcurrency is the average number of in-flight and queued inference
+	// requests per replica to scale at
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetConcurrency int32 `json:"targetConcurrency,omitempty"`
+
+	// ConcurrencyMetric is the per-pod metric TargetConcurrency applies to, such as
+	// vllm:num_requests_running exported by a vLLM server. It is required with
+	// TargetConcurrency, as the custom metrics API only serves what an adapter is
+	// configured to
+	// +optional
+	ConcurrencyMetric string `json:"concurrencyMetric,omitempty"`
+
+	// TargetCPUUtilization is the average CPU utilization, in percent of the CPU
+	// request, to scale at
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	TargetCPUUtilization int32 `json:"targetCPUUtilization,omitempty"`
+
+	// TargetGPUUtilization is the average GPU utilization, in percent, to scale at. It
+	// applies to the per-pod GPUUtilizationMetric
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	TargetGPUUtilization int32 `json:"targetGPUUtilization,omitempty"`
 }
 
-// ResourceRequirements defines resource requirements
+const (
+	// DefaultTargetCPUUtilization is the CPU utilization a model is scaled at when its
+	// autoscaler sets no target
+	DefaultTargetCPUUtilization = 80
+	// GPUUtilizationMetric is the per-pod GPU utilization reported by the NVIDIA DCGM
+	// exporter
+	GPUUtilizationMetric = "DCGM_FI_DEV_GPU_UTIL"
+)
+
+// CPUUtilizationTarget returns the CPU utilization the model is scaled at, or 0 when it
+// is only scaled on other targets. A concurrency target without its metric does not count
+func (a *ModelAutoscaling) CPUUtilizationTarget() int32 {
+	concurrency := a.TargetConcurrency != 0 && a.ConcurrencyMetric != ""
+	if a.TargetCPUUtilization == 0 && !concurrency && a.TargetGPUUtilization == 0 {
+		return DefaultTargetCPUUtilization
+	}
+	return a.TargetCPUUtilization
+}
+
+// ResourceRequirements defines resource requirements. CPU and Memory are shorthand
+// that set both the request and the limit; Requests and Limits override them per resource
 type ResourceRequirements struct {
 	// CPU cores required
 	// +optional
@@ -68,6 +311,427 @@ type ResourceRequirements struct {
 	// GPU devices required
 	// +optional
 	GPU int32 `json:"gpu,omitempty"`
+
+	// Requests is the CPU and memory guaranteed to each replica
+	// +optional
+	Requests *ComputeResources `json:"requests,omitempty"`
+
+	// Limits is the most CPU and memory each replica may use
+	// +optional
+	Limits *ComputeResources `json:"limits,omitempty"`
+}
+
+// ComputeResources is an amount of CPU and memory
+type ComputeResources struct {
+	// CPU cores (e.g., "500m")
+	// +optional
+	CPU string `json:"cpu,omitempty"`
+
+	// Memory (e.g., "2Gi")
+	// +optional
+	Memory string `json:"memory,omitempty"`
+}
+
+// GPUResourceName is the extended resource GPUs are requested as
+const GPUResourceName corev1.ResourceName = "nvidia.com/gpu"
+
+// CPURequest returns the CPU guaranteed to each replica
+func (r *ResourceRequirements) CPURequest() string {
+	if r.Requests != nil && r.Requests.CPU != "" {
+		return r.Requests.CPU
+	}
+	return r.CPU
+}
+
+// MemoryRequest returns the memory guaranteed to each replica
+func (r *ResourceRequirements) MemoryRequest() string {
+	if r.Requests != nil && r.Requests.Memory != "" {
+		return r.Requests.Memory
+	}
+	return r.Memory
+}
+
+// CPULimit returns the most CPU each replica may use
+func (r *ResourceRequirements) CPULimit() string {
+	if r.Limits != nil && r.Limits.CPU != "" {
+		return r.Limits.CPU
+	}
+	return r.CPU
+}
+
+// MemoryLimit returns the most memory each replica may use
+func (r *ResourceRequirements) MemoryLimit() string {
+	if r.Limits != nil && r.Limits.Memory != "" {
+		return r.Limits.Memory
+	}
+	return r.Memory
+}
+
+// PodResources converts the requirements to the container resources of a replica.
+// GPUs are extended resources, so they are only set as a limit
+func (r *ResourceRequirements) PodResources() (corev1.ResourceRequirements, error) {
+	var out corev1.ResourceRequirements
+	set := func(list *corev1.ResourceList, name corev1.ResourceName, value string) error {
+		if value == "" {
+			return nil
+		}
+		q, err := resource.ParseQuantity(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+		if *list == nil {
+			*list = corev1.ResourceList{}
+		}
+		(*list)[name] = q
+		return nil
+	}
+
+	for _, err := range []error{
+		set(&out.Requests, corev1.ResourceCPU, r.CPURequest()),
+		set(&out.Requests, corev1.ResourceMemory, r.MemoryRequest()),
+		set(&out.Limits, corev1.ResourceCPU, r.CPULimit()),
+		set(&out.Limits, corev1.ResourceMemory, r.MemoryLimit()),
+	} {
+		if err != nil {
+			return corev1.ResourceRequirements{}, err
+		}
+	}
+	if r.GPU > 0 {
+		if out.Limits == nil {
+			out.Limits = corev1.ResourceList{}
+		}
+		out.Limits[GPUResourceName] = *resource.NewQuantity(int64(r.GPU), resource.DecimalSI)
+	}
+	return out, nil
+}
+
+// ValidateResourceRequirements checks that every amount is a quantity and that no
+// request is larger than its limit
+func ValidateResourceRequirements(r *ResourceRequirements, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	var requests, limits ComputeResources
+	if r.Requests != nil {
+		requests = *r.Requests
+	}
+	if r.Limits != nil {
+		limits = *r.Limits
+	}
+
+	for _, amount := range []struct {
+		path  *field.Path
+		value string
+	}{
+		{fldPath.Child("cpu"), r.CPU},
+		{fldPath.Child("memory"), r.Memory},
+		{fldPath.Child("requests", "cpu"), requests.CPU},
+		{fldPath.Child("requests", "memory"), requests.Memory},
+		{fldPath.Child("limits", "cpu"), limits.CPU},
+		{fldPath.Child("limits", "memory"), limits.Memory},
+	} {
+		if q, ok := quantity(amount.value); amount.value != "" && (!ok || q.Sign() <= 0) {
+			allErrs = append(allErrs, field.Invalid(amount.path, amount.value, "must be a positive quantity such as 500m or 2Gi"))
+		}
+	}
+	if r.GPU < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("gpu"), r.GPU, "must not be negative"))
+	}
+
+	for _, res := range []struct {
+		name           string
+		explicit       string
+		request, limit string
+	}{
+		{name: "cpu", explicit: requests.CPU, request: r.CPURequest(), limit: r.CPULimit()},
+		{name: "memory", explicit: requests.Memory, request: r.MemoryRequest(), limit: r.MemoryLimit()},
+	} {
+		request, requestOK := quantity(res.request)
+		limit, limitOK := quantity(res.limit)
+		if !requestOK || !limitOK || request.Cmp(limit) <= 0 {
+			continue
+		}
+		// Blame the explicit request, or the limit when the request is the shorthand
+		if res.explicit != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests", res.name), res.request,
+				fmt.Sprintf("must not be greater than the limit %s", res.limit)))
+		} else {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("limits", res.name), res.limit,
+				fmt.Sprintf("must not be less than the request %s", res.request)))
+		}
+	}
+	return allErrs
+}
+
+// Model providers
+const (
+	ProviderOllama      = "ollama"
+	ProviderHuggingFace = "huggingface"
+)
+
+var (
+	// ollamaModelName matches ollama library names, optionally with a namespace
+	ollamaModelName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*(/[a-z0-9][a-z0-9._-]*)?$`)
+	// huggingFaceModelName matches Hugging Face repository ids such as mistralai/Mistral-7B-v0.1
+	huggingFaceModelName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*/[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// modelTag matches the size and quantization that make up an ollama tag
+	modelTag = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._]*$`)
+)
+
+// ValidateLLMModelSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateLLMModelSpec(spec *LLMModelSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	// A preset spec is validated as it runs, with the preset's fields filled in
+	if spec.Preset != "" {
+		spec = spec.DeepCopy()
+		if err := ApplyModelPreset(spec); err != nil {
+			return field.ErrorList{field.NotSupported(fldPath.Child("preset"), spec.Preset, modelPresetNames())}
+		}
+	}
+	allErrs = append(allErrs, validateModelSource(spec, fldPath)...)
+	if err := ValidateReplicas(spec.Replicas); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), spec.Replicas,
+			fmt.Sprintf("exceeds the maximum of %d", MaxReplicas)))
+	}
+	allErrs = append(allErrs, validateModelAutoscaling(spec, fldPath)...)
+	allErrs = append(allErrs, validateModelWeightsAccess(spec, fldPath)...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(spec.NodeSelector, fldPath.Child("nodeSelector"))...)
+	allErrs = append(allErrs, validateModelRollout(spec.Rollout, fldPath.Child("rollout"))...)
+	allErrs = append(allErrs, validateTTL(spec.TTL, fldPath.Child("ttl"))...)
+	allErrs = append(allErrs, validateModelHealthCheck(spec.HealthCheck, fldPath.Child("healthCheck"))...)
+	return append(allErrs, ValidateResourceRequirements(&spec.Resources, fldPath.Child("resources"))...)
+}
+
+// validateModelHealthCheck checks that the path is absolute and that the durations are
+// positive
+func validateModelHealthCheck(check *ModelHealthCheck, fldPath *field.Path) field.ErrorList {
+	if check == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), check.Path, "must start with /"))
+	}
+	if d := check.PromptTimeout; d != nil && d.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("promptTimeout"), d.Duration.String(), "must be positive"))
+	}
+	if d := check.Interval; d != nil && d.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("interval"), d.Duration.String(), "must be positive"))
+	}
+	return allErrs
+}
+
+// validateModelAutoscaling checks that the replica range is ordered and within
+// MaxReplicas, that a concurrency target names its metric, and that a model scaled on
+// CPU utilization requests CPU
+func validateModelAutoscaling(spec *LLMModelSpec, specPath *field.Path) field.ErrorList {
+	autoscaling := spec.Autoscaling
+	if autoscaling == nil {
+		return nil
+	}
+	fldPath := specPath.Child("autoscaling")
+	var allErrs field.ErrorList
+	if err := ValidateReplicas(autoscaling.MaxReplicas); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxReplicas"), autoscaling.MaxReplicas,
+			fmt.Sprintf("exceeds the maximum of %d", MaxReplicas)))
+	}
+	if autoscaling.MinReplicas > autoscaling.MaxReplicas {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("minReplicas"), autoscaling.MinReplicas,
+			"must not be greater than maxReplicas"))
+	}
+	if autoscaling.TargetConcurrency != 0 && autoscaling.ConcurrencyMetric == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("concurrencyMetric"),
+			"is required with targetConcurrency"))
+	}
+	if autoscaling.CPUUtilizationTarget() != 0 && spec.Resources.CPURequest() == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("resources", "cpu"),
+			"is required to scale on CPU utilization"))
+	}
+	return allErrs
+}
+
+// validateModelWeightsAccess checks that a model running more than one replica keeps
+// its weights on a volume that every replica can mount
+func validateModelWeightsAccess(spec *LLMModelSpec, fldPath *field.Path) field.ErrorList {
+	if spec.Provider == ProviderHuggingFace {
+		if spec.WeightsAccessMode != "" {
+			return field.ErrorList{field.Forbidden(fldPath.Child("weightsAccessMode"),
+				"huggingface models share the model cache, whose access mode the operator configures")}
+		}
+		return nil
+	}
+	if spec.MaxReplicasWanted() <= 1 || spec.WeightsAccessMode == corev1.ReadWriteMany {
+		return nil
+	}
+	path := fldPath.Child("replicas")
+	if spec.Autoscaling != nil {
+		path = fldPath.Child("autoscaling", "maxReplicas")
+	}
+	return field.ErrorList{field.Forbidden(path,
+		"more than one replica requires weightsAccessMode ReadWriteMany, as a ReadWriteOnce volume is only mounted on one node")}
+}
+
+// ValidateLLMModelUpdate returns the violations of ValidateLLMModelSpec the update
+// introduces, so that models created before a rule was added can still be edited, and
+// changes to the access mode of the weights volume, which was created for the model
+func ValidateLLMModelUpdate(oldSpec, newSpec *LLMModelSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := ratchet(ValidateLLMModelSpec(newSpec, fldPath), ValidateLLMModelSpec(oldSpec, fldPath))
+	if newSpec.WeightsAccessMode != oldSpec.WeightsAccessMode {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("weightsAccessMode"),
+			"cannot change once the weights volume exists; create a new model instead"))
+	}
+	return allErrs
+}
+
+// validateModelRollout checks that the canary names an LLMModel and that the weight
+// and error rate are percentages
+func validateModelRollout(rollout *ModelRollout, fldPath *field.Path) field.ErrorList {
+	if rollout == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if rollout.Canary == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("canary"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(rollout.Canary) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("canary"), rollout.Canary, msg))
+		}
+	}
+	if rollout.Weight < 0 || rollout.Weight > 100 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("weight"), rollout.Weight, "must be between 1 and 100"))
+	}
+	if rollout.MaxErrorRate < 0 || rollout.MaxErrorRate > 100 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxErrorRate"), rollout.MaxErrorRate, "must be between 0 and 100"))
+	}
+	if rollout.MinRequests < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("minRequests"), rollout.MinRequests, "must not be negative"))
+	}
+	return allErrs
+}
+
+// validateModelSource checks that the model name suits its provider. Models without an
+// image are pulled by the default ollama server, so only ollama names can run without one
+func validateModelSource(spec *LLMModelSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	namePath := fldPath.Child("modelName")
+	switch spec.Provider {
+	case "", ProviderOllama:
+		if spec.ModelName == "" {
+			allErrs = append(allErrs, field.Required(namePath, ""))
+		} else if !ollamaModelName.MatchString(spec.ModelName) {
+			allErrs = append(allErrs, field.Invalid(namePath, spec.ModelName,
+				"must be an ollama model name such as llama3 or library/mistral"))
+		}
+		for _, tag := range []struct {
+			name, value string
+		}{{"modelSize", spec.ModelSize}, {"quantization", spec.Quantization}} {
+			if tag.value != "" && !modelTag.MatchString(tag.value) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child(tag.name), tag.value,
+					"must contain only letters, digits, dots and underscores"))
+			}
+		}
+	case ProviderHuggingFace:
+		if spec.ModelName == "" {
+			allErrs = append(allErrs, field.Required(namePath, ""))
+		} else if !huggingFaceModelName.MatchString(spec.ModelName) {
+			allErrs = append(allErrs, field.Invalid(namePath, spec.ModelName,
+				"must be a Hugging Face repository id such as mistralai/Mistral-7B-v0.1"))
+		}
+		if spec.Image == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("image"),
+				"the default ollama image cannot serve huggingface models"))
+		}
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("provider"), spec.Provider,
+			[]string{ProviderOllama, ProviderHuggingFace}))
+	}
+	return allErrs
+}
+
+// quantity parses value, reporting whether it is a valid quantity
+func quantity(value string) (resource.Quantity, bool) {
+	q, err := resource.ParseQuantity(value)
+	return q, err == nil
+}
+
+// Model download phases
+const (
+	ModelDownloadPhasePending     = "Pending"
+	ModelDownloadPhaseDownloading = "Downloading"
+	ModelDownloadPhaseComplete    = "Complete"
+	ModelDownloadPhaseFailed      = "Failed"
+)
+
+// ModelDownload is the progress of the Job downloading a Hugging Face model's weights
+// into the model cache of its namespace
+type ModelDownload struct {
+	// Phase is Pending, Downloading, Complete or Failed
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// Path is the directory in the model cache the weights are downloaded to
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// StartTime is when the download started
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the weights were in the cache
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// FailedAttempts is the number of download pods that failed
+	// +optional
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+
+	// Message explains why the download failed
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// ModelRolloutStatus counts the requests the inference gateway served for each side of
+// a rollout, and how many of them failed
+type ModelRolloutStatus struct {
+	// Canary is the LLMModel the rollout sends requests to
+	Canary string `json:"canary"`
+
+	// Phase is Progressing, Promoted or RolledBack
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// StableRequests is the number of requests served by the model itself
+	// +optional
+	StableRequests int64 `json:"stableRequests,omitempty"`
+
+	// StableErrors is the number of requests to the model itself that failed
+	// +optional
+	StableErrors int64 `json:"stableErrors,omitempty"`
+
+	// CanaryRequests is the number of requests served by the canary
+	// +optional
+	CanaryRequests int64 `json:"canaryRequests,omitempty"`
+
+	// CanaryErrors is the number of requests to the canary that failed
+	// +optional
+	CanaryErrors int64 `json:"canaryErrors,omitempty"`
+
+	// Message explains why the canary was promoted or rolled back
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// LastTransitionTime is when the rollout entered its phase
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// RolloutPhase returns the phase of the model's rollout, or an empty string when it
+// has none or its status is not about its current canary yet
+func (m *LLMModel) RolloutPhase() string {
+	rollout, status := m.Spec.Rollout, m.Status.Rollout
+	if rollout == nil || status == nil || status.Canary != rollout.Canary {
+		return ""
+	}
+	return status.Phase
 }
 
 // LLMModelStatus defines the observed state of LLMModel
@@ -84,6 +748,35 @@ type LLMModelStatus struct {
 	// +optional
 	Endpoint string `json:"endpoint,omitempty"`
 
+	// Image is the image the model runs, after the operator's registry mirror is applied
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// PullAttempts is the number of automatic retries made after a failed model pull
+	// +optional
+	PullAttempts int32 `json:"pullAttempts,omitempty"`
+
+	// LastPullAttempt is when the last automatic retry was made
+	// +optional
+	LastPullAttempt *metav1.Time `json:"lastPullAttempt,omitempty"`
+
+	// Download is the progress of downloading the weights of a huggingface model,
+	// which is served once they are cached
+	// +optional
+	Download *ModelDownload `json:"download,omitempty"`
+
+	// Rollout is the progress of the model's rollout
+	// +optional
+	Rollout *ModelRolloutStatus `json:"rollout,omitempty"`
+
+	// Usage is the inference usage of the model metered by the inference gateway
+	// +optional
+	Usage *InferenceUsage `json:"usage,omitempty"`
+
+	// LastPrompt is when the health check last prompted the model's pods
+	// +optional
+	LastPrompt *metav1.Time `json:"lastPrompt,omitempty"`
+
 	// Conditions represent the latest available observations of the model's state
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
@@ -114,3 +807,77 @@ type LLMModelList struct {
 func init() {
 	SchemeBuilder.Register(&LLMModel{}, &LLMModelList{})
 }
+
+// MaxReplicasWanted returns the most replicas the model may run: Replicas, or the
+// autoscaler's MaxReplicas when it is autoscaled
+func (s *LLMModelSpec) MaxReplicasWanted() int32 {
+	if s.Autoscaling != nil {
+		return s.Autoscaling.MaxReplicas
+	}
+	return s.Replicas
+}
+
+// EffectiveServeProtocol returns ServeProtocol, or the default for the provider when unset
+func (s *LLMModelSpec) EffectiveServeProtocol() string {
+	if s.ServeProtocol != "" {
+		return s.ServeProtocol
+	}
+	if s.Provider == ProviderOllama {
+		return ServeProtocolOllama
+	}
+	return ServeProtocolOpenAI
+}
+
+// ReadinessProbePath returns the HTTP path that lists the served models, used to
+// check that the model server is ready
+func (s *LLMModelSpec) ReadinessProbePath() string {
+	if s.EffectiveServeProtocol() == ServeProtocolOllama {
+		return "/api/tags"
+	}
+	return "/v1/models"
+}
+
+// HealthCheckPath returns the HTTP path the model server is probed on: the health
+// check's path, or ReadinessProbePath
+func (s *LLMModelSpec) HealthCheckPath() string {
+	if s.HealthCheck != nil && s.HealthCheck.Path != "" {
+		return s.HealthCheck.Path
+	}
+	return s.ReadinessProbePath()
+}
+
+// ProxyRoutePrefix returns the path prefix of the model server's API that requests
+// are proxied to
+func (s *LLMModelSpec) ProxyRoutePrefix() string {
+	if s.EffectiveServeProtocol() == ServeProtocolOllama {
+		return "/api"
+	}
+	return "/v1"
+}
+
+// MaxReplicas is the maximum number of replicas accepted for an LLM model or service.
+// It can be overridden at startup with the --max-replicas flag; zero disables the limit.
+var MaxReplicas int32 = 20
+
+// ratchet drops the errors of an update that the old object already had, by field and
+// type, so that objects stored before a validation rule was added can still be edited
+// as long as the update does not make them invalid anew
+func ratchet(allErrs, oldErrs field.ErrorList) field.ErrorList {
+	var errs field.ErrorList
+	for _, err := range allErrs {
+		if !slices.ContainsFunc(oldErrs, func(old *field.Error) bool {
+			return old.Field == err.Field && old.Type == err.Type
+		}) {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// ValidateReplicas checks that replicas does not exceed MaxReplicas
+func ValidateReplicas(replicas int32) error {
+	if MaxReplicas > 0 && replicas > MaxReplicas {
+		return fmt.Errorf("replicas %d exceeds the maximum of %d", replicas, MaxReplicas)
+	}
+	return nil
+}
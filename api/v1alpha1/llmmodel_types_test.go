@@ -0,0 +1,356 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestLLMModelServeProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        LLMModelSpec
+		protocol    string
+		probePath   string
+		routePrefix string
+	}{
+		{
+			name:        "ollama provider defaults to ollama",
+			spec:        LLMModelSpec{ModelName: "llama3", Provider: "ollama"},
+			protocol:    ServeProtocolOllama,
+			probePath:   "/api/tags",
+			routePrefix: "/api",
+		},
+		{
+			name:        "huggingface provider defaults to openai",
+			spec:        LLMModelSpec{ModelName: "mistral", Provider: "huggingface"},
+			protocol:    ServeProtocolOpenAI,
+			probePath:   "/v1/models",
+			routePrefix: "/v1",
+		},
+		{
+			name:        "no provider defaults to openai",
+			spec:        LLMModelSpec{ModelName: "mistral"},
+			protocol:    ServeProtocolOpenAI,
+			probePath:   "/v1/models",
+			routePrefix: "/v1",
+		},
+		{
+			name:        "explicit openai overrides ollama provider",
+			spec:        LLMModelSpec{ModelName: "llama3", Provider: "ollama", ServeProtocol: ServeProtocolOpenAI},
+			protocol:    ServeProtocolOpenAI,
+			probePath:   "/v1/models",
+			routePrefix: "/v1",
+		},
+		{
+			name:        "explicit ollama",
+			spec:        LLMModelSpec{ModelName: "llama3", Provider: "huggingface", ServeProtocol: ServeProtocolOllama},
+			protocol:    ServeProtocolOllama,
+			probePath:   "/api/tags",
+			routePrefix: "/api",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spec.EffectiveServeProtocol(); got != tt.protocol {
+				t.Errorf("EffectiveServeProtocol() = %q, want %q", got, tt.protocol)
+			}
+			if got := tt.spec.ReadinessProbePath(); got != tt.probePath {
+				t.Errorf("ReadinessProbePath() = %q, want %q", got, tt.probePath)
+			}
+			if got := tt.spec.ProxyRoutePrefix(); got != tt.routePrefix {
+				t.Errorf("ProxyRoutePrefix() = %q, want %q", got, tt.routePrefix)
+			}
+		})
+	}
+}
+
+func TestValidateReplicas(t *testing.T) {
+	orig := MaxReplicas
+	defer func() { MaxReplicas = orig }()
+	MaxReplicas = 10
+
+	for _, replicas := range []int32{0, 1, 9, 10} {
+		if err := ValidateReplicas(replicas); err != nil {
+			t.Errorf("Expected %d replicas to be accepted, got %v", replicas, err)
+		}
+	}
+	for _, replicas := range []int32{11, 1000} {
+		if err := ValidateReplicas(replicas); err == nil {
+			t.Errorf("Expected %d replicas to be rejected", replicas)
+		}
+	}
+
+	MaxReplicas = 0
+	if err := ValidateReplicas(1000); err != nil {
+		t.Errorf("Expected no limit when MaxReplicas is 0, got %v", err)
+	}
+}
+
+func TestPodResources(t *testing.T) {
+	tests := []struct {
+		name     string
+		res      ResourceRequirements
+		requests corev1.ResourceList
+		limits   corev1.ResourceList
+	}{
+		{
+			name: "shorthand sets requests and limits",
+			res:  ResourceRequirements{CPU: "2", Memory: "8Gi"},
+			requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+			limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+		{
+			name: "explicit requests and limits override the shorthand",
+			res: ResourceRequirements{
+				CPU:      "2",
+				Memory:   "8Gi",
+				Requests: &ComputeResources{CPU: "500m"},
+				Limits:   &ComputeResources{Memory: "16Gi"},
+				GPU:      1,
+			},
+			requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("500m"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+			limits: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("2"),
+				corev1.ResourceMemory: resource.MustParse("16Gi"),
+				GPUResourceName:       resource.MustParse("1"),
+			},
+		},
+		{
+			name:     "requests without limits",
+			res:      ResourceRequirements{Requests: &ComputeResources{CPU: "1", Memory: "1Gi"}},
+			requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.res.PodResources()
+			if err != nil {
+				t.Fatalf("PodResources() error = %v", err)
+			}
+			if !equalResourceLists(got.Requests, tt.requests) {
+				t.Errorf("Requests = %v, want %v", got.Requests, tt.requests)
+			}
+			if !equalResourceLists(got.Limits, tt.limits) {
+				t.Errorf("Limits = %v, want %v", got.Limits, tt.limits)
+			}
+		})
+	}
+}
+
+func equalResourceLists(a, b corev1.ResourceList) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, q := range a {
+		if other, ok := b[name]; !ok || q.Cmp(other) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestValidateResourceRequirements(t *testing.T) {
+	tests := []struct {
+		name   string
+		res    ResourceRequirements
+		fields []string
+	}{
+		{name: "empty"},
+		{name: "shorthand", res: ResourceRequirements{CPU: "1", Memory: "1Gi"}},
+		{name: "burstable", res: ResourceRequirements{Requests: &ComputeResources{CPU: "500m"}, Limits: &ComputeResources{CPU: "2"}}},
+		{
+			name:   "request above limit",
+			res:    ResourceRequirements{Requests: &ComputeResources{Memory: "4Gi"}, Limits: &ComputeResources{Memory: "2Gi"}},
+			fields: []string{"spec.resources.requests.memory"},
+		},
+		{
+			name:   "limit below the shorthand request",
+			res:    ResourceRequirements{CPU: "2", Limits: &ComputeResources{CPU: "1"}},
+			fields: []string{"spec.resources.limits.cpu"},
+		},
+		{
+			name:   "non-positive amounts",
+			res:    ResourceRequirements{CPU: "0", Requests: &ComputeResources{Memory: "-1Gi"}},
+			fields: []string{"spec.resources.cpu", "spec.resources.requests.memory"},
+		},
+		{
+			name:   "unparsable amounts",
+			res:    ResourceRequirements{CPU: "lots", Limits: &ComputeResources{Memory: "big"}, GPU: -1},
+			fields: []string{"spec.resources.cpu", "spec.resources.limits.memory", "spec.resources.gpu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateResourceRequirements(&tt.res, field.NewPath("spec", "resources"))
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.fields), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tt.fields[i] {
+					t.Errorf("Error %d on %s, want %s", i, err.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLLMModelSpec(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   LLMModelSpec
+		fields []string
+	}{
+		{name: "ollama default", spec: LLMModelSpec{ModelName: "llama3", ModelSize: "8b", Quantization: "q4_0"}},
+		{name: "ollama namespaced", spec: LLMModelSpec{ModelName: "library/mistral", Provider: ProviderOllama}},
+		{
+			name: "huggingface with image",
+			spec: LLMModelSpec{ModelName: "mistralai/Mistral-7B-v0.1", Provider: ProviderHuggingFace, Image: "vllm/vllm-openai"},
+		},
+		{name: "missing name", spec: LLMModelSpec{}, fields: []string{"spec.modelName"}},
+		{
+			name:   "invalid ollama name and tags",
+			spec:   LLMModelSpec{ModelName: "Llama 3", ModelSize: "8b:latest", Quantization: "-q4"},
+			fields: []string{"spec.modelName", "spec.modelSize", "spec.quantization"},
+		},
+		{
+			name:   "huggingface without image or organization",
+			spec:   LLMModelSpec{ModelName: "mistral", Provider: ProviderHuggingFace},
+			fields: []string{"spec.modelName", "spec.image"},
+		},
+		{name: "unknown provider", spec: LLMModelSpec{ModelName: "llama3", Provider: "openai"}, fields: []string{"spec.provider"}},
+		{
+			name: "autoscaling",
+			spec: LLMModelSpec{ModelName: "llama3", WeightsAccessMode: corev1.ReadWriteMany,
+				Autoscaling: &ModelAutoscaling{MinReplicas: 1, MaxReplicas: 4, TargetConcurrency: 8, ConcurrencyMetric: "vllm:num_requests_running"}},
+		},
+		{
+			name: "autoscaling on CPU by default",
+			spec: LLMModelSpec{ModelName: "llama3", WeightsAccessMode: corev1.ReadWriteMany, Resources: ResourceRequirements{CPU: "2"},
+				Autoscaling: &ModelAutoscaling{MinReplicas: 1, MaxReplicas: 4}},
+		},
+		{
+			name: "concurrency target without its metric",
+			spec: LLMModelSpec{ModelName: "llama3", WeightsAccessMode: corev1.ReadWriteMany,
+				Autoscaling: &ModelAutoscaling{MinReplicas: 1, MaxReplicas: 4, TargetConcurrency: 8}},
+			fields: []string{"spec.autoscaling.concurrencyMetric", "spec.resources.cpu"},
+		},
+		{
+			name: "autoscaling beyond the maximum",
+			spec: LLMModelSpec{ModelName: "llama3", WeightsAccessMode: corev1.ReadWriteMany,
+				Autoscaling: &ModelAutoscaling{MinReplicas: 2, MaxReplicas: MaxReplicas + 1, TargetGPUUtilization: 80}},
+			fields: []string{"spec.autoscaling.maxReplicas"},
+		},
+		{
+			name:   "autoscaling on CPU without a CPU request",
+			spec:   LLMModelSpec{ModelName: "llama3", Autoscaling: &ModelAutoscaling{MinReplicas: 3, MaxReplicas: 2}},
+			fields: []string{"spec.autoscaling.minReplicas", "spec.resources.cpu", "spec.autoscaling.maxReplicas"},
+		},
+		{name: "replicas on a ReadWriteOnce volume", spec: LLMModelSpec{ModelName: "llama3", Replicas: 2}, fields: []string{"spec.replicas"}},
+		{name: "replicas on a ReadWriteMany volume", spec: LLMModelSpec{ModelName: "llama3", Replicas: 2, WeightsAccessMode: corev1.ReadWriteMany}},
+		{
+			name:   "huggingface with a weights access mode",
+			spec:   LLMModelSpec{ModelName: "mistralai/Mistral-7B-v0.1", Provider: ProviderHuggingFace, Image: "vllm/vllm-openai", WeightsAccessMode: corev1.ReadWriteMany},
+			fields: []string{"spec.weightsAccessMode"},
+		},
+		{name: "rollout", spec: LLMModelSpec{ModelName: "llama3", Rollout: &ModelRollout{Canary: "llama3-1", Weight: 20}}},
+		{
+			name:   "invalid rollout",
+			spec:   LLMModelSpec{ModelName: "llama3", Rollout: &ModelRollout{Canary: "Llama 3.1", Weight: 120, MaxErrorRate: -1}},
+			fields: []string{"spec.rollout.canary", "spec.rollout.weight", "spec.rollout.maxErrorRate"},
+		},
+		{name: "preset", spec: LLMModelSpec{Preset: "mistral-7b-q4"}},
+		{name: "unknown preset", spec: LLMModelSpec{Preset: "gpt-5"}, fields: []string{"spec.preset"}},
+		{
+			name:   "preset with a limit below its memory",
+			spec:   LLMModelSpec{Preset: "mistral-7b-q4", Resources: ResourceRequirements{Limits: &ComputeResources{Memory: "1Gi"}}},
+			fields: []string{"spec.resources.limits.memory"},
+		},
+		{
+			name: "health check",
+			spec: LLMModelSpec{ModelName: "llama3", HealthCheck: &ModelHealthCheck{Path: "/health", PromptTimeout: &metav1.Duration{Duration: 30 * time.Second}}},
+		},
+		{
+			name: "invalid health check",
+			spec: LLMModelSpec{ModelName: "llama3", HealthCheck: &ModelHealthCheck{
+				Path:          "health",
+				PromptTimeout: &metav1.Duration{},
+				Interval:      &metav1.Duration{Duration: -time.Minute},
+			}},
+			fields: []string{"spec.healthCheck.path", "spec.healthCheck.promptTimeout", "spec.healthCheck.interval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateLLMModelSpec(&tt.spec, field.NewPath("spec"))
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.fields), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tt.fields[i] {
+					t.Errorf("Error %d on %s, want %s", i, err.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateLLMModelUpdate(t *testing.T) {
+	// Stored before more than one replica required a ReadWriteMany volume
+	old := LLMModelSpec{ModelName: "llama3", Replicas: 2}
+	spec := old
+	spec.Replicas = 3
+	if errs := ValidateLLMModelUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected an existing violation not to block an update, got %v", errs)
+	}
+	spec.WeightsAccessMode = corev1.ReadWriteMany
+	if errs := ValidateLLMModelUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.weightsAccessMode" {
+		t.Errorf("Expected the weights access mode to be immutable, got %v", errs)
+	}
+	single := LLMModelSpec{ModelName: "llama3"}
+	if errs := ValidateLLMModelUpdate(&single, &old, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.replicas" {
+		t.Errorf("Expected scaling out on a ReadWriteOnce volume to be rejected, got %v", errs)
+	}
+}
+
+func TestApplyModelPreset(t *testing.T) {
+	spec := LLMModelSpec{Preset: "mistral-7b-q4", Quantization: "q8_0", Resources: ResourceRequirements{GPU: 1}}
+	if err := ApplyModelPreset(&spec); err != nil {
+		t.Fatalf("Failed to apply preset: %v", err)
+	}
+	want := LLMModelSpec{
+		Preset:        "mistral-7b-q4",
+		ModelName:     "mistral",
+		ModelSize:     "7b",
+		Quantization:  "q8_0",
+		Provider:      ProviderOllama,
+		Image:         "ollama/ollama:latest",
+		Resources:     ResourceRequirements{CPU: "4", Memory: "8Gi", GPU: 1},
+		ContextLength: 32768,
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Errorf("Expected the preset to fill only the empty fields, got %+v", spec)
+	}
+
+	if err := ApplyModelPreset(&LLMModelSpec{Preset: "gpt-5"}); err == nil {
+		t.Error("Expected an unknown preset to be rejected")
+	}
+}
@@ -17,12 +17,26 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
+// GroupMemberPrefix marks a project member naming a Group rather than a user
+const GroupMemberPrefix = "group:"
+
 // ProjectMember defines a member of the project
 type ProjectMember struct {
-	// Username is the Kubernetes user or service account name
+	// Username is the Kubernetes user or service account name, or group:<name> to make
+	// every user in the named Group a member
 	// +kubebuilder:validation:Required
 	Username string `json:"username"`
 
@@ -32,6 +46,15 @@ type ProjectMember struct {
 	Role string `json:"role"`
 }
 
+// Group returns the name of the Group the member refers to, or "" for a user
+func (m ProjectMember) Group() string {
+	group, ok := strings.CutPrefix(m.Username, GroupMemberPrefix)
+	if !ok {
+		return ""
+	}
+	return group
+}
+
 // ProjectSpec defines the desired state of Project
 type ProjectSpec struct {
 	// Description is a human-readable description of the project
@@ -45,6 +68,72 @@ type ProjectSpec struct {
 	// ResourceQuotas defines resource limits for the project
 	// +optional
 	ResourceQuotas *ProjectResourceQuotas `json:"resourceQuotas,omitempty"`
+
+	// Labels are applied to the project namespace and inherited by the VMs, LLM models
+	// and services in it, unless a workload sets the same label itself. Labels removed
+	// from the project are removed from them again. Keys under llmcloud.io, kubernetes.io
+	// and k8s.io are reserved
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are applied to the project namespace and inherited by its workloads
+	// in the same way as Labels
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Egress restricts outbound traffic from the project namespace. When set, pods may
+	// only reach DNS, other pods in the project and the listed destinations
+	// +optional
+	Egress *ProjectEgressPolicy `json:"egress,omitempty"`
+
+	// IsolationMode controls which inbound traffic the project namespace admits:
+	// "strict" only admits traffic from the project's own pods and from namespaces that
+	// are not projects, such as the operator's and the ingress controller's, "custom"
+	// also admits the sources listed in Ingress, and "none" admits all traffic
+	// +kubebuilder:validation:Enum=none;strict;custom
+	// +kubebuilder:default=strict
+	// +optional
+	IsolationMode string `json:"isolationMode,omitempty"`
+
+	// Ingress lists the sources a project in custom isolation mode admits in addition
+	// to those of strict mode
+	// +optional
+	Ingress *ProjectIngressPolicy `json:"ingress,omitempty"`
+
+	// TTL deletes the project, and everything in it, this long after it was created
+	// (e.g., "72h"), for short-lived experiments that clean up after themselves. The
+	// project is never deleted when unset
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// ProjectEgressPolicy lists the destinations a project's pods may connect to
+type ProjectEgressPolicy struct {
+	// AllowedCIDRs are IP ranges outbound traffic is allowed to (e.g., "10.0.0.0/8")
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+
+	// AllowedNamespaces are namespaces whose pods outbound traffic is allowed to
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
+
+// Project isolation modes
+const (
+	IsolationModeNone   = "none"
+	IsolationModeStrict = "strict"
+	IsolationModeCustom = "custom"
+)
+
+// ProjectIngressPolicy lists the sources a project's pods may be reached from
+type ProjectIngressPolicy struct {
+	// AllowedCIDRs are IP ranges inbound traffic is allowed from (e.g., "10.0.0.0/8")
+	// +optional
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+
+	// AllowedNamespaces are namespaces whose pods inbound traffic is allowed from
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
 }
 
 // ProjectResourceQuotas defines resource quotas for a project
@@ -88,6 +177,37 @@ type ProjectStatus struct {
 	// +optional
 	ServiceCount int32 `json:"serviceCount,omitempty"`
 
+	// ReadyVMCount is the number of VMs in the project that are ready
+	// +optional
+	ReadyVMCount int32 `json:"readyVMCount,omitempty"`
+
+	// ReadyLLMModelCount is the number of LLM models in the project that are ready
+	// +optional
+	ReadyLLMModelCount int32 `json:"readyLLMModelCount,omitempty"`
+
+	// ReadyServiceCount is the number of services in the project that are ready
+	// +optional
+	ReadyServiceCount int32 `json:"readyServiceCount,omitempty"`
+
+	// HealthyWorkloads is the total number of ready VMs, LLM models and services
+	// +optional
+	HealthyWorkloads int32 `json:"healthyWorkloads,omitempty"`
+
+	// CPUUsage is the CPU requested by the VMs, LLM models and services, counting
+	// every replica
+	// +optional
+	CPUUsage string `json:"cpuUsage,omitempty"`
+
+	// MemoryUsage is the memory requested by the VMs, LLM models and services,
+	// counting every replica
+	// +optional
+	MemoryUsage string `json:"memoryUsage,omitempty"`
+
+	// InferenceUsage is the inference usage of the project's LLM models metered by the
+	// inference gateway. It includes the usage of models that were since deleted
+	// +optional
+	InferenceUsage *InferenceUsage `json:"inferenceUsage,omitempty"`
+
 	// Conditions represent the current state of the Project resource
 	// +listType=map
 	// +listMapKey=type
@@ -101,6 +221,7 @@ type ProjectStatus struct {
 // +kubebuilder:printcolumn:name="Namespace",type="string",JSONPath=".status.namespace"
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="VMs",type="integer",JSONPath=".status.vmCount"
+// +kubebuilder:printcolumn:name="Healthy",type="integer",JSONPath=".status.healthyWorkloads"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Project is the Schema for the projects API
@@ -125,3 +246,162 @@ type ProjectList struct {
 func init() {
 	SchemeBuilder.Register(&Project{}, &ProjectList{})
 }
+
+// ReservedProjectNames are names that cannot be used for projects because they
+// would be confused with system or operator namespaces
+var ReservedProjectNames = []string{
+	"default",
+	"system",
+	"kube-system",
+	"kube-public",
+	"kube-node-lease",
+	"kubevirt",
+	"cdi",
+	"local-path-storage",
+	"llmcloud-operator-system",
+}
+
+// ValidateProjectName checks that a project name is not reserved and that the
+// derived namespace name (project-<name>) is a valid DNS-1123 label
+func ValidateProjectName(name string) error {
+	if name == "" {
+		return fmt.Errorf("project name is required")
+	}
+	if slices.Contains(ReservedProjectNames, name) || strings.HasPrefix(name, "kube-") {
+		return fmt.Errorf("project name %q is reserved", name)
+	}
+	if errs := validation.IsDNS1123Label("project-" + name); len(errs) > 0 {
+		return fmt.Errorf("invalid project name %q: %s", name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ValidateProjectSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateProjectSpec(spec *ProjectSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := normalizeResourceQuotas(spec.ResourceQuotas.DeepCopy(), fldPath.Child("resourceQuotas"))
+	for i, member := range spec.Members {
+		if group, ok := strings.CutPrefix(member.Username, GroupMemberPrefix); ok {
+			for _, msg := range validation.IsDNS1123Subdomain(group) {
+				allErrs = append(allErrs, field.Invalid(fldPath.Child("members").Index(i).Child("username"), member.Username, msg))
+			}
+		}
+	}
+	allErrs = append(allErrs, validateProjectMetadata(spec.Labels, true, fldPath.Child("labels"))...)
+	allErrs = append(allErrs, validateProjectMetadata(spec.Annotations, false, fldPath.Child("annotations"))...)
+	if spec.Egress != nil {
+		allErrs = append(allErrs, validateNetworkPeers(spec.Egress.AllowedCIDRs, spec.Egress.AllowedNamespaces, fldPath.Child("egress"))...)
+	}
+	if spec.Ingress != nil {
+		ingressPath := fldPath.Child("ingress")
+		if spec.IsolationMode != IsolationModeCustom {
+			allErrs = append(allErrs, field.Forbidden(ingressPath, "may only be set when isolationMode is custom"))
+		}
+		allErrs = append(allErrs, validateNetworkPeers(spec.Ingress.AllowedCIDRs, spec.Ingress.AllowedNamespaces, ingressPath)...)
+	}
+	return append(allErrs, validateTTL(spec.TTL, fldPath.Child("ttl"))...)
+}
+
+// reservedMetadataDomains are the key prefixes of the labels and annotations set by the
+// operator and Kubernetes, which a project may not set on its namespace and workloads
+var reservedMetadataDomains = []string{"llmcloud.io", "kubernetes.io", "k8s.io"}
+
+// validateProjectMetadata checks the keys of the labels, or annotations, a project sets,
+// and the values of labels
+func validateProjectMetadata(metadata map[string]string, labels bool, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		keyPath := fldPath.Key(key)
+		for _, msg := range validation.IsQualifiedName(key) {
+			allErrs = append(allErrs, field.Invalid(keyPath, key, msg))
+		}
+		prefix, _, ok := strings.Cut(key, "/")
+		if ok && slices.ContainsFunc(reservedMetadataDomains, func(domain string) bool {
+			return prefix == domain || strings.HasSuffix(prefix, "."+domain)
+		}) {
+			allErrs = append(allErrs, field.Forbidden(keyPath, fmt.Sprintf("the prefix %s is reserved", prefix)))
+		}
+		if labels {
+			for _, msg := range validation.IsValidLabelValue(metadata[key]) {
+				allErrs = append(allErrs, field.Invalid(keyPath, metadata[key], msg))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateNetworkPeers checks the CIDRs and namespace names a NetworkPolicy of the
+// project allows traffic to or from
+func validateNetworkPeers(cidrs, namespaces []string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	for i, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("allowedCIDRs").Index(i), cidr, "must be a CIDR such as 10.0.0.0/8"))
+		}
+	}
+	for i, namespace := range namespaces {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("allowedNamespaces").Index(i), namespace, strings.Join(errs, "; ")))
+		}
+	}
+	return allErrs
+}
+
+// ExpiryTime returns when obj expires under ttl, counting from its creation, and
+// false when ttl is unset
+func ExpiryTime(obj metav1.Object, ttl *metav1.Duration) (time.Time, bool) {
+	if ttl == nil {
+		return time.Time{}, false
+	}
+	return obj.GetCreationTimestamp().Add(ttl.Duration), true
+}
+
+func validateTTL(ttl *metav1.Duration, fldPath *field.Path) field.ErrorList {
+	if ttl != nil && ttl.Duration <= 0 {
+		return field.ErrorList{field.Invalid(fldPath, ttl.Duration.String(), "must be positive")}
+	}
+	return nil
+}
+
+// ValidateResourceQuotas checks that the CPU and memory limits of quotas, when set,
+// are non-negative Kubernetes quantities (e.g., "10", "500m", "20Gi")
+func ValidateResourceQuotas(quotas *ProjectResourceQuotas) error {
+	return NormalizeResourceQuotas(quotas.DeepCopy())
+}
+
+// NormalizeResourceQuotas validates quotas like ValidateResourceQuotas and rewrites
+// the CPU and memory limits in canonical form (e.g., "0.5" becomes "500m")
+func NormalizeResourceQuotas(quotas *ProjectResourceQuotas) error {
+	return normalizeResourceQuotas(quotas, nil).ToAggregate()
+}
+
+func normalizeResourceQuotas(quotas *ProjectResourceQuotas, fldPath *field.Path) field.ErrorList {
+	if quotas == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	for _, limit := range []struct {
+		name    string
+		value   *string
+		example string
+	}{
+		{"maxCPU", quotas.MaxCPU, "10 or 500m"},
+		{"maxMemory", quotas.MaxMemory, "20Gi"},
+	} {
+		if limit.value == nil {
+			continue
+		}
+		q, err := resource.ParseQuantity(*limit.value)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(limit.name), *limit.value,
+				fmt.Sprintf("must be a quantity such as %s", limit.example)))
+			continue
+		}
+		if q.Sign() < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(limit.name), *limit.value, "must not be negative"))
+			continue
+		}
+		*limit.value = q.String()
+	}
+	return allErrs
+}
@@ -1,9 +1,12 @@
 package v1alpha1
 
 import (
+	"slices"
 	"testing"
+	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 func TestProjectSpec(t *testing.T) {
@@ -210,3 +213,108 @@ func TestProjectWithResourceQuotas(t *testing.T) {
 		t.Errorf("Expected MaxVMs 5, got %d", *project.Spec.ResourceQuotas.MaxVMs)
 	}
 }
+
+func TestNormalizeResourceQuotas(t *testing.T) {
+	quantity := func(s string) *string { return &s }
+
+	quotas := &ProjectResourceQuotas{MaxCPU: quantity("0.5"), MaxMemory: quantity("20Gi")}
+	if err := NormalizeResourceQuotas(quotas); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if *quotas.MaxCPU != "500m" || *quotas.MaxMemory != "20Gi" {
+		t.Errorf("Expected canonical quantities, got %q and %q", *quotas.MaxCPU, *quotas.MaxMemory)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		quotas *ProjectResourceQuotas
+	}{
+		{"memory with unit typo", &ProjectResourceQuotas{MaxMemory: quantity("20 GB")}},
+		{"cpu word", &ProjectResourceQuotas{MaxCPU: quantity("lots")}},
+		{"negative memory", &ProjectResourceQuotas{MaxMemory: quantity("-1Gi")}},
+	} {
+		original := *tt.quotas.DeepCopy()
+		if err := ValidateResourceQuotas(tt.quotas); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+		if tt.quotas.MaxMemory != nil && *tt.quotas.MaxMemory != *original.MaxMemory {
+			t.Errorf("%s: ValidateResourceQuotas must not modify the quotas", tt.name)
+		}
+	}
+
+	if err := ValidateResourceQuotas(nil); err != nil {
+		t.Errorf("Expected nil quotas to be valid, got %v", err)
+	}
+}
+
+func TestProjectTTL(t *testing.T) {
+	created := time.Date(2025, time.June, 2, 12, 0, 0, 0, time.UTC)
+	project := &Project{ObjectMeta: metav1.ObjectMeta{Name: "experiment", CreationTimestamp: metav1.NewTime(created)}}
+	if _, ok := ExpiryTime(project, project.Spec.TTL); ok {
+		t.Error("Expected a project without a TTL never to expire")
+	}
+	project.Spec.TTL = &metav1.Duration{Duration: 72 * time.Hour}
+	if expiresAt, ok := ExpiryTime(project, project.Spec.TTL); !ok || !expiresAt.Equal(created.Add(72*time.Hour)) {
+		t.Errorf("Expected the project to expire 72h after its creation, got %v", expiresAt)
+	}
+	if errs := ValidateProjectSpec(&project.Spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+
+	project.Spec.TTL.Duration = 0
+	if errs := ValidateProjectSpec(&project.Spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.ttl" {
+		t.Errorf("Expected spec.ttl to be invalid, got %v", errs)
+	}
+}
+
+func TestProjectIngress(t *testing.T) {
+	spec := &ProjectSpec{
+		IsolationMode: IsolationModeCustom,
+		Ingress:       &ProjectIngressPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}, AllowedNamespaces: []string{"monitoring"}},
+	}
+	if errs := ValidateProjectSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+
+	spec.Ingress.AllowedCIDRs = []string{"10.0.0.0"}
+	if errs := ValidateProjectSpec(spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.ingress.allowedCIDRs[0]" {
+		t.Errorf("Expected spec.ingress.allowedCIDRs[0] to be invalid, got %v", errs)
+	}
+
+	spec.Ingress.AllowedCIDRs = nil
+	spec.IsolationMode = IsolationModeStrict
+	if errs := ValidateProjectSpec(spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Type != field.ErrorTypeForbidden {
+		t.Errorf("Expected ingress outside custom mode to be forbidden, got %v", errs)
+	}
+}
+
+func TestProjectMetadata(t *testing.T) {
+	spec := &ProjectSpec{
+		Labels:      map[string]string{"team": "ml", "example.com/cost-center": "42"},
+		Annotations: map[string]string{"example.com/owner": "Alice <alice@example.com>"},
+	}
+	if errs := ValidateProjectSpec(spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Unexpected errors %v", errs)
+	}
+
+	spec.Labels = map[string]string{
+		"llmcloud.io/managed":                "false",
+		"pod-security.kubernetes.io/enforce": "privileged",
+		"team":                               "not a label value",
+	}
+	spec.Annotations = map[string]string{"llmcloud.io/project-labels": "[]", "not a key": ""}
+	var got []string
+	for _, err := range ValidateProjectSpec(spec, field.NewPath("spec")) {
+		got = append(got, err.Field)
+	}
+	want := []string{
+		"spec.labels[llmcloud.io/managed]",
+		"spec.labels[pod-security.kubernetes.io/enforce]",
+		"spec.labels[team]",
+		"spec.annotations[llmcloud.io/project-labels]",
+		"spec.annotations[not a key]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected %v to be rejected, got %v", want, got)
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import "slices"
+
+// Managed service types, which the operator runs as a database with persistent
+// storage, generated credentials and scheduled backups when a Service sets managed
+const (
+	ServiceTypePostgreSQL = "postgresql"
+	ServiceTypeRedis      = "redis"
+	ServiceTypeMinIO      = "minio"
+)
+
+// ServicePreset is a managed service type in ServiceCatalog, with the image, ports,
+// resources and storage it runs with by default
+type ServicePreset struct {
+	// Type is what a Service sets as its type (e.g., "postgresql")
+	Type string `json:"type"`
+	// Description is a short summary of the service for the catalog
+	Description string `json:"description,omitempty"`
+
+	Image     string               `json:"image"`
+	Ports     []ServicePort        `json:"ports"`
+	Resources ResourceRequirements `json:"resources,omitempty"`
+	// StorageSize is the size of the data volume
+	StorageSize string `json:"storageSize"`
+}
+
+// ServiceCatalog lists the managed service types
+var ServiceCatalog = []ServicePreset{
+	{
+		Type:        ServiceTypePostgreSQL,
+		Description: "PostgreSQL 16 database",
+		Image:       "postgres:16-alpine",
+		Ports:       []ServicePort{{Name: "postgresql", Port: 5432}},
+		Resources:   ResourceRequirements{CPU: "1", Memory: "1Gi"},
+		StorageSize: "10Gi",
+	},
+	{
+		Type:        ServiceTypeRedis,
+		Description: "Redis 7 key-value store, persisted with an append-only file",
+		Image:       "redis:7-alpine",
+		Ports:       []ServicePort{{Name: "redis", Port: 6379}},
+		Resources:   ResourceRequirements{CPU: "500m", Memory: "512Mi"},
+		StorageSize: "2Gi",
+	},
+	{
+		Type:        ServiceTypeMinIO,
+		Description: "MinIO S3-compatible object store, with its console on port 9001",
+		Image:       "quay.io/minio/minio:latest",
+		Ports:       []ServicePort{{Name: "api", Port: 9000}, {Name: "console", Port: 9001}},
+		Resources:   ResourceRequirements{CPU: "1", Memory: "1Gi"},
+		StorageSize: "20Gi",
+	},
+}
+
+// LookupServicePreset returns the preset in ServiceCatalog of serviceType, which is
+// found only for managed service types
+func LookupServicePreset(serviceType string) (ServicePreset, bool) {
+	i := slices.IndexFunc(ServiceCatalog, func(p ServicePreset) bool { return p.Type == serviceType })
+	if i < 0 {
+		return ServicePreset{}, false
+	}
+	return ServiceCatalog[i], true
+}
+
+// ApplyServicePreset fills the fields a managed service leaves empty from the preset of
+// its type: the image, the ports, the resource shorthand and the storage size. Other
+// services are left unchanged
+func ApplyServicePreset(spec *ServiceSpec) {
+	preset, ok := spec.ManagedPreset()
+	if !ok {
+		return
+	}
+	if spec.Image == "" {
+		spec.Image = preset.Image
+	}
+	if len(spec.Ports) == 0 {
+		spec.Ports = slices.Clone(preset.Ports)
+	}
+	if spec.Resources.CPU == "" {
+		spec.Resources.CPU = preset.Resources.CPU
+	}
+	if spec.Resources.Memory == "" {
+		spec.Resources.Memory = preset.Resources.Memory
+	}
+	if spec.Storage == nil {
+		spec.Storage = &ServiceStorage{}
+	}
+	if spec.Storage.Size == "" {
+		spec.Storage.Size = preset.StorageSize
+	}
+}
@@ -17,23 +17,42 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"cmp"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // Service phase constants
 const (
 	ServicePhasePending = "Pending"
+	ServicePhaseRunning = "Running"
 )
 
 // ServiceSpec defines the desired state of Service
 type ServiceSpec struct {
-	// Type is the type of service (e.g., "api", "web", "worker")
+	// Type is the type of service (e.g., "api", "web", "worker"). A managed service
+	// names a type of ServiceCatalog: postgresql, redis or minio
 	Type string `json:"type"`
 
-	// Image is the container image to run
-	Image string `json:"image"`
+	// Managed runs the service as the ServiceCatalog type named by Type: a single replica
+	// with a data volume, generated credentials and optional scheduled backups, whose
+	// image, ports and resources default to those of its preset. It cannot change once
+	// the service exists
+	// +optional
+	Managed bool `json:"managed,omitempty"`
+
+	// Image is the container image to run. It is required unless the service is managed
+	// +optional
+	Image string `json:"image,omitempty"`
 
-	// Replicas is the desired number of replicas
+	// Replicas is the desired number of replicas, at most one for a managed service
 	// +optional
 	Replicas int32 `json:"replicas,omitempty"`
 
@@ -56,6 +75,67 @@ type ServiceSpec struct {
 	// Args overrides the default container args
 	// +optional
 	Args []string `json:"args,omitempty"`
+
+	// Ingress exposes the service's first port outside the cluster. It requires ports
+	// +optional
+	Ingress *ServiceIngress `json:"ingress,omitempty"`
+
+	// Storage is the data volume of a managed service. It cannot change once the
+	// service exists
+	// +optional
+	Storage *ServiceStorage `json:"storage,omitempty"`
+
+	// Backup schedules backups of a managed service's data to a volume of its own
+	// +optional
+	Backup *ServiceBackup `json:"backup,omitempty"`
+}
+
+// ServiceStorage is the persistent volume holding a managed service's data
+type ServiceStorage struct {
+	// Size is the size of the volume (e.g., "10Gi"), the preset's size when empty
+	// +optional
+	Size string `json:"size,omitempty"`
+
+	// StorageClass is the storage class of the volume; the cluster default when empty
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// ServiceBackup schedules dumps of a managed service's data
+type ServiceBackup struct {
+	// Schedule is a cron expression (e.g., "0 2 * * *") on which a backup is taken
+	Schedule string `json:"schedule"`
+
+	// Retention is the number of backups to keep
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+
+	// Size is the size of the volume holding the backups, the size of the data volume
+	// when empty
+	// +optional
+	Size string `json:"size,omitempty"`
+}
+
+// ServiceIngress routes external HTTP traffic for a host and path to a service
+type ServiceIngress struct {
+	// Host is the DNS name the service is reached at
+	Host string `json:"host"`
+
+	// Path is the URL path prefix routed to the service
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// ClassName is the IngressClass to use; the cluster default when empty
+	// +optional
+	ClassName string `json:"className,omitempty"`
+
+	// TLSSecretName is a Secret holding the certificate for Host. TLS is not
+	// terminated when empty
+	// +optional
+	TLSSecretName string `json:"tlsSecretName,omitempty"`
 }
 
 // ServicePort defines a port to expose
@@ -116,10 +196,31 @@ type ServiceStatus struct {
 	// +optional
 	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
 
+	// UpdatedReplicas is the number of replicas running the latest rollout
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+
+	// AvailableReplicas is the number of replicas available to serve traffic
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// UnavailableReplicas is the number of replicas still needed for the rollout to be complete
+	// +optional
+	UnavailableReplicas int32 `json:"unavailableReplicas,omitempty"`
+
 	// Endpoint is the service endpoint
 	// +optional
 	Endpoint string `json:"endpoint,omitempty"`
 
+	// Image is the image the service runs, after the operator's registry mirror is applied
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// CredentialsSecret is the Secret holding the username and password of a managed
+	// service, and the database of a postgresql service
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
 	// Conditions represent the latest available observations
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
@@ -150,3 +251,159 @@ type ServiceList struct {
 func init() {
 	SchemeBuilder.Register(&Service{}, &ServiceList{})
 }
+
+// ValidateServiceSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateServiceSpec(spec *ServiceSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if err := ValidateReplicas(spec.Replicas); err != nil {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), spec.Replicas,
+			fmt.Sprintf("exceeds the maximum of %d", MaxReplicas)))
+	}
+	allErrs = append(allErrs, validateManagedService(spec, fldPath)...)
+
+	portNames := map[string]bool{}
+	portNumbers := map[string]bool{}
+	for i, port := range spec.Ports {
+		idxPath := fldPath.Child("ports").Index(i)
+		if errs := validation.IsValidPortNum(int(port.Port)); len(errs) > 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("port"), port.Port, strings.Join(errs, "; ")))
+		}
+		// The same port may be exposed once per protocol
+		if key := fmt.Sprintf("%d/%s", port.Port, cmp.Or(port.Protocol, string(corev1.ProtocolTCP))); portNumbers[key] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("port"), port.Port))
+		} else {
+			portNumbers[key] = true
+		}
+		if port.TargetPort != 0 {
+			if errs := validation.IsValidPortNum(int(port.TargetPort)); len(errs) > 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("targetPort"), port.TargetPort, strings.Join(errs, "; ")))
+			}
+		}
+		switch corev1.Protocol(port.Protocol) {
+		case "", corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP:
+		default:
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("protocol"), port.Protocol,
+				[]corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP, corev1.ProtocolSCTP}))
+		}
+		if port.Name != "" {
+			if portNames[port.Name] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), port.Name))
+			}
+			portNames[port.Name] = true
+		}
+	}
+
+	allErrs = append(allErrs, ValidateResourceRequirements(&spec.Resources, fldPath.Child("resources"))...)
+	allErrs = append(allErrs, validateServiceIngress(spec, fldPath.Child("ingress"))...)
+
+	for i, env := range spec.Env {
+		if errs := validation.IsEnvVarName(env.Name); len(errs) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("env").Index(i).Child("name"), env.Name, strings.Join(errs, "; ")))
+		}
+	}
+	return allErrs
+}
+
+// ManagedPreset returns the preset in ServiceCatalog a managed service runs as. A
+// service that is not managed has none, whatever its type
+func (s *ServiceSpec) ManagedPreset() (ServicePreset, bool) {
+	if !s.Managed {
+		return ServicePreset{}, false
+	}
+	return LookupServicePreset(s.Type)
+}
+
+// validateManagedService checks the fields whose validity depends on whether the
+// service is managed: the type, image, replicas, storage and backup
+func validateManagedService(spec *ServiceSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if _, ok := LookupServicePreset(spec.Type); spec.Managed && !ok {
+		types := make([]string, 0, len(ServiceCatalog))
+		for _, preset := range ServiceCatalog {
+			types = append(types, preset.Type)
+		}
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("type"), spec.Type, types))
+	}
+	if !spec.Managed {
+		if spec.Image == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("image"), "required unless the service is managed"))
+		}
+		if spec.Storage != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("storage"), "only managed services have storage"))
+		}
+		if spec.Backup != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("backup"), "only managed services are backed up"))
+		}
+		return allErrs
+	}
+
+	if spec.Replicas > 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), spec.Replicas, "a managed service runs a single replica"))
+	}
+	if spec.Storage != nil {
+		allErrs = append(allErrs, validateOptionalSize(spec.Storage.Size, fldPath.Child("storage", "size"))...)
+	}
+	if spec.Backup != nil {
+		backupPath := fldPath.Child("backup")
+		if spec.Backup.Schedule == "" {
+			allErrs = append(allErrs, field.Required(backupPath.Child("schedule"), ""))
+		}
+		allErrs = append(allErrs, validateBackupSchedule(spec.Backup.Schedule, backupPath.Child("schedule"))...)
+		allErrs = append(allErrs, validateOptionalSize(spec.Backup.Size, backupPath.Child("size"))...)
+	}
+	return allErrs
+}
+
+// validateOptionalSize checks that a non-empty size is a positive quantity
+func validateOptionalSize(size string, fldPath *field.Path) field.ErrorList {
+	if size == "" {
+		return nil
+	}
+	if q, err := resource.ParseQuantity(size); err != nil || q.Sign() <= 0 {
+		return field.ErrorList{field.Invalid(fldPath, size, "must be a positive quantity such as 10Gi")}
+	}
+	return nil
+}
+
+// ValidateServiceUpdate returns the violations of ValidateServiceSpec the update
+// introduces, so that services created before a rule was added can still be edited, and
+// changes to whether a service is managed or to the type or storage of a managed
+// service, whose data volume was created for them
+func ValidateServiceUpdate(oldSpec, newSpec *ServiceSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := ratchet(ValidateServiceSpec(newSpec, fldPath), ValidateServiceSpec(oldSpec, fldPath))
+	if newSpec.Managed != oldSpec.Managed {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("managed"),
+			"cannot change once the service exists; create a new service instead"))
+	}
+	if !oldSpec.Managed {
+		return allErrs
+	}
+	if newSpec.Type != oldSpec.Type {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("type"), "the type of a managed service cannot change"))
+	}
+	if !equality.Semantic.DeepEqual(oldSpec.Storage, newSpec.Storage) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("storage"), "the storage of a managed service cannot change"))
+	}
+	return allErrs
+}
+
+// validateServiceIngress checks that an ingress has a valid host and an absolute path,
+// and that the service has a port to route to
+func validateServiceIngress(spec *ServiceSpec, fldPath *field.Path) field.ErrorList {
+	ingress := spec.Ingress
+	if ingress == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if len(spec.Ports) == 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "an ingress requires at least one port"))
+	}
+	if errs := validation.IsDNS1123Subdomain(ingress.Host); len(errs) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("host"), ingress.Host, strings.Join(errs, "; ")))
+	}
+	if ingress.Path != "" && !strings.HasPrefix(ingress.Path, "/") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("path"), ingress.Path, "must be an absolute path"))
+	}
+	return allErrs
+}
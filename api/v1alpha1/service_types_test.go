@@ -0,0 +1,115 @@
+package v1alpha1
+
+import (
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateManagedService(t *testing.T) {
+	tests := []struct {
+		name string
+		spec ServiceSpec
+		want []string
+	}{
+		{name: "image", spec: ServiceSpec{Type: "web", Image: "nginx:latest"}},
+		{name: "managed", spec: ServiceSpec{Type: ServiceTypePostgreSQL, Managed: true, Storage: &ServiceStorage{Size: "5Gi"},
+			Backup: &ServiceBackup{Schedule: "0 2 * * *", Retention: 7}}},
+		{name: "no image", spec: ServiceSpec{Type: "web"}, want: []string{"spec.image"}},
+		{
+			name: "storage of an unmanaged type",
+			spec: ServiceSpec{Type: "web", Image: "nginx:latest", Storage: &ServiceStorage{}, Backup: &ServiceBackup{}},
+			want: []string{"spec.storage", "spec.backup"},
+		},
+		{name: "replicas", spec: ServiceSpec{Type: ServiceTypeRedis, Managed: true, Replicas: 2}, want: []string{"spec.replicas"}},
+		{name: "bad size", spec: ServiceSpec{Type: ServiceTypeMinIO, Managed: true, Storage: &ServiceStorage{Size: "big"}},
+			want: []string{"spec.storage.size"}},
+		{
+			name: "bad backup",
+			spec: ServiceSpec{Type: ServiceTypePostgreSQL, Managed: true, Backup: &ServiceBackup{Schedule: "nightly", Size: "-1Gi"}},
+			want: []string{"spec.backup.schedule", "spec.backup.size"},
+		},
+		{name: "unknown managed type", spec: ServiceSpec{Type: "web", Managed: true}, want: []string{"spec.type"}},
+		{name: "type of a managed service's name", spec: ServiceSpec{Type: ServiceTypeRedis}, want: []string{"spec.image"}},
+		{name: "no schedule", spec: ServiceSpec{Type: ServiceTypePostgreSQL, Managed: true, Backup: &ServiceBackup{}},
+			want: []string{"spec.backup.schedule"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateServiceSpec(&tt.spec, field.NewPath("spec")) {
+				got = append(got, err.Field)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected violations of %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestApplyServicePreset(t *testing.T) {
+	spec := ServiceSpec{Type: ServiceTypeMinIO, Managed: true, Resources: ResourceRequirements{Memory: "4Gi"}}
+	ApplyServicePreset(&spec)
+	if spec.Image != "quay.io/minio/minio:latest" || len(spec.Ports) != 2 || spec.Ports[1].Port != 9001 {
+		t.Errorf("Expected the preset's image and ports, got %+v", spec)
+	}
+	if spec.Resources.CPU != "1" || spec.Resources.Memory != "4Gi" || spec.Storage == nil || spec.Storage.Size != "20Gi" {
+		t.Errorf("Expected the preset to fill only the empty resources and storage, got %+v", spec)
+	}
+
+	spec = ServiceSpec{Type: "web", Image: "nginx:latest"}
+	ApplyServicePreset(&spec)
+	if spec.Storage != nil || len(spec.Ports) != 0 {
+		t.Errorf("Expected an unmanaged service to be left unchanged, got %+v", spec)
+	}
+}
+
+func TestValidateServiceUpdate(t *testing.T) {
+	old := ServiceSpec{Type: ServiceTypePostgreSQL, Managed: true, Storage: &ServiceStorage{Size: "10Gi"}}
+
+	spec := ServiceSpec{Type: ServiceTypePostgreSQL, Managed: true, Storage: &ServiceStorage{Size: "10Gi"},
+		Backup: &ServiceBackup{Schedule: "@daily"}}
+	if errs := ValidateServiceUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected backups to be mutable, got %v", errs)
+	}
+	spec = ServiceSpec{Type: ServiceTypeRedis, Managed: true, Storage: &ServiceStorage{Size: "20Gi"}}
+	var got []string
+	for _, err := range ValidateServiceUpdate(&old, &spec, field.NewPath("spec")) {
+		got = append(got, err.Field)
+	}
+	if !slices.Equal(got, []string{"spec.type", "spec.storage"}) {
+		t.Errorf("Expected the type and storage to be immutable, got %v", got)
+	}
+	web := ServiceSpec{Type: "web", Image: "nginx:latest"}
+	if errs := ValidateServiceUpdate(&web, &ServiceSpec{Type: "api", Image: "nginx:latest"}, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected the type of an unmanaged service to be mutable, got %v", errs)
+	}
+	managed := web
+	managed.Type, managed.Managed = ServiceTypePostgreSQL, true
+	if errs := ValidateServiceUpdate(&web, &managed, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.managed" {
+		t.Errorf("Expected an existing service not to become managed, got %v", errs)
+	}
+	unmanaged := ServiceSpec{Type: ServiceTypePostgreSQL, Image: "postgres:16-alpine"}
+	got = nil
+	for _, err := range ValidateServiceUpdate(&old, &unmanaged, field.NewPath("spec")) {
+		got = append(got, err.Field)
+	}
+	if !slices.Equal(got, []string{"spec.managed", "spec.storage"}) {
+		t.Errorf("Expected a managed service not to become a Deployment, got %v", got)
+	}
+}
+
+func TestValidateServiceUpdateRatchets(t *testing.T) {
+	// Stored before an image was required, with more replicas than a managed type allows
+	old := ServiceSpec{Type: ServiceTypePostgreSQL, Replicas: 3}
+	spec := old
+	spec.Replicas = 4
+	if errs := ValidateServiceUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected an existing violation not to block an update, got %v", errs)
+	}
+	spec.Storage = &ServiceStorage{}
+	if errs := ValidateServiceUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.storage" {
+		t.Errorf("Expected a new violation to be rejected, got %v", errs)
+	}
+}
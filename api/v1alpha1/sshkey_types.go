@@ -0,0 +1,99 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// SSHKeySpec defines an SSH public key owned by a user or a project
+type SSHKeySpec struct {
+	// PublicKey is the key in authorized_keys format
+	// +kubebuilder:validation:Required
+	PublicKey string `json:"publicKey"`
+
+	// User is the username owning the key; VMs in any of the user's projects may use it.
+	// Exactly one of User and Project is set
+	// +optional
+	User string `json:"user,omitempty"`
+
+	// Project is the project owning the key; VMs in that project may use it
+	// +optional
+	Project string `json:"project,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="User",type="string",JSONPath=".spec.user"
+// +kubebuilder:printcolumn:name="Project",type="string",JSONPath=".spec.project"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SSHKey is the Schema for the sshkeys API. VMs reference SSHKeys by name to have
+// their keys injected through cloud-init
+type SSHKey struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the key and its owner
+	// +required
+	Spec SSHKeySpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// SSHKeyList contains a list of SSHKey
+type SSHKeyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SSHKey `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SSHKey{}, &SSHKeyList{})
+}
+
+// ValidateSSHKeySpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateSSHKeySpec(spec *SSHKeySpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if err := validateSSHKey(spec.PublicKey, fldPath.Child("publicKey")); err != nil {
+		allErrs = append(allErrs, err)
+	}
+	switch {
+	case spec.User == "" && spec.Project == "":
+		allErrs = append(allErrs, field.Required(fldPath, "one of user or project is required"))
+	case spec.User != "" && spec.Project != "":
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("project"), "cannot be set together with user"))
+	}
+	return allErrs
+}
+
+// UsableIn reports whether VMs in project may use the key. owner is the User named by
+// the key's User field, or nil when there is none
+func (k *SSHKey) UsableIn(project string, owner *User) bool {
+	if k.Spec.Project != "" {
+		return k.Spec.Project == project
+	}
+	return owner != nil && owner.Spec.Username == k.Spec.User &&
+		(owner.Spec.IsAdmin || slices.Contains(owner.Spec.Projects, project))
+}
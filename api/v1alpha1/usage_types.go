@@ -0,0 +1,108 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// UsageDays and UsageMonths are how many daily and monthly buckets of inference usage
+// are kept
+const (
+	UsageDays   = 62
+	UsageMonths = 24
+)
+
+// UsageCounts are the inference requests served and the tokens they used
+type UsageCounts struct {
+	// Requests is the number of successful inference requests
+	Requests int64 `json:"requests"`
+
+	// PromptTokens is the number of tokens in the prompts, as reported by the model
+	// +optional
+	PromptTokens int64 `json:"promptTokens,omitempty"`
+
+	// CompletionTokens is the number of tokens generated, as reported by the model
+	// +optional
+	CompletionTokens int64 `json:"completionTokens,omitempty"`
+}
+
+// Add adds other to the counts
+func (c *UsageCounts) Add(other UsageCounts) {
+	c.Requests += other.Requests
+	c.PromptTokens += other.PromptTokens
+	c.CompletionTokens += other.CompletionTokens
+}
+
+// UsageBucket is the inference usage of one day or month
+type UsageBucket struct {
+	// Period is the UTC day (2006-01-02) or month (2006-01) counted
+	Period string `json:"period"`
+
+	UsageCounts `json:",inline"`
+}
+
+// InferenceUsage is the inference usage metered by the inference gateway
+type InferenceUsage struct {
+	// Total counts all usage since metering started
+	Total UsageCounts `json:"total"`
+
+	// Daily counts the usage of the last UsageDays days, oldest first
+	// +optional
+	Daily []UsageBucket `json:"daily,omitempty"`
+
+	// Monthly counts the usage of the last UsageMonths months, oldest first
+	// +optional
+	Monthly []UsageBucket `json:"monthly,omitempty"`
+
+	// LastUpdated is when usage was last added
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+// Record adds counts used at the given time to the total and to the buckets of its
+// day and month, dropping the buckets that are too old to keep
+func (u *InferenceUsage) Record(at time.Time, counts UsageCounts) {
+	at = at.UTC()
+	u.Total.Add(counts)
+	u.Daily = addToBucket(u.Daily, at.Format(time.DateOnly), counts, UsageDays)
+	u.Monthly = addToBucket(u.Monthly, at.Format("2006-01"), counts, UsageMonths)
+	now := metav1.Now()
+	u.LastUpdated = &now
+}
+
+// addToBucket adds counts to the bucket of period, keeping buckets sorted by period and
+// only the newest keep of them. Periods sort as strings, as they are zero-padded dates
+func addToBucket(buckets []UsageBucket, period string, counts UsageCounts, keep int) []UsageBucket {
+	i := len(buckets)
+	for i > 0 && buckets[i-1].Period >= period {
+		i--
+	}
+	if i < len(buckets) && buckets[i].Period == period {
+		buckets[i].Add(counts)
+	} else {
+		buckets = append(buckets, UsageBucket{})
+		copy(buckets[i+1:], buckets[i:])
+		buckets[i] = UsageBucket{Period: period, UsageCounts: counts}
+	}
+	if len(buckets) > keep {
+		buckets = buckets[len(buckets)-keep:]
+	}
+	return buckets
+}
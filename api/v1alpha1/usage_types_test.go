@@ -0,0 +1,55 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInferenceUsageRecord(t *testing.T) {
+	usage := &InferenceUsage{}
+	day := time.Date(2026, time.January, 30, 12, 0, 0, 0, time.UTC)
+
+	usage.Record(day, UsageCounts{Requests: 1, PromptTokens: 10, CompletionTokens: 20})
+	usage.Record(day.AddDate(0, 0, 3), UsageCounts{Requests: 2, PromptTokens: 5})
+	// Usage flushed late lands in its own day, before the newer one
+	usage.Record(day.AddDate(0, 0, 1), UsageCounts{Requests: 1})
+	usage.Record(day, UsageCounts{Requests: 1, CompletionTokens: 1})
+
+	if usage.Total != (UsageCounts{Requests: 5, PromptTokens: 15, CompletionTokens: 21}) {
+		t.Errorf("Unexpected total %+v", usage.Total)
+	}
+	wantDays := []UsageBucket{
+		{Period: "2026-01-30", UsageCounts: UsageCounts{Requests: 2, PromptTokens: 10, CompletionTokens: 21}},
+		{Period: "2026-01-31", UsageCounts: UsageCounts{Requests: 1}},
+		{Period: "2026-02-02", UsageCounts: UsageCounts{Requests: 2, PromptTokens: 5}},
+	}
+	if len(usage.Daily) != len(wantDays) {
+		t.Fatalf("Expected %d days, got %+v", len(wantDays), usage.Daily)
+	}
+	for i, want := range wantDays {
+		if usage.Daily[i] != want {
+			t.Errorf("Day %d: expected %+v, got %+v", i, want, usage.Daily[i])
+		}
+	}
+	if len(usage.Monthly) != 2 || usage.Monthly[0].Period != "2026-01" || usage.Monthly[0].Requests != 3 ||
+		usage.Monthly[1].Period != "2026-02" || usage.Monthly[1].Requests != 2 {
+		t.Errorf("Unexpected months %+v", usage.Monthly)
+	}
+}
+
+func TestInferenceUsageRetention(t *testing.T) {
+	usage := &InferenceUsage{}
+	start := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < UsageDays+10; i++ {
+		usage.Record(start.AddDate(0, 0, i), UsageCounts{Requests: 1})
+	}
+	if len(usage.Daily) != UsageDays {
+		t.Fatalf("Expected %d days kept, got %d", UsageDays, len(usage.Daily))
+	}
+	if first := usage.Daily[0].Period; first != start.AddDate(0, 0, 10).Format(time.DateOnly) {
+		t.Errorf("Expected the oldest days dropped, first kept is %s", first)
+	}
+	if usage.Total.Requests != UsageDays+10 {
+		t.Errorf("Expected the total to keep every request, got %d", usage.Total.Requests)
+	}
+}
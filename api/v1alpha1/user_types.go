@@ -17,7 +17,14 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -50,6 +57,66 @@ type UserSpec struct {
 	// Disabled indicates if the user account is disabled
 	// +kubebuilder:default=false
 	Disabled bool `json:"disabled,omitempty"`
+
+	// MustChangePassword makes the user change the password at the next login before
+	// getting a token
+	// +optional
+	MustChangePassword bool `json:"mustChangePassword,omitempty"`
+
+	// APIKeys let programs call the API as the user with an X-API-Key header
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	APIKeys []APIKey `json:"apiKeys,omitempty"`
+}
+
+// API key scopes
+const (
+	// APIKeyScopeRead allows GET requests only
+	APIKeyScopeRead = "read"
+	// APIKeyScopeWrite allows every request the user may make
+	APIKeyScopeWrite = "write"
+)
+
+// APIKey is a key for programmatic access to the API. Only its hash is stored; the key
+// itself is shown once, when it is created
+type APIKey struct {
+	// Name identifies the key among the user's keys
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Hash is the hex-encoded SHA-256 hash of the key
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	Hash string `json:"hash"`
+
+	// Prefix is the start of the key, to recognize it by
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Scopes are what the key may do: read, or write, which includes read
+	// +kubebuilder:validation:MinItems=1
+	// +listType=set
+	Scopes []string `json:"scopes"`
+
+	// CreatedAt is when the key was created
+	// +optional
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+
+	// ExpiresAt is when the key stops being accepted; it never expires when unset
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+}
+
+// Expired reports whether the key has expired at now
+func (k *APIKey) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(k.ExpiresAt.Time)
+}
+
+// ReadOnly reports whether the key only has the read scope
+func (k *APIKey) ReadOnly() bool {
+	return !slices.Contains(k.Scopes, APIKeyScopeWrite)
 }
 
 // UserStatus defines the observed state of User.
@@ -58,6 +125,20 @@ type UserStatus struct {
 	// +optional
 	LastLoginTime *metav1.Time `json:"lastLoginTime,omitempty"`
 
+	// FailedLoginAttempts counts the logins that failed since the last successful one
+	// +optional
+	FailedLoginAttempts int32 `json:"failedLoginAttempts,omitempty"`
+
+	// LockedUntil is when the account, locked after too many failed logins, accepts
+	// logins again
+	// +optional
+	LockedUntil *metav1.Time `json:"lockedUntil,omitempty"`
+
+	// PasswordChangedAt is when the user last changed the password; a password never
+	// changed dates from the user's creation
+	// +optional
+	PasswordChangedAt *metav1.Time `json:"passwordChangedAt,omitempty"`
+
 	// conditions represent the current state of the User resource.
 	// +listType=map
 	// +listMapKey=type
@@ -85,6 +166,14 @@ type User struct {
 	Status UserStatus `json:"status,omitempty,omitzero"`
 }
 
+// PasswordSetAt returns when the user's password was set
+func (u *User) PasswordSetAt() time.Time {
+	if u.Status.PasswordChangedAt != nil {
+		return u.Status.PasswordChangedAt.Time
+	}
+	return u.CreationTimestamp.Time
+}
+
 // +kubebuilder:object:root=true
 
 // UserList contains a list of User
@@ -97,3 +186,51 @@ type UserList struct {
 func init() {
 	SchemeBuilder.Register(&User{}, &UserList{})
 }
+
+// ReservedUsernames cannot be used for users because they collide with
+// Kubernetes or operator identities
+var ReservedUsernames = []string{
+	"admin",
+	"administrator",
+	"system",
+	"anonymous",
+	"kubernetes-admin",
+	"llmcloud-operator",
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// ValidateAPIKeys returns every violation in keys that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateAPIKeys(keys []APIKey, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	hashes := map[string]bool{}
+	for i, key := range keys {
+		idxPath := fldPath.Index(i)
+		for j, scope := range key.Scopes {
+			if scope != APIKeyScopeRead && scope != APIKeyScopeWrite {
+				allErrs = append(allErrs, field.NotSupported(idxPath.Child("scopes").Index(j), scope,
+					[]string{APIKeyScopeRead, APIKeyScopeWrite}))
+			}
+		}
+		if hashes[key.Hash] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("hash"), key.Hash))
+		}
+		hashes[key.Hash] = true
+	}
+	return allErrs
+}
+
+// ValidateUsername checks that a username is well-formed and not reserved
+func ValidateUsername(username string) error {
+	if len(username) < 3 || len(username) > 50 {
+		return fmt.Errorf("username must be between 3 and 50 characters")
+	}
+	if slices.Contains(ReservedUsernames, username) || strings.HasPrefix(username, "system:") {
+		return fmt.Errorf("username %q is reserved", username)
+	}
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("invalid username %q: must consist of lower case alphanumeric characters, '.', '_' or '-'", username)
+	}
+	return nil
+}
@@ -17,17 +17,49 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"cmp"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"golang.org/x/crypto/ssh"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 // VirtualMachine phase constants
 const (
 	PhaseRunning = "Running"
 	PhasePending = "Pending"
+	// PhaseResizing is set while the VM is restarted to apply changed CPUs or memory
+	PhaseResizing = "Resizing"
 )
 
+// RebootPhaseRebooting marks a VM whose reboot has been issued but whose
+// instance has not yet come back Running
+const RebootPhaseRebooting = "Rebooting"
+
 // VirtualMachineSpec defines the desired state of VirtualMachine
 type VirtualMachineSpec struct {
+	// TemplateRef names the VMTemplate the VM was created from. The API fills the
+	// fields the VM leaves unset from the template on creation; later changes to the
+	// template do not affect the VM
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+
+	// Description is a free-form note describing what the VM is used for
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	Description string `json:"description,omitempty"`
+
 	// CPUs is the number of CPUs for the VM
 	// +kubebuilder:validation:Minimum=1
 	// +kubebuilder:default=1
@@ -37,7 +69,13 @@ type VirtualMachineSpec struct {
 	// +kubebuilder:default="1Gi"
 	Memory string `json:"memory,omitempty"`
 
-	// DiskSize is the size of the persistent disk (e.g., "10Gi")
+	// GuestMemory is the memory the guest sees, when different from Memory, which is
+	// what the VM is scheduled with. Setting it above Memory overcommits the node
+	// +optional
+	GuestMemory string `json:"guestMemory,omitempty"`
+
+	// DiskSize is the size of the persistent disk (e.g., "10Gi"). It can be increased
+	// later, which expands the disk's volume in place
 	// +kubebuilder:default="10Gi"
 	DiskSize string `json:"diskSize,omitempty"`
 
@@ -50,27 +88,200 @@ type VirtualMachineSpec struct {
 	// +optional
 	OSVersion string `json:"osVersion,omitempty"`
 
+	// ImageRef names the VMImage the VM boots from instead of the built-in image of its
+	// OS. The API fills OS from the image when the VM leaves it unset
+	// +optional
+	ImageRef string `json:"imageRef,omitempty"`
+
 	// CloudInit is the cloud-init user data
 	// +optional
 	CloudInit string `json:"cloudInit,omitempty"`
 
-	// SSHKeys is a list of SSH public keys to inject
+	// CloudInitSecretRef references a Secret key holding the cloud-init user data, for
+	// user data that should not appear in the spec. It cannot be combined with CloudInit
+	// +optional
+	CloudInitSecretRef *SecretKeySelector `json:"cloudInitSecretRef,omitempty"`
+
+	// SSHKeys is a list of SSH public keys to inject (authorized_keys format)
 	// +optional
 	SSHKeys []string `json:"sshKeys,omitempty"`
 
+	// SSHKeysFrom references a Secret key holding SSH public keys in authorized_keys
+	// format, one per line. They are injected in addition to SSHKeys
+	// +optional
+	SSHKeysFrom *SecretKeySelector `json:"sshKeysFrom,omitempty"`
+
+	// SSHKeyRefs are the names of SSHKey resources whose keys are injected in addition
+	// to SSHKeys. Each must belong to the VM's project, or to a user with access to it
+	// +optional
+	SSHKeyRefs []string `json:"sshKeyRefs,omitempty"`
+
+	// SecretFiles are Secret keys written into the VM through cloud-init write_files.
+	// They cannot be combined with a custom CloudInit
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	SecretFiles []SecretFile `json:"secretFiles,omitempty"`
+
 	// RunStrategy defines the VM run strategy (Always, RerunOnFailure, Manual, Halted)
 	// +kubebuilder:validation:Enum=Always;RerunOnFailure;Manual;Halted
 	// +kubebuilder:default=Always
 	RunStrategy string `json:"runStrategy,omitempty"`
 
-	// StorageClass is the storage class for the VM disk
+	// StorageClass is the storage class for the VM disk, defaulting to the
+	// defaultStorageClass of the LLMCloudConfig, or local-path
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+
+	// AdditionalDisks are extra persistent disks attached to the VM as hotpluggable
+	// volumes, so they can be added to a running VM
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	AdditionalDisks []VMDisk `json:"additionalDisks,omitempty"`
+
+	// GPUs are host GPUs passed through to the VM
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	GPUs []VMDevice `json:"gpus,omitempty"`
+
+	// HostDevices are other host PCI or mediated devices passed through to the VM
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	HostDevices []VMDevice `json:"hostDevices,omitempty"`
+
+	// NodeSelector restricts the VM to nodes with these labels
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations let the VM run on nodes with matching taints
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// ExposedPorts are ports of services running inside the VM that are made reachable
+	// from outside the cluster, through a Service named <vm>-ports
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	ExposedPorts []VMPort `json:"exposedPorts,omitempty"`
+
+	// ExposeType is the type of the Service exposing ExposedPorts: NodePort (the
+	// default) opens them on every node, LoadBalancer gets them an external address
+	// +kubebuilder:validation:Enum=NodePort;LoadBalancer
+	// +optional
+	ExposeType string `json:"exposeType,omitempty"`
+
+	// BackupSchedule is a cron expression (e.g., "0 2 * * *") on which a
+	// VirtualMachineSnapshot of the VM is taken. Backups are disabled when empty
+	// +optional
+	BackupSchedule string `json:"backupSchedule,omitempty"`
+
+	// BackupRetention is the number of scheduled snapshots to keep
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	BackupRetention int32 `json:"backupRetention,omitempty"`
+
+	// SuspendAfterIdle halts the VM once it has been idle for this long (e.g., "2h").
+	// The VM can be resumed with the start action. Disabled when unset
+	// +optional
+	SuspendAfterIdle *metav1.Duration `json:"suspendAfterIdle,omitempty"`
+
+	// SchedulePolicy starts and stops the VM on a schedule, such as shutting a
+	// development VM down at night. The VM can still be started and stopped in between
+	// +optional
+	SchedulePolicy *VMSchedulePolicy `json:"schedulePolicy,omitempty"`
+
+	// TTL deletes the VM this long after it was created (e.g., "8h"). The VM is never
+	// deleted when unset
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// VMSchedulePolicy starts and stops a VM on cron schedules by setting its RunStrategy
+// to Always or Halted whenever a schedule fires
+type VMSchedulePolicy struct {
+	// Start is a cron expression (e.g., "0 8 * * 1-5") on which the VM is started
+	// +optional
+	Start string `json:"start,omitempty"`
+
+	// Stop is a cron expression (e.g., "0 20 * * 1-5") on which the VM is stopped
+	// +optional
+	Stop string `json:"stop,omitempty"`
+
+	// TimeZone is the IANA time zone the schedules are in (e.g., "Europe/Berlin").
+	// Defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// VMDevice is a host device passed through to a VM
+type VMDevice struct {
+	// Name identifies the device in the VM
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// DeviceName is the resource name of the device, which KubeVirt must permit as a
+	// host device (e.g., "nvidia.com/TU104GL_Tesla_T4")
+	DeviceName string `json:"deviceName"`
+}
+
+// VMPort is a port inside a VM exposed outside the cluster
+type VMPort struct {
+	// Name is the name of the port
+	// +kubebuilder:validation:MaxLength=15
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Port is the port the service listens on inside the VM, which the Service exposes
+	// under the same number
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Protocol is the protocol of the port (TCP/UDP)
+	// +kubebuilder:validation:Enum=TCP;UDP
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// NodePort is the port opened on every node for a NodePort or LoadBalancer
+	// Service, allocated by Kubernetes when unset
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// VMDisk is an additional persistent disk of a VM
+type VMDisk struct {
+	// Name identifies the disk in the VM; its volume is named <vm>-<name>
+	// +kubebuilder:validation:MaxLength=63
+	Name string `json:"name"`
+
+	// Size is the size of the disk (e.g., "20Gi"). It can be increased later
+	Size string `json:"size"`
+
+	// StorageClass is the storage class for the disk, defaulting to that of the VM disk
 	// +optional
 	StorageClass string `json:"storageClass,omitempty"`
 }
 
+// SecretFile places the value of a Secret key at a path inside the VM
+type SecretFile struct {
+	// SecretRef selects the Secret key holding the file contents
+	SecretRef SecretKeySelector `json:"secretRef"`
+
+	// Path is the absolute path of the file inside the VM
+	// +kubebuilder:validation:Pattern=`^/`
+	// +kubebuilder:validation:MaxLength=4096
+	Path string `json:"path"`
+
+	// Permissions is the octal file mode (e.g., "0644")
+	// +kubebuilder:validation:Pattern=`^0?[0-7]{3}$`
+	// +kubebuilder:default="0600"
+	// +optional
+	Permissions string `json:"permissions,omitempty"`
+}
+
 // VirtualMachineStatus defines the observed state of VirtualMachine
 type VirtualMachineStatus struct {
-	// Phase is the current phase of the VM (Pending, Running, Stopped, Failed)
+	// Phase is the current phase of the VM (Pending, Running, Resizing, Stopped, Failed)
 	// +optional
 	Phase string `json:"phase,omitempty"`
 
@@ -82,10 +293,69 @@ type VirtualMachineStatus struct {
 	// +optional
 	IPAddress string `json:"ipAddress,omitempty"`
 
+	// Interfaces lists the network interfaces of the VM with all of their IP addresses.
+	// Interfaces inside the guest are only known while its guest agent is connected
+	// +optional
+	Interfaces []VMInterface `json:"interfaces,omitempty"`
+
+	// Guest is what the QEMU guest agent reports from inside the running VM. It is
+	// unset while no guest agent is connected
+	// +optional
+	Guest *GuestInfo `json:"guest,omitempty"`
+
 	// Ready indicates if the VM is ready
 	// +optional
 	Ready bool `json:"ready,omitempty"`
 
+	// PhaseTransitions records when the VM instance entered each phase,
+	// as reported by the KubeVirt VirtualMachineInstance
+	// +optional
+	PhaseTransitions []PhaseTransition `json:"phaseTransitions,omitempty"`
+
+	// RebootPhase is set while a requested reboot is in progress
+	// +optional
+	RebootPhase string `json:"rebootPhase,omitempty"`
+
+	// RebootStartTime is when the in-progress reboot was issued
+	// +optional
+	RebootStartTime *metav1.Time `json:"rebootStartTime,omitempty"`
+
+	// PendingSnapshot is the VirtualMachineSnapshot created for the llmcloud.io/snapshot
+	// annotation that has not finished yet
+	// +optional
+	PendingSnapshot string `json:"pendingSnapshot,omitempty"`
+
+	// PendingMigration is the VirtualMachineInstanceMigration created for the
+	// llmcloud.io/migrate annotation that has not finished yet
+	// +optional
+	PendingMigration string `json:"pendingMigration,omitempty"`
+
+	// LastBackupTime is when the last scheduled snapshot was taken
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+
+	// LastActiveTime is when the VM was last seen active, used by SuspendAfterIdle
+	// +optional
+	LastActiveTime *metav1.Time `json:"lastActiveTime,omitempty"`
+
+	// LastScheduleTime is when the SchedulePolicy was last applied
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Defaults are the cluster defaults the VM was created with
+	// +optional
+	Defaults *VMDefaults `json:"defaults,omitempty"`
+
+	// CloudInitComplete is true once cloud-init has finished successfully in the guest.
+	// The CloudInitComplete condition reports whether it is still running or failed
+	// +optional
+	CloudInitComplete bool `json:"cloudInitComplete,omitempty"`
+
+	// LauncherPod is the name of the virt-launcher pod running the VM, useful for
+	// reading its logs and events
+	// +optional
+	LauncherPod string `json:"launcherPod,omitempty"`
+
 	// Conditions represent the current state of the VirtualMachine resource
 	// +listType=map
 	// +listMapKey=type
@@ -93,12 +363,161 @@ type VirtualMachineStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
+// VMInterface is a network interface of a VM
+type VMInterface struct {
+	// Name is the name of the KubeVirt network the interface is attached to, empty for
+	// an interface only the guest knows about
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// InterfaceName is the name of the interface inside the guest, e.g. eth0
+	// +optional
+	InterfaceName string `json:"interfaceName,omitempty"`
+
+	// MAC is the MAC address of the interface
+	// +optional
+	MAC string `json:"mac,omitempty"`
+
+	// IPAddresses are the IPv4 and IPv6 addresses of the interface
+	// +optional
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+}
+
+// VMDefaults are the cluster defaults in effect when a VM was created, which keep
+// applying to it when the LLMCloudConfig changes
+type VMDefaults struct {
+	// StorageClass is the storage class of the VM's disks that do not set one
+	StorageClass string `json:"storageClass"`
+
+	// OSImageRegistry replaces quay.io/containerdisks in the VM's OS image, if set
+	// +optional
+	OSImageRegistry string `json:"osImageRegistry,omitempty"`
+}
+
+// CurrentVMDefaults returns the defaults of a VM created under the LLMCloudConfig in
+// effect
+func CurrentVMDefaults() *VMDefaults {
+	config := RuntimeConfig()
+	return &VMDefaults{
+		StorageClass:    cmp.Or(config.DefaultStorageClass, DefaultStorageClass),
+		OSImageRegistry: config.OSImageRegistry,
+	}
+}
+
+// ImageForOS returns the container disk image for an OS and optional version, pulled
+// from the OSImageRegistry of the defaults
+func (d *VMDefaults) ImageForOS(os, version string) string {
+	image := osImage(os, version)
+	if d.OSImageRegistry != "" {
+		if path, ok := strings.CutPrefix(image, DefaultOSImageRegistry+"/"); ok {
+			return d.OSImageRegistry + "/" + path
+		}
+	}
+	return image
+}
+
+// GuestInfo is reported by the QEMU guest agent running inside a VM
+type GuestInfo struct {
+	// Hostname is the hostname of the guest
+	// +optional
+	Hostname string `json:"hostname,omitempty"`
+
+	// OS is the operating system of the guest
+	// +optional
+	OS GuestOSInfo `json:"os,omitempty"`
+
+	// Timezone is the timezone of the guest, e.g. "UTC, 0"
+	// +optional
+	Timezone string `json:"timezone,omitempty"`
+
+	// Filesystems are the mounted filesystems of the guest
+	// +optional
+	Filesystems []GuestFilesystem `json:"filesystems,omitempty"`
+
+	// Users are the users logged in to the guest
+	// +optional
+	Users []GuestUser `json:"users,omitempty"`
+
+	// UpdatedAt is when the hostname, filesystems and users were last read from the agent
+	// +optional
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+}
+
+// GuestOSInfo describes the operating system of a guest
+type GuestOSInfo struct {
+	// Name is the name of the distribution, e.g. Ubuntu
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Version is the version of the distribution, e.g. 22.04.3 LTS (Jammy Jellyfish)
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// PrettyName is the full name of the distribution, e.g. Ubuntu 22.04.3 LTS
+	// +optional
+	PrettyName string `json:"prettyName,omitempty"`
+
+	// KernelRelease is the release of the running kernel
+	// +optional
+	KernelRelease string `json:"kernelRelease,omitempty"`
+
+	// Machine is the hardware architecture, e.g. x86_64
+	// +optional
+	Machine string `json:"machine,omitempty"`
+}
+
+// GuestFilesystem is a filesystem mounted in a guest
+type GuestFilesystem struct {
+	// MountPoint is where the filesystem is mounted
+	MountPoint string `json:"mountPoint"`
+
+	// Disk is the name of the disk holding the filesystem, e.g. vda1
+	// +optional
+	Disk string `json:"disk,omitempty"`
+
+	// Type is the filesystem type, e.g. ext4
+	// +optional
+	Type string `json:"type,omitempty"`
+
+	// TotalBytes is the size of the filesystem
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+
+	// UsedBytes is how much of the filesystem is used
+	// +optional
+	UsedBytes int64 `json:"usedBytes,omitempty"`
+}
+
+// GuestUser is a user logged in to a guest
+type GuestUser struct {
+	// Name is the name of the user
+	Name string `json:"name"`
+
+	// Domain is the domain of the user, set for Windows guests
+	// +optional
+	Domain string `json:"domain,omitempty"`
+
+	// LoginTime is when the user logged in
+	// +optional
+	LoginTime *metav1.Time `json:"loginTime,omitempty"`
+}
+
+// PhaseTransition records the time a VM instance entered a phase
+type PhaseTransition struct {
+	// Phase is the VMI phase that was entered (e.g., Scheduling, Running)
+	Phase string `json:"phase"`
+
+	// TransitionTime is when the phase was entered
+	TransitionTime metav1.Time `json:"transitionTime"`
+}
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
 // +kubebuilder:printcolumn:name="Node",type="string",JSONPath=".status.node"
 // +kubebuilder:printcolumn:name="IP",type="string",JSONPath=".status.ipAddress"
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description",priority=1
 
 // VirtualMachine is the Schema for the virtualmachines API
 // A VirtualMachine represents a KubeVirt virtual machine
@@ -134,8 +553,56 @@ var OSImageMap = map[string]string{
 	"freebsd": "quay.io/containerdisks/freebsd:13.2",
 }
 
-// GetImageForOS returns the container disk image for a given OS and optional version
+// SupportedOSVersions lists the versions a VM may set as OSVersion for each OS, which
+// have a container disk image published
+var SupportedOSVersions = map[string][]string{
+	"ubuntu":  {"20.04", "22.04", "24.04"},
+	"fedora":  {"38", "39", "40"},
+	"debian":  {"11", "12"},
+	"centos":  {"8", "9"},
+	"alpine":  {"3.18", "3.19", "3.20"},
+	"cirros":  {"latest"},
+	"freebsd": {"13.2", "14.0"},
+}
+
+// DefaultOSVersions overrides the version used for an OS when a VM does not set
+// OSVersion. It can be set at startup with the --default-os-versions flag.
+var DefaultOSVersions = map[string]string{}
+
+// ParseDefaultOSVersions parses a comma-separated list of os=version pairs
+// (e.g., "ubuntu=24.04,debian=13") for DefaultOSVersions
+func ParseDefaultOSVersions(value string) (map[string]string, error) {
+	versions := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		os, version, ok := strings.Cut(pair, "=")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("invalid OS version %q: expected os=version", pair)
+		}
+		if _, known := OSImageMap[os]; !known {
+			return nil, fmt.Errorf("invalid OS version %q: unknown OS %q", pair, os)
+		}
+		versions[os] = version
+	}
+	return versions, nil
+}
+
+// GetImageForOS returns the container disk image for a given OS and optional version.
+// Without a version, the default from DefaultOSVersions is used if one is configured.
+// Images from DefaultOSImageRegistry are pulled from the OSImageRegistry of the
+// LLMCloudConfig in effect instead, if it sets one
 func GetImageForOS(os, version string) string {
+	return CurrentVMDefaults().ImageForOS(os, version)
+}
+
+// osImage returns the image GetImageForOS returns before a registry override
+func osImage(os, version string) string {
+	if version == "" {
+		version = DefaultOSVersions[os]
+	}
 	if version != "" {
 		// Check if versioned image exists
 		versionedKey := os + ":" + version
@@ -177,3 +644,359 @@ func splitImageTag(image string) []string {
 	}
 	return parts
 }
+
+// MaxSSHKeys is the maximum number of SSH keys accepted for a single VM.
+// It can be overridden at startup with the --max-ssh-keys flag.
+var MaxSSHKeys = 32
+
+// MaxVMCPUs is the maximum number of CPUs accepted for a single VM
+const MaxVMCPUs = 64
+
+// ValidateVirtualMachineSpec returns every violation in spec that the CRD schema
+// cannot express, with field paths under fldPath
+func ValidateVirtualMachineSpec(spec *VirtualMachineSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := validateAllowedOS(spec.OS, fldPath.Child("os"))
+	return append(allErrs, validateVirtualMachineSpec(spec, fldPath)...)
+}
+
+// validateAllowedOS checks that os is allowed by the LLMCloudConfig in effect
+func validateAllowedOS(os string, fldPath *field.Path) field.ErrorList {
+	if os == "" || OSAllowed(os) {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, os, RuntimeConfig().AllowedOS)}
+}
+
+// validateVirtualMachineSpec returns the violations of ValidateVirtualMachineSpec that
+// do not depend on the LLMCloudConfig, which existing VMs may keep violating
+func validateVirtualMachineSpec(spec *VirtualMachineSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	// Zero CPUs is left to the CRD default
+	if spec.CPUs < 0 || spec.CPUs > MaxVMCPUs {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("cpus"), spec.CPUs,
+			fmt.Sprintf("must be between 1 and %d", MaxVMCPUs)))
+	}
+	allErrs = append(allErrs, validatePositiveQuantity(spec.Memory, fldPath.Child("memory"))...)
+	allErrs = append(allErrs, validatePositiveQuantity(spec.DiskSize, fldPath.Child("diskSize"))...)
+	allErrs = append(allErrs, validateOSVersion(spec.OS, spec.OSVersion, fldPath.Child("osVersion"))...)
+	allErrs = append(allErrs, validateVMDevices(spec.GPUs, spec.HostDevices, fldPath)...)
+	allErrs = append(allErrs, validateVMDisks(spec.AdditionalDisks, fldPath.Child("additionalDisks"))...)
+	allErrs = append(allErrs, metav1validation.ValidateLabels(spec.NodeSelector, fldPath.Child("nodeSelector"))...)
+	allErrs = append(allErrs, validateVMPorts(spec.ExposedPorts, fldPath.Child("exposedPorts"))...)
+	allErrs = append(allErrs, validateSSHKeys(spec.SSHKeys, fldPath.Child("sshKeys"))...)
+	for i, ref := range spec.SSHKeyRefs {
+		for _, msg := range validation.IsDNS1123Subdomain(ref) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("sshKeyRefs").Index(i), ref, msg))
+		}
+	}
+	if spec.TemplateRef != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(spec.TemplateRef) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("templateRef"), spec.TemplateRef, msg))
+		}
+	}
+	allErrs = append(allErrs, validateCloudInitSecretRef(spec, fldPath.Child("cloudInitSecretRef"))...)
+	allErrs = append(allErrs, validateSecretFiles(spec.SecretFiles, spec.CloudInit, fldPath.Child("secretFiles"))...)
+	allErrs = append(allErrs, validateBackupSchedule(spec.BackupSchedule, fldPath.Child("backupSchedule"))...)
+	allErrs = append(allErrs, validateSchedulePolicy(spec.SchedulePolicy, fldPath.Child("schedulePolicy"))...)
+	allErrs = append(allErrs, validateTTL(spec.TTL, fldPath.Child("ttl"))...)
+	allErrs = append(allErrs, validateGuestMemory(spec.GuestMemory, spec.Memory, fldPath.Child("guestMemory"))...)
+	return allErrs
+}
+
+// ValidateVirtualMachineUpdate returns the violations of ValidateVirtualMachineSpec in
+// newSpec, and those of changing oldSpec into it: disks can grow but never shrink. A
+// VM keeping its OS may keep it after the LLMCloudConfig stops allowing it
+func ValidateVirtualMachineUpdate(newSpec, oldSpec *VirtualMachineSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := validateVirtualMachineSpec(newSpec, fldPath)
+	if newSpec.OS != oldSpec.OS {
+		allErrs = append(allErrs, validateAllowedOS(newSpec.OS, fldPath.Child("os"))...)
+	}
+	allErrs = append(allErrs, validateDiskGrowth(newSpec.DiskSize, oldSpec.DiskSize, fldPath.Child("diskSize"))...)
+	for i, disk := range newSpec.AdditionalDisks {
+		for _, old := range oldSpec.AdditionalDisks {
+			if old.Name == disk.Name {
+				allErrs = append(allErrs, validateDiskGrowth(disk.Size, old.Size, fldPath.Child("additionalDisks").Index(i).Child("size"))...)
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateDiskGrowth checks that a disk size did not shrink, as volumes can only be
+// expanded. Sizes that do not parse are left to the other checks
+func validateDiskGrowth(size, oldSize string, fldPath *field.Path) field.ErrorList {
+	newQuantity, ok := quantity(size)
+	oldQuantity, oldOK := quantity(oldSize)
+	if !ok || !oldOK || newQuantity.Cmp(oldQuantity) >= 0 {
+		return nil
+	}
+	return field.ErrorList{field.Invalid(fldPath, size, fmt.Sprintf("must not be less than the current size (%s)", oldSize))}
+}
+
+// ValidateSSHKeys checks that every entry is a single-line public key in
+// authorized_keys format and that the number of keys does not exceed MaxSSHKeys
+func ValidateSSHKeys(keys []string) error {
+	return validateSSHKeys(keys, field.NewPath("sshKeys")).ToAggregate()
+}
+
+func validateSSHKeys(keys []string, fldPath *field.Path) field.ErrorList {
+	if MaxSSHKeys > 0 && len(keys) > MaxSSHKeys {
+		return field.ErrorList{field.TooMany(fldPath, len(keys), MaxSSHKeys)}
+	}
+	var allErrs field.ErrorList
+	for i, key := range keys {
+		if err := validateSSHKey(key, fldPath.Index(i)); err != nil {
+			allErrs = append(allErrs, err)
+		}
+	}
+	return allErrs
+}
+
+// validateSSHKey checks that key is a single-line public key in authorized_keys format
+func validateSSHKey(key string, fldPath *field.Path) *field.Error {
+	if strings.ContainsAny(key, "\r\n") {
+		return field.Invalid(fldPath, field.OmitValueType{}, "key must be a single line")
+	}
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err != nil {
+		return field.Invalid(fldPath, field.OmitValueType{}, "not a valid SSH public key")
+	}
+	return nil
+}
+
+// CloudInitStatusAnnotation carries the cloud-init status reported through the API, as
+// printed by "cloud-init status": running, done or error. It is only used for guests
+// whose agent does not let the controller read the result cloud-init leaves
+const CloudInitStatusAnnotation = "llmcloud.io/cloud-init-status"
+
+// MaxSecretFileSize is the largest Secret value, in bytes, that can be written into a
+// VM as a secret file
+const MaxSecretFileSize = 64 * 1024
+
+var secretFilePermissions = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+// ValidateSecretFiles checks that secret files have clean, absolute and unique paths and
+// valid permissions, and that they are not combined with a custom cloud-init, into
+// which they could not be merged
+func ValidateSecretFiles(files []SecretFile, cloudInit string) error {
+	return validateSecretFiles(files, cloudInit, field.NewPath("secretFiles")).ToAggregate()
+}
+
+func validateSecretFiles(files []SecretFile, cloudInit string, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(files) > 0 && cloudInit != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "secretFiles cannot be combined with a custom cloudInit"))
+	}
+	seen := map[string]bool{}
+	for i, file := range files {
+		idxPath := fldPath.Index(i)
+		if file.SecretRef.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("secretRef", "name"), ""))
+		}
+		if file.SecretRef.Key == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("secretRef", "key"), ""))
+		}
+		if !path.IsAbs(file.Path) || path.Clean(file.Path) != file.Path || file.Path == "/" {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("path"), file.Path, "must be a clean absolute file path"))
+		} else if seen[file.Path] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("path"), file.Path))
+		}
+		seen[file.Path] = true
+		if file.Permissions != "" && !secretFilePermissions.MatchString(file.Permissions) {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("permissions"), file.Permissions, "must be an octal file mode such as 0600"))
+		}
+	}
+	return allErrs
+}
+
+// validateCloudInitSecretRef checks that a cloud-init Secret reference is complete and
+// that it does not compete with another source of user data
+func validateCloudInitSecretRef(spec *VirtualMachineSpec, fldPath *field.Path) field.ErrorList {
+	ref := spec.CloudInitSecretRef
+	if ref == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), ""))
+	}
+	if ref.Key == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("key"), ""))
+	}
+	if spec.CloudInit != "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cloudInitSecretRef cannot be combined with cloudInit"))
+	}
+	if len(spec.SecretFiles) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "cloudInitSecretRef cannot be combined with secretFiles"))
+	}
+	return allErrs
+}
+
+// validatePositiveQuantity checks that a non-empty amount is a quantity above zero
+func validatePositiveQuantity(value string, fldPath *field.Path) field.ErrorList {
+	if value == "" {
+		return nil
+	}
+	if q, ok := quantity(value); !ok || q.Sign() <= 0 {
+		return field.ErrorList{field.Invalid(fldPath, value, "must be a positive quantity such as 4Gi")}
+	}
+	return nil
+}
+
+// validateOSVersion checks that a non-empty version is one of the SupportedOSVersions
+// of the OS. Unknown operating systems are left to the CRD schema
+func validateOSVersion(os, version string, fldPath *field.Path) field.ErrorList {
+	versions, ok := SupportedOSVersions[os]
+	if version == "" || !ok || slices.Contains(versions, version) {
+		return nil
+	}
+	return field.ErrorList{field.NotSupported(fldPath, version, versions)}
+}
+
+// validateVMDevices checks that every GPU and host device names a device resource and
+// has a DNS label name unique across both lists, as KubeVirt requires
+func validateVMDevices(gpus, hostDevices []VMDevice, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for _, list := range []struct {
+		path    *field.Path
+		devices []VMDevice
+	}{{fldPath.Child("gpus"), gpus}, {fldPath.Child("hostDevices"), hostDevices}} {
+		for i, device := range list.devices {
+			idxPath := list.path.Index(i)
+			if errs := validation.IsDNS1123Label(device.Name); len(errs) > 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), device.Name, strings.Join(errs, "; ")))
+			} else if names[device.Name] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), device.Name))
+			}
+			names[device.Name] = true
+			if device.DeviceName == "" {
+				allErrs = append(allErrs, field.Required(idxPath.Child("deviceName"), ""))
+			}
+		}
+	}
+	return allErrs
+}
+
+// reservedDiskNames are the disks every VM may have, which additional disks must not
+// be named after
+var reservedDiskNames = []string{"containerdisk", "datadisk", "cloudinitdisk"}
+
+// validateVMDisks checks that every additional disk has a unique DNS label name not
+// used by the VM's own disks, and a positive size
+func validateVMDisks(disks []VMDisk, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, disk := range disks {
+		idxPath := fldPath.Index(i)
+		switch errs := validation.IsDNS1123Label(disk.Name); {
+		case len(errs) > 0:
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), disk.Name, strings.Join(errs, "; ")))
+		case slices.Contains(reservedDiskNames, disk.Name):
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), disk.Name, "is reserved for the VM's own disks"))
+		case names[disk.Name]:
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), disk.Name))
+		}
+		names[disk.Name] = true
+		if disk.Size == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("size"), ""))
+		} else {
+			allErrs = append(allErrs, validatePositiveQuantity(disk.Size, idxPath.Child("size"))...)
+		}
+	}
+	return allErrs
+}
+
+// validateVMPorts checks that every exposed port is a valid port, exposed once per
+// protocol, with a unique IANA service name if named
+func validateVMPorts(ports []VMPort, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	numbers := map[string]bool{}
+	for i, port := range ports {
+		idxPath := fldPath.Index(i)
+		if port.Name != "" {
+			if errs := validation.IsValidPortName(port.Name); len(errs) > 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), port.Name, strings.Join(errs, "; ")))
+			} else if names[port.Name] {
+				allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), port.Name))
+			}
+			names[port.Name] = true
+		}
+		if errs := validation.IsValidPortNum(int(port.Port)); len(errs) > 0 {
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("port"), port.Port, strings.Join(errs, "; ")))
+		}
+		protocol := cmp.Or(port.Protocol, string(corev1.ProtocolTCP))
+		if protocol != string(corev1.ProtocolTCP) && protocol != string(corev1.ProtocolUDP) {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("protocol"), port.Protocol,
+				[]corev1.Protocol{corev1.ProtocolTCP, corev1.ProtocolUDP}))
+		}
+		if key := fmt.Sprintf("%d/%s", port.Port, protocol); numbers[key] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("port"), port.Port))
+		} else {
+			numbers[key] = true
+		}
+		if port.NodePort != 0 {
+			if errs := validation.IsValidPortNum(int(port.NodePort)); len(errs) > 0 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("nodePort"), port.NodePort, strings.Join(errs, "; ")))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateGuestMemory checks that guest memory, when set, is a positive quantity no
+// smaller than the scheduled memory: a smaller guest would only waste the difference
+func validateGuestMemory(guest, memory string, fldPath *field.Path) field.ErrorList {
+	if guest == "" {
+		return nil
+	}
+	guestQuantity, err := resource.ParseQuantity(guest)
+	if err != nil || guestQuantity.Sign() <= 0 {
+		return field.ErrorList{field.Invalid(fldPath, guest, "must be a positive quantity such as 4Gi")}
+	}
+	if memoryQuantity, err := resource.ParseQuantity(memory); err == nil && guestQuantity.Cmp(memoryQuantity) < 0 {
+		return field.ErrorList{field.Invalid(fldPath, guest, fmt.Sprintf("must not be less than memory (%s)", memory))}
+	}
+	return nil
+}
+
+// ValidateBackupSchedule checks that a non-empty backup schedule is a valid
+// standard five-field cron expression
+func ValidateBackupSchedule(schedule string) error {
+	return validateBackupSchedule(schedule, field.NewPath("backupSchedule")).ToAggregate()
+}
+
+func validateBackupSchedule(schedule string, fldPath *field.Path) field.ErrorList {
+	if schedule == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return field.ErrorList{field.Invalid(fldPath, schedule, fmt.Sprintf("not a valid cron expression: %v", err))}
+	}
+	return nil
+}
+
+func validateSchedulePolicy(policy *VMSchedulePolicy, fldPath *field.Path) field.ErrorList {
+	if policy == nil {
+		return nil
+	}
+	var allErrs field.ErrorList
+	if policy.Start == "" && policy.Stop == "" {
+		allErrs = append(allErrs, field.Required(fldPath, "at least one of start and stop is required"))
+	}
+	for _, schedule := range []struct {
+		name, value string
+	}{{"start", policy.Start}, {"stop", policy.Stop}} {
+		// The time zone is given by TimeZone alone
+		if strings.HasPrefix(schedule.value, "CRON_TZ=") || strings.HasPrefix(schedule.value, "TZ=") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(schedule.name), schedule.value, "use timeZone to set the time zone"))
+			continue
+		}
+		allErrs = append(allErrs, validateBackupSchedule(schedule.value, fldPath.Child(schedule.name))...)
+	}
+	// time.LoadLocation("Local") is the operator's own time zone
+	if _, err := time.LoadLocation(policy.TimeZone); err != nil || policy.TimeZone == "Local" {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("timeZone"), policy.TimeZone, "must be an IANA time zone such as Europe/Berlin"))
+	}
+	return allErrs
+}
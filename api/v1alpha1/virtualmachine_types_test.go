@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 )
 
 func TestVirtualMachineSpec(t *testing.T) {
@@ -212,3 +213,288 @@ func TestVirtualMachinePhases(t *testing.T) {
 		})
 	}
 }
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGQdgUmX97JMXLbmXCll+bvnMY+OT7LrGdVP1JFp8L+J test@llmcloud"
+
+func TestValidateSSHKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		wantErr bool
+	}{
+		{name: "no keys", keys: nil},
+		{name: "valid key", keys: []string{testSSHKey}},
+		{name: "not a key", keys: []string{"hello world"}, wantErr: true},
+		{name: "multi-line injection", keys: []string{testSSHKey + "\nruncmd:\n  - rm -rf /"}, wantErr: true},
+		{name: "truncated key", keys: []string{"ssh-rsa AAAA..."}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateSSHKeys(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSSHKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSSHKeysLimit(t *testing.T) {
+	orig := MaxSSHKeys
+	defer func() { MaxSSHKeys = orig }()
+	MaxSSHKeys = 2
+
+	if err := ValidateSSHKeys([]string{testSSHKey, testSSHKey}); err != nil {
+		t.Errorf("Expected keys within limit to be accepted, got %v", err)
+	}
+	if err := ValidateSSHKeys([]string{testSSHKey, testSSHKey, testSSHKey}); err == nil {
+		t.Error("Expected error when exceeding the SSH key limit")
+	}
+}
+
+func TestValidateBackupSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		wantErr  bool
+	}{
+		{schedule: ""},
+		{schedule: "0 2 * * *"},
+		{schedule: "@daily"},
+		{schedule: "*/15 * * * *"},
+		{schedule: "0 2 * *", wantErr: true},
+		{schedule: "nightly", wantErr: true},
+		{schedule: "61 * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schedule, func(t *testing.T) {
+			err := ValidateBackupSchedule(tt.schedule)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBackupSchedule(%q) error = %v, wantErr %v", tt.schedule, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSchedulePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *VMSchedulePolicy
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "start and stop", policy: &VMSchedulePolicy{Start: "0 8 * * 1-5", Stop: "0 20 * * 1-5", TimeZone: "Europe/Berlin"}},
+		{name: "stop only", policy: &VMSchedulePolicy{Stop: "0 20 * * *"}},
+		{name: "empty", policy: &VMSchedulePolicy{}, wantErr: true},
+		{name: "invalid start", policy: &VMSchedulePolicy{Start: "mornings"}, wantErr: true},
+		{name: "time zone in schedule", policy: &VMSchedulePolicy{Stop: "CRON_TZ=Europe/Berlin 0 20 * * *"}, wantErr: true},
+		{name: "unknown time zone", policy: &VMSchedulePolicy{Stop: "0 20 * * *", TimeZone: "Mars/Olympus"}, wantErr: true},
+		{name: "local time zone", policy: &VMSchedulePolicy{Stop: "0 20 * * *", TimeZone: "Local"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateSchedulePolicy(tt.policy, field.NewPath("schedulePolicy"))
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("validateSchedulePolicy(%+v) errors = %v, wantErr %v", tt.policy, errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateGuestMemory(t *testing.T) {
+	tests := []struct {
+		name    string
+		guest   string
+		memory  string
+		wantErr bool
+	}{
+		{name: "unset", memory: "2Gi"},
+		{name: "equal", guest: "2Gi", memory: "2Gi"},
+		{name: "overcommit", guest: "8Gi", memory: "2Gi"},
+		{name: "smaller than memory", guest: "1Gi", memory: "2Gi", wantErr: true},
+		{name: "not a quantity", guest: "lots", memory: "2Gi", wantErr: true},
+		{name: "zero", guest: "0", memory: "2Gi", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := &VirtualMachineSpec{OS: "ubuntu", Memory: tt.memory, GuestMemory: tt.guest}
+			errs := ValidateVirtualMachineSpec(spec, field.NewPath("spec"))
+			if (len(errs) != 0) != tt.wantErr {
+				t.Fatalf("ValidateVirtualMachineSpec() errors = %v, wantErr %v", errs, tt.wantErr)
+			}
+			if tt.wantErr && errs[0].Field != "spec.guestMemory" {
+				t.Errorf("Expected error on spec.guestMemory, got %s", errs[0].Field)
+			}
+		})
+	}
+}
+
+func TestValidateVirtualMachineSizing(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   VirtualMachineSpec
+		fields []string
+	}{
+		{name: "defaults left to the CRD", spec: VirtualMachineSpec{OS: "ubuntu"}},
+		{name: "supported version", spec: VirtualMachineSpec{OS: "debian", OSVersion: "12", CPUs: 4, Memory: "8Gi", DiskSize: "20Gi"}},
+		{
+			name:   "out of range",
+			spec:   VirtualMachineSpec{OS: "ubuntu", CPUs: MaxVMCPUs + 1, Memory: "0", DiskSize: "big"},
+			fields: []string{"spec.cpus", "spec.memory", "spec.diskSize"},
+		},
+		{name: "unsupported version", spec: VirtualMachineSpec{OS: "alpine", OSVersion: "22.04"}, fields: []string{"spec.osVersion"}},
+		{
+			name: "devices and node selector",
+			spec: VirtualMachineSpec{
+				OS:           "ubuntu",
+				GPUs:         []VMDevice{{Name: "gpu1", DeviceName: "nvidia.com/GA102GL_A10"}, {Name: "Bad_Name", DeviceName: "x"}},
+				HostDevices:  []VMDevice{{Name: "gpu1"}},
+				NodeSelector: map[string]string{"gpu": "a 10"},
+			},
+			fields: []string{"spec.gpus[1].name", "spec.hostDevices[0].name", "spec.hostDevices[0].deviceName", "spec.nodeSelector"},
+		},
+		{
+			name: "additional disks",
+			spec: VirtualMachineSpec{
+				OS: "ubuntu",
+				AdditionalDisks: []VMDisk{
+					{Name: "data", Size: "50Gi"},
+					{Name: "datadisk", Size: "1Gi"},
+					{Name: "data", Size: "0"},
+					{Name: "logs"},
+				},
+			},
+			fields: []string{
+				"spec.additionalDisks[1].name",
+				"spec.additionalDisks[2].name",
+				"spec.additionalDisks[2].size",
+				"spec.additionalDisks[3].size",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateVirtualMachineSpec(&tt.spec, field.NewPath("spec"))
+			if len(errs) != len(tt.fields) {
+				t.Fatalf("Expected %d errors, got %v", len(tt.fields), errs)
+			}
+			for i, err := range errs {
+				if err.Field != tt.fields[i] {
+					t.Errorf("Error %d on %s, want %s", i, err.Field, tt.fields[i])
+				}
+			}
+		})
+	}
+}
+
+func TestGetImageForOSDefaultVersion(t *testing.T) {
+	defer func(saved map[string]string) { DefaultOSVersions = saved }(DefaultOSVersions)
+
+	DefaultOSVersions = map[string]string{"ubuntu": "24.04"}
+	if got := GetImageForOS("ubuntu", ""); got != "quay.io/containerdisks/ubuntu:24.04" {
+		t.Errorf("Expected the default version override, got %q", got)
+	}
+	if got := GetImageForOS("ubuntu", "20.04"); got != "quay.io/containerdisks/ubuntu:20.04" {
+		t.Errorf("Expected an explicit version to win over the default, got %q", got)
+	}
+	if got := GetImageForOS("fedora", ""); got != OSImageMap["fedora"] {
+		t.Errorf("Expected the pinned image for an OS without an override, got %q", got)
+	}
+}
+
+func TestParseDefaultOSVersions(t *testing.T) {
+	versions, err := ParseDefaultOSVersions("ubuntu=24.04, debian=13")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if versions["ubuntu"] != "24.04" || versions["debian"] != "13" || len(versions) != 2 {
+		t.Errorf("Unexpected versions: %v", versions)
+	}
+
+	for _, value := range []string{"ubuntu", "ubuntu=", "windows=11"} {
+		if _, err := ParseDefaultOSVersions(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestValidateSecretFiles(t *testing.T) {
+	ref := SecretKeySelector{Name: "tls", Key: "tls.crt"}
+	tests := []struct {
+		name      string
+		files     []SecretFile
+		cloudInit string
+		wantErr   bool
+	}{
+		{name: "none"},
+		{name: "valid", files: []SecretFile{{SecretRef: ref, Path: "/etc/app/tls.crt", Permissions: "0644"}}},
+		{name: "relative path", files: []SecretFile{{SecretRef: ref, Path: "etc/app/tls.crt"}}, wantErr: true},
+		{name: "unclean path", files: []SecretFile{{SecretRef: ref, Path: "/etc/app/../shadow"}}, wantErr: true},
+		{name: "root", files: []SecretFile{{SecretRef: ref, Path: "/"}}, wantErr: true},
+		{name: "duplicate path", files: []SecretFile{{SecretRef: ref, Path: "/a"}, {SecretRef: ref, Path: "/a"}}, wantErr: true},
+		{name: "bad permissions", files: []SecretFile{{SecretRef: ref, Path: "/a", Permissions: "rw-r--r--"}}, wantErr: true},
+		{name: "missing key", files: []SecretFile{{SecretRef: SecretKeySelector{Name: "tls"}, Path: "/a"}}, wantErr: true},
+		{name: "with custom cloud-init", files: []SecretFile{{SecretRef: ref, Path: "/a"}}, cloudInit: "#cloud-config", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSecretFiles(tt.files, tt.cloudInit); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSecretFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCloudInitSecretRef(t *testing.T) {
+	ref := &SecretKeySelector{Name: "init", Key: "user-data"}
+	file := SecretFile{SecretRef: SecretKeySelector{Name: "tls", Key: "tls.crt"}, Path: "/etc/app/tls.crt"}
+	tests := []struct {
+		name    string
+		spec    VirtualMachineSpec
+		wantErr bool
+	}{
+		{name: "valid", spec: VirtualMachineSpec{OS: "ubuntu", CloudInitSecretRef: ref}},
+		{name: "missing key", spec: VirtualMachineSpec{OS: "ubuntu", CloudInitSecretRef: &SecretKeySelector{Name: "init"}}, wantErr: true},
+		{name: "with cloud-init", spec: VirtualMachineSpec{OS: "ubuntu", CloudInitSecretRef: ref, CloudInit: "#cloud-config"}, wantErr: true},
+		{name: "with secret files", spec: VirtualMachineSpec{OS: "ubuntu", CloudInitSecretRef: ref, SecretFiles: []SecretFile{file}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := ValidateVirtualMachineSpec(&tt.spec, field.NewPath("spec"))
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("ValidateVirtualMachineSpec() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVMPorts(t *testing.T) {
+	tests := []struct {
+		name    string
+		ports   []VMPort
+		wantErr bool
+	}{
+		{name: "valid", ports: []VMPort{{Name: "http", Port: 80}, {Name: "dns", Port: 53, Protocol: "UDP"}, {Port: 53, NodePort: 30053}}},
+		{name: "invalid port", ports: []VMPort{{Port: 70000}}, wantErr: true},
+		{name: "invalid name", ports: []VMPort{{Name: "Not_A_Name", Port: 80}}, wantErr: true},
+		{name: "duplicate name", ports: []VMPort{{Name: "http", Port: 80}, {Name: "http", Port: 8080}}, wantErr: true},
+		{name: "duplicate port", ports: []VMPort{{Port: 80}, {Port: 80, Protocol: "TCP"}}, wantErr: true},
+		{name: "unsupported protocol", ports: []VMPort{{Port: 80, Protocol: "SCTP"}}, wantErr: true},
+		{name: "invalid node port", ports: []VMPort{{Port: 80, NodePort: -1}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := VirtualMachineSpec{OS: "ubuntu", ExposedPorts: tt.ports}
+			errs := ValidateVirtualMachineSpec(&spec, field.NewPath("spec"))
+			if (len(errs) > 0) != tt.wantErr {
+				t.Errorf("ValidateVirtualMachineSpec() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
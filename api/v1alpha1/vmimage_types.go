@@ -0,0 +1,171 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// VMImage phase constants
+const (
+	// VMImagePhasePending is the phase of an uploaded image whose volume is not ready
+	// to receive the upload yet
+	VMImagePhasePending = "Pending"
+	// VMImagePhaseUploadReady is the phase of an uploaded image waiting for its upload
+	VMImagePhaseUploadReady = "UploadReady"
+	VMImagePhaseReady       = "Ready"
+	VMImagePhaseFailed      = "Failed"
+)
+
+// VMImageSpec defines where the disk image VMs boot from comes from. Exactly one of
+// ContainerDisk and Upload is set
+type VMImageSpec struct {
+	// Description tells users what the image contains
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// OS is the operating system in the image, which VMs booting it take when they do
+	// not set one
+	// +kubebuilder:validation:Enum=ubuntu;fedora;debian;centos;alpine;cirros;freebsd
+	// +optional
+	OS string `json:"os,omitempty"`
+
+	// ContainerDisk is a container disk image in a registry
+	// (e.g., "registry.example.com/images/ubuntu:24.04"), pulled like the built-in images
+	// +optional
+	ContainerDisk string `json:"containerDisk,omitempty"`
+
+	// Upload keeps a qcow2 or raw disk image uploaded through the API in a volume,
+	// which VMs booting the image get a clone of
+	// +optional
+	Upload *VMImageUpload `json:"upload,omitempty"`
+}
+
+// VMImageUpload is the volume an uploaded image is stored in
+type VMImageUpload struct {
+	// Size is the size of the volume, which must hold the image converted to raw (e.g.,
+	// "10Gi"). VMs booting the image get a disk of this size
+	Size string `json:"size"`
+
+	// StorageClass is the storage class of the volume, defaulting to the
+	// defaultStorageClass of the LLMCloudConfig, or local-path
+	// +optional
+	StorageClass string `json:"storageClass,omitempty"`
+}
+
+// VMImageVolume names the PVC holding an uploaded image
+type VMImageVolume struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// VMImageStatus defines the observed state of VMImage
+type VMImageStatus struct {
+	// Phase is Ready once VMs can boot the image. Uploaded images are Pending, then
+	// UploadReady until the upload completes, or Failed
+	// +optional
+	Phase string `json:"phase,omitempty"`
+
+	// Progress is the progress of the upload as reported by CDI (e.g., "42.5%")
+	// +optional
+	Progress string `json:"progress,omitempty"`
+
+	// Message explains a Failed phase
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Volume is the PVC the image is uploaded to
+	// +optional
+	Volume *VMImageVolume `json:"volume,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="OS",type="string",JSONPath=".spec.os"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Progress",type="string",JSONPath=".status.progress"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description",priority=1
+
+// VMImage is the Schema for the vmimages API. Admins register container disks or
+// upload disk images, and VMs boot one by name with ImageRef instead of the built-in
+// image of their OS
+type VMImage struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the source of the image
+	// +required
+	Spec VMImageSpec `json:"spec"`
+
+	// status reports whether VMs can boot the image
+	// +optional
+	Status VMImageStatus `json:"status,omitempty,omitzero"`
+}
+
+// +kubebuilder:object:root=true
+
+// VMImageList contains a list of VMImage
+type VMImageList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VMImage `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VMImage{}, &VMImageList{})
+}
+
+// ValidateVMImageSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateVMImageSpec(spec *VMImageSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	switch {
+	case spec.ContainerDisk == "" && spec.Upload == nil:
+		allErrs = append(allErrs, field.Required(fldPath, "one of containerDisk or upload is required"))
+	case spec.ContainerDisk != "" && spec.Upload != nil:
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("upload"), "cannot be combined with containerDisk"))
+	}
+	if spec.Upload != nil {
+		if spec.Upload.Size == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("upload", "size"), ""))
+		}
+		allErrs = append(allErrs, validatePositiveQuantity(spec.Upload.Size, fldPath.Child("upload", "size"))...)
+	}
+	return allErrs
+}
+
+// ValidateVMImageUpdate returns the violations of ValidateVMImageSpec in the new spec,
+// and rejects changes to the volume of an uploaded image, which holds the upload
+func ValidateVMImageUpdate(oldSpec, spec *VMImageSpec, fldPath *field.Path) field.ErrorList {
+	allErrs := ValidateVMImageSpec(spec, fldPath)
+	if oldSpec.Upload != nil && (spec.Upload == nil || *spec.Upload != *oldSpec.Upload) {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("upload"), "is immutable"))
+	}
+	return allErrs
+}
+
+// Ready reports whether VMs can boot the image
+func (i *VMImage) Ready() bool {
+	return i.Spec.ContainerDisk != "" || i.Status.Phase == VMImagePhaseReady && i.Status.Volume != nil
+}
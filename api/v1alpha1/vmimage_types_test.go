@@ -0,0 +1,67 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateVMImageSpec(t *testing.T) {
+	tests := []struct {
+		name string
+		spec VMImageSpec
+		want []string
+	}{
+		{name: "container disk", spec: VMImageSpec{ContainerDisk: "registry.local/ubuntu:24.04"}},
+		{name: "upload", spec: VMImageSpec{Upload: &VMImageUpload{Size: "10Gi"}}},
+		{name: "no source", spec: VMImageSpec{OS: "ubuntu"}, want: []string{"spec"}},
+		{
+			name: "both sources",
+			spec: VMImageSpec{ContainerDisk: "registry.local/ubuntu:24.04", Upload: &VMImageUpload{Size: "10Gi"}},
+			want: []string{"spec.upload"},
+		},
+		{name: "no size", spec: VMImageSpec{Upload: &VMImageUpload{}}, want: []string{"spec.upload.size"}},
+		{name: "bad size", spec: VMImageSpec{Upload: &VMImageUpload{Size: "big"}}, want: []string{"spec.upload.size"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateVMImageSpec(&tt.spec, field.NewPath("spec")) {
+				got = append(got, err.Field)
+			}
+			if len(got) != len(tt.want) || len(got) > 0 && got[0] != tt.want[0] {
+				t.Errorf("Expected violations of %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateVMImageUpdate(t *testing.T) {
+	old := VMImageSpec{Upload: &VMImageUpload{Size: "10Gi"}}
+
+	spec := VMImageSpec{Description: "Windows Server", Upload: &VMImageUpload{Size: "10Gi"}}
+	if errs := ValidateVMImageUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 0 {
+		t.Errorf("Expected the description to be mutable, got %v", errs)
+	}
+	spec.Upload.Size = "20Gi"
+	if errs := ValidateVMImageUpdate(&old, &spec, field.NewPath("spec")); len(errs) != 1 || errs[0].Field != "spec.upload" {
+		t.Errorf("Expected the upload to be immutable, got %v", errs)
+	}
+}
+
+func TestVMImageReady(t *testing.T) {
+	if !(&VMImage{Spec: VMImageSpec{ContainerDisk: "registry.local/ubuntu:24.04"}}).Ready() {
+		t.Error("Expected a container disk to be ready")
+	}
+	image := &VMImage{
+		Spec:   VMImageSpec{Upload: &VMImageUpload{Size: "10Gi"}},
+		Status: VMImageStatus{Phase: VMImagePhaseUploadReady, Volume: &VMImageVolume{Namespace: "images", Name: "windows"}},
+	}
+	if image.Ready() {
+		t.Error("Expected an image waiting for its upload not to be ready")
+	}
+	image.Status.Phase = VMImagePhaseReady
+	if !image.Ready() {
+		t.Error("Expected an uploaded image to be ready")
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// VMTemplateSpec defines the size, OS and cloud-init of VMs created from a template.
+// Fields left unset are left to the VM, or to its defaults
+type VMTemplateSpec struct {
+	// Description tells users what the template is for
+	// +kubebuilder:validation:MaxLength=1024
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// CPUs is the number of CPUs for the VM
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	CPUs int32 `json:"cpus,omitempty"`
+
+	// Memory is the amount of memory for the VM (e.g., "2Gi")
+	// +optional
+	Memory string `json:"memory,omitempty"`
+
+	// DiskSize is the size of the persistent disk (e.g., "10Gi")
+	// +optional
+	DiskSize string `json:"diskSize,omitempty"`
+
+	// OS is the operating system for the VM
+	// +kubebuilder:validation:Enum=ubuntu;fedora;debian;centos;alpine;cirros;freebsd
+	// +optional
+	OS string `json:"os,omitempty"`
+
+	// OSVersion is the version of the OS
+	// +optional
+	OSVersion string `json:"osVersion,omitempty"`
+
+	// CloudInit is the cloud-init user data for the VM
+	// +optional
+	CloudInit string `json:"cloudInit,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="CPUs",type="integer",JSONPath=".spec.cpus"
+// +kubebuilder:printcolumn:name="Memory",type="string",JSONPath=".spec.memory"
+// +kubebuilder:printcolumn:name="OS",type="string",JSONPath=".spec.os"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// +kubebuilder:printcolumn:name="Description",type="string",JSONPath=".spec.description",priority=1
+
+// VMTemplate is the Schema for the vmtemplates API. Admins define templates such as
+// "small" or "gpu-large", and VMs reference one by name with TemplateRef
+type VMTemplate struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty,omitzero"`
+
+	// spec defines the VMs created from the template
+	// +required
+	Spec VMTemplateSpec `json:"spec"`
+}
+
+// +kubebuilder:object:root=true
+
+// VMTemplateList contains a list of VMTemplate
+type VMTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []VMTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&VMTemplate{}, &VMTemplateList{})
+}
+
+// ValidateVMTemplateSpec returns every violation in spec that the CRD schema cannot
+// express, with field paths under fldPath
+func ValidateVMTemplateSpec(spec *VMTemplateSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if spec.CPUs < 0 || spec.CPUs > MaxVMCPUs {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("cpus"), spec.CPUs,
+			fmt.Sprintf("must be between 1 and %d", MaxVMCPUs)))
+	}
+	allErrs = append(allErrs, validatePositiveQuantity(spec.Memory, fldPath.Child("memory"))...)
+	allErrs = append(allErrs, validatePositiveQuantity(spec.DiskSize, fldPath.Child("diskSize"))...)
+	if spec.OSVersion != "" && spec.OS == "" {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("osVersion"), "requires os"))
+	}
+	allErrs = append(allErrs, validateAllowedOS(spec.OS, fldPath.Child("os"))...)
+	allErrs = append(allErrs, validateOSVersion(spec.OS, spec.OSVersion, fldPath.Child("osVersion"))...)
+	return allErrs
+}
+
+// ApplyTo fills the fields of spec that are unset with those of the template, so that
+// the VM's own values take precedence. The OS version comes from the template only
+// together with its OS, and the cloud-init not for VMs with secret files, which cannot
+// be combined with it
+func (t *VMTemplate) ApplyTo(spec *VirtualMachineSpec) {
+	if spec.CPUs == 0 {
+		spec.CPUs = t.Spec.CPUs
+	}
+	if spec.Memory == "" {
+		spec.Memory = t.Spec.Memory
+	}
+	if spec.DiskSize == "" {
+		spec.DiskSize = t.Spec.DiskSize
+	}
+	if spec.OS == "" {
+		spec.OS = t.Spec.OS
+		spec.OSVersion = t.Spec.OSVersion
+	}
+	if spec.CloudInit == "" && len(spec.SecretFiles) == 0 {
+		spec.CloudInit = t.Spec.CloudInit
+	}
+}
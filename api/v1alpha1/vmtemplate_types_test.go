@@ -0,0 +1,29 @@
+package v1alpha1
+
+import (
+	"testing"
+)
+
+func TestVMTemplateApplyTo(t *testing.T) {
+	template := &VMTemplate{Spec: VMTemplateSpec{
+		CPUs: 4, Memory: "8Gi", DiskSize: "50Gi", OS: "ubuntu", OSVersion: "24.04", CloudInit: "#cloud-config\n",
+	}}
+
+	spec := VirtualMachineSpec{TemplateRef: "medium", Memory: "16Gi"}
+	template.ApplyTo(&spec)
+	if spec.CPUs != 4 || spec.Memory != "16Gi" || spec.DiskSize != "50Gi" || spec.CloudInit != "#cloud-config\n" {
+		t.Errorf("Expected the template to fill only unset fields, got %+v", spec)
+	}
+	if spec.OS != "ubuntu" || spec.OSVersion != "24.04" {
+		t.Errorf("Expected the template's OS and version, got %s %s", spec.OS, spec.OSVersion)
+	}
+
+	spec = VirtualMachineSpec{OS: "debian", SecretFiles: []SecretFile{{Path: "/etc/token"}}}
+	template.ApplyTo(&spec)
+	if spec.OS != "debian" || spec.OSVersion != "" {
+		t.Errorf("Expected the VM's OS without the template's version, got %s %q", spec.OS, spec.OSVersion)
+	}
+	if spec.CloudInit != "" {
+		t.Error("Expected no cloud-init for a VM with secret files")
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/backup"
+)
+
+var (
+	kubeconfig       string
+	location         string
+	s3Endpoint       string
+	snapshotVMs      bool
+	exportDisks      bool
+	namespaceTimeout time.Duration
+	uploadProxyURL   string
+)
+
+// NewBackupCmd returns the command exporting the llmcloud resources of a cluster
+func NewBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the llmcloud resources of a cluster",
+		Long: `Exports the configuration, projects, users, groups, SSH keys, VM templates and images, VMs, models
+and services of a cluster, and the Secrets they use, to a gzipped tarball, written to a file or to an S3
+bucket (s3://bucket/key). With --export-disks the contents of the VM disks are kept as well. S3
+credentials and region are read from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and
+AWS_REGION variables`,
+		RunE: runBackup,
+	}
+	addFlags(cmd)
+	cmd.Flags().StringVar(&location, "output", "llmcloud-backup-"+time.Now().UTC().Format("20060102-150405")+".tar.gz",
+		"File or s3://bucket/key to write the backup to")
+	cmd.Flags().BoolVar(&snapshotVMs, "snapshot-vms", false,
+		"Also take a VirtualMachineSnapshot of every VM's disks, kept by the cluster's storage")
+	cmd.Flags().BoolVar(&exportDisks, "export-disks", false,
+		"Also keep the contents of every VM's disks, exported from a snapshot, to restore the VMs onto another cluster")
+	return cmd
+}
+
+// NewRestoreCmd returns the command applying a backup to a cluster
+func NewRestoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore",
+		Short: "Restore the llmcloud resources of a backup to a cluster",
+		Long: `Applies the resources of a backup made with the backup command to a cluster, which may be a fresh
+one. The operator must be running, as it creates the namespaces of the restored projects`,
+		RunE: runRestore,
+	}
+	addFlags(cmd)
+	cmd.Flags().StringVar(&location, "input", "", "File or s3://bucket/key to read the backup from")
+	cmd.Flags().DurationVar(&namespaceTimeout, "namespace-timeout", 2*time.Minute,
+		"How long to wait for the operator to create the namespace of a restored project")
+	cmd.Flags().StringVar(&uploadProxyURL, "cdi-upload-proxy-url", "",
+		"URL of the CDI upload proxy the VM disks of the backup are uploaded through (defaults to the cluster IP of its Service)")
+	_ = cmd.MarkFlagRequired("input")
+	return cmd
+}
+
+func addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", filepath.Join(os.Getenv("HOME"), ".kube", "config-llmcloud"), "Kubeconfig path")
+	cmd.Flags().StringVar(&s3Endpoint, "s3-endpoint", "",
+		"URL of the S3-compatible object store (defaults to AWS S3 in AWS_REGION)")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	c, config, err := newClient()
+	if err != nil {
+		return err
+	}
+	opts := backup.Options{SnapshotVMs: snapshotVMs, ExportDisks: exportDisks, Config: config}
+	var manifest *backup.Manifest
+	if bucket, key, ok := backup.ParseS3URL(location); ok {
+		var data bytes.Buffer
+		if manifest, err = backup.Export(cmd.Context(), c, &data, opts); err != nil {
+			return err
+		}
+		if err := backup.S3FromEnv(s3Endpoint).Put(cmd.Context(), bucket, key, data.Bytes()); err != nil {
+			return err
+		}
+	} else if manifest, err = exportFile(cmd.Context(), c, location, opts); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Backed up to %s\n", location)
+	for _, kind := range backup.Resources {
+		fmt.Printf("  %s: %d\n", kind, manifest.Counts[kind])
+	}
+	for _, snapshot := range manifest.Snapshots {
+		fmt.Printf("  VM %s/%s snapshot: %s\n", snapshot.Namespace, snapshot.VirtualMachine, snapshot.Name)
+	}
+	for _, disk := range manifest.Disks {
+		fmt.Printf("  VM %s/%s disk: %s\n", disk.Namespace, disk.VirtualMachine, disk.Volume)
+	}
+	return nil
+}
+
+// exportFile writes a backup straight to a file, as one with disks may not fit in memory
+func exportFile(ctx context.Context, c client.Client, path string, opts backup.Options) (*backup.Manifest, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := backup.Export(ctx, c, f, opts)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	c, _, err := newClient()
+	if err != nil {
+		return err
+	}
+	data, err := read(cmd.Context(), location)
+	if err != nil {
+		return err
+	}
+	manifest, err := backup.Restore(cmd.Context(), c, bytes.NewReader(data),
+		backup.RestoreOptions{NamespaceTimeout: namespaceTimeout, UploadProxyURL: uploadProxyURL})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Restored the backup of %s\n", manifest.CreatedAt.Format(time.RFC3339))
+	for _, kind := range backup.Resources {
+		fmt.Printf("  %s: %d\n", kind, manifest.Counts[kind])
+	}
+	return nil
+}
+
+// newClient returns a client for the cluster of the kubeconfig, and its config
+func newClient() (client.Client, *rest.Config, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(llmcloudv1alpha1.AddToScheme(scheme))
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	return c, config, err
+}
+
+// read loads the data at a file or S3 location
+func read(ctx context.Context, location string) ([]byte, error) {
+	if bucket, key, ok := backup.ParseS3URL(location); ok {
+		return backup.S3FromEnv(s3Endpoint).Get(ctx, bucket, key)
+	}
+	return os.ReadFile(location)
+}
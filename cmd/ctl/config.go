@@ -0,0 +1,111 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the file of the llmcloud endpoints the CLI talks to, like a kubeconfig. Each
+// context is an API server with the credentials of a user
+type Config struct {
+	CurrentContext string    `json:"current-context,omitempty"`
+	Contexts       []Context `json:"contexts,omitempty"`
+}
+
+// Context is an API server and the credentials used with it
+type Context struct {
+	Name   string `json:"name"`
+	Server string `json:"server"`
+	// Username is the user logged in, shown by get-contexts
+	Username string `json:"username,omitempty"`
+	// Token is the token of the last login, used unless APIKey is set
+	Token  string `json:"token,omitempty"`
+	APIKey string `json:"apiKey,omitempty"`
+	// Project is the project commands use when --project is not set
+	Project string `json:"project,omitempty"`
+	// InsecureSkipTLSVerify accepts any certificate of the server, e.g. a self-signed one
+	InsecureSkipTLSVerify bool `json:"insecure-skip-tls-verify,omitempty"`
+}
+
+// defaultConfigPath is $LLMCLOUD_CONFIG, or ~/.llmcloud/config
+func defaultConfigPath() string {
+	if path := os.Getenv("LLMCLOUD_CONFIG"); path != "" {
+		return path
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".llmcloud", "config")
+}
+
+// loadConfig reads the config at path, which is empty if the file does not exist
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// save writes the config to path, readable only by the user as it holds credentials
+func (c *Config) save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// context returns the context name, or the current one when name is empty
+func (c *Config) context(name string) (*Context, error) {
+	if name == "" {
+		name = c.CurrentContext
+	}
+	if name == "" {
+		return nil, errors.New("no current context, log in with: manager ctl login --server URL")
+	}
+	i := slices.IndexFunc(c.Contexts, func(ctx Context) bool { return ctx.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("context %q not found", name)
+	}
+	return &c.Contexts[i], nil
+}
+
+// setContext adds ctx, replacing the context with the same name
+func (c *Config) setContext(ctx Context) {
+	if i := slices.IndexFunc(c.Contexts, func(existing Context) bool { return existing.Name == ctx.Name }); i >= 0 {
+		c.Contexts[i] = ctx
+		return
+	}
+	c.Contexts = append(c.Contexts, ctx)
+}
@@ -0,0 +1,343 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	apiclient "github.com/rusik69/llmcloud-operator/pkg/client"
+)
+
+var (
+	configPath  string
+	contextName string
+	project     string
+
+	server                string
+	username              string
+	apiKeyStdin           bool
+	insecureSkipTLSVerify bool
+)
+
+// NewCtlCmd returns the command line client of the llmcloud API for end users
+func NewCtlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ctl",
+		Short: "Manage projects, VMs and models through the llmcloud API",
+		Long: `A command line client of the llmcloud HTTP API. Log in once per API server with the login command,
+which saves the server and token as a context in ~/.llmcloud/config (or $LLMCLOUD_CONFIG), like a
+kubeconfig. Commands use the current context, or the one named with --context`,
+		SilenceUsage: true,
+	}
+	cmd.PersistentFlags().StringVar(&configPath, "config", defaultConfigPath(), "Path of the CLI config with the contexts")
+	cmd.PersistentFlags().StringVar(&contextName, "context", "", "Context to use instead of the current one")
+	cmd.PersistentFlags().StringVarP(&project, "project", "p", "", "Project to use instead of the context's project")
+
+	cmd.AddCommand(newLoginCmd(), newLogoutCmd(), newConfigCmd())
+	cmd.AddCommand(newProjectCmd(), newVMCmd(), newModelCmd(), newLogsCmd(), newUsageCmd())
+	return cmd
+}
+
+func newLoginCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to an API server and save it as the current context",
+		Long: `Logs in with a username and password, prompted for on a terminal and otherwise read from stdin, and
+saves the server and token as a context named after --context or the server's host. With --api-key-stdin
+the context authenticates with an API key read from stdin instead. Without --server, logs in again to the
+current context's server`,
+		Args: cobra.NoArgs,
+		RunE: runLogin,
+	}
+	cmd.Flags().StringVar(&server, "server", "", "URL of the API server, e.g. https://llmcloud.example.com")
+	cmd.Flags().StringVarP(&username, "username", "u", "", "Username to log in as")
+	cmd.Flags().BoolVar(&apiKeyStdin, "api-key-stdin", false, "Authenticate with an API key read from stdin")
+	cmd.Flags().BoolVar(&insecureSkipTLSVerify, "insecure-skip-tls-verify", false,
+		"Accept any certificate of the server, e.g. a self-signed one")
+	return cmd
+}
+
+func runLogin(cmd *cobra.Command, args []string) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	var ctx Context
+	if existing, err := config.context(contextName); err == nil && (server == "" || existing.Server == server) {
+		ctx = *existing
+	} else {
+		if server == "" {
+			return errors.New("--server is required for a new context")
+		}
+		u, err := url.Parse(server)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("invalid server %q, expected a URL such as https://llmcloud.example.com", server)
+		}
+		ctx = Context{Name: cmp.Or(contextName, u.Host), Server: strings.TrimSuffix(server, "/")}
+	}
+	if cmd.Flags().Changed("insecure-skip-tls-verify") {
+		ctx.InsecureSkipTLSVerify = insecureSkipTLSVerify
+	}
+	ctx.Token, ctx.APIKey = "", ""
+	in := bufio.NewReader(cmd.InOrStdin())
+
+	var projects []string
+	if apiKeyStdin {
+		if ctx.APIKey, err = readLine(in); err != nil {
+			return fmt.Errorf("failed to read the API key: %w", err)
+		}
+		list, err := newAPIClient(&ctx).ListProjects(cmd.Context())
+		if err != nil {
+			return err
+		}
+		for _, p := range list.Items {
+			projects = append(projects, p.Name)
+		}
+		ctx.Username = cmp.Or(username, ctx.Username)
+	} else {
+		user := cmp.Or(username, ctx.Username)
+		if user == "" {
+			_, _ = fmt.Fprint(cmd.ErrOrStderr(), "Username: ")
+			if user, err = readLine(in); err != nil {
+				return fmt.Errorf("failed to read the username: %w", err)
+			}
+		}
+		password, err := readPassword(cmd, in, "Password: ")
+		if err != nil {
+			return fmt.Errorf("failed to read the password: %w", err)
+		}
+		api := newAPIClient(&ctx)
+		session, err := api.Login(cmd.Context(), user, password)
+		if apiclient.IsPasswordChangeRequired(err) {
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "The password must be changed before logging in")
+			newPassword, err := readPassword(cmd, in, "New password: ")
+			if err != nil {
+				return fmt.Errorf("failed to read the new password: %w", err)
+			}
+			session, err = api.ChangePassword(cmd.Context(), user, password, newPassword)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+		ctx.Token, ctx.Username, projects = session.Token, session.Username, session.Projects
+	}
+	// A single project is the obvious default, and a project the user left is not one
+	if len(projects) > 0 && !slices.Contains(projects, ctx.Project) {
+		ctx.Project = ""
+		if len(projects) == 1 {
+			ctx.Project = projects[0]
+		}
+	}
+
+	config.setContext(ctx)
+	config.CurrentContext = ctx.Name
+	if err := config.save(configPath); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Logged in to %s as %s (context %s)\n", ctx.Server, ctx.Username, ctx.Name)
+	return nil
+}
+
+func newLogoutCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "logout",
+		Short: "Revoke the token of the current context and forget its credentials",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(configPath)
+			if err != nil {
+				return err
+			}
+			ctx, err := config.context(contextName)
+			if err != nil {
+				return err
+			}
+			if ctx.Token != "" {
+				// The token may have expired already, which is as good as revoked
+				if err := newAPIClient(ctx).Logout(cmd.Context()); err != nil && !hasStatus(err, http.StatusUnauthorized) {
+					return err
+				}
+			}
+			ctx.Token, ctx.APIKey = "", ""
+			if err := config.save(configPath); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Logged out of %s\n", ctx.Server)
+			return nil
+		},
+	}
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show and switch the contexts of the CLI config",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "get-contexts",
+		Short: "List the contexts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := loadConfig(configPath)
+			if err != nil {
+				return err
+			}
+			w := newTable(cmd, "CURRENT", "NAME", "SERVER", "USER", "PROJECT")
+			for _, ctx := range config.Contexts {
+				current := ""
+				if ctx.Name == config.CurrentContext {
+					current = "*"
+				}
+				row(w, current, ctx.Name, ctx.Server, ctx.Username, ctx.Project)
+			}
+			return w.Flush()
+		},
+	}, &cobra.Command{
+		Use:   "use-context NAME",
+		Short: "Make NAME the current context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateConfig(cmd, args[0], func(config *Config, ctx *Context) {
+				config.CurrentContext = ctx.Name
+			}, "✓ Switched to context "+args[0])
+		},
+	}, &cobra.Command{
+		Use:   "set-project NAME",
+		Short: "Make NAME the project of the current context",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return updateConfig(cmd, contextName, func(config *Config, ctx *Context) {
+				ctx.Project = args[0]
+			}, "✓ Using project "+args[0])
+		},
+	})
+	return cmd
+}
+
+// updateConfig applies update to the context name and saves the config
+func updateConfig(cmd *cobra.Command, name string, update func(*Config, *Context), done string) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	ctx, err := config.context(name)
+	if err != nil {
+		return err
+	}
+	update(config, ctx)
+	if err := config.save(configPath); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), done)
+	return nil
+}
+
+// connect returns a client of the API server of the context in use, and the context
+func connect() (*apiclient.Client, *Context, error) {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, err := config.context(contextName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.Token == "" && ctx.APIKey == "" {
+		return nil, nil, fmt.Errorf("not logged in to %s, log in with: manager ctl login", ctx.Server)
+	}
+	return newAPIClient(ctx), ctx, nil
+}
+
+func newAPIClient(ctx *Context) *apiclient.Client {
+	c := apiclient.New(ctx.Server)
+	c.APIKey = ctx.APIKey
+	c.SetToken(ctx.Token)
+	if ctx.InsecureSkipTLSVerify {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec // Requested with --insecure-skip-tls-verify
+		c.HTTPClient = &http.Client{Transport: transport}
+	}
+	return c
+}
+
+// projectName returns --project, or the project of ctx
+func projectName(ctx *Context) (string, error) {
+	name := cmp.Or(project, ctx.Project)
+	if name == "" {
+		return "", errors.New("no project selected, use --project or: manager ctl config set-project NAME")
+	}
+	return name, nil
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *apiclient.Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// readLine reads a line from in, without its line ending
+func readLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readPassword prompts for a password with prompt without echoing it on a terminal, and
+// reads it from in otherwise
+func readPassword(cmd *cobra.Command, in *bufio.Reader, prompt string) (string, error) {
+	if f, ok := cmd.InOrStdin().(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		_, _ = fmt.Fprint(cmd.ErrOrStderr(), prompt)
+		password, err := term.ReadPassword(int(f.Fd()))
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr())
+		return string(password), err
+	}
+	return readLine(in)
+}
+
+// newTable returns a writer of a table with the columns headers
+func newTable(cmd *cobra.Command, headers ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, strings.Join(headers, "\t"))
+	return w
+}
+
+func row(w io.Writer, cells ...any) {
+	for i, cell := range cells {
+		if i > 0 {
+			_, _ = fmt.Fprint(w, "\t")
+		}
+		_, _ = fmt.Fprint(w, cell)
+	}
+	_, _ = fmt.Fprintln(w)
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	apiclient "github.com/rusik69/llmcloud-operator/pkg/client"
+)
+
+// run runs the ctl command with args and stdin, returning its output
+func run(t *testing.T, config, stdin string, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewCtlCmd()
+	var out bytes.Buffer
+	cmd.SetArgs(append([]string{"--config", config}, args...))
+	cmd.SetIn(strings.NewReader(stdin))
+	cmd.SetOut(&out)
+	cmd.SetErr(&out)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestCtl(t *testing.T) {
+	var created llmcloudv1alpha1.VirtualMachine
+	var actions []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["username"] != "alice" || req["password"] != "secret" {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(apiclient.Session{Token: "token", Username: "alice", Projects: []string{"team"}})
+	})
+	authorized := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer token" {
+				http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+				return
+			}
+			h(w, r)
+		}
+	}
+	mux.HandleFunc("GET /api/v1/projects", authorized(func(w http.ResponseWriter, r *http.Request) {
+		project := llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "team"}}
+		project.Status.VMCount = 2
+		_ = json.NewEncoder(w).Encode(llmcloudv1alpha1.ProjectList{Items: []llmcloudv1alpha1.Project{project}})
+	}))
+	mux.HandleFunc("POST /api/v1/namespaces/project-team/vms", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&created)
+		_ = json.NewEncoder(w).Encode(created)
+	}))
+	mux.HandleFunc("POST /api/v1/actions/vm/project-team/web/{action}", authorized(func(w http.ResponseWriter, r *http.Request) {
+		actions = append(actions, r.PathValue("action"))
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	}))
+	mux.HandleFunc("POST /api/v1/bulk/vms/{action}", authorized(func(w http.ResponseWriter, r *http.Request) {
+		var req apiclient.BulkRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		response := apiclient.BulkResponse{Resource: "vms", Action: r.PathValue("action")}
+		for _, item := range req.Items {
+			result := apiclient.BulkResult{Namespace: item.Namespace, Name: item.Name, Status: http.StatusOK}
+			if item.Name == "missing" {
+				result.Status, result.Error = http.StatusNotFound, "VM not found"
+				response.Failed++
+			} else {
+				actions = append(actions, r.PathValue("action")+" "+item.Name)
+				response.Succeeded++
+			}
+			response.Results = append(response.Results, result)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}))
+	mux.HandleFunc("GET /api/v1/logs/project-team/models/llama", authorized(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tail=" + r.URL.Query().Get("tailLines") + "\n"))
+	}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	config := filepath.Join(t.TempDir(), "config")
+
+	if _, err := run(t, config, "", "project", "list"); err == nil || !strings.Contains(err.Error(), "no current context") {
+		t.Errorf("Expected an error before logging in, got %v", err)
+	}
+	if _, err := run(t, config, "wrong\n", "login", "--server", srv.URL, "-u", "alice"); err == nil {
+		t.Error("Expected a wrong password to fail")
+	}
+	out, err := run(t, config, "secret\n", "login", "--server", srv.URL, "-u", "alice", "--context", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Logged in to "+srv.URL+" as alice (context dev)") {
+		t.Errorf("Unexpected login output %q", out)
+	}
+	saved, err := loadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.CurrentContext != "dev" || len(saved.Contexts) != 1 || saved.Contexts[0].Token != "token" ||
+		saved.Contexts[0].Project != "team" {
+		t.Errorf("Unexpected config %+v", saved)
+	}
+
+	out, err = run(t, config, "", "project", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "NAME") || !strings.Contains(out, "team") {
+		t.Errorf("Unexpected project list %q", out)
+	}
+
+	if _, err := run(t, config, "", "vm", "create", "web", "--os", "fedora", "--cpus", "2", "--memory", "4Gi"); err != nil {
+		t.Fatal(err)
+	}
+	if created.Name != "web" || created.Spec.OS != "fedora" || created.Spec.CPUs != 2 || created.Spec.Memory != "4Gi" {
+		t.Errorf("Unexpected VM %+v", created)
+	}
+	for _, action := range []string{"start", "stop"} {
+		if _, err := run(t, config, "", "vm", action, "web"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(actions, []string{"start", "stop"}) {
+		t.Errorf("Unexpected actions %v", actions)
+	}
+	out, err = run(t, config, "", "vm", "stop", "web", "db", "missing")
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 VMs failed") {
+		t.Errorf("Expected the missing VM to fail, got %v", err)
+	}
+	if !strings.Contains(out, "✓ Requested stop of VM db") || !strings.Contains(out, "✗ VM missing: VM not found") {
+		t.Errorf("Unexpected bulk output %q", out)
+	}
+	if !slices.Equal(actions, []string{"start", "stop", "stop web", "stop db"}) {
+		t.Errorf("Unexpected actions %v", actions)
+	}
+
+	out, err = run(t, config, "", "logs", "model/llama", "--tail", "20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "tail=20\n" {
+		t.Errorf("Unexpected logs %q", out)
+	}
+	if _, err := run(t, config, "", "logs", "template/small"); err == nil {
+		t.Error("Expected an unknown resource type to fail")
+	}
+	if _, err := run(t, config, "", "vm", "list", "--project", "other"); err == nil {
+		t.Error("Expected another project to be used with --project")
+	}
+}
+
+func TestConfigContexts(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config")
+	initial := &Config{CurrentContext: "dev", Contexts: []Context{
+		{Name: "dev", Server: "https://dev.example.com", Token: "a"},
+		{Name: "prod", Server: "https://prod.example.com", Token: "b"},
+	}}
+	if err := initial.save(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(t, config, "", "config", "use-context", "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, config, "", "config", "set-project", "team"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run(t, config, "", "config", "use-context", "staging"); err == nil {
+		t.Error("Expected an unknown context to fail")
+	}
+	out, err := run(t, config, "", "config", "get-contexts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[2], "*") || !strings.Contains(lines[2], "team") {
+		t.Errorf("Unexpected contexts:\n%s", out)
+	}
+
+	saved, err := loadConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := saved.context("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctx.Name != "prod" || ctx.Project != "team" {
+		t.Errorf("Unexpected current context %+v", ctx)
+	}
+	if ctx, err := saved.context("dev"); err != nil || ctx.Project != "" {
+		t.Errorf("Expected dev to keep no project, got %+v, %v", ctx, err)
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "centos"},
+	}
+	ssh := llmcloudv1alpha1.VMPort{Port: 22, Protocol: "TCP", NodePort: 30022}
+
+	for _, tt := range []struct {
+		name     string
+		exposure apiclient.VMExposure
+		user     string
+		want     []string
+	}{
+		{"node port", apiclient.VMExposure{Ports: []llmcloudv1alpha1.VMPort{ssh}}, "",
+			[]string{"-p", "30022", "cloud-user@llmcloud.example.com"}},
+		{"load balancer", apiclient.VMExposure{Ports: []llmcloudv1alpha1.VMPort{ssh}, Addresses: []string{"203.0.113.10"}}, "admin",
+			[]string{"admin@203.0.113.10"}},
+		{"not exposed", apiclient.VMExposure{Ports: []llmcloudv1alpha1.VMPort{{Port: 80, Protocol: "TCP"}}}, "", nil},
+		{"no node port yet", apiclient.VMExposure{Ports: []llmcloudv1alpha1.VMPort{{Port: 22}}}, "", nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sshCommand("https://llmcloud.example.com:8443", vm, &tt.exposure, tt.user)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,411 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ctl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+	apiclient "github.com/rusik69/llmcloud-operator/pkg/client"
+)
+
+var (
+	description string
+	ttl         time.Duration
+
+	vmSpec  llmcloudv1alpha1.VirtualMachineSpec
+	sshUser string
+
+	modelSpec llmcloudv1alpha1.LLMModelSpec
+
+	follow    bool
+	tailLines int64
+	container string
+)
+
+// execSSH runs ssh with args, attached to the terminal
+var execSSH = func(args []string) error {
+	cmd := exec.Command("ssh", args...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+func newProjectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "project",
+		Short: "List and create projects",
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the projects you are a member of",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := connect()
+			if err != nil {
+				return err
+			}
+			projects, err := c.ListProjects(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := newTable(cmd, "NAME", "PHASE", "VMS", "MODELS", "SERVICES", "DESCRIPTION")
+			for _, p := range projects.Items {
+				row(w, p.Name, p.Status.Phase, p.Status.VMCount, p.Status.LLMModelCount, p.Status.ServiceCount, p.Spec.Description)
+			}
+			return w.Flush()
+		},
+	}
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create a project",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, _, err := connect()
+			if err != nil {
+				return err
+			}
+			req := apiclient.ProjectRequest{Name: args[0], Description: description}
+			if ttl > 0 {
+				req.TTL = &metav1.Duration{Duration: ttl}
+			}
+			if _, err := c.CreateProject(cmd.Context(), req); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Created project %s\n", args[0])
+			return nil
+		},
+	}
+	create.Flags().StringVar(&description, "description", "", "Description of the project")
+	create.Flags().DurationVar(&ttl, "ttl", 0, "Delete the project once it is this old, e.g. 72h")
+	cmd.AddCommand(list, create)
+	return cmd
+}
+
+func newVMCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "vm",
+		Short: "Manage the VMs of a project",
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the VMs of the project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			vms, err := c.VMs(namespace).List(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := newTable(cmd, "NAME", "OS", "CPUS", "MEMORY", "PHASE", "IP", "NODE")
+			for _, vm := range vms.Items {
+				row(w, vm.Name, vm.Spec.OS, vm.Spec.CPUs, vm.Spec.Memory, vm.Status.Phase, vm.Status.IPAddress, vm.Status.Node)
+			}
+			return w.Flush()
+		},
+	}
+	create := &cobra.Command{
+		Use:   "create NAME",
+		Short: "Create a VM",
+		Long: `Creates a VM in the project. Sizes left unset are taken from the --template, or from the defaults
+of the operator`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: args[0], Namespace: namespace},
+				Spec:       vmSpec,
+			}
+			if _, err := c.VMs(namespace).Create(cmd.Context(), vm); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Created VM %s\n", args[0])
+			return nil
+		},
+	}
+	create.Flags().StringVar(&vmSpec.OS, "os", "ubuntu", "Operating system: ubuntu, fedora, debian, centos, alpine, cirros or freebsd")
+	create.Flags().StringVar(&vmSpec.OSVersion, "os-version", "", "Version of the operating system")
+	create.Flags().StringVar(&vmSpec.TemplateRef, "template", "", "VM template to take the sizes from")
+	create.Flags().StringVar(&vmSpec.ImageRef, "image", "", "VM image to boot instead of the OS's cloud image")
+	create.Flags().Int32Var(&vmSpec.CPUs, "cpus", 0, "Number of CPUs")
+	create.Flags().StringVar(&vmSpec.Memory, "memory", "", "Memory, e.g. 4Gi")
+	create.Flags().StringVar(&vmSpec.DiskSize, "disk-size", "", "Size of the root disk, e.g. 20Gi")
+	create.Flags().StringSliceVar(&vmSpec.SSHKeyRefs, "ssh-key", nil, "Name of an SSH key to authorize, repeatable")
+
+	ssh := &cobra.Command{
+		Use:   "ssh NAME [-- SSH_ARGS...]",
+		Short: "Connect to a VM with ssh",
+		Long: `Runs ssh to the exposed port 22 of a VM, through its LoadBalancer address or its node port on the
+API server's host. Arguments after -- are passed to ssh`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			vm, err := c.VMs(namespace).Get(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			exposure, err := c.GetVMExposure(cmd.Context(), namespace, args[0])
+			if err != nil {
+				return err
+			}
+			sshArgs, err := sshCommand(c.BaseURL, vm, exposure, sshUser)
+			if err != nil {
+				return err
+			}
+			return execSSH(append(sshArgs, args[1:]...))
+		},
+	}
+	ssh.Flags().StringVar(&sshUser, "user", "", "User to log in as, the default user of the VM's OS when empty")
+
+	cmd.AddCommand(list, create, ssh, newVMActionCmd(apiclient.VMActionStart), newVMActionCmd(apiclient.VMActionStop))
+	return cmd
+}
+
+// newVMActionCmd runs action on the VMs named, all in one bulk request when there are several
+func newVMActionCmd(action string) *cobra.Command {
+	return &cobra.Command{
+		Use:   action + " NAME...",
+		Short: strings.ToUpper(action[:1]) + action[1:] + " VMs",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				if err := c.VMAction(cmd.Context(), namespace, args[0], action); err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Requested %s of VM %s\n", action, args[0])
+				return nil
+			}
+
+			req := apiclient.BulkRequest{}
+			for _, name := range args {
+				req.Items = append(req.Items, apiclient.BulkItem{Namespace: namespace, Name: name})
+			}
+			response, err := c.Bulk(cmd.Context(), "vms", action, req)
+			if err != nil {
+				return err
+			}
+			for _, result := range response.Results {
+				if result.Error != "" {
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✗ VM %s: %s\n", result.Name, result.Error)
+				} else {
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Requested %s of VM %s\n", action, result.Name)
+				}
+			}
+			if response.Failed > 0 {
+				return fmt.Errorf("%d of %d VMs failed", response.Failed, len(args))
+			}
+			return nil
+		},
+	}
+}
+
+// sshCommand returns the arguments of ssh connecting as user to the exposed port 22 of
+// vm, through the first LoadBalancer address or the node port on the host of server
+func sshCommand(server string, vm *llmcloudv1alpha1.VirtualMachine, exposure *apiclient.VMExposure, user string) ([]string, error) {
+	var port *llmcloudv1alpha1.VMPort
+	for i, p := range exposure.Ports {
+		if p.Port == 22 && (p.Protocol == "" || p.Protocol == "TCP") {
+			port = &exposure.Ports[i]
+		}
+	}
+	if port == nil {
+		return nil, fmt.Errorf("VM %s does not expose port 22", vm.Name)
+	}
+	if user == "" {
+		user = controller.DefaultVMUser(vm.Spec.OS)
+	}
+
+	switch {
+	case len(exposure.Addresses) > 0:
+		return []string{user + "@" + exposure.Addresses[0]}, nil
+	case port.NodePort != 0:
+		u, err := url.Parse(server)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"-p", strconv.Itoa(int(port.NodePort)), user + "@" + u.Hostname()}, nil
+	}
+	return nil, fmt.Errorf("port 22 of VM %s has no address or node port yet", vm.Name)
+}
+
+func newModelCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "model",
+		Short: "Manage the LLM models of a project",
+	}
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List the models of the project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			models, err := c.Models(namespace).List(cmd.Context())
+			if err != nil {
+				return err
+			}
+			w := newTable(cmd, "NAME", "MODEL", "PHASE", "READY", "ENDPOINT")
+			for _, m := range models.Items {
+				ready := fmt.Sprintf("%d/%d", m.Status.ReadyReplicas, max(m.Spec.Replicas, 1))
+				row(w, m.Name, cmp.Or(m.Spec.ModelName, m.Spec.Preset), m.Status.Phase, ready, m.Status.Endpoint)
+			}
+			return w.Flush()
+		},
+	}
+	deploy := &cobra.Command{
+		Use:   "deploy NAME",
+		Short: "Deploy an LLM model",
+		Long: `Deploys a model of the catalog with --preset, or any model with --model-name and --provider. The fields
+a preset leaves empty are taken from the catalog`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if modelSpec.Preset == "" && modelSpec.ModelName == "" {
+				return errors.New("--preset or --model-name is required")
+			}
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: args[0], Namespace: namespace},
+				Spec:       modelSpec,
+			}
+			if _, err := c.Models(namespace).Create(cmd.Context(), model); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "✓ Deploying model %s\n", args[0])
+			return nil
+		},
+	}
+	deploy.Flags().StringVar(&modelSpec.Preset, "preset", "", "Model of the catalog to deploy, e.g. mistral-7b-q4")
+	deploy.Flags().StringVar(&modelSpec.ModelName, "model-name", "", "Name of the model at its provider")
+	deploy.Flags().StringVar(&modelSpec.Provider, "provider", "", "Provider to download the model from")
+	deploy.Flags().Int32Var(&modelSpec.Replicas, "replicas", 0, "Number of replicas serving the model")
+	cmd.AddCommand(list, deploy)
+	return cmd
+}
+
+// logResources maps the resource types of the logs command to those of the API
+var logResources = map[string]string{
+	"vm": "vms", "vms": "vms",
+	"model": "models", "models": "models",
+	"service": "services", "services": "services",
+}
+
+func newLogsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs TYPE/NAME",
+		Short: "Print or tail the log of a VM, model or service",
+		Long: `Prints the log of the newest pod of a VM, model or service, e.g. model/llama. The log of a VM is its
+serial console. With -f, keeps printing new lines until interrupted`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			kind, name, _ := strings.Cut(args[0], "/")
+			resource, ok := logResources[kind]
+			if !ok || name == "" {
+				return fmt.Errorf("invalid %q, expected vm/NAME, model/NAME or service/NAME", args[0])
+			}
+			c, namespace, err := connectProject()
+			if err != nil {
+				return err
+			}
+			opts := apiclient.LogOptions{Follow: follow, Container: container}
+			if tailLines >= 0 {
+				opts.TailLines = &tailLines
+			}
+			logs, err := c.Logs(cmd.Context(), namespace, resource, name, opts)
+			if err != nil {
+				return err
+			}
+			defer func() { _ = logs.Close() }()
+			_, err = io.Copy(cmd.OutOrStdout(), logs)
+			return err
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Keep printing new lines")
+	cmd.Flags().Int64Var(&tailLines, "tail", -1, "Print only the last lines, all when negative")
+	cmd.Flags().StringVarP(&container, "container", "c", "", "Container of the pod to print")
+	return cmd
+}
+
+func newUsageCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "usage",
+		Short: "Report the inference usage of the project",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, ctx, err := connect()
+			if err != nil {
+				return err
+			}
+			name, err := projectName(ctx)
+			if err != nil {
+				return err
+			}
+			report, err := c.ProjectUsage(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			w := newTable(cmd, "MODEL", "REQUESTS", "PROMPT TOKENS", "COMPLETION TOKENS")
+			for _, m := range report.Models {
+				row(w, m.Name, m.Total.Requests, m.Total.PromptTokens, m.Total.CompletionTokens)
+			}
+			row(w, "TOTAL", report.Total.Requests, report.Total.PromptTokens, report.Total.CompletionTokens)
+			return w.Flush()
+		},
+	}
+}
+
+// connectProject returns a client and the namespace of the project in use
+func connectProject() (*apiclient.Client, string, error) {
+	c, ctx, err := connect()
+	if err != nil {
+		return nil, "", err
+	}
+	name, err := projectName(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	return c, "project-" + name, nil
+}
@@ -18,6 +18,7 @@ package deploy
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
@@ -26,45 +27,122 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rusik69/llmcloud-operator/internal/kubeops"
+	"github.com/rusik69/llmcloud-operator/internal/sshexec"
 )
 
 var (
-	sshHost       string
-	k0sVersion    string
-	kubeconfig    string
-	storageDevice string
+	sshHost                 string
+	workers                 []string
+	inventoryFile           string
+	k0sVersion              string
+	kubeconfig              string
+	storageDevice           string
+	stopTimeout             time.Duration
+	startTimeout            time.Duration
+	leaderElect             bool
+	leaderElectionNamespace string
+
+	// hosts holds the SSH connections to the nodes
+	hosts sshexec.Pool
+	// kube is the client of the deployed cluster, once its kubeconfig is saved
+	kube *kubeops.Client
+)
+
+const (
+	managerPath = "/opt/llmcloud-operator/manager"
+
+	// managerPattern matches the command line of the manager for pgrep and pkill, but
+	// not that of the shell running them, which only contains the pattern itself
+	managerPattern = "[/]opt/llmcloud-operator/manager"
+
+	// leaderElectionID must match the LeaderElectionID of the manager
+	leaderElectionID = "fe560ec5.llmcloud.io"
+
+	// pollInterval is how often the operator is checked while stopping or starting
+	pollInterval = time.Second
 )
 
+// sleep is stubbed in tests
+var sleep = time.Sleep
+
 func NewDeployCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Deploy llmcloud-operator to remote k0s cluster",
-		Long:  `Deploys k0s cluster and llmcloud-operator to a remote host via SSH`,
-		RunE:  runDeploy,
+		Long: `Deploys k0s cluster and llmcloud-operator to a remote host via SSH, or with --mode=in-cluster
+deploys llmcloud-operator as a Deployment into the existing cluster of --kubeconfig`,
+		RunE: runDeploy,
 	}
 
-	cmd.Flags().StringVar(&sshHost, "ssh-host", os.Getenv("SSH_HOST"), "SSH host (user@hostname)")
+	cmd.Flags().StringVar(&deployMode, "mode", deployModeHost,
+		"host installs k3s on the SSH hosts and runs the operator there; in-cluster runs it as a Deployment in the --kubeconfig cluster")
+	cmd.Flags().StringVar(&sshHost, "ssh-host", os.Getenv("SSH_HOST"), "SSH host (user@hostname) of the control plane")
+	cmd.Flags().StringSliceVar(&workers, "workers", nil, "SSH hosts (user@hostname) to join as worker nodes")
+	cmd.Flags().StringVar(&inventoryFile, "inventory", "",
+		"YAML file listing the controlPlane and workers, each with a host and optional storageDevice and labels")
 	cmd.Flags().StringVar(&k0sVersion, "k0s-version", "v1.29.1+k0s.0", "k0s version to install")
 	defaultKubeconfig := filepath.Join(os.Getenv("HOME"), ".kube", "config-llmcloud")
 	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", defaultKubeconfig, "Kubeconfig path")
-	cmd.Flags().StringVar(&storageDevice, "storage-device", "/dev/sda", "Block device for storage (VMs, containers, data)")
+	cmd.Flags().StringVar(&storageDevice, "storage-device", "/dev/sda",
+		"Block device for storage (VMs, containers, data) on nodes the inventory gives none")
+	cmd.Flags().DurationVar(&stopTimeout, "stop-timeout", 30*time.Second,
+		"How long the running operator may take to shut down gracefully before it is killed")
+	cmd.Flags().DurationVar(&startTimeout, "start-timeout", time.Minute,
+		"How long to wait for the restarted operator to report ready")
+	cmd.Flags().BoolVar(&leaderElect, "leader-elect", false, "Run the operator with leader election enabled")
+	cmd.Flags().StringVar(&leaderElectionNamespace, "leader-election-namespace", "kube-system",
+		"Namespace of the operator's leader election lease")
+	cmd.Flags().StringVar(&image, "image", "ghcr.io/rusik69/llmcloud-operator:latest", "Operator image (in-cluster mode)")
+	cmd.Flags().StringVar(&namespace, "namespace", "llmcloud-system", "Namespace the operator runs in (in-cluster mode)")
+	cmd.Flags().IntVar(&replicas, "replicas", 1, "Operator replicas; all serve the API, the leader reconciles (in-cluster mode)")
+	cmd.Flags().StringVar(&ingressHost, "ingress-host", "", "Host to expose the API on through an Ingress (in-cluster mode)")
+	cmd.Flags().StringVar(&ingressClass, "ingress-class", "", "IngressClass of the Ingress (defaults to the cluster's)")
+	cmd.Flags().StringVar(&ingressTLSSecret, "ingress-tls-secret", "", "Secret with the Ingress's TLS certificate (plain HTTP when empty)")
+	cmd.Flags().BoolVar(&monitoring, "monitoring", false,
+		"Install Prometheus and Grafana with dashboards for the operator, VMs, models and KubeVirt")
+	cmd.Flags().StringVar(&monitoringNamespace, "monitoring-namespace", "monitoring", "Namespace of Prometheus and Grafana")
+	cmd.Flags().StringVar(&prometheusRetention, "prometheus-retention", "15d", "How long Prometheus keeps metrics")
+
+	sshFlags := flag.NewFlagSet("ssh", flag.ContinueOnError)
+	hosts.Config.BindFlags(sshFlags, "")
+	cmd.Flags().AddGoFlagSet(sshFlags)
 
 	return cmd
 }
 
 func runDeploy(cmd *cobra.Command, args []string) error {
-	if sshHost == "" {
-		return fmt.Errorf("--ssh-host or SSH_HOST environment variable must be set")
+	switch deployMode {
+	case deployModeInCluster:
+		return runInClusterDeploy()
+	case deployModeHost:
+	default:
+		return fmt.Errorf("unknown --mode %q: use %s or %s", deployMode, deployModeHost, deployModeInCluster)
 	}
 
-	fmt.Printf("==> Deploying to %s\n", sshHost)
+	inv, err := loadInventory()
+	if err != nil {
+		return err
+	}
+	sshHost = inv.ControlPlane.Host
+	defer hosts.Close()
 
-	// Setup storage device
-	if err := setupStorageDevice(); err != nil {
-		return fmt.Errorf("failed to setup storage device: %w", err)
+	fmt.Printf("==> Deploying to %s", sshHost)
+	if len(inv.Workers) > 0 {
+		fmt.Printf(" with %d worker(s)", len(inv.Workers))
+	}
+	fmt.Println()
+
+	// Setup storage devices
+	for _, n := range inv.nodes() {
+		if err := setupStorageDevice(n.Host, n.StorageDevice); err != nil {
+			return fmt.Errorf("failed to setup storage device on %s: %w", n.Host, err)
+		}
 	}
 
 	// Deploy k0s
@@ -72,6 +150,14 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to deploy k0s: %w", err)
 	}
 
+	// Join workers and label nodes
+	if err := joinWorkers(sshHost, inv.Workers); err != nil {
+		return err
+	}
+	if err := labelNodes(inv); err != nil {
+		return err
+	}
+
 	// Build frontend
 	if err := buildFrontend(); err != nil {
 		return fmt.Errorf("failed to build frontend: %w", err)
@@ -87,23 +173,35 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to create root user: %w", err)
 	}
 
+	if monitoring {
+		values, err := newMonitoringValues(sshHost, "")
+		if err != nil {
+			return fmt.Errorf("failed to install monitoring: %w", err)
+		}
+		if err := installMonitoring(values); err != nil {
+			return fmt.Errorf("failed to install monitoring: %w", err)
+		}
+	}
+
 	fmt.Println("\n✓ Deployment completed successfully!")
 	return nil
 }
 
-func setupStorageDevice() error {
-	fmt.Printf("==> Setting up storage device %s\n", storageDevice)
+// setupStorageDevice formats and mounts storageDevice at /mnt on host, unless it is
+// missing or already mounted
+func setupStorageDevice(host, storageDevice string) error {
+	fmt.Printf("==> Setting up storage device %s on %s\n", storageDevice, host)
 
 	// Check if device exists
 	checkDeviceCmd := fmt.Sprintf("test -b %s", storageDevice)
-	if err := execCommand("ssh", sshHost, checkDeviceCmd); err != nil {
+	if err := runOn(host, checkDeviceCmd); err != nil {
 		fmt.Printf("⚠ Warning: Device %s not found, skipping storage setup\n", storageDevice)
 		return nil
 	}
 
 	// Check if device is already mounted
 	checkMountCmd := fmt.Sprintf("mountpoint -q /mnt || mount | grep -q '%s'", storageDevice)
-	if execCommand("ssh", sshHost, checkMountCmd) == nil {
+	if runOn(host, checkMountCmd) == nil {
 		fmt.Println("✓ Storage device already mounted at /mnt")
 		return nil
 	}
@@ -111,18 +209,18 @@ func setupStorageDevice() error {
 	fmt.Printf("Formatting %s with ext4 filesystem...\n", storageDevice)
 	// Format the device with ext4
 	formatCmd := fmt.Sprintf("sudo mkfs.ext4 -F %s", storageDevice)
-	if err := execCommand("ssh", sshHost, formatCmd); err != nil {
+	if err := runOn(host, formatCmd); err != nil {
 		return fmt.Errorf("failed to format device: %w", err)
 	}
 
 	// Create mount point
 	fmt.Println("Creating mount point /mnt...")
-	_ = execCommand("ssh", sshHost, "sudo mkdir -p /mnt")
+	_ = runOn(host, "sudo mkdir -p /mnt")
 
 	// Mount the device
 	fmt.Println("Mounting storage device at /mnt...")
 	mountCmd := fmt.Sprintf("sudo mount %s /mnt", storageDevice)
-	if err := execCommand("ssh", sshHost, mountCmd); err != nil {
+	if err := runOn(host, mountCmd); err != nil {
 		return fmt.Errorf("failed to mount device: %w", err)
 	}
 
@@ -131,12 +229,12 @@ func setupStorageDevice() error {
 	fstabEntry := fmt.Sprintf("%s /mnt ext4 defaults 0 2", storageDevice)
 	fstabCheck := fmt.Sprintf("sudo grep -q '%s' /etc/fstab", storageDevice)
 	fstabCmd := fmt.Sprintf("%s || echo '%s' | sudo tee -a /etc/fstab", fstabCheck, fstabEntry)
-	_ = execCommand("ssh", sshHost, fstabCmd)
+	_ = runOn(host, fstabCmd)
 
 	// Create directories for different storage types
 	fmt.Println("Creating storage directories...")
 	dirs := []string{
-		"/mnt/k0s",           // k0s data
+		k3sDataDir,           // k3s data
 		"/mnt/containerd",    // Container images and layers
 		"/mnt/vm-disks",      // VM disk images
 		"/mnt/llm-models",    // LLM models
@@ -144,26 +242,27 @@ func setupStorageDevice() error {
 	}
 
 	for _, dir := range dirs {
-		_ = execCommand("ssh", sshHost, fmt.Sprintf("sudo mkdir -p %s && sudo chmod 755 %s", dir, dir))
+		_ = runOn(host, fmt.Sprintf("sudo mkdir -p %s && sudo chmod 755 %s", dir, dir))
 	}
 
 	fmt.Println("✓ Storage device setup completed")
 	return nil
 }
 
-func installVirtualizationPackages() error {
+// installVirtualizationPackages installs QEMU, KVM and libvirt on host for KubeVirt
+func installVirtualizationPackages(host string) error {
 	fmt.Println("Installing virtualization packages...")
 
 	// Check if packages are already installed
 	checkCmd := "dpkg -l | grep -E 'qemu-kvm|libvirt-daemon-system' >/dev/null 2>&1"
-	if execCommand("ssh", sshHost, checkCmd) == nil {
+	if runOn(host, checkCmd) == nil {
 		fmt.Println("✓ Virtualization packages already installed")
 		return nil
 	}
 
 	// Update package cache
 	fmt.Println("Updating package cache...")
-	if err := execCommand("ssh", sshHost, "sudo apt-get update -qq"); err != nil {
+	if err := runOn(host, "sudo apt-get update -qq"); err != nil {
 		fmt.Println("⚠ Warning: apt-get update failed, continuing anyway...")
 	}
 
@@ -177,26 +276,26 @@ func installVirtualizationPackages() error {
 		cpu-checker \
 		>/dev/null 2>&1`
 
-	if err := execCommand("ssh", sshHost, installCmd); err != nil {
+	if err := runOn(host, installCmd); err != nil {
 		return fmt.Errorf("failed to install virtualization packages: %w", err)
 	}
 
 	// Verify KVM is available
 	checkKVMCmd := "test -c /dev/kvm && echo 'KVM available' || echo 'KVM not available'"
-	if err := execCommand("ssh", sshHost, checkKVMCmd); err != nil {
+	if err := runOn(host, checkKVMCmd); err != nil {
 		fmt.Println("⚠ Warning: /dev/kvm not available - VMs may not work")
 	}
 
 	// Set permissions on /dev/kvm (make it world-accessible)
 	fmt.Println("Setting permissions on /dev/kvm...")
-	if err := execCommand("ssh", sshHost, "sudo chmod 666 /dev/kvm"); err != nil {
+	if err := runOn(host, "sudo chmod 666 /dev/kvm"); err != nil {
 		fmt.Println("⚠ Warning: failed to set /dev/kvm permissions")
 	}
 
 	// Make /dev/kvm permissions persistent across reboots
 	udevRule := `KERNEL=="kvm", GROUP="kvm", MODE="0666"`
 	udevCmd := fmt.Sprintf(`echo '%s' | sudo tee /etc/udev/rules.d/99-kvm.rules >/dev/null`, udevRule)
-	if err := execCommand("ssh", sshHost, udevCmd); err != nil {
+	if err := runOn(host, udevCmd); err != nil {
 		fmt.Println("⚠ Warning: failed to create udev rule for /dev/kvm")
 	}
 
@@ -208,26 +307,26 @@ func deployK0s() error {
 	fmt.Println("==> Deploying k3s")
 
 	// Check SSH connection
-	if err := execCommand("ssh", "-o", "ConnectTimeout=10", "-o", "BatchMode=yes", sshHost, "exit"); err != nil {
-		return fmt.Errorf("cannot connect to %s - ensure SSH keys are configured", sshHost)
+	if _, err := hosts.Client(sshHost); err != nil {
+		return fmt.Errorf("cannot connect to %s - ensure SSH keys are configured: %w", sshHost, err)
 	}
 
 	// Install virtualization packages
-	if err := installVirtualizationPackages(); err != nil {
+	if err := installVirtualizationPackages(sshHost); err != nil {
 		return fmt.Errorf("failed to install virtualization packages: %w", err)
 	}
 
 	// Check if k3s is already running
 	checkCmd := "systemctl is-active k3s"
-	isRunning := execCommand("ssh", sshHost, checkCmd) == nil
+	isRunning := runOn(sshHost, checkCmd) == nil
 
 	if !isRunning {
 		fmt.Println("Installing k3s...")
 
 		// Install k3s with custom data directory and KubeVirt-friendly settings
-		k3sExec := "--data-dir=/mnt/k3s --disable traefik --disable servicelb --kube-proxy-arg=conntrack-max-per-core=0"
+		k3sExec := "--data-dir=" + k3sDataDir + " --disable traefik --disable servicelb --kube-proxy-arg=conntrack-max-per-core=0"
 		installCmd := fmt.Sprintf(`curl -sfL https://get.k3s.io | INSTALL_K3S_EXEC="%s" sh -`, k3sExec)
-		if err := execCommand("ssh", sshHost, installCmd); err != nil {
+		if err := runOn(sshHost, installCmd); err != nil {
 			return fmt.Errorf("failed to install k3s: %w", err)
 		}
 
@@ -239,19 +338,14 @@ func deployK0s() error {
 	}
 
 	// Save kubeconfig locally
-	kubeconfigData, err := exec.Command("ssh", sshHost, "sudo cat /etc/rancher/k3s/k3s.yaml").Output()
+	kubeconfigData, err := outputOf(sshHost, "sudo cat /etc/rancher/k3s/k3s.yaml")
 	if err != nil {
 		return fmt.Errorf("failed to retrieve kubeconfig: %w", err)
 	}
 
 	// Replace localhost with actual host IP
 	kubeconfigStr := string(kubeconfigData)
-	// Extract IP from SSH host (format: user@ip)
-	hostIP := sshHost
-	if idx := strings.Index(sshHost, "@"); idx != -1 {
-		hostIP = sshHost[idx+1:]
-	}
-	kubeconfigStr = strings.ReplaceAll(kubeconfigStr, "127.0.0.1", hostIP)
+	kubeconfigStr = strings.ReplaceAll(kubeconfigStr, "127.0.0.1", hostAddress(sshHost))
 
 	if err := os.WriteFile(kubeconfig, []byte(kubeconfigStr), 0600); err != nil {
 		return fmt.Errorf("failed to write kubeconfig: %w", err)
@@ -260,6 +354,9 @@ func deployK0s() error {
 	if err := os.Setenv("KUBECONFIG", kubeconfig); err != nil {
 		return fmt.Errorf("failed to set KUBECONFIG: %w", err)
 	}
+	if kube, err = kubeops.NewFromKubeconfig(kubeconfig); err != nil {
+		return err
+	}
 
 	// Wait for cluster to be ready
 	if err := waitForCluster(); err != nil {
@@ -278,19 +375,9 @@ func deployK0s() error {
 func waitForCluster() error {
 	fmt.Println("Waiting for k3s cluster to be ready...")
 
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		return fmt.Errorf("failed to build config: %w", err)
-	}
-
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return fmt.Errorf("failed to create clientset: %w", err)
-	}
-
 	ctx := context.Background()
 	for i := 0; i < 60; i++ {
-		nodes, err := clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		nodes, err := kube.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 		if err == nil && len(nodes.Items) > 0 {
 			fmt.Printf("✓ Found %d node(s)\n", len(nodes.Items))
 			break
@@ -302,45 +389,66 @@ func waitForCluster() error {
 	}
 
 	// Remove control-plane taint
-	taintKey := "node-role.kubernetes.io/control-plane:NoSchedule-"
-	taintCmd := fmt.Sprintf("kubectl --kubeconfig %s taint nodes --all %s", kubeconfig, taintKey)
-	_ = execCommand("sh", "-c", taintCmd)
+	nodes, err := kube.Clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		taints := withoutTaint(node.Spec.Taints, "node-role.kubernetes.io/control-plane", corev1.TaintEffectNoSchedule)
+		if len(taints) == len(node.Spec.Taints) {
+			continue
+		}
+		node.Spec.Taints = taints
+		_, _ = kube.Clientset.CoreV1().Nodes().Update(ctx, node, metav1.UpdateOptions{})
+	}
 
 	return nil
 }
 
+// withoutTaint returns taints without the one with key and effect
+func withoutTaint(taints []corev1.Taint, key string, effect corev1.TaintEffect) []corev1.Taint {
+	var kept []corev1.Taint
+	for _, taint := range taints {
+		if taint.Key != key || taint.Effect != effect {
+			kept = append(kept, taint)
+		}
+	}
+	return kept
+}
+
 func installDependencies() error {
 	fmt.Println("Installing dependencies...")
+	ctx := context.Background()
 
 	// Install KubeVirt v1.6.0 (latest version as of 2025)
-	_ = execCommand("kubectl", "--kubeconfig", kubeconfig, "create", "namespace", "kubevirt")
 	kubevirtOperatorURL := "https://github.com/kubevirt/kubevirt/releases/download/v1.6.0/kubevirt-operator.yaml"
-	if err := execCommand("kubectl", "--kubeconfig", kubeconfig, "apply", "-f", kubevirtOperatorURL); err != nil {
+	if err := kube.Apply(ctx, kubevirtOperatorURL); err != nil {
 		return err
 	}
 	kubevirtCRURL := "https://github.com/kubevirt/kubevirt/releases/download/v1.6.0/kubevirt-cr.yaml"
-	if err := execCommand("kubectl", "--kubeconfig", kubeconfig, "apply", "-f", kubevirtCRURL); err != nil {
+	if err := kube.Apply(ctx, kubevirtCRURL); err != nil {
 		return err
 	}
 
 	// Configure KVM device permissions and enable hardware virtualization
-	_ = execCommand("ssh", sshHost, "sudo chmod 666 /dev/kvm")
-	_ = execCommand("ssh", sshHost, "sudo usermod -a -G kvm $(whoami)")
+	_ = runOn(sshHost, "sudo chmod 666 /dev/kvm")
+	_ = runOn(sshHost, "sudo usermod -a -G kvm $(whoami)")
 
 	// Wait for KubeVirt to be ready then patch for KVM support
 	time.Sleep(5 * time.Second)
 	kubevirtPatch := `{"spec":{"configuration":{"developerConfiguration":{"featureGates":["HardwareVirtualization"]}}}}`
-	kubevirtPatchArgs := []string{
-		"--kubeconfig", kubeconfig, "-n", "kubevirt", "patch", "kubevirt", "kubevirt",
-		"--type=merge", "-p", kubevirtPatch,
-	}
-	_ = execCommand("kubectl", kubevirtPatchArgs...)
+	_ = kube.Patch(ctx, "kubevirts.kubevirt.io", "kubevirt", "kubevirt", types.MergePatchType, []byte(kubevirtPatch))
 
 	// Install CDI v1.61.0 (latest version as of 2025)
 	cdiOperatorURL := "https://github.com/kubevirt/containerized-data-importer/releases/download/v1.61.0/cdi-operator.yaml"
-	_ = execCommand("kubectl", "--kubeconfig", kubeconfig, "create", "-f", cdiOperatorURL)
+	if err := kube.Apply(ctx, cdiOperatorURL); err != nil {
+		fmt.Printf("⚠ Failed to install the CDI operator: %v\n", err)
+	}
 	cdiCRURL := "https://github.com/kubevirt/containerized-data-importer/releases/download/v1.61.0/cdi-cr.yaml"
-	_ = execCommand("kubectl", "--kubeconfig", kubeconfig, "create", "-f", cdiCRURL)
+	if err := kube.Apply(ctx, cdiCRURL); err != nil {
+		fmt.Printf("⚠ Failed to create the CDI resource: %v\n", err)
+	}
 
 	// Wait for CDI to be ready and create CDIConfig
 	time.Sleep(10 * time.Second)
@@ -353,16 +461,13 @@ spec:
   featureGates:
   - HonorWaitForFirstConsumer
   uploadProxyURLOverride: ""`
-	cdiConfigFile := "/tmp/cdiconfig.yaml"
-	if err := os.WriteFile(cdiConfigFile, []byte(cdiConfigYAML), 0600); err == nil {
-		_ = execCommand("kubectl", "--kubeconfig", kubeconfig, "apply", "-f", cdiConfigFile)
-		_ = os.Remove(cdiConfigFile) // Best effort cleanup
+	if err := kube.ApplyYAML(ctx, []byte(cdiConfigYAML)); err != nil {
+		fmt.Printf("⚠ Failed to configure CDI: %v\n", err)
 	}
 
 	// Install local-path provisioner
 	localPathURL := "https://raw.githubusercontent.com/rancher/local-path-provisioner/v0.0.28/deploy/local-path-storage.yaml"
-	localPathArgs := []string{"--kubeconfig", kubeconfig, "apply", "-f", localPathURL}
-	if err := execCommand("kubectl", localPathArgs...); err != nil {
+	if err := kube.Apply(ctx, localPathURL); err != nil {
 		return err
 	}
 
@@ -370,16 +475,14 @@ spec:
 	fmt.Println("Configuring local-path provisioner to use /mnt/vm-disks...")
 	time.Sleep(5 * time.Second) // Wait for provisioner to be created
 	patchData := `{"nodePathMap":[{"node":"DEFAULT_PATH_FOR_NON_LISTED_NODES","paths":["/mnt/vm-disks"]}]}`
-	patchCmd := fmt.Sprintf(`{"data":{"config.json":"%s"}}`, patchData)
-	patchArgs := []string{
-		"--kubeconfig", kubeconfig, "-n", "local-path-storage", "patch", "configmap",
-		"local-path-config", "-p", patchCmd,
-	}
-	_ = execCommand("kubectl", patchArgs...)
+	patchCmd := fmt.Sprintf(`{"data":{"config.json":%q}}`, patchData)
+	_ = kube.Patch(ctx, "configmaps", "local-path-storage", "local-path-config", types.MergePatchType, []byte(patchCmd))
 
-	// Restart local-path-provisioner to apply changes
-	restartArgs := []string{"--kubeconfig", kubeconfig, "-n", "local-path-storage", "rollout", "restart", "deployment/local-path-provisioner"}
-	_ = execCommand("kubectl", restartArgs...)
+	// Restart local-path-provisioner to apply changes, as kubectl rollout restart does
+	restartPatch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`,
+		time.Now().Format(time.RFC3339))
+	_, _ = kube.Clientset.AppsV1().Deployments("local-path-storage").Patch(ctx, "local-path-provisioner",
+		types.StrategicMergePatchType, []byte(restartPatch), metav1.PatchOptions{})
 
 	fmt.Println("✓ Dependencies installed")
 	return nil
@@ -427,66 +530,72 @@ func deployOperator() error {
 		return fmt.Errorf("failed to build operator: %w", err)
 	}
 
-	// Stop existing operator and kill any remaining processes
 	fmt.Println("Stopping existing operator...")
-	_ = execCommand("ssh", sshHost, "sudo systemctl stop llmcloud-operator 2>/dev/null || true")
-	_ = execCommand("ssh", sshHost, "sudo pkill -9 -f '/opt/llmcloud-operator/manager' || true")
-	_ = execCommand("ssh", sshHost, "sudo pkill -9 -f 'llmcloud' || true")
-	// Kill any process using port 8090 or 8081
-	_ = execCommand("ssh", sshHost, "sudo fuser -k 8090/tcp 2>/dev/null || true")
-	_ = execCommand("ssh", sshHost, "sudo fuser -k 8081/tcp 2>/dev/null || true")
-	time.Sleep(3 * time.Second)
+	if killed := stopOperator(sshCommand, stopTimeout); killed && leaderElect {
+		releaseLeaderLease()
+	}
 
 	// Copy binary
-	_ = execCommand("ssh", sshHost, "sudo mkdir -p /opt/llmcloud-operator")
-	if err := execCommand("scp", "bin/manager-linux", sshHost+":/tmp/manager"); err != nil {
+	_ = runOn(sshHost, "sudo mkdir -p /opt/llmcloud-operator")
+	if err := upload(sshHost, "bin/manager-linux", "/tmp/manager"); err != nil {
 		return err
 	}
 	mvCmd := "sudo mv /tmp/manager /opt/llmcloud-operator/manager && sudo chmod +x /opt/llmcloud-operator/manager"
-	if err := execCommand("ssh", sshHost, mvCmd); err != nil {
+	if err := runOn(sshHost, mvCmd); err != nil {
 		return err
 	}
 
 	// Create kubeconfig on remote host
 	kubeconfigCmd := "sudo k0s kubeconfig admin | sudo tee /opt/llmcloud-operator/kubeconfig > /dev/null"
-	if err := execCommand("ssh", sshHost, kubeconfigCmd); err != nil {
+	if err := runOn(sshHost, kubeconfigCmd); err != nil {
 		return fmt.Errorf("failed to create kubeconfig on remote host: %w", err)
 	}
 
 	// Create systemd service
-	serviceContent := `[Unit]
+	execStart := managerPath + " --local-path-disk-dir=/mnt/vm-disks"
+	if leaderElect {
+		execStart += " --leader-elect --leader-election-namespace=" + leaderElectionNamespace
+	}
+	if monitoring {
+		execStart += fmt.Sprintf(" --metrics-bind-address=:%d", metricsPort)
+	}
+	serviceContent := fmt.Sprintf(`[Unit]
 Description=LLMCloud Operator
 After=network.target
 
 [Service]
 Type=simple
-ExecStart=/opt/llmcloud-operator/manager
+ExecStart=%s
 Restart=always
 RestartSec=5
+TimeoutStopSec=%d
 Environment="KUBECONFIG=/opt/llmcloud-operator/kubeconfig"
 
 [Install]
-WantedBy=multi-user.target`
+WantedBy=multi-user.target`, execStart, int(stopTimeout.Seconds()))
 
 	serviceCmd := fmt.Sprintf("echo '%s' | sudo tee /etc/systemd/system/llmcloud-operator.service > /dev/null",
 		serviceContent)
-	if err := execCommand("ssh", sshHost, serviceCmd); err != nil {
+	if err := runOn(sshHost, serviceCmd); err != nil {
 		return err
 	}
 
 	// Start service
 	startCmd := "sudo systemctl daemon-reload && sudo systemctl enable llmcloud-operator && sudo systemctl start llmcloud-operator"
-	if err := execCommand("ssh", sshHost, startCmd); err != nil {
+	if err := runOn(sshHost, startCmd); err != nil {
 		return err
 	}
 
-	// Wait for operator to start
 	fmt.Println("Waiting for operator to start...")
-	time.Sleep(10 * time.Second)
+	if !waitFor(func() bool {
+		return sshCommand("curl -fs http://localhost:8081/readyz >/dev/null 2>&1") == nil
+	}, startTimeout) {
+		fmt.Printf("⚠ Operator did not report ready within %s, check: sudo journalctl -u llmcloud-operator\n", startTimeout)
+	}
 
 	// Install CRDs
 	fmt.Println("Installing CRDs...")
-	if err := execCommand("kubectl", "--kubeconfig", kubeconfig, "apply", "-f", "config/crd/bases"); err != nil {
+	if err := kube.Apply(context.Background(), "config/crd/bases"); err != nil {
 		fmt.Println("⚠ Failed to install CRDs, they may already exist")
 	}
 
@@ -494,6 +603,90 @@ WantedBy=multi-user.target`
 	return nil
 }
 
+// remoteCommand runs a shell command on the deploy host
+type remoteCommand func(command string) error
+
+func sshCommand(command string) error {
+	return runOn(sshHost, command)
+}
+
+// runOn runs a shell command on host, streaming its output
+func runOn(host, command string) error {
+	client, err := hosts.Client(host)
+	if err != nil {
+		return err
+	}
+	return client.Run(command, os.Stdout, os.Stderr)
+}
+
+// outputOf runs a shell command on host and returns its output
+func outputOf(host, command string) ([]byte, error) {
+	client, err := hosts.Client(host)
+	if err != nil {
+		return nil, err
+	}
+	return client.Output(command)
+}
+
+// upload copies the local file to path on host
+func upload(host, file, path string) error {
+	client, err := hosts.Client(host)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return client.Upload(path, f, 0755)
+}
+
+// stopOperator asks the running operator to shut down with SIGTERM, so that it can
+// finish in-flight work and release its leader election lease, and kills it only if it
+// is still running after timeout. It reports whether the operator had to be killed
+func stopOperator(run remoteCommand, timeout time.Duration) bool {
+	_ = run("sudo systemctl stop --no-block llmcloud-operator 2>/dev/null || true")
+	_ = run("sudo pkill -TERM -f '" + managerPattern + "' || true")
+
+	stopped := waitFor(func() bool {
+		return run("! pgrep -f '"+managerPattern+"' >/dev/null") == nil
+	}, timeout)
+	if stopped {
+		return false
+	}
+
+	fmt.Printf("⚠ Operator did not stop within %s, killing it\n", timeout)
+	_ = run("sudo pkill -9 -f '" + managerPattern + "' || true")
+	// Free the API and health probe ports in case a stray process still holds them
+	_ = run("sudo fuser -k 8090/tcp 2>/dev/null || true")
+	_ = run("sudo fuser -k 8081/tcp 2>/dev/null || true")
+	return true
+}
+
+// waitFor polls check every pollInterval until it returns true or timeout has passed
+func waitFor(check func() bool, timeout time.Duration) bool {
+	for waited := time.Duration(0); ; waited += pollInterval {
+		if check() {
+			return true
+		}
+		if waited >= timeout {
+			return false
+		}
+		sleep(pollInterval)
+	}
+}
+
+// releaseLeaderLease deletes the leader election lease of a killed operator, which
+// could not release it itself, so the restarted one does not wait for it to expire
+func releaseLeaderLease() {
+	err := kube.Clientset.CoordinationV1().Leases(leaderElectionNamespace).
+		Delete(context.Background(), leaderElectionID, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		fmt.Printf("⚠ Failed to release leader election lease: %v\n", err)
+	}
+}
+
 func createRootUser() error {
 	fmt.Println("==> Creating root user")
 
@@ -530,12 +723,7 @@ spec:
   email: root@localhost
   isAdmin: true`, passwordHash)
 
-	userFile := "/tmp/root-user.yaml"
-	if err := os.WriteFile(userFile, []byte(userYAML), 0600); err != nil {
-		return err
-	}
-
-	if err := execCommand("kubectl", "--kubeconfig", kubeconfig, "apply", "-f", userFile); err != nil {
+	if err := kube.ApplyYAML(context.Background(), []byte(userYAML)); err != nil {
 		fmt.Println("⚠ Root user may already exist")
 	}
 
@@ -569,13 +757,6 @@ func generatePassword() (string, error) {
 	return password, nil
 }
 
-func execCommand(name string, args ...string) error {
-	cmd := exec.Command(name, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 func execCommandInDir(dir, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	cmd.Dir = dir
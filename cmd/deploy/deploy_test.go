@@ -0,0 +1,121 @@
+package deploy
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHost simulates the deploy host: the operator process exits after stopsAfter
+// pgrep checks, or never when stopsAfter is negative
+type fakeHost struct {
+	stopsAfter int
+	checks     int
+	commands   []string
+}
+
+func (h *fakeHost) run(command string) error {
+	h.commands = append(h.commands, command)
+	if strings.Contains(command, "pgrep") {
+		h.checks++
+		if h.stopsAfter < 0 || h.checks <= h.stopsAfter {
+			return errors.New("still running")
+		}
+	}
+	return nil
+}
+
+func (h *fakeHost) ran(substr string) bool {
+	for _, command := range h.commands {
+		if strings.Contains(command, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func stubSleep(t *testing.T) *time.Duration {
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept += d }
+	t.Cleanup(func() { sleep = time.Sleep })
+	return &slept
+}
+
+func TestStopOperatorGraceful(t *testing.T) {
+	slept := stubSleep(t)
+	host := &fakeHost{stopsAfter: 3}
+
+	if killed := stopOperator(host.run, 10*time.Second); killed {
+		t.Error("Expected the operator to stop without being killed")
+	}
+	if !host.ran("pkill -TERM") {
+		t.Errorf("Expected a SIGTERM, ran %v", host.commands)
+	}
+	if host.ran("pkill -9") || host.ran("fuser -k") {
+		t.Errorf("Expected no force kill, ran %v", host.commands)
+	}
+	if *slept != 3*pollInterval {
+		t.Errorf("Expected to wait %s, waited %s", 3*pollInterval, *slept)
+	}
+}
+
+func TestStopOperatorForceKillsAfterTimeout(t *testing.T) {
+	slept := stubSleep(t)
+	host := &fakeHost{stopsAfter: -1}
+
+	if killed := stopOperator(host.run, 5*time.Second); !killed {
+		t.Error("Expected the operator to be killed")
+	}
+	if !host.ran("pkill -9") {
+		t.Errorf("Expected a force kill, ran %v", host.commands)
+	}
+	if *slept != 5*time.Second {
+		t.Errorf("Expected to wait the full timeout, waited %s", *slept)
+	}
+
+	// The graceful stop must come before the force kill
+	var term, kill int
+	for i, command := range host.commands {
+		if strings.Contains(command, "pkill -TERM") {
+			term = i
+		}
+		if strings.Contains(command, "pkill -9") {
+			kill = i
+		}
+	}
+	if term > kill {
+		t.Errorf("Expected SIGTERM before SIGKILL, ran %v", host.commands)
+	}
+}
+
+func TestStopOperatorDoesNotMatchItsOwnCommand(t *testing.T) {
+	stubSleep(t)
+	host := &fakeHost{stopsAfter: -1}
+	stopOperator(host.run, time.Second)
+
+	pattern := regexp.MustCompile(managerPattern)
+	if !pattern.MatchString(managerPath) {
+		t.Fatalf("Expected %q to match the manager %q", managerPattern, managerPath)
+	}
+	for _, command := range host.commands {
+		if strings.Contains(command, "pgrep") || strings.Contains(command, "pkill") {
+			if pattern.MatchString(command) {
+				t.Errorf("Expected %q not to match itself", command)
+			}
+		}
+	}
+}
+
+func TestStopOperatorNotRunning(t *testing.T) {
+	slept := stubSleep(t)
+	host := &fakeHost{}
+
+	if killed := stopOperator(host.run, 0); killed {
+		t.Error("Expected a stopped operator not to be killed")
+	}
+	if *slept != 0 {
+		t.Errorf("Expected no wait, waited %s", *slept)
+	}
+}
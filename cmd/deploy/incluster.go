@@ -0,0 +1,195 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"io/fs"
+	"text/template"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/rusik69/llmcloud-operator/config"
+	"github.com/rusik69/llmcloud-operator/internal/kubeops"
+)
+
+const (
+	// deployModeHost installs k3s on the hosts and runs the operator there under systemd
+	deployModeHost = "host"
+	// deployModeInCluster runs the operator as a Deployment in an existing cluster
+	deployModeInCluster = "in-cluster"
+
+	// operatorName names the operator's Deployment, ServiceAccount and roles
+	operatorName = "llmcloud-operator"
+)
+
+var (
+	deployMode       string
+	image            string
+	namespace        string
+	replicas         int
+	ingressHost      string
+	ingressClass     string
+	ingressTLSSecret string
+)
+
+//go:embed manifests/operator.yaml
+var operatorManifest string
+
+var operatorTemplate = template.Must(template.New("operator").Parse(operatorManifest))
+
+// operatorValues fill in the operator manifest
+type operatorValues struct {
+	Namespace          string
+	Image              string
+	Replicas           int
+	StopTimeoutSeconds int
+	// DeployedAt changes the pod template on every deploy, restarting the pods
+	DeployedAt string
+	// IngressHost exposes the API through an Ingress when set
+	IngressHost      string
+	IngressClass     string
+	IngressTLSSecret string
+	// MetricsPort serves the operator's metrics when set
+	MetricsPort int
+}
+
+// inClusterObjects returns what the in-cluster mode applies, in order: the CRDs, the
+// manager's roles, named after the operator, and the operator's manifest
+func inClusterObjects(values operatorValues) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	crds, err := fs.Glob(config.Manifests, "crd/bases/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]string{
+		"rbac/role.yaml":                 operatorName + "-manager",
+		"rbac/leader_election_role.yaml": operatorName + "-leader-election",
+	}
+	for _, file := range append(crds, "rbac/role.yaml", "rbac/leader_election_role.yaml") {
+		data, err := fs.ReadFile(config.Manifests, file)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := kubeops.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for _, obj := range decoded {
+			if name, ok := roles[file]; ok {
+				obj.SetName(name)
+				obj.SetLabels(map[string]string{"app.kubernetes.io/name": operatorName})
+				if obj.GetKind() == "Role" {
+					obj.SetNamespace(values.Namespace)
+				}
+			}
+		}
+		objects = append(objects, decoded...)
+	}
+
+	var manifest bytes.Buffer
+	if err := operatorTemplate.Execute(&manifest, values); err != nil {
+		return nil, fmt.Errorf("failed to render the operator manifest: %w", err)
+	}
+	decoded, err := kubeops.Decode(manifest.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return append(objects, decoded...), nil
+}
+
+// runInClusterDeploy deploys the operator into the cluster of --kubeconfig, which
+// must already exist, and creates the root user
+func runInClusterDeploy() error {
+	fmt.Printf("==> Deploying %s to namespace %s\n", image, namespace)
+
+	var err error
+	if kube, err = kubeops.NewFromKubeconfig(kubeconfig); err != nil {
+		return err
+	}
+
+	values := operatorValues{
+		Namespace:          namespace,
+		Image:              image,
+		Replicas:           replicas,
+		StopTimeoutSeconds: int(stopTimeout.Seconds()),
+		DeployedAt:         time.Now().UTC().Format(time.RFC3339),
+		IngressHost:        ingressHost,
+		IngressClass:       ingressClass,
+		IngressTLSSecret:   ingressTLSSecret,
+	}
+	if monitoring {
+		values.MetricsPort = metricsPort
+	}
+	objects, err := inClusterObjects(values)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := kube.ApplyObjects(ctx, objects); err != nil {
+		return fmt.Errorf("failed to deploy operator: %w", err)
+	}
+
+	fmt.Println("Waiting for operator to start...")
+	if !waitFor(operatorAvailable, startTimeout) {
+		fmt.Printf("⚠ Operator did not report ready within %s, check: kubectl -n %s logs deployment/%s\n",
+			startTimeout, namespace, operatorName)
+	} else {
+		fmt.Println("✓ Operator deployed")
+	}
+
+	if err := createRootUser(); err != nil {
+		return fmt.Errorf("failed to create root user: %w", err)
+	}
+
+	if monitoring {
+		values, err := newMonitoringValues("", namespace)
+		if err != nil {
+			return fmt.Errorf("failed to install monitoring: %w", err)
+		}
+		if err := installMonitoring(values); err != nil {
+			return fmt.Errorf("failed to install monitoring: %w", err)
+		}
+	}
+
+	if ingressHost != "" {
+		fmt.Printf("\n✓ Deployment completed successfully! The API is served at %s\n", ingressHost)
+	} else {
+		fmt.Printf("\n✓ Deployment completed successfully! Reach the API with: kubectl -n %s port-forward service/llmcloud-api 8090\n",
+			namespace)
+	}
+	return nil
+}
+
+// operatorAvailable reports whether the operator's Deployment has rolled out: every
+// replica runs the current pod template and is ready
+func operatorAvailable() bool {
+	deployment, err := kube.Clientset.AppsV1().Deployments(namespace).Get(context.Background(), operatorName, metav1.GetOptions{})
+	if err != nil {
+		return false
+	}
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == *deployment.Spec.Replicas &&
+		status.AvailableReplicas == *deployment.Spec.Replicas &&
+		status.Replicas == status.UpdatedReplicas
+}
@@ -0,0 +1,92 @@
+package deploy
+
+import (
+	"io/fs"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/rusik69/llmcloud-operator/config"
+)
+
+func objectsByKind(objects []*unstructured.Unstructured) map[string][]*unstructured.Unstructured {
+	byKind := map[string][]*unstructured.Unstructured{}
+	for _, obj := range objects {
+		byKind[obj.GetKind()] = append(byKind[obj.GetKind()], obj)
+	}
+	return byKind
+}
+
+func TestInClusterObjects(t *testing.T) {
+	objects, err := inClusterObjects(operatorValues{
+		Namespace: "llmcloud", Image: "registry.local/llmcloud:v1", Replicas: 2, StopTimeoutSeconds: 30,
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	byKind := objectsByKind(objects)
+
+	crds, _ := fs.Glob(config.Manifests, "crd/bases/*.yaml")
+	if len(crds) == 0 || len(byKind["CustomResourceDefinition"]) != len(crds) {
+		t.Errorf("Expected the %d CRDs, got %d", len(crds), len(byKind["CustomResourceDefinition"]))
+	}
+	if objects[0].GetKind() != "CustomResourceDefinition" {
+		t.Errorf("Expected the CRDs to be applied first, got %s", objects[0].GetKind())
+	}
+	if role := byKind["ClusterRole"]; len(role) != 1 || role[0].GetName() != "llmcloud-operator-manager" {
+		t.Errorf("Expected the manager role named after the operator, got %v", role)
+	}
+	if role := byKind["Role"]; len(role) != 1 || role[0].GetNamespace() != "llmcloud" {
+		t.Errorf("Expected the leader election role in the operator's namespace, got %v", role)
+	}
+	if len(byKind["Ingress"]) != 0 {
+		t.Error("Expected no Ingress without a host")
+	}
+
+	deployment := byKind["Deployment"][0]
+	if deployment.GetNamespace() != "llmcloud" {
+		t.Errorf("Expected the Deployment in the operator's namespace, got %s", deployment.GetNamespace())
+	}
+	if replicas, _, _ := unstructured.NestedInt64(deployment.Object, "spec", "replicas"); replicas != 2 {
+		t.Errorf("Expected 2 replicas, got %d", replicas)
+	}
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	manager := containers[0].(map[string]interface{})
+	if manager["image"] != "registry.local/llmcloud:v1" {
+		t.Errorf("Expected the image, got %v", manager["image"])
+	}
+	args, _, _ := unstructured.NestedStringSlice(manager, "args")
+	want := map[string]bool{"--leader-elect": true, "--leader-election-namespace=llmcloud": true, "--auth-namespace=llmcloud": true}
+	for _, arg := range args {
+		delete(want, arg)
+	}
+	if len(want) != 0 {
+		t.Errorf("Expected the args %v, got %v", want, args)
+	}
+}
+
+func TestInClusterIngress(t *testing.T) {
+	objects, err := inClusterObjects(operatorValues{
+		Namespace: "llmcloud", Image: "llmcloud", Replicas: 1,
+		IngressHost: "llmcloud.example.com", IngressClass: "nginx", IngressTLSSecret: "llmcloud-tls",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	ingresses := objectsByKind(objects)["Ingress"]
+	if len(ingresses) != 1 {
+		t.Fatalf("Expected an Ingress, got %d", len(ingresses))
+	}
+	ingress := ingresses[0].Object
+	if class, _, _ := unstructured.NestedString(ingress, "spec", "ingressClassName"); class != "nginx" {
+		t.Errorf("Expected the ingress class, got %q", class)
+	}
+	rules, _, _ := unstructured.NestedSlice(ingress, "spec", "rules")
+	if len(rules) != 1 || rules[0].(map[string]interface{})["host"] != "llmcloud.example.com" {
+		t.Errorf("Expected a rule for the host, got %v", rules)
+	}
+	tls, _, _ := unstructured.NestedSlice(ingress, "spec", "tls")
+	if len(tls) != 1 || tls[0].(map[string]interface{})["secretName"] != "llmcloud-tls" {
+		t.Errorf("Expected TLS with the secret, got %v", tls)
+	}
+}
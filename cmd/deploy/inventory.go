@@ -0,0 +1,217 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deploy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// k3sDataDir is where k3s keeps its data on every node, on the storage device
+	k3sDataDir = "/mnt/k3s"
+
+	// workerRoleLabel marks the nodes joined as workers
+	workerRoleLabel = "node-role.kubernetes.io/worker"
+
+	// nodeJoinTimeout is how long a worker may take to register with the control plane
+	nodeJoinTimeout = 3 * time.Minute
+)
+
+// node is a host of the cluster, reached over SSH
+type node struct {
+	// Host is the SSH destination (user@hostname)
+	Host string `json:"host"`
+	// StorageDevice is the block device mounted at /mnt for the node's data.
+	// Defaults to --storage-device
+	StorageDevice string `json:"storageDevice,omitempty"`
+	// Labels are set on the node once it is part of the cluster
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// inventory lists the hosts of the cluster. The control plane also runs the operator;
+// the workers join it as k3s agents
+type inventory struct {
+	ControlPlane node   `json:"controlPlane"`
+	Workers      []node `json:"workers,omitempty"`
+}
+
+// nodes returns the control plane followed by the workers
+func (inv *inventory) nodes() []node {
+	return append([]node{inv.ControlPlane}, inv.Workers...)
+}
+
+// loadInventory reads the --inventory file, if any, and completes it from the flags:
+// --ssh-host is the control plane unless the file names one, --workers are added to
+// its workers, and nodes without a storage device use --storage-device
+func loadInventory() (*inventory, error) {
+	inv := &inventory{}
+	if inventoryFile != "" {
+		data, err := os.ReadFile(inventoryFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read inventory: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, inv); err != nil {
+			return nil, fmt.Errorf("invalid inventory %s: %w", inventoryFile, err)
+		}
+	}
+
+	inv.ControlPlane.Host = cmp.Or(inv.ControlPlane.Host, sshHost)
+	if inv.ControlPlane.Host == "" {
+		return nil, fmt.Errorf("--ssh-host, SSH_HOST or the inventory's controlPlane must be set")
+	}
+	for _, host := range workers {
+		if host = strings.TrimSpace(host); host != "" {
+			inv.Workers = append(inv.Workers, node{Host: host})
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, n := range inv.nodes() {
+		if n.Host == "" {
+			return nil, fmt.Errorf("inventory worker %d has no host", i)
+		}
+		if seen[n.Host] {
+			return nil, fmt.Errorf("host %s is listed more than once", n.Host)
+		}
+		seen[n.Host] = true
+	}
+	inv.ControlPlane.StorageDevice = cmp.Or(inv.ControlPlane.StorageDevice, storageDevice)
+	for i := range inv.Workers {
+		inv.Workers[i].StorageDevice = cmp.Or(inv.Workers[i].StorageDevice, storageDevice)
+	}
+	return inv, nil
+}
+
+// hostAddress returns the hostname of an SSH destination (user@hostname)
+func hostAddress(host string) string {
+	if idx := strings.Index(host, "@"); idx != -1 {
+		return host[idx+1:]
+	}
+	return host
+}
+
+// k3sAgentInstallCommand installs k3s as an agent joining the server at serverURL
+func k3sAgentInstallCommand(serverURL, token string) string {
+	return fmt.Sprintf(`curl -sfL https://get.k3s.io | K3S_URL=%s K3S_TOKEN=%s INSTALL_K3S_EXEC="agent --data-dir=%s" sh -`,
+		serverURL, token, k3sDataDir)
+}
+
+// joinWorkers installs k3s agents on the workers and waits for each to register
+func joinWorkers(controlPlane string, workers []node) error {
+	if len(workers) == 0 {
+		return nil
+	}
+	fmt.Printf("==> Joining %d worker(s)\n", len(workers))
+
+	tokenData, err := outputOf(controlPlane, "sudo cat "+k3sDataDir+"/server/node-token")
+	if err != nil {
+		return fmt.Errorf("failed to read the cluster join token: %w", err)
+	}
+	token := strings.TrimSpace(string(tokenData))
+	serverURL := fmt.Sprintf("https://%s:6443", hostAddress(controlPlane))
+
+	for _, worker := range workers {
+		if err := joinWorker(worker, serverURL, token); err != nil {
+			return fmt.Errorf("failed to join %s: %w", worker.Host, err)
+		}
+	}
+	return nil
+}
+
+// joinWorker installs a k3s agent on worker, unless one is already running, and waits
+// for it to register
+func joinWorker(worker node, serverURL, token string) error {
+	fmt.Printf("Joining %s...\n", worker.Host)
+	if _, err := hosts.Client(worker.Host); err != nil {
+		return fmt.Errorf("cannot connect to %s - ensure SSH keys are configured: %w", worker.Host, err)
+	}
+	if err := installVirtualizationPackages(worker.Host); err != nil {
+		return fmt.Errorf("failed to install virtualization packages: %w", err)
+	}
+
+	if runOn(worker.Host, "systemctl is-active k3s-agent") == nil {
+		fmt.Printf("✓ k3s agent already running on %s\n", worker.Host)
+	} else if err := runOn(worker.Host, k3sAgentInstallCommand(serverURL, token)); err != nil {
+		return fmt.Errorf("failed to install k3s agent: %w", err)
+	}
+
+	name, err := nodeName(worker.Host)
+	if err != nil {
+		return err
+	}
+	if !waitFor(func() bool {
+		_, err := kube.Clientset.CoreV1().Nodes().Get(context.Background(), name, metav1.GetOptions{})
+		return err == nil
+	}, nodeJoinTimeout) {
+		return fmt.Errorf("node %s did not register within %s", name, nodeJoinTimeout)
+	}
+	fmt.Printf("✓ %s joined as node %s\n", worker.Host, name)
+	return nil
+}
+
+// labelNodes sets the inventory's labels on the nodes, and marks the workers' role
+func labelNodes(inv *inventory) error {
+	for i, n := range inv.nodes() {
+		labels := map[string]string{}
+		for k, v := range n.Labels {
+			labels[k] = v
+		}
+		if i > 0 {
+			labels[workerRoleLabel] = "true"
+		}
+		if len(labels) == 0 {
+			continue
+		}
+		name, err := nodeName(n.Host)
+		if err != nil {
+			return err
+		}
+		patch, err := labelPatch(labels)
+		if err != nil {
+			return err
+		}
+		_, err = kube.Clientset.CoreV1().Nodes().Patch(context.Background(), name, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to label node %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// labelPatch returns the merge patch setting labels on a node, overwriting their values
+func labelPatch(labels map[string]string) ([]byte, error) {
+	return json.Marshal(map[string]any{"metadata": map[string]any{"labels": labels}})
+}
+
+// nodeName returns the name the host registers with, its hostname
+func nodeName(host string) (string, error) {
+	out, err := outputOf(host, "hostname")
+	if err != nil {
+		return "", fmt.Errorf("failed to get the hostname of %s: %w", host, err)
+	}
+	return strings.ToLower(strings.TrimSpace(string(out))), nil
+}
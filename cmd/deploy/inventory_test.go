@@ -0,0 +1,118 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setInventoryFlags sets the deploy flags loadInventory reads for the test
+func setInventoryFlags(t *testing.T, host string, workerHosts []string, file string) {
+	oldHost, oldWorkers, oldFile, oldDevice := sshHost, workers, inventoryFile, storageDevice
+	t.Cleanup(func() { sshHost, workers, inventoryFile, storageDevice = oldHost, oldWorkers, oldFile, oldDevice })
+	sshHost, workers, inventoryFile, storageDevice = host, workerHosts, file, "/dev/sda"
+}
+
+func writeInventory(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "inventory.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write inventory: %v", err)
+	}
+	return path
+}
+
+func TestLoadInventoryFromFlags(t *testing.T) {
+	setInventoryFlags(t, "admin@10.0.0.1", []string{"admin@10.0.0.2", " admin@10.0.0.3"}, "")
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("Failed to load inventory: %v", err)
+	}
+	want := &inventory{
+		ControlPlane: node{Host: "admin@10.0.0.1", StorageDevice: "/dev/sda"},
+		Workers: []node{
+			{Host: "admin@10.0.0.2", StorageDevice: "/dev/sda"},
+			{Host: "admin@10.0.0.3", StorageDevice: "/dev/sda"},
+		},
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Errorf("Expected %+v, got %+v", want, inv)
+	}
+}
+
+func TestLoadInventoryFile(t *testing.T) {
+	path := writeInventory(t, `
+controlPlane:
+  host: admin@cp
+workers:
+  - host: admin@gpu1
+    storageDevice: /dev/nvme0n1
+    labels:
+      llmcloud.io/gpu: "true"
+`)
+	setInventoryFlags(t, "admin@ignored", []string{"admin@extra"}, path)
+
+	inv, err := loadInventory()
+	if err != nil {
+		t.Fatalf("Failed to load inventory: %v", err)
+	}
+	want := &inventory{
+		ControlPlane: node{Host: "admin@cp", StorageDevice: "/dev/sda"},
+		Workers: []node{
+			{Host: "admin@gpu1", StorageDevice: "/dev/nvme0n1", Labels: map[string]string{"llmcloud.io/gpu": "true"}},
+			{Host: "admin@extra", StorageDevice: "/dev/sda"},
+		},
+	}
+	if !reflect.DeepEqual(inv, want) {
+		t.Errorf("Expected the file's hosts plus --workers, got %+v", inv)
+	}
+}
+
+func TestLoadInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		workers []string
+		file    string
+		want    string
+	}{
+		{name: "no control plane", want: "controlPlane must be set"},
+		{name: "duplicate host", host: "admin@a", workers: []string{"admin@a"}, want: "more than once"},
+		{name: "worker without host", file: "controlPlane:\n  host: admin@a\nworkers:\n  - storageDevice: /dev/sdb\n", want: "no host"},
+		{name: "unknown field", file: "controlPlane:\n  hostname: admin@a\n", want: "invalid inventory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := ""
+			if tt.file != "" {
+				file = writeInventory(t, tt.file)
+			}
+			setInventoryFlags(t, tt.host, tt.workers, file)
+			if _, err := loadInventory(); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestK3sAgentInstallCommand(t *testing.T) {
+	command := k3sAgentInstallCommand("https://"+hostAddress("admin@10.0.0.1")+":6443", "s3cret")
+	for _, want := range []string{"K3S_URL=https://10.0.0.1:6443", "K3S_TOKEN=s3cret", "agent --data-dir=/mnt/k3s"} {
+		if !strings.Contains(command, want) {
+			t.Errorf("Expected %q in %s", want, command)
+		}
+	}
+}
+
+func TestLabelPatch(t *testing.T) {
+	got, err := labelPatch(map[string]string{"zone": "b", workerRoleLabel: "true", "llmcloud.io/gpu": "true"})
+	if err != nil {
+		t.Fatalf("Failed to build the patch: %v", err)
+	}
+	want := `{"metadata":{"labels":{"llmcloud.io/gpu":"true","node-role.kubernetes.io/worker":"true","zone":"b"}}}`
+	if string(got) != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
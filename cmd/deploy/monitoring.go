@@ -0,0 +1,229 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net"
+	"path"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/rusik69/llmcloud-operator/internal/kubeops"
+)
+
+const (
+	// prometheusOperatorURL installs the Prometheus operator and its CRDs, which run the
+	// Prometheus that the monitoring manifest describes
+	prometheusOperatorURL = "https://github.com/prometheus-operator/prometheus-operator/releases/download/v0.76.2/bundle.yaml"
+
+	grafanaImage = "grafana/grafana:11.2.0"
+	// grafanaAdminSecret holds Grafana's admin credentials, generated on the first deploy
+	grafanaAdminSecret = "grafana-admin"
+
+	// metricsPort is where the operator serves its metrics over HTTPS with monitoring
+	metricsPort = 8443
+)
+
+var (
+	monitoring          bool
+	monitoringNamespace string
+	prometheusRetention string
+)
+
+//go:embed manifests/monitoring.yaml
+var monitoringManifest string
+
+var monitoringTemplate = template.Must(template.New("monitoring").Parse(monitoringManifest))
+
+//go:embed manifests/dashboards/*.json
+var dashboards embed.FS
+
+// monitoringValues fill in the monitoring manifest
+type monitoringValues struct {
+	// Namespace runs Prometheus and Grafana
+	Namespace string
+	// OperatorNamespace holds the ServiceMonitor of the operator and its metrics Service
+	OperatorNamespace string
+	// OperatorAddress is the IP of the host running the operator outside the cluster.
+	// When empty, the operator runs in the cluster and its pods are scraped
+	OperatorAddress     string
+	OperatorAddressType string
+	MetricsPort         int
+	Retention           string
+	GrafanaImage        string
+	// DeployedAt changes the pod template on every deploy, restarting Grafana
+	DeployedAt string
+}
+
+// monitoringObjects returns what --monitoring applies once the Prometheus operator is
+// installed: Prometheus, the operator's ServiceMonitor, and Grafana with the dashboards
+func monitoringObjects(values monitoringValues) ([]*unstructured.Unstructured, error) {
+	var manifest bytes.Buffer
+	if err := monitoringTemplate.Execute(&manifest, values); err != nil {
+		return nil, fmt.Errorf("failed to render the monitoring manifest: %w", err)
+	}
+	objects, err := kubeops.Decode(manifest.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(dashboards, "manifests/dashboards/*.json")
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{}
+	for _, file := range files {
+		dashboard, err := fs.ReadFile(dashboards, file)
+		if err != nil {
+			return nil, err
+		}
+		data[path.Base(file)] = string(dashboard)
+	}
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"data":       data,
+	}}
+	configMap.SetName("grafana-dashboards")
+	configMap.SetNamespace(values.Namespace)
+	configMap.SetLabels(map[string]string{"app.kubernetes.io/part-of": "llmcloud-monitoring"})
+	return append(objects, configMap), nil
+}
+
+// operatorIP resolves the address of the host running the operator, as the endpoint
+// of its metrics Service must be an IP, and returns the EndpointSlice address type
+func operatorIP(host string) (string, string, error) {
+	address := hostAddress(host)
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		address = h
+	}
+	ip := net.ParseIP(address)
+	if ip == nil {
+		ips, err := net.LookupIP(address)
+		if err != nil || len(ips) == 0 {
+			return "", "", fmt.Errorf("failed to resolve %s: %w", address, err)
+		}
+		ip = ips[0]
+	}
+	if ip.To4() != nil {
+		return ip.String(), "IPv4", nil
+	}
+	return ip.String(), "IPv6", nil
+}
+
+// installMonitoring installs the Prometheus operator, then Prometheus scraping the
+// operator and KubeVirt, and Grafana with the LLMCloud and KubeVirt dashboards
+func installMonitoring(values monitoringValues) error {
+	fmt.Printf("==> Installing monitoring to namespace %s\n", values.Namespace)
+	ctx := context.Background()
+
+	if err := kube.Apply(ctx, prometheusOperatorURL); err != nil {
+		return fmt.Errorf("failed to install the Prometheus operator: %w", err)
+	}
+
+	objects, err := monitoringObjects(values)
+	if err != nil {
+		return err
+	}
+	// The Prometheus and ServiceMonitor kinds are served once their CRDs, just
+	// created, are established
+	if !waitFor(func() bool {
+		err = kube.ApplyObjects(ctx, objects)
+		return err == nil
+	}, startTimeout) {
+		return err
+	}
+
+	password, err := grafanaPassword(ctx, values.Namespace)
+	if err != nil {
+		return err
+	}
+
+	// KubeVirt creates the ServiceMonitor and alerting rules for its components and
+	// VMs in the namespace it is told to, for the account Prometheus runs as
+	kubevirtPatch := fmt.Sprintf(`{"spec":{"monitorNamespace":%q,"monitorAccount":"prometheus"}}`, values.Namespace)
+	if err := kube.Patch(ctx, "kubevirts.kubevirt.io", "kubevirt", "kubevirt", types.MergePatchType, []byte(kubevirtPatch)); err != nil {
+		fmt.Printf("⚠ Failed to enable KubeVirt monitoring: %v\n", err)
+	}
+
+	fmt.Println("✓ Monitoring installed")
+	fmt.Printf("Reach Grafana with: kubectl -n %s port-forward service/grafana 3000\n", values.Namespace)
+	fmt.Printf("Grafana login: admin / %s\n", password)
+	return nil
+}
+
+// grafanaPassword returns Grafana's admin password, generating it into its Secret on
+// the first deploy so that later ones keep it
+func grafanaPassword(ctx context.Context, namespace string) (string, error) {
+	secrets := kube.Clientset.CoreV1().Secrets(namespace)
+	secret, err := secrets.Get(ctx, grafanaAdminSecret, metav1.GetOptions{})
+	if err == nil {
+		return string(secret.Data["password"]), nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to get the Grafana admin secret: %w", err)
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return "", err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      grafanaAdminSecret,
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/part-of": "llmcloud-monitoring"},
+		},
+		StringData: map[string]string{"username": "admin", "password": password},
+	}
+	if _, err := secrets.Create(ctx, secret, metav1.CreateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to create the Grafana admin secret: %w", err)
+	}
+	return password, nil
+}
+
+// newMonitoringValues returns the monitoring values of a deploy. operatorHost is the
+// SSH host running the operator, or empty when it runs in operatorNamespace
+func newMonitoringValues(operatorHost, operatorNamespace string) (monitoringValues, error) {
+	values := monitoringValues{
+		Namespace:         monitoringNamespace,
+		OperatorNamespace: operatorNamespace,
+		MetricsPort:       metricsPort,
+		Retention:         prometheusRetention,
+		GrafanaImage:      grafanaImage,
+		DeployedAt:        time.Now().UTC().Format(time.RFC3339),
+	}
+	if operatorHost != "" {
+		var err error
+		values.OperatorNamespace = monitoringNamespace
+		if values.OperatorAddress, values.OperatorAddressType, err = operatorIP(operatorHost); err != nil {
+			return values, err
+		}
+	}
+	return values, nil
+}
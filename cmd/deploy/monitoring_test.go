@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestMonitoringObjects(t *testing.T) {
+	objects, err := monitoringObjects(monitoringValues{
+		Namespace: "monitoring", OperatorNamespace: "llmcloud", MetricsPort: 8443, Retention: "7d", GrafanaImage: "grafana",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	byKind := objectsByKind(objects)
+
+	prometheus := byKind["Prometheus"]
+	if len(prometheus) != 1 || prometheus[0].GetNamespace() != "monitoring" {
+		t.Fatalf("Expected Prometheus in the monitoring namespace, got %v", prometheus)
+	}
+	if retention, _, _ := unstructured.NestedString(prometheus[0].Object, "spec", "retention"); retention != "7d" {
+		t.Errorf("Expected the retention, got %q", retention)
+	}
+	monitor := byKind["ServiceMonitor"]
+	if len(monitor) != 1 || monitor[0].GetNamespace() != "llmcloud" {
+		t.Fatalf("Expected the operator's ServiceMonitor in its namespace, got %v", monitor)
+	}
+	services := byKind["Service"]
+	i := slices.IndexFunc(services, func(s *unstructured.Unstructured) bool { return s.GetName() == "llmcloud-operator-metrics" })
+	if i < 0 || services[i].GetLabels()["llmcloud.io/metrics"] != "true" {
+		t.Fatalf("Expected the metrics Service the ServiceMonitor selects, got %v", services)
+	}
+	if selector, _, _ := unstructured.NestedStringMap(services[i].Object, "spec", "selector"); selector["app.kubernetes.io/name"] != "llmcloud-operator" {
+		t.Errorf("Expected the metrics Service to select the operator's pods, got %v", selector)
+	}
+	if len(byKind["EndpointSlice"]) != 0 {
+		t.Error("Expected no EndpointSlice for an operator in the cluster")
+	}
+	if bindings := byKind["ClusterRoleBinding"]; len(bindings) != 2 {
+		t.Errorf("Expected bindings for Prometheus and the operator's metrics auth, got %d", len(bindings))
+	}
+
+	dashboards := byKind["ConfigMap"][len(byKind["ConfigMap"])-1]
+	data, _, _ := unstructured.NestedStringMap(dashboards.Object, "data")
+	for _, name := range []string{"llmcloud.json", "kubevirt.json"} {
+		var dashboard struct {
+			UID    string            `json:"uid"`
+			Panels []json.RawMessage `json:"panels"`
+		}
+		if err := json.Unmarshal([]byte(data[name]), &dashboard); err != nil || dashboard.UID == "" || len(dashboard.Panels) == 0 {
+			t.Errorf("Expected the %s dashboard, got %v", name, err)
+		}
+	}
+}
+
+func TestMonitoringObjectsHostOperator(t *testing.T) {
+	objects, err := monitoringObjects(monitoringValues{
+		Namespace: "monitoring", OperatorNamespace: "monitoring", MetricsPort: 8443,
+		OperatorAddress: "10.0.0.1", OperatorAddressType: "IPv4",
+	})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	byKind := objectsByKind(objects)
+
+	endpointSlices := byKind["EndpointSlice"]
+	if len(endpointSlices) != 1 {
+		t.Fatalf("Expected an EndpointSlice for the host, got %d", len(endpointSlices))
+	}
+	endpoints, _, _ := unstructured.NestedSlice(endpointSlices[0].Object, "endpoints")
+	addresses := endpoints[0].(map[string]interface{})["addresses"].([]interface{})
+	if len(addresses) != 1 || addresses[0] != "10.0.0.1" {
+		t.Errorf("Expected the host's address, got %v", addresses)
+	}
+	if endpointSlices[0].GetLabels()["kubernetes.io/service-name"] != "llmcloud-operator-metrics" {
+		t.Errorf("Expected the EndpointSlice of the metrics Service, got %v", endpointSlices[0].GetLabels())
+	}
+	if len(byKind["ClusterRoleBinding"]) != 1 {
+		t.Error("Expected no metrics auth binding for an operator outside the cluster")
+	}
+}
+
+func TestInClusterMetrics(t *testing.T) {
+	objects, err := inClusterObjects(operatorValues{Namespace: "llmcloud", Image: "llmcloud", Replicas: 1, MetricsPort: 8443})
+	if err != nil {
+		t.Fatalf("Failed to render: %v", err)
+	}
+	deployment := objectsByKind(objects)["Deployment"][0]
+	containers, _, _ := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	manager := containers[0].(map[string]interface{})
+	args, _, _ := unstructured.NestedStringSlice(manager, "args")
+	if !slices.Contains(args, "--metrics-bind-address=:8443") {
+		t.Errorf("Expected the operator to serve metrics, got %v", args)
+	}
+	ports, _, _ := unstructured.NestedSlice(manager, "ports")
+	if len(ports) != 3 || ports[2].(map[string]interface{})["name"] != "metrics" {
+		t.Errorf("Expected the metrics port, got %v", ports)
+	}
+}
+
+func TestOperatorIP(t *testing.T) {
+	for host, want := range map[string]string{
+		"ubuntu@10.0.0.1":    "10.0.0.1",
+		"ubuntu@10.0.0.1:22": "10.0.0.1",
+		"[::1]:22":           "::1",
+	} {
+		ip, _, err := operatorIP(host)
+		if err != nil || ip != want {
+			t.Errorf("operatorIP(%q) = %q, %v; want %q", host, ip, err, want)
+		}
+	}
+}
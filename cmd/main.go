@@ -17,28 +17,46 @@ limitations under the License.
 package main
 
 import (
+	"cmp"
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/cmd/backup"
+	"github.com/rusik69/llmcloud-operator/cmd/ctl"
 	"github.com/rusik69/llmcloud-operator/cmd/deploy"
 	"github.com/rusik69/llmcloud-operator/cmd/uninstall"
 	"github.com/rusik69/llmcloud-operator/internal/api"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
 	"github.com/rusik69/llmcloud-operator/internal/auth"
 	"github.com/rusik69/llmcloud-operator/internal/controller"
+	"github.com/rusik69/llmcloud-operator/internal/metering"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+	"github.com/rusik69/llmcloud-operator/internal/sshexec"
+	webhookv1alpha1 "github.com/rusik69/llmcloud-operator/internal/webhook/v1alpha1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -56,10 +74,13 @@ func init() {
 func main() {
 	// Check for subcommands
 	if len(os.Args) > 1 {
-		if os.Args[1] == "deploy" || os.Args[1] == "uninstall" {
+		if slices.Contains([]string{"deploy", "uninstall", "backup", "restore", "ctl"}, os.Args[1]) {
 			rootCmd := &cobra.Command{Use: "manager"}
 			rootCmd.AddCommand(deploy.NewDeployCmd())
 			rootCmd.AddCommand(uninstall.NewUninstallCmd())
+			rootCmd.AddCommand(backup.NewBackupCmd())
+			rootCmd.AddCommand(backup.NewRestoreCmd())
+			rootCmd.AddCommand(ctl.NewCtlCmd())
 			if err := rootCmd.Execute(); err != nil {
 				os.Exit(1)
 			}
@@ -71,10 +92,41 @@ func main() {
 	var metricsCertPath, metricsCertName, metricsCertKey string
 	var webhookCertPath, webhookCertName, webhookCertKey string
 	var enableLeaderElection, secureMetrics, enableHTTP2 bool
+	var leaderElectionNamespace string
+	var apiKubeconfig string
+	var apiExitOnFailure bool
+	var apiTLS bool
+	var apiShutdownTimeout time.Duration
+	var enableWebhooks bool
+	var captureVMPhaseTransitions bool
+	var modelPullRetries int
+	var modelPullRetryBackoff time.Duration
+	var modelCache controller.ModelCache
+	var orphanSweepInterval time.Duration
+	var vmResyncInterval time.Duration
+	var expiryWarning time.Duration
+	var images controller.ImagePolicy
+	var localPathDiskDir string
+	var vmImageNamespace string
+	var uploadProxyURL string
+	var maxReplicas int
+	var vmIdleCPUThreshold string
+	var inferenceGateway bool
+	var usageFlushInterval time.Duration
+	var authNamespace string
+	var jwtKeyRotationInterval, jwtKeyGracePeriod time.Duration
+	var auditRetention time.Duration
+	var oidcConfig auth.OIDCConfig
+	var loginLimits api.LoginLimits
+	var loginLockoutAttempts int
+	var oidcConfigMap string
+	var nodeSSH sshexec.Config
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "Metrics endpoint address")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "Health probe address")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false, "Enable leader election")
+	flag.StringVar(&leaderElectionNamespace, "leader-election-namespace", "",
+		"Namespace of the leader election lease (required when running outside the cluster)")
 	flag.BoolVar(&secureMetrics, "metrics-secure", true, "Serve metrics via HTTPS")
 	flag.StringVar(&webhookCertPath, "webhook-cert-path", "", "Webhook certificate directory")
 	flag.StringVar(&webhookCertName, "webhook-cert-name", "tls.crt", "Webhook cert filename")
@@ -83,11 +135,130 @@ func main() {
 	flag.StringVar(&metricsCertName, "metrics-cert-name", "tls.crt", "Metrics cert filename")
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "Metrics key filename")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false, "Enable HTTP/2")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false, "Serve validating admission webhooks (requires webhook certificates)")
+	flag.StringVar(&apiKubeconfig, "api-kubeconfig", "", "Kubeconfig for the API server's client (defaults to the manager's)")
+	flag.BoolVar(&apiExitOnFailure, "api-exit-on-failure", true,
+		"Exit when the API server fails; otherwise only report it through the healthz endpoint")
+	flag.BoolVar(&apiTLS, "api-tls", false, "Serve the API over TLS with the webhook certificate (see --webhook-cert-path)")
+	flag.DurationVar(&apiShutdownTimeout, "api-shutdown-timeout", 20*time.Second,
+		"How long in-flight API requests may take to finish on shutdown")
+	flag.BoolVar(&captureVMPhaseTransitions, "capture-vm-phase-transitions", true,
+		"Record VMI phase transition timestamps in VirtualMachine status")
+	flag.IntVar(&modelPullRetries, "model-pull-retries", 5, "Automatic retries after a failed model pull (0 disables)")
+	flag.DurationVar(&modelPullRetryBackoff, "model-pull-retry-backoff", 30*time.Second,
+		"Base delay between model pull retries, doubled on each attempt")
+	flag.StringVar(&modelCache.Size, "model-cache-size", controller.DefaultModelCacheSize,
+		"Size of the volume Hugging Face models are downloaded to in each namespace")
+	flag.StringVar(&modelCache.StorageClass, "model-cache-storage-class", "",
+		"Storage class of the model cache volumes (defaults to the cluster's)")
+	flag.StringVar(&modelCache.AccessMode, "model-cache-access-mode", "ReadWriteOnce",
+		"Access mode of the model cache volumes; ReadWriteMany lets model replicas run on any node")
+	flag.StringVar(&modelCache.DownloadImage, "model-download-image", controller.DefaultModelDownloadImage,
+		"Image with python and pip that downloads Hugging Face models")
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", 10*time.Minute,
+		"How often project namespaces are checked for a deleted Project and cleaned up")
+	flag.DurationVar(&vmResyncInterval, "vm-resync-interval", 10*time.Minute,
+		"How often every VM is reconciled, correcting drift of its KubeVirt objects (0 to disable)")
+	flag.DurationVar(&expiryWarning, "expiry-warning", time.Hour,
+		"How long before projects, VMs and models with a TTL are deleted that an Expiring warning event is recorded")
+	flag.StringVar(&images.RegistryMirror, "registry-mirror", "",
+		"Registry prefix that images from public registries are pulled through (e.g., mirror.local:5000)")
+	flag.StringVar(&images.PullPolicy, "image-pull-policy", "",
+		"Image pull policy for workloads: Always, IfNotPresent or Never (defaults to the cluster's)")
+	flag.StringVar(&vmImageNamespace, "vm-image-namespace", controller.DefaultVMImageNamespace,
+		"Namespace holding the volumes of uploaded VM images")
+	flag.StringVar(&uploadProxyURL, "cdi-upload-proxy-url", "",
+		"URL of the CDI upload proxy VM images are uploaded through (defaults to the cluster IP of its Service)")
+	flag.StringVar(&localPathDiskDir, "local-path-disk-dir", "",
+		"Keep the local-path provisioner's default path set to this directory (disabled when empty)")
+	flag.IntVar(&llmcloudv1alpha1.MaxSSHKeys, "max-ssh-keys", llmcloudv1alpha1.MaxSSHKeys, "Maximum number of SSH keys per VM")
+	flag.Func("default-os-versions",
+		"Default versions for OSes when a VM does not set one, as os=version pairs (e.g., ubuntu=24.04,debian=13)",
+		func(value string) error {
+			versions, err := llmcloudv1alpha1.ParseDefaultOSVersions(value)
+			if err == nil {
+				llmcloudv1alpha1.DefaultOSVersions = versions
+			}
+			return err
+		})
+	flag.IntVar(&maxReplicas, "max-replicas", int(llmcloudv1alpha1.MaxReplicas),
+		"Maximum replicas for an LLM model or service (0 disables the limit)")
+	flag.BoolVar(&inferenceGateway, "inference-gateway", false,
+		"Route /api/v1/inference/{namespace}/{model}/... requests on the API server to the model's endpoint")
+	flag.DurationVar(&usageFlushInterval, "usage-flush-interval", time.Minute,
+		"How often the requests and tokens metered by the inference gateway are added to the model and project statuses")
+	flag.StringVar(&vmIdleCPUThreshold, "vm-idle-cpu-threshold", "50m",
+		"CPU usage below which a VM with suspendAfterIdle counts as idle")
+	flag.DurationVar(&auth.TokenTTL, "token-ttl", auth.TokenTTL,
+		"How long login and refreshed API tokens are valid, unless the LLMCloudConfig sets tokenTTL")
+	flag.StringVar(&authNamespace, "auth-namespace", "kube-system",
+		"Namespace of the Secret holding the API token signing keys and the ConfigMap recording revoked tokens")
+	flag.DurationVar(&jwtKeyRotationInterval, "jwt-key-rotation-interval", 0,
+		"How often the API token signing key is rotated (0 disables rotation)")
+	flag.DurationVar(&jwtKeyGracePeriod, "jwt-key-grace-period", 24*time.Hour,
+		"How long tokens signed with a rotated key remain valid; at least --token-ttl and the tokenTTL of the LLMCloudConfig")
+	flag.StringVar(&oidcConfig.IssuerURL, "oidc-issuer-url", "", "Issuer of the OpenID Connect provider users may log in through")
+	flag.StringVar(&oidcConfig.ClientID, "oidc-client-id", "", "Client ID of the API server at the OpenID Connect provider")
+	flag.StringVar(&oidcConfig.ClientSecret, "oidc-client-secret", "", "Client secret of the API server at the OpenID Connect provider")
+	flag.StringVar(&oidcConfig.RedirectURL, "oidc-redirect-url", "",
+		"External URL of /api/v1/auth/oidc/callback, as registered with the OpenID Connect provider")
+	flag.StringVar(&oidcConfig.UsernameClaim, "oidc-username-claim", "",
+		"ID token claim used as the username, prefixed with oidc: (defaults to preferred_username, then a verified email, then sub)")
+	flag.StringVar(&oidcConfig.GroupsClaim, "oidc-groups-claim", "", "ID token claim listing the user's groups (defaults to groups)")
+	flag.Func("oidc-admin-groups", "Comma-separated OpenID Connect groups whose members are admins", func(value string) error {
+		for _, group := range strings.Split(value, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				oidcConfig.AdminGroups = append(oidcConfig.AdminGroups, group)
+			}
+		}
+		return nil
+	})
+	flag.Func("oidc-group-projects",
+		"Projects granted to OpenID Connect groups, as group=project pairs (e.g., devs=team,devs=ml)",
+		func(value string) error {
+			groupProjects, err := auth.ParseOIDCGroupProjects(value)
+			if err == nil {
+				oidcConfig.GroupProjects = groupProjects
+			}
+			return err
+		})
+	flag.StringVar(&oidcConfigMap, "oidc-config", "llmcloud-oidc",
+		"ConfigMap in the auth namespace with OpenID Connect settings for the fields not set by flags")
+	flag.DurationVar(&auditRetention, "audit-retention", 90*24*time.Hour,
+		"How long audit events are kept (0 keeps them forever)")
+	flag.IntVar(&loginLimits.FreeAttempts, "login-free-attempts", 5,
+		"Failed logins in a row allowed per address and per username before attempts back off (0 disables)")
+	flag.DurationVar(&loginLimits.Backoff, "login-backoff", time.Second,
+		"How long logins are refused after the first failure beyond --login-free-attempts; doubles with each further failure")
+	flag.DurationVar(&loginLimits.MaxBackoff, "login-max-backoff", 15*time.Minute, "Longest time logins are refused after failures")
+	flag.IntVar(&loginLockoutAttempts, "login-lockout-attempts", 10,
+		"Failed logins in a row after which the account is locked in its status (0 disables)")
+	nodeSSH.BindFlags(flag.CommandLine, "node-")
 
 	opts := zap.Options{Development: true}
 	opts.BindFlags(flag.CommandLine)
 	flag.Parse()
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	llmcloudv1alpha1.MaxReplicas = int32(maxReplicas)
+	loginLimits.LockoutAttempts = int32(loginLockoutAttempts)
+
+	switch images.PullPolicy {
+	case "", "Always", "IfNotPresent", "Never":
+	default:
+		setupLog.Error(nil, "invalid --image-pull-policy", "value", images.PullPolicy)
+		os.Exit(1)
+	}
+	idleCPUThreshold, err := resource.ParseQuantity(vmIdleCPUThreshold)
+	if err != nil {
+		setupLog.Error(err, "invalid --vm-idle-cpu-threshold", "value", vmIdleCPUThreshold)
+		os.Exit(1)
+	}
+	// Tokens signed with a rotated key must stay valid until they expire
+	if jwtKeyRotationInterval > 0 && jwtKeyGracePeriod < auth.TokenTTL {
+		setupLog.Error(nil, "--jwt-key-grace-period must be at least --token-ttl",
+			"jwt-key-grace-period", jwtKeyGracePeriod, "token-ttl", auth.TokenTTL)
+		os.Exit(1)
+	}
 
 	var tlsOpts []func(*tls.Config)
 	if !enableHTTP2 {
@@ -124,22 +295,110 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "fe560ec5.llmcloud.io",
+		// Release the lease on SIGTERM so a restarted operator does not wait for it to expire
+		LeaderElectionNamespace:       leaderElectionNamespace,
+		LeaderElectionReleaseOnCancel: true,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	if err := ctrlmetrics.Registry.Register(&metrics.ResourceCollector{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to register resource metrics")
+		os.Exit(1)
+	}
+
+	auditRecorder := &audit.Recorder{Client: mgr.GetClient(), Retention: auditRetention}
+	if err := mgr.Add(auditRecorder); err != nil {
+		setupLog.Error(err, "unable to set up audit event pruning")
+		os.Exit(1)
+	}
+
+	guestAgent, err := controller.NewKubeVirtGuestAgent(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to set up the guest agent client")
+		os.Exit(1)
+	}
+	cloudInitStatus, err := controller.NewGuestAgentCloudInitStatus(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to set up the cloud-init status client")
+		os.Exit(1)
+	}
+
 	controllers := []interface {
 		SetupWithManager(ctrl.Manager) error
 	}{
-		&controller.ProjectReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
-		&controller.VirtualMachineReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
-		&controller.LLMModelReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
-		&controller.ServiceReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
-		&controller.UserReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
+		&controller.ProjectReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("project-controller"),
+		},
+		&controller.VirtualMachineReconciler{
+			Client:                  mgr.GetClient(),
+			Scheme:                  mgr.GetScheme(),
+			CapturePhaseTransitions: captureVMPhaseTransitions,
+			Images:                  images,
+			Activity:                &controller.PodMetricsActivity{Client: mgr.GetClient(), CPUThreshold: idleCPUThreshold},
+			CloudInit:               cloudInitStatus,
+			GuestAgent:              guestAgent,
+			Recorder:                mgr.GetEventRecorderFor("virtualmachine-controller"),
+			ResyncInterval:          vmResyncInterval,
+		},
+		&controller.LLMModelReconciler{
+			Client:           mgr.GetClient(),
+			Scheme:           mgr.GetScheme(),
+			MaxPullRetries:   int32(modelPullRetries),
+			PullRetryBackoff: modelPullRetryBackoff,
+			Images:           images,
+			Cache:            modelCache,
+			Recorder:         mgr.GetEventRecorderFor("llmmodel-controller"),
+			Prober:           &controller.HTTPModelProber{},
+		},
+		&controller.ServiceReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Images:   images,
+			Recorder: mgr.GetEventRecorderFor("service-controller"),
+		},
+		&controller.UserReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorderFor("user-controller"),
+		},
+		&controller.LLMCloudConfigReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme()},
+		&controller.ClusterNodeReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme(), SSH: nodeSSH},
+		&controller.VMImageReconciler{Client: mgr.GetClient(), Scheme: mgr.GetScheme(), Namespace: vmImageNamespace},
+		&controller.OrphanedProjectReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			Interval:  orphanSweepInterval,
+			Audit:     auditRecorder,
+			APIReader: mgr.GetAPIReader(),
+		},
 		// +kubebuilder:scaffold:builder
 	}
+	for _, newObject := range []func() client.Object{
+		func() client.Object { return &llmcloudv1alpha1.Project{} },
+		func() client.Object { return &llmcloudv1alpha1.VirtualMachine{} },
+		func() client.Object { return &llmcloudv1alpha1.LLMModel{} },
+	} {
+		controllers = append(controllers, &controller.ExpiryReconciler{
+			Client:    mgr.GetClient(),
+			Scheme:    mgr.GetScheme(),
+			NewObject: newObject,
+			Warning:   expiryWarning,
+			Recorder:  mgr.GetEventRecorderFor("expiry-controller"),
+			Audit:     auditRecorder,
+		})
+	}
+	if localPathDiskDir != "" {
+		controllers = append(controllers, &controller.LocalPathConfigReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			DiskPath: localPathDiskDir,
+		})
+	}
 
 	for _, c := range controllers {
 		if err := c.SetupWithManager(mgr); err != nil {
@@ -148,27 +407,156 @@ func main() {
 		}
 	}
 
+	if enableWebhooks {
+		webhooks := []func(ctrl.Manager) error{
+			webhookv1alpha1.SetupProjectWebhookWithManager,
+			webhookv1alpha1.SetupUserWebhookWithManager,
+			webhookv1alpha1.SetupVirtualMachineWebhookWithManager,
+			webhookv1alpha1.SetupLLMModelWebhookWithManager,
+			webhookv1alpha1.SetupServiceWebhookWithManager,
+			webhookv1alpha1.SetupSSHKeyWebhookWithManager,
+			webhookv1alpha1.SetupVMTemplateWebhookWithManager,
+			webhookv1alpha1.SetupVMImageWebhookWithManager,
+			webhookv1alpha1.SetupGroupWebhookWithManager,
+			webhookv1alpha1.SetupLLMCloudConfigWebhookWithManager,
+			webhookv1alpha1.SetupClusterNodeWebhookWithManager,
+		}
+		for _, setup := range webhooks {
+			if err := setup(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook")
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Load the JWT signing keys before the manager's cache is running, so the API server
+	// can validate tokens as soon as it starts
+	keyClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client for JWT signing keys")
+		os.Exit(1)
+	}
+	signingKeys := &auth.SigningKeyStore{
+		Client:           keyClient,
+		Namespace:        authNamespace,
+		Name:             "llmcloud-jwt-signing-keys",
+		RotationInterval: jwtKeyRotationInterval,
+		GracePeriod:      jwtKeyGracePeriod,
+	}
+	if err := signingKeys.Load(context.Background()); err != nil {
+		setupLog.Error(err, "unable to load JWT signing keys")
+		os.Exit(1)
+	}
+	if jwtKeyRotationInterval > 0 {
+		config := &llmcloudv1alpha1.LLMCloudConfig{}
+		err := keyClient.Get(context.Background(), client.ObjectKey{Name: llmcloudv1alpha1.LLMCloudConfigName}, config)
+		if client.IgnoreNotFound(err) != nil {
+			setupLog.Error(err, "unable to read the LLMCloudConfig")
+			os.Exit(1)
+		}
+		if ttl := config.Spec.TokenTTL; ttl != nil && ttl.Duration > jwtKeyGracePeriod {
+			setupLog.Error(nil, "--jwt-key-grace-period must be at least the tokenTTL of the LLMCloudConfig",
+				"jwt-key-grace-period", jwtKeyGracePeriod, "tokenTTL", ttl.Duration)
+			os.Exit(1)
+		}
+	}
+	if err := mgr.Add(signingKeys); err != nil {
+		setupLog.Error(err, "unable to set up JWT signing key rotation")
+		os.Exit(1)
+	}
+
+	if err := auth.LoadOIDCConfigMap(context.Background(), keyClient, authNamespace, oidcConfigMap, &oidcConfig); err != nil {
+		setupLog.Error(err, "unable to load OIDC configuration")
+		os.Exit(1)
+	}
+	var oidcProvider *auth.OIDCProvider
+	if oidcConfig.Enabled() {
+		if oidcProvider, err = auth.NewOIDCProvider(context.Background(), oidcConfig); err != nil {
+			setupLog.Error(err, "unable to set up OIDC login")
+			os.Exit(1)
+		}
+	}
+
+	apiClient, err := api.NewClient(apiKubeconfig, scheme, mgr.GetClient())
+	if err != nil {
+		setupLog.Error(err, "unable to create API server client")
+		os.Exit(1)
+	}
+	auth.SetRevocationList(&auth.RevocationList{
+		Client:    apiClient,
+		Namespace: authNamespace,
+		Name:      "llmcloud-revoked-tokens",
+	})
+	apiConfig, err := api.NewConfig(apiKubeconfig, mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to load API server config")
+		os.Exit(1)
+	}
+	// Watches share the manager's informers, unless the API server reads another cluster
+	apiInformers := mgr.GetCache()
+	if apiKubeconfig != "" {
+		if apiInformers, err = cache.New(apiConfig, cache.Options{Scheme: scheme}); err != nil {
+			setupLog.Error(err, "unable to create API server cache")
+			os.Exit(1)
+		}
+		if err := mgr.Add(apiInformers); err != nil {
+			setupLog.Error(err, "unable to set up API server cache")
+			os.Exit(1)
+		}
+	}
+	apiServer := api.NewServer(apiClient)
+	apiServer.Images = images
+	apiServer.InferenceGateway = inferenceGateway
+	if inferenceGateway {
+		meter := &metering.Meter{Client: apiClient, FlushInterval: usageFlushInterval}
+		if err := mgr.Add(meter); err != nil {
+			setupLog.Error(err, "unable to set up inference metering")
+			os.Exit(1)
+		}
+		apiServer.Meter = meter
+	}
+	apiServer.Config = apiConfig
+	apiServer.Audit = auditRecorder
+	apiServer.Informers = apiInformers
+	apiServer.OIDC = oidcProvider
+	apiServer.LoginLimits = loginLimits
+	apiServer.ShutdownTimeout = apiShutdownTimeout
+	apiServer.UploadProxyURL = uploadProxyURL
+	apiServer.TLSOpts = tlsOpts
+	if apiTLS {
+		apiServer.CertDir = cmp.Or(webhookCertPath, filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs"))
+		apiServer.CertName = webhookCertName
+		apiServer.KeyName = webhookCertKey
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
+	if err := mgr.AddHealthzCheck("api", apiServer.Healthz); err != nil {
+		setupLog.Error(err, "unable to set up API server health check")
+		os.Exit(1)
+	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
 
-	// Initialize JWT secret for authentication
-	if err := auth.InitJWTSecret(); err != nil {
-		setupLog.Error(err, "unable to initialize JWT secret")
+	// A failing API server stops the manager, unless it should only be reported
+	var apiRunnable manager.Runnable = apiServer
+	if !apiExitOnFailure {
+		apiRunnable = manager.RunnableFunc(func(ctx context.Context) error {
+			if err := apiServer.Start(ctx); err != nil {
+				setupLog.Error(err, "API server failed")
+			}
+			return nil
+		})
+	}
+	if err := mgr.Add(apiRunnable); err != nil {
+		setupLog.Error(err, "unable to set up API server")
 		os.Exit(1)
 	}
 
-	go func() {
-		if err := api.NewServer(mgr.GetClient()).Start(":8090"); err != nil {
-			setupLog.Error(err, "API server failed")
-		}
-	}()
-
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
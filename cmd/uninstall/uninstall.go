@@ -18,23 +18,31 @@ package uninstall
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/rusik69/llmcloud-operator/internal/kubeops"
+	"github.com/rusik69/llmcloud-operator/internal/sshexec"
 )
 
 var (
 	sshHost      string
 	kubeconfig   string
 	uninstallK0s bool
+
+	// hosts holds the SSH connection to the host
+	hosts sshexec.Pool
 )
 
 func NewUninstallCmd() *cobra.Command {
@@ -49,6 +57,10 @@ func NewUninstallCmd() *cobra.Command {
 	cmd.Flags().StringVar(&kubeconfig, "kubeconfig", filepath.Join(os.Getenv("HOME"), ".kube", "config-llmcloud"), "Kubeconfig path")
 	cmd.Flags().BoolVar(&uninstallK0s, "k0s", false, "Also uninstall k0s from the node")
 
+	sshFlags := flag.NewFlagSet("ssh", flag.ContinueOnError)
+	hosts.Config.BindFlags(sshFlags, "")
+	cmd.Flags().AddGoFlagSet(sshFlags)
+
 	return cmd
 }
 
@@ -58,12 +70,13 @@ func runUninstall(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Printf("==> Uninstalling from %s\n", sshHost)
+	defer hosts.Close()
 
 	// Stop operator service
 	stopOperator()
 
 	// Remove finalizers and delete resources
-	cleanupResources()
+	report := cleanupResources()
 
 	// Cleanup operator files
 	cleanupOperatorFiles()
@@ -73,6 +86,10 @@ func runUninstall(cmd *cobra.Command, args []string) error {
 		uninstallK0sCluster()
 	}
 
+	fmt.Printf("\n%s", report.summary())
+	if failed := len(report.failures()); failed > 0 {
+		return fmt.Errorf("uninstall incomplete: %d cleanup action(s) failed", failed)
+	}
 	fmt.Println("\n✓ Uninstall completed successfully!")
 	return nil
 }
@@ -82,53 +99,134 @@ func stopOperator() {
 
 	_ = execCommand(sshHost, "sudo systemctl stop llmcloud-operator 2>/dev/null || true")
 	_ = execCommand(sshHost, "sudo systemctl disable llmcloud-operator 2>/dev/null || true")
-	_ = execCommand(sshHost, "sudo pkill -f llmcloud-operator || true")
+	// The bracket keeps the pattern from matching the shell running pkill and pgrep
+	_ = execCommand(sshHost, "sudo pkill -f '[/]opt/llmcloud-operator/manager' || true")
+
+	// Give the operator a moment to exit so it is not reconciling while resources are removed
+	_ = execCommand(sshHost, "for i in $(seq 10); do pgrep -f '[/]opt/llmcloud-operator/manager' >/dev/null || break; sleep 1; done")
 
 	fmt.Println("✓ Operator stopped")
 }
 
-func cleanupResources() {
+// cleanupRetries is how many times a failed cleanup action is attempted
+const cleanupRetries = 3
+
+// cleanupRetryDelay is the wait between attempts of a failed cleanup action
+var cleanupRetryDelay = 2 * time.Second
+
+// cleanupStep is the outcome of one cleanup action
+type cleanupStep struct {
+	name string
+	err  error
+}
+
+// cleanupReport collects the outcome of every cleanup action, so that what could not
+// be removed is reported instead of silently ignored
+type cleanupReport struct {
+	steps []cleanupStep
+}
+
+func (r *cleanupReport) record(name string, err error) {
+	r.steps = append(r.steps, cleanupStep{name: name, err: err})
+}
+
+// run records the outcome of fn, retrying it up to cleanupRetries times
+func (r *cleanupReport) run(name string, fn func() error) {
+	r.record(name, retry(cleanupRetries, fn))
+}
+
+func (r *cleanupReport) failures() []cleanupStep {
+	var failed []cleanupStep
+	for _, step := range r.steps {
+		if step.err != nil {
+			failed = append(failed, step)
+		}
+	}
+	return failed
+}
+
+// summary lists every cleanup action with its result
+func (r *cleanupReport) summary() string {
+	var b strings.Builder
+	failed := len(r.failures())
+	fmt.Fprintf(&b, "Cleanup summary: %d succeeded, %d failed\n", len(r.steps)-failed, failed)
+	for _, step := range r.steps {
+		if step.err != nil {
+			fmt.Fprintf(&b, "  ✗ %s: %v\n", step.name, step.err)
+		} else {
+			fmt.Fprintf(&b, "  ✓ %s\n", step.name)
+		}
+	}
+	return b.String()
+}
+
+// retry calls fn until it succeeds or has been called attempts times, and returns
+// its last error
+func retry(attempts int, fn func() error) error {
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(cleanupRetryDelay)
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func cleanupResources() *cleanupReport {
 	fmt.Println("Cleaning up Kubernetes resources...")
+	report := &cleanupReport{}
 
 	// Check if kubeconfig exists
 	if _, err := os.Stat(kubeconfig); os.IsNotExist(err) {
 		fmt.Println("⚠ Kubeconfig not found, skipping resource cleanup")
-		return
+		report.record("load kubeconfig", err)
+		return report
 	}
 
 	// Load kubeconfig
 	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
 	if err != nil {
 		fmt.Printf("⚠ Failed to load kubeconfig: %v, skipping resource cleanup\n", err)
-		return
+		report.record("load kubeconfig", err)
+		return report
 	}
 
 	// Set timeout for API server connection
 	config.Timeout = 10 * time.Second
 
-	clientset, err := kubernetes.NewForConfig(config)
+	kube, err := kubeops.New(config)
 	if err != nil {
 		fmt.Printf("⚠ Failed to create kubernetes client: %v, skipping resource cleanup\n", err)
-		return
+		report.record("create kubernetes client", err)
+		return report
 	}
+	clientset := kube.Clientset
 
 	ctx := context.Background()
 
+	// Request deletion before stripping finalizers: no finalizer can be added to an object
+	// that is being deleted, so an operator that has not fully stopped cannot re-add them
+	resources := []string{
+		"llmmodels.llmcloud.llmcloud.io", "services.llmcloud.llmcloud.io", "virtualmachines.llmcloud.llmcloud.io",
+		"projects.llmcloud.llmcloud.io", "users.llmcloud.llmcloud.io", "vmimages.llmcloud.llmcloud.io",
+	}
+	for _, resource := range resources {
+		fmt.Printf("Deleting %s...\n", resource)
+		report.run("delete "+resource, func() error {
+			return ignoreUnknownResource(kube.DeleteAll(ctx, resource, "", metav1.DeleteOptions{}))
+		})
+	}
+
 	// Remove finalizers from projects
 	fmt.Println("Removing finalizers from projects...")
-	removeFinalizers("projects")
+	removeFinalizers(ctx, kube, report, "projects.llmcloud.llmcloud.io")
 
 	// Remove finalizers from users
 	fmt.Println("Removing finalizers from users...")
-	removeFinalizers("users")
-
-	// Delete resources
-	resources := []string{"llmmodels", "services.llmcloud.io", "virtualmachines", "projects", "users"}
-	for _, resource := range resources {
-		fmt.Printf("Deleting %s...\n", resource)
-		deleteCmd := fmt.Sprintf("kubectl --kubeconfig=%s delete %s --all --all-namespaces --timeout=10s 2>/dev/null || true", kubeconfig, resource)
-		_ = execCommandShell(deleteCmd)
-	}
+	removeFinalizers(ctx, kube, report, "users.llmcloud.llmcloud.io")
 
 	// Wait for resources to be deleted
 	time.Sleep(2 * time.Second)
@@ -136,50 +234,98 @@ func cleanupResources() {
 	// Delete project namespaces with force
 	fmt.Println("Deleting project namespaces...")
 	namespaces, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	report.record("list namespaces", err)
 	if err == nil {
 		for _, ns := range namespaces.Items {
 			if strings.HasPrefix(ns.Name, "project-") {
 				fmt.Printf("  Deleting namespace %s...\n", ns.Name)
 
-				// Delete all resources without waiting - use short timeouts
-				_ = execCommandShell(fmt.Sprintf("kubectl --kubeconfig=%s delete virtualmachines.llmcloud.llmcloud.io --all -n %s --timeout=3s 2>/dev/null || true", kubeconfig, ns.Name))
-				_ = execCommandShell(fmt.Sprintf("kubectl --kubeconfig=%s delete datavolumes --all -n %s --timeout=3s 2>/dev/null || true", kubeconfig, ns.Name))
-				_ = execCommandShell(fmt.Sprintf("kubectl --kubeconfig=%s delete pvc --all -n %s --timeout=3s 2>/dev/null || true", kubeconfig, ns.Name))
-				_ = execCommandShell(fmt.Sprintf("kubectl --kubeconfig=%s delete pods --all -n %s --grace-period=0 --force --timeout=3s 2>/dev/null || true", kubeconfig, ns.Name))
+				// Delete all resources without waiting
+				for _, resource := range []string{"virtualmachines.llmcloud.llmcloud.io", "datavolumes", "persistentvolumeclaims"} {
+					report.run(fmt.Sprintf("delete %s in %s", resource, ns.Name), func() error {
+						return ignoreUnknownResource(kube.DeleteAll(ctx, resource, ns.Name, metav1.DeleteOptions{}))
+					})
+				}
+				report.run("delete pods in "+ns.Name, func() error {
+					return kube.DeleteAll(ctx, "pods", ns.Name, forceDelete())
+				})
 
 				// Remove finalizers from namespace
 				ns.Finalizers = []string{}
-				_, _ = clientset.CoreV1().Namespaces().Update(ctx, &ns, metav1.UpdateOptions{})
+				_, err := clientset.CoreV1().Namespaces().Update(ctx, &ns, metav1.UpdateOptions{})
+				report.record("remove finalizers from namespace "+ns.Name, ignoreNotFound(err))
 
 				// Delete namespace without waiting
-				_ = execCommandShell(fmt.Sprintf("kubectl --kubeconfig=%s delete namespace %s --wait=false --timeout=2s 2>/dev/null || true", kubeconfig, ns.Name))
+				report.run("delete namespace "+ns.Name, func() error {
+					return ignoreNotFound(clientset.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{}))
+				})
 			}
 		}
 	}
 
 	// Force cleanup any stuck namespaces after a brief wait - don't wait for this either
 	time.Sleep(2 * time.Second)
-	_ = execCommandShell(fmt.Sprintf("for ns in $(kubectl --kubeconfig=%s get ns -o name 2>/dev/null | grep project- | cut -d/ -f2); do kubectl --kubeconfig=%s patch namespace $ns -p '{\"metadata\":{\"finalizers\":null}}' --type=merge 2>/dev/null; kubectl --kubeconfig=%s delete namespace $ns --wait=false --grace-period=0 2>/dev/null; done || true", kubeconfig, kubeconfig, kubeconfig))
+	namespaces, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err == nil {
+		for _, ns := range namespaces.Items {
+			if strings.HasPrefix(ns.Name, "project-") {
+				report.run("force delete namespace "+ns.Name, func() error {
+					_, err := clientset.CoreV1().Namespaces().Patch(ctx, ns.Name, types.MergePatchType,
+						[]byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+					if err := ignoreNotFound(err); err != nil {
+						return err
+					}
+					return ignoreNotFound(clientset.CoreV1().Namespaces().Delete(ctx, ns.Name, forceDelete()))
+				})
+			}
+		}
+	}
 
-	fmt.Println("✓ Resources cleaned up")
+	if len(report.failures()) == 0 {
+		fmt.Println("✓ Resources cleaned up")
+	} else {
+		fmt.Println("⚠ Some resources could not be cleaned up")
+	}
+	return report
 }
 
-func removeFinalizers(resource string) {
+func removeFinalizers(ctx context.Context, kube *kubeops.Client, report *cleanupReport, resource string) {
 	// Get all resources
-	listCmd := fmt.Sprintf("kubectl --kubeconfig=%s get %s -o name 2>/dev/null", kubeconfig, resource)
-	output, err := exec.Command("sh", "-c", listCmd).Output()
+	objects, err := kube.List(ctx, resource, "")
 	if err != nil {
 		return // No resources found
 	}
 
-	resources := strings.Split(strings.TrimSpace(string(output)), "\n")
-	for _, res := range resources {
-		if res == "" {
-			continue
-		}
-		patchCmd := fmt.Sprintf("kubectl --kubeconfig=%s patch %s -p '{\"metadata\":{\"finalizers\":[]}}' --type=merge 2>/dev/null", kubeconfig, res)
-		_ = execCommandShell(patchCmd)
+	for _, obj := range objects {
+		name := strings.ToLower(obj.GetKind()) + "/" + obj.GetName()
+		report.run("remove finalizers from "+name, func() error {
+			return kube.Patch(ctx, resource, obj.GetNamespace(), obj.GetName(), types.MergePatchType,
+				[]byte(`{"metadata":{"finalizers":[]}}`))
+		})
+	}
+}
+
+// ignoreUnknownResource treats a resource type that does not exist, e.g. because its
+// CRD was never installed, as having nothing to clean up
+func ignoreUnknownResource(err error) error {
+	if meta.IsNoMatchError(err) {
+		return nil
 	}
+	return err
+}
+
+// forceDelete deletes without a grace period, like kubectl delete --force --grace-period=0
+func forceDelete() metav1.DeleteOptions {
+	gracePeriod := int64(0)
+	return metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod}
+}
+
+// ignoreNotFound treats an object that is already gone as cleaned up
+func ignoreNotFound(err error) error {
+	if err == nil || apierrors.IsNotFound(err) || strings.Contains(err.Error(), "NotFound") {
+		return nil
+	}
+	return err
 }
 
 func cleanupOperatorFiles() {
@@ -231,16 +377,11 @@ func uninstallK0sCluster() {
 	fmt.Println("✓ k0s uninstalled successfully")
 }
 
-func execCommand(args ...string) error {
-	cmd := exec.Command("ssh", args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
-func execCommandShell(command string) error {
-	cmd := exec.Command("sh", "-c", command)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
+// execCommand runs a shell command on host, streaming its output
+func execCommand(host, command string) error {
+	client, err := hosts.Client(host)
+	if err != nil {
+		return err
+	}
+	return client.Run(command, os.Stdout, os.Stderr)
 }
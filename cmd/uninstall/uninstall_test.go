@@ -0,0 +1,75 @@
+package uninstall
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCleanupReportSummary(t *testing.T) {
+	cleanupRetryDelay = 0
+
+	report := &cleanupReport{}
+	report.run("delete llmmodels", func() error { return nil })
+	report.run("delete namespace project-a", func() error { return errors.New("connection refused") })
+	report.record("remove finalizers from namespace project-b", nil)
+	report.record("list namespaces", errors.New("forbidden"))
+
+	failures := report.failures()
+	if len(failures) != 2 {
+		t.Fatalf("Expected 2 failures, got %v", failures)
+	}
+	if failures[0].name != "delete namespace project-a" || failures[1].name != "list namespaces" {
+		t.Errorf("Unexpected failures %v", failures)
+	}
+
+	summary := report.summary()
+	for _, want := range []string{
+		"2 succeeded, 2 failed",
+		"✓ delete llmmodels",
+		"✗ delete namespace project-a: connection refused",
+		"✗ list namespaces: forbidden",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("Expected %q in summary:\n%s", want, summary)
+		}
+	}
+}
+
+func TestCleanupReportRetries(t *testing.T) {
+	cleanupRetryDelay = 0
+
+	report := &cleanupReport{}
+	calls := 0
+	report.run("delete users", func() error {
+		calls++
+		if calls < cleanupRetries {
+			return errors.New("etcd leader changed")
+		}
+		return nil
+	})
+	if calls != cleanupRetries || len(report.failures()) != 0 {
+		t.Errorf("Expected success on attempt %d, got %d calls and failures %v", cleanupRetries, calls, report.failures())
+	}
+
+	calls = 0
+	report.run("delete projects", func() error {
+		calls++
+		return errors.New("timeout")
+	})
+	if calls != cleanupRetries {
+		t.Errorf("Expected %d attempts, got %d", cleanupRetries, calls)
+	}
+	if failures := report.failures(); len(failures) != 1 || failures[0].err.Error() != "timeout" {
+		t.Errorf("Expected the last error to be recorded, got %v", failures)
+	}
+}
+
+func TestIgnoreNotFound(t *testing.T) {
+	if err := ignoreNotFound(errors.New(`Error from server (NotFound): namespaces "project-a" not found`)); err != nil {
+		t.Errorf("Expected NotFound to be ignored, got %v", err)
+	}
+	if err := ignoreNotFound(errors.New("forbidden")); err == nil {
+		t.Error("Expected other errors to be kept")
+	}
+}
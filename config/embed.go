@@ -0,0 +1,26 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package config embeds the generated manifests, so that the deploy command can apply
+// them without a checkout of the repository
+package config
+
+import "embed"
+
+// Manifests holds the CRDs and the manager's roles, as controller-gen generates them
+//
+//go:embed crd/bases/*.yaml rbac/role.yaml rbac/leader_election_role.yaml
+var Manifests embed.FS
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// apiKeyPrefixLength is how much of a key is kept in the clear to recognize it by
+const apiKeyPrefixLength = 12
+
+// apiKeyInfo describes an API key without its hash. Key is only set in the response to
+// its creation, the one time the key is shown
+type apiKeyInfo struct {
+	Name      string       `json:"name"`
+	Prefix    string       `json:"prefix,omitempty"`
+	Scopes    []string     `json:"scopes"`
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	Expired   bool         `json:"expired"`
+	Key       string       `json:"key,omitempty"`
+}
+
+func newAPIKeyInfo(key *llmcloudv1alpha1.APIKey) apiKeyInfo {
+	return apiKeyInfo{
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		ExpiresAt: key.ExpiresAt,
+		Expired:   key.Expired(time.Now()),
+	}
+}
+
+// redactUser removes the password and API key hashes from user before it is sent out
+func redactUser(user *llmcloudv1alpha1.User) {
+	user.Spec.PasswordHash = ""
+	for i := range user.Spec.APIKeys {
+		user.Spec.APIKeys[i].Hash = ""
+	}
+}
+
+// keptAPIKeys returns the stored keys named in requested, so that an update of a user
+// can revoke keys but neither create one nor change what one is
+func keptAPIKeys(stored, requested []llmcloudv1alpha1.APIKey) ([]llmcloudv1alpha1.APIKey, error) {
+	keys := make([]llmcloudv1alpha1.APIKey, 0, len(requested))
+	for _, key := range requested {
+		i := slices.IndexFunc(stored, func(k llmcloudv1alpha1.APIKey) bool { return k.Name == key.Name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown API key %q; keys are created through /api/v1/me/apikeys", key.Name)
+		}
+		keys = append(keys, stored[i])
+	}
+	return keys, nil
+}
+
+// apiKeyClaims authenticates a request carrying key in its X-API-Key header. Keys of a
+// user who must change the password are refused, like their logins
+func (s *Server) apiKeyClaims(ctx context.Context, key string) (*auth.Claims, error) {
+	user, apiKey, err := auth.AuthenticateAPIKey(ctx, s.client, key)
+	if err != nil {
+		return nil, err
+	}
+	if auth.PasswordChangeRequired(user, time.Now()) {
+		return nil, errPasswordChangeRequired
+	}
+	if err := auth.AddGroupProjects(ctx, s.client, user); err != nil {
+		return nil, err
+	}
+	return auth.APIKeyClaims(user, apiKey), nil
+}
+
+// apiKeyOwner returns the User of the caller, replying with an error when there is none.
+// Keys are managed with a login token only, so that a leaked key cannot mint others
+func (s *Server) apiKeyOwner(w http.ResponseWriter, r *http.Request) (*llmcloudv1alpha1.User, bool) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if claims.APIKey != "" {
+		writeProblem(w, "API keys cannot manage API keys", http.StatusForbidden)
+		return nil, false
+	}
+	if claims.Provider != "" {
+		writeProblem(w, "API keys are only available to local users", http.StatusForbidden)
+		return nil, false
+	}
+	user, err := auth.LookupUser(r.Context(), s.client, claims.Username)
+	if err != nil {
+		writeProblem(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	return user, true
+}
+
+// handleMyAPIKeys handles /api/v1/me/apikeys. GET lists the caller's API keys and POST
+// creates one from {"name", "scopes", "ttl"}, returning the key. Scopes default to
+// read, and the key never expires without a ttl duration such as "2160h"
+func (s *Server) handleMyAPIKeys(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		keys := make([]apiKeyInfo, 0, len(user.Spec.APIKeys))
+		for i := range user.Spec.APIKeys {
+			keys = append(keys, newAPIKeyInfo(&user.Spec.APIKeys[i]))
+		}
+		s.writeJSON(w, keys)
+
+	case http.MethodPost:
+		var req struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+			TTL    string   `json:"ttl"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			writeProblem(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if slices.ContainsFunc(user.Spec.APIKeys, func(k llmcloudv1alpha1.APIKey) bool { return k.Name == req.Name }) {
+			writeProblem(w, "API key "+req.Name+" already exists", http.StatusConflict)
+			return
+		}
+		if len(req.Scopes) == 0 {
+			req.Scopes = []string{llmcloudv1alpha1.APIKeyScopeRead}
+		}
+		for _, scope := range req.Scopes {
+			if scope != llmcloudv1alpha1.APIKeyScopeRead && scope != llmcloudv1alpha1.APIKeyScopeWrite {
+				writeProblem(w, "scopes must be read or write", http.StatusBadRequest)
+				return
+			}
+		}
+
+		key, hash, err := auth.GenerateAPIKey()
+		if err != nil {
+			writeProblem(w, "Failed to generate API key", http.StatusInternalServerError)
+			return
+		}
+		now := metav1.Now()
+		apiKey := llmcloudv1alpha1.APIKey{
+			Name:      req.Name,
+			Hash:      hash,
+			Prefix:    key[:apiKeyPrefixLength],
+			Scopes:    req.Scopes,
+			CreatedAt: &now,
+		}
+		if req.TTL != "" {
+			ttl, err := time.ParseDuration(req.TTL)
+			if err != nil || ttl <= 0 {
+				writeProblem(w, "ttl must be a positive duration such as 2160h", http.StatusBadRequest)
+				return
+			}
+			expiresAt := metav1.NewTime(now.Add(ttl))
+			apiKey.ExpiresAt = &expiresAt
+		}
+		user.Spec.APIKeys = append(user.Spec.APIKeys, apiKey)
+		if err := s.client.Update(r.Context(), user); err != nil {
+			writeError(w, "Failed to create API key", err)
+			return
+		}
+
+		info := newAPIKeyInfo(&apiKey)
+		info.Key = key
+		s.writeJSON(w, info)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleMyAPIKey handles /api/v1/me/apikeys/{name}: GET describes one of the caller's
+// API keys and DELETE revokes it
+func (s *Server) handleMyAPIKey(w http.ResponseWriter, r *http.Request) {
+	user, ok := s.apiKeyOwner(w, r)
+	if !ok {
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/api/v1/me/apikeys/")
+	i := slices.IndexFunc(user.Spec.APIKeys, func(k llmcloudv1alpha1.APIKey) bool { return k.Name == name })
+	if i < 0 {
+		writeProblem(w, "API key not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, newAPIKeyInfo(&user.Spec.APIKeys[i]))
+
+	case http.MethodDelete:
+		user.Spec.APIKeys = slices.Delete(user.Spec.APIKeys, i, i+1)
+		if err := s.client.Update(r.Context(), user); err != nil {
+			writeError(w, "Failed to delete API key", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestHandleMyAPIKeys(t *testing.T) {
+	c := setupTestClient()
+	if err := c.Create(context.Background(), &llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec:       llmcloudv1alpha1.UserSpec{Username: "alice", Projects: []string{"team"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{client: c}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	create := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.handleMyAPIKeys(w, withClaims(httptest.NewRequest("POST", "/api/v1/me/apikeys", strings.NewReader(body)), alice))
+		return w
+	}
+	w := create(`{"name": "ci", "ttl": "720h"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var created apiKeyInfo
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Key, created.Prefix) || created.ExpiresAt == nil ||
+		len(created.Scopes) != 1 || created.Scopes[0] != llmcloudv1alpha1.APIKeyScopeRead {
+		t.Errorf("Expected a read key expiring in 30 days, got %+v", created)
+	}
+
+	for body, code := range map[string]int{
+		`{"name": "ci"}`:                        http.StatusConflict,
+		`{"scopes": ["read"]}`:                  http.StatusBadRequest,
+		`{"name": "admin", "scopes": ["root"]}`: http.StatusBadRequest,
+		`{"name": "old", "ttl": "forever"}`:     http.StatusBadRequest,
+	} {
+		if w := create(body); w.Code != code {
+			t.Errorf("%s: expected status %d, got %d", body, code, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleMyAPIKeys(w, withClaims(httptest.NewRequest("GET", "/api/v1/me/apikeys", nil), alice))
+	if strings.Contains(w.Body.String(), created.Key) || !strings.Contains(w.Body.String(), created.Prefix) {
+		t.Errorf("Expected the key listed by its prefix only, got %s", w.Body.String())
+	}
+
+	for _, claims := range []*auth.Claims{
+		{Username: "alice", APIKey: "ci"},
+		{Username: "alice", Provider: "google"},
+	} {
+		w := httptest.NewRecorder()
+		s.handleMyAPIKeys(w, withClaims(httptest.NewRequest("GET", "/api/v1/me/apikeys", nil), claims))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("Expected status Forbidden for %+v, got %d", claims, w.Code)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleMyAPIKey(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/me/apikeys/ci", nil), alice))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status No Content, got %d: %s", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	s.handleMyAPIKey(w, withClaims(httptest.NewRequest("GET", "/api/v1/me/apikeys/ci", nil), alice))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected the deleted key not found, got %d", w.Code)
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	readKey, readHash, _ := auth.GenerateAPIKey()
+	expiredKey, expiredHash, _ := auth.GenerateAPIKey()
+	past := metav1.Now()
+	c := setupTestClient()
+	if err := c.Create(context.Background(), &llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: llmcloudv1alpha1.UserSpec{Username: "alice", APIKeys: []llmcloudv1alpha1.APIKey{
+			{Name: "dashboard", Hash: readHash, Scopes: []string{"read"}},
+			{Name: "old", Hash: expiredHash, Scopes: []string{"write"}, ExpiresAt: &past},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{client: c}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		code   int
+	}{
+		{"read key lists projects", "GET", "/api/v1/projects", readKey, http.StatusOK},
+		{"read key cannot write", "POST", "/api/v1/projects", readKey, http.StatusForbidden},
+		{"key cannot mint tokens", "POST", "/api/v1/auth/refresh", readKey, http.StatusForbidden},
+		{"expired key", "GET", "/api/v1/projects", expiredKey, http.StatusUnauthorized},
+		{"unknown key", "GET", "/api/v1/projects", "llmc_unknown", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"name": "team"}`))
+			req.Header.Set("X-API-Key", tt.key)
+			w := httptest.NewRecorder()
+			s.handleAPI(w, req)
+			if w.Code != tt.code {
+				t.Errorf("Expected status %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAPIKeyPasswordChangeRequired(t *testing.T) {
+	key, hash, _ := auth.GenerateAPIKey()
+	c := setupTestClient()
+	if err := c.Create(context.Background(), &llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: llmcloudv1alpha1.UserSpec{Username: "alice", MustChangePassword: true, APIKeys: []llmcloudv1alpha1.APIKey{
+			{Name: "ci", Hash: hash, Scopes: []string{"read", "write"}},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{client: c}
+
+	req := httptest.NewRequest("GET", "/api/v1/projects", nil)
+	req.Header.Set("X-API-Key", key)
+	w := httptest.NewRecorder()
+	s.handleAPI(w, req)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), `"reason":"PasswordChangeRequired"`) {
+		t.Errorf("Expected the key to require a password change, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleUserKeepsHashes(t *testing.T) {
+	c := setupTestClient()
+	if err := c.Create(context.Background(), &llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec: llmcloudv1alpha1.UserSpec{Username: "alice", PasswordHash: "bcrypt-hash", APIKeys: []llmcloudv1alpha1.APIKey{
+			{Name: "ci", Hash: strings.Repeat("a", 64), Scopes: []string{llmcloudv1alpha1.APIKeyScopeRead}},
+			{Name: "deploy", Hash: strings.Repeat("b", 64), Scopes: []string{llmcloudv1alpha1.APIKeyScopeWrite}},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{client: c}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	do := func(method, body string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest(method, "/api/v1/users/alice", strings.NewReader(body)), admin)
+		req.SetPathValue("name", "alice")
+		w := httptest.NewRecorder()
+		s.handleUser(w, req)
+		return w
+	}
+
+	w := do("GET", "")
+	if strings.Contains(w.Body.String(), "bcrypt-hash") || strings.Contains(w.Body.String(), strings.Repeat("a", 64)) {
+		t.Errorf("Expected the hashes not to be returned, got %s", w.Body.String())
+	}
+	var user llmcloudv1alpha1.User
+	if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	// The user as read back is stored with its hashes intact
+	user.Spec.Email = "alice@example.com"
+	body, _ := json.Marshal(user)
+	if w := do("PUT", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	stored := &llmcloudv1alpha1.User{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "alice"}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Spec.Email != "alice@example.com" || stored.Spec.PasswordHash != "bcrypt-hash" ||
+		len(stored.Spec.APIKeys) != 2 || stored.Spec.APIKeys[0].Hash != strings.Repeat("a", 64) {
+		t.Errorf("Expected the update to keep the hashes, got %+v", stored.Spec)
+	}
+
+	// Leaving a key out revokes it
+	if w := do("PUT", `{"metadata": {"resourceVersion": "`+stored.ResourceVersion+`"},
+		"spec": {"username": "alice", "apiKeys": [{"name": "deploy"}]}}`); w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "alice"}, stored); err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Spec.APIKeys) != 1 || stored.Spec.APIKeys[0].Hash != strings.Repeat("b", 64) ||
+		stored.Spec.PasswordHash != "bcrypt-hash" {
+		t.Errorf("Expected only the deploy key to be kept, got %+v", stored.Spec)
+	}
+
+	for _, body := range []string{
+		`{"metadata": {"name": "bob"}, "spec": {"username": "alice"}}`,
+		`{"spec": {"username": "alice", "apiKeys": [{"name": "new", "hash": "` + strings.Repeat("c", 64) + `"}]}}`,
+	} {
+		if w := do("PUT", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status Bad Request, got %d", body, w.Code)
+		}
+	}
+}
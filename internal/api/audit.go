@@ -0,0 +1,206 @@
+package api
+
+import (
+	"cmp"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// Limits on the number of audit events /api/v1/audit returns at once
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// unauditedRoutes are the route prefixes whose non-GET requests change nothing, or
+// whose handlers record an event for each object they change, and so are not recorded
+var unauditedRoutes = []string{
+	"/api/v1/inference/",
+	"/api/v1/preview/",
+	"/api/v1/bulk/",
+}
+
+// auditedRequest reports whether a request changes something and should be audited.
+// Dry runs change nothing
+func auditedRequest(r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		return false
+	}
+	if dryRun, _ := parseDryRun(r); dryRun {
+		return false
+	}
+	return !slices.ContainsFunc(unauditedRoutes, func(prefix string) bool {
+		return strings.HasPrefix(r.URL.Path, prefix)
+	})
+}
+
+// statusRecorder remembers the status code a handler responded with
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the underlying writer, if it supports it, so streams such as watches
+// can be recorded too
+func (w *statusRecorder) Flush() {
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to hijack the
+// connection of a console
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditEvent describes the request r by user, answered with status, as an audit event.
+// The action and the object acted on are derived from the request path
+func auditEvent(r *http.Request, user string, status int) llmcloudv1alpha1.AuditEventSpec {
+	event := llmcloudv1alpha1.AuditEventSpec{
+		User:       user,
+		SourceIP:   sourceIP(r),
+		Path:       r.URL.Path,
+		StatusCode: int32(status),
+	}
+	switch r.Method {
+	case http.MethodPost:
+		event.Action = "create"
+	case http.MethodPut, http.MethodPatch:
+		event.Action = "update"
+	case http.MethodDelete:
+		event.Action = "delete"
+	default:
+		event.Action = strings.ToLower(r.Method)
+	}
+
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/")
+	segment := func(i int) string {
+		if i < len(segments) {
+			return segments[i]
+		}
+		return ""
+	}
+	switch segment(0) {
+	case "namespaces":
+		event.Namespace, event.Resource, event.Name = segment(1), segment(2), segment(3)
+	case "actions":
+		event.Resource = map[string]string{"vm": "virtualmachines", "model": "llmmodels"}[segment(1)]
+		event.Namespace, event.Name, event.Action = segment(2), segment(3), segment(4)
+	case "auth":
+		event.Action = segment(1)
+	default:
+		event.Resource, event.Name = segment(0), segment(1)
+	}
+	return event
+}
+
+// sourceIP is the address r came from, without its port
+func sourceIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// handleAudit lists audit events, newest first (admin only). The "user", "action",
+// "resource" and "namespace" query parameters filter by exact match, "since" and
+// "until" by time (RFC 3339), and "limit" and "offset" page through the results
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	var since, until time.Time
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"since", &since}, {"until", &until}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeProblem(w, bound.name+" must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		*bound.t = t
+	}
+	limit, offset := defaultAuditLimit, 0
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			writeProblem(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxAuditLimit)
+	}
+	if value := query.Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeProblem(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	var events llmcloudv1alpha1.AuditEventList
+	if err := s.client.List(r.Context(), &events); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	items := slices.DeleteFunc(events.Items, func(event llmcloudv1alpha1.AuditEvent) bool {
+		spec := event.Spec
+		for param, value := range map[string]string{
+			"user":      spec.User,
+			"action":    spec.Action,
+			"resource":  spec.Resource,
+			"namespace": spec.Namespace,
+		} {
+			if want := query.Get(param); want != "" && want != value {
+				return true
+			}
+		}
+		return (!since.IsZero() && spec.Time.Time.Before(since)) || (!until.IsZero() && spec.Time.Time.After(until))
+	})
+	slices.SortFunc(items, func(a, b llmcloudv1alpha1.AuditEvent) int {
+		return cmp.Or(b.Spec.Time.Compare(a.Spec.Time.Time), strings.Compare(a.Name, b.Name))
+	})
+
+	total := len(items)
+	page := items[min(offset, total):min(offset+limit, total)]
+	result := make([]llmcloudv1alpha1.AuditEventSpec, 0, len(page))
+	for _, event := range page {
+		result = append(result, event.Spec)
+	}
+	s.writeJSON(w, map[string]interface{}{
+		"items":  result,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuditEvent(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   llmcloudv1alpha1.AuditEventSpec
+	}{
+		{
+			method: "DELETE",
+			path:   "/api/v1/namespaces/project-team/virtualmachines/web",
+			want:   llmcloudv1alpha1.AuditEventSpec{Action: "delete", Resource: "virtualmachines", Namespace: "project-team", Name: "web"},
+		},
+		{
+			method: "POST",
+			path:   "/api/v1/actions/vm/project-team/web/start",
+			want:   llmcloudv1alpha1.AuditEventSpec{Action: "start", Resource: "virtualmachines", Namespace: "project-team", Name: "web"},
+		},
+		{
+			method: "PUT",
+			path:   "/api/v1/users/bob",
+			want:   llmcloudv1alpha1.AuditEventSpec{Action: "update", Resource: "users", Name: "bob"},
+		},
+		{
+			method: "POST",
+			path:   "/api/v1/auth/logout",
+			want:   llmcloudv1alpha1.AuditEventSpec{Action: "logout"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.RemoteAddr = "10.0.0.7:51234"
+			got := auditEvent(req, "alice", http.StatusOK)
+
+			tt.want.User, tt.want.SourceIP, tt.want.Path, tt.want.StatusCode = "alice", "10.0.0.7", tt.path, http.StatusOK
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuditRecordsRequests(t *testing.T) {
+	if err := auth.InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	c := setupTestClient()
+	s := &Server{client: c, Audit: &audit.Recorder{Client: c}}
+	token, err := auth.GenerateJWT(&llmcloudv1alpha1.User{
+		Spec: llmcloudv1alpha1.UserSpec{Username: "root", IsAdmin: true},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	for _, method := range []string{"GET", "POST"} {
+		req := httptest.NewRequest(method, "/api/v1/projects", strings.NewReader(`{"name": "team"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		s.handleAPI(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", strings.NewReader(`{"username": "mallory", "password": "guess"}`))
+	s.handleAPI(httptest.NewRecorder(), req)
+
+	var events llmcloudv1alpha1.AuditEventList
+	if err := c.List(context.Background(), &events); err != nil {
+		t.Fatalf("Failed to list audit events: %v", err)
+	}
+	got := map[string]llmcloudv1alpha1.AuditEventSpec{}
+	for _, event := range events.Items {
+		got[event.Spec.Action] = event.Spec
+	}
+	if len(events.Items) != 2 {
+		t.Fatalf("Expected the project creation and the login to be audited, got %+v", got)
+	}
+	if create := got["create"]; create.User != "root" || create.Resource != "projects" || create.StatusCode >= 300 {
+		t.Errorf("Unexpected audit event for project creation: %+v", create)
+	}
+	if login := got["login"]; login.User != "mallory" || login.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Unexpected audit event for failed login: %+v", login)
+	}
+}
+
+func TestHandleAudit(t *testing.T) {
+	c := setupTestClient()
+	base := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i, spec := range []llmcloudv1alpha1.AuditEventSpec{
+		{User: "alice", Action: "login"},
+		{User: "alice", Action: "create", Resource: "virtualmachines", Namespace: "project-team", Name: "web"},
+		{User: "bob", Action: "delete", Resource: "virtualmachines", Namespace: "project-team", Name: "db"},
+		{User: "alice", Action: "delete", Resource: "virtualmachines", Namespace: "project-team", Name: "web"},
+	} {
+		spec.Time = metav1.NewTime(base.Add(time.Duration(i) * time.Minute))
+		event := &llmcloudv1alpha1.AuditEvent{ObjectMeta: metav1.ObjectMeta{GenerateName: "audit-"}, Spec: spec}
+		if err := c.Create(context.Background(), event); err != nil {
+			t.Fatalf("Failed to create audit event: %v", err)
+		}
+	}
+	s := &Server{client: c}
+
+	tests := []struct {
+		name      string
+		query     string
+		wantNames []string
+		wantTotal int
+	}{
+		{name: "all, newest first", query: "", wantNames: []string{"web", "db", "web", ""}, wantTotal: 4},
+		{name: "by user and action", query: "?user=alice&action=delete", wantNames: []string{"web"}, wantTotal: 1},
+		{name: "since", query: "?since=2025-06-01T12:02:00Z", wantNames: []string{"web", "db"}, wantTotal: 2},
+		{name: "paged", query: "?resource=virtualmachines&limit=1&offset=1", wantNames: []string{"db"}, wantTotal: 3},
+		{name: "past the end", query: "?offset=10", wantNames: []string{}, wantTotal: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/audit"+tt.query, nil)
+			req = req.WithContext(context.WithValue(req.Context(), claimsKey, &auth.Claims{Username: "root", IsAdmin: true}))
+			w := httptest.NewRecorder()
+			s.handleAudit(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp struct {
+				Items []llmcloudv1alpha1.AuditEventSpec `json:"items"`
+				Total int                               `json:"total"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			names := []string{}
+			for _, item := range resp.Items {
+				names = append(names, item.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") || resp.Total != tt.wantTotal {
+				t.Errorf("Expected %v of %d, got %v of %d", tt.wantNames, tt.wantTotal, names, resp.Total)
+			}
+		})
+	}
+}
+
+func TestHandleAuditRequiresAdmin(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	req := httptest.NewRequest("GET", "/api/v1/audit", nil)
+	req = req.WithContext(context.WithValue(req.Context(), claimsKey, &auth.Claims{Username: "alice"}))
+	w := httptest.NewRecorder()
+	s.handleAudit(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected status Forbidden, got %d", w.Code)
+	}
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/backup"
+)
+
+// maxBackupSize bounds the backups that can be restored through the API
+const maxBackupSize = 64 << 20
+
+// handleBackup downloads a backup of the cluster's llmcloud resources or restores the
+// backup in the request body. Only admins may do either, and not with a read-only token
+// since the backup carries every referenced Secret and the users' password hashes
+func (s *Server) handleBackup(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin || claims.ReadOnly {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeBackup(w, r, backup.Options{})
+
+	case http.MethodPost:
+		manifest, err := backup.Restore(ctx, s.client, http.MaxBytesReader(w, r.Body, maxBackupSize), backup.RestoreOptions{})
+		if errors.Is(err, backup.ErrInvalidBackup) {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, manifest)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBackupSnapshots takes VM disk snapshots and downloads a backup that references
+// them. It is a POST of its own so that downloading a backup has no side effects
+func (s *Server) handleBackupSnapshots(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin || claims.ReadOnly {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	s.writeBackup(w, r, backup.Options{SnapshotVMs: true})
+}
+
+// writeBackup exports a backup with opts as a gzipped tarball download
+func (s *Server) writeBackup(w http.ResponseWriter, r *http.Request, opts backup.Options) {
+	// The backup is buffered so that a failure is reported as an error, not as a
+	// truncated download
+	var data bytes.Buffer
+	manifest, err := backup.Export(r.Context(), s.client, &data, opts)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=llmcloud-backup-%s.tar.gz",
+		manifest.CreatedAt.Format("20060102-150405")))
+	_, _ = w.Write(data.Bytes())
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleBackup(t *testing.T) {
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	source := &Server{client: setupTestClient()}
+	if err := source.client.Create(context.Background(), &llmcloudv1alpha1.VMTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "small"},
+		Spec:       llmcloudv1alpha1.VMTemplateSpec{CPUs: 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	source.handleBackup(w, withClaims(httptest.NewRequest("GET", "/api/v1/backup", nil),
+		&auth.Claims{Username: "alice", Projects: []string{"team"}}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected users to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	source.handleBackup(w, withClaims(httptest.NewRequest("GET", "/api/v1/backup", nil),
+		&auth.Claims{Username: "root", IsAdmin: true, ReadOnly: true}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected read-only admin tokens to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	source.handleBackup(w, withClaims(httptest.NewRequest("GET", "/api/v1/backup", nil), admin))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("Expected a backup, got %d: %s", w.Code, w.Body.String())
+	}
+	backup := w.Body.Bytes()
+
+	target := &Server{client: setupTestClient()}
+	w = httptest.NewRecorder()
+	target.handleBackup(w, withClaims(httptest.NewRequest("POST", "/api/v1/backup", bytes.NewReader(backup)), admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the backup to be restored, got %d: %s", w.Code, w.Body.String())
+	}
+	template := &llmcloudv1alpha1.VMTemplate{}
+	if err := target.client.Get(context.Background(), client.ObjectKey{Name: "small"}, template); err != nil || template.Spec.CPUs != 2 {
+		t.Errorf("Expected the template to be restored, got %+v, %v", template, err)
+	}
+
+	w = httptest.NewRecorder()
+	target.handleBackup(w, withClaims(httptest.NewRequest("POST", "/api/v1/backup", strings.NewReader("junk")), admin))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an invalid backup to be rejected, got %d", w.Code)
+	}
+}
+
+func TestHandleBackupSnapshots(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+
+	w := httptest.NewRecorder()
+	s.handleBackupSnapshots(w, withClaims(httptest.NewRequest("POST", "/api/v1/backup/snapshots", nil),
+		&auth.Claims{Username: "root", IsAdmin: true, ReadOnly: true}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected read-only admin tokens to be forbidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleBackupSnapshots(w, withClaims(httptest.NewRequest("POST", "/api/v1/backup/snapshots", nil),
+		&auth.Claims{Username: "root", IsAdmin: true}))
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/gzip" {
+		t.Errorf("Expected a backup, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+const (
+	// maxBulkItems is the most resources one bulk request may act on
+	maxBulkItems = 100
+
+	// bulkConcurrency is how many items of a bulk request are acted on at once
+	bulkConcurrency = 8
+)
+
+// bulkItem names a resource a bulk request acts on
+type bulkItem struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// bulkRequest is the body of POST /api/v1/bulk/{resource}/{action}
+type bulkRequest struct {
+	Items []bulkItem `json:"items"`
+	// DeleteData also deletes the data disks of deleted VMs, which are kept otherwise
+	DeleteData bool `json:"deleteData,omitempty"`
+}
+
+// bulkResult is the outcome of a bulk action on one item. Status is the HTTP status the
+// single-resource endpoint would have answered with, and Error its problem detail
+type bulkResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// bulkResponse is the response of POST /api/v1/bulk/{resource}/{action}, with a result
+// for each item in the order of the request
+type bulkResponse struct {
+	Resource  string       `json:"resource"`
+	Action    string       `json:"action"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+// bulkResources maps the resources of /api/v1/bulk to the resource name of audit events
+var bulkResources = map[string]string{"vms": "virtualmachines", "models": "llmmodels"}
+
+// handleBulk handles POST /api/v1/bulk/{resource}/{action}, taking an action of
+// /api/v1/actions, or delete, on many VMs or models at once. The items are acted on
+// concurrently and each gets its own result, so some may fail while others succeed;
+// the request itself only fails when it is malformed
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	resource, action := r.PathValue("resource"), r.PathValue("action")
+
+	var actions []string
+	switch resource {
+	case "vms":
+		actions = vmActions
+	case "models":
+		actions = modelActions
+	default:
+		writeProblem(w, "Unknown resource, valid resources: vms, models", http.StatusNotFound)
+		return
+	}
+	if action != "delete" && !slices.Contains(actions, action) {
+		writeProblem(w, fmt.Sprintf("Unknown action, valid actions: %s, delete", strings.Join(actions, ", ")),
+			http.StatusBadRequest)
+		return
+	}
+
+	var req bulkRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		writeProblem(w, "items is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > maxBulkItems {
+		writeProblem(w, fmt.Sprintf("At most %d items may be acted on at once", maxBulkItems), http.StatusBadRequest)
+		return
+	}
+	for i, item := range req.Items {
+		if item.Namespace == "" || item.Name == "" {
+			writeProblem(w, fmt.Sprintf("items[%d]: namespace and name are required", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	response := bulkResponse{Resource: resource, Action: action, Results: make([]bulkResult, len(req.Items))}
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, bulkConcurrency)
+	for i, item := range req.Items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result := bulkResult{Namespace: item.Namespace, Name: item.Name, Status: http.StatusOK}
+			if !canAccessNamespace(claims, item.Namespace) {
+				result.Status, result.Error = http.StatusForbidden, "Forbidden"
+			} else if p := s.bulkAction(r.Context(), resource, action, item, req.DeleteData); p != nil {
+				result.Status, result.Error = p.Status, p.Detail
+			}
+			response.Results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range response.Results {
+		if result.Status < 300 {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+		if s.Audit != nil {
+			event := auditEvent(r, claims.Username, result.Status)
+			event.Resource, event.Namespace, event.Name, event.Action = bulkResources[resource], result.Namespace, result.Name, action
+			s.Audit.Record(r.Context(), event)
+		}
+	}
+	s.writeJSON(w, response)
+}
+
+// bulkAction takes action on one item of a bulk request the way the single-resource
+// endpoints do, returning the problem they would reply with, or nil on success
+func (s *Server) bulkAction(ctx context.Context, resource, action string, item bulkItem, deleteData bool) *problem {
+	key := client.ObjectKey{Namespace: item.Namespace, Name: item.Name}
+	var obj client.Object
+	switch resource {
+	case "vms":
+		obj = &llmcloudv1alpha1.VirtualMachine{}
+	case "models":
+		obj = &llmcloudv1alpha1.LLMModel{}
+	}
+	if err := s.client.Get(ctx, key, obj); err != nil {
+		p := errorProblem("", err)
+		return &p
+	}
+
+	if action == "delete" {
+		var err error
+		if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok {
+			// Like a single delete, the VM's data disks are kept unless asked otherwise
+			err = s.deleteVM(ctx, vm, deleteData)
+		} else {
+			err = s.client.Delete(ctx, obj)
+		}
+		if err != nil {
+			p := errorProblem("", err)
+			return &p
+		}
+		return nil
+	}
+
+	var status int
+	var err error
+	switch obj := obj.(type) {
+	case *llmcloudv1alpha1.VirtualMachine:
+		status, err = applyVMAction(obj, action)
+	case *llmcloudv1alpha1.LLMModel:
+		status, err = applyModelAction(obj, action)
+	}
+	if err != nil {
+		return &problem{Status: status, Detail: err.Error()}
+	}
+	if err := s.client.Update(ctx, obj); err != nil {
+		p := errorProblem("", err)
+		return &p
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// bulkRequestFor returns a request for POST /api/v1/bulk/{resource}/{action} by claims
+func bulkRequestFor(resource, action, body string, claims *auth.Claims) *http.Request {
+	req := httptest.NewRequest("POST", "/api/v1/bulk/"+resource+"/"+action, strings.NewReader(body))
+	req.SetPathValue("resource", resource)
+	req.SetPathValue("action", action)
+	return withClaims(req, claims)
+}
+
+func TestHandleBulkVMs(t *testing.T) {
+	c := setupTestClient()
+	s := &Server{client: c, Audit: &audit.Recorder{Client: c}}
+	ctx := context.Background()
+	for _, name := range []string{"web", "db"} {
+		vm := &llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-team"}}
+		if err := c.Create(ctx, vm); err != nil {
+			t.Fatalf("Failed to create VM: %v", err)
+		}
+	}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	w := httptest.NewRecorder()
+	s.handleBulk(w, bulkRequestFor("vms", "stop", `{"items": [
+		{"namespace": "project-team", "name": "web"},
+		{"namespace": "project-team", "name": "db"},
+		{"namespace": "project-team", "name": "missing"},
+		{"namespace": "project-other", "name": "web"}]}`, alice))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var response bulkResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Succeeded != 2 || response.Failed != 2 {
+		t.Errorf("Expected 2 VMs stopped and 2 failures, got %+v", response)
+	}
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusNotFound, http.StatusForbidden} {
+		if got := response.Results[i]; got.Status != want {
+			t.Errorf("Expected status %d for %s/%s, got %+v", want, got.Namespace, got.Name, got)
+		}
+	}
+	for _, name := range []string{"web", "db"} {
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: name}, vm); err != nil {
+			t.Fatalf("Failed to get VM: %v", err)
+		}
+		if vm.Spec.RunStrategy != "Halted" {
+			t.Errorf("Expected VM %s to be halted, got %q", name, vm.Spec.RunStrategy)
+		}
+	}
+
+	var events llmcloudv1alpha1.AuditEventList
+	if err := c.List(ctx, &events); err != nil {
+		t.Fatalf("Failed to list audit events: %v", err)
+	}
+	if len(events.Items) != 4 {
+		t.Fatalf("Expected an audit event for each VM, got %d", len(events.Items))
+	}
+	for _, event := range events.Items {
+		if event.Spec.Action != "stop" || event.Spec.Resource != "virtualmachines" || event.Spec.Name == "" {
+			t.Errorf("Unexpected audit event: %+v", event.Spec)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleBulk(w, bulkRequestFor("vms", "delete",
+		`{"items": [{"namespace": "project-team", "name": "web"}, {"namespace": "project-team", "name": "db"}]}`, alice))
+	response = bulkResponse{}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Succeeded != 2 {
+		t.Errorf("Expected both VMs to be deleted, got %+v", response)
+	}
+	err := c.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "web"}, &llmcloudv1alpha1.VirtualMachine{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the VM to be deleted, got %v", err)
+	}
+}
+
+func TestHandleBulkModels(t *testing.T) {
+	c := setupTestClient()
+	s := &Server{client: c}
+	ctx := context.Background()
+	model := &llmcloudv1alpha1.LLMModel{
+		ObjectMeta: metav1.ObjectMeta{Name: "mistral", Namespace: "project-ml"},
+		Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "mistral"},
+	}
+	if err := c.Create(ctx, model); err != nil {
+		t.Fatalf("Failed to create model: %v", err)
+	}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+
+	for _, tt := range []struct {
+		action  string
+		stopped bool
+	}{{"stop", true}, {"start", false}} {
+		w := httptest.NewRecorder()
+		s.handleBulk(w, bulkRequestFor("models", tt.action, `{"items": [{"namespace": "project-ml", "name": "mistral"}]}`, admin))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+		}
+		if err := c.Get(ctx, client.ObjectKeyFromObject(model), model); err != nil {
+			t.Fatalf("Failed to get model: %v", err)
+		}
+		if model.Spec.Stopped != tt.stopped {
+			t.Errorf("Expected stopped %v after %s, got %v", tt.stopped, tt.action, model.Spec.Stopped)
+		}
+	}
+}
+
+func TestHandleBulkInvalid(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	var tooMany []string
+	for i := 0; i <= maxBulkItems; i++ {
+		tooMany = append(tooMany, fmt.Sprintf(`{"namespace": "project-team", "name": "vm-%d"}`, i))
+	}
+
+	for name, tt := range map[string]struct {
+		resource, action, body string
+		want                   int
+	}{
+		"unknown resource": {"services", "stop", `{"items": [{"namespace": "project-team", "name": "web"}]}`, http.StatusNotFound},
+		"unknown action":   {"models", "reboot", `{"items": [{"namespace": "project-team", "name": "web"}]}`, http.StatusBadRequest},
+		"no items":         {"vms", "start", `{"items": []}`, http.StatusBadRequest},
+		"missing name":     {"vms", "start", `{"items": [{"namespace": "project-team"}]}`, http.StatusBadRequest},
+		"too many items":   {"vms", "start", `{"items": [` + strings.Join(tooMany, ",") + `]}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		s.handleBulk(w, bulkRequestFor(tt.resource, tt.action, tt.body, admin))
+		if w.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d: %s", name, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+)
+
+// resourceUsage reports a resource of a node: how much pods may request, how much the
+// pods scheduled to it request, and what is left
+type resourceUsage struct {
+	Allocatable resource.Quantity `json:"allocatable"`
+	Requested   resource.Quantity `json:"requested"`
+	Free        resource.Quantity `json:"free"`
+}
+
+// nodeInventory reports the resources of a node and the workloads running on it
+type nodeInventory struct {
+	Name string `json:"name"`
+	// Schedulable is false for a node that is cordoned, not ready, or tainted against
+	// new pods other than GPU workloads
+	Schedulable bool          `json:"schedulable"`
+	CPU         resourceUsage `json:"cpu"`
+	Memory      resourceUsage `json:"memory"`
+	GPUs        resourceUsage `json:"gpus"`
+	VMIs        int           `json:"vmis"`
+	ModelPods   int           `json:"modelPods"`
+}
+
+// inventoryNodes reports the resources of nodes, in list order, with the requests of the
+// pods that hold resources on them
+func inventoryNodes(nodes, pods []unstructured.Unstructured) []nodeInventory {
+	type requests struct {
+		cpu, memory     resource.Quantity
+		gpus            int64
+		vmis, modelPods int
+	}
+	byNode := map[string]*requests{}
+	for _, pod := range pods {
+		nodeName, _, _ := unstructured.NestedString(pod.Object, "spec", "nodeName")
+		phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase")
+		if nodeName == "" || phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		r := byNode[nodeName]
+		if r == nil {
+			r = &requests{}
+			byNode[nodeName] = r
+		}
+		r.cpu.Add(podRequest(pod.Object, string(corev1.ResourceCPU)))
+		r.memory.Add(podRequest(pod.Object, string(corev1.ResourceMemory)))
+		r.gpus += podGPURequest(pod.Object)
+		labels := pod.GetLabels()
+		if _, ok := labels[logLabels["vms"]]; ok {
+			r.vmis++
+		}
+		if _, ok := labels[logLabels["models"]]; ok {
+			r.modelPods++
+		}
+	}
+
+	inventory := make([]nodeInventory, 0, len(nodes))
+	for _, node := range nodes {
+		r := byNode[node.GetName()]
+		if r == nil {
+			r = &requests{}
+		}
+		var gpus int64
+		for _, name := range gpuResourceNames {
+			allocatable := controller.NestedQuantity(node.Object, "status", "allocatable", name)
+			gpus += allocatable.Value()
+		}
+		inventory = append(inventory, nodeInventory{
+			Name:        node.GetName(),
+			Schedulable: nodeSchedulable(node.Object),
+			CPU:         newResourceUsage(controller.NestedQuantity(node.Object, "status", "allocatable", string(corev1.ResourceCPU)), r.cpu),
+			Memory:      newResourceUsage(controller.NestedQuantity(node.Object, "status", "allocatable", string(corev1.ResourceMemory)), r.memory),
+			GPUs:        newResourceUsage(*resource.NewQuantity(gpus, resource.DecimalSI), *resource.NewQuantity(r.gpus, resource.DecimalSI)),
+			VMIs:        r.vmis,
+			ModelPods:   r.modelPods,
+		})
+	}
+	return inventory
+}
+
+// newResourceUsage returns the usage of a resource of which requested out of allocatable
+// is taken. Free is never negative, even on an overcommitted node
+func newResourceUsage(allocatable, requested resource.Quantity) resourceUsage {
+	free := allocatable.DeepCopy()
+	free.Sub(requested)
+	if free.Sign() < 0 {
+		free = *resource.NewQuantity(0, allocatable.Format)
+	}
+	return resourceUsage{Allocatable: allocatable, Requested: requested, Free: free}
+}
+
+// nodeSchedulable reports whether new pods can be scheduled to node: it is not cordoned,
+// is Ready and has no NoSchedule or NoExecute taint but the GPU taints, which GPU
+// workloads tolerate
+func nodeSchedulable(node map[string]interface{}) bool {
+	if unschedulable, _, _ := unstructured.NestedBool(node, "spec", "unschedulable"); unschedulable {
+		return false
+	}
+	taints, _, _ := unstructured.NestedSlice(node, "spec", "taints")
+	for _, t := range taints {
+		taint, _ := t.(map[string]interface{})
+		key, _ := taint["key"].(string)
+		effect, _ := taint["effect"].(string)
+		if (effect == string(corev1.TaintEffectNoSchedule) || effect == string(corev1.TaintEffectNoExecute)) &&
+			!slices.Contains(gpuResourceNames, key) {
+			return false
+		}
+	}
+	conditions, _, _ := unstructured.NestedSlice(node, "status", "conditions")
+	for _, c := range conditions {
+		condition, _ := c.(map[string]interface{})
+		if condition["type"] == string(corev1.NodeReady) {
+			return condition["status"] == string(corev1.ConditionTrue)
+		}
+	}
+	return false
+}
+
+// podRequest returns how much of the resource name a pod holds: the sum over its
+// containers, or the largest init container request if that is higher, plus the pod's
+// overhead. Limits are used when a container sets no request, as Kubernetes defaults
+// requests to limits
+func podRequest(pod map[string]interface{}, name string) resource.Quantity {
+	containerRequest := func(container map[string]interface{}) resource.Quantity {
+		q := controller.NestedQuantity(container, "resources", "requests", name)
+		if q.IsZero() {
+			q = controller.NestedQuantity(container, "resources", "limits", name)
+		}
+		return q
+	}
+
+	var total, initMax resource.Quantity
+	containers, _, _ := unstructured.NestedSlice(pod, "spec", "containers")
+	for _, c := range containers {
+		if container, ok := c.(map[string]interface{}); ok {
+			total.Add(containerRequest(container))
+		}
+	}
+	initContainers, _, _ := unstructured.NestedSlice(pod, "spec", "initContainers")
+	for _, c := range initContainers {
+		if container, ok := c.(map[string]interface{}); ok {
+			if q := containerRequest(container); q.Cmp(initMax) > 0 {
+				initMax = q
+			}
+		}
+	}
+	if initMax.Cmp(total) > 0 {
+		total = initMax
+	}
+	total.Add(controller.NestedQuantity(pod, "spec", "overhead", name))
+	return total
+}
+
+// capacityRequest is the VM a capacity estimate places
+type capacityRequest struct {
+	CPU    resource.Quantity `json:"cpu"`
+	Memory resource.Quantity `json:"memory"`
+	GPUs   int64             `json:"gpus"`
+}
+
+// nodeFit is how many VMs of a capacityRequest fit on a node
+type nodeFit struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+	// Reason names the resources that keep another VM off the node
+	Reason string `json:"reason,omitempty"`
+}
+
+// capacityEstimate is the response of GET /api/v1/capacity
+type capacityEstimate struct {
+	Request capacityRequest `json:"request"`
+	// Wanted is how many VMs were asked about, and Fits whether they all fit
+	Wanted int64 `json:"wanted"`
+	Fits   bool  `json:"fits"`
+	// Available is how many VMs of the request fit in the cluster at most
+	Available int64 `json:"available"`
+	// Nodes break Available down by node, for admins only
+	Nodes []nodeFit `json:"nodes,omitempty"`
+}
+
+// estimateCapacity places VMs of req on the schedulable nodes of inventory the way the
+// scheduler filters nodes: a node takes as many as its free CPU, memory and GPUs each
+// allow. As the VMs are identical, filling the nodes one by one places as many as any
+// other packing would
+func estimateCapacity(inventory []nodeInventory, req capacityRequest, wanted int64) capacityEstimate {
+	estimate := capacityEstimate{Request: req, Wanted: wanted}
+	for _, node := range inventory {
+		fit := nodeFit{Name: node.Name}
+		if !node.Schedulable {
+			fit.Reason = "node is not schedulable"
+			estimate.Nodes = append(estimate.Nodes, fit)
+			continue
+		}
+		fit.Count = -1
+		var short []string
+		limit := func(free, want int64, resource string) {
+			if want <= 0 {
+				return
+			}
+			switch n := free / want; {
+			case fit.Count < 0 || n < fit.Count:
+				fit.Count, short = n, []string{resource}
+			case n == fit.Count:
+				short = append(short, resource)
+			}
+		}
+		limit(node.CPU.Free.MilliValue(), req.CPU.MilliValue(), "cpu")
+		limit(node.Memory.Free.Value(), req.Memory.Value(), "memory")
+		limit(node.GPUs.Free.Value(), req.GPUs, "gpus")
+		fit.Reason = "insufficient " + strings.Join(short, " and ")
+		estimate.Available += fit.Count
+		estimate.Nodes = append(estimate.Nodes, fit)
+	}
+	estimate.Fits = estimate.Available >= wanted
+	return estimate
+}
+
+// parseCapacityRequest reads the VM to place and how many from the query of r: cpu and
+// memory as quantities, gpu and count as numbers. At least one resource must be asked
+// for, and count defaults to 1
+func parseCapacityRequest(r *http.Request) (capacityRequest, int64, error) {
+	var req capacityRequest
+	query := r.URL.Query()
+	quantities := []struct {
+		name string
+		q    *resource.Quantity
+	}{{"cpu", &req.CPU}, {"memory", &req.Memory}}
+	for _, param := range quantities {
+		name, q := param.name, param.q
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := resource.ParseQuantity(value)
+		if err != nil || parsed.Sign() < 0 {
+			return req, 0, fmt.Errorf("%s must be a quantity such as 8 or 32Gi", name)
+		}
+		*q = parsed
+	}
+	wanted := int64(1)
+	numbers := []struct {
+		name string
+		n    *int64
+	}{{"gpu", &req.GPUs}, {"count", &wanted}}
+	for _, param := range numbers {
+		name, n := param.name, param.n
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			return req, 0, fmt.Errorf("%s must be a non-negative number", name)
+		}
+		*n = parsed
+	}
+	if req.CPU.IsZero() && req.Memory.IsZero() && req.GPUs == 0 {
+		return req, 0, fmt.Errorf("at least one of cpu, memory and gpu is required")
+	}
+	return req, wanted, nil
+}
+
+// handleCapacity handles GET /api/v1/capacity?cpu=8&memory=32Gi&gpu=1&count=2, estimating
+// whether count VMs of the given resources fit in the cluster next to what is running.
+// Admins also get the estimate per node
+func (s *Server) handleCapacity(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	req, wanted, err := parseCapacityRequest(r)
+	if err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodes, pods, err := s.listNodesAndPods(r.Context())
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	estimate := estimateCapacity(inventoryNodes(nodes, pods), req, wanted)
+	if !claims.IsAdmin {
+		estimate.Nodes = nil
+	}
+	s.writeJSON(w, estimate)
+}
+
+// listNodesAndPods returns the nodes and the pods of every namespace
+func (s *Server) listNodesAndPods(ctx context.Context) ([]unstructured.Unstructured, []unstructured.Unstructured, error) {
+	lists := map[string]*unstructured.UnstructuredList{}
+	for _, kind := range []string{"NodeList", "PodList"} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: kind})
+		if err := s.client.List(ctx, list); err != nil {
+			return nil, nil, fmt.Errorf("failed to list %s: %w", kind, err)
+		}
+		lists[kind] = list
+	}
+	return lists["NodeList"].Items, lists["PodList"].Items, nil
+}
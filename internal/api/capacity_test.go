@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// newCapacityNode returns a Ready node with the allocatable resources, and spec fields
+func newCapacityNode(name string, allocatable map[string]interface{}, spec map[string]interface{}) unstructured.Unstructured {
+	return newStorageObject("Node", name, map[string]interface{}{
+		"spec": spec,
+		"status": map[string]interface{}{
+			"allocatable": allocatable,
+			"conditions":  []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+		},
+	})
+}
+
+// newCapacityPod returns a running pod on nodeName with labels, requesting requests
+func newCapacityPod(name, nodeName string, labels map[string]string, requests map[string]interface{}) unstructured.Unstructured {
+	pod := newStorageObject("Pod", name, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"nodeName": nodeName,
+			"containers": []interface{}{
+				map[string]interface{}{"name": "main", "resources": map[string]interface{}{"requests": requests}},
+			},
+		},
+		"status": map[string]interface{}{"phase": "Running"},
+	})
+	pod.SetNamespace("project-ml")
+	pod.SetLabels(labels)
+	return pod
+}
+
+func capacityTestObjects() ([]unstructured.Unstructured, []unstructured.Unstructured) {
+	nodes := []unstructured.Unstructured{
+		newCapacityNode("big", map[string]interface{}{"cpu": "32", "memory": "128Gi", "nvidia.com/gpu": "2"}, nil),
+		newCapacityNode("small", map[string]interface{}{"cpu": "8", "memory": "16Gi"}, nil),
+		newCapacityNode("cordoned", map[string]interface{}{"cpu": "64", "memory": "256Gi"}, map[string]interface{}{"unschedulable": true}),
+	}
+	pods := []unstructured.Unstructured{
+		newCapacityPod("virt-launcher-web", "big", map[string]string{"vm.kubevirt.io/name": "web"},
+			map[string]interface{}{"cpu": "4", "memory": "16Gi"}),
+		newCapacityPod("mistral-0", "big", map[string]string{"llmcloud.io/model": "mistral"},
+			map[string]interface{}{"cpu": "4", "memory": "32Gi", "nvidia.com/gpu": "1"}),
+		newCapacityPod("sidecar", "small", nil, map[string]interface{}{"cpu": "500m", "memory": "512Mi"}),
+	}
+	return nodes, pods
+}
+
+func TestInventoryNodes(t *testing.T) {
+	nodes, pods := capacityTestObjects()
+	inventory := inventoryNodes(nodes, pods)
+	if len(inventory) != 3 {
+		t.Fatalf("Expected 3 nodes, got %d", len(inventory))
+	}
+
+	big := inventory[0]
+	if !big.Schedulable || big.VMIs != 1 || big.ModelPods != 1 {
+		t.Errorf("Expected a schedulable node with a VMI and a model pod, got %+v", big)
+	}
+	if big.CPU.Requested.String() != "8" || big.CPU.Free.String() != "24" {
+		t.Errorf("Expected 8 of 32 CPUs requested, got %+v", big.CPU)
+	}
+	if big.Memory.Free.String() != "80Gi" || big.GPUs.Free.Value() != 1 {
+		t.Errorf("Expected 80Gi of memory and a GPU free, got %+v %+v", big.Memory, big.GPUs)
+	}
+	if small := inventory[1]; small.CPU.Free.String() != "7500m" || small.VMIs != 0 {
+		t.Errorf("Expected 7.5 CPUs free on the small node, got %+v", small)
+	}
+	if inventory[2].Schedulable {
+		t.Error("Expected the cordoned node not to be schedulable")
+	}
+}
+
+func TestNodeSchedulable(t *testing.T) {
+	for name, tt := range map[string]struct {
+		taints []interface{}
+		ready  string
+		want   bool
+	}{
+		"ready":           {ready: "True", want: true},
+		"not ready":       {ready: "False"},
+		"gpu taint":       {ready: "True", taints: []interface{}{map[string]interface{}{"key": "nvidia.com/gpu", "effect": "NoSchedule"}}, want: true},
+		"control plane":   {ready: "True", taints: []interface{}{map[string]interface{}{"key": "node-role.kubernetes.io/control-plane", "effect": "NoSchedule"}}},
+		"prefer no sched": {ready: "True", taints: []interface{}{map[string]interface{}{"key": "busy", "effect": "PreferNoSchedule"}}, want: true},
+	} {
+		node := map[string]interface{}{
+			"spec":   map[string]interface{}{"taints": tt.taints},
+			"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": tt.ready}}},
+		}
+		if got := nodeSchedulable(node); got != tt.want {
+			t.Errorf("%s: expected schedulable %v, got %v", name, tt.want, got)
+		}
+	}
+}
+
+func TestEstimateCapacity(t *testing.T) {
+	nodes, pods := capacityTestObjects()
+	inventory := inventoryNodes(nodes, pods)
+
+	req := capacityRequest{CPU: resource.MustParse("8"), Memory: resource.MustParse("32Gi"), GPUs: 1}
+	estimate := estimateCapacity(inventory, req, 1)
+	if !estimate.Fits || estimate.Available != 1 {
+		t.Errorf("Expected one VM with a GPU to fit, got %+v", estimate)
+	}
+	if fit := estimate.Nodes[0]; fit.Count != 1 || fit.Reason != "insufficient gpus" {
+		t.Errorf("Expected the GPUs to limit the big node, got %+v", fit)
+	}
+	if fit := estimate.Nodes[1]; fit.Count != 0 || fit.Reason != "insufficient cpu and memory and gpus" {
+		t.Errorf("Expected the small node to lack every resource, got %+v", fit)
+	}
+
+	estimate = estimateCapacity(inventory, capacityRequest{CPU: resource.MustParse("4"), Memory: resource.MustParse("8Gi")}, 12)
+	// 6 on the big node, limited by its CPUs, and one on the small node
+	if estimate.Fits || estimate.Available != 7 {
+		t.Errorf("Expected 7 VMs to fit, got %+v", estimate)
+	}
+	if fit := estimate.Nodes[1]; fit.Count != 1 || fit.Reason != "insufficient cpu and memory" {
+		t.Errorf("Expected the small node to fit one VM, got %+v", fit)
+	}
+	if fit := estimate.Nodes[2]; fit.Count != 0 || fit.Reason != "node is not schedulable" {
+		t.Errorf("Expected nothing on the cordoned node, got %+v", fit)
+	}
+}
+
+func TestHandleCapacity(t *testing.T) {
+	nodes, pods := capacityTestObjects()
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for i := range nodes {
+		builder.WithObjects(&nodes[i])
+	}
+	for i := range pods {
+		builder.WithObjects(&pods[i])
+	}
+	s := &Server{client: builder.Build()}
+
+	get := func(query string, claims *auth.Claims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/capacity?"+query, nil)
+		req = req.WithContext(context.WithValue(req.Context(), claimsKey, claims))
+		w := httptest.NewRecorder()
+		s.handleCapacity(w, req)
+		return w
+	}
+
+	w := get("cpu=8&memory=32Gi&gpu=1", &auth.Claims{Username: "alice"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var estimate capacityEstimate
+	if err := json.NewDecoder(w.Body).Decode(&estimate); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !estimate.Fits || estimate.Available != 1 || estimate.Nodes != nil {
+		t.Errorf("Expected the VM to fit, without nodes for a user, got %+v", estimate)
+	}
+
+	w = get("cpu=8&memory=32Gi&gpu=1&count=2", &auth.Claims{Username: "root", IsAdmin: true})
+	estimate = capacityEstimate{}
+	if err := json.NewDecoder(w.Body).Decode(&estimate); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if estimate.Fits || len(estimate.Nodes) != 3 {
+		t.Errorf("Expected two VMs not to fit, with nodes for an admin, got %+v", estimate)
+	}
+
+	for _, query := range []string{"", "cpu=lots", "memory=32Gi&count=-1", "gpu=0"} {
+		if w := get(query, &auth.Claims{Username: "alice"}); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected %q to be refused, got %d", query, w.Code)
+		}
+	}
+}
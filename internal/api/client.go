@@ -0,0 +1,41 @@
+package api
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NewClient returns the Kubernetes client the API server should use.
+// If kubeconfig is empty the fallback client (normally the manager's) is returned,
+// otherwise a new client is built for the cluster and context in that kubeconfig.
+func NewClient(kubeconfig string, scheme *runtime.Scheme, fallback client.Client) (client.Client, error) {
+	if kubeconfig == "" {
+		return fallback, nil
+	}
+
+	config, err := NewConfig(kubeconfig, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.New(config, client.Options{Scheme: scheme})
+}
+
+// NewConfig returns the REST config the API server should use to reach the cluster
+// directly. If kubeconfig is empty fallback is returned, otherwise the config is loaded
+// from that kubeconfig, matching the client returned by NewClient
+func NewConfig(kubeconfig string, fallback *rest.Config) (*rest.Config, error) {
+	if kubeconfig == "" {
+		return fallback, nil
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API kubeconfig %s: %w", kubeconfig, err)
+	}
+	return config, nil
+}
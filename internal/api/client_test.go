@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestNewClientFallback(t *testing.T) {
+	fallback := setupTestClient()
+
+	c, err := NewClient("", runtime.NewScheme(), fallback)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c != fallback {
+		t.Error("Expected fallback client when no kubeconfig is provided")
+	}
+}
+
+func TestNewClientUsesKubeconfig(t *testing.T) {
+	var hits atomic.Int32
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer apiServer.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	content := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: alt
+  cluster:
+    server: %s
+contexts:
+- name: alt
+  context:
+    cluster: alt
+    user: alt
+current-context: alt
+users:
+- name: alt
+  user:
+    token: test
+`, apiServer.URL)
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write kubeconfig: %v", err)
+	}
+
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	fallback := setupTestClient()
+
+	c, err := NewClient(kubeconfig, scheme, fallback)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c == fallback {
+		t.Fatal("Expected a new client when kubeconfig is provided")
+	}
+
+	_ = c.List(context.Background(), &llmcloudv1alpha1.ProjectList{})
+	if hits.Load() == 0 {
+		t.Error("Expected the client to talk to the cluster from the provided kubeconfig")
+	}
+}
+
+func TestNewClientInvalidKubeconfig(t *testing.T) {
+	_, err := NewClient(filepath.Join(t.TempDir(), "missing"), runtime.NewScheme(), setupTestClient())
+	if err == nil {
+		t.Error("Expected error for missing kubeconfig")
+	}
+}
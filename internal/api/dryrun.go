@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/pmezard/go-difflib/difflib"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+)
+
+// dryRunKey marks the context of a request that is a dry run
+const dryRunKey contextKey = "dryRun"
+
+// parseDryRun reports whether r asks for a dry run with ?dryRun=true, or All as the
+// Kubernetes API spells it
+func parseDryRun(r *http.Request) (bool, error) {
+	switch value := r.URL.Query().Get("dryRun"); value {
+	case "", "false":
+		return false, nil
+	case "true", metav1.DryRunAll:
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid dryRun %q: expected true or false", value)
+	}
+}
+
+// isDryRun reports whether ctx belongs to a dry-run request
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(dryRunKey).(bool)
+	return dryRun
+}
+
+// withDryRun marks the context of dry-run requests to h, and refuses them unless
+// supported and for a POST, PUT or PATCH
+func withDryRun(h http.HandlerFunc, supported bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := parseDryRun(r)
+		if err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dryRun {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				supported = false
+			}
+			if !supported {
+				writeProblem(w, fmt.Sprintf("%s %s does not support dryRun", r.Method, r.URL.Path), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), dryRunKey, true))
+		}
+		h(w, r)
+	}
+}
+
+// vmDryRun is the response to a dry run creating or updating a VM: the VM as the API
+// server would store it, the KubeVirt VirtualMachine the controller would generate for
+// it and a unified diff of that manifest from the one generated for the current VM
+type vmDryRun struct {
+	Object   *llmcloudv1alpha1.VirtualMachine `json:"object"`
+	KubeVirt string                           `json:"kubevirt"`
+	Diff     string                           `json:"diff"`
+}
+
+// writeVMDryRun writes the vmDryRun for a dry run turning current into vm, with
+// current nil for a VM being created
+func (s *Server) writeVMDryRun(w http.ResponseWriter, current, vm *llmcloudv1alpha1.VirtualMachine) {
+	manifest, err := yaml.Marshal(controller.PreviewKubeVirtVM(vm, s.Images).Object)
+	if err != nil {
+		writeError(w, "Failed to convert VM to YAML", err)
+		return
+	}
+	var currentManifest []byte
+	if current != nil {
+		if currentManifest, err = yaml.Marshal(controller.PreviewKubeVirtVM(current, s.Images).Object); err != nil {
+			writeError(w, "Failed to convert VM to YAML", err)
+			return
+		}
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(currentManifest)),
+		B:        difflib.SplitLines(string(manifest)),
+		FromFile: "current",
+		ToFile:   "dry-run",
+		Context:  3,
+	})
+	if err != nil {
+		writeError(w, "Failed to diff the KubeVirt VirtualMachine", err)
+		return
+	}
+	s.writeJSON(w, vmDryRun{Object: vm, KubeVirt: string(manifest), Diff: diff})
+}
+
+// dryRunClient makes the writes of dry-run requests dry runs, which the Kubernetes API
+// server validates, defaults and admits through the webhooks without persisting them
+type dryRunClient struct {
+	client.Client
+}
+
+func (c dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c dryRunClient) Status() client.SubResourceWriter {
+	return dryRunSubResourceWriter{c.Client.Status()}
+}
+
+func (c dryRunClient) SubResource(subResource string) client.SubResourceClient {
+	return dryRunSubResourceClient{c.Client.SubResource(subResource)}
+}
+
+// dryRunSubResourceWriter makes the subresource writes of dry-run requests dry runs
+type dryRunSubResourceWriter struct {
+	client.SubResourceWriter
+}
+
+func (w dryRunSubResourceWriter) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (w dryRunSubResourceWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w dryRunSubResourceWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if isDryRun(ctx) {
+		opts = append(opts, client.DryRunAll)
+	}
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+// dryRunSubResourceClient is a dryRunSubResourceWriter that can also read
+type dryRunSubResourceClient struct {
+	client.SubResourceClient
+}
+
+func (c dryRunSubResourceClient) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return dryRunSubResourceWriter{c.SubResourceClient}.Create(ctx, obj, subResource, opts...)
+}
+
+func (c dryRunSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return dryRunSubResourceWriter{c.SubResourceClient}.Update(ctx, obj, opts...)
+}
+
+func (c dryRunSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return dryRunSubResourceWriter{c.SubResourceClient}.Patch(ctx, obj, patch, opts...)
+}
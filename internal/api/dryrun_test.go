@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestDryRun(t *testing.T) {
+	c := setupTestClient()
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", CPUs: 1, Memory: "1Gi", DiskSize: "10Gi"},
+	}
+	if err := c.Create(context.Background(), vm); err != nil {
+		t.Fatal(err)
+	}
+	routes := NewServer(c).newRoutes()
+	admin := &auth.Claims{Username: "admin", IsAdmin: true}
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		routes.ServeHTTP(w, withClaims(httptest.NewRequest(method, path, strings.NewReader(body)), admin))
+		return w
+	}
+
+	w := serve("POST", "/api/v1/namespaces/project-team/vms?dryRun=true",
+		`{"metadata": {"name": "db"}, "spec": {"os": "debian", "cpus": 2, "memory": "2Gi", "diskSize": "20Gi"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the dry run to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var created vmDryRun
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Object == nil || created.Object.Name != "db" || !strings.Contains(created.KubeVirt, "kind: VirtualMachine") ||
+		!strings.Contains(created.Diff, "+++ dry-run") || !strings.Contains(created.Diff, "+kind: VirtualMachine") {
+		t.Errorf("Unexpected dry run %+v", created)
+	}
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: "db"}, &llmcloudv1alpha1.VirtualMachine{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the dry run to create nothing, got %v", err)
+	}
+
+	w = serve("PUT", "/api/v1/namespaces/project-team/vms/web?dryRun=true",
+		`{"spec": {"os": "ubuntu", "cpus": 4, "memory": "1Gi", "diskSize": "10Gi"}}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the dry run to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	var updated vmDryRun
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(updated.Diff, "--- current") || !strings.Contains(updated.Diff, "+          cores: 4") {
+		t.Errorf("Expected the diff to change the cores, got:\n%s", updated.Diff)
+	}
+	current := &llmcloudv1alpha1.VirtualMachine{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(vm), current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Spec.CPUs != 1 {
+		t.Errorf("Expected the dry run to leave the VM unchanged, got %d CPUs", current.Spec.CPUs)
+	}
+
+	for _, tt := range []struct {
+		method, path string
+	}{
+		{method: "POST", path: "/api/v1/namespaces/project-team/vms?dryRun=maybe"},
+		{method: "DELETE", path: "/api/v1/namespaces/project-team/vms/web?dryRun=true"},
+		{method: "POST", path: "/api/v1/actions/vm/project-team/web/stop?dryRun=true"},
+		{method: "POST", path: "/api/v1/namespaces/project-team/vms?dryRun=true&createProject=true"},
+	} {
+		if w := serve(tt.method, tt.path, `{"metadata": {"name": "db"}, "spec": {"os": "debian"}}`); w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, http.StatusBadRequest, w.Code, w.Body.String())
+		}
+	}
+}
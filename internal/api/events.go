@@ -0,0 +1,134 @@
+package api
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch
+
+// eventKinds maps the resources of /api/v1/events to the kinds of the objects whose
+// events are listed with theirs: the resource itself and the workload running it,
+// which share its name
+var eventKinds = map[string][]string{
+	"vm":      {"VirtualMachine", "VirtualMachineInstance"},
+	"model":   {"LLMModel", "Deployment"},
+	"service": {"Service", "Deployment", "StatefulSet"},
+	"project": {"Project"},
+	"user":    {"User"},
+}
+
+// resourceEvent is an event of GET /api/v1/events
+type resourceEvent struct {
+	Type               string `json:"type"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+	FirstTimestamp     string `json:"firstTimestamp"`
+	LastTimestamp      string `json:"lastTimestamp"`
+	Count              int32  `json:"count"`
+	InvolvedObjectName string `json:"involvedObjectName"`
+	InvolvedObjectKind string `json:"involvedObjectKind"`
+	Source             string `json:"source"`
+}
+
+// handleEvents handles GET /api/v1/events/{resource}/{namespace}/{name}, listing the
+// events of a VM, model or service, oldest first
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	resource, namespace, name := r.PathValue("resource"), r.PathValue("namespace"), r.PathValue("name")
+	if resource != "vm" && resource != "model" && resource != "service" {
+		writeProblem(w, "Unknown resource, valid resources: vm, model, service", http.StatusBadRequest)
+		return
+	}
+	s.writeEvents(w, r, resource, namespace, name)
+}
+
+// handleClusterEvents handles GET /api/v1/events/{resource}/{name}, listing the events
+// of a project, for its members, or of a user, for admins
+func (s *Server) handleClusterEvents(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	resource, name := r.PathValue("resource"), r.PathValue("name")
+
+	var obj client.Object
+	switch resource {
+	case "project":
+		if !auth.HasProjectAccess(claims, name) {
+			writeProblem(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		obj = &llmcloudv1alpha1.Project{}
+	case "user":
+		if !claims.IsAdmin {
+			writeProblem(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		obj = &llmcloudv1alpha1.User{}
+	default:
+		writeProblem(w, "Unknown resource, valid resources: project, user", http.StatusBadRequest)
+		return
+	}
+	if err := s.client.Get(r.Context(), client.ObjectKey{Name: name}, obj); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	// Events of cluster-scoped objects are recorded in the default namespace
+	s.writeEvents(w, r, resource, cmp.Or(obj.GetNamespace(), metav1.NamespaceDefault), name)
+}
+
+// writeEvents responds with the events in namespace of the objects of the kinds of
+// resource named name
+func (s *Server) writeEvents(w http.ResponseWriter, r *http.Request, resource, namespace, name string) {
+	var list corev1.EventList
+	if err := s.client.List(r.Context(), &list, client.InNamespace(namespace)); err != nil {
+		writeError(w, "Failed to list events", err)
+		return
+	}
+
+	kinds := eventKinds[resource]
+	matching := slices.DeleteFunc(list.Items, func(e corev1.Event) bool {
+		return e.InvolvedObject.Name != name || !slices.Contains(kinds, e.InvolvedObject.Kind)
+	})
+	slices.SortStableFunc(matching, func(a, b corev1.Event) int {
+		return eventTime(&a).Compare(eventTime(&b))
+	})
+
+	events := []resourceEvent{}
+	for _, e := range matching {
+		events = append(events, resourceEvent{
+			Type:               e.Type,
+			Reason:             e.Reason,
+			Message:            e.Message,
+			FirstTimestamp:     formatEventTime(e.FirstTimestamp.Time),
+			LastTimestamp:      formatEventTime(eventTime(&e)),
+			Count:              max(e.Count, 1),
+			InvolvedObjectName: e.InvolvedObject.Name,
+			InvolvedObjectKind: e.InvolvedObject.Kind,
+			Source:             cmp.Or(e.Source.Component, e.ReportingController),
+		})
+	}
+	s.writeJSON(w, map[string]any{"events": events})
+}
+
+// eventTime is when an event last occurred. Events recorded through the events.k8s.io
+// API only have an event time
+func eventTime(e *corev1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	return e.EventTime.Time
+}
+
+func formatEventTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleEvents(t *testing.T) {
+	now := time.Now()
+	event := func(namespace, name, kind, reason string, last time.Time) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name + "." + reason, Namespace: namespace},
+			InvolvedObject: corev1.ObjectReference{Kind: kind, Name: name, Namespace: namespace},
+			Reason:         reason,
+			Type:           corev1.EventTypeNormal,
+			LastTimestamp:  metav1.NewTime(last),
+			Source:         corev1.EventSource{Component: "service-controller"},
+		}
+	}
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		event("project-team", "db", "Service", "Running", now),
+		event("project-team", "db", "StatefulSet", "SuccessfulCreate", now.Add(-time.Minute)),
+		event("project-team", "db", "VirtualMachine", "Created", now),
+		event("project-team", "cache", "Service", "Running", now),
+		event("default", "team", "Project", "QuotaExceeded", now),
+		&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+		&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+	).Build()
+	s := &Server{client: c}
+	member := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	for _, tt := range []struct {
+		name, path string
+		handler    http.HandlerFunc
+		values     map[string]string
+		claims     *auth.Claims
+		wantCode   int
+		want       []string
+	}{
+		{"service", "/api/v1/events/service/project-team/db", s.handleEvents,
+			map[string]string{"resource": "service", "namespace": "project-team", "name": "db"}, member,
+			http.StatusOK, []string{"StatefulSet/SuccessfulCreate", "Service/Running"}},
+		{"no events", "/api/v1/events/model/project-team/db", s.handleEvents,
+			map[string]string{"resource": "model", "namespace": "project-team", "name": "db"}, member,
+			http.StatusOK, []string{}},
+		{"unknown resource", "/api/v1/events/template/project-team/db", s.handleEvents,
+			map[string]string{"resource": "template", "namespace": "project-team", "name": "db"}, member,
+			http.StatusBadRequest, nil},
+		{"project", "/api/v1/events/project/team", s.handleClusterEvents,
+			map[string]string{"resource": "project", "name": "team"}, member,
+			http.StatusOK, []string{"Project/QuotaExceeded"}},
+		{"other project", "/api/v1/events/project/other", s.handleClusterEvents,
+			map[string]string{"resource": "project", "name": "other"}, member,
+			http.StatusForbidden, nil},
+		{"user as non-admin", "/api/v1/events/user/bob", s.handleClusterEvents,
+			map[string]string{"resource": "user", "name": "bob"}, member,
+			http.StatusForbidden, nil},
+		{"missing project", "/api/v1/events/project/gone", s.handleClusterEvents,
+			map[string]string{"resource": "project", "name": "gone"}, &auth.Claims{Username: "root", IsAdmin: true},
+			http.StatusNotFound, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			for k, v := range tt.values {
+				req.SetPathValue(k, v)
+			}
+			req = req.WithContext(context.WithValue(req.Context(), claimsKey, tt.claims))
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp struct {
+				Events []resourceEvent `json:"events"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, e := range resp.Events {
+				got = append(got, e.InvolvedObjectKind+"/"+e.Reason)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Expected events %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+)
+
+// vmExposure describes how the exposed ports of a VM are reached: through the node
+// ports allocated for them on any node, or the external addresses of a LoadBalancer
+type vmExposure struct {
+	Service   string                    `json:"service"`
+	Type      string                    `json:"type"`
+	Ports     []llmcloudv1alpha1.VMPort `json:"ports"`
+	Addresses []string                  `json:"addresses,omitempty"`
+}
+
+// handleVMExpose handles /api/v1/namespaces/{namespace}/vms/{name}/expose. GET returns
+// the VM's exposure, POST exposes a port (replacing the one with the same number and
+// protocol, if any) and DELETE ?port=N[&protocol=UDP] stops exposing one. The VM
+// controller keeps the <vm>-ports Service in line with the VM's ExposedPorts
+func (s *Server) handleVMExpose(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"))
+	if len(parts) != 4 || parts[1] != "vms" || parts[3] != "expose" {
+		writeProblem(w, "Invalid path, expected: /api/v1/namespaces/{namespace}/vms/{name}/expose", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: parts[0], Name: parts[2]}, vm); err != nil {
+		writeError(w, "Failed to get VM", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeVMExposure(ctx, w, vm)
+
+	case http.MethodPost:
+		var req struct {
+			llmcloudv1alpha1.VMPort
+			Type string `json:"type"` // Optional NodePort or LoadBalancer, keeping the VM's type when empty
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		old := vm.Spec.DeepCopy()
+		port := req.VMPort
+		port.Protocol = cmp.Or(port.Protocol, string(corev1.ProtocolTCP))
+		i := slices.IndexFunc(vm.Spec.ExposedPorts, func(p llmcloudv1alpha1.VMPort) bool { return samePort(p, port) })
+		if i >= 0 {
+			vm.Spec.ExposedPorts[i] = port
+		} else {
+			vm.Spec.ExposedPorts = append(vm.Spec.ExposedPorts, port)
+		}
+		if req.Type != "" {
+			vm.Spec.ExposeType = req.Type
+		}
+		if vm.Spec.ExposeType != "" && vm.Spec.ExposeType != string(corev1.ServiceTypeNodePort) &&
+			vm.Spec.ExposeType != string(corev1.ServiceTypeLoadBalancer) {
+			writeProblem(w, "Type must be 'NodePort' or 'LoadBalancer'", http.StatusBadRequest)
+			return
+		}
+		if err := llmcloudv1alpha1.ValidateVirtualMachineUpdate(&vm.Spec, old, field.NewPath("spec")).ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.client.Update(ctx, vm); err != nil {
+			writeError(w, "Failed to expose port", err)
+			return
+		}
+		s.writeVMExposure(ctx, w, vm)
+
+	case http.MethodDelete:
+		number, err := strconv.ParseInt(r.URL.Query().Get("port"), 10, 32)
+		if err != nil {
+			writeProblem(w, "A numeric port query parameter is required", http.StatusBadRequest)
+			return
+		}
+		port := llmcloudv1alpha1.VMPort{Port: int32(number), Protocol: r.URL.Query().Get("protocol")}
+		i := slices.IndexFunc(vm.Spec.ExposedPorts, func(p llmcloudv1alpha1.VMPort) bool { return samePort(p, port) })
+		if i < 0 {
+			writeProblem(w, "Port is not exposed", http.StatusNotFound)
+			return
+		}
+		vm.Spec.ExposedPorts = slices.Delete(vm.Spec.ExposedPorts, i, i+1)
+		if err := s.client.Update(ctx, vm); err != nil {
+			writeError(w, "Failed to unexpose port", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// samePort reports whether a and b are the same port number and protocol, TCP when empty
+func samePort(a, b llmcloudv1alpha1.VMPort) bool {
+	return a.Port == b.Port &&
+		cmp.Or(a.Protocol, string(corev1.ProtocolTCP)) == cmp.Or(b.Protocol, string(corev1.ProtocolTCP))
+}
+
+// writeVMExposure writes the exposure of vm, filling in the node ports and addresses
+// from its Service once the controller has created it
+func (s *Server) writeVMExposure(ctx context.Context, w http.ResponseWriter, vm *llmcloudv1alpha1.VirtualMachine) {
+	exposure := vmExposure{
+		Service: controller.VMPortsServiceName(vm),
+		Type:    cmp.Or(vm.Spec.ExposeType, string(corev1.ServiceTypeNodePort)),
+		Ports:   slices.Clone(vm.Spec.ExposedPorts),
+	}
+	if exposure.Ports == nil {
+		exposure.Ports = []llmcloudv1alpha1.VMPort{}
+	}
+
+	svc := &corev1.Service{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: exposure.Service}, svc); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			writeError(w, "Failed to get the VM's Service", err)
+			return
+		}
+		s.writeJSON(w, exposure)
+		return
+	}
+	for i, port := range exposure.Ports {
+		for _, servicePort := range svc.Spec.Ports {
+			if port.NodePort == 0 && samePort(port, llmcloudv1alpha1.VMPort{Port: servicePort.Port, Protocol: string(servicePort.Protocol)}) {
+				exposure.Ports[i].NodePort = servicePort.NodePort
+			}
+		}
+	}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		exposure.Addresses = append(exposure.Addresses, cmp.Or(ingress.IP, ingress.Hostname))
+	}
+	s.writeJSON(w, exposure)
+}
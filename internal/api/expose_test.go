@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestHandleVMExpose(t *testing.T) {
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu"},
+	}
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-ports", Namespace: "project-team"},
+		Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80, Protocol: corev1.ProtocolTCP, NodePort: 30080}}},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{
+			Ingress: []corev1.LoadBalancerIngress{{IP: "203.0.113.10"}},
+		}},
+	}
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(vm, svc).Build()
+	s := &Server{client: c}
+	path := "/api/v1/namespaces/project-team/vms/web/expose"
+
+	w := httptest.NewRecorder()
+	s.handleVMExpose(w, httptest.NewRequest("POST", path, strings.NewReader(`{"name": "http", "port": 80, "type": "LoadBalancer"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the port to be exposed, got %d: %s", w.Code, w.Body.String())
+	}
+	var exposure vmExposure
+	if err := json.NewDecoder(w.Body).Decode(&exposure); err != nil {
+		t.Fatal(err)
+	}
+	if exposure.Service != "web-ports" || exposure.Type != "LoadBalancer" || len(exposure.Ports) != 1 ||
+		exposure.Ports[0].NodePort != 30080 || len(exposure.Addresses) != 1 || exposure.Addresses[0] != "203.0.113.10" {
+		t.Errorf("Unexpected exposure %+v", exposure)
+	}
+	updated := &llmcloudv1alpha1.VirtualMachine{}
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(vm), updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Spec.ExposeType != "LoadBalancer" || len(updated.Spec.ExposedPorts) != 1 || updated.Spec.ExposedPorts[0].Protocol != "TCP" {
+		t.Errorf("Unexpected spec %+v", updated.Spec)
+	}
+
+	for _, tt := range []struct {
+		method, path, body string
+		want               int
+	}{
+		{method: "POST", path: path, body: `{"port": 70000}`, want: http.StatusBadRequest},
+		{method: "POST", path: path, body: `{"port": 22, "type": "ClusterIP"}`, want: http.StatusBadRequest},
+		{method: "DELETE", path: path + "?port=80&protocol=UDP", want: http.StatusNotFound},
+		{method: "DELETE", path: path + "?port=80", want: http.StatusNoContent},
+		{method: "GET", path: "/api/v1/namespaces/project-team/vms/missing/expose", want: http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		s.handleVMExpose(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.path, tt.want, w.Code, w.Body.String())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	s.handleVMExpose(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"ports":[]`) {
+		t.Errorf("Expected no exposed ports, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=groups,verbs=get;list;watch;create;update;delete
+
+// handleGroups lists the user groups or creates one, which only admins may. A project
+// member named group:<name> makes every user in the group a member of the project
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		var groups llmcloudv1alpha1.GroupList
+		if err := s.client.List(ctx, &groups); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, groups)
+
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+			llmcloudv1alpha1.GroupSpec
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group := &llmcloudv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name},
+			Spec:       req.GroupSpec,
+		}
+		allErrs := llmcloudv1alpha1.ValidateGroupSpec(&group.Spec, field.NewPath("spec"))
+		for _, msg := range validation.IsDNS1123Subdomain(req.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("name"), req.Name, msg))
+		}
+		if err := allErrs.ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.client.Create(ctx, group); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, group)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleGroup returns a group, or replaces its spec with the request body or deletes
+// it. Members gain or lose the group's projects when they next log in or refresh
+func (s *Server) handleGroup(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+	name := r.URL.Path[len("/api/v1/groups/"):]
+
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	group := &llmcloudv1alpha1.Group{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, group); err != nil {
+		writeError(w, "Group not found", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		setETag(w, group)
+		s.writeJSON(w, group)
+
+	case http.MethodPut:
+		if !checkIfMatch(w, r, group) {
+			return
+		}
+		var spec llmcloudv1alpha1.GroupSpec
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&spec); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := llmcloudv1alpha1.ValidateGroupSpec(&spec, field.NewPath("spec")).ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group.Spec = spec
+		if err := s.client.Update(ctx, group); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		setETag(w, group)
+		s.writeJSON(w, group)
+
+	case http.MethodDelete:
+		if err := s.client.Delete(ctx, group); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
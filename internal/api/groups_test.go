@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleGroups(t *testing.T) {
+	c := setupTestClient()
+	s := &Server{client: c}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		body   string
+		want   int
+	}{
+		{name: "by an admin", claims: admin, body: `{"name": "ml-team", "members": ["alice", "bob"]}`, want: http.StatusOK},
+		{name: "by a user", claims: alice, body: `{"name": "mine", "members": ["alice"]}`, want: http.StatusForbidden},
+		{name: "nested group", claims: admin, body: `{"name": "all", "members": ["group:ml-team"]}`, want: http.StatusBadRequest},
+		{name: "invalid name", claims: admin, body: `{"name": "ML Team"}`, want: http.StatusBadRequest},
+		{name: "duplicate", claims: admin, body: `{"name": "ml-team"}`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handleGroups(w, withClaims(httptest.NewRequest("POST", "/api/v1/groups", strings.NewReader(tt.body)), tt.claims))
+			if w.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.handleGroup(w, withClaims(httptest.NewRequest("PUT", "/api/v1/groups/ml-team", strings.NewReader(`{"members": ["alice"]}`)), admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	group := &llmcloudv1alpha1.Group{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "ml-team"}, group); err != nil || group.HasMember("bob") {
+		t.Errorf("Expected bob to have left the group, got %+v (%v)", group.Spec, err)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleGroup(w, withClaims(httptest.NewRequest("GET", "/api/v1/groups/ml-team", nil), alice))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected users not to see groups, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.handleGroup(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/groups/ml-team", nil), admin))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status NoContent, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.handleGroup(w, withClaims(httptest.NewRequest("GET", "/api/v1/groups/ml-team", nil), admin))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound after deletion, got %d", w.Code)
+	}
+}
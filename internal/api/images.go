@@ -0,0 +1,175 @@
+package api
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/cdi"
+)
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=vmimages,verbs=get;list;watch;create;update;delete
+
+// handleImages lists the VM images, which every user may boot VMs from, or registers
+// one, which only admins may. An uploaded image is ready once its disk is uploaded to
+// /api/v1/images/{name}/upload
+func (s *Server) handleImages(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var images llmcloudv1alpha1.VMImageList
+		if err := s.client.List(ctx, &images); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, images)
+
+	case http.MethodPost:
+		if !claims.IsAdmin {
+			writeProblem(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+			llmcloudv1alpha1.VMImageSpec
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		image := &llmcloudv1alpha1.VMImage{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name},
+			Spec:       req.VMImageSpec,
+		}
+		allErrs := llmcloudv1alpha1.ValidateVMImageSpec(&image.Spec, field.NewPath("spec"))
+		for _, msg := range validation.IsDNS1123Subdomain(req.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("name"), req.Name, msg))
+		}
+		if err := allErrs.ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.client.Create(ctx, image); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, image)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleImage returns a VM image, or deletes it, which only admins may while no VM
+// boots the image
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	if r.Method != http.MethodGet && !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	image := &llmcloudv1alpha1.VMImage{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: r.PathValue("name")}, image); err != nil {
+		writeProblem(w, "VM image not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		setETag(w, image)
+		s.writeJSON(w, image)
+
+	case http.MethodDelete:
+		var vms llmcloudv1alpha1.VirtualMachineList
+		if err := s.client.List(ctx, &vms); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		for _, vm := range vms.Items {
+			if vm.Spec.ImageRef == image.Name {
+				writeProblem(w, fmt.Sprintf("VM image %s is used by VM %s/%s", image.Name, vm.Namespace, vm.Name), http.StatusConflict)
+				return
+			}
+		}
+		if err := s.client.Delete(ctx, image); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleImageUpload streams the request body, a qcow2 or raw disk image, through the
+// CDI upload proxy into the volume of an uploaded image, which only admins may. The
+// image becomes Ready once CDI has converted it
+func (s *Server) handleImageUpload(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	image := &llmcloudv1alpha1.VMImage{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: r.PathValue("name")}, image); err != nil {
+		writeProblem(w, "VM image not found", http.StatusNotFound)
+		return
+	}
+	if image.Spec.Upload == nil {
+		writeProblem(w, "VM image "+image.Name+" is a container disk, not an upload", http.StatusBadRequest)
+		return
+	}
+	if image.Status.Phase != llmcloudv1alpha1.VMImagePhaseUploadReady || image.Status.Volume == nil {
+		writeProblem(w, fmt.Sprintf("VM image %s is not waiting for an upload (phase %q)", image.Name, image.Status.Phase),
+			http.StatusConflict)
+		return
+	}
+
+	token, err := cdi.UploadToken(ctx, s.client, image.Status.Volume.Namespace, image.Status.Volume.Name)
+	if err != nil {
+		writeError(w, "Failed to request an upload token", err)
+		return
+	}
+	proxyURL, httpClient, err := cdi.UploadProxy(ctx, s.client, s.UploadProxyURL)
+	if err != nil {
+		writeError(w, "Failed to reach the CDI upload proxy", err)
+		return
+	}
+	if err := cdi.Upload(ctx, httpClient, proxyURL, token, r.Body, r.ContentLength); err != nil {
+		writeProblem(w, "Failed to upload the image: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyVMImage fills the OS of a new VM that leaves it unset from the VMImage it boots,
+// returning the HTTP status to report on failure
+func (s *Server) applyVMImage(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (int, error) {
+	image := &llmcloudv1alpha1.VMImage{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: vm.Spec.ImageRef}, image); err != nil {
+		if apierrors.IsNotFound(err) {
+			return http.StatusBadRequest, fmt.Errorf("VM image %q not found", vm.Spec.ImageRef)
+		}
+		return http.StatusInternalServerError, err
+	}
+	vm.Spec.OS = cmp.Or(vm.Spec.OS, image.Spec.OS)
+	return http.StatusOK, nil
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/cdi"
+)
+
+func TestHandleImagesCreate(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		body   string
+		want   int
+	}{
+		{name: "by an admin", claims: admin, body: `{"name": "windows", "upload": {"size": "40Gi"}}`, want: http.StatusOK},
+		{name: "by a user", claims: alice, body: `{"name": "mine", "containerDisk": "registry.local/mine"}`, want: http.StatusForbidden},
+		{name: "no source", claims: admin, body: `{"name": "empty", "os": "ubuntu"}`, want: http.StatusBadRequest},
+		{name: "invalid name", claims: admin, body: `{"name": "Not A Name", "containerDisk": "registry.local/x"}`, want: http.StatusBadRequest},
+		{name: "duplicate", claims: admin, body: `{"name": "windows", "containerDisk": "registry.local/x"}`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest("POST", "/api/v1/images", strings.NewReader(tt.body)), tt.claims)
+			w := httptest.NewRecorder()
+			s.handleImages(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.handleImages(w, withClaims(httptest.NewRequest("GET", "/api/v1/images", nil), alice))
+	var images llmcloudv1alpha1.VMImageList
+	if err := json.NewDecoder(w.Body).Decode(&images); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(images.Items) != 1 || images.Items[0].Spec.Upload == nil {
+		t.Errorf("Expected users to see the windows image, got %+v", images.Items)
+	}
+}
+
+func TestHandleImageDelete(t *testing.T) {
+	c := setupTestClient()
+	ctx := context.Background()
+	image := &llmcloudv1alpha1.VMImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "windows"},
+		Spec:       llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "40Gi"}},
+	}
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "desktop", Namespace: "team"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{ImageRef: "windows"},
+	}
+	for _, obj := range []client.Object{image, vm} {
+		if err := c.Create(ctx, obj); err != nil {
+			t.Fatalf("Failed to create %s: %v", obj.GetName(), err)
+		}
+	}
+	s := &Server{client: c}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+
+	deleteImage := func(claims *auth.Claims) int {
+		req := withClaims(httptest.NewRequest("DELETE", "/api/v1/images/windows", nil), claims)
+		req.SetPathValue("name", "windows")
+		w := httptest.NewRecorder()
+		s.handleImage(w, req)
+		return w.Code
+	}
+	if code := deleteImage(&auth.Claims{Username: "alice"}); code != http.StatusForbidden {
+		t.Errorf("Expected users not to delete images, got %d", code)
+	}
+	if code := deleteImage(admin); code != http.StatusConflict {
+		t.Errorf("Expected an image a VM boots not to be deleted, got %d", code)
+	}
+	if err := c.Delete(ctx, vm); err != nil {
+		t.Fatalf("Failed to delete VM: %v", err)
+	}
+	if code := deleteImage(admin); code != http.StatusNoContent {
+		t.Errorf("Expected the image to be deleted, got %d", code)
+	}
+}
+
+func TestHandleImageUpload(t *testing.T) {
+	var uploaded, token string
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta1/upload" {
+			http.NotFound(w, r)
+			return
+		}
+		token = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		uploaded = string(body)
+	}))
+	defer proxy.Close()
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	image := &llmcloudv1alpha1.VMImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "windows"},
+		Spec:       llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "40Gi"}},
+		Status: llmcloudv1alpha1.VMImageStatus{
+			Phase:  llmcloudv1alpha1.VMImagePhaseUploadReady,
+			Volume: &llmcloudv1alpha1.VMImageVolume{Namespace: "llmcloud-images", Name: "windows"},
+		},
+	}
+	bundle := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: cdi.Namespace, Name: cdi.UploadProxyCABundle},
+		Data: map[string]string{
+			"ca-bundle.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: proxy.Certificate().Raw})),
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(image, bundle).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				request, ok := obj.(*unstructured.Unstructured)
+				if !ok || request.GetKind() != "UploadTokenRequest" {
+					return c.Create(ctx, obj, opts...)
+				}
+				return unstructured.SetNestedField(request.Object, "secret-token", "status", "token")
+			},
+		}).Build()
+	s := &Server{client: c, UploadProxyURL: proxy.URL}
+
+	upload := func(claims *auth.Claims, name string) *httptest.ResponseRecorder {
+		req := withClaims(httptest.NewRequest("POST", "/api/v1/images/"+name+"/upload", strings.NewReader("disk")), claims)
+		req.SetPathValue("name", name)
+		w := httptest.NewRecorder()
+		s.handleImageUpload(w, req)
+		return w
+	}
+	if w := upload(&auth.Claims{Username: "alice"}, "windows"); w.Code != http.StatusForbidden {
+		t.Errorf("Expected users not to upload images, got %d", w.Code)
+	}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	if w := upload(admin, "plan9"); w.Code != http.StatusNotFound {
+		t.Errorf("Expected a missing image to be reported, got %d", w.Code)
+	}
+	w := upload(admin, "windows")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected the upload to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if uploaded != "disk" || token != "Bearer secret-token" {
+		t.Errorf("Expected the disk to reach the proxy with the upload token, got %q with %q", uploaded, token)
+	}
+
+	image.Status.Phase = llmcloudv1alpha1.VMImagePhaseReady
+	if err := c.Update(context.Background(), image); err != nil {
+		t.Fatalf("Failed to update image: %v", err)
+	}
+	if w := upload(admin, "windows"); w.Code != http.StatusConflict {
+		t.Errorf("Expected an uploaded image not to take another upload, got %d", w.Code)
+	}
+}
+
+func TestApplyVMImage(t *testing.T) {
+	c := setupTestClient()
+	image := &llmcloudv1alpha1.VMImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "bookworm"},
+		Spec:       llmcloudv1alpha1.VMImageSpec{OS: "debian", ContainerDisk: "registry.local/debian:12"},
+	}
+	if err := c.Create(context.Background(), image); err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	s := &Server{client: c}
+
+	vm := &llmcloudv1alpha1.VirtualMachine{Spec: llmcloudv1alpha1.VirtualMachineSpec{ImageRef: "bookworm"}}
+	if _, err := s.applyVMImage(context.Background(), vm); err != nil || vm.Spec.OS != "debian" {
+		t.Errorf("Expected the image's OS, got %q, %v", vm.Spec.OS, err)
+	}
+	vm.Spec.ImageRef = "plan9"
+	if code, err := s.applyVMImage(context.Background(), vm); code != http.StatusBadRequest || err == nil {
+		t.Errorf("Expected a missing image to be a bad request, got %d, %v", code, err)
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// logLabels maps the resources of /api/v1/logs to the label selecting their pods by name
+var logLabels = map[string]string{
+	"vms":      "vm.kubevirt.io/name",
+	"models":   "llmcloud.io/model",
+	"services": "llmcloud.io/service",
+}
+
+// vmLogContainers are the containers of a virt-launcher pod whose log is shown by
+// default, in order of preference. guest-console-log carries the guest's serial console
+var vmLogContainers = []string{"guest-console-log", "compute"}
+
+// +kubebuilder:rbac:groups="",resources=pods/log,verbs=get
+
+// handleLogs handles GET /api/v1/logs/{namespace}/{resource}/{name}, streaming the log of
+// the newest pod of a VM, model or service. ?follow=true keeps streaming new lines,
+// ?tailLines=N starts with the last N lines and ?container picks a container of the pod
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if s.Config == nil {
+		writeProblem(w, "Logs are not configured", http.StatusServiceUnavailable)
+		return
+	}
+	namespace, resource, name := r.PathValue("namespace"), r.PathValue("resource"), r.PathValue("name")
+	label, ok := logLabels[resource]
+	if !ok {
+		writeProblem(w, "Unknown resource, valid resources: vms, models, services", http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	opts := &corev1.PodLogOptions{Container: query.Get("container")}
+	if follow := query.Get("follow"); follow != "" {
+		var err error
+		if opts.Follow, err = strconv.ParseBool(follow); err != nil {
+			writeProblem(w, "Invalid follow, expected true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	if tail := query.Get("tailLines"); tail != "" {
+		lines, err := strconv.ParseInt(tail, 10, 64)
+		if err != nil || lines < 0 {
+			writeProblem(w, "Invalid tailLines, expected a non-negative number", http.StatusBadRequest)
+			return
+		}
+		opts.TailLines = &lines
+	}
+
+	ctx := r.Context()
+	var pods corev1.PodList
+	if err := s.client.List(ctx, &pods, client.InNamespace(namespace), client.MatchingLabels{label: name}); err != nil {
+		writeError(w, "Failed to list pods", err)
+		return
+	}
+	if len(pods.Items) == 0 {
+		writeProblem(w, fmt.Sprintf("No pod of %s %s/%s found", resource, namespace, name), http.StatusNotFound)
+		return
+	}
+	pod := slices.MaxFunc(pods.Items, func(a, b corev1.Pod) int {
+		return a.CreationTimestamp.Compare(b.CreationTimestamp.Time)
+	})
+	if opts.Container == "" && resource == "vms" {
+		opts.Container = vmLogContainer(&pod)
+	}
+
+	clientset, err := kubernetes.NewForConfig(s.Config)
+	if err != nil {
+		writeError(w, "Failed to build Kubernetes client", err)
+		return
+	}
+	stream, err := clientset.CoreV1().Pods(namespace).GetLogs(pod.Name, opts).Stream(ctx)
+	if err != nil {
+		writeError(w, "Failed to get logs", err)
+		return
+	}
+	defer func() { _ = stream.Close() }()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Pod-Name", pod.Name)
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.FromContext(ctx).Error(err, "Failed to stream logs", "pod", pod.Name, "namespace", namespace)
+			}
+			return
+		}
+	}
+}
+
+// vmLogContainer returns the first of vmLogContainers the virt-launcher pod has
+func vmLogContainer(pod *corev1.Pod) string {
+	for _, name := range vmLogContainers {
+		if slices.ContainsFunc(pod.Spec.Containers, func(c corev1.Container) bool { return c.Name == name }) {
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestHandleLogs(t *testing.T) {
+	var requested *http.Request
+	kube := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r
+		_, _ = w.Write([]byte("booted\n"))
+	}))
+	defer kube.Close()
+
+	now := time.Now()
+	pod := func(name, label, value string, created time.Time, containers ...string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "project-team", Labels: map[string]string{label: value},
+			CreationTimestamp: metav1.NewTime(created),
+		}}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, corev1.Container{Name: c})
+		}
+		return p
+	}
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		pod("llama-old", "llmcloud.io/model", "llama", now.Add(-time.Hour), "vllm"),
+		pod("llama-new", "llmcloud.io/model", "llama", now, "vllm"),
+		pod("virt-launcher-web-x", "vm.kubevirt.io/name", "web", now, "compute", "guest-console-log"),
+	).Build()
+	s := &Server{client: c, Config: &rest.Config{Host: kube.URL}}
+
+	for _, tt := range []struct {
+		name, resource, target, query string
+		wantCode                      int
+		wantPath, wantQuery           string
+	}{
+		{"model", "models", "llama", "?tailLines=10&follow=true", http.StatusOK,
+			"/api/v1/namespaces/project-team/pods/llama-new/log", "follow=true&tailLines=10"},
+		{"VM serial console", "vms", "web", "", http.StatusOK,
+			"/api/v1/namespaces/project-team/pods/virt-launcher-web-x/log", "container=guest-console-log"},
+		{"container", "vms", "web", "?container=compute", http.StatusOK,
+			"/api/v1/namespaces/project-team/pods/virt-launcher-web-x/log", "container=compute"},
+		{"no pod", "services", "api", "", http.StatusNotFound, "", ""},
+		{"unknown resource", "templates", "web", "", http.StatusBadRequest, "", ""},
+		{"invalid tail", "models", "llama", "?tailLines=-1", http.StatusBadRequest, "", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+			req := httptest.NewRequest("GET", "/api/v1/logs/project-team/"+tt.resource+"/"+tt.target+tt.query, nil)
+			req.SetPathValue("namespace", "project-team")
+			req.SetPathValue("resource", tt.resource)
+			req.SetPathValue("name", tt.target)
+			w := httptest.NewRecorder()
+			s.handleLogs(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("Expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if w.Body.String() != "booted\n" {
+				t.Errorf("Unexpected log %q", w.Body.String())
+			}
+			if requested.URL.Path != tt.wantPath || requested.URL.RawQuery != tt.wantQuery {
+				t.Errorf("Unexpected Kubernetes request %s", requested.URL)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	(&Server{client: c}).handleLogs(w, httptest.NewRequest("GET", "/api/v1/logs/project-team/models/llama", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 without a Kubernetes config, got %d", w.Code)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// handleClusterNodes handles GET /api/v1/nodes and POST /api/v1/nodes (cluster-wide, admin only)
+// GET returns the Kubernetes nodes, each with a gpus field reporting its GPU capacity and
+// how many are requested by its pods, a resources field with its allocatable, requested
+// and free CPU, memory and GPUs and its number of VMIs and model pods, and a clusterNode
+// field with the status of the ClusterNode managing it, if any. POST creates a ClusterNode, whose controller joins
+// the host to the cluster
+func (s *Server) handleClusterNodes(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		nodeList := &unstructured.UnstructuredList{}
+		nodeList.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "NodeList"})
+		if err := s.client.List(ctx, nodeList); err != nil {
+			writeError(w, "Failed to list nodes", err)
+			return
+		}
+		podList := &unstructured.UnstructuredList{}
+		podList.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "PodList"})
+		if err := s.client.List(ctx, podList); err != nil {
+			writeError(w, "Failed to list pods", err)
+			return
+		}
+		var clusterNodes llmcloudv1alpha1.ClusterNodeList
+		if err := s.client.List(ctx, &clusterNodes); err != nil {
+			writeError(w, "Failed to list cluster nodes", err)
+			return
+		}
+
+		// summarizeGPUs and inventoryNodes report nodes in list order
+		for i, gpus := range summarizeGPUs(nodeList.Items, podList.Items).Nodes {
+			nodeList.Items[i].Object["gpus"] = gpus
+		}
+		for i, inventory := range inventoryNodes(nodeList.Items, podList.Items) {
+			nodeList.Items[i].Object["resources"] = inventory
+		}
+		for i := range nodeList.Items {
+			if cn := clusterNodeFor(clusterNodes.Items, nodeList.Items[i].GetName()); cn != nil {
+				nodeList.Items[i].Object["clusterNode"] = map[string]interface{}{
+					"name":    cn.Name,
+					"state":   cn.DesiredState(),
+					"phase":   cn.Status.Phase,
+					"message": cn.Status.Message,
+				}
+			}
+		}
+		s.writeJSON(w, nodeList)
+
+	case http.MethodPost:
+		var req struct {
+			Name              string                  `json:"name"`              // Optional, defaults to the node name
+			Host              string                  `json:"host"`              // SSH host ([user@]host[:port])
+			Role              string                  `json:"role"`              // "controller" (or "master") or "worker"
+			NodeName          string                  `json:"nodeName"`          // Optional, defaults to the host name
+			CredentialsSecret *corev1.SecretReference `json:"credentialsSecret"` // Optional ssh-privatekey and/or password
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Host == "" {
+			writeProblem(w, "Host is required", http.StatusBadRequest)
+			return
+		}
+		role := req.Role
+		if role == "master" {
+			role = llmcloudv1alpha1.ClusterNodeRoleController
+		}
+		if role != llmcloudv1alpha1.ClusterNodeRoleController && role != llmcloudv1alpha1.ClusterNodeRoleWorker {
+			writeProblem(w, "Role must be 'controller', 'master' or 'worker'", http.StatusBadRequest)
+			return
+		}
+
+		cn := &llmcloudv1alpha1.ClusterNode{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name},
+			Spec: llmcloudv1alpha1.ClusterNodeSpec{
+				Host:              req.Host,
+				Role:              role,
+				CredentialsSecret: req.CredentialsSecret,
+				NodeName:          req.NodeName,
+				State:             llmcloudv1alpha1.ClusterNodeStateJoined,
+			},
+		}
+		if cn.Name == "" {
+			cn.Name = cn.KubernetesNodeName()
+		}
+		if err := llmcloudv1alpha1.ValidateClusterNodeSpec(&cn.Spec, field.NewPath("spec")).ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.client.Create(ctx, cn); err != nil {
+			writeError(w, "Failed to add node", err)
+			return
+		}
+		s.writeJSON(w, cn)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleNodeActions handles DELETE /api/v1/nodes/:name. The node's ClusterNode is asked
+// to be removed, and its controller drains and deletes the node. A node joined without
+// a ClusterNode gets one, without a host to reset
+func (s *Server) handleNodeActions(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	// Extract node name from path: /api/v1/nodes/:name
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/nodes/"), "/")
+	if len(parts) == 0 || parts[0] == "" {
+		writeProblem(w, "Node name required", http.StatusBadRequest)
+		return
+	}
+	nodeName := parts[0]
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodDelete:
+		var clusterNodes llmcloudv1alpha1.ClusterNodeList
+		if err := s.client.List(ctx, &clusterNodes); err != nil {
+			writeError(w, "Failed to list cluster nodes", err)
+			return
+		}
+		cn := clusterNodeFor(clusterNodes.Items, nodeName)
+		if cn == nil {
+			cn = &llmcloudv1alpha1.ClusterNode{
+				ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+				Spec: llmcloudv1alpha1.ClusterNodeSpec{
+					NodeName: nodeName,
+					State:    llmcloudv1alpha1.ClusterNodeStateRemoved,
+				},
+			}
+			if err := s.client.Create(ctx, cn); err != nil {
+				writeError(w, "Failed to remove node", err)
+				return
+			}
+		} else if cn.Spec.State != llmcloudv1alpha1.ClusterNodeStateRemoved {
+			cn.Spec.State = llmcloudv1alpha1.ClusterNodeStateRemoved
+			if err := s.client.Update(ctx, cn); err != nil {
+				writeError(w, "Failed to remove node", err)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(cn)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleClusterNodeList handles GET /api/v1/clusternodes (admin only), reporting the
+// progress of the nodes being joined, drained or removed
+func (s *Server) handleClusterNodeList(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	var clusterNodes llmcloudv1alpha1.ClusterNodeList
+	if err := s.client.List(r.Context(), &clusterNodes); err != nil {
+		writeError(w, "Failed to list cluster nodes", err)
+		return
+	}
+	s.writeJSON(w, clusterNodes)
+}
+
+// clusterNodeFor returns the ClusterNode managing the node named nodeName, or nil
+func clusterNodeFor(clusterNodes []llmcloudv1alpha1.ClusterNode, nodeName string) *llmcloudv1alpha1.ClusterNode {
+	for i := range clusterNodes {
+		if clusterNodes[i].KubernetesNodeName() == nodeName {
+			return &clusterNodes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleClusterNodesAdd(t *testing.T) {
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	s := &Server{client: setupTestClient()}
+
+	w := httptest.NewRecorder()
+	s.handleClusterNodes(w, withClaims(httptest.NewRequest("POST", "/api/v1/nodes",
+		strings.NewReader(`{"host": "ubuntu@gpu-1:2222", "role": "master"}`)), admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the node to be added, got %d: %s", w.Code, w.Body.String())
+	}
+	cn := &llmcloudv1alpha1.ClusterNode{}
+	if err := s.client.Get(context.Background(), client.ObjectKey{Name: "gpu-1"}, cn); err != nil {
+		t.Fatalf("Expected a ClusterNode named after the host: %v", err)
+	}
+	if cn.Spec.Host != "ubuntu@gpu-1:2222" || cn.Spec.Role != "controller" || cn.Spec.State != "Joined" {
+		t.Errorf("Unexpected spec %+v", cn.Spec)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleClusterNodes(w, withClaims(httptest.NewRequest("POST", "/api/v1/nodes",
+		strings.NewReader(`{"host": "gpu-1"}`)), admin))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a missing role to be rejected, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.handleClusterNodes(w, withClaims(httptest.NewRequest("POST", "/api/v1/nodes",
+		strings.NewReader(`{"host": "gpu-1", "role": "worker"}`)), admin))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected a second node for the host to conflict, got %d", w.Code)
+	}
+}
+
+func TestHandleNodeActionsRemove(t *testing.T) {
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	c := setupTestClient()
+	if err := c.Create(context.Background(), &llmcloudv1alpha1.ClusterNode{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-1"},
+		Spec:       llmcloudv1alpha1.ClusterNodeSpec{Host: "10.0.0.21", NodeName: "gpu-1", State: "Joined"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{client: c}
+
+	w := httptest.NewRecorder()
+	s.handleNodeActions(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/nodes/gpu-1", nil), admin))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected the removal to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	cn := &llmcloudv1alpha1.ClusterNode{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "worker-1"}, cn); err != nil || cn.Spec.State != "Removed" {
+		t.Errorf("Expected the node's ClusterNode to be removed, got %+v, %v", cn.Spec, err)
+	}
+
+	// A node joined by hand gets a ClusterNode to remove it
+	w = httptest.NewRecorder()
+	s.handleNodeActions(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/nodes/old-1", nil), admin))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("Expected the removal to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	var adopted llmcloudv1alpha1.ClusterNode
+	if err := json.NewDecoder(w.Body).Decode(&adopted); err != nil {
+		t.Fatal(err)
+	}
+	if adopted.Name != "old-1" || adopted.Spec.NodeName != "old-1" || adopted.Spec.Host != "" || adopted.Spec.State != "Removed" {
+		t.Errorf("Unexpected ClusterNode %+v", adopted)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleNodeActions(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/nodes/gpu-1", nil),
+		&auth.Claims{Username: "alice"}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected users to be forbidden, got %d", w.Code)
+	}
+}
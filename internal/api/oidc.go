@@ -0,0 +1,142 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// oidcStateCookie and oidcNonceCookie hold the values a login through the OIDC
+	// provider must come back with, tying the callback to the browser that started it
+	oidcStateCookie = "llmcloud_oidc_state"
+	oidcNonceCookie = "llmcloud_oidc_nonce"
+	// oidcLoginTimeout is how long the user has to log in at the provider
+	oidcLoginTimeout = 10 * time.Minute
+)
+
+// handleOIDCStatus handles GET /api/v1/auth/oidc, telling the login page whether to
+// offer login through the OIDC provider
+func (s *Server) handleOIDCStatus(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, map[string]bool{"enabled": s.OIDC != nil})
+}
+
+// handleOIDCLogin handles GET /api/v1/auth/oidc/login, redirecting the browser to the
+// OIDC provider's login page
+func (s *Server) handleOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.OIDC == nil {
+		writeProblem(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	state, err := randomHex(16)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	setOIDCCookie(w, r, oidcStateCookie, state, int(oidcLoginTimeout.Seconds()))
+	setOIDCCookie(w, r, oidcNonceCookie, nonce, int(oidcLoginTimeout.Seconds()))
+	http.Redirect(w, r, s.OIDC.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+// handleOIDCCallback handles GET /api/v1/auth/oidc/callback, where the OIDC provider
+// sends the browser back after login. The user gets the same token a local login
+// issues, and is redirected to the SPA's login page with it in the URL fragment
+func (s *Server) handleOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.OIDC == nil {
+		writeProblem(w, "OIDC login is not configured", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	if reason := query.Get("error"); reason != "" {
+		writeProblem(w, "Login failed at the identity provider: "+reason, http.StatusUnauthorized)
+		return
+	}
+	state, err := r.Cookie(oidcStateCookie)
+	if err != nil || state.Value == "" || state.Value != query.Get("state") {
+		writeProblem(w, "Login state does not match; start the login again", http.StatusBadRequest)
+		return
+	}
+	nonce, err := r.Cookie(oidcNonceCookie)
+	if err != nil {
+		writeProblem(w, "Login state does not match; start the login again", http.StatusBadRequest)
+		return
+	}
+	setOIDCCookie(w, r, oidcStateCookie, "", -1)
+	setOIDCCookie(w, r, oidcNonceCookie, "", -1)
+
+	ctx := r.Context()
+	user, err := s.OIDC.Exchange(ctx, query.Get("code"), nonce.Value)
+	metrics.ObserveLogin(err == nil)
+	if err != nil {
+		log.FromContext(ctx).Info("OIDC login failed", "reason", err.Error())
+		writeProblem(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if s.Audit != nil {
+		s.Audit.Record(ctx, auditEvent(r, user.Spec.Username, http.StatusOK))
+	}
+
+	if err := auth.AddGroupProjects(ctx, s.client, user); err != nil {
+		writeError(w, "Failed to resolve group memberships", err)
+		return
+	}
+	token, err := auth.GenerateOIDCJWT(user)
+	if err != nil {
+		writeProblem(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	projects, _ := json.Marshal(append([]string{}, user.Spec.Projects...))
+	fragment := url.Values{
+		"token":    {token},
+		"username": {user.Spec.Username},
+		"isAdmin":  {strconv.FormatBool(user.Spec.IsAdmin)},
+		"projects": {string(projects)},
+	}
+	http.Redirect(w, r, "/login#"+fragment.Encode(), http.StatusFound)
+}
+
+// setOIDCCookie sets a cookie scoped to the OIDC login routes, deleting it when maxAge
+// is negative
+func setOIDCCookie(w http.ResponseWriter, r *http.Request, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/api/v1/auth/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// randomHex returns n random bytes, hex encoded
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
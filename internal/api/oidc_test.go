@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleOIDCStatus(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	w := httptest.NewRecorder()
+	s.handleAPI(w, httptest.NewRequest("GET", "/api/v1/auth/oidc", nil))
+	var status struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected the OIDC status without a token, got %d (%v)", w.Code, err)
+	}
+	if status.Enabled {
+		t.Error("Expected OIDC to be reported disabled without a provider")
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPI(w, httptest.NewRequest("GET", "/api/v1/auth/oidc/login", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected login to be unavailable without a provider, got %d", w.Code)
+	}
+}
+
+func TestHandleOIDCLogin(t *testing.T) {
+	// Discovery only needs the endpoints the login redirect uses
+	idp := httptest.NewServer(nil)
+	defer idp.Close()
+	idp.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/auth",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/keys",
+		})
+	})
+	provider, err := auth.NewOIDCProvider(context.Background(), auth.OIDCConfig{
+		IssuerURL: idp.URL, ClientID: "llmcloud", RedirectURL: "https://cloud.example.com/api/v1/auth/oidc/callback",
+	})
+	if err != nil {
+		t.Fatalf("Failed to discover provider: %v", err)
+	}
+	s := &Server{client: setupTestClient(), OIDC: provider}
+
+	w := httptest.NewRecorder()
+	s.handleAPI(w, httptest.NewRequest("GET", "/api/v1/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("Expected a redirect to the provider, got %d: %s", w.Code, w.Body.String())
+	}
+	location, _ := url.Parse(w.Header().Get("Location"))
+	cookies := map[string]string{}
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie.Value
+	}
+	query := location.Query()
+	if location.Path != "/auth" || query.Get("client_id") != "llmcloud" ||
+		query.Get("state") != cookies[oidcStateCookie] || query.Get("nonce") != cookies[oidcNonceCookie] {
+		t.Errorf("Expected the provider's login with the state and nonce of the cookies, got %s and %v", location, cookies)
+	}
+
+	// A callback that does not carry the browser's state is refused before the code is redeemed
+	req := httptest.NewRequest("GET", "/api/v1/auth/oidc/callback?code=abc&state=forged", nil)
+	req.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: cookies[oidcStateCookie]})
+	req.AddCookie(&http.Cookie{Name: oidcNonceCookie, Value: cookies[oidcNonceCookie]})
+	w = httptest.NewRecorder()
+	s.handleAPI(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected a forged state to be refused, got %d", w.Code)
+	}
+}
+
+func TestHandleRefreshRejectsOIDCTokens(t *testing.T) {
+	if err := auth.InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	s := &Server{client: setupTestClient()}
+	req := httptest.NewRequest("POST", "/api/v1/auth/refresh", nil)
+	req = req.WithContext(context.WithValue(req.Context(), claimsKey, &auth.Claims{Username: "alice", Provider: auth.ProviderOIDC}))
+	w := httptest.NewRecorder()
+	s.handleRefresh(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected an OIDC token to need a new login, got %d", w.Code)
+	}
+}
@@ -0,0 +1,341 @@
+package api
+
+import (
+	_ "embed"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// apiDocsPage is the Swagger UI page /api/v1/docs serves, rendering /api/v1/openapi.json
+//
+//go:embed openapi.html
+var apiDocsPage []byte
+
+// publicRoutes are the "METHOD pattern" routes served without authentication, outside
+// the router
+var publicRoutes = []string{
+	"POST /api/v1/auth/login",
+	"POST /api/v1/auth/password",
+	"GET /api/v1/auth/oidc",
+	"GET /api/v1/auth/oidc/login",
+	"GET /api/v1/auth/oidc/callback",
+	"GET /api/v1/openapi.json",
+	"GET /api/v1/docs",
+}
+
+// routeSummaries describe each "METHOD pattern" route of the API in the OpenAPI
+// document. Every route must have one, which TestOpenAPIDocumentsEveryRoute checks
+var routeSummaries = map[string]string{
+	"POST /api/v1/auth/login":          "Log in with a username and password, returning a token",
+	"POST /api/v1/auth/password":       "Change a password and log in, returning a token",
+	"GET /api/v1/auth/oidc":            "Report whether OIDC login is enabled",
+	"GET /api/v1/auth/oidc/login":      "Redirect to the OIDC provider to log in",
+	"GET /api/v1/auth/oidc/callback":   "Complete an OIDC login, returning a token",
+	"GET /api/v1/openapi.json":         "Get this OpenAPI document",
+	"GET /api/v1/docs":                 "Browse this OpenAPI document with Swagger UI",
+	"POST /api/v1/auth/tokens":         "Issue a read-only token for the caller",
+	"POST /api/v1/auth/refresh":        "Refresh the caller's token",
+	"POST /api/v1/auth/logout":         "Revoke the caller's token",
+	"GET /api/v1/me/apikeys":           "List the caller's API keys",
+	"POST /api/v1/me/apikeys":          "Create an API key, returning the key once",
+	"GET /api/v1/me/apikeys/{name}":    "Describe one of the caller's API keys",
+	"DELETE /api/v1/me/apikeys/{name}": "Revoke one of the caller's API keys",
+
+	"GET /api/v1/users":           "List users",
+	"POST /api/v1/users":          "Create a user",
+	"GET /api/v1/users/{name}":    "Get a user",
+	"PUT /api/v1/users/{name}":    "Update a user",
+	"DELETE /api/v1/users/{name}": "Delete a user",
+
+	"GET /api/v1/groups":           "List groups",
+	"POST /api/v1/groups":          "Create a group",
+	"GET /api/v1/groups/{name}":    "Get a group",
+	"PUT /api/v1/groups/{name}":    "Update a group",
+	"DELETE /api/v1/groups/{name}": "Delete a group",
+
+	"GET /api/v1/projects":              "List the caller's projects",
+	"POST /api/v1/projects":             "Create a project (admin only)",
+	"GET /api/v1/projects/{name}":       "Get a project",
+	"DELETE /api/v1/projects/{name}":    "Delete a project and everything in it (admin only)",
+	"PUT /api/v1/projects/{name}/quota": "Set the resource quotas of a project",
+	"GET /api/v1/usage/projects/{name}": "Get the resource usage of a project",
+
+	"GET /api/v1/sshkeys":           "List the SSH keys the caller can manage",
+	"POST /api/v1/sshkeys":          "Register an SSH key",
+	"GET /api/v1/sshkeys/{name}":    "Get an SSH key",
+	"DELETE /api/v1/sshkeys/{name}": "Delete an SSH key",
+
+	"GET /api/v1/templates":           "List VM templates",
+	"POST /api/v1/templates":          "Create a VM template",
+	"GET /api/v1/templates/{name}":    "Get a VM template",
+	"PUT /api/v1/templates/{name}":    "Update a VM template",
+	"DELETE /api/v1/templates/{name}": "Delete a VM template",
+
+	"GET /api/v1/images":                "List VM images",
+	"POST /api/v1/images":               "Register a container disk or create an uploaded VM image",
+	"GET /api/v1/images/{name}":         "Get a VM image",
+	"DELETE /api/v1/images/{name}":      "Delete a VM image no VM boots",
+	"POST /api/v1/images/{name}/upload": "Upload the qcow2 or raw disk of a VM image",
+
+	"GET /api/v1/nodes":             "List the Kubernetes nodes with their resources and GPUs",
+	"POST /api/v1/nodes":            "Join a node to the cluster",
+	"DELETE /api/v1/nodes/{name}":   "Remove a node from the cluster",
+	"GET /api/v1/clusternodes":      "List the ClusterNodes and their join progress",
+	"GET /api/v1/cluster/storage":   "Get the storage capacity of the cluster",
+	"GET /api/v1/cluster/gpus":      "List the GPUs in the cluster",
+	"GET /api/v1/capacity":          "Estimate whether VMs of the given resources fit in the cluster",
+	"GET /api/v1/audit":             "List audit events",
+	"GET /api/v1/backup":            "Download a backup of the llmcloud resources",
+	"POST /api/v1/backup":           "Restore a backup",
+	"POST /api/v1/backup/snapshots": "Snapshot the VM disks and download a backup referencing them",
+	"GET /api/v1/catalog/models":    "List the models of the catalog",
+	"POST /api/v1/preview/vm":       "Preview the KubeVirt VirtualMachine a VM would get",
+
+	"GET /api/v1/namespaces/{namespace}/{resource}":           "List VMs, models or services in a namespace",
+	"POST /api/v1/namespaces/{namespace}/{resource}":          "Create a VM, model or service",
+	"GET /api/v1/namespaces/{namespace}/{resource}/{name}":    "Get a VM, model or service",
+	"PUT /api/v1/namespaces/{namespace}/{resource}/{name}":    "Replace a VM, model or service",
+	"PATCH /api/v1/namespaces/{namespace}/{resource}/{name}":  "Patch a VM, model or service with a JSON merge patch",
+	"DELETE /api/v1/namespaces/{namespace}/{resource}/{name}": "Delete a VM, model or service",
+
+	"GET /api/v1/namespaces/{namespace}/vms/{name}/credentials": "Get the generated login credentials of a VM",
+	"GET /api/v1/namespaces/{namespace}/vms/{name}/expose":      "List the ports a VM exposes",
+	"POST /api/v1/namespaces/{namespace}/vms/{name}/expose":     "Expose a port of a VM",
+	"DELETE /api/v1/namespaces/{namespace}/vms/{name}/expose":   "Stop exposing a port of a VM",
+	"GET /api/v1/cloudinit/vm/{namespace}/{name}":               "Get the cloud-init status of a VM",
+	"POST /api/v1/cloudinit/vm/{namespace}/{name}":              "Report the cloud-init status of a VM its guest agent cannot read",
+	"POST /api/v1/actions/vm/{namespace}/{name}/{action}":       "Start, stop or reboot a VM",
+	"POST /api/v1/actions/model/{namespace}/{name}/{action}":    "Retry a failed LLM model, or start or stop it",
+	"POST /api/v1/bulk/{resource}/{action}":                     "Start, stop or delete many VMs or models at once",
+	"GET /api/v1/describe/vm/{namespace}/{name}":                "Describe the KubeVirt VM of a VM",
+	"GET /api/v1/events/{resource}/{namespace}/{name}":          "List the events of a VM, model or service",
+	"GET /api/v1/events/{resource}/{name}":                      "List the events of a project or user",
+	"GET /api/v1/logs/{namespace}/{resource}/{name}":            "Stream the log of the newest pod of a VM, model or service",
+	"GET /api/v1/console/vm/{namespace}/{name}":                 "Open the VNC or serial console of a VM over a WebSocket",
+	"GET /api/v1/watch/namespaces/{namespace}/{resource}":       "Stream changes to VMs, models or services as server-sent events",
+
+	"POST /api/v1/inference/{namespace}/{model}/{route...}":       "Proxy an OpenAI-compatible request to a model",
+	"GET /api/v1/playground/{namespace}/{model}/sessions":         "List the caller's playground conversations with a model",
+	"POST /api/v1/playground/{namespace}/{model}/sessions":        "Start a playground conversation",
+	"GET /api/v1/playground/{namespace}/{model}/sessions/{id}":    "Get a playground conversation",
+	"DELETE /api/v1/playground/{namespace}/{model}/sessions/{id}": "Delete a playground conversation",
+	"POST /api/v1/playground/{namespace}/{model}/chat":            "Chat with a model, saving the exchange to a conversation",
+}
+
+// pathParameter matches the wildcards of a route pattern, e.g. {name} or {route...}
+var pathParameter = regexp.MustCompile(`\{([a-z]+)(\.\.\.)?\}`)
+
+// openAPIDocument is an OpenAPI 3 document
+type openAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Info       openAPIInfo                            `json:"info"`
+	Paths      map[string]map[string]openAPIOperation `json:"paths"`
+	Components openAPIComponents                      `json:"components"`
+	Security   []map[string][]string                  `json:"security"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Summary     string                     `json:"summary"`
+	Tags        []string                   `json:"tags"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIBody               `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+	// Security is empty for public routes, overriding the document's
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name     string        `json:"name"`
+	In       string        `json:"in"`
+	Required bool          `json:"required,omitempty"`
+	Schema   openAPISchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema openAPISchema `json:"schema"`
+}
+
+type openAPISchema struct {
+	Ref        string                   `json:"$ref,omitempty"`
+	Type       string                   `json:"type,omitempty"`
+	Format     string                   `json:"format,omitempty"`
+	Enum       []string                 `json:"enum,omitempty"`
+	Properties map[string]openAPISchema `json:"properties,omitempty"`
+	Items      *openAPISchema           `json:"items,omitempty"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]openAPISecurityScheme `json:"securitySchemes"`
+}
+
+type openAPISecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+}
+
+// openAPI describes the routes of rt and the public routes as an OpenAPI 3 document.
+// Requests and responses are JSON objects, and errors are problem details
+func (rt *router) openAPI() *openAPIDocument {
+	doc := &openAPIDocument{
+		OpenAPI: "3.0.3",
+		Info: openAPIInfo{
+			Title:       "LLMCloud API",
+			Description: "Manage projects, VMs, LLM models and services on an LLMCloud cluster",
+			Version:     "v1",
+		},
+		Paths: map[string]map[string]openAPIOperation{},
+		Components: openAPIComponents{
+			Schemas: map[string]openAPISchema{
+				"Problem": {Type: "object", Properties: map[string]openAPISchema{
+					"type":    {Type: "string"},
+					"title":   {Type: "string"},
+					"status":  {Type: "integer"},
+					"detail":  {Type: "string"},
+					"code":    {Type: "integer"},
+					"reason":  {Type: "string"},
+					"message": {Type: "string"},
+					"details": {Type: "array", Items: &openAPISchema{Type: "object", Properties: map[string]openAPISchema{
+						"field":   {Type: "string"},
+						"type":    {Type: "string"},
+						"message": {Type: "string"},
+					}}},
+				}},
+			},
+			SecuritySchemes: map[string]openAPISecurityScheme{
+				"bearerAuth": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				"apiKey":     {Type: "apiKey", In: "header", Name: "X-API-Key"},
+			},
+		},
+		Security: []map[string][]string{{"bearerAuth": {}}, {"apiKey": {}}},
+	}
+
+	for _, route := range publicRoutes {
+		method, pattern, _ := strings.Cut(route, " ")
+		op := rt.openAPIOperation(method, pattern)
+		op.Security = &[]map[string][]string{}
+		doc.addOperation(method, pattern, op)
+	}
+	for pattern, methods := range rt.methods {
+		for _, method := range methods {
+			doc.addOperation(method, pattern, rt.openAPIOperation(method, pattern))
+		}
+	}
+	return doc
+}
+
+func (doc *openAPIDocument) addOperation(method, pattern string, op openAPIOperation) {
+	path := pathParameter.ReplaceAllString(pattern, "{$1}")
+	if doc.Paths[path] == nil {
+		doc.Paths[path] = map[string]openAPIOperation{}
+	}
+	doc.Paths[path][strings.ToLower(method)] = op
+}
+
+// openAPIOperation describes the route for method and pattern
+func (rt *router) openAPIOperation(method, pattern string) openAPIOperation {
+	tag, _, _ := strings.Cut(strings.TrimPrefix(pattern, "/api/v1/"), "/")
+	op := openAPIOperation{
+		OperationID: operationID(method, pattern),
+		Summary:     routeSummaries[method+" "+pattern],
+		Tags:        []string{tag},
+		Responses: map[string]openAPIResponse{
+			"default": {
+				Description: "The error, as RFC 7807 problem details",
+				Content: map[string]openAPIMediaType{
+					"application/problem+json": {Schema: openAPISchema{Ref: "#/components/schemas/Problem"}},
+				},
+			},
+		},
+	}
+
+	for _, match := range pathParameter.FindAllStringSubmatch(pattern, -1) {
+		param := openAPIParameter{Name: match[1], In: "path", Required: true, Schema: openAPISchema{Type: "string"}}
+		if match[1] == "resource" {
+			param.Schema.Enum = slices.Sorted(maps.Keys(watchedResources))
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+	if rt.dryRuns[method+" "+pattern] && method != http.MethodGet && method != http.MethodDelete {
+		op.Parameters = append(op.Parameters, openAPIParameter{Name: "dryRun", In: "query", Schema: openAPISchema{Type: "boolean"}})
+	}
+
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		contentType := "application/json"
+		if method == http.MethodPatch {
+			contentType = "application/merge-patch+json"
+		}
+		schema := openAPISchema{Type: "object"}
+		if strings.HasSuffix(pattern, "/upload") {
+			contentType, schema = "application/octet-stream", openAPISchema{Type: "string", Format: "binary"}
+		}
+		op.RequestBody = &openAPIBody{Content: map[string]openAPIMediaType{contentType: {Schema: schema}}}
+	}
+	if method == http.MethodDelete {
+		op.Responses["2XX"] = openAPIResponse{Description: "Deleted"}
+	} else {
+		op.Responses["2XX"] = openAPIResponse{
+			Description: "Success",
+			Content:     map[string]openAPIMediaType{"application/json": {Schema: openAPISchema{Type: "object"}}},
+		}
+	}
+	return op
+}
+
+// operationID names the route for method and pattern for generated clients, e.g.
+// getNamespacesNamespaceResourceName for GET /api/v1/namespaces/{namespace}/{resource}/{name}
+func operationID(method, pattern string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.FieldsFunc(strings.TrimPrefix(pattern, "/api/v1/"), func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-'
+	}) {
+		b.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+	return b.String()
+}
+
+// handleOpenAPI serves the OpenAPI document of the API, which needs no authentication
+// so that clients can be generated from it
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.routesOnce.Do(func() { s.routes = s.newRoutes() })
+	s.writeJSON(w, s.routes.openAPI())
+}
+
+// handleAPIDocs serves Swagger UI for the OpenAPI document
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(apiDocsPage)
+}
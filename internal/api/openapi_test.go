@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	rt := (&Server{client: setupTestClient()}).newRoutes()
+
+	routes := slices.Clone(publicRoutes)
+	for pattern, methods := range rt.methods {
+		for _, method := range methods {
+			routes = append(routes, method+" "+pattern)
+		}
+	}
+	for _, route := range routes {
+		if routeSummaries[route] == "" {
+			t.Errorf("Expected a summary for %s", route)
+		}
+	}
+	for route := range routeSummaries {
+		if !slices.Contains(routes, route) {
+			t.Errorf("Expected no summary for %s, which is not a route", route)
+		}
+	}
+
+	ids := map[string]string{}
+	for path, operations := range rt.openAPI().Paths {
+		for method, op := range operations {
+			if other, ok := ids[op.OperationID]; ok {
+				t.Errorf("Expected unique operation IDs, got %s for %s %s and %s", op.OperationID, method, path, other)
+			}
+			ids[op.OperationID] = method + " " + path
+		}
+	}
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+
+	w := httptest.NewRecorder()
+	s.handleAPI(w, httptest.NewRequest("GET", "/api/v1/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the document without authentication, got %d: %s", w.Code, w.Body.String())
+	}
+	var doc openAPIDocument
+	if err := json.NewDecoder(w.Body).Decode(&doc); err != nil {
+		t.Fatalf("Failed to decode document: %v", err)
+	}
+	if doc.OpenAPI != "3.0.3" || len(doc.Security) != 2 {
+		t.Errorf("Expected an OpenAPI 3 document requiring a token or API key, got %s %v", doc.OpenAPI, doc.Security)
+	}
+
+	patch, ok := doc.Paths["/api/v1/namespaces/{namespace}/{resource}/{name}"]["patch"]
+	if !ok {
+		t.Fatal("Expected PATCH of a namespaced resource")
+	}
+	var params []string
+	for _, p := range patch.Parameters {
+		params = append(params, p.In+":"+p.Name)
+	}
+	if !slices.Equal(params, []string{"path:namespace", "path:resource", "path:name", "query:dryRun"}) {
+		t.Errorf("Expected the path parameters and dryRun, got %v", params)
+	}
+	if enum := patch.Parameters[1].Schema.Enum; !slices.Equal(enum, []string{"models", "services", "vms"}) {
+		t.Errorf("Expected the resources, got %v", enum)
+	}
+	if _, ok := patch.RequestBody.Content["application/merge-patch+json"]; !ok {
+		t.Errorf("Expected a merge patch body, got %v", patch.RequestBody.Content)
+	}
+
+	if del := doc.Paths["/api/v1/nodes/{name}"]["delete"]; len(del.Parameters) != 1 || del.Tags[0] != "nodes" {
+		t.Errorf("Expected no dryRun for a route without dry runs, got %+v", del)
+	}
+	if _, ok := doc.Paths["/api/v1/inference/{namespace}/{model}/{route}"]["post"]; !ok {
+		t.Error("Expected the inference route with its trailing wildcard")
+	}
+	login := doc.Paths["/api/v1/auth/login"]["post"]
+	if login.Security == nil || len(*login.Security) != 0 {
+		t.Errorf("Expected login to need no authentication, got %v", login.Security)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleAPI(w, httptest.NewRequest("GET", "/api/v1/docs", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/api/v1/openapi.json") {
+		t.Errorf("Expected Swagger UI for the document, got %d", w.Code)
+	}
+}
@@ -0,0 +1,454 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+)
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;create;update;delete
+
+const (
+	// playgroundUserLabel and playgroundModelLabel mark the ConfigMap of a playground
+	// session with the user it belongs to and the model it is with. The user is hashed
+	// by playgroundUserHash, as usernames such as oidc:alice@example.com are not valid
+	// label values; playgroundUserAnnotation holds it as is
+	playgroundUserLabel      = "llmcloud.io/playground-user"
+	playgroundModelLabel     = "llmcloud.io/playground-model"
+	playgroundUserAnnotation = "llmcloud.io/playground-user"
+
+	// playgroundTitleAnnotation is the title of a playground session
+	playgroundTitleAnnotation = "llmcloud.io/playground-title"
+
+	// maxPlaygroundMessages is how many messages a session keeps. The oldest are
+	// dropped first, apart from a system prompt
+	maxPlaygroundMessages = 100
+
+	// maxPlaygroundTitle is how many characters of its first message a session's title keeps
+	maxPlaygroundTitle = 60
+)
+
+// chatMessage is a message of a playground conversation, in the format of the chat
+// APIs of both ollama and OpenAI
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatSession is a playground conversation of a user with a model. It is stored in a
+// ConfigMap in the model's namespace, whose name is the session ID
+type chatSession struct {
+	ID       string        `json:"id"`
+	Model    string        `json:"model"`
+	Title    string        `json:"title,omitempty"`
+	Created  metav1.Time   `json:"created"`
+	Messages []chatMessage `json:"messages,omitempty"`
+}
+
+// chatEvent is a server-sent event of a playground chat response: a piece of the reply,
+// the end of the reply with the session it was saved to, or an error
+type chatEvent struct {
+	Content string `json:"content,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Session string `json:"session,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// playgroundPath splits /api/v1/playground/{namespace}/{model}/{rest...}, reporting
+// whether rest has the expected number of segments
+func playgroundPath(r *http.Request, segments int) (namespace, model string, rest []string, ok bool) {
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/v1/playground/"))
+	if len(parts) != 2+segments {
+		return "", "", nil, false
+	}
+	return parts[0], parts[1], parts[2:], true
+}
+
+// handlePlaygroundSessions handles /api/v1/playground/{namespace}/{model}/sessions. GET
+// lists the caller's sessions with the model, newest first and without their messages,
+// and POST starts one, optionally with a system prompt
+func (s *Server) handlePlaygroundSessions(w http.ResponseWriter, r *http.Request) {
+	namespace, model, _, ok := playgroundPath(r, 1)
+	if !ok {
+		writeProblem(w, "Invalid path, expected: /api/v1/playground/{namespace}/{model}/sessions", http.StatusBadRequest)
+		return
+	}
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var configMaps corev1.ConfigMapList
+		if err := s.client.List(ctx, &configMaps, client.InNamespace(namespace), client.MatchingLabels{
+			playgroundUserLabel:  playgroundUserHash(claims.Username),
+			playgroundModelLabel: model,
+		}); err != nil {
+			writeError(w, "Failed to list sessions", err)
+			return
+		}
+		sessions := make([]chatSession, 0, len(configMaps.Items))
+		for i := range configMaps.Items {
+			if configMaps.Items[i].Annotations[playgroundUserAnnotation] != claims.Username {
+				continue
+			}
+			session := sessionFromConfigMap(&configMaps.Items[i])
+			session.Messages = nil
+			sessions = append(sessions, *session)
+		}
+		slices.SortFunc(sessions, func(a, b chatSession) int {
+			return b.Created.Compare(a.Created.Time)
+		})
+		s.writeJSON(w, sessions)
+
+	case http.MethodPost:
+		var req struct {
+			Title  string `json:"title"`  // Optional, defaults to the start of the first message
+			System string `json:"system"` // Optional system prompt
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			writeProblem(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		session := &chatSession{Model: model, Title: req.Title}
+		if req.System != "" {
+			session.Messages = []chatMessage{{Role: "system", Content: req.System}}
+		}
+		if err := s.savePlaygroundSession(ctx, namespace, claims.Username, session, nil); err != nil {
+			writeError(w, "Failed to create session", err)
+			return
+		}
+		s.writeJSON(w, session)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlaygroundSession handles /api/v1/playground/{namespace}/{model}/sessions/{id}.
+// GET returns the session with its messages and DELETE deletes it. Sessions of other
+// users are not found
+func (s *Server) handlePlaygroundSession(w http.ResponseWriter, r *http.Request) {
+	namespace, model, rest, ok := playgroundPath(r, 2)
+	if !ok {
+		writeProblem(w, "Invalid path, expected: /api/v1/playground/{namespace}/{model}/sessions/{id}", http.StatusBadRequest)
+		return
+	}
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	cm, err := s.getPlaygroundSession(ctx, namespace, model, claims.Username, rest[1])
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, sessionFromConfigMap(cm))
+
+	case http.MethodDelete:
+		if err := s.client.Delete(ctx, cm); err != nil {
+			writeError(w, "Failed to delete session", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handlePlaygroundChat handles POST /api/v1/playground/{namespace}/{model}/chat. It sends
+// the conversation of the session, or of a new one when none is given, with the new
+// message to the model, and streams the reply back as server-sent chatEvents as the
+// model generates it. Once complete, the message and the reply are saved to the session
+func (s *Server) handlePlaygroundChat(w http.ResponseWriter, r *http.Request) {
+	if !s.InferenceGateway {
+		writeProblem(w, "Inference gateway is disabled", http.StatusNotFound)
+		return
+	}
+	namespace, name, _, ok := playgroundPath(r, 1)
+	if !ok {
+		writeProblem(w, "Invalid path, expected: /api/v1/playground/{namespace}/{model}/chat", http.StatusBadRequest)
+		return
+	}
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	var req struct {
+		Session string `json:"session"` // Optional, starts a new session when empty
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeProblem(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Message) == "" {
+		writeProblem(w, "Message is required", http.StatusBadRequest)
+		return
+	}
+
+	session := &chatSession{Model: name}
+	var cm *corev1.ConfigMap
+	if req.Session != "" {
+		var err error
+		if cm, err = s.getPlaygroundSession(ctx, namespace, name, claims.Username, req.Session); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		session = sessionFromConfigMap(cm)
+	}
+
+	_, served, target, ok := s.inferenceTarget(w, r, namespace, name)
+	if !ok {
+		return
+	}
+	messages := append(slices.Clone(session.Messages), chatMessage{Role: "user", Content: req.Message})
+	upstream, err := playgroundRequest(ctx, served, target, messages)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	resp, err := http.DefaultClient.Do(upstream)
+	if err != nil {
+		writeProblem(w, fmt.Sprintf("Model %s/%s is unreachable: %v", namespace, served.Name, err), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		writeProblem(w, fmt.Sprintf("Model %s/%s responded with %s: %s", namespace, served.Name, resp.Status, bytes.TrimSpace(detail)),
+			http.StatusBadGateway)
+		return
+	}
+	body := resp.Body
+	if s.Meter != nil {
+		body = s.Meter.Count(namespace, served.Name, body)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	send := func(event chatEvent) {
+		data, _ := json.Marshal(event)
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	var reply strings.Builder
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		content, err := chatChunkContent(scanner.Bytes())
+		if err != nil {
+			send(chatEvent{Error: err.Error()})
+			return
+		}
+		if content != "" {
+			reply.WriteString(content)
+			send(chatEvent{Content: content})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		send(chatEvent{Error: fmt.Sprintf("Reading the reply failed: %v", err)})
+		return
+	}
+
+	session.Messages = append(messages, chatMessage{Role: "assistant", Content: reply.String()})
+	if session.Title == "" {
+		session.Title = playgroundTitle(req.Message)
+	}
+	// The reply is saved even if the client has gone away
+	if err := s.savePlaygroundSession(context.WithoutCancel(ctx), namespace, claims.Username, session, cm); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to save playground session", "namespace", namespace, "model", name)
+		send(chatEvent{Error: fmt.Sprintf("Failed to save session: %v", err)})
+		return
+	}
+	send(chatEvent{Done: true, Session: session.ID})
+}
+
+// playgroundRequest builds the request streaming the model's reply to messages, for the
+// chat API of the protocol the model serves
+func playgroundRequest(ctx context.Context, served *llmcloudv1alpha1.LLMModel, target *url.URL, messages []chatMessage) (*http.Request, error) {
+	body := map[string]interface{}{"messages": messages, "stream": true}
+	route := "chat/completions"
+	if served.Spec.EffectiveServeProtocol() == llmcloudv1alpha1.ServeProtocolOllama {
+		route = "chat"
+		body["model"] = controller.ModelReference(&served.Spec)
+	} else {
+		body["model"] = served.Spec.ModelName
+		// The usage is what the meter counts the tokens of the reply from
+		body["stream_options"] = map[string]bool{"include_usage": true}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	u := *target
+	u.Path = path.Join(target.Path, served.Spec.ProxyRoutePrefix(), route)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// chatChunkContent returns the text a line of a streamed chat reply adds: a line of
+// ollama's NDJSON or a server-sent event of OpenAI's API. Lines carrying no JSON
+// object, such as OpenAI's final [DONE], add nothing
+func chatChunkContent(line []byte) (string, error) {
+	line = bytes.TrimSpace(line)
+	if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+		line = bytes.TrimSpace(data)
+	}
+	if len(line) == 0 || line[0] != '{' {
+		return "", nil
+	}
+
+	var chunk struct {
+		Message *chatMessage `json:"message"`
+		Choices []struct {
+			Delta chatMessage `json:"delta"`
+		} `json:"choices"`
+		// A string from ollama, an object with a message from OpenAI
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(line, &chunk); err != nil {
+		return "", fmt.Errorf("invalid reply from the model: %w", err)
+	}
+	if len(chunk.Error) > 0 && string(chunk.Error) != "null" {
+		var message string
+		if json.Unmarshal(chunk.Error, &message) != nil {
+			var openAIError struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(chunk.Error, &openAIError)
+			message = openAIError.Message
+		}
+		return "", fmt.Errorf("the model failed: %s", message)
+	}
+	if chunk.Message != nil {
+		return chunk.Message.Content, nil
+	}
+	var content strings.Builder
+	for _, choice := range chunk.Choices {
+		content.WriteString(choice.Delta.Content)
+	}
+	return content.String(), nil
+}
+
+// playgroundTitle returns the title of a session starting with message
+func playgroundTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if runes := []rune(title); len(runes) > maxPlaygroundTitle {
+		title = string(runes[:maxPlaygroundTitle]) + "…"
+	}
+	return title
+}
+
+// playgroundUserHash returns the value of playgroundUserLabel for username
+func playgroundUserHash(username string) string {
+	sum := sha256.Sum256([]byte(username))
+	return hex.EncodeToString(sum[:16])
+}
+
+// getPlaygroundSession returns the ConfigMap of the session id of username with model,
+// and a NotFound error for a session of another user or model
+func (s *Server) getPlaygroundSession(ctx context.Context, namespace, model, username, id string) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: id}, cm); err != nil {
+		return nil, err
+	}
+	if cm.Annotations[playgroundUserAnnotation] != username || cm.Labels[playgroundModelLabel] != model {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), id)
+	}
+	return cm, nil
+}
+
+// sessionFromConfigMap returns the session stored in cm
+func sessionFromConfigMap(cm *corev1.ConfigMap) *chatSession {
+	session := &chatSession{
+		ID:       cm.Name,
+		Model:    cm.Labels[playgroundModelLabel],
+		Title:    cm.Annotations[playgroundTitleAnnotation],
+		Created:  cm.CreationTimestamp,
+		Messages: []chatMessage{},
+	}
+	// A session edited into an invalid state starts over
+	_ = json.Unmarshal([]byte(cm.Data["messages"]), &session.Messages)
+	return session
+}
+
+// savePlaygroundSession stores session in current, its ConfigMap, or creates one when
+// current is nil and fills in the session's ID. Only the newest maxPlaygroundMessages
+// messages are kept
+func (s *Server) savePlaygroundSession(ctx context.Context, namespace, username string, session *chatSession, current *corev1.ConfigMap) error {
+	if excess := len(session.Messages) - maxPlaygroundMessages; excess > 0 {
+		start := 0
+		if session.Messages[0].Role == "system" {
+			start = 1
+		}
+		session.Messages = slices.Delete(session.Messages, start, start+excess)
+	}
+	messages, err := json.Marshal(session.Messages)
+	if err != nil {
+		return err
+	}
+
+	if current != nil {
+		if current.Annotations == nil {
+			current.Annotations = map[string]string{}
+		}
+		current.Annotations[playgroundTitleAnnotation] = session.Title
+		current.Data = map[string]string{"messages": string(messages)}
+		return s.client.Update(ctx, current)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "playground-",
+			Namespace:    namespace,
+			Labels: map[string]string{
+				playgroundUserLabel:   playgroundUserHash(username),
+				playgroundModelLabel:  session.Model,
+				"llmcloud.io/managed": "true",
+			},
+			Annotations: map[string]string{
+				playgroundUserAnnotation:  username,
+				playgroundTitleAnnotation: session.Title,
+			},
+		},
+		Data: map[string]string{"messages": string(messages)},
+	}
+	if err := s.client.Create(ctx, cm); err != nil {
+		return err
+	}
+	session.ID = cm.Name
+	session.Created = cm.CreationTimestamp
+	return nil
+}
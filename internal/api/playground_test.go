@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// chatEvents parses the server-sent events of a playground chat response
+func chatEvents(t *testing.T, body string) []chatEvent {
+	t.Helper()
+	var events []chatEvent
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var event chatEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("Invalid event %q: %v", data, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestPlaygroundChat(t *testing.T) {
+	var requests []map[string]interface{}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		switch r.URL.Path {
+		case "/api/chat":
+			for _, token := range []string{"Hel", "lo"} {
+				_, _ = fmt.Fprintf(w, `{"message":{"role":"assistant","content":%q},"done":false}`+"\n", token)
+			}
+			_, _ = fmt.Fprintln(w, `{"done":true,"prompt_eval_count":3,"eval_count":2}`)
+		case "/v1/chat/completions":
+			_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Bonjour\"}}]}\n\n")
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	for name, protocol := range map[string]string{"llama": "ollama", "mistral": "openai"} {
+		_ = c.Create(context.Background(), &llmcloudv1alpha1.LLMModel{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-team"},
+			Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: name, ModelSize: "7b", ServeProtocol: protocol},
+			Status:     llmcloudv1alpha1.LLMModelStatus{Endpoint: backend.URL},
+		})
+	}
+	s := &Server{client: c, InferenceGateway: true}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+	serve := func(h http.HandlerFunc, method, path, body string, claims *auth.Claims) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h(w, withClaims(httptest.NewRequest(method, path, strings.NewReader(body)), claims))
+		return w
+	}
+
+	w := serve(s.handlePlaygroundChat, "POST", "/api/v1/playground/project-team/llama/chat", `{"message": "Say hello"}`, alice)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %d: %s", w.Code, w.Body.String())
+	}
+	events := chatEvents(t, w.Body.String())
+	if len(events) != 3 || events[0].Content != "Hel" || events[1].Content != "lo" || !events[2].Done || events[2].Session == "" {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	id := events[2].Session
+	if requests[0]["model"] != "llama:7b" || requests[0]["stream"] != true {
+		t.Errorf("Unexpected ollama request %v", requests[0])
+	}
+
+	w = serve(s.handlePlaygroundChat, "POST", "/api/v1/playground/project-team/llama/chat",
+		`{"session": "`+id+`", "message": "Again"}`, alice)
+	if events := chatEvents(t, w.Body.String()); len(events) != 3 || events[2].Session != id {
+		t.Fatalf("Expected the reply to be saved to the session, got %+v", events)
+	}
+	if messages := requests[1]["messages"].([]interface{}); len(messages) != 3 {
+		t.Errorf("Expected the conversation to be sent with the new message, got %v", messages)
+	}
+
+	w = serve(s.handlePlaygroundSession, "GET", "/api/v1/playground/project-team/llama/sessions/"+id, "", alice)
+	var session chatSession
+	if err := json.NewDecoder(w.Body).Decode(&session); err != nil {
+		t.Fatal(err)
+	}
+	if session.Title != "Say hello" || len(session.Messages) != 4 || session.Messages[3].Content != "Hello" {
+		t.Errorf("Unexpected session %+v", session)
+	}
+
+	w = serve(s.handlePlaygroundChat, "POST", "/api/v1/playground/project-team/mistral/chat", `{"message": "Say hello in French"}`, alice)
+	if events := chatEvents(t, w.Body.String()); len(events) != 2 || events[0].Content != "Bonjour" || !events[1].Done {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+	if requests[2]["model"] != "mistral" || requests[2]["stream_options"] == nil {
+		t.Errorf("Unexpected OpenAI request %v", requests[2])
+	}
+
+	w = serve(s.handlePlaygroundSessions, "GET", "/api/v1/playground/project-team/llama/sessions", "", alice)
+	var sessions []chatSession
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != id || sessions[0].Messages != nil {
+		t.Errorf("Expected the llama session without its messages, got %+v", sessions)
+	}
+
+	bob := &auth.Claims{Username: "bob", Projects: []string{"team"}}
+	for _, tt := range []struct {
+		h            http.HandlerFunc
+		method, path string
+		body         string
+		claims       *auth.Claims
+		want         int
+	}{
+		{h: s.handlePlaygroundSession, method: "GET", path: "/api/v1/playground/project-team/llama/sessions/" + id, claims: bob, want: http.StatusNotFound},
+		{h: s.handlePlaygroundSession, method: "GET", path: "/api/v1/playground/project-team/mistral/sessions/" + id, claims: alice, want: http.StatusNotFound},
+		{h: s.handlePlaygroundChat, method: "POST", path: "/api/v1/playground/project-team/llama/chat", body: `{"session": "` + id + `", "message": "Hi"}`, claims: bob, want: http.StatusNotFound},
+		{h: s.handlePlaygroundChat, method: "POST", path: "/api/v1/playground/project-team/llama/chat", body: `{"message": " "}`, claims: alice, want: http.StatusBadRequest},
+		{h: s.handlePlaygroundChat, method: "POST", path: "/api/v1/playground/project-team/missing/chat", body: `{"message": "Hi"}`, claims: alice, want: http.StatusNotFound},
+		{h: s.handlePlaygroundSessions, method: "POST", path: "/api/v1/playground/project-team/llama/sessions", body: `{"system": "Be brief"}`, claims: bob, want: http.StatusOK},
+		{h: s.handlePlaygroundSession, method: "DELETE", path: "/api/v1/playground/project-team/llama/sessions/" + id, claims: alice, want: http.StatusNoContent},
+	} {
+		if w := serve(tt.h, tt.method, tt.path, tt.body, tt.claims); w.Code != tt.want {
+			t.Errorf("%s %s as %s: expected %d, got %d: %s", tt.method, tt.path, tt.claims.Username, tt.want, w.Code, w.Body.String())
+		}
+	}
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: id}, &corev1.ConfigMap{})
+	if err == nil {
+		t.Error("Expected the session to be deleted")
+	}
+
+	// OIDC usernames are not valid label values, so the label holds their hash
+	carol := &auth.Claims{Username: "oidc:carol@example.com", Projects: []string{"team"}, Provider: auth.ProviderOIDC}
+	w = serve(s.handlePlaygroundSessions, "POST", "/api/v1/playground/project-team/llama/sessions", `{"title": "OIDC"}`, carol)
+	if err := json.NewDecoder(w.Body).Decode(&session); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("Expected an OIDC user to start a session, got %d: %s", w.Code, w.Body.String())
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: session.ID}, cm); err != nil {
+		t.Fatal(err)
+	}
+	if errs := validation.IsValidLabelValue(cm.Labels[playgroundUserLabel]); len(errs) != 0 {
+		t.Errorf("Expected a valid user label, got %q: %v", cm.Labels[playgroundUserLabel], errs)
+	}
+	w = serve(s.handlePlaygroundSessions, "GET", "/api/v1/playground/project-team/llama/sessions", "", carol)
+	if err := json.NewDecoder(w.Body).Decode(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != session.ID {
+		t.Errorf("Expected the OIDC user's session only, got %+v", sessions)
+	}
+}
+
+func TestChatChunkContent(t *testing.T) {
+	for _, tt := range []struct {
+		line, want string
+		wantErr    bool
+	}{
+		{line: `{"message":{"role":"assistant","content":"Hi"},"done":false}`, want: "Hi"},
+		{line: `data: {"choices":[{"delta":{"content":"Hi"}}]}`, want: "Hi"},
+		{line: `data: [DONE]`},
+		{line: `: keep-alive`},
+		{line: ``},
+		{line: `{"error":"model not found"}`, wantErr: true},
+		{line: `data: {"error":{"message":"context too long"}}`, wantErr: true},
+	} {
+		got, err := chatChunkContent([]byte(tt.line))
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("chatChunkContent(%q) = %q, %v; want %q, error %v", tt.line, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// maxLoginBody bounds the login request the rate limiter reads the username from
+const maxLoginBody = 64 << 10
+
+// LoginLimits configures the brute-force protection of /api/v1/auth/login
+type LoginLimits struct {
+	// FreeAttempts is how many logins may fail in a row, from one address or for one
+	// username, before further attempts are refused for a while. 0 disables the limits
+	FreeAttempts int
+	// Backoff is how long attempts are refused after the first failure beyond
+	// FreeAttempts. It doubles with every further failure, up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// LockoutAttempts is how many logins may fail in a row before the account itself
+	// is locked, for as long as the backoff at that count. The lock is recorded in the
+	// User's status, so it holds across restarts and replicas. 0 disables lockout
+	LockoutAttempts int32
+}
+
+// delay is how long attempts are refused after failures logins failed in a row
+func (l LoginLimits) delay(failures int) time.Duration {
+	if l.FreeAttempts <= 0 || failures <= l.FreeAttempts {
+		return 0
+	}
+	d := l.Backoff
+	for i := l.FreeAttempts + 1; i < failures && d < l.MaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, l.MaxBackoff)
+}
+
+// loginFailures tracks failed logins from one address or for one username
+type loginFailures struct {
+	count int
+	last  time.Time
+	until time.Time
+}
+
+// loginLimiter refuses login attempts from addresses and for usernames that failed
+// too often recently. It is usable as its zero value
+type loginLimiter struct {
+	mu       sync.Mutex
+	failures map[string]*loginFailures
+}
+
+// retryAfter is how long attempts for keys are refused, as of now
+func (l *loginLimiter) retryAfter(now time.Time, keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		if f := l.failures[key]; f != nil {
+			wait = max(wait, f.until.Sub(now))
+		}
+	}
+	return wait
+}
+
+// fail records a failed login for keys. Failures are forgotten once none happened for
+// MaxBackoff after the last refusal ended
+func (l *loginLimiter) fail(limits LoginLimits, now time.Time, keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.failures == nil {
+		l.failures = map[string]*loginFailures{}
+	}
+	for key, f := range l.failures {
+		if now.Sub(f.until) > limits.MaxBackoff && now.Sub(f.last) > limits.MaxBackoff {
+			delete(l.failures, key)
+		}
+	}
+	for _, key := range keys {
+		f := l.failures[key]
+		if f == nil {
+			f = &loginFailures{}
+			l.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		f.until = now.Add(limits.delay(f.count))
+	}
+}
+
+// reset forgets the failed logins for key
+func (l *loginLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.failures, key)
+}
+
+// limitLogins wraps the login handler h, refusing attempts with 429 while the caller's
+// address or the username it logs in as is backing off after failed logins
+func (s *Server) limitLogins(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.LoginLimits.FreeAttempts <= 0 || r.Method != http.MethodPost {
+			h(w, r)
+			return
+		}
+
+		// The body is read here for the username and replayed to the handler
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxLoginBody))
+		if err != nil {
+			writeProblem(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var login struct {
+			Username string `json:"username"`
+		}
+		_ = json.Unmarshal(body, &login)
+
+		keys := []string{"ip:" + sourceIP(r), "user:" + login.Username}
+		if wait := s.logins.retryAfter(time.Now(), keys...); wait > 0 {
+			writeTooManyLogins(w, wait)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		h(recorder, r)
+		switch recorder.status {
+		case http.StatusUnauthorized:
+			s.logins.fail(s.LoginLimits, time.Now(), keys...)
+		case http.StatusOK:
+			// The address keeps its failures; logging into one account must not
+			// clear an attack on others
+			s.logins.reset(keys[1])
+		}
+	}
+}
+
+// writeTooManyLogins refuses a login attempt, telling the client when to retry
+func writeTooManyLogins(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	writeProblem(w, "Too many failed logins; try again later", http.StatusTooManyRequests)
+}
+
+// recordLoginFailure counts a failed login in the status of the user called username,
+// locking the account once LoginLimits.LockoutAttempts logins failed in a row. Unknown
+// and disabled users are ignored
+func (s *Server) recordLoginFailure(ctx context.Context, username string) {
+	if s.LoginLimits.LockoutAttempts <= 0 {
+		return
+	}
+	user, err := auth.LookupUser(ctx, s.client, username)
+	if err != nil {
+		return
+	}
+	user.Status.FailedLoginAttempts++
+	if failures := user.Status.FailedLoginAttempts; failures >= s.LoginLimits.LockoutAttempts {
+		until := metav1.NewTime(time.Now().Add(max(s.LoginLimits.delay(int(failures)), s.LoginLimits.Backoff)))
+		user.Status.LockedUntil = &until
+	}
+	if err := s.client.Status().Update(ctx, user); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record failed login", "user", username)
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestLoginLimitsDelay(t *testing.T) {
+	limits := LoginLimits{FreeAttempts: 3, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	for failures, want := range map[int]time.Duration{
+		0: 0, 3: 0, 4: time.Second, 5: 2 * time.Second, 6: 4 * time.Second, 7: 8 * time.Second, 8: 10 * time.Second, 100: 10 * time.Second,
+	} {
+		if got := limits.delay(failures); got != want {
+			t.Errorf("Expected a delay of %v after %d failures, got %v", want, failures, got)
+		}
+	}
+	if got := (LoginLimits{}).delay(100); got != 0 {
+		t.Errorf("Expected no delay without limits, got %v", got)
+	}
+}
+
+// setupLoginTestClient returns a client with the user alice, whose password is secret
+func setupLoginTestClient(t *testing.T) client.Client {
+	hash, err := auth.HashPassword("secret")
+	if err != nil {
+		t.Fatalf("Failed to hash password: %v", err)
+	}
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithStatusSubresource(&llmcloudv1alpha1.User{}).WithObjects(&llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+		Spec:       llmcloudv1alpha1.UserSpec{Username: "alice", PasswordHash: hash},
+	}).Build()
+}
+
+// login posts a login as username from addr
+func login(s *Server, addr, username, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/auth/login",
+		strings.NewReader(`{"username": "`+username+`", "password": "`+password+`"}`))
+	req.RemoteAddr = addr + ":1234"
+	w := httptest.NewRecorder()
+	s.handleAPI(w, req)
+	return w
+}
+
+func TestLimitLogins(t *testing.T) {
+	if err := auth.InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	s := &Server{client: setupLoginTestClient(t), LoginLimits: LoginLimits{FreeAttempts: 2, Backoff: time.Minute, MaxBackoff: time.Hour}}
+
+	for i := 0; i < 3; i++ {
+		if w := login(s, "10.0.0.1", "alice", "guess"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("Expected failed login %d to be checked, got %d", i+1, w.Code)
+		}
+	}
+	w := login(s, "10.0.0.1", "alice", "secret")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("Expected the address to back off for a minute, got %d (Retry-After %q)", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := login(s, "10.0.0.2", "alice", "secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the username to back off from another address too, got %d", w.Code)
+	}
+	if w := login(s, "10.0.0.2", "bob", "guess"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected other users to log in from another address, got %d", w.Code)
+	}
+
+	// Once the backoff is over, a successful login clears the username's failures
+	s.logins.failures["ip:10.0.0.1"].until = time.Now()
+	s.logins.failures["user:alice"].until = time.Now()
+	if w := login(s, "10.0.0.3", "alice", "secret"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the login to succeed after the backoff, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, ok := s.logins.failures["user:alice"]; ok {
+		t.Error("Expected a successful login to clear the username's failures")
+	}
+	if _, ok := s.logins.failures["ip:10.0.0.1"]; !ok {
+		t.Error("Expected the address to keep its failures")
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	if err := auth.InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	c := setupLoginTestClient(t)
+	s := &Server{client: c, LoginLimits: LoginLimits{Backoff: time.Minute, MaxBackoff: time.Hour, LockoutAttempts: 2}}
+	user := &llmcloudv1alpha1.User{}
+
+	for i := 0; i < 2; i++ {
+		login(s, "10.0.0.1", "alice", "guess")
+	}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "alice"}, user); err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.Status.FailedLoginAttempts != 2 || user.Status.LockedUntil == nil || !user.Status.LockedUntil.After(time.Now()) {
+		t.Fatalf("Expected the account to be locked after 2 failures, got %+v", user.Status)
+	}
+	w := login(s, "10.0.0.2", "alice", "secret")
+	if unknown := login(s, "10.0.0.2", "mallory", "secret"); w.Code != http.StatusUnauthorized || w.Body.String() != unknown.Body.String() {
+		t.Fatalf("Expected a locked account to be refused like an unknown user, got %d: %s", w.Code, w.Body.String())
+	}
+
+	past := metav1.NewTime(time.Now().Add(-time.Second))
+	user.Status.LockedUntil = &past
+	if err := c.Status().Update(context.Background(), user); err != nil {
+		t.Fatalf("Failed to expire lock: %v", err)
+	}
+	if w := login(s, "10.0.0.2", "alice", "secret"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the login to succeed once the lock expired, got %d", w.Code)
+	}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "alice"}, user); err != nil {
+		t.Fatalf("Failed to get user: %v", err)
+	}
+	if user.Status.FailedLoginAttempts != 0 || user.Status.LockedUntil != nil {
+		t.Errorf("Expected a successful login to clear the lockout, got %+v", user.Status)
+	}
+}
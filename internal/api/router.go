@@ -0,0 +1,162 @@
+package api
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+)
+
+// problem is an RFC 7807 problem details error body. Code, Reason, Message and Details
+// are extension members carrying the same error in the envelope of the Kubernetes API
+// Status: Reason is a machine-readable CamelCase cause, and Details lists the fields
+// of an invalid object
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+
+	Code    int            `json:"code"`
+	Reason  string         `json:"reason"`
+	Message string         `json:"message,omitempty"`
+	Details []problemCause `json:"details,omitempty"`
+}
+
+// problemCause is a field of the request that caused an error
+type problemCause struct {
+	Field   string `json:"field,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message"`
+}
+
+// writeProblem replies with an application/problem+json body for status, carrying
+// detail as the human-readable explanation. It replaces http.Error in API handlers
+func writeProblem(w http.ResponseWriter, detail string, status int) {
+	writeProblemBody(w, problem{Status: status, Detail: detail})
+}
+
+// writeError replies with the problem for err, its message prefixed by msg if set.
+// Errors of the Kubernetes API keep their status code and reason, so an object that
+// already exists or was modified concurrently is a 409, a forbidden one a 403, a
+// missing one a 404 and an invalid one a 422 with its invalid fields as details. Any
+// other error is a 500
+func writeError(w http.ResponseWriter, msg string, err error) {
+	writeProblemBody(w, errorProblem(msg, err))
+}
+
+// errorProblem returns the problem writeError replies with for err
+func errorProblem(msg string, err error) problem {
+	p := problem{Status: http.StatusInternalServerError, Detail: err.Error()}
+	if msg != "" {
+		p.Detail = msg + ": " + p.Detail
+	}
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		if status.Code >= 400 && status.Code < 600 {
+			p.Status = int(status.Code)
+		}
+		p.Reason = string(status.Reason)
+		if status.Details != nil {
+			for _, cause := range status.Details.Causes {
+				p.Details = append(p.Details, problemCause{
+					Field:   cause.Field,
+					Type:    string(cause.Type),
+					Message: cause.Message,
+				})
+			}
+		}
+	}
+	return p
+}
+
+// writeProblemBody fills in the members p derives from its status and writes it
+func writeProblemBody(w http.ResponseWriter, p problem) {
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.Code = p.Status
+	p.Message = p.Detail
+	if p.Reason == "" || p.Reason == string(metav1.StatusReasonUnknown) {
+		// "Unprocessable Entity" becomes "UnprocessableEntity", like the reasons of
+		// the Kubernetes API
+		p.Reason = strings.ReplaceAll(p.Title, " ", "")
+	}
+
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/problem+json")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
+
+// router dispatches API requests by method and path pattern, using the pattern syntax
+// of http.ServeMux. Requests for an unknown path, or with a method a path does not
+// support, are answered with a problem body
+type router struct {
+	mux *http.ServeMux
+	// methods are the methods registered for each pattern
+	methods map[string][]string
+	// dryRuns are the "METHOD pattern" routes that serve dry runs
+	dryRuns map[string]bool
+}
+
+func newRouter() *router {
+	rt := &router{mux: http.NewServeMux(), methods: map[string][]string{}, dryRuns: map[string]bool{}}
+	rt.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, "No route for "+r.URL.Path, http.StatusNotFound)
+	})
+	return rt
+}
+
+// handle registers h for requests with method whose path matches pattern. Dry runs
+// are refused, as h may have effects outside the server's client
+func (rt *router) handle(method, pattern string, h http.HandlerFunc) {
+	rt.register(method, pattern, withDryRun(h, false))
+}
+
+// handleDryRun registers h like handle, for a route whose writes all go through the
+// server's client, so that POST, PUT and PATCH requests with ?dryRun=true can be
+// served with dryRunClient making them dry runs
+func (rt *router) handleDryRun(method, pattern string, h http.HandlerFunc) {
+	rt.register(method, pattern, withDryRun(h, true))
+	rt.dryRuns[method+" "+pattern] = true
+}
+
+func (rt *router) register(method, pattern string, h http.HandlerFunc) {
+	rt.mux.HandleFunc(method+" "+pattern, instrument(pattern, h))
+
+	// A pattern without a method is less specific, so it only sees the methods
+	// that have no handler of their own
+	if _, ok := rt.methods[pattern]; !ok {
+		rt.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", strings.Join(rt.methods[pattern], ", "))
+			writeProblem(w, "Method "+r.Method+" is not allowed", http.StatusMethodNotAllowed)
+		})
+	}
+	rt.methods[pattern] = append(rt.methods[pattern], method)
+	slices.Sort(rt.methods[pattern])
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// instrument observes the latency of the requests h serves as route
+func instrument(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w}
+		h(recorder, r)
+		metrics.ObserveAPIRequest(route, r.Method, cmp.Or(recorder.status, http.StatusOK), start)
+	}
+}
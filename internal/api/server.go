@@ -1,21 +1,47 @@
 package api
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
+	"math/rand/v2"
+	"mime"
 	"net/http"
-	"os/exec"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	jsonpatch "github.com/evanphx/json-patch/v5"
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
 	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/controller"
+	"github.com/rusik69/llmcloud-operator/internal/metering"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+	webhookv1alpha1 "github.com/rusik69/llmcloud-operator/internal/webhook/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/yaml"
@@ -31,13 +57,80 @@ const claimsKey contextKey = "claims"
 
 type Server struct {
 	client client.Client
+
+	// Images is the image policy applied to previewed KubeVirt VMs; it should
+	// match the one the VirtualMachine controller uses
+	Images controller.ImagePolicy
+
+	// InferenceGateway enables /api/v1/inference, which proxies requests to models by name
+	InferenceGateway bool
+
+	// Meter counts the requests and tokens proxied by the inference gateway. Inference is
+	// not metered when it is nil
+	Meter *metering.Meter
+
+	// Audit records the requests that change something, and logins. Nothing is
+	// audited when it is nil
+	Audit *audit.Recorder
+
+	// Config reaches the Kubernetes API server directly, for the KubeVirt console
+	// subresources the client cannot proxy. Consoles are unavailable when it is nil
+	Config *rest.Config
+
+	// OIDC logs users in through an external identity provider at /api/v1/auth/oidc.
+	// Only local users can log in when it is nil
+	OIDC *auth.OIDCProvider
+
+	// LoginLimits throttles and locks out repeated failed logins. Logins are not
+	// limited when it is zero
+	LoginLimits LoginLimits
+	logins      loginLimiter
+
+	// Informers feed /api/v1/watch; they should read the same cluster as the client.
+	// Watches are unavailable when it is nil
+	Informers cache.Informers
+
+	// UploadProxyURL is where the CDI upload proxy receives VM image uploads. When empty,
+	// it is reached at the cluster IP of its Service
+	UploadProxyURL string
+
+	// Addr is the address the API server listens on, ":8090" when empty
+	Addr string
+
+	// CertDir holds the certificate and key, named CertName and KeyName, the API server
+	// serves TLS with. Plain HTTP is served when it is empty
+	CertDir, CertName, KeyName string
+	// TLSOpts adjust the TLS configuration
+	TLSOpts []func(*tls.Config)
+
+	// ShutdownTimeout is how long in-flight requests may take to finish when the
+	// server stops, 30s when zero
+	ShutdownTimeout time.Duration
+
+	// stopping is closed when the server shuts down, ending watches
+	stopping chan struct{}
+
+	routesOnce sync.Once
+	routes     *router
+
+	mu sync.Mutex
+	// startErr is the error Start returned, reported by Healthz
+	startErr error
 }
 
 func NewServer(c client.Client) *Server {
-	return &Server{client: c}
+	return &Server{client: dryRunClient{c}}
+}
+
+// NeedLeaderElection reports that every replica serves the API, not only the leader
+func (s *Server) NeedLeaderElection() bool {
+	return false
 }
 
-func (s *Server) Start(addr string) error {
+// Start serves the API until ctx is done, then stops accepting connections and waits
+// up to ShutdownTimeout for in-flight requests to finish. It implements
+// manager.Runnable, so the API server stops with the manager
+func (s *Server) Start(ctx context.Context) error {
 	// Create custom handler that checks API routes first
 	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Handle API routes
@@ -50,65 +143,293 @@ func (s *Server) Start(addr string) error {
 		s.handleStatic(w, r)
 	})
 
-	log.Log.Info("Starting API server", "address", addr)
-	return http.ListenAndServe(addr, s.corsMiddleware(handler))
+	srv := &http.Server{Addr: cmp.Or(s.Addr, ":8090"), Handler: s.corsMiddleware(securityHeaders(handler))}
+	// Watches stream until the client leaves, so they are ended rather than drained
+	s.stopping = make(chan struct{})
+	srv.RegisterOnShutdown(func() { close(s.stopping) })
+
+	err := s.listenAndServe(ctx, srv)
+	if err == nil || errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	s.mu.Lock()
+	s.startErr = err
+	s.mu.Unlock()
+	return err
+}
+
+// listenAndServe runs srv until it fails or ctx is done, serving TLS when CertDir is set
+func (s *Server) listenAndServe(ctx context.Context, srv *http.Server) error {
+	served := make(chan error, 1)
+	if s.CertDir != "" {
+		tlsConfig := &tls.Config{}
+		for _, opt := range s.TLSOpts {
+			opt(tlsConfig)
+		}
+		watcher, err := certwatcher.New(filepath.Join(s.CertDir, cmp.Or(s.CertName, "tls.crt")),
+			filepath.Join(s.CertDir, cmp.Or(s.KeyName, "tls.key")))
+		if err != nil {
+			return fmt.Errorf("failed to load API server certificate: %w", err)
+		}
+		go func() {
+			if err := watcher.Start(ctx); err != nil {
+				log.Log.Error(err, "API server certificate watcher failed")
+			}
+		}()
+		tlsConfig.GetCertificate = watcher.GetCertificate
+		srv.TLSConfig = tlsConfig
+		log.Log.Info("Starting API server", "address", srv.Addr, "tls", true)
+		go func() { served <- srv.ListenAndServeTLS("", "") }()
+	} else {
+		log.Log.Info("Starting API server", "address", srv.Addr)
+		go func() { served <- srv.ListenAndServe() }()
+	}
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	timeout := cmp.Or(s.ShutdownTimeout, 30*time.Second)
+	log.Log.Info("Shutting down API server", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Log.Error(err, "API requests did not finish in time; closing their connections")
+		_ = srv.Close()
+	}
+	return nil
+}
+
+// Healthz is a health check that fails once the API server has stopped serving,
+// e.g. because its address was already in use
+func (s *Server) Healthz(_ *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.startErr != nil {
+		return fmt.Errorf("API server is not running: %w", s.startErr)
+	}
+	return nil
 }
 
 func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 
 	// Auth routes (no authentication required)
-	if path == "/api/v1/auth/login" {
-		s.handleLogin(w, r)
+	switch path {
+	case "/api/v1/auth/login":
+		instrument(path, s.limitLogins(s.handleLogin))(w, r)
+		return
+	case "/api/v1/auth/password":
+		instrument(path, s.limitLogins(s.handleChangePassword))(w, r)
+		return
+	case "/api/v1/auth/oidc":
+		instrument(path, s.handleOIDCStatus)(w, r)
+		return
+	case "/api/v1/auth/oidc/login":
+		instrument(path, s.handleOIDCLogin)(w, r)
+		return
+	case "/api/v1/auth/oidc/callback":
+		instrument(path, s.handleOIDCCallback)(w, r)
+		return
+	case "/api/v1/openapi.json":
+		instrument(path, s.handleOpenAPI)(w, r)
+		return
+	case "/api/v1/docs":
+		instrument(path, s.handleAPIDocs)(w, r)
 		return
 	}
 
 	// All other API routes require authentication
 	// Extract the auth middleware logic inline
 	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		http.Error(w, "Missing authorization header", http.StatusUnauthorized)
+	// Browsers cannot set headers on a WebSocket upgrade or an EventSource, so consoles
+	// and watches may pass the token in the query
+	if token := r.URL.Query().Get("token"); authHeader == "" && token != "" &&
+		(strings.HasPrefix(path, "/api/v1/console/") || strings.HasPrefix(path, "/api/v1/watch/")) {
+		authHeader = "Bearer " + token
+	}
+	var claims *auth.Claims
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		var err error
+		if claims, err = s.apiKeyClaims(r.Context(), key); err != nil {
+			if errors.Is(err, errPasswordChangeRequired) {
+				writePasswordChangeRequired(w)
+			} else {
+				writeProblem(w, "Invalid or expired API key", http.StatusUnauthorized)
+			}
+			return
+		}
+	} else {
+		if authHeader == "" {
+			writeProblem(w, "Missing authorization header", http.StatusUnauthorized)
+			return
+		}
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+		var err error
+		if claims, err = auth.ValidateJWT(tokenString); err != nil {
+			writeProblem(w, "Invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+		if claims.ReadOnly {
+			if claims, err = s.readOnlyTokenClaims(r.Context(), claims); err != nil {
+				if errors.Is(err, errPasswordChangeRequired) {
+					writePasswordChangeRequired(w)
+				} else {
+					writeProblem(w, "Invalid or expired token", http.StatusUnauthorized)
+				}
+				return
+			}
+		}
+	}
+	if claims.ReadOnly && r.Method != http.MethodGet {
+		writeProblem(w, "Read-only token", http.StatusForbidden)
 		return
 	}
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-	claims, err := auth.ValidateJWT(tokenString)
-	if err != nil {
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+	if !authorizeRoute(claims, path) {
+		writeProblem(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 	ctx := context.WithValue(r.Context(), claimsKey, claims)
 	r = r.WithContext(ctx)
 
-	// Route to appropriate handler
-	if path == "/api/v1/users" {
-		s.handleUsers(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/users/") {
-		s.handleUser(w, r)
-	} else if path == "/api/v1/projects" {
-		s.handleProjects(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/projects/") {
-		s.handleProject(w, r)
-	} else if path == "/api/v1/nodes" {
-		s.handleClusterNodes(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/nodes/") {
-		s.handleNodeActions(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/namespaces/") {
-		s.handleNamespaceResources(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/actions/vm/") {
-		s.handleVMActions(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/describe/vm/") {
-		s.handleVMDescribe(w, r)
-	} else if strings.HasPrefix(path, "/api/v1/events/vm/") {
-		s.handleVMEvents(w, r)
-	} else {
-		http.NotFound(w, r)
+	s.routesOnce.Do(func() { s.routes = s.newRoutes() })
+	if s.Audit == nil || !auditedRequest(r) {
+		s.routes.ServeHTTP(w, r)
+		return
+	}
+	recorder := &statusRecorder{ResponseWriter: w}
+	s.routes.ServeHTTP(recorder, r)
+	s.Audit.Record(ctx, auditEvent(r, claims.Username, cmp.Or(recorder.status, http.StatusOK)))
+}
+
+// newRoutes registers the authenticated API routes
+func (s *Server) newRoutes() *router {
+	rt := newRouter()
+	rt.handle(http.MethodPost, "/api/v1/auth/tokens", s.handleReadOnlyToken)
+	rt.handle(http.MethodPost, "/api/v1/auth/refresh", s.handleRefresh)
+	rt.handle(http.MethodPost, "/api/v1/auth/logout", s.handleLogout)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rt.handle(method, "/api/v1/me/apikeys", s.handleMyAPIKeys)
 	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rt.handle(method, "/api/v1/me/apikeys/{name}", s.handleMyAPIKey)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rt.handleDryRun(method, "/api/v1/users", s.handleUsers)
+		rt.handleDryRun(method, "/api/v1/projects", s.handleProjects)
+		rt.handleDryRun(method, "/api/v1/sshkeys", s.handleSSHKeys)
+		rt.handleDryRun(method, "/api/v1/templates", s.handleTemplates)
+		rt.handleDryRun(method, "/api/v1/images", s.handleImages)
+		rt.handleDryRun(method, "/api/v1/groups", s.handleGroups)
+		rt.handleDryRun(method, "/api/v1/nodes", s.handleClusterNodes)
+		rt.handleDryRun(method, "/api/v1/namespaces/{namespace}/{resource}", s.handleNamespaceResources)
+		rt.handleDryRun(method, "/api/v1/cloudinit/vm/{namespace}/{name}", s.handleVMCloudInit)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		rt.handleDryRun(method, "/api/v1/users/{name}", s.handleUser)
+		rt.handleDryRun(method, "/api/v1/templates/{name}", s.handleTemplate)
+		rt.handleDryRun(method, "/api/v1/groups/{name}", s.handleGroup)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rt.handle(method, "/api/v1/projects/{name}", s.handleProject)
+		rt.handle(method, "/api/v1/sshkeys/{name}", s.handleSSHKey)
+		rt.handleDryRun(method, "/api/v1/images/{name}", s.handleImage)
+	}
+	rt.handle(http.MethodPost, "/api/v1/images/{name}/upload", s.handleImageUpload)
+	rt.handleDryRun(http.MethodPut, "/api/v1/projects/{name}/quota", s.handleProject)
+	rt.handle(http.MethodDelete, "/api/v1/nodes/{name}", s.handleNodeActions)
+	rt.handle(http.MethodGet, "/api/v1/clusternodes", s.handleClusterNodeList)
+	rt.handle(http.MethodGet, "/api/v1/cluster/storage", s.handleClusterStorage)
+	rt.handle(http.MethodGet, "/api/v1/cluster/gpus", s.handleClusterGPUs)
+	rt.handle(http.MethodGet, "/api/v1/capacity", s.handleCapacity)
+	rt.handle(http.MethodGet, "/api/v1/audit", s.handleAudit)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rt.handle(method, "/api/v1/backup", s.handleBackup)
+	}
+	rt.handle(http.MethodPost, "/api/v1/backup/snapshots", s.handleBackupSnapshots)
+	rt.handle(http.MethodGet, "/api/v1/catalog/models", s.handleModelCatalog)
+	rt.handle(http.MethodGet, "/api/v1/usage/projects/{name}", s.handleProjectUsage)
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		rt.handleDryRun(method, "/api/v1/namespaces/{namespace}/{resource}/{name}", s.handleNamespaceResources)
+	}
+	rt.handle(http.MethodGet, "/api/v1/namespaces/{namespace}/vms/{name}/credentials", s.handleVMCredentials)
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodDelete} {
+		rt.handleDryRun(method, "/api/v1/namespaces/{namespace}/vms/{name}/expose", s.handleVMExpose)
+	}
+	rt.handle(http.MethodPost, "/api/v1/actions/vm/{namespace}/{name}/{action}", s.handleVMActions)
+	rt.handle(http.MethodPost, "/api/v1/actions/model/{namespace}/{name}/{action}", s.handleModelActions)
+	rt.handle(http.MethodPost, "/api/v1/bulk/{resource}/{action}", s.handleBulk)
+	rt.handle(http.MethodPost, "/api/v1/preview/vm", s.handlePreviewVM)
+	rt.handle(http.MethodGet, "/api/v1/describe/vm/{namespace}/{name}", s.handleVMDescribe)
+	rt.handle(http.MethodGet, "/api/v1/events/{resource}/{namespace}/{name}", s.handleEvents)
+	rt.handle(http.MethodGet, "/api/v1/events/{resource}/{name}", s.handleClusterEvents)
+	rt.handle(http.MethodGet, "/api/v1/logs/{namespace}/{resource}/{name}", s.handleLogs)
+	rt.handle(http.MethodPost, "/api/v1/inference/{namespace}/{model}/{route...}", s.handleInference)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		rt.handle(method, "/api/v1/playground/{namespace}/{model}/sessions", s.handlePlaygroundSessions)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		rt.handle(method, "/api/v1/playground/{namespace}/{model}/sessions/{id}", s.handlePlaygroundSession)
+	}
+	rt.handle(http.MethodPost, "/api/v1/playground/{namespace}/{model}/chat", s.handlePlaygroundChat)
+	rt.handle(http.MethodGet, "/api/v1/console/vm/{namespace}/{name}", s.handleVMConsole)
+	rt.handle(http.MethodGet, "/api/v1/watch/namespaces/{namespace}/{resource}", s.handleWatch)
+	return rt
+}
+
+// namespacedRoutes are the route prefixes followed by the namespace the request operates on
+var namespacedRoutes = []string{
+	"/api/v1/namespaces/",
+	"/api/v1/actions/vm/",
+	"/api/v1/actions/model/",
+	"/api/v1/describe/vm/",
+	"/api/v1/events/vm/",
+	"/api/v1/events/model/",
+	"/api/v1/events/service/",
+	"/api/v1/logs/",
+	"/api/v1/cloudinit/vm/",
+	"/api/v1/inference/",
+	"/api/v1/playground/",
+	"/api/v1/console/vm/",
+	"/api/v1/watch/namespaces/",
+}
+
+// authorizeRoute reports whether claims allow a request to path. Requests for a project,
+// or for resources in a namespace, are limited to the caller's projects unless they are
+// an admin. Other routes are left to their handlers
+func authorizeRoute(claims *auth.Claims, path string) bool {
+	for _, prefix := range []string{"/api/v1/projects/", "/api/v1/usage/projects/"} {
+		if project, ok := strings.CutPrefix(path, prefix); ok {
+			project, _, _ = strings.Cut(project, "/")
+			return auth.HasProjectAccess(claims, project)
+		}
+	}
+	for _, prefix := range namespacedRoutes {
+		if remainder, ok := strings.CutPrefix(path, prefix); ok {
+			namespace, _, _ := strings.Cut(remainder, "/")
+			return canAccessNamespace(claims, namespace)
+		}
+	}
+	return true
+}
+
+// canAccessNamespace reports whether claims allow access to the resources in namespace.
+// Admins may access every namespace, other users only the project-<name> namespaces of
+// their projects
+func canAccessNamespace(claims *auth.Claims, namespace string) bool {
+	if claims.IsAdmin {
+		return true
+	}
+	project, ok := strings.CutPrefix(namespace, "project-")
+	return ok && auth.HasProjectAccess(claims, project)
 }
 
 func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	staticFS, err := fs.Sub(staticFiles, "static")
 	if err != nil {
-		http.Error(w, "Static files not available", http.StatusInternalServerError)
+		writeProblem(w, "Static files not available", http.StatusInternalServerError)
 		return
 	}
 
@@ -123,7 +444,7 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 		// File doesn't exist, serve index.html for SPA routing
 		indexFile, err := staticFS.Open("index.html")
 		if err != nil {
-			http.Error(w, "index.html not found", http.StatusInternalServerError)
+			writeProblem(w, "index.html not found", http.StatusInternalServerError)
 			return
 		}
 		defer func() {
@@ -145,11 +466,34 @@ func (s *Server) handleStatic(w http.ResponseWriter, r *http.Request) {
 	fileServer.ServeHTTP(w, r)
 }
 
+// corsMiddleware lets browsers call the API from the corsOrigins of the LLMCloudConfig
+// in effect, or from any origin when it sets none, with its corsMethods and corsHeaders.
+// With corsAllowCredentials, browsers may also send credentials from the listed origins
 func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		config := llmcloudv1alpha1.RuntimeConfig()
+		origins := config.CORSOrigins
+		if len(origins) == 0 || slices.Contains(origins, "*") {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		} else {
+			w.Header().Add("Vary", "Origin")
+			if origin := r.Header.Get("Origin"); slices.Contains(origins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				if config.CORSAllowCredentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+		}
+		methods, headers := config.CORSMethods, config.CORSHeaders
+		if len(methods) == 0 {
+			methods = llmcloudv1alpha1.DefaultCORSMethods
+		}
+		if len(headers) == 0 {
+			headers = llmcloudv1alpha1.DefaultCORSHeaders
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -160,25 +504,77 @@ func (s *Server) corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// defaultContentSecurityPolicy only lets the web UI load from and connect to its own
+// origin, and only be framed by it. Its styles are partly inline
+const defaultContentSecurityPolicy = "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; " +
+	"img-src 'self' data:; font-src 'self' data:; connect-src 'self'; object-src 'none'; base-uri 'self'; " +
+	"form-action 'self'; frame-ancestors 'self'"
+
+// hstsMaxAge is how long browsers only connect to the server over HTTPS after visiting
+// it over HTTPS once
+const hstsMaxAge = 365 * 24 * time.Hour
+
+// securityHeaders sets the headers that keep browsers from sniffing content types,
+// framing pages into other sites and leaking URLs in referrers, and, over TLS, from
+// connecting over plain HTTP. The web UI also gets the contentSecurityPolicy of the
+// LLMCloudConfig in effect, or defaultContentSecurityPolicy; the API's documentation page
+// loads Swagger UI from a CDN, so API responses get none
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "SAMEORIGIN")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if r.TLS != nil {
+			h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int(hstsMaxAge.Seconds())))
+		}
+		if !strings.HasPrefix(r.URL.Path, "/api/") {
+			h.Set("Content-Security-Policy",
+				cmp.Or(llmcloudv1alpha1.RuntimeConfig().ContentSecurityPolicy, defaultContentSecurityPolicy))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 
 	switch r.Method {
 	case http.MethodGet:
 		var projects llmcloudv1alpha1.ProjectList
 		if err := s.client.List(ctx, &projects); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
+		// Users only see the projects they are members of
+		claims := r.Context().Value(claimsKey).(*auth.Claims)
+		visible := projects.Items[:0]
+		for _, project := range projects.Items {
+			if auth.HasProjectAccess(claims, project.Name) {
+				visible = append(visible, project)
+			}
+		}
+		projects.Items = visible
 		s.writeJSON(w, projects)
 
 	case http.MethodPost:
+		// A project grants its members a namespace, so only admins create them
+		claims := r.Context().Value(claimsKey).(*auth.Claims)
+		if !claims.IsAdmin {
+			writeProblem(w, "Admin access required", http.StatusForbidden)
+			return
+		}
 		var req struct {
-			Name        string `json:"name"`
-			Description string `json:"description"`
+			Name        string           `json:"name"`
+			Description string           `json:"description"`
+			TTL         *metav1.Duration `json:"ttl"` // Optional, deletes the project once it has passed
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := llmcloudv1alpha1.ValidateProjectName(req.Name); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		project := &llmcloudv1alpha1.Project{
@@ -187,45 +583,186 @@ func (s *Server) handleProjects(w http.ResponseWriter, r *http.Request) {
 			},
 			Spec: llmcloudv1alpha1.ProjectSpec{
 				Description: req.Description,
+				TTL:         req.TTL,
 			},
 		}
+		if err := llmcloudv1alpha1.ValidateProjectSpec(&project.Spec, field.NewPath("spec")).ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err := s.client.Create(ctx, project); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 		s.writeJSON(w, project)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
 func (s *Server) handleProject(w http.ResponseWriter, r *http.Request) {
-	ctx := context.Background()
+	ctx := r.Context()
 	name := r.URL.Path[len("/api/v1/projects/"):]
 
+	if strings.HasSuffix(name, "/quota") {
+		s.handleProjectQuota(w, r, strings.TrimSuffix(name, "/quota"))
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		var project llmcloudv1alpha1.Project
 		if err := s.client.Get(ctx, client.ObjectKey{Name: name}, &project); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, "", err)
 			return
 		}
+		setETag(w, &project)
 		s.writeJSON(w, project)
 
 	case http.MethodDelete:
+		// Deleting a project deletes every workload in it, so like its creation it is
+		// left to admins rather than to any member
+		claims := r.Context().Value(claimsKey).(*auth.Claims)
+		if !claims.IsAdmin {
+			writeProblem(w, "Admin access required", http.StatusForbidden)
+			return
+		}
 		project := &llmcloudv1alpha1.Project{
 			ObjectMeta: metav1.ObjectMeta{Name: name},
 		}
 		if err := s.client.Delete(ctx, project); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// projectUsage is the resource consumption of the workloads in a project namespace
+type projectUsage struct {
+	VMs       int32
+	LLMModels int32
+	CPU       resource.Quantity
+	Memory    resource.Quantity
+}
+
+// handleProjectQuota replaces a project's resource quotas, rejecting limits that
+// are below what the project's existing workloads already consume
+func (s *Server) handleProjectQuota(w http.ResponseWriter, r *http.Request, name string) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodPut {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var quotas llmcloudv1alpha1.ProjectResourceQuotas
+	if err := json.NewDecoder(r.Body).Decode(&quotas); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
 	}
+	if err := llmcloudv1alpha1.NormalizeResourceQuotas(&quotas); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var project llmcloudv1alpha1.Project
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, &project); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	if !checkIfMatch(w, r, &project) {
+		return
+	}
+
+	usage, err := s.projectUsage(ctx, "project-"+name)
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	violations, err := quotaViolations(&quotas, usage)
+	if err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(violations) > 0 {
+		writeProblem(w, "Quota is below current usage: "+strings.Join(violations, "; "), http.StatusConflict)
+		return
+	}
+
+	// The update carries the resourceVersion read above, so a concurrent change
+	// to the project fails with a conflict instead of being overwritten
+	project.Spec.ResourceQuotas = &quotas
+	if err := s.client.Update(ctx, &project); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	setETag(w, &project)
+	s.writeJSON(w, project)
+}
+
+// projectUsage sums the VMs, LLM models and services in a project namespace.
+// Model and service resource requests are multiplied by their replica count
+func (s *Server) projectUsage(ctx context.Context, namespace string) (projectUsage, error) {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := s.client.List(ctx, &vms, client.InNamespace(namespace)); err != nil {
+		return projectUsage{}, fmt.Errorf("failed to list VMs: %w", err)
+	}
+	var models llmcloudv1alpha1.LLMModelList
+	if err := s.client.List(ctx, &models, client.InNamespace(namespace)); err != nil {
+		return projectUsage{}, fmt.Errorf("failed to list LLM models: %w", err)
+	}
+	var services llmcloudv1alpha1.ServiceList
+	if err := s.client.List(ctx, &services, client.InNamespace(namespace)); err != nil {
+		return projectUsage{}, fmt.Errorf("failed to list services: %w", err)
+	}
+
+	usage := projectUsage{VMs: int32(len(vms.Items)), LLMModels: int32(len(models.Items))}
+	usage.CPU, usage.Memory = controller.WorkloadRequests(vms.Items, models.Items, services.Items)
+	return usage, nil
+}
+
+// quotaViolations lists every limit in quotas that is lower than usage.
+// It returns an error if a CPU or memory limit is not a valid quantity
+func quotaViolations(quotas *llmcloudv1alpha1.ProjectResourceQuotas, usage projectUsage) ([]string, error) {
+	var violations []string
+
+	if quotas.MaxVMs != nil && *quotas.MaxVMs < usage.VMs {
+		violations = append(violations, fmt.Sprintf("maxVMs %d < %d VMs in use", *quotas.MaxVMs, usage.VMs))
+	}
+	if quotas.MaxLLMModels != nil && *quotas.MaxLLMModels < usage.LLMModels {
+		violations = append(violations, fmt.Sprintf("maxLLMModels %d < %d models in use", *quotas.MaxLLMModels, usage.LLMModels))
+	}
+	if quotas.MaxCPU != nil {
+		limit, err := resource.ParseQuantity(*quotas.MaxCPU)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxCPU %q: %w", *quotas.MaxCPU, err)
+		}
+		if limit.Cmp(usage.CPU) < 0 {
+			violations = append(violations, fmt.Sprintf("maxCPU %s < %s CPU in use", limit.String(), usage.CPU.String()))
+		}
+	}
+	if quotas.MaxMemory != nil {
+		limit, err := resource.ParseQuantity(*quotas.MaxMemory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maxMemory %q: %w", *quotas.MaxMemory, err)
+		}
+		if limit.Cmp(usage.Memory) < 0 {
+			violations = append(violations, fmt.Sprintf("maxMemory %s < %s memory in use", limit.String(), usage.Memory.String()))
+		}
+	}
+
+	return violations, nil
 }
 
 func (s *Server) handleNamespaceResources(w http.ResponseWriter, r *http.Request) {
@@ -233,7 +770,7 @@ func (s *Server) handleNamespaceResources(w http.ResponseWriter, r *http.Request
 	path := r.URL.Path[len("/api/v1/namespaces/"):]
 	parts := splitPath(path)
 	if len(parts) < 2 {
-		http.Error(w, "Invalid path", http.StatusBadRequest)
+		writeProblem(w, "Invalid path", http.StatusBadRequest)
 		return
 	}
 
@@ -244,7 +781,7 @@ func (s *Server) handleNamespaceResources(w http.ResponseWriter, r *http.Request
 		name = parts[2]
 	}
 
-	ctx := context.Background()
+	ctx := r.Context()
 
 	switch resource {
 	case "vms":
@@ -254,16 +791,128 @@ func (s *Server) handleNamespaceResources(w http.ResponseWriter, r *http.Request
 	case "services":
 		s.handleServices(ctx, w, r, namespace, name)
 	default:
-		http.Error(w, "Unknown resource", http.StatusNotFound)
+		writeProblem(w, "Unknown resource", http.StatusNotFound)
 	}
 }
 
 func (s *Server) handleVMs(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace, name string) {
+	// A plain JSON PATCH keeps its original meaning of setting the description
+	if r.Method == http.MethodPatch && name != "" && !isMergePatch(r) {
+		s.patchVMDescription(ctx, w, r, namespace, name)
+		return
+	}
+
+	if r.Method == http.MethodDelete && name != "" {
+		s.handleVMDelete(ctx, w, r, namespace, name)
+		return
+	}
 	s.handleResource(ctx, w, r, namespace, name,
 		&llmcloudv1alpha1.VirtualMachine{},
 		&llmcloudv1alpha1.VirtualMachineList{})
 }
 
+// patchVMDescription handles PATCH /api/v1/namespaces/{namespace}/vms/{name}
+// Only the description note can be changed this way
+func (s *Server) patchVMDescription(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace, name string) {
+	var req struct {
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Description == nil {
+		writeProblem(w, "description is required", http.StatusBadRequest)
+		return
+	}
+
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, vm); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	if !checkIfMatch(w, r, vm) {
+		return
+	}
+
+	vm.Spec.Description = *req.Description
+	if err := s.client.Update(ctx, vm); err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	setETag(w, vm)
+	s.writeJSON(w, vm)
+}
+
+// handleVMDelete handles DELETE /api/v1/namespaces/{namespace}/vms/{name}, keeping the
+// VM's data disks unless the caller explicitly asks for them to be deleted
+func (s *Server) handleVMDelete(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace, name string) {
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, vm); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	if !checkIfMatch(w, r, vm) {
+		return
+	}
+	if err := s.deleteVM(ctx, vm, r.URL.Query().Get("deleteData") == "true"); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteVM deletes vm as it was read, detaching its data disks first unless deleteData
+// is set. The preconditions make the delete fail rather than remove a VM that was
+// replaced or changed since, whose disks would otherwise be detached from it
+func (s *Server) deleteVM(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, deleteData bool) error {
+	if !deleteData {
+		if err := s.retainVMData(ctx, vm); err != nil {
+			return fmt.Errorf("failed to retain VM data: %w", err)
+		}
+	}
+	uid, resourceVersion := vm.UID, vm.ResourceVersion
+	return s.client.Delete(ctx, vm, client.Preconditions{UID: &uid, ResourceVersion: &resourceVersion})
+}
+
+// retainVMData detaches the DataVolumes and PVCs of the VM's data disk and additional
+// disks from their owners so that garbage collection does not delete them together with
+// the KubeVirt VM
+func (s *Server) retainVMData(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	diskNames := []string{vm.Name + "-disk"}
+	for _, disk := range vm.Spec.AdditionalDisks {
+		diskNames = append(diskNames, vm.Name+"-"+disk.Name)
+	}
+	for _, diskName := range diskNames {
+		for _, gvk := range []schema.GroupVersionKind{
+			{Group: "cdi.kubevirt.io", Version: "v1beta1", Kind: "DataVolume"},
+			{Group: "", Version: "v1", Kind: "PersistentVolumeClaim"},
+		} {
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(gvk)
+			if err := s.client.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: diskName}, obj); err != nil {
+				if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+					continue
+				}
+				return err
+			}
+
+			annotations := obj.GetAnnotations()
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations["llmcloud.io/retained-from-vm"] = vm.Name
+			obj.SetAnnotations(annotations)
+			obj.SetOwnerReferences(nil)
+			if err := s.client.Update(ctx, obj); err != nil {
+				return fmt.Errorf("failed to detach %s %s: %w", gvk.Kind, diskName, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (s *Server) handleModels(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace, name string) {
 	s.handleResource(ctx, w, r, namespace, name,
 		&llmcloudv1alpha1.LLMModel{},
@@ -281,62 +930,322 @@ func (s *Server) handleResource(ctx context.Context, w http.ResponseWriter, r *h
 	case http.MethodGet:
 		if name == "" {
 			if err := s.client.List(ctx, list, client.InNamespace(namespace)); err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				writeError(w, "", err)
 				return
 			}
 			s.writeJSON(w, list)
 		} else {
 			if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, obj); err != nil {
-				http.Error(w, err.Error(), http.StatusNotFound)
+				writeError(w, "", err)
 				return
 			}
+			setETag(w, obj)
 			s.writeJSON(w, obj)
 		}
 
 	case http.MethodPost:
 		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(obj); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Access was checked for the namespace in the path, so the body cannot name another
+		if obj.GetNamespace() != "" && obj.GetNamespace() != namespace {
+			writeProblem(w, fmt.Sprintf("Namespace %q does not match the path", obj.GetNamespace()), http.StatusBadRequest)
+			return
+		}
+		obj.SetNamespace(namespace)
+		if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok && vm.Spec.TemplateRef != "" {
+			if status, err := s.applyVMTemplate(ctx, vm); err != nil {
+				writeProblem(w, err.Error(), status)
+				return
+			}
+		}
+		if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok && vm.Spec.ImageRef != "" {
+			if status, err := s.applyVMImage(ctx, vm); err != nil {
+				writeProblem(w, err.Error(), status)
+				return
+			}
+		}
+		if err := validateResource(obj); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if r.URL.Query().Get("createProject") == "true" {
+			if isDryRun(ctx) {
+				// The workload could not be admitted into a namespace that is not created
+				writeProblem(w, "createProject does not support dryRun", http.StatusBadRequest)
+				return
+			}
+			if status, err := s.ensureProject(ctx, r, namespace); err != nil {
+				writeProblem(w, err.Error(), status)
+				return
+			}
+		}
 		if err := s.client.Create(ctx, obj); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
+			return
+		}
+		if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok && isDryRun(ctx) {
+			s.writeVMDryRun(w, nil, vm)
 			return
 		}
 		s.writeJSON(w, obj)
 
+	case http.MethodPut, http.MethodPatch:
+		if name == "" {
+			writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.updateResource(ctx, w, r, namespace, name, obj)
+
 	case http.MethodDelete:
 		obj.SetNamespace(namespace)
 		obj.SetName(name)
 		if err := s.client.Delete(ctx, obj); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-func (s *Server) writeJSON(w http.ResponseWriter, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, "failed to encode JSON", http.StatusInternalServerError)
-	}
+// isMergePatch reports whether the request body is a JSON merge patch
+func isMergePatch(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/merge-patch+json"
 }
 
-func splitPath(path string) []string {
-	var parts []string
-	for _, p := range []byte(path) {
-		if p != '/' {
-			if len(parts) == 0 {
-				parts = append(parts, string(p))
-			} else {
-				parts[len(parts)-1] += string(p)
-			}
-		} else if len(parts) > 0 && parts[len(parts)-1] != "" {
-			parts = append(parts, "")
-		}
+// updateResource handles PUT, which replaces the resource with the request body, and
+// PATCH, which applies the body to the resource as a JSON merge patch (RFC 7386).
+// The name and namespace cannot be changed, and the status is left to the controllers
+func (s *Server) updateResource(ctx context.Context, w http.ResponseWriter, r *http.Request, namespace, name string, obj client.Object) {
+	current := obj.DeepCopyObject().(client.Object)
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, current); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	if !checkIfMatch(w, r, current) {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		original, err := json.Marshal(current)
+		if err != nil {
+			writeError(w, "", err)
+			return
+		}
+		if body, err = jsonpatch.MergePatch(original, body); err != nil {
+			writeProblem(w, fmt.Sprintf("Invalid merge patch: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := json.Unmarshal(body, obj); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if obj.GetName() != "" && obj.GetName() != name {
+		writeProblem(w, fmt.Sprintf("Name %q does not match the path", obj.GetName()), http.StatusBadRequest)
+		return
+	}
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if r.Method == http.MethodPut {
+		preserveServerMetadata(obj, current)
+	}
+	// Without a resourceVersion in the body the update is made against the version read above
+	if obj.GetResourceVersion() == "" {
+		obj.SetResourceVersion(current.GetResourceVersion())
+	}
+	if err := validateResourceUpdate(obj, current); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.client.Update(ctx, obj); err != nil {
+		writeError(w, "", err)
+		return
+	}
+	setETag(w, obj)
+	if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok && isDryRun(ctx) {
+		s.writeVMDryRun(w, current.(*llmcloudv1alpha1.VirtualMachine), vm)
+		return
+	}
+	s.writeJSON(w, obj)
+}
+
+// systemMetadataDomains are the domains of the labels and annotations set by the
+// operator and by Kubernetes rather than by users
+var systemMetadataDomains = []string{"llmcloud.io", "kubernetes.io", "k8s.io"}
+
+// isSystemMetadataKey reports whether a label or annotation key is under one of the
+// systemMetadataDomains or their subdomains
+func isSystemMetadataKey(key string) bool {
+	prefix, _, ok := strings.Cut(key, "/")
+	if !ok {
+		return false
+	}
+	return slices.ContainsFunc(systemMetadataDomains, func(domain string) bool {
+		return prefix == domain || strings.HasSuffix(prefix, "."+domain)
+	})
+}
+
+// preserveServerMetadata copies the metadata that controllers manage from current to
+// obj, so that replacing an object cannot orphan its children or skip its finalization:
+// the finalizers, the owner references, and the system labels and annotations obj does
+// not set itself
+func preserveServerMetadata(obj, current client.Object) {
+	obj.SetFinalizers(current.GetFinalizers())
+	obj.SetOwnerReferences(current.GetOwnerReferences())
+	keep := func(set, stored map[string]string) map[string]string {
+		for key, value := range stored {
+			if _, ok := set[key]; !ok && isSystemMetadataKey(key) {
+				if set == nil {
+					set = map[string]string{}
+				}
+				set[key] = value
+			}
+		}
+		return set
+	}
+	obj.SetLabels(keep(obj.GetLabels(), current.GetLabels()))
+	obj.SetAnnotations(keep(obj.GetAnnotations(), current.GetAnnotations()))
+}
+
+// ensureProject creates the Project owning a project namespace, and the namespace itself,
+// if they do not exist yet so a workload can be created in a single request. Only admins
+// may create projects this way. On failure it returns the HTTP status to respond with
+func (s *Server) ensureProject(ctx context.Context, r *http.Request, namespace string) (int, error) {
+	name, ok := strings.CutPrefix(namespace, "project-")
+	if !ok || name == "" {
+		return http.StatusBadRequest, fmt.Errorf("namespace %q does not belong to a project", namespace)
+	}
+
+	project := &llmcloudv1alpha1.Project{}
+	err := s.client.Get(ctx, client.ObjectKey{Name: name}, project)
+	if err == nil {
+		return 0, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return http.StatusInternalServerError, err
+	}
+
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		return http.StatusForbidden, fmt.Errorf("project %q does not exist and only admins may create it", name)
+	}
+	if err := llmcloudv1alpha1.ValidateProjectName(name); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	project = &llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if err := s.client.Create(ctx, project); err != nil && !apierrors.IsAlreadyExists(err) {
+		return http.StatusInternalServerError, fmt.Errorf("failed to create project: %w", err)
+	}
+
+	// The project controller creates the namespace asynchronously; create it here with the
+	// same labels and owner so the workload can be created right away
+	ns := &unstructured.Unstructured{}
+	ns.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"})
+	ns.SetName(namespace)
+	ns.SetLabels(map[string]string{
+		"llmcloud.io/project": name,
+		"llmcloud.io/managed": "true",
+	})
+	ns.SetOwnerReferences([]metav1.OwnerReference{*metav1.NewControllerRef(project,
+		llmcloudv1alpha1.GroupVersion.WithKind("Project"))})
+	if err := s.client.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
+		return http.StatusInternalServerError, fmt.Errorf("failed to create project namespace: %w", err)
+	}
+
+	return 0, nil
+}
+
+// validateResource performs request-level validation that the CRD schema cannot express
+func validateResource(obj client.Object) error {
+	switch o := obj.(type) {
+	case *llmcloudv1alpha1.VirtualMachine:
+		return llmcloudv1alpha1.ValidateVirtualMachineSpec(&o.Spec, field.NewPath("spec")).ToAggregate()
+	case *llmcloudv1alpha1.LLMModel:
+		return llmcloudv1alpha1.ValidateLLMModelSpec(&o.Spec, field.NewPath("spec")).ToAggregate()
+	case *llmcloudv1alpha1.Service:
+		return llmcloudv1alpha1.ValidateServiceSpec(&o.Spec, field.NewPath("spec")).ToAggregate()
+	}
+	return nil
+}
+
+// validateResourceUpdate is validateResource for an update of current to obj, which
+// also rejects changes that cannot be applied in place, such as shrinking a VM disk
+func validateResourceUpdate(obj, current client.Object) error {
+	if vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine); ok {
+		old := current.(*llmcloudv1alpha1.VirtualMachine)
+		return llmcloudv1alpha1.ValidateVirtualMachineUpdate(&vm.Spec, &old.Spec, field.NewPath("spec")).ToAggregate()
+	}
+	if service, ok := obj.(*llmcloudv1alpha1.Service); ok {
+		old := current.(*llmcloudv1alpha1.Service)
+		return llmcloudv1alpha1.ValidateServiceUpdate(&old.Spec, &service.Spec, field.NewPath("spec")).ToAggregate()
+	}
+	if model, ok := obj.(*llmcloudv1alpha1.LLMModel); ok {
+		old := current.(*llmcloudv1alpha1.LLMModel)
+		return llmcloudv1alpha1.ValidateLLMModelUpdate(&old.Spec, &model.Spec, field.NewPath("spec")).ToAggregate()
+	}
+	return validateResource(obj)
+}
+
+// setETag exposes the object's resourceVersion as its ETag so clients can make
+// conditional updates with If-Match
+func setETag(w http.ResponseWriter, obj client.Object) {
+	if rv := obj.GetResourceVersion(); rv != "" {
+		w.Header().Set("ETag", strconv.Quote(rv))
+	}
+}
+
+// checkIfMatch compares the request's If-Match header against the current object's
+// resourceVersion. On a mismatch it responds with 412 Precondition Failed and returns
+// false. Requests without If-Match always pass
+func checkIfMatch(w http.ResponseWriter, r *http.Request, current client.Object) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	etag := strconv.Quote(current.GetResourceVersion())
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	w.Header().Set("ETag", etag)
+	writeProblem(w, "Resource has been modified", http.StatusPreconditionFailed)
+	return false
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		writeProblem(w, "failed to encode JSON", http.StatusInternalServerError)
+	}
+}
+
+func splitPath(path string) []string {
+	var parts []string
+	for _, p := range []byte(path) {
+		if p != '/' {
+			if len(parts) == 0 {
+				parts = append(parts, string(p))
+			} else {
+				parts[len(parts)-1] += string(p)
+			}
+		} else if len(parts) > 0 && parts[len(parts)-1] != "" {
+			parts = append(parts, "")
+		}
 	}
 	return parts
 }
@@ -345,7 +1254,7 @@ func splitPath(path string) []string {
 // URL format: /api/v1/actions/vm/{namespace}/{name}/{action}
 func (s *Server) handleVMActions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -353,7 +1262,7 @@ func (s *Server) handleVMActions(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path[len("/api/v1/actions/vm/"):]
 	parts := splitPath(path)
 	if len(parts) < 3 {
-		http.Error(w, "Invalid path, expected: /api/v1/actions/vm/{namespace}/{name}/{action}", http.StatusBadRequest)
+		writeProblem(w, "Invalid path, expected: /api/v1/actions/vm/{namespace}/{name}/{action}", http.StatusBadRequest)
 		return
 	}
 
@@ -366,10 +1275,29 @@ func (s *Server) handleVMActions(w http.ResponseWriter, r *http.Request) {
 	// Get the VM
 	vm := &llmcloudv1alpha1.VirtualMachine{}
 	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, vm); err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		writeError(w, "", err)
 		return
 	}
 
+	if status, err := applyVMAction(vm, action); err != nil {
+		writeProblem(w, err.Error(), status)
+		return
+	}
+
+	if err := s.client.Update(ctx, vm); err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	s.writeJSON(w, map[string]string{"status": "success", "action": action})
+}
+
+// vmActions are the actions of /api/v1/actions/vm
+var vmActions = []string{"start", "stop", "reboot", "migrate"}
+
+// applyVMAction changes vm to have the controller carry out action, or returns the
+// status to reply with when the action is unknown or cannot be taken now
+func applyVMAction(vm *llmcloudv1alpha1.VirtualMachine, action string) (int, error) {
 	// Perform action by updating RunStrategy
 	switch action {
 	case "start":
@@ -382,91 +1310,641 @@ func (s *Server) handleVMActions(w http.ResponseWriter, r *http.Request) {
 			vm.Annotations = make(map[string]string)
 		}
 		vm.Annotations["llmcloud.io/reboot"] = "true"
+	case "migrate":
+		// The controller live-migrates the VM with a VirtualMachineInstanceMigration
+		if vm.Status.Phase != "Running" {
+			return http.StatusConflict, fmt.Errorf("VM %s/%s is not running", vm.Namespace, vm.Name)
+		}
+		if vm.Annotations == nil {
+			vm.Annotations = make(map[string]string)
+		}
+		vm.Annotations[controller.VMMigrateAnnotation] = "true"
+	default:
+		return http.StatusBadRequest, fmt.Errorf("unknown action, valid actions: %s", strings.Join(vmActions, ", "))
+	}
+	return 0, nil
+}
+
+// handleModelActions handles LLM model actions (retry, start, stop)
+// URL format: /api/v1/actions/model/{namespace}/{name}/{action}
+func (s *Server) handleModelActions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.Path[len("/api/v1/actions/model/"):]
+	parts := splitPath(path)
+	if len(parts) < 3 {
+		writeProblem(w, "Invalid path, expected: /api/v1/actions/model/{namespace}/{name}/{action}", http.StatusBadRequest)
+		return
+	}
+
+	namespace := parts[0]
+	name := parts[1]
+	action := parts[2]
+
+	ctx := context.Background()
+
+	model := &llmcloudv1alpha1.LLMModel{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, model); err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	if status, err := applyModelAction(model, action); err != nil {
+		writeProblem(w, err.Error(), status)
+		return
+	}
+
+	if err := s.client.Update(ctx, model); err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	s.writeJSON(w, map[string]string{"status": "success", "action": action})
+}
+
+// modelActions are the actions of /api/v1/actions/model
+var modelActions = []string{"retry", "start", "stop"}
+
+// applyModelAction changes model to have the controller carry out action, or returns
+// the status to reply with when the action is unknown
+func applyModelAction(model *llmcloudv1alpha1.LLMModel, action string) (int, error) {
+	switch action {
+	case "retry":
+		// The controller resets the retry counter and removes the annotation
+		if model.Annotations == nil {
+			model.Annotations = make(map[string]string)
+		}
+		model.Annotations["llmcloud.io/retry"] = "true"
+	case "start":
+		model.Spec.Stopped = false
+	case "stop":
+		model.Spec.Stopped = true
+	default:
+		return http.StatusBadRequest, fmt.Errorf("unknown action, valid actions: %s", strings.Join(modelActions, ", "))
+	}
+	return 0, nil
+}
+
+// handleModelCatalog handles GET /api/v1/catalog/models, listing the presets an
+// LLMModel can be created from
+func (s *Server) handleModelCatalog(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, llmcloudv1alpha1.ModelPresets())
+}
+
+// handleInference proxies POST /api/v1/inference/{namespace}/{model}/{path} to the
+// model's endpoint, under the API prefix of its serving protocol, so clients reach every
+// model through one base URL. The model's endpoint Service balances across replicas
+func (s *Server) handleInference(w http.ResponseWriter, r *http.Request) {
+	if !s.InferenceGateway {
+		writeProblem(w, "Inference gateway is disabled", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/v1/inference/"), "/", 3)
+	if len(parts) < 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		writeProblem(w, "Invalid path, expected: /api/v1/inference/{namespace}/{model}/{path}", http.StatusBadRequest)
+		return
+	}
+	namespace, name, route := parts[0], parts[1], parts[2]
+
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !canAccessNamespace(claims, namespace) {
+		writeProblem(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	model, served, target, ok := s.inferenceTarget(w, r, namespace, name)
+	if !ok {
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			// Cleaning the route first keeps ".." from escaping the API prefix
+			pr.Out.URL.Path = path.Join(target.Path, served.Spec.ProxyRoutePrefix(), path.Clean("/"+route))
+			pr.Out.URL.RawPath = ""
+			// The user's token is for this API only
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("X-API-Key")
+		},
+		// Stream generated tokens to the client as the model produces them
+		FlushInterval: -1,
+	}
+	if s.Meter != nil {
+		// The requests of a progressing rollout are counted to judge its canary
+		judged := model.RolloutPhase() == llmcloudv1alpha1.RolloutPhaseProgressing
+		proxy.ModifyResponse = func(resp *http.Response) error {
+			if judged {
+				s.Meter.RecordRollout(namespace, name, model.Spec.Rollout.Canary, served != model, resp.StatusCode >= 500)
+			}
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				resp.Body = s.Meter.Count(namespace, served.Name, resp.Body)
+			}
+			return nil
+		}
+		if judged {
+			proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+				// Requests the client gave up on say nothing about the model
+				if r.Context().Err() == nil {
+					s.Meter.RecordRollout(namespace, name, model.Spec.Rollout.Canary, served != model, true)
+				}
+				writeProblem(w, fmt.Sprintf("Model %s/%s is unreachable: %v", namespace, served.Name, err), http.StatusBadGateway)
+			}
+		}
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// inferenceTarget returns the model namespace/name, the model serving a request for it
+// (the model itself or its rollout's canary, with its preset applied) and the endpoint
+// to send the request to. When the model cannot serve requests it writes the problem
+// and returns false
+func (s *Server) inferenceTarget(w http.ResponseWriter, r *http.Request, namespace, name string) (model, served *llmcloudv1alpha1.LLMModel, target *url.URL, ok bool) {
+	model = &llmcloudv1alpha1.LLMModel{}
+	if err := s.client.Get(r.Context(), client.ObjectKey{Namespace: namespace, Name: name}, model); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeProblem(w, fmt.Sprintf("Model %s/%s not found", namespace, name), http.StatusNotFound)
+			return nil, nil, nil, false
+		}
+		writeError(w, "", err)
+		return nil, nil, nil, false
+	}
+	// A rollout sends some or all of the requests to its canary instead
+	served = model
+	if canary := s.rolloutCanary(r.Context(), model); canary != nil {
+		served = canary
+	}
+	if served.Status.Endpoint == "" {
+		writeProblem(w, fmt.Sprintf("Model %s/%s has no endpoint yet", namespace, served.Name), http.StatusServiceUnavailable)
+		return nil, nil, nil, false
+	}
+	// The preset decides the provider, and so the protocol, of models that leave it empty
+	if err := llmcloudv1alpha1.ApplyModelPreset(&served.Spec); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadGateway)
+		return nil, nil, nil, false
+	}
+	target, err := url.Parse(served.Status.Endpoint)
+	if err != nil || target.Host == "" {
+		writeProblem(w, fmt.Sprintf("Model %s/%s has an invalid endpoint %q", namespace, served.Name, served.Status.Endpoint),
+			http.StatusBadGateway)
+		return nil, nil, nil, false
+	}
+	return model, served, target, true
+}
+
+// rolloutCanary returns the canary of model's rollout when it is to serve a request for
+// model: always once the rollout promoted it, and for the rollout's weight of the
+// requests while it progresses. It returns nil for model to serve the request itself,
+// as it also does while the canary has no endpoint
+func (s *Server) rolloutCanary(ctx context.Context, model *llmcloudv1alpha1.LLMModel) *llmcloudv1alpha1.LLMModel {
+	switch model.RolloutPhase() {
+	case llmcloudv1alpha1.RolloutPhasePromoted:
+	case llmcloudv1alpha1.RolloutPhaseProgressing:
+		if rand.IntN(100) >= int(cmp.Or(model.Spec.Rollout.Weight, llmcloudv1alpha1.DefaultRolloutWeight)) {
+			return nil
+		}
 	default:
-		http.Error(w, "Unknown action, valid actions: start, stop, reboot", http.StatusBadRequest)
+		return nil
+	}
+
+	canary := &llmcloudv1alpha1.LLMModel{}
+	key := client.ObjectKey{Namespace: model.Namespace, Name: model.Spec.Rollout.Canary}
+	if err := s.client.Get(ctx, key, canary); err != nil || canary.Status.Endpoint == "" {
+		return nil
+	}
+	return canary
+}
+
+// consoleSubresources maps the console types of /api/v1/console/vm to the KubeVirt
+// VirtualMachineInstance subresource serving them
+var consoleSubresources = map[string]string{"vnc": "vnc", "serial": "console"}
+
+// +kubebuilder:rbac:groups=subresources.kubevirt.io,resources=virtualmachineinstances/vnc;virtualmachineinstances/console,verbs=get
+
+// handleVMConsole proxies the WebSocket GET /api/v1/console/vm/{namespace}/{name} to the
+// VNC console of the VM's KubeVirt instance, or to its serial console with ?type=serial.
+// The KubeVirt connection is authenticated as the operator, not the user
+func (s *Server) handleVMConsole(w http.ResponseWriter, r *http.Request) {
+	if s.Config == nil {
+		writeProblem(w, "Console access is not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/v1/console/vm/"))
+	if len(parts) != 2 {
+		writeProblem(w, "Invalid path, expected: /api/v1/console/vm/{namespace}/{name}", http.StatusBadRequest)
+		return
+	}
+	namespace, name := parts[0], parts[1]
+
+	consoleType := r.URL.Query().Get("type")
+	if consoleType == "" {
+		consoleType = "vnc"
+	}
+	subresource, ok := consoleSubresources[consoleType]
+	if !ok {
+		writeProblem(w, "Unknown console type, valid types: vnc, serial", http.StatusBadRequest)
+		return
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		writeProblem(w, "Expected a WebSocket upgrade", http.StatusBadRequest)
+		return
+	}
+
+	// A console is interactive access to the guest, so read-only tokens may not open one
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if claims.ReadOnly || !canAccessNamespace(claims, namespace) {
+		writeProblem(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(r.Context(), client.ObjectKey{Namespace: namespace, Name: name}, vm); err != nil {
+		if apierrors.IsNotFound(err) {
+			writeProblem(w, fmt.Sprintf("VM %s/%s not found", namespace, name), http.StatusNotFound)
+			return
+		}
+		writeError(w, "", err)
+		return
+	}
+
+	target, _, err := rest.DefaultServerUrlFor(s.Config)
+	if err != nil {
+		writeProblem(w, fmt.Sprintf("Invalid Kubernetes API address: %v", err), http.StatusInternalServerError)
+		return
+	}
+	transport, err := rest.TransportFor(s.Config)
+	if err != nil {
+		writeError(w, "Failed to build Kubernetes API transport", err)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = path.Join(target.Path, "/apis/subresources.kubevirt.io/v1/namespaces",
+				namespace, "virtualmachineinstances", name, subresource)
+			pr.Out.URL.RawPath = ""
+			// The query carries the user's token, which is for this API only
+			pr.Out.URL.RawQuery = ""
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("X-API-Key")
+		},
+		Transport: transport,
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// handleLogin handles user authentication
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var loginReq struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
+		writeProblem(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	user, err := auth.AuthenticateUser(ctx, s.client, loginReq.Username, loginReq.Password)
+	metrics.ObserveLogin(err == nil)
+	if s.Audit != nil {
+		event := auditEvent(r, loginReq.Username, http.StatusOK)
+		if err != nil {
+			event.StatusCode = http.StatusUnauthorized
+		}
+		s.Audit.Record(ctx, event)
+	}
+	if err != nil {
+		// A locked account is refused like an unknown username, so that the answer
+		// does not tell which usernames exist; the lock is not extended
+		if !errors.Is(err, auth.ErrAccountLocked) {
+			s.recordLoginFailure(ctx, loginReq.Username)
+		}
+		writeProblem(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+	if auth.PasswordChangeRequired(user, time.Now()) {
+		writePasswordChangeRequired(w)
+		return
+	}
+
+	// Update last login time, clearing the failed logins
+	now := metav1.Now()
+	user.Status.LastLoginTime = &now
+	user.Status.FailedLoginAttempts = 0
+	user.Status.LockedUntil = nil
+	_ = s.client.Status().Update(ctx, user)
+
+	s.writeSession(ctx, w, user)
+}
+
+// passwordChangeRequired is the reason of the problem a login is refused with while the
+// user must change the password
+const passwordChangeRequired = "PasswordChangeRequired"
+
+// errPasswordChangeRequired refuses the credentials of a user who must change the password
+var errPasswordChangeRequired = errors.New("password change required")
+
+// writePasswordChangeRequired refuses a login or token refresh of a user who must change
+// the password first
+func writePasswordChangeRequired(w http.ResponseWriter) {
+	writeProblemBody(w, problem{
+		Status: http.StatusForbidden,
+		Reason: passwordChangeRequired,
+		Detail: "The password must be changed before logging in, with POST /api/v1/auth/password",
+	})
+}
+
+// handleChangePassword changes the password of a local user who authenticates with the
+// current one, and logs the user in. It is how a user who must change the password
+// gets a token; the new password must meet the password policy in effect
+func (s *Server) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Username    string `json:"username"`
+		Password    string `json:"password"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	ctx := context.Background()
+	user, err := auth.AuthenticateUser(ctx, s.client, req.Username, req.Password)
+	metrics.ObserveLogin(err == nil)
+	if s.Audit != nil {
+		event := auditEvent(r, req.Username, http.StatusOK)
+		if err != nil {
+			event.StatusCode = http.StatusUnauthorized
+		}
+		s.Audit.Record(ctx, event)
+	}
+	if err != nil {
+		// A locked account is refused like an unknown username, so that the answer
+		// does not tell which usernames exist; the lock is not extended
+		if !errors.Is(err, auth.ErrAccountLocked) {
+			s.recordLoginFailure(ctx, req.Username)
+		}
+		writeProblem(w, "Invalid credentials", http.StatusUnauthorized)
+		return
+	}
+
+	if req.NewPassword == req.Password {
+		writeProblem(w, "The new password must differ from the current one", http.StatusBadRequest)
+		return
+	}
+	if err := llmcloudv1alpha1.RuntimeConfig().PasswordPolicy.Check(req.NewPassword); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		writeProblem(w, "Failed to hash password", http.StatusInternalServerError)
+		return
+	}
+	user.Spec.PasswordHash = hash
+	user.Spec.MustChangePassword = false
+	if err := s.client.Update(ctx, user); err != nil {
+		writeError(w, "Failed to change password", err)
+		return
+	}
+
+	now := metav1.Now()
+	user.Status.PasswordChangedAt = &now
+	user.Status.LastLoginTime = &now
+	user.Status.FailedLoginAttempts = 0
+	user.Status.LockedUntil = nil
+	if err := s.client.Status().Update(ctx, user); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to record password change", "user", user.Name)
+	}
+
+	s.writeSession(ctx, w, user)
+}
+
+// writeSession replies with a new token for user, who just logged in
+func (s *Server) writeSession(ctx context.Context, w http.ResponseWriter, user *llmcloudv1alpha1.User) {
+	if err := auth.AddGroupProjects(ctx, s.client, user); err != nil {
+		writeError(w, "Failed to resolve group memberships", err)
+		return
+	}
+
+	// Generate JWT
+	token, err := auth.GenerateJWT(user)
+	if err != nil {
+		writeProblem(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	s.writeJSON(w, map[string]interface{}{
+		"token":    token,
+		"username": user.Spec.Username,
+		"isAdmin":  user.Spec.IsAdmin,
+		"projects": user.Spec.Projects,
+	})
+}
+
+// handleRefresh exchanges the caller's token for a fresh one, reflecting the user's
+// current projects and admin flag, and revokes the old token
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	if claims.APIKey != "" {
+		writeProblem(w, "API keys cannot be exchanged for tokens", http.StatusForbidden)
+		return
+	}
+	// Only the identity provider can vouch for its users' groups again
+	if claims.Provider != "" {
+		writeProblem(w, "Tokens from an identity provider cannot be refreshed; log in again", http.StatusUnauthorized)
+		return
+	}
+	user, err := auth.LookupUser(ctx, s.client, claims.Username)
+	if err != nil {
+		writeProblem(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
-
-	if err := s.client.Update(ctx, vm); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	// Refreshing must not outlive a password that has to be changed
+	if auth.PasswordChangeRequired(user, time.Now()) {
+		writePasswordChangeRequired(w)
+		return
+	}
+	if err := auth.AddGroupProjects(ctx, s.client, user); err != nil {
+		writeError(w, "Failed to resolve group memberships", err)
+		return
+	}
+	token, err := auth.GenerateJWT(user)
+	if err != nil {
+		writeProblem(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+	if err := auth.Revoke(ctx, claims); err != nil {
+		writeError(w, "", err)
 		return
 	}
 
-	s.writeJSON(w, map[string]string{"status": "success", "action": action})
+	s.writeJSON(w, map[string]interface{}{
+		"token":    token,
+		"username": user.Spec.Username,
+		"isAdmin":  user.Spec.IsAdmin,
+		"projects": user.Spec.Projects,
+	})
 }
 
-// handleLogin handles user authentication
-func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleLogout revokes the caller's token
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if err := auth.Revoke(r.Context(), claims); err != nil {
+		writeError(w, "", err)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	var loginReq struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+// maxReadOnlyTokenTTL is how long a read-only token lasts at most, and when the request
+// does not say. Dashboards that run longer mint a new one with an API key or a login
+const maxReadOnlyTokenTTL = 24 * time.Hour
+
+// handleReadOnlyToken mints a read-only token for the caller, optionally with a "ttl"
+// duration such as "8h". The token carries the user as stored, not the caller's claims
+func (s *Server) handleReadOnlyToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if claims.APIKey != "" {
+		writeProblem(w, "API keys cannot be exchanged for tokens", http.StatusForbidden)
+		return
+	}
+	// Like a refresh, only the identity provider can vouch for its users again
+	if claims.Provider != "" {
+		writeProblem(w, "Tokens from an identity provider cannot be exchanged for read-only tokens", http.StatusForbidden)
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeProblem(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	ttl := maxReadOnlyTokenTTL
+	if req.TTL != "" {
+		parsed, err := time.ParseDuration(req.TTL)
+		if err != nil || parsed <= 0 || parsed > maxReadOnlyTokenTTL {
+			writeProblem(w, fmt.Sprintf("ttl must be a positive duration of at most %s", maxReadOnlyTokenTTL), http.StatusBadRequest)
+			return
+		}
+		ttl = parsed
+	}
 
-	ctx := context.Background()
-	user, err := auth.AuthenticateUser(ctx, s.client, loginReq.Username, loginReq.Password)
+	user, err := s.currentUser(r.Context(), claims.Username)
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		writeError(w, "", err)
+		return
+	}
+	if auth.PasswordChangeRequired(user, time.Now()) {
+		writePasswordChangeRequired(w)
 		return
 	}
 
-	// Update last login time
-	now := metav1.Now()
-	user.Status.LastLoginTime = &now
-	_ = s.client.Status().Update(ctx, user)
-
-	// Generate JWT
-	token, err := auth.GenerateJWT(user)
+	token, err := auth.GenerateReadOnlyJWT(user, ttl)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		writeProblem(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	s.writeJSON(w, map[string]interface{}{
-		"token":    token,
-		"username": user.Spec.Username,
-		"isAdmin":  user.Spec.IsAdmin,
-		"projects": user.Spec.Projects,
+		"token":     token,
+		"readonly":  true,
+		"expiresAt": time.Now().Add(ttl).UTC().Format(time.RFC3339),
 	})
 }
 
+// currentUser returns the enabled user with username, with the projects of the groups
+// the user is in, answering 401 when there is none
+func (s *Server) currentUser(ctx context.Context, username string) (*llmcloudv1alpha1.User, error) {
+	user, err := auth.LookupUser(ctx, s.client, username)
+	if err != nil {
+		return nil, apierrors.NewUnauthorized("Invalid credentials")
+	}
+	if err := auth.AddGroupProjects(ctx, s.client, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// readOnlyTokenClaims checks the claims of a read-only token against the user as
+// stored now, since the token outlives a login token. A user who was deleted, disabled
+// or must change the password is refused, and demotions and project removals apply
+func (s *Server) readOnlyTokenClaims(ctx context.Context, claims *auth.Claims) (*auth.Claims, error) {
+	user, err := s.currentUser(ctx, claims.Username)
+	if err != nil {
+		return nil, err
+	}
+	if auth.PasswordChangeRequired(user, time.Now()) {
+		return nil, errPasswordChangeRequired
+	}
+	current := *claims
+	current.IsAdmin = user.Spec.IsAdmin
+	current.Projects = user.Spec.Projects
+	return &current, nil
+}
+
 // handleUsers handles user listing and creation (admin only)
 func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(claimsKey).(*auth.Claims)
-	ctx := context.Background()
+	ctx := r.Context()
 
 	switch r.Method {
 	case http.MethodGet:
 		if !claims.IsAdmin {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeProblem(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
 		var users llmcloudv1alpha1.UserList
 		if err := s.client.List(ctx, &users); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 
-		// Remove password hashes before sending
 		for i := range users.Items {
-			users.Items[i].Spec.PasswordHash = ""
+			redactUser(&users.Items[i])
 		}
 
 		s.writeJSON(w, users)
 
 	case http.MethodPost:
 		if !claims.IsAdmin {
-			http.Error(w, "Admin access required", http.StatusForbidden)
+			writeProblem(w, "Admin access required", http.StatusForbidden)
 			return
 		}
 
@@ -478,15 +1956,19 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 			} `json:"spec"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			writeProblem(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Hash the password if provided
 		if userReq.Spec.Password != "" {
+			if err := llmcloudv1alpha1.RuntimeConfig().PasswordPolicy.Check(userReq.Spec.Password); err != nil {
+				writeProblem(w, err.Error(), http.StatusBadRequest)
+				return
+			}
 			hash, err := auth.HashPassword(userReq.Spec.Password)
 			if err != nil {
-				http.Error(w, "Failed to hash password", http.StatusInternalServerError)
+				writeProblem(w, "Failed to hash password", http.StatusInternalServerError)
 				return
 			}
 			userReq.User.Spec.PasswordHash = hash
@@ -498,18 +1980,24 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 		userReq.User.Spec.IsAdmin = userReq.Spec.IsAdmin
 		userReq.User.Spec.Projects = userReq.Spec.Projects
 		userReq.User.Spec.Disabled = userReq.Spec.Disabled
+		userReq.User.Spec.MustChangePassword = userReq.Spec.MustChangePassword
+
+		validator := &webhookv1alpha1.UserCustomValidator{Client: s.client}
+		if _, err := validator.ValidateCreate(ctx, &userReq.User); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		if err := s.client.Create(ctx, &userReq.User); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 
-		// Remove password hash before sending response
-		userReq.User.Spec.PasswordHash = ""
+		redactUser(&userReq.User)
 		s.writeJSON(w, userReq.User)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
@@ -517,36 +2005,90 @@ func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(claimsKey).(*auth.Claims)
 	if !claims.IsAdmin {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+		writeProblem(w, "Admin access required", http.StatusForbidden)
 		return
 	}
 
-	ctx := context.Background()
-	name := r.URL.Path[len("/api/v1/users/"):]
+	ctx := r.Context()
+	name := r.PathValue("name")
 
 	switch r.Method {
 	case http.MethodGet:
 		var user llmcloudv1alpha1.User
 		if err := s.client.Get(ctx, client.ObjectKey{Name: name}, &user); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+			writeError(w, "", err)
 			return
 		}
-		user.Spec.PasswordHash = ""
+		setETag(w, &user)
+		redactUser(&user)
 		s.writeJSON(w, user)
 
 	case http.MethodPut:
-		var user llmcloudv1alpha1.User
-		if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		// The password and API key hashes are never sent out, so they are kept from the
+		// stored user: the password changes only with spec.password, and spec.apiKeys,
+		// when given, names the keys to keep
+		var userReq struct {
+			llmcloudv1alpha1.User
+			Spec struct {
+				llmcloudv1alpha1.UserSpec
+				Password string                     `json:"password,omitempty"`
+				APIKeys  *[]llmcloudv1alpha1.APIKey `json:"apiKeys,omitempty"`
+			} `json:"spec"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&userReq); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if userReq.Name != "" && userReq.Name != name {
+			writeProblem(w, fmt.Sprintf("metadata.name %q does not match the user %q", userReq.Name, name), http.StatusBadRequest)
 			return
 		}
 
+		var current llmcloudv1alpha1.User
+		if err := s.client.Get(ctx, client.ObjectKey{Name: name}, &current); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		if r.Header.Get("If-Match") != "" {
+			if !checkIfMatch(w, r, &current) {
+				return
+			}
+			userReq.ResourceVersion = current.ResourceVersion
+		}
+
+		user := userReq.User
+		user.Name = name
+		user.Spec = userReq.Spec.UserSpec
+		user.Spec.PasswordHash = current.Spec.PasswordHash
+		if userReq.Spec.Password != "" {
+			if err := llmcloudv1alpha1.RuntimeConfig().PasswordPolicy.Check(userReq.Spec.Password); err != nil {
+				writeProblem(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			hash, err := auth.HashPassword(userReq.Spec.Password)
+			if err != nil {
+				writeProblem(w, "Failed to hash password", http.StatusInternalServerError)
+				return
+			}
+			user.Spec.PasswordHash = hash
+		}
+		user.Spec.APIKeys = current.Spec.APIKeys
+		if userReq.Spec.APIKeys != nil {
+			keys, err := keptAPIKeys(current.Spec.APIKeys, *userReq.Spec.APIKeys)
+			if err != nil {
+				writeProblem(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			user.Spec.APIKeys = keys
+		}
+
 		if err := s.client.Update(ctx, &user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 
-		user.Spec.PasswordHash = ""
+		setETag(w, &user)
+		redactUser(&user)
 		s.writeJSON(w, user)
 
 	case http.MethodDelete:
@@ -554,21 +2096,57 @@ func (s *Server) handleUser(w http.ResponseWriter, r *http.Request) {
 			ObjectMeta: metav1.ObjectMeta{Name: name},
 		}
 		if err := s.client.Delete(ctx, user); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			writeError(w, "", err)
 			return
 		}
 		w.WriteHeader(http.StatusNoContent)
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleVMCredentials handles GET /api/v1/namespaces/{namespace}/vms/{name}/credentials
+// (admin only), returning the username and generated password of the VM's default user.
+// The password grants access to the guest, so read-only tokens may not read it
+func (s *Server) handleVMCredentials(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin || claims.ReadOnly {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/v1/namespaces/"))
+	if len(parts) != 4 || parts[1] != "vms" || parts[3] != "credentials" {
+		writeProblem(w, "Invalid path, expected: /api/v1/namespaces/{namespace}/vms/{name}/credentials", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: parts[0], Name: parts[2]}, vm); err != nil {
+		writeError(w, "Failed to get VM", err)
+		return
+	}
+	if !controller.HasGeneratedCredentials(vm) {
+		writeProblem(w, "The VM has a custom cloud-init, so no credentials are generated for it", http.StatusNotFound)
+		return
 	}
+	secret := &corev1.Secret{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: controller.VMCredentialsSecretName(vm)}, secret); err != nil {
+		writeError(w, "Failed to get VM credentials", err)
+		return
+	}
+	s.writeJSON(w, map[string]string{
+		"username": string(secret.Data[corev1.BasicAuthUsernameKey]),
+		"password": string(secret.Data[corev1.BasicAuthPasswordKey]),
+	})
 }
 
 // handleVMDescribe returns kubectl describe output for a KubeVirt VM
 // URL format: /api/v1/describe/vm/{namespace}/{name}
 func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
@@ -576,7 +2154,7 @@ func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path[len("/api/v1/describe/vm/"):]
 	parts := splitPath(path)
 	if len(parts) < 2 {
-		http.Error(w, "Invalid path, expected: /api/v1/describe/vm/{namespace}/{name}", http.StatusBadRequest)
+		writeProblem(w, "Invalid path, expected: /api/v1/describe/vm/{namespace}/{name}", http.StatusBadRequest)
 		return
 	}
 
@@ -593,7 +2171,7 @@ func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, kvVM); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get KubeVirt VM: %v", err), http.StatusNotFound)
+		writeError(w, "Failed to get KubeVirt VM", err)
 		return
 	}
 
@@ -617,13 +2195,13 @@ func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 	// Convert to JSON first, then to YAML for proper formatting
 	vmJSON, err := json.MarshalIndent(cleanVM.Object, "", "  ")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to marshal VM to JSON: %v", err), http.StatusInternalServerError)
+		writeError(w, "Failed to marshal VM to JSON", err)
 		return
 	}
 
 	vmYaml, err := yaml.JSONToYAML(vmJSON)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to convert VM to YAML: %v", err), http.StatusInternalServerError)
+		writeError(w, "Failed to convert VM to YAML", err)
 		return
 	}
 
@@ -643,11 +2221,26 @@ func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// The description lives on our VirtualMachine, not the KubeVirt one
+	var description, cloudInit string
+	var guest *llmcloudv1alpha1.GuestInfo
+	var interfaces []llmcloudv1alpha1.VMInterface
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, vm); err == nil {
+		description = vm.Spec.Description
+		cloudInit = cloudInitSummary(vm)
+		guest = vm.Status.Guest
+		interfaces = vm.Status.Interfaces
+	}
+
 	// Build describe-style output
-	describe := buildVMDescribe(kvVM, vmi, vmiExists)
+	describe := buildVMDescribe(kvVM, vmi, vmiExists, description, cloudInit, guest)
 
 	s.writeJSON(w, map[string]interface{}{
-		"describe": describe,
+		"describe":    describe,
+		"description": description,
+		"guest":       guest,
+		"interfaces":  interfaces,
 		"yaml": map[string]interface{}{
 			"vm":  string(vmYaml),
 			"vmi": string(vmiYaml),
@@ -655,13 +2248,79 @@ func (s *Server) handleVMDescribe(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// buildVMDescribe creates a kubectl describe-style output
-func buildVMDescribe(vm *unstructured.Unstructured, vmi *unstructured.Unstructured, vmiExists bool) string {
+// handlePreviewVM renders the KubeVirt VirtualMachine the controller would create for
+// the posted VirtualMachineSpec as YAML, without creating anything. The name and
+// namespace used in the manifest come from the optional name and namespace query parameters
+func (s *Server) handlePreviewVM(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var spec llmcloudv1alpha1.VirtualMachineSpec
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if spec.OS == "" {
+		writeProblem(w, "os is required", http.StatusBadRequest)
+		return
+	}
+
+	// Apply the CRD defaults the API server would otherwise fill in on creation
+	if spec.CPUs == 0 {
+		spec.CPUs = 1
+	}
+	if spec.Memory == "" {
+		spec.Memory = "1Gi"
+	}
+	if spec.DiskSize == "" {
+		spec.DiskSize = "10Gi"
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "preview"
+	}
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: r.URL.Query().Get("namespace")},
+		Spec:       spec,
+	}
+	if err := validateResource(vm); err != nil {
+		writeProblem(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vmYaml, err := yaml.Marshal(controller.PreviewKubeVirtVM(vm, s.Images).Object)
+	if err != nil {
+		writeError(w, "Failed to convert VM to YAML", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	if _, err := w.Write(vmYaml); err != nil {
+		log.FromContext(r.Context()).Error(err, "failed to write VM preview")
+	}
+}
+
+// buildVMDescribe creates a kubectl describe-style output. guest is what the guest
+// agent reported, or nil while it is not connected
+func buildVMDescribe(vm *unstructured.Unstructured, vmi *unstructured.Unstructured, vmiExists bool, description, cloudInit string, guest *llmcloudv1alpha1.GuestInfo) string {
 	var output strings.Builder
 
 	// VM Header
 	output.WriteString(fmt.Sprintf("Name:         %s\n", vm.GetName()))
 	output.WriteString(fmt.Sprintf("Namespace:    %s\n", vm.GetNamespace()))
+	if description == "" {
+		output.WriteString("Description:  <none>\n")
+	} else {
+		output.WriteString(fmt.Sprintf("Description:  %s\n", description))
+	}
+	if cloudInit == "" {
+		output.WriteString("Cloud-Init:   <unknown>\n")
+	} else {
+		output.WriteString(fmt.Sprintf("Cloud-Init:   %s\n", cloudInit))
+	}
 
 	// Labels
 	labels := vm.GetLabels()
@@ -777,12 +2436,19 @@ func buildVMDescribe(vm *unstructured.Unstructured, vmi *unstructured.Unstructur
 			for i, iface := range interfaces {
 				if ifaceMap, ok := iface.(map[string]interface{}); ok {
 					output.WriteString(fmt.Sprintf("  [%d]:\n", i))
-					if ip, ok := ifaceMap["ipAddress"].(string); ok {
+					if ips, ok := ifaceMap["ipAddresses"].([]interface{}); ok && len(ips) > 0 {
+						for _, ip := range ips {
+							output.WriteString(fmt.Sprintf("    IP Address: %v\n", ip))
+						}
+					} else if ip, ok := ifaceMap["ipAddress"].(string); ok {
 						output.WriteString(fmt.Sprintf("    IP Address: %s\n", ip))
 					}
 					if name, ok := ifaceMap["name"].(string); ok {
 						output.WriteString(fmt.Sprintf("    Name:       %s\n", name))
 					}
+					if name, ok := ifaceMap["interfaceName"].(string); ok {
+						output.WriteString(fmt.Sprintf("    Guest Name: %s\n", name))
+					}
 				}
 			}
 		}
@@ -799,274 +2465,341 @@ func buildVMDescribe(vm *unstructured.Unstructured, vmi *unstructured.Unstructur
 		}
 	}
 
+	output.WriteString(describeGuest(guest))
+
 	// Events (placeholder - would need to query events separately)
 	output.WriteString("\nEvents: <use kubectl get events to see events>\n")
 
 	return output.String()
 }
 
-// handleVMEvents handles GET /api/v1/events/vm/{namespace}/{name}
-func (s *Server) handleVMEvents(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// describeGuest describes what the guest agent reported, in the format of buildVMDescribe
+func describeGuest(guest *llmcloudv1alpha1.GuestInfo) string {
+	if guest == nil {
+		return "\nGuest Agent: <not connected>\n"
 	}
 
-	// Parse namespace and name from path: /api/v1/events/vm/{namespace}/{name}
-	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/events/vm/"), "/")
-	if len(parts) != 2 {
-		http.Error(w, "Invalid path format", http.StatusBadRequest)
-		return
+	var output strings.Builder
+	output.WriteString("\nGuest Agent:\n")
+	output.WriteString(fmt.Sprintf("  Hostname:  %s\n", guest.Hostname))
+	osName := guest.OS.PrettyName
+	if osName == "" {
+		osName = strings.TrimSpace(guest.OS.Name + " " + guest.OS.Version)
 	}
-	namespace, name := parts[0], parts[1]
-
-	ctx := context.Background()
-
-	// Get events related to the VM
-	eventList := &unstructured.UnstructuredList{}
-	eventList.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "",
-		Version: "v1",
-		Kind:    "EventList",
-	})
-
-	// List all events in the namespace
-	if err := s.client.List(ctx, eventList, client.InNamespace(namespace)); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to list events: %v", err), http.StatusInternalServerError)
-		return
+	output.WriteString(fmt.Sprintf("  OS:        %s\n", osName))
+	output.WriteString(fmt.Sprintf("  Kernel:    %s %s\n", guest.OS.KernelRelease, guest.OS.Machine))
+	output.WriteString(fmt.Sprintf("  Timezone:  %s\n", guest.Timezone))
+	if guest.UpdatedAt != nil {
+		output.WriteString(fmt.Sprintf("  Updated:   %s\n", guest.UpdatedAt.UTC().Format(time.RFC3339)))
 	}
 
-	// Filter events related to this VM
-	type Event struct {
-		Type               string `json:"type"`
-		Reason             string `json:"reason"`
-		Message            string `json:"message"`
-		FirstTimestamp     string `json:"firstTimestamp"`
-		LastTimestamp      string `json:"lastTimestamp"`
-		Count              int64  `json:"count"`
-		InvolvedObjectName string `json:"involvedObjectName"`
-		InvolvedObjectKind string `json:"involvedObjectKind"`
-		Source             string `json:"source"`
+	output.WriteString("  Filesystems:\n")
+	if len(guest.Filesystems) == 0 {
+		output.WriteString("    <none>\n")
+	}
+	for _, fs := range guest.Filesystems {
+		output.WriteString(fmt.Sprintf("    %s (%s on %s): %s of %s used\n", fs.MountPoint, fs.Type, fs.Disk,
+			formatBytes(fs.UsedBytes), formatBytes(fs.TotalBytes)))
 	}
 
-	var events []Event
-	for _, item := range eventList.Items {
-		involvedObj, _, _ := unstructured.NestedMap(item.Object, "involvedObject")
-		if involvedObj == nil {
-			continue
+	output.WriteString("  Users:\n")
+	if len(guest.Users) == 0 {
+		output.WriteString("    <none>\n")
+	}
+	for _, user := range guest.Users {
+		name := user.Name
+		if user.Domain != "" {
+			name = user.Domain + "\\" + user.Name
 		}
-
-		objName, _ := involvedObj["name"].(string)
-		objKind, _ := involvedObj["kind"].(string)
-
-		// Include events for the VM and related VMI
-		if objName == name && (objKind == "VirtualMachine" || objKind == "VirtualMachineInstance") {
-			event := Event{
-				InvolvedObjectName: objName,
-				InvolvedObjectKind: objKind,
-			}
-
-			if eventType, ok, _ := unstructured.NestedString(item.Object, "type"); ok {
-				event.Type = eventType
-			}
-			if reason, ok, _ := unstructured.NestedString(item.Object, "reason"); ok {
-				event.Reason = reason
-			}
-			if message, ok, _ := unstructured.NestedString(item.Object, "message"); ok {
-				event.Message = message
-			}
-			if firstTimestamp, ok, _ := unstructured.NestedString(item.Object, "firstTimestamp"); ok {
-				event.FirstTimestamp = firstTimestamp
-			}
-			if lastTimestamp, ok, _ := unstructured.NestedString(item.Object, "lastTimestamp"); ok {
-				event.LastTimestamp = lastTimestamp
-			}
-			if count, ok, _ := unstructured.NestedInt64(item.Object, "count"); ok {
-				event.Count = count
-			}
-			if source, ok, _ := unstructured.NestedMap(item.Object, "source"); ok {
-				if component, ok := source["component"].(string); ok {
-					event.Source = component
-				}
-			}
-
-			events = append(events, event)
+		if user.LoginTime != nil {
+			name += fmt.Sprintf(" (since %s)", user.LoginTime.UTC().Format(time.RFC3339))
 		}
+		output.WriteString(fmt.Sprintf("    %s\n", name))
 	}
+	return output.String()
+}
 
-	s.writeJSON(w, map[string]interface{}{
-		"events": events,
-	})
+// formatBytes formats n bytes with a binary unit, e.g. 1.5GiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
 }
 
-// handleClusterNodes handles GET /api/v1/nodes and POST /api/v1/nodes (cluster-wide, admin only)
-// Returns actual Kubernetes nodes, not custom Node CRD
-func (s *Server) handleClusterNodes(w http.ResponseWriter, r *http.Request) {
-	claims := r.Context().Value(claimsKey).(*auth.Claims)
-	if !claims.IsAdmin {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+// cloudInitSummary describes the cloud-init state recorded by the controller, or
+// returns "" when it has not been reported
+func cloudInitSummary(vm *llmcloudv1alpha1.VirtualMachine) string {
+	cond := meta.FindStatusCondition(vm.Status.Conditions, "CloudInitComplete")
+	if cond == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", cond.Reason, cond.Message)
+}
+
+// handleVMCloudInit handles /api/v1/cloudinit/vm/{namespace}/{name}. GET returns the
+// cloud-init status of the VM; POST reports it with a body of {"status": "done"}, for
+// guests whose agent does not let the controller read it, e.g. from a script running
+// "cloud-init status --wait"
+func (s *Server) handleVMCloudInit(w http.ResponseWriter, r *http.Request) {
+	parts := splitPath(strings.TrimPrefix(r.URL.Path, "/api/v1/cloudinit/vm/"))
+	if len(parts) != 2 {
+		writeProblem(w, "Invalid path, expected: /api/v1/cloudinit/vm/{namespace}/{name}", http.StatusBadRequest)
 		return
 	}
 
-	ctx := context.Background()
+	ctx := r.Context()
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := s.client.Get(ctx, client.ObjectKey{Namespace: parts[0], Name: parts[1]}, vm); err != nil {
+		writeError(w, "", err)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
-		// Get actual Kubernetes nodes
-		nodeList := &unstructured.UnstructuredList{}
-		nodeList.SetGroupVersionKind(schema.GroupVersionKind{
-			Group:   "",
-			Version: "v1",
-			Kind:    "NodeList",
+		s.writeJSON(w, map[string]interface{}{
+			"reported": vm.Annotations[llmcloudv1alpha1.CloudInitStatusAnnotation],
+			"complete": vm.Status.CloudInitComplete,
+			"summary":  cloudInitSummary(vm),
 		})
 
-		if err := s.client.List(ctx, nodeList); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to list nodes: %v", err), http.StatusInternalServerError)
-			return
-		}
-
-		s.writeJSON(w, nodeList)
-
 	case http.MethodPost:
-		// Add a new node
 		var req struct {
-			Host     string `json:"host"`     // SSH host (user@host or host)
-			Role     string `json:"role"`     // "master" or "worker"
-			SSHKey   string `json:"sshKey"`   // Optional SSH key path
-			Password string `json:"password"` // Optional SSH password
+			Status string `json:"status"`
 		}
-
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			writeProblem(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		if req.Host == "" {
-			http.Error(w, "Host is required", http.StatusBadRequest)
+		switch req.Status {
+		case "running", "done", "error":
+		default:
+			writeProblem(w, "status must be one of: running, done, error", http.StatusBadRequest)
 			return
 		}
 
-		if req.Role != "master" && req.Role != "worker" {
-			http.Error(w, "Role must be 'master' or 'worker'", http.StatusBadRequest)
-			return
+		if vm.Annotations == nil {
+			vm.Annotations = make(map[string]string)
 		}
-
-		// Execute k0s join command via SSH
-		if err := s.addNode(req.Host, req.Role); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to add node: %v", err), http.StatusInternalServerError)
+		vm.Annotations[llmcloudv1alpha1.CloudInitStatusAnnotation] = req.Status
+		if err := s.client.Update(ctx, vm); err != nil {
+			writeError(w, "", err)
 			return
 		}
-
-		s.writeJSON(w, map[string]string{"status": "success", "message": "Node join initiated"})
+		s.writeJSON(w, map[string]string{"status": "success"})
 
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 	}
 }
 
-// handleNodeActions handles DELETE /api/v1/nodes/:name
-func (s *Server) handleNodeActions(w http.ResponseWriter, r *http.Request) {
+// storageUsage summarizes capacity and usage for a group of volumes
+type storageUsage struct {
+	Count    int    `json:"count"`
+	Bound    int    `json:"bound"`
+	Capacity string `json:"capacity"`
+}
+
+// nodeStorage reports the local storage capacity of a node
+type nodeStorage struct {
+	Name        string `json:"name"`
+	Capacity    string `json:"capacity,omitempty"`
+	Allocatable string `json:"allocatable,omitempty"`
+}
+
+// storageSummary is the response of GET /api/v1/cluster/storage
+type storageSummary struct {
+	PersistentVolumes      storageUsage            `json:"persistentVolumes"`
+	PersistentVolumeClaims storageUsage            `json:"persistentVolumeClaims"`
+	StorageClasses         map[string]storageUsage `json:"storageClasses"`
+	Nodes                  []nodeStorage           `json:"nodes"`
+}
+
+// handleClusterStorage handles GET /api/v1/cluster/storage (admin only)
+// Reports PV/PVC usage and node ephemeral storage capacity
+func (s *Server) handleClusterStorage(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(claimsKey).(*auth.Claims)
 	if !claims.IsAdmin {
-		http.Error(w, "Admin access required", http.StatusForbidden)
+		writeProblem(w, "Admin access required", http.StatusForbidden)
 		return
 	}
 
-	// Extract node name from path: /api/v1/nodes/:name
-	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v1/nodes/"), "/")
-	if len(parts) == 0 || parts[0] == "" {
-		http.Error(w, "Node name required", http.StatusBadRequest)
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	nodeName := parts[0]
 
-	switch r.Method {
-	case http.MethodDelete:
-		// Remove node from cluster
-		if err := s.removeNode(nodeName); err != nil {
-			http.Error(w, fmt.Sprintf("Failed to remove node: %v", err), http.StatusInternalServerError)
+	ctx := context.Background()
+	lists := map[string]*unstructured.UnstructuredList{}
+	for _, kind := range []string{"PersistentVolumeList", "PersistentVolumeClaimList", "NodeList"} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: kind})
+		if err := s.client.List(ctx, list); err != nil {
+			writeError(w, "Failed to list "+kind, err)
 			return
 		}
-
-		s.writeJSON(w, map[string]string{"status": "success", "message": "Node removed"})
-
-	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		lists[kind] = list
 	}
+
+	s.writeJSON(w, summarizeStorage(
+		lists["PersistentVolumeList"].Items,
+		lists["PersistentVolumeClaimList"].Items,
+		lists["NodeList"].Items,
+	))
 }
 
-// addNode adds a new node to the k0s cluster via SSH
-func (s *Server) addNode(host, role string) error {
-	// Get k0s token from the controller
-	var tokenType string
-	if role == "master" {
-		tokenType = "controller"
-	} else {
-		tokenType = "worker"
+// summarizeStorage aggregates PV, PVC and node storage into a storageSummary
+func summarizeStorage(pvs, pvcs, nodes []unstructured.Unstructured) storageSummary {
+	summary := storageSummary{StorageClasses: map[string]storageUsage{}}
+
+	pvTotal := resource.Quantity{}
+	classTotals := map[string]*resource.Quantity{}
+	for _, pv := range pvs {
+		capacity := controller.NestedQuantity(pv.Object, "spec", "capacity", "storage")
+		phase, _, _ := unstructured.NestedString(pv.Object, "status", "phase")
+		class, _, _ := unstructured.NestedString(pv.Object, "spec", "storageClassName")
+
+		pvTotal.Add(capacity)
+		summary.PersistentVolumes.Count++
+
+		usage := summary.StorageClasses[class]
+		usage.Count++
+		if phase == "Bound" {
+			summary.PersistentVolumes.Bound++
+			usage.Bound++
+		}
+		if classTotals[class] == nil {
+			classTotals[class] = &resource.Quantity{}
+		}
+		classTotals[class].Add(capacity)
+		usage.Capacity = classTotals[class].String()
+		summary.StorageClasses[class] = usage
 	}
-
-	// Generate k0s token
-	tokenCmd := fmt.Sprintf("sudo k0s token create --role=%s", tokenType)
-	token, err := s.executeSSHCommand("", tokenCmd) // Empty host means execute locally
-	if err != nil {
-		return fmt.Errorf("failed to generate k0s token: %v", err)
+	summary.PersistentVolumes.Capacity = pvTotal.String()
+
+	pvcTotal := resource.Quantity{}
+	for _, pvc := range pvcs {
+		pvcTotal.Add(controller.NestedQuantity(pvc.Object, "spec", "resources", "requests", "storage"))
+		summary.PersistentVolumeClaims.Count++
+		if phase, _, _ := unstructured.NestedString(pvc.Object, "status", "phase"); phase == "Bound" {
+			summary.PersistentVolumeClaims.Bound++
+		}
 	}
-
-	// Install k0s on the target node
-	installCmd := "curl -sSLf https://get.k0s.sh | sudo sh"
-	if _, err := s.executeSSHCommand(host, installCmd); err != nil {
-		return fmt.Errorf("failed to install k0s: %v", err)
+	summary.PersistentVolumeClaims.Capacity = pvcTotal.String()
+
+	for _, node := range nodes {
+		capacity, _, _ := unstructured.NestedString(node.Object, "status", "capacity", "ephemeral-storage")
+		allocatable, _, _ := unstructured.NestedString(node.Object, "status", "allocatable", "ephemeral-storage")
+		summary.Nodes = append(summary.Nodes, nodeStorage{
+			Name:        node.GetName(),
+			Capacity:    capacity,
+			Allocatable: allocatable,
+		})
 	}
 
-	// Join the cluster
-	joinCmd := fmt.Sprintf("sudo k0s install %s --token='%s'", tokenType, strings.TrimSpace(token))
-	if _, err := s.executeSSHCommand(host, joinCmd); err != nil {
-		return fmt.Errorf("failed to join cluster: %v", err)
-	}
+	return summary
+}
 
-	// Start k0s service
-	startCmd := "sudo k0s start"
-	if _, err := s.executeSSHCommand(host, startCmd); err != nil {
-		return fmt.Errorf("failed to start k0s: %v", err)
-	}
+// gpuResourceNames are the extended resources counted as GPUs
+var gpuResourceNames = []string{"nvidia.com/gpu", "amd.com/gpu"}
 
-	return nil
+// nodeGPUs reports the GPUs of a node and how many are requested by its pods
+type nodeGPUs struct {
+	Name        string `json:"name"`
+	Total       int64  `json:"total"`
+	Allocatable int64  `json:"allocatable"`
+	Used        int64  `json:"used"`
 }
 
-// removeNode removes a node from the k0s cluster
-func (s *Server) removeNode(nodeName string) error {
-	// First, drain the node
-	drainCmd := fmt.Sprintf("kubectl drain %s --ignore-daemonsets --delete-emptydir-data --force --timeout=60s", nodeName)
-	if _, err := s.executeSSHCommand("", drainCmd); err != nil {
-		return fmt.Errorf("failed to drain node: %v", err)
+// gpuSummary is the response of GET /api/v1/cluster/gpus
+type gpuSummary struct {
+	Total       int64            `json:"total"`
+	Allocatable int64            `json:"allocatable"`
+	Used        int64            `json:"used"`
+	Nodes       []nodeGPUs       `json:"nodes"`
+	Projects    map[string]int64 `json:"projects"`
+}
+
+// handleClusterGPUs handles GET /api/v1/cluster/gpus (admin only)
+// Reports GPU capacity per node and the GPUs requested by scheduled pods
+func (s *Server) handleClusterGPUs(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	if !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
 	}
 
-	// Delete the node from Kubernetes
-	deleteCmd := fmt.Sprintf("kubectl delete node %s", nodeName)
-	if _, err := s.executeSSHCommand("", deleteCmd); err != nil {
-		return fmt.Errorf("failed to delete node: %v", err)
+	if r.Method != http.MethodGet {
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
-	return nil
+	ctx := context.Background()
+	lists := map[string]*unstructured.UnstructuredList{}
+	for _, kind := range []string{"NodeList", "PodList"} {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(schema.GroupVersionKind{Group: "", Version: "v1", Kind: kind})
+		if err := s.client.List(ctx, list); err != nil {
+			writeError(w, "Failed to list "+kind, err)
+			return
+		}
+		lists[kind] = list
+	}
+
+	s.writeJSON(w, summarizeGPUs(lists["NodeList"].Items, lists["PodList"].Items))
 }
 
-// executeSSHCommand executes a command via SSH
-// If host is empty, executes locally
-func (s *Server) executeSSHCommand(host, command string) (string, error) {
-	var cmd *exec.Cmd
+// summarizeGPUs aggregates node GPU capacity and the GPU requests of running pods.
+// Pods in project namespaces are also attributed to their project
+func summarizeGPUs(nodes, pods []unstructured.Unstructured) gpuSummary {
+	summary := gpuSummary{Projects: map[string]int64{}}
 
-	if host == "" {
-		// Execute locally
-		cmd = exec.Command("bash", "-c", command)
-	} else {
-		// Execute via SSH
-		cmd = exec.Command("ssh", "-o", "StrictHostKeyChecking=no", host, command)
+	used := map[string]int64{}
+	for _, pod := range pods {
+		nodeName, _, _ := unstructured.NestedString(pod.Object, "spec", "nodeName")
+		phase, _, _ := unstructured.NestedString(pod.Object, "status", "phase")
+		if nodeName == "" || phase == "Succeeded" || phase == "Failed" {
+			continue
+		}
+		gpus := podGPURequest(pod.Object)
+		if gpus == 0 {
+			continue
+		}
+		used[nodeName] += gpus
+		if project, ok := strings.CutPrefix(pod.GetNamespace(), "project-"); ok {
+			summary.Projects[project] += gpus
+		}
 	}
 
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("command failed: %v, output: %s", err, string(output))
+	for _, node := range nodes {
+		n := nodeGPUs{Name: node.GetName(), Used: used[node.GetName()]}
+		for _, name := range gpuResourceNames {
+			total := controller.NestedQuantity(node.Object, "status", "capacity", name)
+			allocatable := controller.NestedQuantity(node.Object, "status", "allocatable", name)
+			n.Total += total.Value()
+			n.Allocatable += allocatable.Value()
+		}
+		summary.Total += n.Total
+		summary.Allocatable += n.Allocatable
+		summary.Used += n.Used
+		summary.Nodes = append(summary.Nodes, n)
 	}
 
-	return string(output), nil
+	return summary
+}
+
+// podGPURequest returns the GPUs a pod holds, counted as podRequest counts resources
+func podGPURequest(pod map[string]interface{}) int64 {
+	var gpus int64
+	for _, name := range gpuResourceNames {
+		q := podRequest(pod, name)
+		gpus += q.Value()
+	}
+	return gpus
 }
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// canManageSSHKey reports whether claims allow seeing and deleting key: admins may
+// manage every key, other users their own keys and those of their projects
+func canManageSSHKey(claims *auth.Claims, key *llmcloudv1alpha1.SSHKey) bool {
+	if claims.IsAdmin {
+		return true
+	}
+	if key.Spec.User != "" {
+		return key.Spec.User == claims.Username
+	}
+	return auth.HasProjectAccess(claims, key.Spec.Project)
+}
+
+// handleSSHKeys lists the SSH keys the caller can manage, or creates one. A new key
+// without a user or project belongs to the caller
+func (s *Server) handleSSHKeys(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var keys llmcloudv1alpha1.SSHKeyList
+		if err := s.client.List(ctx, &keys); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		visible := keys.Items[:0]
+		for _, key := range keys.Items {
+			if canManageSSHKey(claims, &key) {
+				visible = append(visible, key)
+			}
+		}
+		keys.Items = visible
+		s.writeJSON(w, keys)
+
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+			llmcloudv1alpha1.SSHKeySpec
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.User == "" && req.Project == "" {
+			req.User = claims.Username
+		}
+		key := &llmcloudv1alpha1.SSHKey{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name},
+			Spec:       req.SSHKeySpec,
+		}
+		allErrs := llmcloudv1alpha1.ValidateSSHKeySpec(&key.Spec, field.NewPath("spec"))
+		for _, msg := range validation.IsDNS1123Subdomain(req.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("name"), req.Name, msg))
+		}
+		if err := allErrs.ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !canManageSSHKey(claims, key) {
+			writeProblem(w, "Keys can only be added for yourself or your projects", http.StatusForbidden)
+			return
+		}
+		if err := s.client.Create(ctx, key); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, key)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSSHKey returns or deletes an SSH key the caller can manage. Keys of other users
+// are reported as not found
+func (s *Server) handleSSHKey(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+	name := r.URL.Path[len("/api/v1/sshkeys/"):]
+
+	key := &llmcloudv1alpha1.SSHKey{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, key); err != nil || !canManageSSHKey(claims, key) {
+		writeProblem(w, "SSH key not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.writeJSON(w, key)
+
+	case http.MethodDelete:
+		if err := s.client.Delete(ctx, key); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
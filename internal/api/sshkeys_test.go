@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGQdgUmX97JMXLbmXCll+bvnMY+OT7LrGdVP1JFp8L+J test@llmcloud"
+
+func withClaims(req *http.Request, claims *auth.Claims) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), claimsKey, claims))
+}
+
+func TestHandleSSHKeysCreate(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	tests := []struct {
+		name     string
+		body     string
+		want     int
+		wantUser string
+	}{
+		{name: "defaults to the caller", body: `{"name": "laptop", "publicKey": "` + testSSHKey + `"}`, want: http.StatusOK, wantUser: "alice"},
+		{name: "for own project", body: `{"name": "deploy", "publicKey": "` + testSSHKey + `", "project": "team"}`, want: http.StatusOK},
+		{name: "for another user", body: `{"name": "bobs", "publicKey": "` + testSSHKey + `", "user": "bob"}`, want: http.StatusForbidden},
+		{name: "for another project", body: `{"name": "others", "publicKey": "` + testSSHKey + `", "project": "other"}`, want: http.StatusForbidden},
+		{name: "invalid key", body: `{"name": "broken", "publicKey": "ssh-rsa nope"}`, want: http.StatusBadRequest},
+		{name: "duplicate", body: `{"name": "laptop", "publicKey": "` + testSSHKey + `"}`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest("POST", "/api/v1/sshkeys", strings.NewReader(tt.body)), alice)
+			w := httptest.NewRecorder()
+			s.handleSSHKeys(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+			if tt.wantUser == "" {
+				return
+			}
+			var key llmcloudv1alpha1.SSHKey
+			if err := json.NewDecoder(w.Body).Decode(&key); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if key.Spec.User != tt.wantUser {
+				t.Errorf("Expected key owned by %q, got %q", tt.wantUser, key.Spec.User)
+			}
+		})
+	}
+}
+
+func TestHandleSSHKeysVisibility(t *testing.T) {
+	c := setupTestClient()
+	for _, key := range []*llmcloudv1alpha1.SSHKey{
+		{ObjectMeta: metav1.ObjectMeta{Name: "alice-laptop"}, Spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey, User: "alice"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "bob-laptop"}, Spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey, User: "bob"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "team-deploy"}, Spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey, Project: "team"}},
+	} {
+		if err := c.Create(context.Background(), key); err != nil {
+			t.Fatalf("Failed to create key: %v", err)
+		}
+	}
+	s := &Server{client: c}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	w := httptest.NewRecorder()
+	s.handleSSHKeys(w, withClaims(httptest.NewRequest("GET", "/api/v1/sshkeys", nil), alice))
+	var keys llmcloudv1alpha1.SSHKeyList
+	if err := json.NewDecoder(w.Body).Decode(&keys); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	var names []string
+	for _, key := range keys.Items {
+		names = append(names, key.Name)
+	}
+	if strings.Join(names, ",") != "alice-laptop,team-deploy" {
+		t.Errorf("Expected alice to see her own and her project's keys, got %v", names)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleSSHKey(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/sshkeys/bob-laptop", nil), alice))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected another user's key to be hidden, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleSSHKey(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/sshkeys/team-deploy", nil), alice))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status NoContent, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "team-deploy"}, &llmcloudv1alpha1.SSHKey{}); err == nil {
+		t.Error("Expected the project key to be deleted")
+	}
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=vmtemplates,verbs=get;list;watch;create;update;delete
+
+// handleTemplates lists the VM templates, which every user may create VMs from, or
+// creates one, which only admins may
+func (s *Server) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodGet:
+		var templates llmcloudv1alpha1.VMTemplateList
+		if err := s.client.List(ctx, &templates); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, templates)
+
+	case http.MethodPost:
+		if !claims.IsAdmin {
+			writeProblem(w, "Admin access required", http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Name string `json:"name"`
+			llmcloudv1alpha1.VMTemplateSpec
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template := &llmcloudv1alpha1.VMTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: req.Name},
+			Spec:       req.VMTemplateSpec,
+		}
+		allErrs := llmcloudv1alpha1.ValidateVMTemplateSpec(&template.Spec, field.NewPath("spec"))
+		for _, msg := range validation.IsDNS1123Subdomain(req.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("name"), req.Name, msg))
+		}
+		if err := allErrs.ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.client.Create(ctx, template); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		s.writeJSON(w, template)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleTemplate returns a VM template, or replaces its spec with the request body or
+// deletes it, which only admins may. VMs created from the template keep their values
+func (s *Server) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(claimsKey).(*auth.Claims)
+	ctx := r.Context()
+	name := r.URL.Path[len("/api/v1/templates/"):]
+
+	if r.Method != http.MethodGet && !claims.IsAdmin {
+		writeProblem(w, "Admin access required", http.StatusForbidden)
+		return
+	}
+	template := &llmcloudv1alpha1.VMTemplate{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, template); err != nil {
+		writeProblem(w, "VM template not found", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		setETag(w, template)
+		s.writeJSON(w, template)
+
+	case http.MethodPut:
+		if !checkIfMatch(w, r, template) {
+			return
+		}
+		var spec llmcloudv1alpha1.VMTemplateSpec
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&spec); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := llmcloudv1alpha1.ValidateVMTemplateSpec(&spec, field.NewPath("spec")).ToAggregate(); err != nil {
+			writeProblem(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		template.Spec = spec
+		if err := s.client.Update(ctx, template); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		setETag(w, template)
+		s.writeJSON(w, template)
+
+	case http.MethodDelete:
+		if err := s.client.Delete(ctx, template); err != nil {
+			writeError(w, "", err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeProblem(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// applyVMTemplate fills the fields a new VM leaves unset from the VMTemplate it
+// references, returning the HTTP status to report on failure
+func (s *Server) applyVMTemplate(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (int, error) {
+	template := &llmcloudv1alpha1.VMTemplate{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: vm.Spec.TemplateRef}, template); err != nil {
+		if apierrors.IsNotFound(err) {
+			return http.StatusBadRequest, fmt.Errorf("VM template %q not found", vm.Spec.TemplateRef)
+		}
+		return http.StatusInternalServerError, err
+	}
+	template.ApplyTo(&vm.Spec)
+	return http.StatusOK, nil
+}
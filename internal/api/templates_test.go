@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+func TestHandleTemplatesCreate(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	tests := []struct {
+		name   string
+		claims *auth.Claims
+		body   string
+		want   int
+	}{
+		{name: "by an admin", claims: admin, body: `{"name": "small", "cpus": 2, "memory": "4Gi", "os": "ubuntu"}`, want: http.StatusOK},
+		{name: "by a user", claims: alice, body: `{"name": "big", "cpus": 16}`, want: http.StatusForbidden},
+		{name: "invalid size", claims: admin, body: `{"name": "broken", "memory": "lots"}`, want: http.StatusBadRequest},
+		{name: "invalid name", claims: admin, body: `{"name": "Not A Name", "cpus": 1}`, want: http.StatusBadRequest},
+		{name: "duplicate", claims: admin, body: `{"name": "small", "cpus": 1}`, want: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withClaims(httptest.NewRequest("POST", "/api/v1/templates", strings.NewReader(tt.body)), tt.claims)
+			w := httptest.NewRecorder()
+			s.handleTemplates(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	s.handleTemplates(w, withClaims(httptest.NewRequest("GET", "/api/v1/templates", nil), alice))
+	var templates llmcloudv1alpha1.VMTemplateList
+	if err := json.NewDecoder(w.Body).Decode(&templates); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(templates.Items) != 1 || templates.Items[0].Spec.Memory != "4Gi" {
+		t.Errorf("Expected users to see the small template, got %+v", templates.Items)
+	}
+}
+
+func TestHandleTemplate(t *testing.T) {
+	c := setupTestClient()
+	small := &llmcloudv1alpha1.VMTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "small"},
+		Spec:       llmcloudv1alpha1.VMTemplateSpec{CPUs: 2, Memory: "4Gi"},
+	}
+	if err := c.Create(context.Background(), small); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	s := &Server{client: c}
+	admin := &auth.Claims{Username: "root", IsAdmin: true}
+	alice := &auth.Claims{Username: "alice"}
+
+	w := httptest.NewRecorder()
+	s.handleTemplate(w, withClaims(httptest.NewRequest("PUT", "/api/v1/templates/small", strings.NewReader(`{"cpus": 4}`)), alice))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("Expected users not to change templates, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleTemplate(w, withClaims(httptest.NewRequest("PUT", "/api/v1/templates/small", strings.NewReader(`{"cpus": 4, "memory": "8Gi"}`)), admin))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	updated := &llmcloudv1alpha1.VMTemplate{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "small"}, updated); err != nil || updated.Spec.CPUs != 4 {
+		t.Errorf("Expected the template to have 4 CPUs, got %+v (%v)", updated.Spec, err)
+	}
+
+	w = httptest.NewRecorder()
+	s.handleTemplate(w, withClaims(httptest.NewRequest("DELETE", "/api/v1/templates/small", nil), admin))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected status NoContent, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	s.handleTemplate(w, withClaims(httptest.NewRequest("GET", "/api/v1/templates/small", nil), alice))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound after deletion, got %d", w.Code)
+	}
+}
+
+func TestHandleVMsPostWithTemplate(t *testing.T) {
+	c := setupTestClient()
+	large := &llmcloudv1alpha1.VMTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "large"},
+		Spec:       llmcloudv1alpha1.VMTemplateSpec{CPUs: 8, Memory: "32Gi", DiskSize: "100Gi", OS: "ubuntu", OSVersion: "24.04"},
+	}
+	if err := c.Create(context.Background(), large); err != nil {
+		t.Fatalf("Failed to create template: %v", err)
+	}
+	s := &Server{client: c}
+
+	body := `{"metadata": {"name": "worker", "namespace": "default"}, "spec": {"templateRef": "large", "memory": "64Gi"}}`
+	w := httptest.NewRecorder()
+	s.handleNamespaceResources(w, httptest.NewRequest("POST", "/api/v1/namespaces/default/vms", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "default", Name: "worker"}, vm); err != nil {
+		t.Fatalf("Failed to get VM: %v", err)
+	}
+	if vm.Spec.CPUs != 8 || vm.Spec.Memory != "64Gi" || vm.Spec.OS != "ubuntu" || vm.Spec.OSVersion != "24.04" {
+		t.Errorf("Expected the template's values under the VM's own, got %+v", vm.Spec)
+	}
+
+	body = `{"metadata": {"name": "other", "namespace": "default"}, "spec": {"templateRef": "missing"}}`
+	w = httptest.NewRecorder()
+	s.handleNamespaceResources(w, httptest.NewRequest("POST", "/api/v1/namespaces/default/vms", strings.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status BadRequest for a missing template, got %d", w.Code)
+	}
+}
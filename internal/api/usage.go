@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// usageReport is the metered inference usage of a project, with the daily and monthly
+// aggregates for chargeback, and the usage of each of its current models
+type usageReport struct {
+	Project                         string `json:"project"`
+	llmcloudv1alpha1.InferenceUsage `json:",inline"`
+	Models                          []modelUsageReport `json:"models"`
+}
+
+// modelUsageReport is the metered inference usage of a model
+type modelUsageReport struct {
+	Name                            string `json:"name"`
+	llmcloudv1alpha1.InferenceUsage `json:",inline"`
+}
+
+// handleProjectUsage handles GET /api/v1/usage/projects/{name}, reporting the inference
+// usage metered for the project and its models
+func (s *Server) handleProjectUsage(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	name := r.URL.Path[len("/api/v1/usage/projects/"):]
+
+	project := &llmcloudv1alpha1.Project{}
+	if err := s.client.Get(ctx, client.ObjectKey{Name: name}, project); err != nil {
+		writeProblem(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	var models llmcloudv1alpha1.LLMModelList
+	if err := s.client.List(ctx, &models, client.InNamespace("project-"+name)); err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	report := usageReport{Project: name, Models: []modelUsageReport{}}
+	if project.Status.InferenceUsage != nil {
+		report.InferenceUsage = *project.Status.InferenceUsage
+	}
+	for _, model := range models.Items {
+		modelReport := modelUsageReport{Name: model.Name}
+		if model.Status.Usage != nil {
+			modelReport.InferenceUsage = *model.Status.Usage
+		}
+		report.Models = append(report.Models, modelReport)
+	}
+	s.writeJSON(w, report)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	"github.com/rusik69/llmcloud-operator/internal/metering"
+)
+
+func TestInferenceUsageMetered(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `{"choices": [], "usage": {"prompt_tokens": 8, "completion_tokens": 16}}`)
+	}))
+	defer backend.Close()
+
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}, &llmcloudv1alpha1.Project{}).
+		WithObjects(
+			&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+			&llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: "mistral", Namespace: "project-team"},
+				Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "mistral", ServeProtocol: "openai"},
+				Status:     llmcloudv1alpha1.LLMModelStatus{Endpoint: backend.URL},
+			},
+			&llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "idle", Namespace: "project-team"}},
+		).Build()
+	meter := &metering.Meter{Client: c}
+	s := &Server{client: c, InferenceGateway: true, Meter: meter}
+	alice := &auth.Claims{Username: "alice", Projects: []string{"team"}}
+
+	for range 2 {
+		req := httptest.NewRequest("POST", "/api/v1/inference/project-team/mistral/chat/completions", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		s.handleInference(w, withClaims(req, alice))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+	meter.Flush(context.Background())
+
+	w := httptest.NewRecorder()
+	s.handleProjectUsage(w, withClaims(httptest.NewRequest("GET", "/api/v1/usage/projects/team", nil), alice))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status OK, got %d: %s", w.Code, w.Body.String())
+	}
+	var report usageReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := llmcloudv1alpha1.UsageCounts{Requests: 2, PromptTokens: 16, CompletionTokens: 32}
+	if report.Total != want {
+		t.Errorf("Expected project usage %+v, got %+v", want, report.Total)
+	}
+	if len(report.Daily) != 1 || len(report.Monthly) != 1 || report.Monthly[0].UsageCounts != want {
+		t.Errorf("Expected daily and monthly aggregates, got %+v and %+v", report.Daily, report.Monthly)
+	}
+	if len(report.Models) != 2 {
+		t.Fatalf("Expected both models, got %+v", report.Models)
+	}
+	for _, model := range report.Models {
+		if model.Name == "mistral" && model.Total != want {
+			t.Errorf("Expected the model's usage %+v, got %+v", want, model.Total)
+		}
+		if model.Name == "idle" && model.Total.Requests != 0 {
+			t.Errorf("Expected no usage for the idle model, got %+v", model.Total)
+		}
+	}
+}
+
+func TestHandleInferenceRollout(t *testing.T) {
+	backends := map[string]*httptest.Server{}
+	for name, status := range map[string]int{"llama": http.StatusOK, "llama-v2": http.StatusInternalServerError} {
+		backends[name] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			_, _ = io.WriteString(w, name)
+		}))
+		defer backends[name].Close()
+	}
+
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	model := &llmcloudv1alpha1.LLMModel{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "project-team"},
+		Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama", Rollout: &llmcloudv1alpha1.ModelRollout{
+			Canary: "llama-v2", Weight: 100,
+		}},
+		Status: llmcloudv1alpha1.LLMModelStatus{
+			Endpoint: backends["llama"].URL,
+			Rollout:  &llmcloudv1alpha1.ModelRolloutStatus{Canary: "llama-v2", Phase: llmcloudv1alpha1.RolloutPhaseProgressing},
+		},
+	}
+	canary := &llmcloudv1alpha1.LLMModel{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama-v2", Namespace: "project-team"},
+		Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "llama"},
+		Status:     llmcloudv1alpha1.LLMModelStatus{Endpoint: backends["llama-v2"].URL},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}).
+		WithObjects(model, canary).Build()
+	meter := &metering.Meter{Client: c}
+	s := &Server{client: c, InferenceGateway: true, Meter: meter}
+
+	infer := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/inference/project-team/llama/chat", strings.NewReader(`{}`))
+		w := httptest.NewRecorder()
+		s.handleInference(w, withClaims(req, &auth.Claims{Username: "alice", Projects: []string{"team"}}))
+		return w
+	}
+
+	if w := infer(); w.Code != http.StatusInternalServerError || w.Body.String() != "llama-v2" {
+		t.Fatalf("Expected the canary to serve the request, got %d: %s", w.Code, w.Body.String())
+	}
+	meter.Flush(context.Background())
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(model), model); err != nil {
+		t.Fatal(err)
+	}
+	if got := model.Status.Rollout; got.CanaryRequests != 1 || got.CanaryErrors != 1 || got.StableRequests != 0 {
+		t.Errorf("Expected the failed canary request to be counted, got %+v", got)
+	}
+
+	// A rolled back canary serves no more requests
+	model.Status.Rollout.Phase = llmcloudv1alpha1.RolloutPhaseRolledBack
+	if err := c.Status().Update(context.Background(), model); err != nil {
+		t.Fatal(err)
+	}
+	if w := infer(); w.Code != http.StatusOK || w.Body.String() != "llama" {
+		t.Errorf("Expected the model itself to serve the request, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleProjectUsageUnknownProject(t *testing.T) {
+	s := &Server{client: setupTestClient()}
+	w := httptest.NewRecorder()
+	s.handleProjectUsage(w, withClaims(httptest.NewRequest("GET", "/api/v1/usage/projects/missing", nil),
+		&auth.Claims{Username: "admin", IsAdmin: true}))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status NotFound, got %d", w.Code)
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const (
+	// watchBuffer is the number of events a watch holds for a slow client before the
+	// stream is closed; the client then reconnects and receives the current state again
+	watchBuffer = 256
+	// watchKeepalive is how often an idle watch sends a comment, so proxies keep the
+	// connection open
+	watchKeepalive = 30 * time.Second
+)
+
+// watchedResources are the resources /api/v1/watch streams, by the name their list
+// endpoints use
+var watchedResources = map[string]func() client.Object{
+	"vms":      func() client.Object { return &llmcloudv1alpha1.VirtualMachine{} },
+	"models":   func() client.Object { return &llmcloudv1alpha1.LLMModel{} },
+	"services": func() client.Object { return &llmcloudv1alpha1.Service{} },
+}
+
+// watchEvent is a change to a watched object, shaped like a Kubernetes watch event.
+// Type is ADDED, MODIFIED or DELETED
+type watchEvent struct {
+	Type   string        `json:"type"`
+	Object client.Object `json:"object"`
+}
+
+// handleWatch handles GET /api/v1/watch/namespaces/{namespace}/{resource}, streaming
+// the changes to the VMs, models or services in a namespace as Server-Sent Events. The
+// stream starts with an ADDED event for every existing object
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	namespace, resource := r.PathValue("namespace"), r.PathValue("resource")
+	newObject, ok := watchedResources[resource]
+	if !ok {
+		writeProblem(w, "Unknown resource", http.StatusNotFound)
+		return
+	}
+	if s.Informers == nil {
+		writeProblem(w, "Watches are not available", http.StatusServiceUnavailable)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeProblem(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	ctx := r.Context()
+	informer, err := s.Informers.GetInformer(ctx, newObject())
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+
+	events := make(chan watchEvent, watchBuffer)
+	overflow := make(chan struct{})
+	var overflowOnce sync.Once
+	send := func(eventType string, obj interface{}) {
+		if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, ok := obj.(client.Object)
+		if !ok || object.GetNamespace() != namespace {
+			return
+		}
+		select {
+		case events <- watchEvent{Type: eventType, Object: object}:
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	}
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { send("ADDED", obj) },
+		UpdateFunc: func(_, obj interface{}) { send("MODIFIED", obj) },
+		DeleteFunc: func(obj interface{}) { send("DELETED", obj) },
+	})
+	if err != nil {
+		writeError(w, "", err)
+		return
+	}
+	defer func() {
+		if err := informer.RemoveEventHandler(registration); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to remove watch event handler")
+		}
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, ": watching %s in %s\n\n", resource, namespace)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(watchKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopping:
+			return
+		case <-overflow:
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case event := <-events:
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+func TestHandleWatch(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	informers := &informertest.FakeInformers{Scheme: scheme}
+	s := &Server{client: setupTestClient(), Informers: informers}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/watch/namespaces/{namespace}/{resource}", s.handleWatch)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/v1/watch/namespaces/project-team/vms")
+	if err != nil {
+		t.Fatalf("Failed to start watch: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got Content-Type %q", ct)
+	}
+	lines := bufio.NewReader(resp.Body)
+	// The opening comment is sent once the handler is registered
+	if line, err := lines.ReadString('\n'); err != nil || !strings.HasPrefix(line, ":") {
+		t.Fatalf("Expected the opening comment, got %q (%v)", line, err)
+	}
+
+	informer, err := informers.FakeInformerFor(context.Background(), &llmcloudv1alpha1.VirtualMachine{})
+	if err != nil {
+		t.Fatalf("Failed to get informer: %v", err)
+	}
+	web := &llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"}}
+	informer.Add(&llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "project-other"}})
+	informer.Add(web)
+	informer.Delete(web)
+
+	for _, want := range []string{"ADDED", "DELETED"} {
+		var line string
+		for line == "" || line == "\n" {
+			if line, err = lines.ReadString('\n'); err != nil {
+				t.Fatalf("Failed to read event: %v", err)
+			}
+		}
+		var event struct {
+			Type   string                          `json:"type"`
+			Object llmcloudv1alpha1.VirtualMachine `json:"object"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event); err != nil {
+			t.Fatalf("Failed to decode event %q: %v", line, err)
+		}
+		if event.Type != want || event.Object.Name != "web" {
+			t.Errorf("Expected %s of web, got %s of %s", want, event.Type, event.Object.Name)
+		}
+	}
+}
+
+func TestHandleWatchRejects(t *testing.T) {
+	if err := auth.InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	s := &Server{client: setupTestClient()}
+	token, err := auth.GenerateJWT(&llmcloudv1alpha1.User{
+		Spec: llmcloudv1alpha1.UserSpec{Username: "alice", Projects: []string{"team"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want int
+	}{
+		{name: "another project", path: "/api/v1/watch/namespaces/project-other/vms?token=" + token, want: http.StatusForbidden},
+		{name: "unknown resource", path: "/api/v1/watch/namespaces/project-team/secrets?token=" + token, want: http.StatusNotFound},
+		{name: "without informers", path: "/api/v1/watch/namespaces/project-team/models?token=" + token, want: http.StatusServiceUnavailable},
+		{name: "without a token", path: "/api/v1/watch/namespaces/project-team/models", want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.handleAPI(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.want {
+				t.Errorf("Expected status %d, got %d: %s", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// OperatorUser is the user actions of the operator itself are recorded as
+const OperatorUser = "system:llmcloud-operator"
+
+// pruneInterval is how often events older than the retention period are deleted
+const pruneInterval = time.Hour
+
+var log = logf.Log.WithName("audit")
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=auditevents,verbs=get;list;watch;create;delete
+
+// Recorder records audit events as AuditEvent resources, and writes each one to the
+// log as well so the trail survives even when an event cannot be stored
+type Recorder struct {
+	Client client.Client
+
+	// Retention is how long events are kept; zero keeps them forever
+	Retention time.Duration
+}
+
+// Record stores event, stamping it with the current time unless it has one. Failing to
+// store it is logged rather than returned: auditing never fails the action itself
+func (r *Recorder) Record(ctx context.Context, event llmcloudv1alpha1.AuditEventSpec) {
+	if event.Time.IsZero() {
+		event.Time = metav1.Now()
+	}
+	log.Info("audit", "user", event.User, "sourceIP", event.SourceIP, "action", event.Action,
+		"resource", event.Resource, "namespace", event.Namespace, "name", event.Name,
+		"path", event.Path, "statusCode", event.StatusCode)
+
+	if err := r.Client.Create(ctx, &llmcloudv1alpha1.AuditEvent{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "audit-"},
+		Spec:       event,
+	}); err != nil {
+		log.Error(err, "failed to store audit event", "user", event.User, "action", event.Action)
+	}
+}
+
+// Start deletes events older than Retention every pruneInterval until ctx is done
+func (r *Recorder) Start(ctx context.Context) error {
+	if r.Retention <= 0 {
+		return nil
+	}
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for {
+		if err := r.prune(ctx); err != nil {
+			log.Error(err, "failed to prune audit events")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune deletes the events older than Retention
+func (r *Recorder) prune(ctx context.Context) error {
+	events := &llmcloudv1alpha1.AuditEventList{}
+	if err := r.Client.List(ctx, events); err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-r.Retention)
+	for i := range events.Items {
+		if events.Items[i].Spec.Time.Time.Before(cutoff) {
+			if err := r.Client.Delete(ctx, &events.Items[i]); client.IgnoreNotFound(err) != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
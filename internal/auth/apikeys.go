@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to recognize
+const apiKeyPrefix = "llmc_"
+
+// ErrInvalidAPIKey is returned by AuthenticateAPIKey for a key no user holds, or
+// one that has expired
+var ErrInvalidAPIKey = errors.New("invalid or expired API key")
+
+// GenerateAPIKey returns a new random API key and its hash
+func GenerateAPIKey() (string, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey returns the hash of key that the User stores. Keys are random, unlike
+// passwords, so a fast hash is enough to keep them from being read back
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuthenticateAPIKey returns the enabled user holding key and the key itself, unless it
+// has expired
+func AuthenticateAPIKey(ctx context.Context, k8sClient client.Client, key string) (*llmcloudv1alpha1.User, *llmcloudv1alpha1.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, nil, ErrInvalidAPIKey
+	}
+	hash := []byte(HashAPIKey(key))
+
+	userList := &llmcloudv1alpha1.UserList{}
+	if err := k8sClient.List(ctx, userList); err != nil {
+		return nil, nil, err
+	}
+	for i := range userList.Items {
+		user := &userList.Items[i]
+		for j := range user.Spec.APIKeys {
+			apiKey := &user.Spec.APIKeys[j]
+			if subtle.ConstantTimeCompare(hash, []byte(apiKey.Hash)) != 1 {
+				continue
+			}
+			if user.Spec.Disabled {
+				return nil, nil, fmt.Errorf("user account is disabled")
+			}
+			if apiKey.Expired(time.Now()) {
+				return nil, nil, ErrInvalidAPIKey
+			}
+			return user, apiKey, nil
+		}
+	}
+	return nil, nil, ErrInvalidAPIKey
+}
+
+// APIKeyClaims returns the claims of a request made with key of user, which only allow
+// GET requests unless the key has the write scope
+func APIKeyClaims(user *llmcloudv1alpha1.User, key *llmcloudv1alpha1.APIKey) *Claims {
+	return &Claims{
+		Username: user.Spec.Username,
+		IsAdmin:  user.Spec.IsAdmin,
+		Projects: user.Spec.Projects,
+		ReadOnly: key.ReadOnly(),
+		APIKey:   key.Name,
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	readKey, readHash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(readKey, apiKeyPrefix) || len(readHash) != 64 {
+		t.Fatalf("Unexpected key %q with hash %q", readKey, readHash)
+	}
+	writeKey, writeHash, _ := GenerateAPIKey()
+	expiredKey, expiredHash, _ := GenerateAPIKey()
+	disabledKey, disabledHash, _ := GenerateAPIKey()
+	past := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&llmcloudv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+			Spec: llmcloudv1alpha1.UserSpec{Username: "alice", Projects: []string{"team"}, APIKeys: []llmcloudv1alpha1.APIKey{
+				{Name: "dashboard", Hash: readHash, Scopes: []string{"read"}},
+				{Name: "ci", Hash: writeHash, Scopes: []string{"read", "write"}},
+				{Name: "old", Hash: expiredHash, Scopes: []string{"write"}, ExpiresAt: &past},
+			}},
+		},
+		&llmcloudv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "bob"},
+			Spec: llmcloudv1alpha1.UserSpec{Username: "bob", Disabled: true, APIKeys: []llmcloudv1alpha1.APIKey{
+				{Name: "ci", Hash: disabledHash, Scopes: []string{"write"}},
+			}},
+		},
+	).Build()
+	ctx := context.Background()
+
+	user, key, err := AuthenticateAPIKey(ctx, c, readKey)
+	if err != nil || user.Spec.Username != "alice" || key.Name != "dashboard" {
+		t.Fatalf("Expected alice's dashboard key, got %v, %v, %v", user, key, err)
+	}
+	if claims := APIKeyClaims(user, key); !claims.ReadOnly || claims.APIKey != "dashboard" || claims.Projects[0] != "team" {
+		t.Errorf("Expected read-only claims for the key, got %+v", claims)
+	}
+	user, key, err = AuthenticateAPIKey(ctx, c, writeKey)
+	if err != nil || APIKeyClaims(user, key).ReadOnly {
+		t.Errorf("Expected the write key to allow writes, got %v", err)
+	}
+
+	for name, key := range map[string]string{
+		"expired":  expiredKey,
+		"disabled": disabledKey,
+		"unknown":  apiKeyPrefix + "unknown",
+		"token":    "eyJhbGciOiJIUzI1NiJ9",
+	} {
+		if _, _, err := AuthenticateAPIKey(ctx, c, key); err == nil {
+			t.Errorf("Expected the %s key to be rejected", name)
+		}
+	}
+}
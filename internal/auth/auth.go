@@ -4,6 +4,8 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
@@ -14,13 +16,27 @@ import (
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
 )
 
-var jwtSecret []byte
+// TokenTTL is how long a login or refreshed token is valid. It can be set at startup
+// with the --token-ttl flag, and is overridden by the tokenTTL of the LLMCloudConfig.
+var TokenTTL = 24 * time.Hour
 
-// InitJWTSecret initializes the JWT secret (should be called once at startup)
+// tokenTTL returns the tokenTTL of the LLMCloudConfig in effect, or TokenTTL
+func tokenTTL() time.Duration {
+	if ttl := llmcloudv1alpha1.RuntimeConfig().TokenTTL; ttl != nil {
+		return ttl.Duration
+	}
+	return TokenTTL
+}
+
+// InitJWTSecret initializes an in-memory JWT secret. Tokens signed with it do not
+// survive a restart; SigningKeyStore persists the keys instead
 func InitJWTSecret() error {
-	jwtSecret = make([]byte, 32)
-	_, err := rand.Read(jwtSecret)
-	return err
+	id, key, err := newSigningKey()
+	if err != nil {
+		return err
+	}
+	setSigningKeys(&signingKeys{currentID: id, keys: map[string][]byte{id: key}})
+	return nil
 }
 
 // Claims represents the JWT claims
@@ -28,6 +44,13 @@ type Claims struct {
 	Username string   `json:"username"`
 	IsAdmin  bool     `json:"isAdmin"`
 	Projects []string `json:"projects"`
+	// ReadOnly tokens may only be used for GET requests, whatever the user's role
+	ReadOnly bool `json:"readonly,omitempty"`
+	// Provider is the identity provider the user logged in through, e.g. ProviderOIDC.
+	// It is empty for local users
+	Provider string `json:"provider,omitempty"`
+	// APIKey names the API key the request was made with; it is empty for tokens
+	APIKey string `json:"apiKey,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -54,48 +77,82 @@ func GeneratePassword(length int) (string, error) {
 
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(user *llmcloudv1alpha1.User) (string, error) {
-	if jwtSecret == nil {
-		return "", fmt.Errorf("JWT secret not initialized")
-	}
+	return signJWT(Claims{
+		Username: user.Spec.Username,
+		IsAdmin:  user.Spec.IsAdmin,
+		Projects: user.Spec.Projects,
+	}, tokenTTL())
+}
 
-	claims := Claims{
+// GenerateReadOnlyJWT generates a read-only token for a user, for dashboards that should
+// never be able to change anything
+func GenerateReadOnlyJWT(user *llmcloudv1alpha1.User, ttl time.Duration) (string, error) {
+	return signJWT(Claims{
 		Username: user.Spec.Username,
 		IsAdmin:  user.Spec.IsAdmin,
 		Projects: user.Spec.Projects,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+		ReadOnly: true,
+	}, ttl)
+}
+
+// signJWT signs claims that expire after ttl with the current signing key, with a
+// random ID by which the token can be revoked
+func signJWT(claims Claims, ttl time.Duration) (string, error) {
+	keyID, key, err := currentSigningKey()
+	if err != nil {
+		return "", err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ID:        hex.EncodeToString(id),
+		ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(now),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token.Header["kid"] = keyID
+	return token.SignedString(key)
 }
 
-// ValidateJWT validates a JWT token and returns the claims
+// ValidateJWT validates a JWT token and returns the claims. Tokens signed with a retired
+// key past its grace period, and revoked tokens, are rejected
 func ValidateJWT(tokenString string) (*Claims, error) {
-	if jwtSecret == nil {
-		return nil, fmt.Errorf("JWT secret not initialized")
-	}
-
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return jwtSecret, nil
+		keyID, _ := token.Header["kid"].(string)
+		return signingKey(keyID)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid token")
 	}
-
-	return nil, fmt.Errorf("invalid token")
+	if revocations != nil && claims.ID != "" {
+		revoked, err := revocations.IsRevoked(context.Background(), claims.ID)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, fmt.Errorf("token has been revoked")
+		}
+	}
+	return claims, nil
 }
 
+// ErrAccountLocked is returned by AuthenticateUser for a user whose account is locked
+// after too many failed logins, without checking the password
+var ErrAccountLocked = errors.New("user account is locked")
+
 // AuthenticateUser authenticates a user by username and password
 func AuthenticateUser(ctx context.Context, k8sClient client.Client, username, password string) (*llmcloudv1alpha1.User, error) {
 	// List all users (they are cluster-scoped)
@@ -111,6 +168,9 @@ func AuthenticateUser(ctx context.Context, k8sClient client.Client, username, pa
 			if user.Spec.Disabled {
 				return nil, fmt.Errorf("user account is disabled")
 			}
+			if locked := user.Status.LockedUntil; locked != nil && time.Now().Before(locked.Time) {
+				return nil, ErrAccountLocked
+			}
 			if CheckPasswordHash(password, user.Spec.PasswordHash) {
 				return user, nil
 			}
@@ -121,6 +181,33 @@ func AuthenticateUser(ctx context.Context, k8sClient client.Client, username, pa
 	return nil, fmt.Errorf("user not found")
 }
 
+// PasswordChangeRequired reports whether user must change the password before logging
+// in, because an admin asked for it or it is older than the MaxAge of the password
+// policy in effect
+func PasswordChangeRequired(user *llmcloudv1alpha1.User, now time.Time) bool {
+	return user.Spec.MustChangePassword ||
+		llmcloudv1alpha1.RuntimeConfig().PasswordPolicy.Expired(user.PasswordSetAt(), now)
+}
+
+// LookupUser returns the enabled user with username
+func LookupUser(ctx context.Context, k8sClient client.Client, username string) (*llmcloudv1alpha1.User, error) {
+	userList := &llmcloudv1alpha1.UserList{}
+	if err := k8sClient.List(ctx, userList); err != nil {
+		return nil, err
+	}
+	for i := range userList.Items {
+		user := &userList.Items[i]
+		if user.Spec.Username != username {
+			continue
+		}
+		if user.Spec.Disabled {
+			return nil, fmt.Errorf("user account is disabled")
+		}
+		return user, nil
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
 // HasProjectAccess checks if a user has access to a project
 func HasProjectAccess(claims *Claims, projectName string) bool {
 	if claims.IsAdmin {
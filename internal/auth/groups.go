@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=groups;projects,verbs=list
+
+// AddGroupProjects adds to user.Spec.Projects every project that has a group:<name>
+// member for a Group the user is in, so that the user's token grants access to them.
+// The user is only changed in memory
+func AddGroupProjects(ctx context.Context, k8sClient client.Client, user *llmcloudv1alpha1.User) error {
+	groups := &llmcloudv1alpha1.GroupList{}
+	if err := k8sClient.List(ctx, groups); err != nil {
+		return err
+	}
+	var names []string
+	for i := range groups.Items {
+		if groups.Items[i].HasMember(user.Spec.Username) {
+			names = append(names, groups.Items[i].Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	projects := &llmcloudv1alpha1.ProjectList{}
+	if err := k8sClient.List(ctx, projects); err != nil {
+		return err
+	}
+	for _, project := range projects.Items {
+		for _, member := range project.Spec.Members {
+			if slices.Contains(names, member.Group()) && !slices.Contains(user.Spec.Projects, project.Name) {
+				user.Spec.Projects = append(user.Spec.Projects, project.Name)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestAddGroupProjects(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	project := func(name string, members ...string) *llmcloudv1alpha1.Project {
+		p := &llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		for _, member := range members {
+			p.Spec.Members = append(p.Spec.Members, llmcloudv1alpha1.ProjectMember{Username: member, Role: "viewer"})
+		}
+		return p
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&llmcloudv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "ml-team"},
+			Spec:       llmcloudv1alpha1.GroupSpec{Members: []string{"alice", "bob"}},
+		},
+		&llmcloudv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "ops"},
+			Spec:       llmcloudv1alpha1.GroupSpec{Members: []string{"carol"}},
+		},
+		project("ml", "group:ml-team"),
+		project("team", "alice", "group:ml-team"),
+		project("infra", "group:ops", "ml-team"),
+	).Build()
+
+	user := &llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "alice", Projects: []string{"team"}}}
+	if err := AddGroupProjects(context.Background(), c, user); err != nil {
+		t.Fatalf("AddGroupProjects failed: %v", err)
+	}
+	slices.Sort(user.Spec.Projects)
+	if !slices.Equal(user.Spec.Projects, []string{"ml", "team"}) {
+		t.Errorf("Expected alice to gain the ml-team projects once, got %v", user.Spec.Projects)
+	}
+
+	user = &llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "dave"}}
+	if err := AddGroupProjects(context.Background(), c, user); err != nil || len(user.Spec.Projects) != 0 {
+		t.Errorf("Expected no projects for a user in no group, got %v (%v)", user.Spec.Projects, err)
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const (
+	// ProviderOIDC marks tokens issued for a login through the OpenID Connect provider
+	ProviderOIDC = "oidc"
+	// OIDCUsernamePrefix namespaces the usernames of OIDC users, so that an identity at
+	// the provider never matches a local user or a reserved name
+	OIDCUsernamePrefix = "oidc:"
+	// maxOIDCUsernameLength bounds the username claim of an ID token
+	maxOIDCUsernameLength = 253
+)
+
+// OIDCConfig configures login through an external OpenID Connect provider
+type OIDCConfig struct {
+	IssuerURL    string `json:"issuerURL,omitempty"`
+	ClientID     string `json:"clientID,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// RedirectURL is the API server's /api/v1/auth/oidc/callback, as registered with
+	// the provider
+	RedirectURL string `json:"redirectURL,omitempty"`
+
+	// UsernameClaim names the only ID token claim used as the username, which is
+	// prefixed with OIDCUsernamePrefix. Defaults to preferred_username, falling back to
+	// email and then sub. An email is only used when email_verified is true
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// GroupsClaim names the ID token claim listing the user's groups. Defaults to groups
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// AdminGroups are the groups whose members are admins
+	AdminGroups []string `json:"adminGroups,omitempty"`
+	// GroupProjects maps groups to the projects their members may access
+	GroupProjects map[string][]string `json:"groupProjects,omitempty"`
+}
+
+// Enabled reports whether an issuer and client are configured
+func (c *OIDCConfig) Enabled() bool {
+	return c.IssuerURL != "" && c.ClientID != ""
+}
+
+// ParseOIDCGroupProjects parses a comma-separated list of group=project pairs (e.g.,
+// "devs=team,devs=ml,ops=infra") for OIDCConfig.GroupProjects. A group may appear
+// more than once to grant several projects
+func ParseOIDCGroupProjects(value string) (map[string][]string, error) {
+	groupProjects := map[string][]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		group, project, ok := strings.Cut(pair, "=")
+		if !ok || group == "" || project == "" {
+			return nil, fmt.Errorf("invalid group mapping %q: expected group=project", pair)
+		}
+		groupProjects[group] = append(groupProjects[group], project)
+	}
+	return groupProjects, nil
+}
+
+// LoadOIDCConfigMap fills the fields config leaves empty from the ConfigMap namespace/name.
+// Each field is a key named like its JSON field; adminGroups is a comma-separated list
+// and groupProjects a YAML map of groups to lists of projects. A missing ConfigMap
+// leaves config unchanged
+func LoadOIDCConfigMap(ctx context.Context, c client.Reader, namespace, name string, config *OIDCConfig) error {
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, cm); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	for key, field := range map[string]*string{
+		"issuerURL":     &config.IssuerURL,
+		"clientID":      &config.ClientID,
+		"clientSecret":  &config.ClientSecret,
+		"redirectURL":   &config.RedirectURL,
+		"usernameClaim": &config.UsernameClaim,
+		"groupsClaim":   &config.GroupsClaim,
+	} {
+		*field = cmp.Or(*field, strings.TrimSpace(cm.Data[key]))
+	}
+	if groups := cm.Data["adminGroups"]; len(config.AdminGroups) == 0 && groups != "" {
+		for _, group := range strings.Split(groups, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				config.AdminGroups = append(config.AdminGroups, group)
+			}
+		}
+	}
+	if mapping := cm.Data["groupProjects"]; len(config.GroupProjects) == 0 && mapping != "" {
+		if err := yaml.Unmarshal([]byte(mapping), &config.GroupProjects); err != nil {
+			return fmt.Errorf("invalid groupProjects in ConfigMap %s/%s: %w", namespace, name, err)
+		}
+	}
+	return nil
+}
+
+// OIDCProvider logs users in through an OpenID Connect provider with the authorization
+// code flow, mapping their groups to projects
+type OIDCProvider struct {
+	config   OIDCConfig
+	oauth2   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider discovers the provider at config.IssuerURL
+func NewOIDCProvider(ctx context.Context, config OIDCConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider %s: %w", config.IssuerURL, err)
+	}
+	return &OIDCProvider{
+		config: config,
+		oauth2: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       []string{oidc.ScopeOpenID, "profile", "email", "groups"},
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+	}, nil
+}
+
+// AuthCodeURL returns the provider's login page, which redirects back with a code for
+// Exchange. state and nonce must be checked when it does
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	return p.oauth2.AuthCodeURL(state, oidc.Nonce(nonce))
+}
+
+// Exchange redeems an authorization code and verifies the ID token issued for it,
+// returning the user it identifies. The user is not stored; it only carries the
+// identity GenerateOIDCJWT puts in the token
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*llmcloudv1alpha1.User, error) {
+	token, err := p.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("provider returned no ID token")
+	}
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, fmt.Errorf("ID token nonce does not match")
+	}
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	return p.userFor(claims)
+}
+
+// userFor maps the claims of an ID token to a user, granting admin and projects by group
+func (p *OIDCProvider) userFor(claims map[string]interface{}) (*llmcloudv1alpha1.User, error) {
+	// A configured claim is the only one used, so that a token missing it cannot pass
+	// for another user through the fallbacks
+	candidates := []string{"preferred_username", "email", "sub"}
+	if p.config.UsernameClaim != "" {
+		candidates = []string{p.config.UsernameClaim}
+	}
+	var username string
+	for _, claim := range candidates {
+		value, _ := claims[claim].(string)
+		// Unverified emails may be set to anything at some providers
+		if claim == "email" && !emailVerified(claims) {
+			continue
+		}
+		if value != "" {
+			username = value
+			break
+		}
+	}
+	if username == "" {
+		return nil, fmt.Errorf("ID token has no username")
+	}
+	if err := validateOIDCUsername(username); err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	switch value := claims[cmp.Or(p.config.GroupsClaim, "groups")].(type) {
+	case []interface{}:
+		for _, group := range value {
+			if group, ok := group.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	case string:
+		groups = []string{value}
+	}
+
+	user := &llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: OIDCUsernamePrefix + username}}
+	for _, group := range groups {
+		user.Spec.IsAdmin = user.Spec.IsAdmin || slices.Contains(p.config.AdminGroups, group)
+		for _, project := range p.config.GroupProjects[group] {
+			if !slices.Contains(user.Spec.Projects, project) {
+				user.Spec.Projects = append(user.Spec.Projects, project)
+			}
+		}
+	}
+	return user, nil
+}
+
+// emailVerified reports whether the email_verified claim of an ID token is true. Some
+// providers send it as a string
+func emailVerified(claims map[string]interface{}) bool {
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		return verified
+	case string:
+		return verified == "true"
+	}
+	return false
+}
+
+// validateOIDCUsername checks that the username claim of an ID token is printable,
+// without spaces, and of a bounded length
+func validateOIDCUsername(username string) error {
+	if len(username) > maxOIDCUsernameLength {
+		return fmt.Errorf("ID token username is longer than %d characters", maxOIDCUsernameLength)
+	}
+	if strings.ContainsFunc(username, func(r rune) bool { return !unicode.IsPrint(r) || unicode.IsSpace(r) }) {
+		return fmt.Errorf("ID token username %q contains spaces or unprintable characters", username)
+	}
+	return nil
+}
+
+// GenerateOIDCJWT generates a token for a user who logged in through the OIDC provider.
+// It carries the same claims as a local user's, marked with ProviderOIDC
+func GenerateOIDCJWT(user *llmcloudv1alpha1.User) (string, error) {
+	return signJWT(Claims{
+		Username: user.Spec.Username,
+		IsAdmin:  user.Spec.IsAdmin,
+		Projects: user.Spec.Projects,
+		Provider: ProviderOIDC,
+	}, tokenTTL())
+}
@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// newTestOIDCServer serves discovery, keys and a token endpoint issuing an ID token
+// with claims, signed by key, for any code
+func newTestOIDCServer(t *testing.T, claims jwt.MapClaims) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                srv.URL + "/auth",
+			"token_endpoint":                        srv.URL + "/token",
+			"jwks_uri":                              srv.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "test", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, _ *http.Request) {
+		idClaims := jwt.MapClaims{"iss": srv.URL, "aud": "llmcloud", "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range claims {
+			idClaims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, idClaims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			t.Errorf("Failed to sign ID token: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access", "token_type": "Bearer", "id_token": idToken,
+		})
+	})
+	return srv
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	srv := newTestOIDCServer(t, jwt.MapClaims{
+		"sub": "1234", "preferred_username": "alice", "nonce": "n0nce",
+		"groups": []string{"devs", "ops", "admins"},
+	})
+	defer srv.Close()
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		IssuerURL:     srv.URL,
+		ClientID:      "llmcloud",
+		AdminGroups:   []string{"admins"},
+		GroupProjects: map[string][]string{"devs": {"team", "ml"}, "ops": {"team"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to discover provider: %v", err)
+	}
+
+	user, err := provider.Exchange(context.Background(), "code", "n0nce")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+	if user.Spec.Username != "oidc:alice" || !user.Spec.IsAdmin || !reflect.DeepEqual(user.Spec.Projects, []string{"team", "ml"}) {
+		t.Errorf("Expected admin oidc:alice with projects team and ml, got %+v", user.Spec)
+	}
+
+	if _, err := provider.Exchange(context.Background(), "code", "other"); err == nil {
+		t.Error("Expected an ID token with another nonce to be rejected")
+	}
+}
+
+func TestOIDCUsernames(t *testing.T) {
+	provider := &OIDCProvider{}
+	user, err := provider.userFor(map[string]interface{}{"preferred_username": "admin", "sub": "1234"})
+	if err != nil {
+		t.Fatalf("Failed to map claims: %v", err)
+	}
+	if user.Spec.Username != "oidc:admin" || user.Spec.IsAdmin {
+		t.Errorf("Expected an IdP user named admin to stay apart from the local admin, got %+v", user.Spec)
+	}
+	for _, username := range []string{"alice smith", "bob\x00", strings.Repeat("a", maxOIDCUsernameLength+1)} {
+		if _, err := provider.userFor(map[string]interface{}{"preferred_username": username}); err == nil {
+			t.Errorf("Expected username %q to be rejected", username)
+		}
+	}
+
+	user, err = provider.userFor(map[string]interface{}{"email": "alice@example.com", "sub": "1234"})
+	if err != nil || user.Spec.Username != "oidc:1234" {
+		t.Errorf("Expected an unverified email to be skipped for sub, got %+v, %v", user, err)
+	}
+	user, err = provider.userFor(map[string]interface{}{"email": "alice@example.com", "email_verified": "true"})
+	if err != nil || user.Spec.Username != "oidc:alice@example.com" {
+		t.Errorf("Expected a verified email to be used, got %+v, %v", user, err)
+	}
+
+	provider = &OIDCProvider{config: OIDCConfig{UsernameClaim: "upn"}}
+	if _, err := provider.userFor(map[string]interface{}{"preferred_username": "alice", "sub": "1234"}); err == nil {
+		t.Error("Expected a token without the configured claim to be rejected")
+	}
+	provider = &OIDCProvider{config: OIDCConfig{UsernameClaim: "email"}}
+	if _, err := provider.userFor(map[string]interface{}{"email": "alice@example.com", "email_verified": false}); err == nil {
+		t.Error("Expected an unverified email to be rejected as the configured claim")
+	}
+}
+
+func TestGenerateOIDCJWT(t *testing.T) {
+	if err := InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	srv := newTestOIDCServer(t, jwt.MapClaims{"sub": "1234", "email": "bob@example.com", "email_verified": true, "nonce": "n"})
+	defer srv.Close()
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{IssuerURL: srv.URL, ClientID: "llmcloud"})
+	if err != nil {
+		t.Fatalf("Failed to discover provider: %v", err)
+	}
+	user, err := provider.Exchange(context.Background(), "code", "n")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+	token, err := GenerateOIDCJWT(user)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	claims, err := ValidateJWT(token)
+	if err != nil {
+		t.Fatalf("Expected the token to validate like a local one: %v", err)
+	}
+	if claims.Username != "oidc:bob@example.com" || claims.Provider != ProviderOIDC || claims.IsAdmin || len(claims.Projects) != 0 {
+		t.Errorf("Expected a plain OIDC user falling back to the email claim, got %+v", claims)
+	}
+}
+
+func TestLoadOIDCConfigMap(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kube-system", Name: "llmcloud-oidc"},
+		Data: map[string]string{
+			"issuerURL":     "https://idp.example.com",
+			"clientID":      "from-configmap",
+			"adminGroups":   "admins, ops",
+			"groupProjects": "devs: [team, ml]\nops: [infra]\n",
+		},
+	}).Build()
+
+	config := OIDCConfig{ClientID: "from-flag"}
+	if err := LoadOIDCConfigMap(context.Background(), c, "kube-system", "llmcloud-oidc", &config); err != nil {
+		t.Fatalf("Failed to load ConfigMap: %v", err)
+	}
+	want := OIDCConfig{
+		IssuerURL:     "https://idp.example.com",
+		ClientID:      "from-flag",
+		AdminGroups:   []string{"admins", "ops"},
+		GroupProjects: map[string][]string{"devs": {"team", "ml"}, "ops": {"infra"}},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("Expected flags to win over the ConfigMap, got %+v", config)
+	}
+
+	missing := OIDCConfig{}
+	if err := LoadOIDCConfigMap(context.Background(), c, "kube-system", "absent", &missing); err != nil || missing.Enabled() {
+		t.Errorf("Expected a missing ConfigMap to leave OIDC disabled, got %+v (%v)", missing, err)
+	}
+}
+
+func TestParseOIDCGroupProjects(t *testing.T) {
+	got, err := ParseOIDCGroupProjects("devs=team, devs=ml,ops=infra")
+	if err != nil {
+		t.Fatalf("Failed to parse: %v", err)
+	}
+	if want := map[string][]string{"devs": {"team", "ml"}, "ops": {"infra"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if _, err := ParseOIDCGroupProjects("devs"); err == nil {
+		t.Error("Expected a pair without a project to be rejected")
+	}
+}
@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// revocationSyncInterval is how long a replica trusts its copy of the revocation list
+// before reading the ConfigMap again, so revocations made by other replicas apply
+// within this interval
+const revocationSyncInterval = 30 * time.Second
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;patch
+
+// RevocationList records the IDs of revoked tokens until they expire. It is persisted in
+// a ConfigMap, mapping token IDs to their expiry, so revocations survive restarts and
+// apply to every replica
+type RevocationList struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+
+	mu      sync.Mutex
+	revoked map[string]time.Time
+	synced  time.Time
+}
+
+// revocations is the list ValidateJWT checks; tokens cannot be revoked when it is nil
+var revocations *RevocationList
+
+// SetRevocationList makes ValidateJWT reject tokens revoked in l
+func SetRevocationList(l *RevocationList) {
+	revocations = l
+}
+
+// Revoke rejects the token carrying claims from now until it expires. It does nothing
+// when no revocation list is configured or the token has no ID
+func Revoke(ctx context.Context, claims *Claims) error {
+	if revocations == nil || claims.ID == "" {
+		return nil
+	}
+	expiresAt := time.Now().Add(tokenTTL())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return revocations.Revoke(ctx, claims.ID, expiresAt)
+}
+
+// Revoke adds the token id, which expires at expiresAt, to the list. Expired entries are
+// dropped at the same time
+func (l *RevocationList) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm := &corev1.ConfigMap{}
+		err := l.Client.Get(ctx, client.ObjectKey{Namespace: l.Namespace, Name: l.Name}, cm)
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: l.Namespace, Name: l.Name},
+				Data:       map[string]string{id: expiresAt.UTC().Format(time.RFC3339)},
+			}
+			return l.Client.Create(ctx, cm)
+		}
+		if err != nil {
+			return err
+		}
+
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		now := time.Now()
+		for key, value := range cm.Data {
+			if expiry, err := time.Parse(time.RFC3339, value); err != nil || expiry.Before(now) {
+				delete(cm.Data, key)
+			}
+		}
+		cm.Data[id] = expiresAt.UTC().Format(time.RFC3339)
+		return l.Client.Update(ctx, cm)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.revoked == nil {
+		l.revoked = map[string]time.Time{}
+	}
+	l.revoked[id] = expiresAt
+	return nil
+}
+
+// IsRevoked reports whether the token id has been revoked and has not expired yet
+func (l *RevocationList) IsRevoked(ctx context.Context, id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.synced) > revocationSyncInterval {
+		cm := &corev1.ConfigMap{}
+		err := l.Client.Get(ctx, client.ObjectKey{Namespace: l.Namespace, Name: l.Name}, cm)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, fmt.Errorf("failed to read revoked tokens: %w", err)
+		}
+		l.revoked = map[string]time.Time{}
+		for key, value := range cm.Data {
+			if expiry, err := time.Parse(time.RFC3339, value); err == nil {
+				l.revoked[key] = expiry
+			}
+		}
+		l.synced = time.Now()
+	}
+
+	expiry, ok := l.revoked[id]
+	return ok && time.Now().Before(expiry), nil
+}
@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// signingKeySyncInterval is how often SigningKeyStore reloads the keys, so keys rotated
+// by another replica are picked up within this interval
+const signingKeySyncInterval = time.Minute
+
+// signingKeyReloadInterval is how often a token with an unknown key ID may reload the
+// keys at most, so that forged key IDs cannot flood the API server with reads
+const signingKeyReloadInterval = time.Second
+
+// signingKeyReloadTimeout bounds the reload of the keys for a token being validated
+const signingKeyReloadTimeout = 5 * time.Second
+
+// Fields of the signing key Secret. Each key is stored under key.<id>; a retired key
+// also has expires.<id>, the time until which it still validates tokens
+const (
+	currentKeyField    = "current"
+	rotatedAtField     = "rotatedAt"
+	keyFieldPrefix     = "key."
+	expiresFieldPrefix = "expires."
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;update
+
+// signingKeys are the keys tokens are signed and validated with
+type signingKeys struct {
+	currentID string
+	rotatedAt time.Time
+	// keys are the current and retired keys by ID
+	keys map[string][]byte
+	// expires holds when each retired key stops validating tokens
+	expires map[string]time.Time
+	// store is where the keys were loaded from, or nil for an in-memory key
+	store *SigningKeyStore
+}
+
+var (
+	signingKeysMu sync.RWMutex
+	activeKeys    *signingKeys
+)
+
+func setSigningKeys(keys *signingKeys) {
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+	activeKeys = keys
+}
+
+// newSigningKey returns a random key and the ID tokens signed with it carry
+func newSigningKey() (string, []byte, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+	return hex.EncodeToString(id), key, nil
+}
+
+// currentSigningKey returns the key new tokens are signed with and its ID
+func currentSigningKey() (string, []byte, error) {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+	if activeKeys == nil {
+		return "", nil, fmt.Errorf("JWT secret not initialized")
+	}
+	return activeKeys.currentID, activeKeys.keys[activeKeys.currentID], nil
+}
+
+// errUnknownSigningKey is returned by lookupSigningKey for a key ID it does not know
+var errUnknownSigningKey = errors.New("unknown signing key")
+
+// signingKey returns the key with id, unless it is unknown or retired past its grace period.
+// An unknown key may have just been rotated in by another replica, so the keys are
+// reloaded once before it is refused
+func signingKey(id string) ([]byte, error) {
+	key, err := lookupSigningKey(id)
+	if !errors.Is(err, errUnknownSigningKey) {
+		return key, err
+	}
+	signingKeysMu.RLock()
+	store := activeKeys.store
+	signingKeysMu.RUnlock()
+	if store != nil && store.reload() {
+		return lookupSigningKey(id)
+	}
+	return nil, err
+}
+
+// lookupSigningKey returns the active key with id, unless it is unknown or retired past
+// its grace period
+func lookupSigningKey(id string) ([]byte, error) {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+	if activeKeys == nil {
+		return nil, fmt.Errorf("JWT secret not initialized")
+	}
+	key, ok := activeKeys.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownSigningKey, id)
+	}
+	if expires, ok := activeKeys.expires[id]; ok && time.Now().After(expires) {
+		return nil, fmt.Errorf("signing key %q has expired", id)
+	}
+	return key, nil
+}
+
+// SigningKeyStore persists the JWT signing keys in a Secret, so sessions survive restarts
+// and every replica validates the tokens of the others. The current key is replaced every
+// RotationInterval; a replaced key keeps validating tokens for GracePeriod
+type SigningKeyStore struct {
+	Client    client.Client
+	Namespace string
+	Name      string
+	// RotationInterval is how often the signing key is rotated; zero disables rotation
+	RotationInterval time.Duration
+	// GracePeriod is how long tokens signed with a rotated key remain valid
+	GracePeriod time.Duration
+
+	reloadMu   sync.Mutex
+	lastReload time.Time
+}
+
+// Load reads the signing keys from the Secret, creating it with a new key when it does
+// not exist, and uses them to sign and validate tokens
+func (s *SigningKeyStore) Load(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, secret)
+	if apierrors.IsNotFound(err) {
+		secret, err = s.create(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load JWT signing keys: %w", err)
+	}
+	return s.use(secret)
+}
+
+// reload loads the keys for a token signed with an unknown key, at most once every
+// signingKeyReloadInterval, reporting whether they were reloaded
+func (s *SigningKeyStore) reload() bool {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+	if time.Since(s.lastReload) < signingKeyReloadInterval {
+		return false
+	}
+	s.lastReload = time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), signingKeyReloadTimeout)
+	defer cancel()
+	if err := s.Load(ctx); err != nil {
+		logf.Log.WithName("jwt-signing-keys").Error(err, "failed to reload JWT signing keys")
+		return false
+	}
+	return true
+}
+
+// create creates the Secret with a new signing key. If another replica created it first,
+// that Secret is returned instead
+func (s *SigningKeyStore) create(ctx context.Context) (*corev1.Secret, error) {
+	id, key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: s.Namespace, Name: s.Name},
+		Type:       corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			currentKeyField:     []byte(id),
+			rotatedAtField:      []byte(time.Now().UTC().Format(time.RFC3339)),
+			keyFieldPrefix + id: key,
+		},
+	}
+	err = s.Client.Create(ctx, secret)
+	if apierrors.IsAlreadyExists(err) {
+		err = s.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// use parses the keys in secret and makes them the active keys
+func (s *SigningKeyStore) use(secret *corev1.Secret) error {
+	keys := &signingKeys{
+		currentID: string(secret.Data[currentKeyField]),
+		keys:      map[string][]byte{},
+		expires:   map[string]time.Time{},
+		store:     s,
+	}
+	keys.rotatedAt, _ = time.Parse(time.RFC3339, string(secret.Data[rotatedAtField]))
+	for field, value := range secret.Data {
+		if id, ok := strings.CutPrefix(field, keyFieldPrefix); ok {
+			keys.keys[id] = value
+		}
+		if id, ok := strings.CutPrefix(field, expiresFieldPrefix); ok {
+			if expires, err := time.Parse(time.RFC3339, string(value)); err == nil {
+				keys.expires[id] = expires
+			}
+		}
+	}
+	if len(keys.keys[keys.currentID]) == 0 {
+		return fmt.Errorf("secret %s/%s has no current JWT signing key", s.Namespace, s.Name)
+	}
+	setSigningKeys(keys)
+	return nil
+}
+
+// Rotate replaces the current signing key with a new one. The replaced key keeps
+// validating tokens for GracePeriod, and keys whose grace period is over are dropped
+func (s *SigningKeyStore) Rotate(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Name}, secret); err != nil {
+		return fmt.Errorf("failed to rotate JWT signing key: %w", err)
+	}
+	id, key, err := newSigningKey()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for field, value := range secret.Data {
+		retired, ok := strings.CutPrefix(field, expiresFieldPrefix)
+		if !ok {
+			continue
+		}
+		if expires, err := time.Parse(time.RFC3339, string(value)); err != nil || expires.Before(now) {
+			delete(secret.Data, field)
+			delete(secret.Data, keyFieldPrefix+retired)
+		}
+	}
+	if previous := string(secret.Data[currentKeyField]); previous != "" {
+		secret.Data[expiresFieldPrefix+previous] = []byte(now.Add(s.GracePeriod).UTC().Format(time.RFC3339))
+	}
+	secret.Data[keyFieldPrefix+id] = key
+	secret.Data[currentKeyField] = []byte(id)
+	secret.Data[rotatedAtField] = []byte(now.UTC().Format(time.RFC3339))
+
+	// A conflict means another replica rotated the key first
+	if err := s.Client.Update(ctx, secret); err != nil {
+		return fmt.Errorf("failed to rotate JWT signing key: %w", err)
+	}
+	return s.use(secret)
+}
+
+// Start reloads the signing keys every signingKeySyncInterval until ctx is done, rotating
+// the current key once it is older than RotationInterval
+func (s *SigningKeyStore) Start(ctx context.Context) error {
+	log := logf.FromContext(ctx).WithName("jwt-signing-keys")
+	ticker := time.NewTicker(signingKeySyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := s.sync(ctx); err != nil {
+			log.Error(err, "failed to sync JWT signing keys")
+		}
+	}
+}
+
+// NeedLeaderElection is false: every replica must keep its keys in sync, and concurrent
+// rotations are resolved by the Secret's resourceVersion
+func (s *SigningKeyStore) NeedLeaderElection() bool {
+	return false
+}
+
+// sync reloads the signing keys and rotates the current key when it is due
+func (s *SigningKeyStore) sync(ctx context.Context) error {
+	if err := s.Load(ctx); err != nil {
+		return err
+	}
+	if s.RotationInterval <= 0 {
+		return nil
+	}
+	signingKeysMu.RLock()
+	rotatedAt := activeKeys.rotatedAt
+	signingKeysMu.RUnlock()
+	if time.Since(rotatedAt) < s.RotationInterval {
+		return nil
+	}
+	if err := s.Rotate(ctx); err != nil && !apierrors.IsConflict(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func newTestKeyStore(grace time.Duration) *SigningKeyStore {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	return &SigningKeyStore{
+		Client:      fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Namespace:   "kube-system",
+		Name:        "jwt-keys",
+		GracePeriod: grace,
+	}
+}
+
+func TestSigningKeyStoreSurvivesRestart(t *testing.T) {
+	store := newTestKeyStore(time.Hour)
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := store.Client.Get(context.Background(), client.ObjectKey{Namespace: "kube-system", Name: "jwt-keys"}, secret); err != nil {
+		t.Fatalf("Expected the signing key Secret to be created: %v", err)
+	}
+	token, err := GenerateJWT(&llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "alice"}})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	// A restarted replica starts from an unrelated in-memory key
+	if err := InitJWTSecret(); err != nil {
+		t.Fatalf("Failed to init JWT secret: %v", err)
+	}
+	if _, err := ValidateJWT(token); err == nil {
+		t.Fatal("Expected a token signed with another key to be rejected")
+	}
+	restarted := &SigningKeyStore{Client: store.Client, Namespace: "kube-system", Name: "jwt-keys"}
+	if err := restarted.Load(context.Background()); err != nil {
+		t.Fatalf("Failed to reload keys: %v", err)
+	}
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("Expected the token to validate after reloading the keys: %v", err)
+	}
+}
+
+func TestSigningKeyStoreRotate(t *testing.T) {
+	tests := []struct {
+		name      string
+		grace     time.Duration
+		wantValid bool
+	}{
+		{name: "within grace period", grace: time.Hour, wantValid: true},
+		{name: "grace period over", grace: -time.Second, wantValid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestKeyStore(tt.grace)
+			if err := store.Load(context.Background()); err != nil {
+				t.Fatalf("Failed to load keys: %v", err)
+			}
+			oldToken, err := GenerateJWT(&llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "alice"}})
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+
+			if err := store.Rotate(context.Background()); err != nil {
+				t.Fatalf("Failed to rotate key: %v", err)
+			}
+			newToken, err := GenerateJWT(&llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "alice"}})
+			if err != nil {
+				t.Fatalf("Failed to generate token: %v", err)
+			}
+			if _, err := ValidateJWT(newToken); err != nil {
+				t.Errorf("Expected a token signed with the new key to validate: %v", err)
+			}
+			if _, err := ValidateJWT(oldToken); (err == nil) != tt.wantValid {
+				t.Errorf("Expected old token valid=%v, got error %v", tt.wantValid, err)
+			}
+		})
+	}
+}
+
+func TestSigningKeyStoreReloadsUnknownKey(t *testing.T) {
+	store := newTestKeyStore(time.Hour)
+	if err := store.Load(context.Background()); err != nil {
+		t.Fatalf("Failed to load keys: %v", err)
+	}
+	signingKeysMu.RLock()
+	stale := activeKeys
+	signingKeysMu.RUnlock()
+
+	// Another replica rotates the key and signs a token with the new one
+	other := &SigningKeyStore{Client: store.Client, Namespace: "kube-system", Name: "jwt-keys", GracePeriod: time.Hour}
+	if err := other.Rotate(context.Background()); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+	token, err := GenerateJWT(&llmcloudv1alpha1.User{Spec: llmcloudv1alpha1.UserSpec{Username: "alice"}})
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	setSigningKeys(stale)
+	if _, err := ValidateJWT(token); err != nil {
+		t.Errorf("Expected a token signed with a key rotated by another replica to validate: %v", err)
+	}
+}
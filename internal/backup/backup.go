@@ -0,0 +1,421 @@
+// Package backup exports the llmcloud resources of a cluster to a gzipped tarball, and
+// applies a tarball to a cluster again, e.g. to rebuild a cluster from scratch
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/kubeops"
+)
+
+// Version is the format of the backups written
+const Version = 1
+
+// manifestFile is the first file of a backup, describing it
+const manifestFile = "manifest.json"
+
+// fieldOwner owns the fields set by a restore
+const fieldOwner = "llmcloud-restore"
+
+// defaultNamespaceTimeout is how long a restore waits for a project's namespace when
+// NamespaceTimeout is zero
+const defaultNamespaceTimeout = 2 * time.Minute
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmcloudconfigs;projects;users;groups;sshkeys;vmtemplates;vmimages;virtualmachines;llmmodels;services,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=snapshot.kubevirt.io,resources=virtualmachinesnapshots,verbs=get;list;watch;create;delete
+
+// ErrInvalidBackup is returned by Restore for data that is not a backup it can restore
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Resources are the kinds a backup holds, in the order they are restored. The cluster
+// configuration comes first, projects before the namespaced kinds, whose namespaces the
+// project controller creates, and Secrets, templates and images before the VMs and
+// services that reference them. Secrets are the core kind, the rest llmcloud kinds. The
+// disks of uploaded images are not kept, and have to be uploaded again
+var Resources = []string{
+	"LLMCloudConfig", "Project", "User", "Group", "SSHKey", "Secret",
+	"VMTemplate", "VMImage", "VirtualMachine", "LLMModel", "Service",
+}
+
+var vmSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.kubevirt.io", Version: "v1beta1", Kind: "VirtualMachineSnapshot"}
+
+// Manifest describes a backup
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	// Counts is the number of objects of each kind
+	Counts map[string]int `json:"counts"`
+	// Snapshots are the VM disk snapshots taken with the backup
+	Snapshots []Snapshot `json:"snapshots,omitempty"`
+	// Disks are the VM disks whose contents the backup holds
+	Disks []Disk `json:"disks,omitempty"`
+}
+
+// Snapshot is a VirtualMachineSnapshot of a VM's disks. It is kept by the storage of the
+// cluster it was taken in, so a VM restored to another cluster starts from its image
+type Snapshot struct {
+	Namespace      string `json:"namespace"`
+	VirtualMachine string `json:"virtualMachine"`
+	Name           string `json:"name"`
+}
+
+// Options control what Export includes
+type Options struct {
+	// SnapshotVMs takes a VirtualMachineSnapshot of every VM's disks
+	SnapshotVMs bool
+	// ExportDisks keeps the contents of every VM's disks in the backup, read from a
+	// snapshot through KubeVirt's export server, so the VMs can be restored onto another
+	// cluster. It implies SnapshotVMs, and needs Config
+	ExportDisks bool
+	// Config is the config of the cluster, whose API server proxies the downloads of
+	// the exported disks
+	Config *rest.Config
+}
+
+// Export writes the llmcloud resources of the cluster, and the Secrets they need, to w
+// as a gzipped tarball: the manifest, then a multi-document YAML file per kind and the
+// exported disks. Only what is needed to create the objects again is kept: their status
+// and server-set metadata are dropped. The backup holds Secrets and password hashes, and
+// has to be stored as safely as the cluster's own
+func Export(ctx context.Context, c client.Client, w io.Writer, opts Options) (*Manifest, error) {
+	manifest := &Manifest{Version: Version, CreatedAt: time.Now().UTC(), Counts: map[string]int{}}
+	objects := map[string][]unstructured.Unstructured{}
+	for _, kind := range Resources {
+		if kind == "Secret" {
+			continue
+		}
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(resourceGVK(kind + "List"))
+		if err := c.List(ctx, list); err != nil {
+			return nil, fmt.Errorf("failed to list %s objects: %w", kind, err)
+		}
+		objects[kind] = list.Items
+	}
+	secrets, err := referencedSecrets(ctx, c, objects)
+	if err != nil {
+		return nil, err
+	}
+	objects["Secret"] = secrets
+
+	files := map[string][]byte{}
+	for _, kind := range Resources {
+		var data bytes.Buffer
+		for i := range objects[kind] {
+			doc, err := yaml.Marshal(exportable(&objects[kind][i]).Object)
+			if err != nil {
+				return nil, err
+			}
+			data.WriteString("---\n")
+			data.Write(doc)
+		}
+		manifest.Counts[kind] = len(objects[kind])
+		files[kind] = data.Bytes()
+	}
+
+	var disks diskFiles
+	defer disks.remove()
+	if opts.SnapshotVMs || opts.ExportDisks {
+		for _, vm := range objects["VirtualMachine"] {
+			snapshot, err := snapshotVM(ctx, c, &vm, manifest.CreatedAt)
+			if err != nil {
+				return nil, err
+			}
+			manifest.Snapshots = append(manifest.Snapshots, snapshot)
+			if !opts.ExportDisks {
+				continue
+			}
+			exported, err := exportDisks(ctx, c, opts.Config, snapshot, &disks)
+			if err != nil {
+				return nil, err
+			}
+			manifest.Disks = append(manifest.Disks, exported...)
+		}
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFile(tw, manifestFile, manifestData, manifest.CreatedAt); err != nil {
+		return nil, err
+	}
+	for _, kind := range Resources {
+		if err := writeFile(tw, resourceFile(kind), files[kind], manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	for _, disk := range manifest.Disks {
+		if err := disks.write(tw, disk.File, manifest.CreatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, gz.Close()
+}
+
+// resourceFile is the file holding the objects of a kind
+func resourceFile(kind string) string {
+	return "resources/" + kind + ".yaml"
+}
+
+// resourceGVK returns the group, version and kind of one of the Resources
+func resourceGVK(kind string) schema.GroupVersionKind {
+	if kind == "Secret" || kind == "SecretList" {
+		return corev1.SchemeGroupVersion.WithKind(kind)
+	}
+	return llmcloudv1alpha1.GroupVersion.WithKind(kind)
+}
+
+// referencedSecrets returns the Secrets the objects of a backup need: those their VMs
+// and services reference, and the credentials the controllers generated in their
+// namespaces, which restored disks and databases still expect
+func referencedSecrets(ctx context.Context, c client.Client, objects map[string][]unstructured.Unstructured) ([]unstructured.Unstructured, error) {
+	namespaces := map[string]bool{}
+	names := map[client.ObjectKey]bool{}
+	for _, items := range objects {
+		for _, obj := range items {
+			if obj.GetNamespace() != "" {
+				namespaces[obj.GetNamespace()] = true
+			}
+		}
+	}
+	for _, vm := range objects["VirtualMachine"] {
+		if name, _, _ := unstructured.NestedString(vm.Object, "spec", "cloudInitSecretRef", "name"); name != "" {
+			names[client.ObjectKey{Namespace: vm.GetNamespace(), Name: name}] = true
+		}
+		files, _, _ := unstructured.NestedSlice(vm.Object, "spec", "secretFiles")
+		for _, file := range files {
+			if file, ok := file.(map[string]interface{}); ok {
+				if name, _, _ := unstructured.NestedString(file, "secretRef", "name"); name != "" {
+					names[client.ObjectKey{Namespace: vm.GetNamespace(), Name: name}] = true
+				}
+			}
+		}
+	}
+	for _, service := range objects["Service"] {
+		if name, _, _ := unstructured.NestedString(service.Object, "spec", "tlsSecretName"); name != "" {
+			names[client.ObjectKey{Namespace: service.GetNamespace(), Name: name}] = true
+		}
+	}
+
+	var secrets []unstructured.Unstructured
+	for _, namespace := range slices.Sorted(maps.Keys(namespaces)) {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(resourceGVK("SecretList"))
+		if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{"llmcloud.io/managed": "true"}); err != nil {
+			return nil, fmt.Errorf("failed to list Secrets in %s: %w", namespace, err)
+		}
+		for _, secret := range list.Items {
+			delete(names, client.ObjectKeyFromObject(&secret))
+			secrets = append(secrets, secret)
+		}
+	}
+	for _, key := range slices.SortedFunc(maps.Keys(names), func(a, b client.ObjectKey) int {
+		return strings.Compare(a.String(), b.String())
+	}) {
+		secret := &unstructured.Unstructured{}
+		secret.SetGroupVersionKind(resourceGVK("Secret"))
+		if err := c.Get(ctx, key, secret); err != nil {
+			// A VM waiting for its Secret is restored waiting for it as well
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to get Secret %s: %w", key, err)
+		}
+		secrets = append(secrets, *secret)
+	}
+	return secrets, nil
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// exportable returns a copy of obj without its status and the metadata the API server
+// and controllers set, which must not be given when creating it
+func exportable(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	out := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": obj.GetAPIVersion(),
+		"kind":       obj.GetKind(),
+	}}
+	// Secrets keep their contents in type and data rather than a spec
+	for _, field := range []string{"spec", "type", "data"} {
+		if value, ok := obj.Object[field]; ok {
+			out.Object[field] = value
+		}
+	}
+	out.SetName(obj.GetName())
+	out.SetNamespace(obj.GetNamespace())
+	out.SetLabels(obj.GetLabels())
+	out.SetAnnotations(obj.GetAnnotations())
+	return out
+}
+
+// snapshotVM takes a VirtualMachineSnapshot of the KubeVirt VM of a VM
+func snapshotVM(ctx context.Context, c client.Client, vm *unstructured.Unstructured, at time.Time) (Snapshot, error) {
+	snapshot := Snapshot{
+		Namespace:      vm.GetNamespace(),
+		VirtualMachine: vm.GetName(),
+		Name:           fmt.Sprintf("%s-export-%d", vm.GetName(), at.Unix()),
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(vmSnapshotGVK)
+	obj.SetName(snapshot.Name)
+	obj.SetNamespace(snapshot.Namespace)
+	obj.Object["spec"] = map[string]interface{}{
+		"source": map[string]interface{}{
+			"apiGroup": "kubevirt.io",
+			"kind":     "VirtualMachine",
+			"name":     snapshot.VirtualMachine,
+		},
+	}
+	if err := c.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+		return Snapshot{}, fmt.Errorf("failed to snapshot VM %s/%s: %w", snapshot.Namespace, snapshot.VirtualMachine, err)
+	}
+	return snapshot, nil
+}
+
+// RestoreOptions control Restore
+type RestoreOptions struct {
+	// NamespaceTimeout is how long to wait for the namespace of a namespaced object,
+	// which the project controller creates for a restored project. Two minutes when zero
+	NamespaceTimeout time.Duration
+	// UploadProxyURL is the URL of the CDI upload proxy the disks of a backup are
+	// uploaded through. The cluster IP of its Service when empty
+	UploadProxyURL string
+}
+
+// Restore applies the objects of a backup read from r with server-side apply, kind by
+// kind in the order of Resources, and returns the backup's manifest. Objects that
+// already exist are updated to their backed up state. The disks of the VMs are uploaded
+// into new DataVolumes before the VMs are applied, which then adopt them
+func Restore(ctx context.Context, c client.Client, r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	manifest, files, err := read(r)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.NamespaceTimeout
+	if timeout <= 0 {
+		timeout = defaultNamespaceTimeout
+	}
+
+	namespaces := map[string]bool{}
+	for _, kind := range Resources {
+		objects, err := kubeops.Decode(files[resourceFile(kind)])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidBackup, resourceFile(kind), err)
+		}
+		if kind == "VirtualMachine" {
+			if err := restoreDisks(ctx, c, manifest.Disks, files, opts.UploadProxyURL); err != nil {
+				return nil, err
+			}
+		}
+		for _, obj := range objects {
+			if obj.GroupVersionKind().GroupKind() != resourceGVK(kind).GroupKind() {
+				return nil, fmt.Errorf("%w: %s: unexpected %s %s", ErrInvalidBackup, resourceFile(kind), obj.GetKind(), obj.GetName())
+			}
+			if ns := obj.GetNamespace(); ns != "" && !namespaces[ns] {
+				if err := waitForNamespace(ctx, c, ns, timeout); err != nil {
+					return nil, err
+				}
+				namespaces[ns] = true
+			}
+			if err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldOwner), client.ForceOwnership); err != nil {
+				return nil, fmt.Errorf("failed to restore %s %s: %w", kind, client.ObjectKeyFromObject(obj), err)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// read returns the manifest and files of a backup
+func read(r io.Reader) (*Manifest, map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+	defer func() { _ = gz.Close() }()
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+		files[header.Name] = data
+	}
+
+	data, ok := files[manifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no %s", ErrInvalidBackup, manifestFile)
+	}
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %w", ErrInvalidBackup, manifestFile, err)
+	}
+	if manifest.Version != Version {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, manifest.Version)
+	}
+	return manifest, files, nil
+}
+
+// waitForNamespace waits until namespace exists
+func waitForNamespace(ctx context.Context, c client.Client, namespace string, timeout time.Duration) error {
+	err := wait.PollUntilContextTimeout(ctx, time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		err := c.Get(ctx, client.ObjectKey{Name: namespace}, &corev1.Namespace{})
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return fmt.Errorf("namespace %s does not exist; is the operator running? %w", namespace, err)
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/yaml"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/cdi"
+)
+
+func newClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func TestExportRestore(t *testing.T) {
+	ctx := context.Background()
+	source := newClient(
+		&llmcloudv1alpha1.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Finalizers: []string{"llmcloud.llmcloud.io/finalizer"}},
+			Spec:       llmcloudv1alpha1.ProjectSpec{Description: "The team"},
+			Status:     llmcloudv1alpha1.ProjectStatus{Namespace: "project-team"},
+		},
+		&llmcloudv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "kube-system"},
+			Spec:       llmcloudv1alpha1.UserSpec{Username: "alice", PasswordHash: "$2a$10$hash", Projects: []string{"team"}},
+		},
+		&llmcloudv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "devs"},
+			Spec:       llmcloudv1alpha1.GroupSpec{Members: []string{"alice"}},
+		},
+		&llmcloudv1alpha1.LLMCloudConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec:       llmcloudv1alpha1.LLMCloudConfigSpec{DefaultStorageClass: "ceph"},
+		},
+		&llmcloudv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team", Labels: map[string]string{"app": "web"}},
+			Spec: llmcloudv1alpha1.VirtualMachineSpec{
+				CPUs: 2, Memory: "4Gi", OS: "ubuntu",
+				CloudInitSecretRef: &llmcloudv1alpha1.SecretKeySelector{Name: "web-userdata", Key: "userdata"},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-userdata", Namespace: "project-team"},
+			Data:       map[string][]byte{"userdata": []byte("#cloud-config")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "db-credentials", Namespace: "project-team", Labels: map[string]string{"llmcloud.io/managed": "true"},
+			},
+			Type: corev1.SecretTypeBasicAuth,
+			Data: map[string][]byte{"password": []byte("s3cret")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "project-team"},
+		},
+	)
+
+	var data bytes.Buffer
+	manifest, err := Export(ctx, source, &data, Options{})
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if manifest.Counts["Project"] != 1 || manifest.Counts["User"] != 1 || manifest.Counts["VirtualMachine"] != 1 ||
+		manifest.Counts["Group"] != 1 || manifest.Counts["LLMCloudConfig"] != 1 || manifest.Counts["Secret"] != 2 ||
+		manifest.Counts["LLMModel"] != 0 {
+		t.Errorf("Unexpected counts %v", manifest.Counts)
+	}
+
+	target := newClient(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "project-team"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}},
+	)
+	restored, err := Restore(ctx, target, &data, RestoreOptions{})
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if !restored.CreatedAt.Equal(manifest.CreatedAt) {
+		t.Errorf("Expected the manifest of the backup, got %+v", restored)
+	}
+
+	project := &llmcloudv1alpha1.Project{}
+	if err := target.Get(ctx, client.ObjectKey{Name: "team"}, project); err != nil {
+		t.Fatalf("Expected the project to be restored: %v", err)
+	}
+	if project.Spec.Description != "The team" || project.Status.Namespace != "" || len(project.Finalizers) != 0 {
+		t.Errorf("Expected the spec without status or finalizers, got %+v", project)
+	}
+	user := &llmcloudv1alpha1.User{}
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "kube-system", Name: "alice"}, user); err != nil {
+		t.Fatalf("Expected the user to be restored: %v", err)
+	}
+	if user.Spec.PasswordHash != "$2a$10$hash" {
+		t.Errorf("Expected the password hash to be kept, got %q", user.Spec.PasswordHash)
+	}
+	vm := &llmcloudv1alpha1.VirtualMachine{}
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "web"}, vm); err != nil {
+		t.Fatalf("Expected the VM to be restored: %v", err)
+	}
+	if vm.Spec.Memory != "4Gi" || vm.Labels["app"] != "web" {
+		t.Errorf("Expected the VM's spec and labels, got %+v", vm)
+	}
+	if err := target.Get(ctx, client.ObjectKey{Name: "devs"}, &llmcloudv1alpha1.Group{}); err != nil {
+		t.Errorf("Expected the group to be restored: %v", err)
+	}
+	if err := target.Get(ctx, client.ObjectKey{Name: "default"}, &llmcloudv1alpha1.LLMCloudConfig{}); err != nil {
+		t.Errorf("Expected the config to be restored: %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "db-credentials"}, secret); err != nil {
+		t.Fatalf("Expected the generated credentials to be restored: %v", err)
+	}
+	if string(secret.Data["password"]) != "s3cret" || secret.Type != corev1.SecretTypeBasicAuth {
+		t.Errorf("Expected the Secret's contents, got %+v", secret)
+	}
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "web-userdata"}, secret); err != nil {
+		t.Errorf("Expected the referenced Secret to be restored: %v", err)
+	}
+	err = target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "unrelated"}, secret)
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected an unreferenced Secret to be left out, got %v", err)
+	}
+}
+
+func TestResourcesCoverEveryCRD(t *testing.T) {
+	// Kinds describing the cluster itself rather than what runs on it
+	skipped := map[string]bool{"AuditEvent": true, "ClusterNode": true, "Node": true}
+
+	files, err := filepath.Glob("../../config/crd/bases/*.yaml")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to find the CRDs: %v", err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		var crd struct {
+			Spec struct {
+				Names struct {
+					Kind string `json:"kind"`
+				} `json:"names"`
+			} `json:"spec"`
+		}
+		if err := yaml.Unmarshal(data, &crd); err != nil {
+			t.Fatalf("Failed to parse %s: %v", file, err)
+		}
+		kind := crd.Spec.Names.Kind
+		if !skipped[kind] && !slices.Contains(Resources, kind) {
+			t.Errorf("Expected backups to hold %s objects, or the kind to be skipped on purpose", kind)
+		}
+	}
+}
+
+func TestExportRestoreDisks(t *testing.T) {
+	ctx := context.Background()
+	readyOnCreate := func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			switch u.GetKind() {
+			case "VirtualMachineSnapshot":
+				_ = unstructured.SetNestedField(u.Object, true, "status", "readyToUse")
+			case "VirtualMachineExport":
+				_ = unstructured.SetNestedField(u.Object, "Ready", "status", "phase")
+				_ = unstructured.SetNestedField(u.Object, "export-token", "status", "tokenSecretRef")
+				_ = unstructured.SetNestedSlice(u.Object, []interface{}{map[string]interface{}{
+					"name": "web-disk",
+					"formats": []interface{}{
+						map[string]interface{}{"format": "raw", "url": "https://virt-export/volumes/web-disk/disk.img"},
+						map[string]interface{}{"format": "gzip", "url": "https://virt-export/volumes/web-disk/disk.img.gz"},
+					},
+				}}, "status", "links", "internal", "volumes")
+			}
+		}
+		return c.Create(ctx, obj, opts...)
+	}
+	kubevirtVM := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"dataVolumeTemplates": []interface{}{map[string]interface{}{
+				"metadata": map[string]interface{}{"name": "web-disk"},
+				"spec": map[string]interface{}{
+					"storage": map[string]interface{}{
+						"resources": map[string]interface{}{"requests": map[string]interface{}{"storage": "20Gi"}},
+					},
+				},
+			}},
+		},
+	}}
+	kubevirtVM.SetGroupVersionKind(kubevirtVMGVK)
+	kubevirtVM.SetNamespace("project-team")
+	kubevirtVM.SetName("web")
+
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	source := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			&llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"}},
+			&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "export-token", Namespace: "project-team"},
+				Data:       map[string][]byte{"token": []byte("t0ken")},
+			},
+			kubevirtVM,
+		).
+		WithInterceptorFuncs(interceptor.Funcs{Create: readyOnCreate}).
+		Build()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/v1/namespaces/project-team/services/https:virt-export-web-export-") ||
+			!strings.HasSuffix(r.URL.Path, ":443/proxy/volumes/web-disk/disk.img.gz") ||
+			r.Header.Get("x-kubevirt-export-token") != "t0ken" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("gzipped disk"))
+	}))
+	defer apiServer.Close()
+
+	var data bytes.Buffer
+	manifest, err := Export(ctx, source, &data, Options{ExportDisks: true, Config: &rest.Config{Host: apiServer.URL}})
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if len(manifest.Disks) != 1 || manifest.Disks[0].Volume != "web-disk" || manifest.Disks[0].Size != "20Gi" {
+		t.Fatalf("Expected the VM's disk, got %+v", manifest.Disks)
+	}
+	exports := &unstructured.UnstructuredList{}
+	exports.SetGroupVersionKind(vmExportGVK.GroupVersion().WithKind("VirtualMachineExportList"))
+	if err := source.List(ctx, exports); err != nil || len(exports.Items) != 0 {
+		t.Errorf("Expected the export to be deleted, got %d, %v", len(exports.Items), err)
+	}
+
+	var uploaded, token string
+	proxy := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		uploaded = string(body)
+	}))
+	defer proxy.Close()
+	target := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "project-team"}},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: cdi.Namespace, Name: cdi.UploadProxyCABundle},
+				Data: map[string]string{
+					"ca-bundle.crt": string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: proxy.Certificate().Raw})),
+				},
+			},
+		).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if u, ok := obj.(*unstructured.Unstructured); ok {
+					switch u.GetKind() {
+					case "DataVolume":
+						_ = unstructured.SetNestedField(u.Object, "UploadReady", "status", "phase")
+					case "UploadTokenRequest":
+						return unstructured.SetNestedField(u.Object, "upload-token", "status", "token")
+					}
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	if _, err := Restore(ctx, target, &data, RestoreOptions{UploadProxyURL: proxy.URL}); err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if uploaded != "gzipped disk" || token != "Bearer upload-token" {
+		t.Errorf("Expected the disk to be uploaded with the upload token, got %q with %q", uploaded, token)
+	}
+	dv := &unstructured.Unstructured{}
+	dv.SetGroupVersionKind(dataVolumeGVK)
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "web-disk"}, dv); err != nil {
+		t.Fatalf("Expected the DataVolume to be created: %v", err)
+	}
+	if size, _, _ := unstructured.NestedString(dv.Object, "spec", "storage", "resources", "requests", "storage"); size != "20Gi" {
+		t.Errorf("Expected the DataVolume to have the disk's size, got %q", size)
+	}
+	if err := target.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: "web"}, &llmcloudv1alpha1.VirtualMachine{}); err != nil {
+		t.Errorf("Expected the VM to be restored: %v", err)
+	}
+}
+
+func TestExportSnapshotsVMs(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(&llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"},
+	})
+	manifest, err := Export(ctx, c, &bytes.Buffer{}, Options{SnapshotVMs: true})
+	if err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	if len(manifest.Snapshots) != 1 || manifest.Snapshots[0].VirtualMachine != "web" {
+		t.Fatalf("Expected a snapshot of the VM, got %+v", manifest.Snapshots)
+	}
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(vmSnapshotGVK)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "project-team", Name: manifest.Snapshots[0].Name}, snapshot); err != nil {
+		t.Errorf("Expected the VirtualMachineSnapshot to be created: %v", err)
+	}
+}
+
+func TestRestoreWaitsForNamespace(t *testing.T) {
+	ctx := context.Background()
+	source := newClient(&llmcloudv1alpha1.LLMModel{
+		ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "project-gone"},
+		Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "llama3"},
+	})
+	var data bytes.Buffer
+	if _, err := Export(ctx, source, &data, Options{}); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	_, err := Restore(ctx, newClient(), &data, RestoreOptions{NamespaceTimeout: 10 * time.Millisecond})
+	if err == nil || errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected a missing namespace error, got %v", err)
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	_, err := Restore(context.Background(), newClient(), bytes.NewReader([]byte("not a backup")), RestoreOptions{})
+	if !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("Expected ErrInvalidBackup, got %v", err)
+	}
+}
@@ -0,0 +1,311 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/rusik69/llmcloud-operator/internal/cdi"
+)
+
+// +kubebuilder:rbac:groups=export.kubevirt.io,resources=virtualmachineexports,verbs=get;create;delete
+// +kubebuilder:rbac:groups=core,resources=services/proxy,verbs=get
+// +kubebuilder:rbac:groups=cdi.kubevirt.io,resources=datavolumes,verbs=get;create
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get
+
+var (
+	vmExportGVK   = schema.GroupVersionKind{Group: "export.kubevirt.io", Version: "v1beta1", Kind: "VirtualMachineExport"}
+	kubevirtVMGVK = schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"}
+	dataVolumeGVK = schema.GroupVersionKind{Group: "cdi.kubevirt.io", Version: "v1beta1", Kind: "DataVolume"}
+)
+
+// diskPollTimeout is how long a snapshot, an export or an upload volume may take to
+// become ready
+var diskPollTimeout = 10 * time.Minute
+
+// Disk is the contents of a VM disk held by a backup, gzipped
+type Disk struct {
+	Namespace      string `json:"namespace"`
+	VirtualMachine string `json:"virtualMachine"`
+	// Volume is the DataVolume of the disk, which the VM adopts when restored
+	Volume string `json:"volume"`
+	// Size is the requested size of the volume
+	Size string `json:"size"`
+	// File is the file of the backup holding the disk
+	File string `json:"file"`
+}
+
+// diskFiles are the temporary files holding the disks downloaded for a backup, until it
+// is written
+type diskFiles map[string]*os.File
+
+// write copies the disk kept for a file of the backup into tw
+func (d diskFiles) write(tw *tar.Writer, name string, modTime time.Time) error {
+	f := d[name]
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: info.Size(), ModTime: modTime}); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// remove deletes the temporary files
+func (d *diskFiles) remove() {
+	for _, f := range *d {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}
+}
+
+// exportDisks downloads the disks of the VM of a snapshot, once it is ready, through a
+// VirtualMachineExport of the snapshot, which is deleted again afterwards
+func exportDisks(ctx context.Context, c client.Client, config *rest.Config, snapshot Snapshot, files *diskFiles) ([]Disk, error) {
+	if config == nil {
+		return nil, fmt.Errorf("exporting disks needs the config of the cluster")
+	}
+	vmName := snapshot.Namespace + "/" + snapshot.VirtualMachine
+	volumes, err := vmVolumes(ctx, c, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+
+	snapshotObj := &unstructured.Unstructured{}
+	snapshotObj.SetGroupVersionKind(vmSnapshotGVK)
+	if err := poll(ctx, c, client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.Name}, snapshotObj, func() bool {
+		ready, _, _ := unstructured.NestedBool(snapshotObj.Object, "status", "readyToUse")
+		return ready
+	}); err != nil {
+		return nil, fmt.Errorf("snapshot of VM %s is not ready: %w", vmName, err)
+	}
+
+	export := &unstructured.Unstructured{}
+	export.SetGroupVersionKind(vmExportGVK)
+	export.SetName(snapshot.Name)
+	export.SetNamespace(snapshot.Namespace)
+	export.Object["spec"] = map[string]interface{}{
+		"source": map[string]interface{}{
+			"apiGroup": vmSnapshotGVK.Group,
+			"kind":     vmSnapshotGVK.Kind,
+			"name":     snapshot.Name,
+		},
+	}
+	if err := c.Create(ctx, export); err != nil && !apierrors.IsAlreadyExists(err) {
+		return nil, fmt.Errorf("failed to export VM %s: %w", vmName, err)
+	}
+	defer func() { _ = client.IgnoreNotFound(c.Delete(context.WithoutCancel(ctx), export)) }()
+	if err := poll(ctx, c, client.ObjectKeyFromObject(export), export, func() bool {
+		phase, _, _ := unstructured.NestedString(export.Object, "status", "phase")
+		return phase == "Ready"
+	}); err != nil {
+		return nil, fmt.Errorf("export of VM %s is not ready: %w", vmName, err)
+	}
+
+	tokenSecret, _, _ := unstructured.NestedString(export.Object, "status", "tokenSecretRef")
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: snapshot.Namespace, Name: tokenSecret}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the export token of VM %s: %w", vmName, err)
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	links, _, _ := unstructured.NestedSlice(export.Object, "status", "links", "internal", "volumes")
+	var disks []Disk
+	for _, volume := range slices.Sorted(maps.Keys(volumes)) {
+		size := volumes[volume]
+		link, ok := volumeLink(links, volume)
+		if !ok {
+			return nil, fmt.Errorf("export of VM %s has no gzipped disk %s", vmName, volume)
+		}
+		disk := Disk{
+			Namespace:      snapshot.Namespace,
+			VirtualMachine: snapshot.VirtualMachine,
+			Volume:         volume,
+			Size:           size,
+			File:           "disks/" + snapshot.Namespace + "/" + volume + ".img.gz",
+		}
+		proxyURL := strings.TrimSuffix(config.Host, "/") + path.Join("/api/v1/namespaces", snapshot.Namespace,
+			"services", "https:virt-export-"+export.GetName()+":443", "proxy", link.Path)
+		if err := download(ctx, httpClient, proxyURL, string(secret.Data["token"]), disk.File, files); err != nil {
+			return nil, fmt.Errorf("failed to download disk %s of VM %s: %w", volume, vmName, err)
+		}
+		disks = append(disks, disk)
+	}
+	return disks, nil
+}
+
+// vmVolumes returns the sizes of the DataVolumes of the KubeVirt VM of a snapshot, by name
+func vmVolumes(ctx context.Context, c client.Client, snapshot Snapshot) (map[string]string, error) {
+	vm := &unstructured.Unstructured{}
+	vm.SetGroupVersionKind(kubevirtVMGVK)
+	if err := c.Get(ctx, client.ObjectKey{Namespace: snapshot.Namespace, Name: snapshot.VirtualMachine}, vm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	templates, _, _ := unstructured.NestedSlice(vm.Object, "spec", "dataVolumeTemplates")
+	volumes := map[string]string{}
+	for _, template := range templates {
+		template, ok := template.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(template, "metadata", "name")
+		size, _, _ := unstructured.NestedString(template, "spec", "storage", "resources", "requests", "storage")
+		if size == "" {
+			size, _, _ = unstructured.NestedString(template, "spec", "pvc", "resources", "requests", "storage")
+		}
+		if name != "" {
+			volumes[name] = size
+		}
+	}
+	return volumes, nil
+}
+
+// volumeLink returns the URL of the gzipped disk of volume among the volume links of an
+// export. Volumes restored from a snapshot may be named after the export
+func volumeLink(links []interface{}, volume string) (*url.URL, bool) {
+	for _, link := range links {
+		link, ok := link.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(link, "name")
+		if name != volume && !strings.HasSuffix(name, "-"+volume) {
+			continue
+		}
+		formats, _, _ := unstructured.NestedSlice(link, "formats")
+		for _, format := range formats {
+			format, ok := format.(map[string]interface{})
+			if !ok || format["format"] != "gzip" {
+				continue
+			}
+			raw, _, _ := unstructured.NestedString(format, "url")
+			if u, err := url.Parse(raw); err == nil {
+				return u, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// download writes the response to a GET of target, authorized with an export token, to a
+// new temporary file kept for name
+func download(ctx context.Context, httpClient *http.Client, target, token, name string, files *diskFiles) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-kubevirt-export-token", token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	f, err := os.CreateTemp("", "llmcloud-disk-*.img.gz")
+	if err != nil {
+		return err
+	}
+	if *files == nil {
+		*files = diskFiles{}
+	}
+	(*files)[name] = f
+	_, err = io.Copy(f, resp.Body)
+	return err
+}
+
+// restoreDisks creates an upload DataVolume for every disk of a backup and uploads the
+// disk into it. A disk whose DataVolume exists already is left alone
+func restoreDisks(ctx context.Context, c client.Client, disks []Disk, files map[string][]byte, proxyURL string) error {
+	if len(disks) == 0 {
+		return nil
+	}
+	proxyURL, httpClient, err := cdi.UploadProxy(ctx, c, proxyURL)
+	if err != nil {
+		return fmt.Errorf("failed to reach the CDI upload proxy: %w", err)
+	}
+	for _, disk := range disks {
+		data, ok := files[disk.File]
+		if !ok {
+			return fmt.Errorf("%w: no %s", ErrInvalidBackup, disk.File)
+		}
+		dv := &unstructured.Unstructured{}
+		dv.SetGroupVersionKind(dataVolumeGVK)
+		dv.SetName(disk.Volume)
+		dv.SetNamespace(disk.Namespace)
+		dv.SetLabels(map[string]string{"llmcloud.io/managed": "true"})
+		dv.Object["spec"] = map[string]interface{}{
+			"source": map[string]interface{}{"upload": map[string]interface{}{}},
+			"storage": map[string]interface{}{
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{"storage": disk.Size},
+				},
+			},
+		}
+		if err := c.Create(ctx, dv); err != nil {
+			if apierrors.IsAlreadyExists(err) {
+				continue
+			}
+			return fmt.Errorf("failed to create DataVolume %s/%s: %w", disk.Namespace, disk.Volume, err)
+		}
+		if err := poll(ctx, c, client.ObjectKeyFromObject(dv), dv, func() bool {
+			phase, _, _ := unstructured.NestedString(dv.Object, "status", "phase")
+			return phase == "UploadReady"
+		}); err != nil {
+			return fmt.Errorf("DataVolume %s/%s is not ready for the upload: %w", disk.Namespace, disk.Volume, err)
+		}
+		token, err := cdi.UploadToken(ctx, c, disk.Namespace, disk.Volume)
+		if err != nil {
+			return fmt.Errorf("failed to request an upload token for %s/%s: %w", disk.Namespace, disk.Volume, err)
+		}
+		if err := cdi.Upload(ctx, httpClient, proxyURL, token, bytes.NewReader(data), int64(len(data))); err != nil {
+			return fmt.Errorf("failed to upload disk %s/%s: %w", disk.Namespace, disk.Volume, err)
+		}
+	}
+	return nil
+}
+
+// poll gets obj by key until ready returns true
+func poll(ctx context.Context, c client.Client, key client.ObjectKey, obj client.Object, ready func() bool) error {
+	return wait.PollUntilContextTimeout(ctx, time.Second, diskPollTimeout, true, func(ctx context.Context) (bool, error) {
+		if err := c.Get(ctx, key, obj); err != nil {
+			return false, err
+		}
+		return ready(), nil
+	})
+}
@@ -0,0 +1,168 @@
+package backup
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores backups in an S3 bucket, or in any object store with its API such as
+// MinIO. Requests are signed with AWS Signature Version 4 and address buckets by path
+type S3 struct {
+	// Endpoint is the URL of the object store, https://s3.<Region>.amazonaws.com when empty
+	Endpoint string
+	// Region is the region requests are signed for, us-east-1 when empty
+	Region string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set with temporary credentials
+	SessionToken string
+
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+}
+
+// S3FromEnv returns an S3 client for endpoint with the credentials and region of the
+// standard AWS_ environment variables
+func S3FromEnv(endpoint string) *S3 {
+	return &S3{
+		Endpoint:        endpoint,
+		Region:          cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION")),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// ParseS3URL splits an s3://bucket/key URL, reporting whether location is one
+func ParseS3URL(location string) (bucket, key string, ok bool) {
+	rest, found := strings.CutPrefix(location, "s3://")
+	if !found {
+		return "", "", false
+	}
+	bucket, key, _ = strings.Cut(rest, "/")
+	return bucket, key, bucket != "" && key != ""
+}
+
+// Put uploads data as the object key of bucket
+func (s *S3) Put(ctx context.Context, bucket, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, bucket, key, data)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, bucket, key)
+	}
+	return nil
+}
+
+// Get downloads the object key of bucket
+func (s *S3) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3Error(resp, bucket, key)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (s *S3) do(ctx context.Context, method, bucket, key string, body []byte) (*http.Response, error) {
+	region := cmp.Or(s.Region, "us-east-1")
+	endpoint := strings.TrimSuffix(cmp.Or(s.Endpoint, "https://s3."+region+".amazonaws.com"), "/")
+	req, err := http.NewRequestWithContext(ctx, method, endpoint+"/"+s3Escape(bucket)+"/"+s3Escape(key), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", time.Now().UTC().Format("20060102T150405Z"))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	s.sign(req, region, payloadHash)
+	return cmp.Or(s.HTTPClient, http.DefaultClient).Do(req)
+}
+
+// sign adds the Authorization header of a Signature Version 4 request, signing the host
+// and every header set on req. The X-Amz-Date header must be set
+func (s *S3) sign(req *http.Request, region, payloadHash string) {
+	amzDate := req.Header.Get("X-Amz-Date")
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes a path the way Signature Version 4 expects: every byte but
+// unreserved characters and slashes
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3Error reports a failed request with the error message of the object store
+func s3Error(resp *http.Response, bucket, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("s3://%s/%s: %s: %s", bucket, key, resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,112 @@
+// Package cdi uploads disk images into the PVCs of DataVolumes through the CDI upload
+// proxy
+package cdi
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=upload.cdi.kubevirt.io,resources=uploadtokenrequests,verbs=create
+
+const (
+	// Namespace is where CDI and its upload proxy run
+	Namespace = "cdi"
+	// UploadProxyService is the Service of the CDI upload proxy
+	UploadProxyService = "cdi-uploadproxy"
+	// UploadProxyCABundle is the ConfigMap holding the CA that signs the certificate of
+	// the upload proxy
+	UploadProxyCABundle = "cdi-uploadproxy-signer-bundle"
+)
+
+// UploadToken requests a token from CDI allowing one upload into the PVC namespace/name
+func UploadToken(ctx context.Context, c client.Client, namespace, name string) (string, error) {
+	request := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "upload.cdi.kubevirt.io/v1beta1",
+		"kind":       "UploadTokenRequest",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"pvcName": name,
+		},
+	}}
+	if err := c.Create(ctx, request); err != nil {
+		return "", err
+	}
+	token, _, _ := unstructured.NestedString(request.Object, "status", "token")
+	if token == "" {
+		return "", fmt.Errorf("CDI returned no upload token for %s/%s", namespace, name)
+	}
+	return token, nil
+}
+
+// UploadProxy returns the URL of the CDI upload proxy and a client trusting the CA in
+// CDI's signer bundle. Without proxyURL the proxy is reached at the cluster IP of its
+// Service, which works from the nodes as well as from pods, and its certificate is
+// verified for the Service's DNS name
+func UploadProxy(ctx context.Context, c client.Client, proxyURL string) (string, *http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	bundle := &corev1.ConfigMap{}
+	err := c.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: UploadProxyCABundle}, bundle)
+	switch {
+	case err == nil:
+		pool := x509.NewCertPool()
+		if pool.AppendCertsFromPEM([]byte(bundle.Data["ca-bundle.crt"])) {
+			tlsConfig.RootCAs = pool
+		}
+	case !apierrors.IsNotFound(err):
+		return "", nil, err
+	}
+
+	if proxyURL == "" {
+		service := &corev1.Service{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: Namespace, Name: UploadProxyService}, service); err != nil {
+			return "", nil, err
+		}
+		if len(service.Spec.Ports) == 0 || service.Spec.ClusterIP == "" {
+			return "", nil, fmt.Errorf("service %s/%s has no cluster IP and port", Namespace, UploadProxyService)
+		}
+		proxyURL = "https://" + net.JoinHostPort(service.Spec.ClusterIP, strconv.Itoa(int(service.Spec.Ports[0].Port)))
+		tlsConfig.ServerName = UploadProxyService + "." + Namespace + ".svc"
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return proxyURL, &http.Client{Transport: transport}, nil
+}
+
+// Upload streams a qcow2 or raw disk image, optionally gzipped, of size bytes (-1 when
+// unknown) to the upload proxy at proxyURL with an upload token
+func Upload(ctx context.Context, httpClient *http.Client, proxyURL, token string, body io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(proxyURL, "/")+"/v1beta1/upload", body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
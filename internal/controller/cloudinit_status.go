@@ -0,0 +1,239 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// vmCloudInitCondition reports whether cloud-init finished in the guest
+const vmCloudInitCondition = "CloudInitComplete"
+
+// CloudInitStatusSource reads the cloud-init status of a running guest: "running",
+// "done" or "error" as printed by "cloud-init status", or "" when unknown
+type CloudInitStatusSource interface {
+	CloudInitStatus(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (string, error)
+}
+
+const (
+	// cloudInitResultPath is written by cloud-init in the guest once it has finished,
+	// listing the errors it ran into
+	cloudInitResultPath = "/run/cloud-init/result.json"
+
+	// launcherComputeContainer is the container of a virt-launcher pod running libvirt
+	launcherComputeContainer = "compute"
+
+	// maxCloudInitResult bounds how much of cloudInitResultPath is read
+	maxCloudInitResult = 64 << 10
+)
+
+// +kubebuilder:rbac:groups="",resources=pods/exec,verbs=create
+
+// GuestAgentCloudInitStatus reads cloudInitResultPath in the guest through the QEMU guest
+// agent, which virsh reaches from the compute container of the VM's virt-launcher pod.
+// The guest is running cloud-init while the file does not exist. Guests whose agent
+// does not allow reading files fall back to the status reported through the API in
+// the llmcloud.io/cloud-init-status annotation
+type GuestAgentCloudInitStatus struct {
+	// Exec runs command in the compute container of pod and returns its output
+	Exec func(ctx context.Context, namespace, pod string, command []string) ([]byte, error)
+}
+
+// NewGuestAgentCloudInitStatus returns a GuestAgentCloudInitStatus running its commands
+// in virt-launcher pods through the Kubernetes API of config
+func NewGuestAgentCloudInitStatus(config *rest.Config) (*GuestAgentCloudInitStatus, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Kubernetes API client: %w", err)
+	}
+	return &GuestAgentCloudInitStatus{
+		Exec: func(ctx context.Context, namespace, pod string, command []string) ([]byte, error) {
+			req := clientset.CoreV1().RESTClient().Post().
+				Namespace(namespace).Resource("pods").Name(pod).SubResource("exec").
+				VersionedParams(&corev1.PodExecOptions{
+					Container: launcherComputeContainer,
+					Command:   command,
+					Stdout:    true,
+					Stderr:    true,
+				}, scheme.ParameterCodec)
+			executor, err := remotecommand.NewSPDYExecutor(config, http.MethodPost, req.URL())
+			if err != nil {
+				return nil, err
+			}
+			var stdout, stderr bytes.Buffer
+			if err := executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr}); err != nil {
+				return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+			}
+			return stdout.Bytes(), nil
+		},
+	}, nil
+}
+
+// CloudInitStatus implements CloudInitStatusSource
+func (a *GuestAgentCloudInitStatus) CloudInitStatus(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (string, error) {
+	if vm.Status.LauncherPod == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, guestAgentTimeout)
+	defer cancel()
+
+	result, err := a.readResult(ctx, vm)
+	if errors.Is(err, errNoCloudInitResult) {
+		return "running", nil
+	}
+	if err != nil {
+		if reported := vm.Annotations[llmcloudv1alpha1.CloudInitStatusAnnotation]; reported != "" {
+			return reported, nil
+		}
+		return "", err
+	}
+
+	var parsed struct {
+		V1 struct {
+			Errors []interface{} `json:"errors"`
+		} `json:"v1"`
+	}
+	if err := json.Unmarshal(result, &parsed); err != nil {
+		return "", fmt.Errorf("invalid %s: %w", cloudInitResultPath, err)
+	}
+	if len(parsed.V1.Errors) > 0 {
+		return "error", nil
+	}
+	return "done", nil
+}
+
+// errNoCloudInitResult is returned by readResult while cloudInitResultPath does not exist
+var errNoCloudInitResult = errors.New("cloud-init has not finished")
+
+// readResult returns the contents of cloudInitResultPath in vm's guest
+func (a *GuestAgentCloudInitStatus) readResult(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) ([]byte, error) {
+	var handle int
+	err := a.agentCommand(ctx, vm, "guest-file-open", map[string]interface{}{"path": cloudInitResultPath, "mode": "r"}, &handle)
+	if err != nil {
+		if strings.Contains(err.Error(), "No such file or directory") {
+			return nil, errNoCloudInitResult
+		}
+		return nil, err
+	}
+	defer func() {
+		if err := a.agentCommand(ctx, vm, "guest-file-close", map[string]interface{}{"handle": handle}, nil); err != nil {
+			logf.FromContext(ctx).Error(err, "Failed to close file in guest", "vm", vm.Name, "path", cloudInitResultPath)
+		}
+	}()
+
+	var read struct {
+		Buffer string `json:"buf-b64"`
+	}
+	if err := a.agentCommand(ctx, vm, "guest-file-read", map[string]interface{}{"handle": handle, "count": maxCloudInitResult}, &read); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(read.Buffer)
+}
+
+// agentCommand runs the guest agent command execute with arguments in vm's guest,
+// decoding what it returns into result unless that is nil
+func (a *GuestAgentCloudInitStatus) agentCommand(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, execute string, arguments map[string]interface{}, result interface{}) error {
+	command, err := json.Marshal(map[string]interface{}{"execute": execute, "arguments": arguments})
+	if err != nil {
+		return err
+	}
+	// libvirt names the domain of a KubeVirt VM <namespace>_<name>
+	out, err := a.Exec(ctx, vm.Namespace, vm.Status.LauncherPod,
+		[]string{"virsh", "qemu-agent-command", vm.Namespace + "_" + vm.Name, string(command)})
+	if err != nil {
+		return fmt.Errorf("guest agent command %s failed: %w", execute, err)
+	}
+	if result == nil {
+		return nil
+	}
+	var response struct {
+		Return json.RawMessage `json:"return"`
+	}
+	if err := json.Unmarshal(out, &response); err != nil {
+		return fmt.Errorf("invalid response to guest agent command %s: %w", execute, err)
+	}
+	return json.Unmarshal(response.Return, result)
+}
+
+// applyCloudInitStatus records the guest's cloud-init status in vm's status once the
+// guest agent is connected. Nothing is checked after cloud-init has succeeded or failed
+func (r *VirtualMachineReconciler) applyCloudInitStatus(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, vmiStatus map[string]interface{}) {
+	if r.CloudInit == nil || !guestAgentConnected(vmiStatus) {
+		return
+	}
+	if cond := meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition); cond != nil &&
+		(cond.Reason == "Succeeded" || cond.Reason == "Failed") {
+		return
+	}
+
+	status, err := r.CloudInit.CloudInitStatus(ctx, vm)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, "Failed to read cloud-init status", "vm", vm.Name)
+		return
+	}
+
+	condition := metav1.Condition{
+		Type:               vmCloudInitCondition,
+		Status:             metav1.ConditionFalse,
+		ObservedGeneration: vm.Generation,
+	}
+	switch status {
+	case "done":
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "Succeeded"
+		condition.Message = "cloud-init finished successfully"
+	case "error":
+		condition.Reason = "Failed"
+		condition.Message = "cloud-init reported an error, check /var/log/cloud-init.log in the guest"
+	case "running":
+		condition.Reason = "Running"
+		condition.Message = "cloud-init is still running"
+	default:
+		return
+	}
+	vm.Status.CloudInitComplete = status == "done"
+	meta.SetStatusCondition(&vm.Status.Conditions, condition)
+}
+
+// guestAgentConnected reports whether a VMI status has the AgentConnected condition
+func guestAgentConnected(vmiStatus map[string]interface{}) bool {
+	conditions, _ := vmiStatus["conditions"].([]interface{})
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]interface{}); ok && cond["type"] == "AgentConnected" {
+			return cond["status"] == "True"
+		}
+	}
+	return false
+}
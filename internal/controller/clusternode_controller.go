@@ -0,0 +1,524 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+	"github.com/rusik69/llmcloud-operator/internal/sshexec"
+)
+
+// k0sInstalled succeeds on a host where k0s is installed as a service
+const k0sInstalled = `[ -n "$(ls /etc/systemd/system/k0s*.service /etc/init.d/k0s* 2>/dev/null)" ]`
+
+var (
+	// clusterNodePollInterval is how often a node that is joining or draining is checked
+	clusterNodePollInterval = 10 * time.Second
+	// clusterNodeRetryInterval is how long a failed step waits before it is retried
+	clusterNodeRetryInterval = time.Minute
+	// clusterNodeJoinTimeout is how long a joined host may take to register its node
+	// before joining it fails, and is retried
+	clusterNodeJoinTimeout = 10 * time.Minute
+)
+
+// NodeShell runs commands on a host
+type NodeShell interface {
+	CombinedOutput(command string) ([]byte, error)
+	Close() error
+}
+
+// ClusterNodeReconciler joins the hosts of ClusterNodes to the k0s cluster over SSH, and
+// drains and removes their nodes again, as their desired state says. Every step checks
+// whether it was already done, so an interrupted step is simply run again
+type ClusterNodeReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// SSH logs in to the hosts, with the keys and password of their credentials secret
+	// when they have one
+	SSH sshexec.Config
+	// JoinToken creates a k0s token to join a host with role. Defaults to K0sJoinToken
+	JoinToken func(ctx context.Context, role string) (string, error)
+	// Dial connects to a host. Defaults to sshexec.Dial
+	Dial func(destination string, config sshexec.Config) (NodeShell, error)
+}
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=clusternodes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=clusternodes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get;list;watch;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/eviction,verbs=create
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// Reconcile moves a ClusterNode one step towards its desired state, requeueing while a
+// node joins or drains and after a step fails
+func (r *ClusterNodeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	cn := &llmcloudv1alpha1.ClusterNode{}
+	if err := r.Get(ctx, req.NamespacedName, cn); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// A failed step is retried after clusterNodeRetryInterval, unless the spec changed
+	if cn.Status.Phase == llmcloudv1alpha1.ClusterNodePhaseFailed && cn.Status.ObservedGeneration == cn.Generation {
+		if wait := time.Until(transitionTime(cn).Add(clusterNodeRetryInterval)); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	nodeName := cn.KubernetesNodeName()
+	var phase, message string
+	var err error
+	switch cn.DesiredState() {
+	case llmcloudv1alpha1.ClusterNodeStateJoined:
+		phase, message, err = r.join(ctx, cn, nodeName)
+	case llmcloudv1alpha1.ClusterNodeStateDrained:
+		phase, message, err = r.drain(ctx, cn, nodeName)
+	case llmcloudv1alpha1.ClusterNodeStateRemoved:
+		phase, message, err = r.remove(ctx, cn, nodeName)
+	default:
+		err = fmt.Errorf("unknown state %q", cn.Spec.State)
+	}
+	if err != nil {
+		logger.Error(err, "Node step failed", "clusterNode", cn.Name, "node", nodeName)
+		phase, message = llmcloudv1alpha1.ClusterNodePhaseFailed, err.Error()
+	}
+	if err := r.setStatus(ctx, cn, nodeName, phase, message); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	switch phase {
+	case llmcloudv1alpha1.ClusterNodePhaseFailed:
+		return ctrl.Result{RequeueAfter: clusterNodeRetryInterval}, nil
+	case llmcloudv1alpha1.ClusterNodePhaseJoining, llmcloudv1alpha1.ClusterNodePhaseDraining,
+		llmcloudv1alpha1.ClusterNodePhaseRemoving:
+		return ctrl.Result{RequeueAfter: clusterNodePollInterval}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// transitionTime is when the phase of cn last changed, or its creation time
+func transitionTime(cn *llmcloudv1alpha1.ClusterNode) time.Time {
+	if cn.Status.LastTransitionTime != nil {
+		return cn.Status.LastTransitionTime.Time
+	}
+	return cn.CreationTimestamp.Time
+}
+
+// setStatus records the phase reached, if anything changed
+func (r *ClusterNodeReconciler) setStatus(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, nodeName, phase, message string) error {
+	status := &cn.Status
+	// The VMs stopped by an earlier drain are only reported until the node is back
+	clearStopped := phase == llmcloudv1alpha1.ClusterNodePhaseJoined && status.StoppedVMs != nil
+	if status.Phase == phase && status.NodeName == nodeName && status.Message == message &&
+		status.ObservedGeneration == cn.Generation && !clearStopped {
+		return nil
+	}
+	if clearStopped {
+		status.StoppedVMs = nil
+	}
+	if status.Phase != phase {
+		now := metav1.Now()
+		status.LastTransitionTime = &now
+	}
+	status.Phase = phase
+	status.NodeName = nodeName
+	status.Message = message
+	status.ObservedGeneration = cn.Generation
+	return r.Status().Update(ctx, cn)
+}
+
+// join joins the host to the cluster unless its node is registered, and waits for the
+// node to be Ready. A node cordoned by an earlier drain or removal is made schedulable
+// again
+func (r *ClusterNodeReconciler) join(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, nodeName string) (string, string, error) {
+	node := &corev1.Node{}
+	err := r.Get(ctx, client.ObjectKey{Name: nodeName}, node)
+	if err == nil {
+		switch cn.Status.Phase {
+		case llmcloudv1alpha1.ClusterNodePhaseDraining, llmcloudv1alpha1.ClusterNodePhaseDrained,
+			llmcloudv1alpha1.ClusterNodePhaseRemoving:
+			if err := r.setUnschedulable(ctx, node, false); err != nil {
+				return "", "", err
+			}
+		}
+		if !nodeReady(node) {
+			return llmcloudv1alpha1.ClusterNodePhaseJoining, fmt.Sprintf("Waiting for node %s to be Ready", nodeName), nil
+		}
+		return llmcloudv1alpha1.ClusterNodePhaseJoined, "", nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return "", "", err
+	}
+
+	waiting := fmt.Sprintf("Waiting for node %s to register", nodeName)
+	if cn.Status.Phase == llmcloudv1alpha1.ClusterNodePhaseJoining && cn.Status.ObservedGeneration == cn.Generation {
+		if time.Since(transitionTime(cn)) < clusterNodeJoinTimeout {
+			return llmcloudv1alpha1.ClusterNodePhaseJoining, waiting, nil
+		}
+		return "", "", fmt.Errorf("node %s did not register within %s", nodeName, clusterNodeJoinTimeout)
+	}
+	if err := r.joinHost(ctx, cn); err != nil {
+		return "", "", err
+	}
+	return llmcloudv1alpha1.ClusterNodePhaseJoining, waiting, nil
+}
+
+// nodeReady reports whether the node's Ready condition is true
+func nodeReady(node *corev1.Node) bool {
+	for _, c := range node.Status.Conditions {
+		if c.Type == corev1.NodeReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// joinHost installs k0s on the host and joins it to the cluster with its role. Steps the
+// host is already past are skipped
+func (r *ClusterNodeReconciler) joinHost(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode) error {
+	role := cmp.Or(cn.Spec.Role, llmcloudv1alpha1.ClusterNodeRoleWorker)
+	joinToken := r.JoinToken
+	if joinToken == nil {
+		joinToken = K0sJoinToken
+	}
+	token, err := joinToken(ctx, role)
+	if err != nil {
+		return fmt.Errorf("failed to create a k0s join token: %w", err)
+	}
+	return r.runSteps(ctx, cn, []nodeStep{
+		{"install k0s", "command -v k0s >/dev/null 2>&1 || curl -sSLf https://get.k0s.sh | sudo sh"},
+		{"join cluster", fmt.Sprintf("%s || sudo k0s install %s --token=%s", k0sInstalled, role, sshexec.Quote(strings.TrimSpace(token)))},
+		{"start k0s", "sudo k0s status >/dev/null 2>&1 || sudo k0s start"},
+	})
+}
+
+// resetHost stops k0s on the host and removes it from the host, if it is installed.
+// Controllers leave etcd first
+func (r *ClusterNodeReconciler) resetHost(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode) error {
+	var steps []nodeStep
+	if cn.Spec.Role == llmcloudv1alpha1.ClusterNodeRoleController {
+		steps = append(steps, nodeStep{"leave etcd", "! " + k0sInstalled + " || sudo k0s etcd leave || true"})
+	}
+	steps = append(steps, nodeStep{"reset k0s", "! " + k0sInstalled + " || { sudo k0s stop || true; sudo k0s reset; }"})
+	return r.runSteps(ctx, cn, steps)
+}
+
+// nodeStep is a command run on a host
+type nodeStep struct{ action, command string }
+
+// runSteps logs in to the host of cn and runs steps in order, stopping at the first
+// that fails
+func (r *ClusterNodeReconciler) runSteps(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, steps []nodeStep) error {
+	config, err := r.sshConfig(ctx, cn)
+	if err != nil {
+		return err
+	}
+	dial := r.Dial
+	if dial == nil {
+		dial = func(destination string, config sshexec.Config) (NodeShell, error) {
+			return sshexec.Dial(destination, config)
+		}
+	}
+	shell, err := dial(cn.Spec.Host, config)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = shell.Close() }()
+
+	for _, step := range steps {
+		log.FromContext(ctx).Info("Running node step", "clusterNode", cn.Name, "step", step.action)
+		if output, err := shell.CombinedOutput(step.command); err != nil {
+			return fmt.Errorf("failed to %s: %v, output: %s", step.action, err, strings.TrimSpace(string(output)))
+		}
+	}
+	return nil
+}
+
+// sshConfig is how to log in to the host of cn: SSH with the private key and password
+// of its credentials secret, if it has one
+func (r *ClusterNodeReconciler) sshConfig(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode) (sshexec.Config, error) {
+	config := r.SSH
+	ref := cn.Spec.CredentialsSecret
+	if ref == nil {
+		return config, nil
+	}
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, secret); err != nil {
+		return config, fmt.Errorf("failed to get credentials secret %s/%s: %w", ref.Namespace, ref.Name, err)
+	}
+	key, password := secret.Data[llmcloudv1alpha1.ClusterNodePrivateKeyKey], secret.Data[llmcloudv1alpha1.ClusterNodePasswordKey]
+	if len(key) == 0 && len(password) == 0 {
+		return config, fmt.Errorf("credentials secret %s/%s has neither %s nor %s", ref.Namespace, ref.Name,
+			llmcloudv1alpha1.ClusterNodePrivateKeyKey, llmcloudv1alpha1.ClusterNodePasswordKey)
+	}
+	config.KeyFiles, config.PrivateKeys, config.Password = nil, nil, string(password)
+	if len(key) > 0 {
+		config.PrivateKeys = [][]byte{key}
+	}
+	return config, nil
+}
+
+// K0sJoinToken creates a token to join a host with role using the k0s of this host,
+// which must be a controller of the cluster
+func K0sJoinToken(ctx context.Context, role string) (string, error) {
+	output, err := exec.CommandContext(ctx, "sudo", "k0s", "token", "create", "--role="+role).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("%v, output: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// drain cordons the node, migrates its VMs away and evicts its pods, reporting Draining
+// until nothing is left on it. A node that is not in the cluster is drained
+func (r *ClusterNodeReconciler) drain(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, nodeName string) (string, string, error) {
+	node := &corev1.Node{}
+	if err := r.Get(ctx, client.ObjectKey{Name: nodeName}, node); err != nil {
+		if apierrors.IsNotFound(err) {
+			return llmcloudv1alpha1.ClusterNodePhaseDrained, fmt.Sprintf("Node %s is not in the cluster", nodeName), nil
+		}
+		return "", "", err
+	}
+	// Cordon the node first, so no VM is migrated back onto it
+	if err := r.setUnschedulable(ctx, node, true); err != nil {
+		return "", "", err
+	}
+
+	vms, err := r.migrateVMsOffNode(ctx, cn, nodeName)
+	if err != nil {
+		return "", "", err
+	}
+	if len(vms) > 0 {
+		return llmcloudv1alpha1.ClusterNodePhaseDraining, "Migrating VMs: " + strings.Join(vms, ", "), nil
+	}
+	pods, err := r.evictPods(ctx, nodeName)
+	if err != nil {
+		return "", "", err
+	}
+	if len(pods) > 0 {
+		return llmcloudv1alpha1.ClusterNodePhaseDraining, "Evicting pods: " + strings.Join(pods, ", "), nil
+	}
+	return llmcloudv1alpha1.ClusterNodePhaseDrained, "", nil
+}
+
+// remove drains the node, resets k0s on the host so that its kubelet does not register
+// the node again, and deletes the node
+func (r *ClusterNodeReconciler) remove(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, nodeName string) (string, string, error) {
+	if cn.Status.Phase == llmcloudv1alpha1.ClusterNodePhaseRemoved && cn.Status.ObservedGeneration == cn.Generation {
+		return llmcloudv1alpha1.ClusterNodePhaseRemoved, "", nil
+	}
+	phase, message, err := r.drain(ctx, cn, nodeName)
+	if err != nil || phase == llmcloudv1alpha1.ClusterNodePhaseDraining {
+		return llmcloudv1alpha1.ClusterNodePhaseRemoving, message, err
+	}
+	if cn.Spec.Host != "" {
+		if err := r.resetHost(ctx, cn); err != nil {
+			return "", "", err
+		}
+	}
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: nodeName}}
+	if err := r.Delete(ctx, node); err != nil && !apierrors.IsNotFound(err) {
+		return "", "", fmt.Errorf("failed to delete node: %w", err)
+	}
+	return llmcloudv1alpha1.ClusterNodePhaseRemoved, "", nil
+}
+
+// setUnschedulable cordons or uncordons the node
+func (r *ClusterNodeReconciler) setUnschedulable(ctx context.Context, node *corev1.Node, unschedulable bool) error {
+	if node.Spec.Unschedulable == unschedulable {
+		return nil
+	}
+	patch := client.MergeFrom(node.DeepCopy())
+	node.Spec.Unschedulable = unschedulable
+	if err := r.Patch(ctx, node, patch); err != nil {
+		return fmt.Errorf("failed to cordon node: %w", err)
+	}
+	return nil
+}
+
+// migrateVMsOffNode requests a live migration of every VM running on nodeName, and
+// returns the VMs still on it. VMs that KubeVirt cannot live-migrate, and those still on
+// the node once the drain has taken its DrainTimeout, are stopped instead and recorded
+// in the status of cn
+func (r *ClusterNodeReconciler) migrateVMsOffNode(ctx context.Context, cn *llmcloudv1alpha1.ClusterNode, nodeName string) ([]string, error) {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := r.List(ctx, &vms); err != nil {
+		return nil, err
+	}
+	timedOut := time.Since(drainStartTime(cn)) >= cn.Spec.EffectiveDrainTimeout()
+	var remaining, stopped []string
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if vm.Status.Node != nodeName {
+			continue
+		}
+		name := vm.Namespace + "/" + vm.Name
+		remaining = append(remaining, name)
+		if vm.Spec.RunStrategy == "Halted" {
+			continue
+		}
+		migratable, err := r.liveMigratable(ctx, vm)
+		if err != nil {
+			return nil, err
+		}
+		if timedOut || !migratable {
+			vm.Spec.RunStrategy = "Halted"
+			if err := r.Update(ctx, vm); err != nil {
+				return nil, fmt.Errorf("failed to stop VM %s: %w", name, err)
+			}
+			log.FromContext(ctx).Info("Stopped VM that could not migrate off node", "node", nodeName, "namespace", vm.Namespace,
+				"name", vm.Name, "liveMigratable", migratable)
+			stopped = append(stopped, name)
+			continue
+		}
+		if vm.Annotations[VMMigrateAnnotation] == "true" {
+			continue
+		}
+		if vm.Annotations == nil {
+			vm.Annotations = make(map[string]string)
+		}
+		vm.Annotations[VMMigrateAnnotation] = "true"
+		if err := r.Update(ctx, vm); err != nil {
+			return nil, fmt.Errorf("failed to migrate VM %s/%s: %w", vm.Namespace, vm.Name, err)
+		}
+		log.FromContext(ctx).Info("Migrating VM off node", "node", nodeName, "namespace", vm.Namespace, "name", vm.Name)
+	}
+	if len(stopped) > 0 {
+		for _, name := range stopped {
+			if !slices.Contains(cn.Status.StoppedVMs, name) {
+				cn.Status.StoppedVMs = append(cn.Status.StoppedVMs, name)
+			}
+		}
+		if err := r.Status().Update(ctx, cn); err != nil {
+			return nil, err
+		}
+	}
+	return remaining, nil
+}
+
+// drainStartTime is when the current drain or removal of cn started: the transition to
+// its phase, or now when it has not started yet
+func drainStartTime(cn *llmcloudv1alpha1.ClusterNode) time.Time {
+	switch cn.Status.Phase {
+	case llmcloudv1alpha1.ClusterNodePhaseDraining, llmcloudv1alpha1.ClusterNodePhaseRemoving:
+		return transitionTime(cn)
+	}
+	return time.Now()
+}
+
+// liveMigratable reports whether KubeVirt can live-migrate the instance of vm, as its
+// LiveMigratable condition says. An instance without the condition, or a VM without an
+// instance, counts as migratable
+func (r *ClusterNodeReconciler) liveMigratable(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (bool, error) {
+	vmi := &unstructured.Unstructured{}
+	vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+	if err := r.Get(ctx, client.ObjectKeyFromObject(vm), vmi); err != nil {
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	conditions, _, _ := unstructured.NestedSlice(vmi.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, _ := c.(map[string]interface{})
+		if condition["type"] == "LiveMigratable" {
+			return condition["status"] != string(metav1.ConditionFalse), nil
+		}
+	}
+	return true, nil
+}
+
+// evictPods evicts the pods on nodeName, except DaemonSet and mirror pods, like kubectl
+// drain --ignore-daemonsets --delete-emptydir-data --force, and returns the pods still
+// on it. Evictions a PodDisruptionBudget refuses are retried by the next call
+func (r *ClusterNodeReconciler) evictPods(ctx context.Context, nodeName string) ([]string, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods); err != nil {
+		return nil, err
+	}
+	var remaining []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !drainable(pod, nodeName) {
+			continue
+		}
+		remaining = append(remaining, pod.Namespace+"/"+pod.Name)
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		eviction := &policyv1.Eviction{ObjectMeta: metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name}}
+		err := r.SubResource("eviction").Create(ctx, pod, eviction)
+		if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsTooManyRequests(err) {
+			return nil, fmt.Errorf("failed to evict pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+	}
+	return remaining, nil
+}
+
+// drainable reports whether draining nodeName evicts pod: any pod on it except mirror
+// pods, which the kubelet owns, and DaemonSet pods, which would be recreated there
+func drainable(pod *corev1.Pod, nodeName string) bool {
+	if pod.Spec.NodeName != nodeName {
+		return false
+	}
+	if _, mirror := pod.Annotations[corev1.MirrorPodAnnotationKey]; mirror {
+		return false
+	}
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "DaemonSet" {
+			return false
+		}
+	}
+	return true
+}
+
+// SetupWithManager sets up the controller with the Manager. Status updates do not
+// trigger a reconcile: progress is polled while a step is in flight
+func (r *ClusterNodeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&llmcloudv1alpha1.ClusterNode{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(metrics.Reconciler("ClusterNode", r))
+}
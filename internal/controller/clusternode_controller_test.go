@@ -0,0 +1,333 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/sshexec"
+)
+
+// fakeShell records the commands run on a host
+type fakeShell struct {
+	commands []string
+}
+
+func (s *fakeShell) CombinedOutput(command string) ([]byte, error) {
+	s.commands = append(s.commands, command)
+	return nil, nil
+}
+
+func (s *fakeShell) Close() error { return nil }
+
+var _ = Describe("ClusterNode Controller", func() {
+	var (
+		c      client.Client
+		r      *ClusterNodeReconciler
+		shell  *fakeShell
+		dialed []sshexec.Config
+		key    = types.NamespacedName{Name: "gpu-1"}
+	)
+
+	newNode := func(name string, ready bool) *corev1.Node {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status:     corev1.NodeStatus{Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: status}}},
+		}
+	}
+	newPod := func(namespace, name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	setup := func(funcs interceptor.Funcs, objs ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+		Expect(policyv1.AddToScheme(testScheme)).To(Succeed())
+		Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).
+			WithStatusSubresource(&llmcloudv1alpha1.ClusterNode{}).WithInterceptorFuncs(funcs).Build()
+		shell, dialed = &fakeShell{}, nil
+		r = &ClusterNodeReconciler{
+			Client: c,
+			Scheme: testScheme,
+			SSH:    sshexec.Config{User: "admin", KeyFiles: []string{"/etc/llmcloud/id_ed25519"}},
+			JoinToken: func(_ context.Context, role string) (string, error) {
+				return "token-" + role + "\n", nil
+			},
+			Dial: func(destination string, config sshexec.Config) (NodeShell, error) {
+				Expect(destination).To(Equal("ubuntu@10.0.0.21"))
+				dialed = append(dialed, config)
+				return shell, nil
+			},
+		}
+	}
+	reconcileNode := func() (reconcile.Result, *llmcloudv1alpha1.ClusterNode) {
+		result, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		cn := &llmcloudv1alpha1.ClusterNode{}
+		Expect(c.Get(context.Background(), key, cn)).To(Succeed())
+		return result, cn
+	}
+	clusterNode := func(state string) *llmcloudv1alpha1.ClusterNode {
+		return &llmcloudv1alpha1.ClusterNode{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpu-1"},
+			Spec:       llmcloudv1alpha1.ClusterNodeSpec{Host: "ubuntu@10.0.0.21", NodeName: "gpu-1", State: state},
+		}
+	}
+
+	It("should join the host once and wait for its node to be Ready", func() {
+		setup(interceptor.Funcs{}, clusterNode(""))
+
+		result, cn := reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseJoining))
+		Expect(cn.Status.Message).To(ContainSubstring("to register"))
+		Expect(result.RequeueAfter).To(Equal(clusterNodePollInterval))
+		Expect(shell.commands).To(HaveLen(3))
+		Expect(shell.commands[1]).To(ContainSubstring("k0s install worker --token='token-worker'"))
+		Expect(dialed[0].KeyFiles).To(Equal([]string{"/etc/llmcloud/id_ed25519"}))
+
+		// Waiting for the node does not join the host again
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseJoining))
+		Expect(shell.commands).To(HaveLen(3))
+
+		Expect(c.Create(context.Background(), newNode("gpu-1", false))).To(Succeed())
+		_, cn = reconcileNode()
+		Expect(cn.Status.Message).To(ContainSubstring("to be Ready"))
+
+		node := &corev1.Node{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Name: "gpu-1"}, node)).To(Succeed())
+		node.Status.Conditions[0].Status = corev1.ConditionTrue
+		Expect(c.Status().Update(context.Background(), node)).To(Succeed())
+		result, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseJoined))
+		Expect(cn.Status.NodeName).To(Equal("gpu-1"))
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(shell.commands).To(HaveLen(3))
+	})
+
+	It("should log in with the credentials secret", func() {
+		cn := clusterNode("")
+		cn.Spec.CredentialsSecret = &corev1.SecretReference{Namespace: "llmcloud-system", Name: "gpu-1-ssh"}
+		setup(interceptor.Funcs{}, cn, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "llmcloud-system", Name: "gpu-1-ssh"},
+			Data:       map[string][]byte{"ssh-privatekey": []byte("PEM"), "password": []byte("s3cret")},
+		})
+
+		reconcileNode()
+		Expect(dialed).To(HaveLen(1))
+		Expect(dialed[0].KeyFiles).To(BeEmpty())
+		Expect(dialed[0].PrivateKeys).To(Equal([][]byte{[]byte("PEM")}))
+		Expect(dialed[0].Password).To(Equal("s3cret"))
+		Expect(dialed[0].User).To(Equal("admin"))
+	})
+
+	It("should report a failed step and retry it later", func() {
+		setup(interceptor.Funcs{}, clusterNode(""))
+		r.Dial = func(string, sshexec.Config) (NodeShell, error) {
+			return nil, errors.New("connection refused")
+		}
+
+		result, cn := reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseFailed))
+		Expect(cn.Status.Message).To(ContainSubstring("connection refused"))
+		Expect(result.RequeueAfter).To(Equal(clusterNodeRetryInterval))
+
+		// Until the retry interval passed, the step is not run again
+		r.Dial = func(string, sshexec.Config) (NodeShell, error) {
+			Fail("Expected the failed step not to be retried yet")
+			return nil, nil
+		}
+		result, _ = reconcileNode()
+		Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	})
+
+	It("should migrate VMs off the node, evict its pods and keep it cordoned", func() {
+		agent := newPod("kube-system", "agent", "gpu-1")
+		agent.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "agent", UID: "1"}}
+		evictions := 0
+		setup(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResource string, obj, subResourceObj client.Object, opts ...client.SubResourceCreateOption) error {
+				// A disruption budget refuses the first eviction
+				if evictions++; evictions == 1 {
+					return apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 1)
+				}
+				return c.SubResource(subResource).Create(ctx, obj, subResourceObj, opts...)
+			},
+		},
+			clusterNode(llmcloudv1alpha1.ClusterNodeStateDrained), newNode("gpu-1", true),
+			newPod("project-team", "web", "gpu-1"), agent, newPod("project-team", "db", "gpu-2"),
+			&llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-team"},
+				Status:     llmcloudv1alpha1.VirtualMachineStatus{Phase: "Running", Node: "gpu-1"},
+			},
+			&llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "project-team"},
+				Status:     llmcloudv1alpha1.VirtualMachineStatus{Phase: "Running", Node: "gpu-2"},
+			},
+		)
+
+		_, cn := reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseDraining))
+		Expect(cn.Status.Message).To(Equal("Migrating VMs: project-team/web"))
+		node := &corev1.Node{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Name: "gpu-1"}, node)).To(Succeed())
+		Expect(node.Spec.Unschedulable).To(BeTrue())
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: "web"}, vm)).To(Succeed())
+		Expect(vm.Annotations).To(HaveKeyWithValue(VMMigrateAnnotation, "true"))
+		db := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: "db"}, db)).To(Succeed())
+		Expect(db.Annotations).NotTo(HaveKey(VMMigrateAnnotation))
+
+		// Stand in for the VirtualMachine controller
+		vm.Status.Node = "gpu-2"
+		Expect(c.Update(context.Background(), vm)).To(Succeed())
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseDraining))
+		Expect(cn.Status.Message).To(Equal("Evicting pods: project-team/web"))
+
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseDraining))
+		Expect(evictions).To(Equal(2))
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseDrained))
+		for _, pod := range []struct {
+			key  client.ObjectKey
+			kept bool
+		}{
+			{client.ObjectKey{Namespace: "project-team", Name: "web"}, false},
+			{client.ObjectKey{Namespace: "kube-system", Name: "agent"}, true},
+			{client.ObjectKey{Namespace: "project-team", Name: "db"}, true},
+		} {
+			err := c.Get(context.Background(), pod.key, &corev1.Pod{})
+			Expect(err == nil).To(Equal(pod.kept), "pod %s", pod.key)
+		}
+
+		// Joining the node again makes it schedulable
+		cn.Spec.State = llmcloudv1alpha1.ClusterNodeStateJoined
+		Expect(c.Update(context.Background(), cn)).To(Succeed())
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseJoined))
+		Expect(c.Get(context.Background(), client.ObjectKey{Name: "gpu-1"}, node)).To(Succeed())
+		Expect(node.Spec.Unschedulable).To(BeFalse())
+		Expect(shell.commands).To(BeEmpty())
+	})
+
+	It("should stop VMs that cannot migrate or outlast the drain timeout", func() {
+		runningVM := func(name string) *llmcloudv1alpha1.VirtualMachine {
+			return &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "project-team"},
+				Spec:       llmcloudv1alpha1.VirtualMachineSpec{RunStrategy: "Always"},
+				Status:     llmcloudv1alpha1.VirtualMachineStatus{Phase: "Running", Node: "gpu-1"},
+			}
+		}
+		// A VM with a host device cannot be live-migrated
+		vmi := &unstructured.Unstructured{}
+		vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+		vmi.SetName("gpu")
+		vmi.SetNamespace("project-team")
+		Expect(unstructured.SetNestedSlice(vmi.Object, []interface{}{
+			map[string]interface{}{"type": "LiveMigratable", "status": "False", "reason": "HostDeviceNotLiveMigratable"},
+		}, "status", "conditions")).To(Succeed())
+		setup(interceptor.Funcs{}, clusterNode(llmcloudv1alpha1.ClusterNodeStateDrained), newNode("gpu-1", true),
+			runningVM("gpu"), runningVM("web"), vmi)
+		getVM := func(name string) *llmcloudv1alpha1.VirtualMachine {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: name}, vm)).To(Succeed())
+			return vm
+		}
+
+		_, cn := reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseDraining))
+		Expect(cn.Status.StoppedVMs).To(Equal([]string{"project-team/gpu"}))
+		Expect(getVM("gpu").Spec.RunStrategy).To(Equal("Halted"))
+		web := getVM("web")
+		Expect(web.Spec.RunStrategy).To(Equal("Always"))
+		Expect(web.Annotations).To(HaveKeyWithValue(VMMigrateAnnotation, "true"))
+
+		// The migration of web does not finish within the drain timeout
+		started := metav1.NewTime(time.Now().Add(-llmcloudv1alpha1.DefaultDrainTimeout))
+		cn.Status.LastTransitionTime = &started
+		Expect(c.Status().Update(context.Background(), cn)).To(Succeed())
+		_, cn = reconcileNode()
+		Expect(cn.Status.StoppedVMs).To(Equal([]string{"project-team/gpu", "project-team/web"}))
+		Expect(getVM("web").Spec.RunStrategy).To(Equal("Halted"))
+
+		// Joining the node again clears the report
+		cn.Spec.State = llmcloudv1alpha1.ClusterNodeStateJoined
+		Expect(c.Update(context.Background(), cn)).To(Succeed())
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseJoined))
+		Expect(cn.Status.StoppedVMs).To(BeEmpty())
+	})
+
+	It("should reset the host and delete its node when removed", func() {
+		cn := clusterNode(llmcloudv1alpha1.ClusterNodeStateRemoved)
+		cn.Spec.Role = llmcloudv1alpha1.ClusterNodeRoleController
+		setup(interceptor.Funcs{}, cn, newNode("gpu-1", true))
+
+		_, cn = reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseRemoved))
+		Expect(shell.commands).To(HaveLen(2))
+		Expect(shell.commands[0]).To(ContainSubstring("k0s etcd leave"))
+		Expect(shell.commands[1]).To(ContainSubstring("k0s reset"))
+		err := c.Get(context.Background(), client.ObjectKey{Name: "gpu-1"}, &corev1.Node{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// A removed node stays removed without logging in again
+		reconcileNode()
+		Expect(shell.commands).To(HaveLen(2))
+	})
+
+	It("should delete a node without a host without logging in", func() {
+		setup(interceptor.Funcs{}, &llmcloudv1alpha1.ClusterNode{
+			ObjectMeta: metav1.ObjectMeta{Name: "gpu-1"},
+			Spec:       llmcloudv1alpha1.ClusterNodeSpec{NodeName: "old-1", State: llmcloudv1alpha1.ClusterNodeStateRemoved},
+		}, newNode("old-1", false))
+
+		_, cn := reconcileNode()
+		Expect(cn.Status.Phase).To(Equal(llmcloudv1alpha1.ClusterNodePhaseRemoved))
+		Expect(dialed).To(BeEmpty())
+		err := c.Get(context.Background(), client.ObjectKey{Name: "old-1"}, &corev1.Node{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,99 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// vmPasswordLength is the length of the generated default user passwords
+const vmPasswordLength = 20
+
+// defaultVMUsers maps each OS to the default user of its cloud image, whose password
+// cloud-init sets
+var defaultVMUsers = map[string]string{
+	"ubuntu":  "ubuntu",
+	"fedora":  "fedora",
+	"debian":  "debian",
+	"centos":  "cloud-user",
+	"alpine":  "alpine",
+	"cirros":  "cirros",
+	"freebsd": "freebsd",
+}
+
+// DefaultVMUser returns the default user of the cloud image of os
+func DefaultVMUser(os string) string {
+	if user := defaultVMUsers[os]; user != "" {
+		return user
+	}
+	return os
+}
+
+// VMCredentialsSecretName is the Secret holding the default user's credentials of a VM
+func VMCredentialsSecretName(vm *llmcloudv1alpha1.VirtualMachine) string {
+	return vm.Name + "-credentials"
+}
+
+// HasGeneratedCredentials reports whether the controller generates the VM's cloud-config,
+// and with it a password for the default user. A custom cloud-init is left untouched
+func HasGeneratedCredentials(vm *llmcloudv1alpha1.VirtualMachine) bool {
+	return vm.Spec.CloudInit == "" && vm.Spec.CloudInitSecretRef == nil
+}
+
+// reconcileCredentialsSecret ensures the VM has a Secret owned by it with the username
+// and a random password of its default user, returning the password. The password is
+// generated once and kept, as cloud-init only sets it on the first boot. Nothing is
+// returned for a VM with a custom cloud-init
+func (r *VirtualMachineReconciler) reconcileCredentialsSecret(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (string, error) {
+	if !HasGeneratedCredentials(vm) {
+		return "", nil
+	}
+
+	username := DefaultVMUser(vm.Spec.OS)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: VMCredentialsSecretName(vm), Namespace: vm.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = map[string]string{"llmcloud.io/managed": "true"}
+		if secret.CreationTimestamp.IsZero() {
+			secret.Type = corev1.SecretTypeBasicAuth
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[corev1.BasicAuthUsernameKey] = []byte(username)
+		if len(secret.Data[corev1.BasicAuthPasswordKey]) == 0 {
+			password, err := auth.GeneratePassword(vmPasswordLength)
+			if err != nil {
+				return err
+			}
+			secret.Data[corev1.BasicAuthPasswordKey] = []byte(password)
+		}
+		return controllerutil.SetControllerReference(vm, secret, r.Scheme)
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(secret.Data[corev1.BasicAuthPasswordKey]), nil
+}
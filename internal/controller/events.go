@@ -0,0 +1,42 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+)
+
+// recordEvent records an event on obj. Reconcilers built without a recorder, as in
+// tests, record nothing
+func recordEvent(recorder record.EventRecorder, obj runtime.Object, eventType, reason, messageFmt string, args ...any) {
+	if recorder != nil {
+		recorder.Eventf(obj, eventType, reason, messageFmt, args...)
+	}
+}
+
+// recordReconcileError records a ReconcileFailed warning on obj for the error a
+// reconcile of it returned. A conflict only means the object was read stale and is
+// retried right away, so it is not worth an event
+func recordReconcileError(recorder record.EventRecorder, obj runtime.Object, err error) {
+	if err == nil || errors.IsConflict(err) {
+		return
+	}
+	recordEvent(recorder, obj, corev1.EventTypeWarning, "ReconcileFailed", "%v", err)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
+)
+
+const (
+	// expiryWarnedAnnotation records the time an object was warned it will be deleted
+	// at. It is deleted no earlier, and warned again if its TTL is extended past it
+	expiryWarnedAnnotation = "llmcloud.io/expiry-warned"
+
+	// defaultExpiryWarning is how long before expiring objects are warned about when
+	// ExpiryReconciler.Warning is unset
+	defaultExpiryWarning = time.Hour
+)
+
+// ExpiryReconciler deletes the objects of one kind with a TTL once it has passed since
+// they were created, recording an Expiring warning event on them Warning beforehand.
+// An object is never deleted without that warning, so one given a TTL it has already
+// outlived is deleted Warning after the TTL is set, not right away
+type ExpiryReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// NewObject returns an empty object of the kind to expire: a Project, a
+	// VirtualMachine or an LLMModel
+	NewObject func() client.Object
+
+	// Warning is how long before an object expires it is warned about
+	Warning time.Duration
+
+	Recorder record.EventRecorder
+
+	// Audit records the objects deleted, when set
+	Audit *audit.Recorder
+}
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=projects;virtualmachines;llmmodels,verbs=get;list;watch;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// objectTTL returns the TTL of obj, and the resource it is recorded as in the audit log
+func objectTTL(obj client.Object) (*metav1.Duration, string) {
+	switch o := obj.(type) {
+	case *llmcloudv1alpha1.Project:
+		return o.Spec.TTL, "projects"
+	case *llmcloudv1alpha1.VirtualMachine:
+		return o.Spec.TTL, "virtualmachines"
+	case *llmcloudv1alpha1.LLMModel:
+		return o.Spec.TTL, "llmmodels"
+	}
+	return nil, ""
+}
+
+// Reconcile warns about an object expiring within Warning, deletes one whose warned
+// deletion time has passed, and otherwise checks it again when either is due
+func (r *ExpiryReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	obj := r.NewObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !obj.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+	ttl, resource := objectTTL(obj)
+	expiresAt, ok := llmcloudv1alpha1.ExpiryTime(obj, ttl)
+	warnedAt, warned := expiryWarning(obj)
+	if !ok {
+		// A warning about a TTL that was removed must not hasten a TTL set later
+		if warned {
+			return ctrl.Result{}, r.setExpiryWarning(ctx, obj, "")
+		}
+		return ctrl.Result{}, nil
+	}
+	// A TTL extended past the warning is warned about again; the recorded time is
+	// only accurate to the second
+	if warned && warnedAt.Before(expiresAt.Truncate(time.Second)) {
+		warned = false
+	}
+
+	now := time.Now()
+	warning := r.Warning
+	if warning <= 0 {
+		warning = defaultExpiryWarning
+	}
+	if !warned {
+		warnAt := expiresAt.Add(-warning)
+		if now.Before(warnAt) {
+			return ctrl.Result{RequeueAfter: warnAt.Sub(now)}, nil
+		}
+		deleteAt := expiresAt
+		if grace := now.Add(warning); grace.After(deleteAt) {
+			deleteAt = grace
+		}
+		expiry := deleteAt.UTC().Format(time.RFC3339)
+		if err := r.setExpiryWarning(ctx, obj, expiry); err != nil {
+			return ctrl.Result{}, err
+		}
+		if r.Recorder != nil {
+			r.Recorder.Event(obj, corev1.EventTypeWarning, "Expiring",
+				fmt.Sprintf("Will be deleted at %s when its TTL of %s runs out", expiry, ttl.Duration))
+		}
+		return ctrl.Result{RequeueAfter: deleteAt.Sub(now)}, nil
+	}
+	if now.Before(warnedAt) {
+		return ctrl.Result{RequeueAfter: warnedAt.Sub(now)}, nil
+	}
+
+	log.Info("Deleting expired object", "resource", resource, "name", obj.GetName(), "ttl", ttl.Duration)
+	// The precondition keeps a replacement created under the same name
+	if err := r.Delete(ctx, obj, client.Preconditions{UID: ptr.To(obj.GetUID())}); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if r.Recorder != nil {
+		r.Recorder.Event(obj, corev1.EventTypeNormal, "Expired", fmt.Sprintf("Deleted after its TTL of %s", ttl.Duration))
+	}
+	if r.Audit != nil {
+		r.Audit.Record(ctx, llmcloudv1alpha1.AuditEventSpec{
+			User:      audit.OperatorUser,
+			Action:    "delete",
+			Resource:  resource,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		})
+	}
+	return ctrl.Result{}, nil
+}
+
+// expiryWarning returns the time obj was warned it will be deleted at, if it was
+func expiryWarning(obj client.Object) (time.Time, bool) {
+	value, ok := obj.GetAnnotations()[expiryWarnedAnnotation]
+	if !ok {
+		return time.Time{}, false
+	}
+	warnedAt, err := time.Parse(time.RFC3339, value)
+	return warnedAt, err == nil
+}
+
+// setExpiryWarning records the time obj was warned it will be deleted at, or removes
+// the record when expiry is empty
+func (r *ExpiryReconciler) setExpiryWarning(ctx context.Context, obj client.Object, expiry string) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if expiry == "" {
+		delete(annotations, expiryWarnedAnnotation)
+	} else {
+		annotations[expiryWarnedAnnotation] = expiry
+	}
+	obj.SetAnnotations(annotations)
+	return client.IgnoreNotFound(r.Patch(ctx, obj, patch))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ExpiryReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	obj := r.NewObject()
+	gvk, err := apiutil.GVKForObject(obj, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(obj).
+		Named(strings.ToLower(gvk.Kind) + "expiry").
+		Complete(r)
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("Expiry Controller", func() {
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "scratch", Namespace: "project-team"}
+
+	newExpiryReconciler := func(age, ttl time.Duration) (*ExpiryReconciler, *record.FakeRecorder) {
+		testScheme := runtime.NewScheme()
+		Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		vm := &llmcloudv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: key.Name, Namespace: key.Namespace,
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-age)),
+			},
+			Spec: llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", TTL: &metav1.Duration{Duration: ttl}},
+		}
+		c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(vm).Build()
+		recorder := record.NewFakeRecorder(10)
+		return &ExpiryReconciler{
+			Client:    c,
+			Scheme:    testScheme,
+			NewObject: func() client.Object { return &llmcloudv1alpha1.VirtualMachine{} },
+			Warning:   time.Hour,
+			Recorder:  recorder,
+		}, recorder
+	}
+
+	It("should check again when the warning is due", func() {
+		r, recorder := newExpiryReconciler(time.Hour, 8*time.Hour)
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", 6*time.Hour, time.Minute))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("should warn once about an object expiring soon", func() {
+		r, recorder := newExpiryReconciler(7*time.Hour+30*time.Minute, 8*time.Hour)
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		// Warned late, it still gets the whole warning
+		Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Expiring")))
+
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+		Expect(vm.Annotations).To(HaveKey(expiryWarnedAnnotation))
+
+		_, err = r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("should warn before deleting an object given a TTL it has outlived", func() {
+		r, recorder := newExpiryReconciler(9*time.Hour, 8*time.Hour)
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, time.Minute))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Expiring")))
+		Expect(r.Get(ctx, key, &llmcloudv1alpha1.VirtualMachine{})).To(Succeed())
+	})
+
+	It("should delete an expired object once the warning has passed", func() {
+		r, recorder := newExpiryReconciler(9*time.Hour, 8*time.Hour)
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+		vm.Annotations = map[string]string{
+			expiryWarnedAnnotation: vm.CreationTimestamp.Add(8 * time.Hour).UTC().Format(time.RFC3339),
+		}
+		Expect(r.Update(ctx, vm)).To(Succeed())
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Expired")))
+
+		err = r.Get(ctx, key, &llmcloudv1alpha1.VirtualMachine{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should leave objects without a TTL alone", func() {
+		r, _ := newExpiryReconciler(9*time.Hour, 8*time.Hour)
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+		vm.Spec.TTL = nil
+		Expect(r.Update(ctx, vm)).To(Succeed())
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeZero())
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+	})
+
+	It("should warn again when a warned TTL is extended", func() {
+		r, recorder := newExpiryReconciler(7*time.Hour+30*time.Minute, 8*time.Hour)
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(ContainSubstring("Expiring")))
+
+		vm := &llmcloudv1alpha1.VirtualMachine{}
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+		vm.Spec.TTL = &metav1.Duration{Duration: 24 * time.Hour}
+		Expect(r.Update(ctx, vm)).To(Succeed())
+
+		result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.RequeueAfter).To(BeNumerically("~", 15*time.Hour+30*time.Minute, time.Minute))
+		Expect(recorder.Events).NotTo(Receive())
+		Expect(r.Get(ctx, key, vm)).To(Succeed())
+	})
+})
@@ -0,0 +1,110 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+
+// VMPortsServiceName is the Service exposing the ExposedPorts of a VM
+func VMPortsServiceName(vm *llmcloudv1alpha1.VirtualMachine) string {
+	return vm.Name + "-ports"
+}
+
+// reconcileExposedPorts creates or updates the Service exposing the VM's ExposedPorts,
+// which selects its virt-launcher pod, and the NetworkPolicy admitting traffic to them
+// from anywhere past the project's isolation, or deletes both once the VM exposes none
+func (r *VirtualMachineReconciler) reconcileExposedPorts(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: VMPortsServiceName(vm), Namespace: vm.Namespace}}
+	policy := &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: VMPortsServiceName(vm), Namespace: vm.Namespace}}
+	if len(vm.Spec.ExposedPorts) == 0 {
+		if err := deleteOwned(ctx, r.Client, vm, svc); err != nil {
+			return err
+		}
+		return deleteOwned(ctx, r.Client, vm, policy)
+	}
+
+	selector := map[string]string{"vm.kubevirt.io/name": vm.Name}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		svc.Labels = map[string]string{"llmcloud.io/managed": "true"}
+		svc.Spec.Type = corev1.ServiceType(cmp.Or(vm.Spec.ExposeType, string(corev1.ServiceTypeNodePort)))
+		svc.Spec.Selector = selector
+		svc.Spec.Ports = exposedServicePorts(vm.Spec.ExposedPorts, svc.Spec.Ports)
+		return controllerutil.SetControllerReference(vm, svc, r.Scheme)
+	}); err != nil {
+		return err
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, policy, func() error {
+		policy.Labels = map[string]string{"llmcloud.io/managed": "true"}
+		policy.Spec.PodSelector = metav1.LabelSelector{MatchLabels: selector}
+		policy.Spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+		policy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{Ports: exposedPolicyPorts(vm.Spec.ExposedPorts)}}
+		return controllerutil.SetControllerReference(vm, policy, r.Scheme)
+	})
+	return err
+}
+
+// exposedPolicyPorts returns the NetworkPolicy ports for the VM ports
+func exposedPolicyPorts(ports []llmcloudv1alpha1.VMPort) []networkingv1.NetworkPolicyPort {
+	policyPorts := make([]networkingv1.NetworkPolicyPort, 0, len(ports))
+	for _, port := range ports {
+		protocol := corev1.Protocol(cmp.Or(port.Protocol, string(corev1.ProtocolTCP)))
+		target := intstr.FromInt32(port.Port)
+		policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &target})
+	}
+	return policyPorts
+}
+
+// exposedServicePorts returns the Service ports for the VM ports. A port without a
+// NodePort keeps the one Kubernetes allocated for it in current, so that updating
+// the Service does not move it
+func exposedServicePorts(ports []llmcloudv1alpha1.VMPort, current []corev1.ServicePort) []corev1.ServicePort {
+	servicePorts := make([]corev1.ServicePort, 0, len(ports))
+	for i, port := range ports {
+		servicePort := corev1.ServicePort{
+			// Every port of a multi-port Service needs a name
+			Name:       cmp.Or(port.Name, fmt.Sprintf("port-%d", i)),
+			Port:       port.Port,
+			TargetPort: intstr.FromInt32(port.Port),
+			Protocol:   corev1.Protocol(cmp.Or(port.Protocol, string(corev1.ProtocolTCP))),
+			NodePort:   port.NodePort,
+		}
+		if servicePort.NodePort == 0 {
+			for _, existing := range current {
+				if existing.Port == servicePort.Port && existing.Protocol == servicePort.Protocol {
+					servicePort.NodePort = existing.NodePort
+				}
+			}
+		}
+		servicePorts = append(servicePorts, servicePort)
+	}
+	return servicePorts
+}
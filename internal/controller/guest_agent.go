@@ -0,0 +1,235 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const (
+	// guestInfoRefreshInterval is how often the hostname, filesystems and users of a
+	// running guest are read again from its agent
+	guestInfoRefreshInterval = time.Minute
+
+	// guestAgentTimeout bounds a request to the guest agent, which KubeVirt relays to a
+	// guest that may be too busy to answer
+	guestAgentTimeout = 10 * time.Second
+)
+
+// GuestAgentSource reads what the QEMU guest agent of a running VM reports: its
+// hostname, OS, timezone, filesystems and logged-in users
+type GuestAgentSource interface {
+	GuestInfo(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (*llmcloudv1alpha1.GuestInfo, error)
+}
+
+// +kubebuilder:rbac:groups=subresources.kubevirt.io,resources=virtualmachineinstances/guestosinfo,verbs=get
+
+// KubeVirtGuestAgent reads the guestosinfo subresource of the VM's KubeVirt instance,
+// which KubeVirt answers by querying the guest agent
+type KubeVirtGuestAgent struct {
+	server *url.URL
+	client *http.Client
+}
+
+// NewKubeVirtGuestAgent returns a GuestAgentSource talking to the Kubernetes API of config
+func NewKubeVirtGuestAgent(config *rest.Config) (*KubeVirtGuestAgent, error) {
+	server, _, err := rest.DefaultServerUrlFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Kubernetes API address: %w", err)
+	}
+	httpClient, err := rest.HTTPClientFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build Kubernetes API client: %w", err)
+	}
+	return &KubeVirtGuestAgent{server: server, client: httpClient}, nil
+}
+
+// kubeVirtGuestAgentInfo is the part of KubeVirt's VirtualMachineInstanceGuestAgentInfo
+// copied into the VM status
+type kubeVirtGuestAgentInfo struct {
+	Hostname string `json:"hostname"`
+	OS       struct {
+		Name          string `json:"name"`
+		Version       string `json:"version"`
+		PrettyName    string `json:"prettyName"`
+		KernelRelease string `json:"kernelRelease"`
+		Machine       string `json:"machine"`
+	} `json:"os"`
+	Timezone string `json:"timezone"`
+	UserList []struct {
+		UserName  string  `json:"userName"`
+		Domain    string  `json:"domain"`
+		LoginTime float64 `json:"loginTime"`
+	} `json:"userList"`
+	FSInfo struct {
+		Disks []struct {
+			DiskName       string `json:"diskName"`
+			MountPoint     string `json:"mountPoint"`
+			FileSystemType string `json:"fileSystemType"`
+			UsedBytes      int64  `json:"usedBytes"`
+			TotalBytes     int64  `json:"totalBytes"`
+		} `json:"disks"`
+	} `json:"fsInfo"`
+}
+
+// GuestInfo implements GuestAgentSource
+func (a *KubeVirtGuestAgent) GuestInfo(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (*llmcloudv1alpha1.GuestInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, guestAgentTimeout)
+	defer cancel()
+
+	target := *a.server
+	target.Path = path.Join(target.Path, "/apis/subresources.kubevirt.io/v1/namespaces",
+		vm.Namespace, "virtualmachineinstances", vm.Name, "guestosinfo")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("guestosinfo returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var info kubeVirtGuestAgentInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode guestosinfo: %w", err)
+	}
+
+	guest := &llmcloudv1alpha1.GuestInfo{
+		Hostname: info.Hostname,
+		OS: llmcloudv1alpha1.GuestOSInfo{
+			Name:          info.OS.Name,
+			Version:       info.OS.Version,
+			PrettyName:    info.OS.PrettyName,
+			KernelRelease: info.OS.KernelRelease,
+			Machine:       info.OS.Machine,
+		},
+		Timezone: info.Timezone,
+	}
+	for _, disk := range info.FSInfo.Disks {
+		guest.Filesystems = append(guest.Filesystems, llmcloudv1alpha1.GuestFilesystem{
+			MountPoint: disk.MountPoint,
+			Disk:       disk.DiskName,
+			Type:       disk.FileSystemType,
+			TotalBytes: disk.TotalBytes,
+			UsedBytes:  disk.UsedBytes,
+		})
+	}
+	for _, user := range info.UserList {
+		guestUser := llmcloudv1alpha1.GuestUser{Name: user.UserName, Domain: user.Domain}
+		if user.LoginTime > 0 {
+			// The agent reports the login time in seconds since the epoch, with a fraction
+			sec, frac := math.Modf(user.LoginTime)
+			loginTime := metav1.NewTime(time.Unix(int64(sec), int64(frac*1e9)))
+			guestUser.LoginTime = &loginTime
+		}
+		guest.Users = append(guest.Users, guestUser)
+	}
+	return guest, nil
+}
+
+// interfacesFromVMI returns the interfaces listed in a VMI status with all of their
+// addresses. The guest agent adds the interfaces only the guest knows about, such as
+// those of containers or VPNs running in it
+func interfacesFromVMI(vmiStatus map[string]interface{}) []llmcloudv1alpha1.VMInterface {
+	list, _ := vmiStatus["interfaces"].([]interface{})
+	var interfaces []llmcloudv1alpha1.VMInterface
+	for _, i := range list {
+		iface, ok := i.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vmInterface := llmcloudv1alpha1.VMInterface{}
+		vmInterface.Name, _ = iface["name"].(string)
+		vmInterface.InterfaceName, _ = iface["interfaceName"].(string)
+		vmInterface.MAC, _ = iface["mac"].(string)
+		addresses, _ := iface["ipAddresses"].([]interface{})
+		for _, a := range addresses {
+			if address, ok := a.(string); ok && address != "" {
+				vmInterface.IPAddresses = append(vmInterface.IPAddresses, address)
+			}
+		}
+		// Older KubeVirt versions only report the primary address
+		if ip, ok := iface["ipAddress"].(string); ok && ip != "" && len(vmInterface.IPAddresses) == 0 {
+			vmInterface.IPAddresses = []string{ip}
+		}
+		interfaces = append(interfaces, vmInterface)
+	}
+	return interfaces
+}
+
+// guestOSFromVMI returns the guest OS that KubeVirt records in the VMI status while
+// the guest agent is connected
+func guestOSFromVMI(vmiStatus map[string]interface{}) llmcloudv1alpha1.GuestOSInfo {
+	info, _ := vmiStatus["guestOSInfo"].(map[string]interface{})
+	var guestOS llmcloudv1alpha1.GuestOSInfo
+	guestOS.Name, _ = info["name"].(string)
+	guestOS.Version, _ = info["version"].(string)
+	guestOS.PrettyName, _ = info["prettyName"].(string)
+	guestOS.KernelRelease, _ = info["kernelRelease"].(string)
+	guestOS.Machine, _ = info["machine"].(string)
+	return guestOS
+}
+
+// applyGuestInfo records what the guest agent reports in vm's status. The OS comes with
+// every VMI status, while the hostname, filesystems and users are read from the agent
+// at most every guestInfoRefreshInterval. The previous values are kept when the agent
+// does not answer, and all of it is cleared once the agent disconnects
+func (r *VirtualMachineReconciler) applyGuestInfo(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, vmiStatus map[string]interface{}) {
+	if !guestAgentConnected(vmiStatus) {
+		vm.Status.Guest = nil
+		return
+	}
+
+	guest := vm.Status.Guest
+	if guest == nil {
+		guest = &llmcloudv1alpha1.GuestInfo{}
+	}
+	now := time.Now()
+	if r.GuestAgent != nil && (guest.UpdatedAt == nil || now.Sub(guest.UpdatedAt.Time) >= guestInfoRefreshInterval) {
+		info, err := r.GuestAgent.GuestInfo(ctx, vm)
+		if err != nil {
+			logf.FromContext(ctx).Error(err, "Failed to read guest agent info", "vm", vm.Name)
+		} else {
+			guest = info
+			guest.UpdatedAt = &metav1.Time{Time: now}
+		}
+	}
+	if guestOS := guestOSFromVMI(vmiStatus); guestOS.Name != "" {
+		guest.OS = guestOS
+	}
+	vm.Status.Guest = guest
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metrics.k8s.io,resources=pods,verbs=get
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+const (
+	// vmSuspendedCondition is set while a VM is halted because it was idle
+	vmSuspendedCondition = "Suspended"
+
+	// idleCheckInterval is how often a running VM with SuspendAfterIdle is checked for activity
+	idleCheckInterval = 5 * time.Minute
+)
+
+// VMActivitySource reports whether a running VM is doing anything
+type VMActivitySource interface {
+	IsActive(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (bool, error)
+}
+
+// PodMetricsActivity treats a VM as active while the CPU usage of its virt-launcher
+// pod, as reported by the metrics API, is at least CPUThreshold
+type PodMetricsActivity struct {
+	Client       client.Client
+	CPUThreshold resource.Quantity
+}
+
+var podMetricsGVK = schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "PodMetrics"}
+
+// IsActive implements VMActivitySource
+func (a *PodMetricsActivity) IsActive(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (bool, error) {
+	var pods corev1.PodList
+	if err := a.Client.List(ctx, &pods, client.InNamespace(vm.Namespace),
+		client.MatchingLabels{"vm.kubevirt.io/name": vm.Name}); err != nil {
+		return false, err
+	}
+
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		metrics := &unstructured.Unstructured{}
+		metrics.SetGroupVersionKind(podMetricsGVK)
+		if err := a.Client.Get(ctx, client.ObjectKeyFromObject(&pod), metrics); err != nil {
+			return false, fmt.Errorf("failed to get metrics for pod %s: %w", pod.Name, err)
+		}
+
+		usage := resource.Quantity{}
+		containers, _, _ := unstructured.NestedSlice(metrics.Object, "containers")
+		for _, c := range containers {
+			if container, ok := c.(map[string]interface{}); ok {
+				usage.Add(NestedQuantity(container, "usage", "cpu"))
+			}
+		}
+		if usage.Cmp(a.CPUThreshold) >= 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NestedQuantity parses a resource quantity at the given path, returning zero if absent or invalid
+func NestedQuantity(obj map[string]interface{}, fields ...string) resource.Quantity {
+	value, found, err := unstructured.NestedString(obj, fields...)
+	if err != nil || !found {
+		return resource.Quantity{}
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return resource.Quantity{}
+	}
+	return q
+}
+
+// idleTiming decides whether a VM last active at lastActive should be suspended
+// after being idle for idleAfter, and otherwise how long to wait before checking again
+func idleTiming(lastActive, now time.Time, idleAfter time.Duration) (bool, time.Duration) {
+	remaining := lastActive.Add(idleAfter).Sub(now)
+	if remaining <= 0 {
+		return true, 0
+	}
+	return false, min(remaining, idleCheckInterval)
+}
+
+// reconcileIdleSuspend halts a running VM with SuspendAfterIdle once it has been idle
+// for that long, and returns when it should be checked again. A VM started again after
+// being suspended gets a fresh idle period
+func (r *VirtualMachineReconciler) reconcileIdleSuspend(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (time.Duration, error) {
+	log := logf.FromContext(ctx)
+
+	if vm.Spec.SuspendAfterIdle == nil || r.Activity == nil || vm.Spec.RunStrategy == "Halted" {
+		return 0, nil
+	}
+
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(vm), latestVM); err != nil {
+		return 0, err
+	}
+	if !latestVM.Status.Ready {
+		return idleCheckInterval, nil
+	}
+
+	now := time.Now()
+	resumed := meta.IsStatusConditionTrue(latestVM.Status.Conditions, vmSuspendedCondition)
+	active, err := r.Activity.IsActive(ctx, latestVM)
+	if err != nil {
+		// Without activity data the VM is assumed to be in use
+		log.Error(err, "Failed to check VM activity", "vm", vm.Name)
+		return idleCheckInterval, nil
+	}
+
+	last := latestVM.Status.LastActiveTime
+	if active || resumed || last == nil {
+		if resumed {
+			meta.SetStatusCondition(&latestVM.Status.Conditions, metav1.Condition{
+				Type:               vmSuspendedCondition,
+				Status:             metav1.ConditionFalse,
+				Reason:             "Resumed",
+				Message:            "Virtual machine was started again",
+				ObservedGeneration: latestVM.Generation,
+			})
+		}
+		// Recording every check would rewrite the status on each reconcile
+		if resumed || last == nil || now.Sub(last.Time) >= time.Minute {
+			activeTime := metav1.NewTime(now)
+			latestVM.Status.LastActiveTime = &activeTime
+			if err := r.Status().Update(ctx, latestVM); err != nil {
+				return 0, err
+			}
+		}
+		return min(vm.Spec.SuspendAfterIdle.Duration, idleCheckInterval), nil
+	}
+
+	suspend, wait := idleTiming(last.Time, now, vm.Spec.SuspendAfterIdle.Duration)
+	if !suspend {
+		return wait, nil
+	}
+
+	log.Info("Suspending idle VM", "vm", vm.Name, "lastActive", last.Time)
+	latestVM.Spec.RunStrategy = "Halted"
+	if err := r.Update(ctx, latestVM); err != nil {
+		return 0, err
+	}
+	message := fmt.Sprintf("Halted after being idle since %s", last.UTC().Format(time.RFC3339))
+	meta.SetStatusCondition(&latestVM.Status.Conditions, metav1.Condition{
+		Type:               vmSuspendedCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Idle",
+		Message:            message,
+		ObservedGeneration: latestVM.Generation,
+	})
+	if err := r.Status().Update(ctx, latestVM); err != nil {
+		return 0, err
+	}
+	if r.Recorder != nil {
+		r.Recorder.Event(latestVM, corev1.EventTypeNormal, "Suspended", message)
+	}
+	return 0, nil
+}
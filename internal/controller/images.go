@@ -0,0 +1,61 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+)
+
+// PublicRegistries are the registries whose images are pulled through the registry mirror
+var PublicRegistries = []string{"docker.io", "quay.io", "ghcr.io", "gcr.io", "registry.k8s.io"}
+
+// ImagePolicy controls how workload images are pulled
+type ImagePolicy struct {
+	// RegistryMirror is a registry prefix (e.g., "mirror.local:5000") that images from
+	// PublicRegistries are rewritten to. Mirroring is disabled when empty
+	RegistryMirror string
+	// PullPolicy is the image pull policy set on workloads (Always, IfNotPresent, Never).
+	// The cluster default is used when empty
+	PullPolicy string
+}
+
+// Resolve returns the image to pull for image. Images from a public registry are
+// prefixed with the registry mirror, keeping the original registry host in the path
+// (quay.io/foo/bar becomes mirror.local/quay.io/foo/bar). Images without a registry
+// host are Docker Hub images. Images from any other registry are returned unchanged
+func (p ImagePolicy) Resolve(image string) string {
+	if p.RegistryMirror == "" || image == "" {
+		return image
+	}
+
+	registry, path := "docker.io", image
+	if i := strings.Index(image, "/"); i > 0 {
+		if host := image[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry, path = host, image[i+1:]
+		}
+	}
+	if registry == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+
+	for _, public := range PublicRegistries {
+		if registry == public {
+			return strings.TrimSuffix(p.RegistryMirror, "/") + "/" + registry + "/" + path
+		}
+	}
+	return image
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("ImagePolicy", func() {
+	policy := ImagePolicy{RegistryMirror: "mirror.local:5000/"}
+
+	It("should prefix public registry images with the mirror", func() {
+		Expect(policy.Resolve("quay.io/containerdisks/ubuntu:22.04")).To(Equal("mirror.local:5000/quay.io/containerdisks/ubuntu:22.04"))
+		Expect(policy.Resolve("ghcr.io/org/app:v1")).To(Equal("mirror.local:5000/ghcr.io/org/app:v1"))
+	})
+
+	It("should treat images without a registry host as Docker Hub images", func() {
+		Expect(policy.Resolve("nginx:1.27")).To(Equal("mirror.local:5000/docker.io/library/nginx:1.27"))
+		Expect(policy.Resolve("ollama/ollama")).To(Equal("mirror.local:5000/docker.io/ollama/ollama"))
+	})
+
+	It("should leave images from internal registries untouched", func() {
+		Expect(policy.Resolve("registry.internal:5000/team/app:v1")).To(Equal("registry.internal:5000/team/app:v1"))
+		Expect(policy.Resolve("localhost/app")).To(Equal("localhost/app"))
+	})
+
+	It("should not rewrite images when no mirror is configured", func() {
+		Expect(ImagePolicy{}.Resolve("quay.io/containerdisks/ubuntu:22.04")).To(Equal("quay.io/containerdisks/ubuntu:22.04"))
+	})
+
+	It("should apply the mirror and pull policy to the VM container disk", func() {
+		r := &VirtualMachineReconciler{Images: ImagePolicy{RegistryMirror: "mirror.local:5000", PullPolicy: "IfNotPresent"}}
+		vm := &llmcloudv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "mirrored", Namespace: "default"},
+			Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", CPUs: 1, Memory: "1Gi", DiskSize: "0"},
+		}
+
+		volumes, _, _ := unstructured.NestedSlice(r.buildKubeVirtVM(vm, nil, nil, nil, "").Object, "spec", "template", "spec", "volumes")
+		Expect(volumes).NotTo(BeEmpty())
+		containerDisk := volumes[0].(map[string]interface{})["containerDisk"].(map[string]interface{})
+		Expect(containerDisk["image"]).To(Equal("mirror.local:5000/quay.io/containerdisks/ubuntu:22.04"))
+		Expect(containerDisk["imagePullPolicy"]).To(Equal("IfNotPresent"))
+	})
+})
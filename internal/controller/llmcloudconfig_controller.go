@@ -0,0 +1,78 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// LLMCloudConfigReconciler puts the LLMCloudConfig named default into effect for the
+// controllers, webhooks and API server whenever it changes, and restores the built-in
+// defaults when it is deleted
+type LLMCloudConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmcloudconfigs,verbs=get;list;watch
+
+// Reconcile applies the LLMCloudConfig to the runtime settings
+func (r *LLMCloudConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	config := &llmcloudv1alpha1.LLMCloudConfig{}
+	if err := r.Get(ctx, req.NamespacedName, config); err != nil {
+		if client.IgnoreNotFound(err) != nil {
+			return ctrl.Result{}, err
+		}
+		llmcloudv1alpha1.SetRuntimeConfig(nil)
+		logger.Info("No LLMCloudConfig, using the built-in defaults")
+		return ctrl.Result{}, nil
+	}
+
+	// A config the webhook would reject, e.g. created with webhooks disabled, is not applied
+	if err := llmcloudv1alpha1.ValidateLLMCloudConfig(config).ToAggregate(); err != nil {
+		logger.Error(err, "Ignoring invalid LLMCloudConfig")
+		return ctrl.Result{}, nil
+	}
+	llmcloudv1alpha1.SetRuntimeConfig(&config.Spec)
+	logger.Info("Applied LLMCloudConfig", "generation", config.Generation)
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager. It runs on every replica,
+// not only the leader, as each serves the API and webhooks
+func (r *LLMCloudConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&llmcloudv1alpha1.LLMCloudConfig{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetName() == llmcloudv1alpha1.LLMCloudConfigName
+		}))).
+		WithOptions(controller.Options{NeedLeaderElection: ptr.To(false)}).
+		Named("llmcloudconfig").
+		Complete(r)
+}
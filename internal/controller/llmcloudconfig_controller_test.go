@@ -0,0 +1,82 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("LLMCloudConfig Controller", func() {
+	ctx := context.Background()
+	request := reconcile.Request{NamespacedName: types.NamespacedName{Name: llmcloudv1alpha1.LLMCloudConfigName}}
+
+	newConfigReconciler := func(objs ...client.Object) *LLMCloudConfigReconciler {
+		testScheme := runtime.NewScheme()
+		Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+		return &LLMCloudConfigReconciler{Client: c, Scheme: testScheme}
+	}
+
+	AfterEach(func() {
+		llmcloudv1alpha1.SetRuntimeConfig(nil)
+	})
+
+	It("should put the config into effect and restore the defaults once it is deleted", func() {
+		config := &llmcloudv1alpha1.LLMCloudConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: llmcloudv1alpha1.LLMCloudConfigName},
+			Spec: llmcloudv1alpha1.LLMCloudConfigSpec{
+				DefaultStorageClass: "fast-ssd",
+				TokenTTL:            &metav1.Duration{Duration: time.Hour},
+			},
+		}
+		r := newConfigReconciler(config)
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(llmcloudv1alpha1.RuntimeConfig().DefaultStorageClass).To(Equal("fast-ssd"))
+		Expect(llmcloudv1alpha1.RuntimeConfig().TokenTTL.Duration).To(Equal(time.Hour))
+
+		Expect(r.Delete(ctx, config)).To(Succeed())
+		_, err = r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(llmcloudv1alpha1.RuntimeConfig().DefaultStorageClass).To(BeEmpty())
+	})
+
+	It("should keep the settings in effect when the config is invalid", func() {
+		llmcloudv1alpha1.SetRuntimeConfig(&llmcloudv1alpha1.LLMCloudConfigSpec{DefaultStorageClass: "fast-ssd"})
+		r := newConfigReconciler(&llmcloudv1alpha1.LLMCloudConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: llmcloudv1alpha1.LLMCloudConfigName},
+			Spec:       llmcloudv1alpha1.LLMCloudConfigSpec{DefaultStorageClass: "Not Valid"},
+		})
+
+		_, err := r.Reconcile(ctx, request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(llmcloudv1alpha1.RuntimeConfig().DefaultStorageClass).To(Equal("fast-ssd"))
+	})
+})
@@ -17,28 +17,58 @@ limitations under the License.
 package controller
 
 import (
+	"cmp"
 	"context"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
 )
 
 // LLMModelReconciler reconciles a LLMModel object
 type LLMModelReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// MaxPullRetries is the number of automatic retries after a failed model pull (0 disables retries)
+	MaxPullRetries int32
+	// PullRetryBackoff is the base delay between retries; it doubles with each attempt
+	PullRetryBackoff time.Duration
+	// Images resolves the model image recorded in the status
+	Images ImagePolicy
+	// Cache configures the volumes huggingface models are downloaded to
+	Cache ModelCache
+	// Recorder emits events for model lifecycle changes and reconcile errors
+	Recorder record.EventRecorder
+	// Prober prompts the pods of models with a health check prompt timeout; nil disables prompting
+	Prober ModelProber
 }
 
+const (
+	// modelRetryAnnotation requests a manual retry, resetting the automatic retry counter
+	modelRetryAnnotation = "llmcloud.io/retry"
+	maxPullRetryBackoff  = 10 * time.Minute
+)
+
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmmodels,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmmodels/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmmodels/finalizers,verbs=update
 
 // Reconcile is part of the main kubernetes reconciliation loop
-func (r *LLMModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *LLMModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	logger := log.FromContext(ctx)
 
 	// Fetch the LLMModel instance
@@ -46,23 +76,202 @@ func (r *LLMModelReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	if err := r.Get(ctx, req.NamespacedName, model); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	defer func() { recordReconcileError(r.Recorder, model, err) }()
+	previous := model.Status.DeepCopy()
 
 	logger.Info("Reconciling LLMModel", "name", model.Name, "namespace", model.Namespace)
 
-	// Update status to Running if not set
-	if model.Status.Phase == "" {
+	if err := inheritProjectMetadata(ctx, r.Client, model); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Handle manual retry annotation
+	if model.Annotations[modelRetryAnnotation] == "true" {
+		delete(model.Annotations, modelRetryAnnotation)
+		if err := r.Update(ctx, model); err != nil {
+			return ctrl.Result{}, err
+		}
 		model.Status.Phase = llmcloudv1alpha1.LLMModelPhasePending
+		model.Status.PullAttempts = 0
+		model.Status.LastPullAttempt = nil
+		logger.Info("Manual retry requested, reset pull attempts", "name", model.Name)
+		return ctrl.Result{}, r.Status().Update(ctx, model)
+	}
+
+	// Fill in the fields taken from the model's preset. Only the status is written from
+	// here on, so they are never stored in its spec
+	if err := llmcloudv1alpha1.ApplyModelPreset(&model.Spec); err != nil {
+		logger.Info("Rejecting preset", "name", model.Name, "reason", err.Error())
+		if meta.SetStatusCondition(&model.Status.Conditions, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			Reason:             "UnknownPreset",
+			Message:            err.Error(),
+			ObservedGeneration: model.Generation,
+		}) {
+			recordEvent(r.Recorder, model, corev1.EventTypeWarning, "UnknownPreset", "%v", err)
+			return ctrl.Result{}, r.Status().Update(ctx, model)
+		}
+		return ctrl.Result{}, nil
+	}
+	if cond := meta.FindStatusCondition(model.Status.Conditions, "Ready"); cond != nil && cond.Reason == "UnknownPreset" {
+		meta.RemoveStatusCondition(&model.Status.Conditions, "Ready")
+		return ctrl.Result{}, r.Status().Update(ctx, model)
+	}
+
+	if ok, err := checkReplicasLimit(ctx, r.Client, r.Recorder, model, &model.Status.Conditions, model.Spec.MaxReplicasWanted()); !ok || err != nil {
+		return ctrl.Result{}, err
+	}
+	if ok, err := r.checkModelWeightsAccess(ctx, model); !ok || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if model.Status.Phase == llmcloudv1alpha1.LLMModelPhaseFailed {
+		return r.retryFailedPull(ctx, model)
+	}
+
+	// The rollout is judged by the requests counted by the gateway, whatever the state
+	// of the model's own workload
+	rolloutChanged := updateRolloutStatus(model, metav1.Now())
+
+	// A huggingface model is served once its weights are in the model cache
+	downloadChanged := false
+	if downloadsWeights(model) {
+		job, err := r.reconcileModelDownload(ctx, model)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		downloadChanged = updateModelDownload(model, job)
+		if model.Status.Download.Phase != llmcloudv1alpha1.ModelDownloadPhaseComplete {
+			if downloadChanged || rolloutChanged {
+				r.recordStatusChange(model, previous)
+				return ctrl.Result{}, r.Status().Update(ctx, model)
+			}
+			return ctrl.Result{}, nil
+		}
+	} else if model.Status.Download != nil {
+		model.Status.Download = nil
+		downloadChanged = true
+	}
+
+	image := r.Images.Resolve(cmp.Or(model.Spec.Image, DefaultModelImage))
+	deployment, err := r.reconcileModelWorkload(ctx, model, image)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if updateModelStatus(model, deployment, image) || downloadChanged || rolloutChanged {
+		r.recordStatusChange(model, previous)
 		if err := r.Status().Update(ctx, model); err != nil {
 			return ctrl.Result{}, err
 		}
 	}
 
-	return ctrl.Result{}, nil
+	nextPrompt, err := r.reconcileModelHealth(ctx, model)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: nextPrompt}, nil
+}
+
+// retryFailedPull moves a failed model back to Pending with exponential backoff until
+// MaxPullRetries automatic attempts have been made
+func (r *LLMModelReconciler) retryFailedPull(ctx context.Context, model *llmcloudv1alpha1.LLMModel) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if model.Status.PullAttempts >= r.MaxPullRetries {
+		logger.Info("Model pull retries exhausted", "name", model.Name, "attempts", model.Status.PullAttempts)
+		return ctrl.Result{}, nil
+	}
+
+	if last := model.Status.LastPullAttempt; last != nil {
+		if wait := time.Until(last.Add(r.pullRetryDelay(model.Status.PullAttempts))); wait > 0 {
+			return ctrl.Result{RequeueAfter: wait}, nil
+		}
+	}
+
+	now := metav1.Now()
+	model.Status.PullAttempts++
+	model.Status.LastPullAttempt = &now
+	model.Status.Phase = llmcloudv1alpha1.LLMModelPhasePending
+	logger.Info("Retrying failed model pull", "name", model.Name, "attempt", model.Status.PullAttempts)
+	recordEvent(r.Recorder, model, corev1.EventTypeNormal, "Retrying", "Retrying the failed model, attempt %d of %d",
+		model.Status.PullAttempts, r.MaxPullRetries)
+	return ctrl.Result{}, r.Status().Update(ctx, model)
+}
+
+// pullRetryDelay returns the backoff before the next retry given the attempts made so far
+func (r *LLMModelReconciler) pullRetryDelay(attempts int32) time.Duration {
+	delay := r.PullRetryBackoff
+	for i := int32(1); i < attempts && delay < maxPullRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxPullRetryBackoff)
+}
+
+// updateModelStatus records the image, endpoint and ready replicas of the model's
+// Deployment, reporting whether anything changed. The model is Running once every
+// replica is ready, Failed when the rollout exceeded its progress deadline, and Stopped
+// while it is stopped
+func updateModelStatus(model *llmcloudv1alpha1.LLMModel, deployment *appsv1.Deployment, image string) bool {
+	phase := llmcloudv1alpha1.LLMModelPhasePending
+	if deployment.Status.ReadyReplicas >= desiredModelReplicas(model) {
+		phase = llmcloudv1alpha1.LLMModelPhaseRunning
+	}
+	for _, c := range deployment.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Status == corev1.ConditionFalse && c.Reason == "ProgressDeadlineExceeded" {
+			phase = llmcloudv1alpha1.LLMModelPhaseFailed
+		}
+	}
+	if model.Spec.Stopped {
+		phase = llmcloudv1alpha1.LLMModelPhaseStopped
+	}
+
+	status := &model.Status
+	endpoint := modelEndpoint(model)
+	changed := status.Phase != phase ||
+		status.Image != image ||
+		status.Endpoint != endpoint ||
+		status.ReadyReplicas != deployment.Status.ReadyReplicas
+	status.Phase = phase
+	status.Image = image
+	status.Endpoint = endpoint
+	status.ReadyReplicas = deployment.Status.ReadyReplicas
+	return changed
+}
+
+// recordStatusChange records an event for the download finishing and the model turning
+// Running or Failed since the previous status
+func (r *LLMModelReconciler) recordStatusChange(model *llmcloudv1alpha1.LLMModel, previous *llmcloudv1alpha1.LLMModelStatus) {
+	if download := model.Status.Download; download != nil && download.Phase == llmcloudv1alpha1.ModelDownloadPhaseComplete &&
+		(previous.Download == nil || previous.Download.Phase != download.Phase) {
+		recordEvent(r.Recorder, model, corev1.EventTypeNormal, "Downloaded", "Downloaded the model weights to %s", download.Path)
+	}
+	if model.Status.Phase == previous.Phase {
+		return
+	}
+	switch model.Status.Phase {
+	case llmcloudv1alpha1.LLMModelPhaseRunning:
+		recordEvent(r.Recorder, model, corev1.EventTypeNormal, "Running", "All %d replicas are ready", desiredModelReplicas(model))
+	case llmcloudv1alpha1.LLMModelPhaseFailed:
+		message := "The rollout exceeded its progress deadline"
+		if download := model.Status.Download; download != nil && download.Phase == llmcloudv1alpha1.ModelDownloadPhaseFailed {
+			message = "Downloading the model weights failed: " + download.Message
+		}
+		recordEvent(r.Recorder, model, corev1.EventTypeWarning, "Failed", "%s", message)
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *LLMModelReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&llmcloudv1alpha1.LLMModel{}).
-		Complete(r)
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&batchv1.Job{}).
+		Watches(&llmcloudv1alpha1.Project{}, handler.EnqueueRequestsFromMapFunc(
+			workloadsForProject(mgr.GetClient(), func() client.ObjectList { return &llmcloudv1alpha1.LLMModelList{} }))).
+		Complete(metrics.Reconciler("LLMModel", r))
 }
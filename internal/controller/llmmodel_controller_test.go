@@ -0,0 +1,717 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("LLMModel Controller", func() {
+	Context("When a model pull has failed", func() {
+		const resourceName = "test-model"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating a failed LLMModel")
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName: "llama2",
+					Replicas:  1,
+				},
+			}
+			err := k8sClient.Create(ctx, model)
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, model)).To(Succeed())
+			model.Status.Phase = llmcloudv1alpha1.LLMModelPhaseFailed
+			Expect(k8sClient.Status().Update(ctx, model)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			model := &llmcloudv1alpha1.LLMModel{}
+			if err := k8sClient.Get(ctx, typeNamespacedName, model); err == nil {
+				Expect(k8sClient.Delete(ctx, model)).To(Succeed())
+			}
+		})
+
+		It("should increment the pull attempt count on retry", func() {
+			controllerReconciler := &LLMModelReconciler{
+				Client:           k8sClient,
+				Scheme:           k8sClient.Scheme(),
+				MaxPullRetries:   3,
+				PullRetryBackoff: time.Second,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			model := &llmcloudv1alpha1.LLMModel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, model)).To(Succeed())
+			Expect(model.Status.PullAttempts).To(Equal(int32(1)))
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Status.LastPullAttempt).NotTo(BeNil())
+		})
+
+		It("should stop retrying once retries are exhausted", func() {
+			controllerReconciler := &LLMModelReconciler{
+				Client:         k8sClient,
+				Scheme:         k8sClient.Scheme(),
+				MaxPullRetries: 0,
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			model := &llmcloudv1alpha1.LLMModel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, model)).To(Succeed())
+			Expect(model.Status.PullAttempts).To(Equal(int32(0)))
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseFailed))
+		})
+
+		It("should reset the attempt count on a manual retry", func() {
+			model := &llmcloudv1alpha1.LLMModel{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, model)).To(Succeed())
+			model.Status.PullAttempts = 3
+			Expect(k8sClient.Status().Update(ctx, model)).To(Succeed())
+
+			model.Annotations = map[string]string{modelRetryAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{
+				Client:         k8sClient,
+				Scheme:         k8sClient.Scheme(),
+				MaxPullRetries: 3,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, model)).To(Succeed())
+			Expect(model.Status.PullAttempts).To(Equal(int32(0)))
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Annotations).NotTo(HaveKey(modelRetryAnnotation))
+		})
+	})
+
+	Context("When replicas exceed the configured maximum", func() {
+		ctx := context.Background()
+
+		var origMax int32
+		BeforeEach(func() {
+			origMax = llmcloudv1alpha1.MaxReplicas
+			llmcloudv1alpha1.MaxReplicas = 5
+		})
+
+		AfterEach(func() {
+			llmcloudv1alpha1.MaxReplicas = origMax
+			for _, name := range []string{"too-many-replicas", "max-replicas"} {
+				model := &llmcloudv1alpha1.LLMModel{}
+				if err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: "default"}, model); err == nil {
+					Expect(k8sClient.Delete(ctx, model)).To(Succeed())
+				}
+			}
+		})
+
+		reconcileModel := func(name string, replicas int32) *llmcloudv1alpha1.LLMModel {
+			key := types.NamespacedName{Name: name, Namespace: "default"}
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", Replicas: replicas,
+					WeightsAccessMode: corev1.ReadWriteMany},
+			}
+			Expect(k8sClient.Create(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			return model
+		}
+
+		It("should reject the model with a clear condition", func() {
+			model := reconcileModel("too-many-replicas", 1000)
+
+			Expect(model.Status.Phase).To(BeEmpty())
+			Expect(model.Status.Conditions).To(HaveLen(1))
+			Expect(model.Status.Conditions[0].Reason).To(Equal("ReplicasExceedLimit"))
+			Expect(model.Status.Conditions[0].Message).To(ContainSubstring("exceeds the maximum of 5"))
+		})
+
+		It("should accept the model at the maximum", func() {
+			model := reconcileModel("max-replicas", 5)
+
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Status.Conditions).To(BeEmpty())
+		})
+	})
+
+	Context("When replicas cannot share the weights volume", func() {
+		ctx := context.Background()
+
+		It("should hold the model until its weights volume is ReadWriteMany", func() {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+				Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", Replicas: 3},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}).
+				WithObjects(model).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &LLMModelReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+
+			ok, err := r.checkModelWeightsAccess(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeFalse())
+			cond := meta.FindStatusCondition(model.Status.Conditions, "Ready")
+			Expect(cond.Reason).To(Equal("SharedWeightsRequired"))
+			Expect(cond.Message).To(ContainSubstring("ReadWriteOnce"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("SharedWeightsRequired")))
+
+			By("using the access mode of the model cache for a huggingface model")
+			model.Spec.Provider = llmcloudv1alpha1.ProviderHuggingFace
+			r.Cache.AccessMode = string(corev1.ReadWriteMany)
+			ok, err = r.checkModelWeightsAccess(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ok).To(BeTrue())
+			Expect(model.Status.Conditions).To(BeEmpty())
+		})
+	})
+
+	Context("When a model is created", func() {
+		const name = "served-model"
+		ctx := context.Background()
+		key := types.NamespacedName{Name: name, Namespace: "default"}
+
+		AfterEach(func() {
+			model := &llmcloudv1alpha1.LLMModel{}
+			if err := k8sClient.Get(ctx, key, model); err == nil {
+				Expect(k8sClient.Delete(ctx, model)).To(Succeed())
+			}
+			for _, obj := range []client.Object{
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}},
+				&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}},
+				&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name + "-weights", Namespace: "default"}},
+				&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}},
+				&batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name + "-download", Namespace: "default"}},
+				&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: modelCacheName, Namespace: "default"}},
+			} {
+				Expect(client.IgnoreNotFound(k8sClient.Delete(ctx, obj))).To(Succeed())
+			}
+		})
+
+		It("should deploy an ollama server and record its endpoint", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName:         "llama2",
+					ModelSize:         "7b",
+					Replicas:          2,
+					Resources:         llmcloudv1alpha1.ResourceRequirements{CPU: "2", Memory: "8Gi"},
+					WeightsAccessMode: corev1.ReadWriteMany,
+				},
+			}
+			Expect(k8sClient.Create(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+			Expect(deployment.OwnerReferences).To(HaveLen(1))
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal(DefaultModelImage))
+			Expect(container.Resources.Limits.Memory().String()).To(Equal("8Gi"))
+			Expect(container.Lifecycle.PostStart.Exec.Command).To(ContainElement("llama2:7b"))
+			Expect(deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(name + "-weights"))
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-weights", Namespace: "default"}, pvc)).To(Succeed())
+			Expect(pvc.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany}))
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, key, service)).To(Succeed())
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(11434)))
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Status.Image).To(Equal(DefaultModelImage))
+			Expect(model.Status.Endpoint).To(Equal("http://served-model.default.svc:11434"))
+		})
+
+		It("should serve a huggingface model once its weights are downloaded", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName: "mistralai/Mistral-7B-v0.1",
+					Provider:  llmcloudv1alpha1.ProviderHuggingFace,
+					Image:     "vllm/vllm-openai",
+				},
+			}
+			Expect(k8sClient.Create(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			cache := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: modelCacheName, Namespace: "default"}, cache)).To(Succeed())
+			Expect(cache.OwnerReferences).To(BeEmpty())
+			job := &batchv1.Job{}
+			jobKey := types.NamespacedName{Name: name + "-download", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, jobKey, job)).To(Succeed())
+			Expect(job.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				corev1.EnvVar{Name: "MODEL", Value: "mistralai/Mistral-7B-v0.1"}))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &appsv1.Deployment{}))).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Status.Download.Phase).To(Equal(llmcloudv1alpha1.ModelDownloadPhasePending))
+			Expect(model.Status.Download.Path).To(Equal("/mnt/llm-models/hub/models--mistralai--Mistral-7B-v0.1"))
+
+			now := metav1.Now()
+			job.Status.StartTime = &now
+			job.Status.CompletionTime = &now
+			job.Status.Succeeded = 1
+			job.Status.Conditions = []batchv1.JobCondition{
+				{Type: batchv1.JobSuccessCriteriaMet, Status: corev1.ConditionTrue, LastTransitionTime: now},
+				{Type: batchv1.JobComplete, Status: corev1.ConditionTrue, LastTransitionTime: now},
+			}
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(modelCacheName))
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.VolumeMounts[0].ReadOnly).To(BeTrue())
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "HF_HUB_OFFLINE", Value: "1"}))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: name + "-weights", Namespace: "default"},
+				&corev1.PersistentVolumeClaim{}))).To(BeTrue())
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			Expect(model.Status.Download.Phase).To(Equal(llmcloudv1alpha1.ModelDownloadPhaseComplete))
+		})
+
+		It("should leave the replicas of an autoscaled model to its HorizontalPodAutoscaler", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName: "llama2",
+					Autoscaling: &llmcloudv1alpha1.ModelAutoscaling{
+						MinReplicas:       2,
+						MaxReplicas:       5,
+						TargetConcurrency: 4,
+						ConcurrencyMetric: "vllm:num_requests_running",
+					},
+					WeightsAccessMode: corev1.ReadWriteMany,
+				},
+			}
+			Expect(k8sClient.Create(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			hpa := &autoscalingv2.HorizontalPodAutoscaler{}
+			Expect(k8sClient.Get(ctx, key, hpa)).To(Succeed())
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal(name))
+			Expect(*hpa.Spec.MinReplicas).To(Equal(int32(2)))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(5)))
+			Expect(hpa.Spec.Metrics).To(HaveLen(1))
+			Expect(hpa.Spec.Metrics[0].Pods.Metric.Name).To(Equal("vllm:num_requests_running"))
+
+			// The autoscaler scales the Deployment up; reconciling must not undo it
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+			replicas := int32(4)
+			deployment.Spec.Replicas = &replicas
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(4)))
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			model.Spec.Autoscaling = nil
+			model.Spec.Replicas = 1
+			Expect(k8sClient.Update(ctx, model)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, hpa))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(1)))
+		})
+
+		It("should scale a stopped model down and remove its autoscaler", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName: "llama2",
+					Autoscaling: &llmcloudv1alpha1.ModelAutoscaling{
+						MinReplicas:       2,
+						MaxReplicas:       5,
+						TargetConcurrency: 4,
+						ConcurrencyMetric: "vllm:num_requests_running",
+					},
+					WeightsAccessMode: corev1.ReadWriteMany,
+				},
+			}
+			Expect(k8sClient.Create(ctx, model)).To(Succeed())
+
+			controllerReconciler := &LLMModelReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			model.Spec.Stopped = true
+			Expect(k8sClient.Update(ctx, model)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(BeZero())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, key, &autoscalingv2.HorizontalPodAutoscaler{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, key, model)).To(Succeed())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseStopped))
+
+			model.Spec.Stopped = false
+			Expect(k8sClient.Update(ctx, model)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(2)))
+			Expect(k8sClient.Get(ctx, key, &autoscalingv2.HorizontalPodAutoscaler{})).To(Succeed())
+		})
+	})
+
+	Context("When prompting the pods of a model", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "probed-model", Namespace: "default"}
+
+		newPod := func(name string, ready bool) *corev1.Pod {
+			status := corev1.ConditionFalse
+			if ready {
+				status = corev1.ConditionTrue
+			}
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: key.Namespace, Labels: map[string]string{modelLabel: key.Name}},
+				Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+			}
+		}
+		newHealthReconciler := func(prober stubProber) (*LLMModelReconciler, *record.FakeRecorder, *llmcloudv1alpha1.LLMModel) {
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: llmcloudv1alpha1.LLMModelSpec{
+					ModelName: "llama2",
+					HealthCheck: &llmcloudv1alpha1.ModelHealthCheck{
+						PromptTimeout:    &metav1.Duration{Duration: time.Second},
+						FailureThreshold: 2,
+					},
+				},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}).
+				WithObjects(model, newPod("healthy", true), newPod("stuck", true), newPod("starting", false)).
+				Build()
+			Expect(c.Get(ctx, key, model)).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			return &LLMModelReconciler{Client: c, Scheme: testScheme, Recorder: recorder, Prober: prober}, recorder, model
+		}
+
+		It("should probe the configured health check path and port", func() {
+			model := &llmcloudv1alpha1.LLMModel{Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2"}}
+			handler := modelProbeHandler(model)
+			Expect(handler.HTTPGet.Path).To(Equal(model.Spec.ReadinessProbePath()))
+			Expect(handler.HTTPGet.Port.String()).To(Equal("http"))
+
+			model.Spec.HealthCheck = &llmcloudv1alpha1.ModelHealthCheck{Path: "/health", Port: 8081}
+			handler = modelProbeHandler(model)
+			Expect(handler.HTTPGet.Path).To(Equal("/health"))
+			Expect(handler.HTTPGet.Port.IntValue()).To(Equal(8081))
+		})
+
+		It("should mark the model not ready and restart a pod that stops answering", func() {
+			r, recorder, model := newHealthReconciler(stubProber{"stuck": errors.NewTimeoutError("no answer", 1)})
+
+			wait, err := r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(Equal(llmcloudv1alpha1.DefaultHealthCheckInterval))
+			cond := meta.FindStatusCondition(model.Status.Conditions, "Ready")
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Message).To(HaveSuffix("stuck"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("NotAnswering")))
+			pod := &corev1.Pod{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "stuck", Namespace: key.Namespace}, pod)).To(Succeed())
+			Expect(pod.Annotations).To(HaveKeyWithValue(modelFailedPromptsAnnotation, "1"))
+
+			By("waiting for the interval before prompting again")
+			wait, err = r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(And(BeNumerically(">", 0), BeNumerically("<=", llmcloudv1alpha1.DefaultHealthCheckInterval)))
+			Expect(r.Get(ctx, types.NamespacedName{Name: "stuck", Namespace: key.Namespace}, pod)).To(Succeed())
+			Expect(pod.Annotations).To(HaveKeyWithValue(modelFailedPromptsAnnotation, "1"))
+
+			By("restarting it once it reaches the failure threshold")
+			model.Status.LastPrompt = nil
+			_, err = r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(r.Get(ctx, types.NamespacedName{Name: "stuck", Namespace: key.Namespace}, pod))).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring("Restarted pod stuck")))
+
+			By("marking the model ready once every pod answers")
+			model.Status.LastPrompt = nil
+			_, err = r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.IsStatusConditionTrue(model.Status.Conditions, "Ready")).To(BeTrue())
+			Expect(recorder.Events).To(Receive(ContainSubstring("Answering")))
+		})
+
+		It("should prompt the pods concurrently within a single timeout", func() {
+			r, _, model := newHealthReconciler(nil)
+			r.Prober = hangingProber{}
+			model.Spec.HealthCheck.PromptTimeout = &metav1.Duration{Duration: 200 * time.Millisecond}
+			Expect(r.Create(ctx, newPod("stuck-2", true))).To(Succeed())
+
+			start := time.Now()
+			_, err := r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically("<", 400*time.Millisecond))
+			Expect(meta.FindStatusCondition(model.Status.Conditions, "Ready").Message).To(ContainSubstring("stuck-2"))
+		})
+
+		It("should not prompt a model without a prompt timeout", func() {
+			r, _, model := newHealthReconciler(stubProber{"stuck": errors.NewTimeoutError("no answer", 1)})
+			model.Spec.HealthCheck.PromptTimeout = nil
+
+			wait, err := r.reconcileModelHealth(ctx, model)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeZero())
+			Expect(meta.FindStatusCondition(model.Status.Conditions, "Ready")).To(BeNil())
+		})
+	})
+
+	Context("Helper functions", func() {
+		It("should double the retry delay up to the cap", func() {
+			r := &LLMModelReconciler{PullRetryBackoff: 10 * time.Second}
+
+			Expect(r.pullRetryDelay(1)).To(Equal(10 * time.Second))
+			Expect(r.pullRetryDelay(2)).To(Equal(20 * time.Second))
+			Expect(r.pullRetryDelay(3)).To(Equal(40 * time.Second))
+			Expect(r.pullRetryDelay(20)).To(Equal(maxPullRetryBackoff))
+		})
+
+		It("should tag the model reference with the size and quantization", func() {
+			Expect(ModelReference(&llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2"})).To(Equal("llama2"))
+			Expect(ModelReference(&llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", ModelSize: "7b"})).To(Equal("llama2:7b"))
+			Expect(ModelReference(&llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", ModelSize: "7b", Quantization: "q4_0"})).
+				To(Equal("llama2:7b-q4_0"))
+		})
+
+		It("should tolerate the GPU taint only for models requesting GPUs", func() {
+			model := &llmcloudv1alpha1.LLMModel{Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2"}}
+			Expect(modelTolerations(model)).To(BeEmpty())
+
+			model.Spec.Resources.GPU = 1
+			Expect(modelTolerations(model)).To(ConsistOf(corev1.Toleration{
+				Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule,
+			}))
+
+			model.Spec.Tolerations = []corev1.Toleration{{Operator: corev1.TolerationOpExists}}
+			Expect(modelTolerations(model)).To(Equal(model.Spec.Tolerations))
+		})
+
+		It("should report a stopped model as Stopped with no replicas", func() {
+			model := &llmcloudv1alpha1.LLMModel{Spec: llmcloudv1alpha1.LLMModelSpec{
+				ModelName:   "llama2",
+				Autoscaling: &llmcloudv1alpha1.ModelAutoscaling{MinReplicas: 2, MaxReplicas: 4},
+			}}
+			Expect(desiredModelReplicas(model)).To(Equal(int32(2)))
+
+			model.Spec.Stopped = true
+			Expect(desiredModelReplicas(model)).To(BeZero())
+			Expect(updateModelStatus(model, &appsv1.Deployment{}, DefaultModelImage)).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseStopped))
+		})
+
+		It("should scale on each autoscaling target that is set", func() {
+			metrics := modelAutoscalerMetrics(&llmcloudv1alpha1.ModelAutoscaling{
+				MaxReplicas:          3,
+				TargetConcurrency:    8,
+				ConcurrencyMetric:    "vllm_num_requests_waiting",
+				TargetGPUUtilization: 80,
+			})
+			Expect(metrics).To(HaveLen(2))
+			Expect(metrics[0].Pods.Metric.Name).To(Equal("vllm_num_requests_waiting"))
+			Expect(metrics[0].Pods.Target.AverageValue.String()).To(Equal("8"))
+			Expect(metrics[1].Pods.Metric.Name).To(Equal(llmcloudv1alpha1.GPUUtilizationMetric))
+			Expect(metrics[1].Pods.Target.AverageValue.String()).To(Equal("80"))
+
+			Expect(modelAutoscalerMetrics(&llmcloudv1alpha1.ModelAutoscaling{MaxReplicas: 3, TargetGPUUtilization: 50})).To(HaveLen(1))
+
+			By("scaling on CPU utilization without another target")
+			metrics = modelAutoscalerMetrics(&llmcloudv1alpha1.ModelAutoscaling{MaxReplicas: 3, TargetConcurrency: 8})
+			Expect(metrics).To(HaveLen(1))
+			Expect(metrics[0].Resource.Name).To(Equal(corev1.ResourceCPU))
+			Expect(*metrics[0].Resource.Target.AverageUtilization).To(Equal(int32(llmcloudv1alpha1.DefaultTargetCPUUtilization)))
+		})
+
+		It("should hold the model until its weights are downloaded", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "org/model", Provider: llmcloudv1alpha1.ProviderHuggingFace},
+			}
+			Expect(updateModelDownload(model, nil)).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+			Expect(model.Status.Download.Phase).To(Equal(llmcloudv1alpha1.ModelDownloadPhasePending))
+
+			job := &batchv1.Job{Status: batchv1.JobStatus{Active: 1, Failed: 1}}
+			Expect(updateModelDownload(model, job)).To(BeTrue())
+			Expect(model.Status.Download.Phase).To(Equal(llmcloudv1alpha1.ModelDownloadPhaseDownloading))
+			Expect(model.Status.Download.FailedAttempts).To(Equal(int32(1)))
+			Expect(updateModelDownload(model, job)).To(BeFalse())
+
+			job.Status = batchv1.JobStatus{Failed: 4, Conditions: []batchv1.JobCondition{{
+				Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "Job has reached the specified backoff limit",
+			}}}
+			Expect(updateModelDownload(model, job)).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseFailed))
+			Expect(model.Status.Download.Message).To(Equal("Job has reached the specified backoff limit"))
+		})
+
+		It("should mark the model running once every replica is ready", func() {
+			model := &llmcloudv1alpha1.LLMModel{
+				ObjectMeta: metav1.ObjectMeta{Name: "m", Namespace: "ns"},
+				Spec:       llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", Replicas: 2},
+			}
+			deployment := &appsv1.Deployment{Status: appsv1.DeploymentStatus{ReadyReplicas: 1}}
+			Expect(updateModelStatus(model, deployment, "img")).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhasePending))
+
+			deployment.Status.ReadyReplicas = 2
+			Expect(updateModelStatus(model, deployment, "img")).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseRunning))
+			Expect(updateModelStatus(model, deployment, "img")).To(BeFalse())
+
+			deployment.Status.Conditions = []appsv1.DeploymentCondition{{
+				Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded",
+			}}
+			Expect(updateModelStatus(model, deployment, "img")).To(BeTrue())
+			Expect(model.Status.Phase).To(Equal(llmcloudv1alpha1.LLMModelPhaseFailed))
+		})
+
+		It("should promote or roll back a canary by its error rate", func() {
+			now := metav1.Now()
+			model := &llmcloudv1alpha1.LLMModel{
+				Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama2", Rollout: &llmcloudv1alpha1.ModelRollout{
+					Canary: "llama3", MinRequests: 10, MaxErrorRate: 5,
+				}},
+			}
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			Expect(model.Status.Rollout.Phase).To(Equal(llmcloudv1alpha1.RolloutPhaseProgressing))
+			Expect(model.RolloutPhase()).To(Equal(llmcloudv1alpha1.RolloutPhaseProgressing))
+
+			By("waiting for the canary to serve enough requests")
+			model.Status.Rollout.CanaryRequests = 9
+			Expect(updateRolloutStatus(model, now)).To(BeFalse())
+
+			model.Status.Rollout.CanaryRequests = 20
+			model.Status.Rollout.CanaryErrors = 1
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			Expect(model.Status.Rollout.Phase).To(Equal(llmcloudv1alpha1.RolloutPhasePromoted))
+			Expect(updateRolloutStatus(model, now)).To(BeFalse())
+
+			By("starting over for a new canary")
+			model.Spec.Rollout.Canary = "llama4"
+			Expect(model.RolloutPhase()).To(BeEmpty())
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			Expect(model.Status.Rollout.CanaryRequests).To(BeZero())
+			model.Status.Rollout.CanaryRequests = 20
+			model.Status.Rollout.CanaryErrors = 2
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			Expect(model.Status.Rollout.Phase).To(Equal(llmcloudv1alpha1.RolloutPhaseRolledBack))
+			Expect(model.Status.Rollout.Message).To(Equal("2 of 20 canary requests failed (10.0%, at most 5% allowed)"))
+
+			By("never judging an A/B test")
+			model.Spec.Rollout = &llmcloudv1alpha1.ModelRollout{Canary: "llama5", Strategy: llmcloudv1alpha1.RolloutStrategyABTest}
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			model.Status.Rollout.CanaryRequests = 1000
+			Expect(updateRolloutStatus(model, now)).To(BeFalse())
+
+			model.Spec.Rollout = nil
+			Expect(updateRolloutStatus(model, now)).To(BeTrue())
+			Expect(model.Status.Rollout).To(BeNil())
+		})
+	})
+})
+
+// stubProber fails the prompts of the pods it maps to an error
+type stubProber map[string]error
+
+func (p stubProber) Prompt(_ context.Context, _ *llmcloudv1alpha1.LLMModel, pod *corev1.Pod) error {
+	return p[pod.Name]
+}
+
+// hangingProber never gets an answer, failing each prompt once its deadline passes
+type hangingProber struct{}
+
+func (hangingProber) Prompt(ctx context.Context, _ *llmcloudv1alpha1.LLMModel, _ *corev1.Pod) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
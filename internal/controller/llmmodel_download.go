@@ -0,0 +1,266 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultModelDownloadImage runs the download Jobs when ModelCache does not set an image
+	DefaultModelDownloadImage = "python:3.12-slim"
+	// DefaultModelCacheSize is the size of a model cache when ModelCache does not set one
+	DefaultModelCacheSize = "200Gi"
+	// modelCacheName is the PersistentVolumeClaim caching the Hugging Face models of a namespace
+	modelCacheName = "llm-model-cache"
+	// modelCachePath is where the model cache is mounted
+	modelCachePath = "/mnt/llm-models"
+	// modelCacheHub is the Hugging Face cache directory in the model cache
+	modelCacheHub = modelCachePath + "/hub"
+	// modelDownloadSourceAnnotation records on a download Job the repository it downloads
+	modelDownloadSourceAnnotation = "llmcloud.io/download-source"
+	// modelDownloadBackoffLimit is the number of download pods retried before a Job fails
+	modelDownloadBackoffLimit = 3
+)
+
+// modelDownloadScript downloads $MODEL into the Hugging Face cache at $HF_HUB_CACHE,
+// unless $MARKER records that an earlier Job downloaded it completely
+const modelDownloadScript = `set -e
+if [ -f "$MARKER" ]; then echo "$MODEL is cached"; exit 0; fi
+pip install --quiet --no-cache-dir huggingface_hub
+python -c 'import os; from huggingface_hub import snapshot_download; snapshot_download(os.environ["MODEL"])'
+mkdir -p "$(dirname "$MARKER")"
+touch "$MARKER"
+`
+
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+
+// ModelCache configures the volumes Hugging Face models are downloaded to, one per
+// namespace, so that every model of a repository shares one copy of its weights
+type ModelCache struct {
+	// Size is the size of each cache volume, DefaultModelCacheSize when empty
+	Size string
+	// StorageClass is the storage class of the cache volumes, the cluster default when empty
+	StorageClass string
+	// AccessMode is the access mode of the cache volumes, ReadWriteOnce when empty. With
+	// ReadWriteMany, the replicas of a model are not bound to the node of the volume
+	AccessMode string
+	// DownloadImage runs the download Jobs, DefaultModelDownloadImage when empty. It must
+	// provide python and pip
+	DownloadImage string
+}
+
+// downloadsWeights reports whether the model's weights are downloaded into the model
+// cache before it is served, which is the case for huggingface models
+func downloadsWeights(model *llmcloudv1alpha1.LLMModel) bool {
+	return model.Spec.Provider == llmcloudv1alpha1.ProviderHuggingFace
+}
+
+// modelDownloadName is the Job downloading a model's weights
+func modelDownloadName(model *llmcloudv1alpha1.LLMModel) string {
+	return model.Name + "-download"
+}
+
+// modelCacheDir returns the directory the Hugging Face cache keeps a repository in,
+// such as /mnt/llm-models/hub/models--mistralai--Mistral-7B-v0.1
+func modelCacheDir(spec *llmcloudv1alpha1.LLMModelSpec) string {
+	return modelCacheHub + "/models--" + strings.ReplaceAll(spec.ModelName, "/", "--")
+}
+
+// modelCacheMarker returns the file recording that a repository was downloaded completely
+func modelCacheMarker(spec *llmcloudv1alpha1.LLMModelSpec) string {
+	return modelCachePath + "/.llmcloud/" + strings.ReplaceAll(spec.ModelName, "/", "--")
+}
+
+// reconcileModelCache creates the model cache of namespace. It is not owned by a model,
+// so the weights stay cached when models are deleted and created again
+func (r *LLMModelReconciler) reconcileModelCache(ctx context.Context, namespace string) error {
+	pvc := &corev1.PersistentVolumeClaim{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: modelCacheName}, pvc)
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	size, err := resource.ParseQuantity(cmp.Or(r.Cache.Size, DefaultModelCacheSize))
+	if err != nil {
+		return err
+	}
+	pvc = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      modelCacheName,
+			Namespace: namespace,
+			Labels:    map[string]string{"llmcloud.io/managed": "true"},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				corev1.PersistentVolumeAccessMode(cmp.Or(r.Cache.AccessMode, string(corev1.ReadWriteOnce))),
+			},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if r.Cache.StorageClass != "" {
+		pvc.Spec.StorageClassName = &r.Cache.StorageClass
+	}
+	return client.IgnoreAlreadyExists(r.Create(ctx, pvc))
+}
+
+// reconcileModelDownload creates the model cache and the Job downloading the model's
+// weights into it, and returns the Job. The spec of a Job cannot change, so a Job
+// downloading another repository, or one that failed before the model was retried, is
+// deleted and nil is returned; the deletion requeues the model
+func (r *LLMModelReconciler) reconcileModelDownload(ctx context.Context, model *llmcloudv1alpha1.LLMModel) (*batchv1.Job, error) {
+	if err := r.reconcileModelCache(ctx, model.Namespace); err != nil {
+		return nil, err
+	}
+
+	job := &batchv1.Job{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: model.Namespace, Name: modelDownloadName(model)}, job)
+	if err == nil {
+		retried := jobFailed(job) && model.Status.Download != nil &&
+			model.Status.Download.Phase == llmcloudv1alpha1.ModelDownloadPhaseFailed
+		if job.Annotations[modelDownloadSourceAnnotation] == model.Spec.ModelName && !retried {
+			return job, nil
+		}
+		err := r.Delete(ctx, job, client.PropagationPolicy(metav1.DeletePropagationBackground))
+		return nil, client.IgnoreNotFound(err)
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	job = r.modelDownloadJob(model)
+	if err := controllerutil.SetControllerReference(model, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// modelDownloadJob returns the Job downloading the model's weights. It runs on the
+// nodes the model runs on, so that a cache volume bound to a node suits the model
+func (r *LLMModelReconciler) modelDownloadJob(model *llmcloudv1alpha1.LLMModel) *batchv1.Job {
+	labels := map[string]string{"llmcloud.io/managed": "true", modelLabel: model.Name}
+	backoffLimit := int32(modelDownloadBackoffLimit)
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        modelDownloadName(model),
+			Namespace:   model.Namespace,
+			Labels:      labels,
+			Annotations: map[string]string{modelDownloadSourceAnnotation: model.Spec.ModelName},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:            "download",
+						Image:           r.Images.Resolve(cmp.Or(r.Cache.DownloadImage, DefaultModelDownloadImage)),
+						ImagePullPolicy: corev1.PullPolicy(r.Images.PullPolicy),
+						Command:         []string{"sh", "-c", modelDownloadScript},
+						Env: []corev1.EnvVar{
+							{Name: "MODEL", Value: model.Spec.ModelName},
+							{Name: "HF_HUB_CACHE", Value: modelCacheHub},
+							{Name: "MARKER", Value: modelCacheMarker(&model.Spec)},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: modelCachePath}},
+					}},
+					NodeSelector: model.Spec.NodeSelector,
+					Tolerations:  modelTolerations(model),
+					Volumes: []corev1.Volume{{
+						Name: "cache",
+						VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+							ClaimName: modelCacheName,
+						}},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// jobFailed reports whether the Job has given up
+func jobFailed(job *batchv1.Job) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// updateModelDownload records the progress of the download Job in the model's status,
+// reporting whether anything changed. Until the weights are cached the model is Pending,
+// and it is Failed when the Job failed
+func updateModelDownload(model *llmcloudv1alpha1.LLMModel, job *batchv1.Job) bool {
+	download := &llmcloudv1alpha1.ModelDownload{
+		Phase: llmcloudv1alpha1.ModelDownloadPhasePending,
+		Path:  modelCacheDir(&model.Spec),
+	}
+	if job != nil {
+		download.StartTime = job.Status.StartTime.DeepCopy()
+		download.CompletionTime = job.Status.CompletionTime.DeepCopy()
+		download.FailedAttempts = job.Status.Failed
+		if job.Status.Active > 0 {
+			download.Phase = llmcloudv1alpha1.ModelDownloadPhaseDownloading
+		}
+		for _, c := range job.Status.Conditions {
+			if c.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch c.Type {
+			case batchv1.JobComplete:
+				download.Phase = llmcloudv1alpha1.ModelDownloadPhaseComplete
+			case batchv1.JobFailed:
+				download.Phase = llmcloudv1alpha1.ModelDownloadPhaseFailed
+				download.Message = c.Message
+			}
+		}
+	}
+
+	phase := model.Status.Phase
+	switch download.Phase {
+	case llmcloudv1alpha1.ModelDownloadPhaseComplete:
+	case llmcloudv1alpha1.ModelDownloadPhaseFailed:
+		phase = llmcloudv1alpha1.LLMModelPhaseFailed
+	default:
+		phase = llmcloudv1alpha1.LLMModelPhasePending
+	}
+
+	changed := model.Status.Phase != phase || !equality.Semantic.DeepEqual(model.Status.Download, download)
+	model.Status.Phase = phase
+	model.Status.Download = download
+	return changed
+}
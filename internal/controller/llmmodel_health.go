@@ -0,0 +1,240 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch;patch;delete
+
+// modelFailedPromptsAnnotation counts the prompts in a row a model pod failed to answer
+const modelFailedPromptsAnnotation = "llmcloud.io/failed-prompts"
+
+// ModelProber sends a prompt to a model server pod and returns an error unless it answered
+type ModelProber interface {
+	Prompt(ctx context.Context, model *llmcloudv1alpha1.LLMModel, pod *corev1.Pod) error
+}
+
+// HTTPModelProber asks the model server at the pod's IP for a one-token completion,
+// through the completion API of the protocol the model serves
+type HTTPModelProber struct {
+	// Client sends the prompts, http.DefaultClient when nil
+	Client *http.Client
+}
+
+// Prompt implements ModelProber
+func (p *HTTPModelProber) Prompt(ctx context.Context, model *llmcloudv1alpha1.LLMModel, pod *corev1.Pod) error {
+	if pod.Status.PodIP == "" {
+		return fmt.Errorf("pod %s has no IP", pod.Name)
+	}
+	body := map[string]interface{}{"prompt": "Hello", "stream": false}
+	route := "completions"
+	if model.Spec.EffectiveServeProtocol() == llmcloudv1alpha1.ServeProtocolOllama {
+		route = "generate"
+		body["model"] = ModelReference(&model.Spec)
+		body["options"] = map[string]int{"num_predict": 1}
+	} else {
+		body["model"] = model.Spec.ModelName
+		body["max_tokens"] = 1
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	host := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(modelPort(model))))
+	url := "http://" + host + model.Spec.ProxyRoutePrefix() + "/" + route
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := cmp.Or(p.Client, http.DefaultClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// podReady reports whether pod has the Ready condition
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// reconcileModelHealth prompts each ready pod of model once Interval has passed since
+// the last prompts, and restarts the pods that failed to answer FailureThreshold prompts
+// in a row within PromptTimeout. The Ready condition reports whether every ready pod
+// answered. It returns when to prompt again, or zero when the model's pods are not prompted
+func (r *LLMModelReconciler) reconcileModelHealth(ctx context.Context, model *llmcloudv1alpha1.LLMModel) (time.Duration, error) {
+	log := logf.FromContext(ctx)
+
+	check := model.Spec.HealthCheck
+	if r.Prober == nil || check == nil || check.PromptTimeout == nil {
+		// Prompts were turned off since they last reported
+		if cond := meta.FindStatusCondition(model.Status.Conditions, "Ready"); model.Status.LastPrompt != nil ||
+			cond != nil && (cond.Reason == "Answering" || cond.Reason == "NotAnswering") {
+			meta.RemoveStatusCondition(&model.Status.Conditions, "Ready")
+			model.Status.LastPrompt = nil
+			return 0, r.Status().Update(ctx, model)
+		}
+		return 0, nil
+	}
+	threshold := cmp.Or(check.FailureThreshold, llmcloudv1alpha1.DefaultHealthCheckFailureThreshold)
+	interval := llmcloudv1alpha1.DefaultHealthCheckInterval
+	if check.Interval != nil {
+		interval = check.Interval.Duration
+	}
+	// The model is reconciled for other reasons far more often than it is prompted
+	if last := model.Status.LastPrompt; last != nil {
+		if wait := interval - time.Since(last.Time); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(model.Namespace), client.MatchingLabels{modelLabel: model.Name}); err != nil {
+		return 0, err
+	}
+	var ready []*corev1.Pod
+	for i := range pods.Items {
+		if pod := &pods.Items[i]; podReady(pod) && pod.DeletionTimestamp.IsZero() {
+			ready = append(ready, pod)
+		}
+	}
+	promptErrs := r.promptPods(ctx, model, ready, check.PromptTimeout.Duration)
+
+	var failing []string
+	for i, pod := range ready {
+		failures, _ := strconv.Atoi(pod.Annotations[modelFailedPromptsAnnotation])
+		promptErr := promptErrs[i]
+		if promptErr == nil {
+			if failures > 0 {
+				if err := r.setFailedPrompts(ctx, pod, 0); err != nil {
+					return 0, err
+				}
+			}
+			continue
+		}
+
+		failures++
+		failing = append(failing, pod.Name)
+		log.Info("Model pod did not answer a prompt", "model", model.Name, "pod", pod.Name, "failures", failures, "reason", promptErr.Error())
+		if failures < int(threshold) {
+			if err := r.setFailedPrompts(ctx, pod, failures); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := r.Delete(ctx, pod); client.IgnoreNotFound(err) != nil {
+			return 0, err
+		}
+		recordEvent(r.Recorder, model, corev1.EventTypeWarning, "Restarted",
+			"Restarted pod %s, which did not answer %d prompts in a row within %s: %v", pod.Name, failures, check.PromptTimeout.Duration, promptErr)
+	}
+
+	condition := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		Reason:             "Answering",
+		Message:            "Every ready pod answers prompts",
+		ObservedGeneration: model.Generation,
+	}
+	if len(failing) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NotAnswering"
+		condition.Message = "Pods not answering prompts: " + strings.Join(failing, ", ")
+	}
+	wasReady := !meta.IsStatusConditionFalse(model.Status.Conditions, "Ready")
+	if meta.SetStatusCondition(&model.Status.Conditions, condition) {
+		switch {
+		case condition.Status == metav1.ConditionFalse && wasReady:
+			recordEvent(r.Recorder, model, corev1.EventTypeWarning, "NotAnswering", "%s", condition.Message)
+		case condition.Status == metav1.ConditionTrue && !wasReady:
+			recordEvent(r.Recorder, model, corev1.EventTypeNormal, "Answering", "%s", condition.Message)
+		}
+	}
+	now := metav1.Now()
+	model.Status.LastPrompt = &now
+	if err := r.Status().Update(ctx, model); err != nil {
+		return 0, err
+	}
+	return interval, nil
+}
+
+// promptPods prompts pods concurrently and returns the error of each. The prompts share
+// one deadline so that a model with many stuck pods holds up the reconcile for no
+// longer than a single timeout
+func (r *LLMModelReconciler) promptPods(ctx context.Context, model *llmcloudv1alpha1.LLMModel, pods []*corev1.Pod, timeout time.Duration) []error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	errs := make([]error, len(pods))
+	var wg sync.WaitGroup
+	for i, pod := range pods {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.Prober.Prompt(ctx, model, pod)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+// setFailedPrompts records the number of prompts in a row pod failed to answer
+func (r *LLMModelReconciler) setFailedPrompts(ctx context.Context, pod *corev1.Pod, failures int) error {
+	patch := client.MergeFrom(pod.DeepCopy())
+	if failures == 0 {
+		delete(pod.Annotations, modelFailedPromptsAnnotation)
+	} else {
+		if pod.Annotations == nil {
+			pod.Annotations = map[string]string{}
+		}
+		pod.Annotations[modelFailedPromptsAnnotation] = strconv.Itoa(failures)
+	}
+	return client.IgnoreNotFound(r.Patch(ctx, pod, patch))
+}
@@ -0,0 +1,68 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// updateRolloutStatus judges the model's canary rollout from the requests the inference
+// gateway counted in its status, reporting whether the status changed. A new canary
+// starts Progressing; once it has served MinRequests, it is Promoted if at most
+// MaxErrorRate percent of them failed, and RolledBack otherwise. An ABTest rollout
+// keeps progressing
+func updateRolloutStatus(model *llmcloudv1alpha1.LLMModel, now metav1.Time) bool {
+	rollout := model.Spec.Rollout
+	if rollout == nil {
+		if model.Status.Rollout == nil {
+			return false
+		}
+		model.Status.Rollout = nil
+		return true
+	}
+
+	status := model.Status.Rollout
+	if status == nil || status.Canary != rollout.Canary {
+		model.Status.Rollout = &llmcloudv1alpha1.ModelRolloutStatus{
+			Canary:             rollout.Canary,
+			Phase:              llmcloudv1alpha1.RolloutPhaseProgressing,
+			LastTransitionTime: &now,
+		}
+		return true
+	}
+	minRequests := cmp.Or(rollout.MinRequests, llmcloudv1alpha1.DefaultRolloutMinRequests)
+	if status.Phase != llmcloudv1alpha1.RolloutPhaseProgressing ||
+		rollout.Strategy == llmcloudv1alpha1.RolloutStrategyABTest ||
+		status.CanaryRequests < minRequests {
+		return false
+	}
+
+	errorRate := 100 * float64(status.CanaryErrors) / float64(status.CanaryRequests)
+	status.Phase = llmcloudv1alpha1.RolloutPhasePromoted
+	if errorRate > float64(rollout.MaxErrorRate) {
+		status.Phase = llmcloudv1alpha1.RolloutPhaseRolledBack
+	}
+	status.Message = fmt.Sprintf("%d of %d canary requests failed (%.1f%%, at most %d%% allowed)",
+		status.CanaryErrors, status.CanaryRequests, errorRate, rollout.MaxErrorRate)
+	status.LastTransitionTime = &now
+	return true
+}
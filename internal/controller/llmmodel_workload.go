@@ -0,0 +1,400 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultModelImage is the model server run when an LLMModel does not set an image
+	DefaultModelImage = "ollama/ollama:latest"
+	// modelWeightsSize is the size of the volume model weights are stored on
+	modelWeightsSize = "50Gi"
+	// modelLabel selects the pods of a model
+	modelLabel = "llmcloud.io/model"
+	// modelPullAttemptAnnotation records on the pod template which pull retry it belongs to
+	modelPullAttemptAnnotation = "llmcloud.io/pull-attempt"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+
+// runsOllama reports whether the model is served by ollama, either because it uses the
+// default image or because ollama is its provider. Ollama serves both the ollama and
+// the OpenAI API, so this is independent of the serve protocol
+func runsOllama(model *llmcloudv1alpha1.LLMModel) bool {
+	return model.Spec.Image == "" || model.Spec.Provider == llmcloudv1alpha1.ProviderOllama
+}
+
+// modelPort returns the port the model server listens on
+func modelPort(model *llmcloudv1alpha1.LLMModel) int32 {
+	if runsOllama(model) {
+		return 11434
+	}
+	return 8000
+}
+
+// modelDataPath returns the directory the model server keeps its weights in
+func modelDataPath(model *llmcloudv1alpha1.LLMModel) string {
+	if runsOllama(model) {
+		return "/root/.ollama"
+	}
+	if downloadsWeights(model) {
+		return modelCachePath
+	}
+	return "/models"
+}
+
+// ModelReference returns the name an ollama server pulls the model by, such as
+// llama2:7b-q4_0
+func ModelReference(spec *llmcloudv1alpha1.LLMModelSpec) string {
+	tag := spec.ModelSize
+	if spec.Quantization != "" {
+		if tag != "" {
+			tag += "-"
+		}
+		tag += spec.Quantization
+	}
+	if tag == "" {
+		return spec.ModelName
+	}
+	return spec.ModelName + ":" + tag
+}
+
+// modelWeightsName is the PersistentVolumeClaim holding a model's weights
+func modelWeightsName(model *llmcloudv1alpha1.LLMModel) string {
+	return model.Name + "-weights"
+}
+
+// modelWeightsClaim is the PersistentVolumeClaim a model's pods mount its weights from:
+// the model cache for downloaded weights, or the model's own weights volume
+func modelWeightsClaim(model *llmcloudv1alpha1.LLMModel) string {
+	if downloadsWeights(model) {
+		return modelCacheName
+	}
+	return modelWeightsName(model)
+}
+
+// modelWeightsAccessMode returns the access mode of the volume model mounts its weights
+// from: that of the model cache for downloaded weights, or the model's own
+func modelWeightsAccessMode(model *llmcloudv1alpha1.LLMModel, cache ModelCache) corev1.PersistentVolumeAccessMode {
+	if downloadsWeights(model) {
+		return corev1.PersistentVolumeAccessMode(cmp.Or(cache.AccessMode, string(corev1.ReadWriteOnce)))
+	}
+	return cmp.Or(model.Spec.WeightsAccessMode, corev1.ReadWriteOnce)
+}
+
+// checkModelWeightsAccess reports whether every replica the model may run can mount its
+// weights. When they cannot, a SharedWeightsRequired condition and warning event are
+// recorded so the model is not scaled onto nodes its volume cannot be attached to; the
+// condition is removed again once the spec is fixed
+func (r *LLMModelReconciler) checkModelWeightsAccess(ctx context.Context, model *llmcloudv1alpha1.LLMModel) (bool, error) {
+	if model.Spec.MaxReplicasWanted() > 1 && modelWeightsAccessMode(model, r.Cache) != corev1.ReadWriteMany {
+		message := fmt.Sprintf("%d replicas need a ReadWriteMany weights volume, but it is %s",
+			model.Spec.MaxReplicasWanted(), modelWeightsAccessMode(model, r.Cache))
+		log.FromContext(ctx).Info("Rejecting replicas", "name", model.Name, "reason", message)
+		if meta.SetStatusCondition(&model.Status.Conditions, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			Reason:             "SharedWeightsRequired",
+			Message:            message,
+			ObservedGeneration: model.Generation,
+		}) {
+			recordEvent(r.Recorder, model, corev1.EventTypeWarning, "SharedWeightsRequired", "%s", message)
+			return false, r.Status().Update(ctx, model)
+		}
+		return false, nil
+	}
+
+	if cond := meta.FindStatusCondition(model.Status.Conditions, "Ready"); cond != nil && cond.Reason == "SharedWeightsRequired" {
+		meta.RemoveStatusCondition(&model.Status.Conditions, "Ready")
+		return true, r.Status().Update(ctx, model)
+	}
+	return true, nil
+}
+
+// modelEndpoint is the in-cluster URL of a model's Service
+func modelEndpoint(model *llmcloudv1alpha1.LLMModel) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", model.Name, model.Namespace, modelPort(model))
+}
+
+// desiredModelReplicas is the number of replicas a model runs, at least one unless it
+// is stopped. An autoscaled model runs at least its MinReplicas
+func desiredModelReplicas(model *llmcloudv1alpha1.LLMModel) int32 {
+	if model.Spec.Stopped {
+		return 0
+	}
+	if autoscaling := model.Spec.Autoscaling; autoscaling != nil {
+		return max(autoscaling.MinReplicas, 1)
+	}
+	return max(model.Spec.Replicas, 1)
+}
+
+// reconcileModelWorkload creates or updates the weights volume, Deployment, ClusterIP
+// Service and HorizontalPodAutoscaler serving model, all owned by it, and returns the
+// Deployment
+func (r *LLMModelReconciler) reconcileModelWorkload(ctx context.Context, model *llmcloudv1alpha1.LLMModel, image string) (*appsv1.Deployment, error) {
+	resources, err := model.Spec.Resources.PodResources()
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{"llmcloud.io/managed": "true", modelLabel: model.Name}
+	selector := map[string]string{modelLabel: model.Name}
+
+	if !downloadsWeights(model) {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: modelWeightsName(model), Namespace: model.Namespace},
+		}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, pvc, func() error {
+			pvc.Labels = labels
+			// The spec of a bound claim is immutable, so it is only set on creation
+			if pvc.CreationTimestamp.IsZero() {
+				pvc.Spec.AccessModes = []corev1.PersistentVolumeAccessMode{modelWeightsAccessMode(model, r.Cache)}
+				pvc.Spec.Resources.Requests = corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse(modelWeightsSize),
+				}
+			}
+			return controllerutil.SetControllerReference(model, pvc, r.Scheme)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: model.Name, Namespace: model.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		deployment.Labels = labels
+		// The autoscaler owns the replica count of an autoscaled model while it runs
+		if model.Spec.Autoscaling == nil || model.Spec.Stopped || deployment.Spec.Replicas == nil {
+			replicas := desiredModelReplicas(model)
+			deployment.Spec.Replicas = &replicas
+		}
+		// The claim can only be mounted read-write on one node, so replicas are
+		// replaced rather than surged
+		deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		if deployment.CreationTimestamp.IsZero() {
+			deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		}
+		deployment.Spec.Template.Labels = labels
+		// Changing the attempt count restarts the pods, so each retry pulls again
+		deployment.Spec.Template.Annotations = nil
+		if model.Status.PullAttempts > 0 {
+			deployment.Spec.Template.Annotations = map[string]string{
+				modelPullAttemptAnnotation: strconv.Itoa(int(model.Status.PullAttempts)),
+			}
+		}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{r.modelContainer(model, image, resources)}
+		deployment.Spec.Template.Spec.NodeSelector = model.Spec.NodeSelector
+		deployment.Spec.Template.Spec.Tolerations = modelTolerations(model)
+		deployment.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "weights",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: modelWeightsClaim(model),
+			}},
+		}}
+		return controllerutil.SetControllerReference(model, deployment, r.Scheme)
+	}); err != nil {
+		return nil, err
+	}
+
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: model.Name, Namespace: model.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, service, func() error {
+		service.Labels = labels
+		service.Spec.Type = corev1.ServiceTypeClusterIP
+		service.Spec.Selector = selector
+		service.Spec.Ports = []corev1.ServicePort{{
+			Name:       "http",
+			Port:       modelPort(model),
+			TargetPort: intstr.FromString("http"),
+			Protocol:   corev1.ProtocolTCP,
+		}}
+		return controllerutil.SetControllerReference(model, service, r.Scheme)
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := r.reconcileModelAutoscaler(ctx, model, labels); err != nil {
+		return nil, err
+	}
+	return deployment, nil
+}
+
+// reconcileModelAutoscaler creates or updates the HorizontalPodAutoscaler scaling the
+// model's Deployment, or deletes it once the model is no longer autoscaled or stopped
+func (r *LLMModelReconciler) reconcileModelAutoscaler(ctx context.Context, model *llmcloudv1alpha1.LLMModel, labels map[string]string) error {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: model.Name, Namespace: model.Namespace}}
+	autoscaling := model.Spec.Autoscaling
+	if autoscaling == nil || model.Spec.Stopped {
+		return deleteOwned(ctx, r.Client, model, hpa)
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, hpa, func() error {
+		hpa.Labels = labels
+		hpa.Spec.ScaleTargetRef = autoscalingv2.CrossVersionObjectReference{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       model.Name,
+		}
+		minReplicas := desiredModelReplicas(model)
+		hpa.Spec.MinReplicas = &minReplicas
+		hpa.Spec.MaxReplicas = max(autoscaling.MaxReplicas, minReplicas)
+		hpa.Spec.Metrics = modelAutoscalerMetrics(autoscaling)
+		return controllerutil.SetControllerReference(model, hpa, r.Scheme)
+	})
+	return err
+}
+
+// modelAutoscalerMetrics returns a CPU utilization target and a per-pod average value
+// target for each of the autoscaler's other targets that is set. A concurrency target
+// stored before its metric was required is skipped
+func modelAutoscalerMetrics(autoscaling *llmcloudv1alpha1.ModelAutoscaling) []autoscalingv2.MetricSpec {
+	var metrics []autoscalingv2.MetricSpec
+	if utilization := autoscaling.CPUUtilizationTarget(); utilization != 0 {
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: corev1.ResourceCPU,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: &utilization,
+				},
+			},
+		})
+	}
+	for _, target := range []struct {
+		metric string
+		value  int32
+	}{
+		{autoscaling.ConcurrencyMetric, autoscaling.TargetConcurrency},
+		{llmcloudv1alpha1.GPUUtilizationMetric, autoscaling.TargetGPUUtilization},
+	} {
+		if target.metric == "" || target.value == 0 {
+			continue
+		}
+		averageValue := resource.NewQuantity(int64(target.value), resource.DecimalSI)
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: target.metric},
+				Target: autoscalingv2.MetricTarget{
+					Type:         autoscalingv2.AverageValueMetricType,
+					AverageValue: averageValue,
+				},
+			},
+		})
+	}
+	return metrics
+}
+
+// modelTolerations returns the model's tolerations, adding one for the taint GPU nodes
+// commonly carry when the model requests GPUs and does not tolerate it already
+func modelTolerations(model *llmcloudv1alpha1.LLMModel) []corev1.Toleration {
+	tolerations := model.Spec.Tolerations
+	if model.Spec.Resources.GPU == 0 {
+		return tolerations
+	}
+	for _, t := range tolerations {
+		if t.Key == string(llmcloudv1alpha1.GPUResourceName) || (t.Key == "" && t.Operator == corev1.TolerationOpExists) {
+			return tolerations
+		}
+	}
+	return append(slices.Clone(tolerations), corev1.Toleration{
+		Key:      string(llmcloudv1alpha1.GPUResourceName),
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoSchedule,
+	})
+}
+
+// modelProbeHandler probes the health check path and port of the model server
+func modelProbeHandler(model *llmcloudv1alpha1.LLMModel) corev1.ProbeHandler {
+	port := intstr.FromString("http")
+	if check := model.Spec.HealthCheck; check != nil && check.Port != 0 {
+		port = intstr.FromInt32(check.Port)
+	}
+	return corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: model.Spec.HealthCheckPath(), Port: port}}
+}
+
+// modelContainer returns the model server container. An ollama server pulls the model
+// once it is listening, and is only ready after the pull has finished. A Hugging Face
+// server finds the weights downloaded into the model cache
+func (r *LLMModelReconciler) modelContainer(model *llmcloudv1alpha1.LLMModel, image string, resources corev1.ResourceRequirements) corev1.Container {
+	container := corev1.Container{
+		Name:            "model",
+		Image:           image,
+		ImagePullPolicy: corev1.PullPolicy(r.Images.PullPolicy),
+		Ports:           []corev1.ContainerPort{{Name: "http", ContainerPort: modelPort(model), Protocol: corev1.ProtocolTCP}},
+		Resources:       resources,
+		// Downloaded weights are shared with the other models of the namespace, which
+		// only read them
+		VolumeMounts: []corev1.VolumeMount{{Name: "weights", MountPath: modelDataPath(model), ReadOnly: downloadsWeights(model)}},
+		ReadinessProbe: &corev1.Probe{
+			ProbeHandler:  modelProbeHandler(model),
+			PeriodSeconds: 10,
+		},
+		// Loading a large model can take a long while, during which the server is not
+		// restarted
+		StartupProbe: &corev1.Probe{
+			ProbeHandler:     modelProbeHandler(model),
+			PeriodSeconds:    10,
+			FailureThreshold: 180,
+		},
+		LivenessProbe: &corev1.Probe{
+			ProbeHandler:     modelProbeHandler(model),
+			PeriodSeconds:    10,
+			TimeoutSeconds:   5,
+			FailureThreshold: 3,
+		},
+	}
+	if runsOllama(model) {
+		container.Env = []corev1.EnvVar{{Name: "OLLAMA_HOST", Value: fmt.Sprintf("0.0.0.0:%d", modelPort(model))}}
+		if model.Spec.ContextLength > 0 {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  "OLLAMA_CONTEXT_LENGTH",
+				Value: strconv.Itoa(int(model.Spec.ContextLength)),
+			})
+		}
+		container.Lifecycle = &corev1.Lifecycle{PostStart: &corev1.LifecycleHandler{Exec: &corev1.ExecAction{
+			Command: []string{"sh", "-c", "until ollama list >/dev/null 2>&1; do sleep 1; done; ollama pull \"$0\"", ModelReference(&model.Spec)},
+		}}}
+	}
+	if downloadsWeights(model) {
+		// Hugging Face servers load the repository from the cache without going online
+		container.Env = []corev1.EnvVar{
+			{Name: "HF_HUB_CACHE", Value: modelCacheHub},
+			{Name: "HF_HUB_OFFLINE", Value: "1"},
+		}
+	}
+	return container
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	localPathNamespace  = "local-path-storage"
+	localPathConfigMap  = "local-path-config"
+	localPathConfigKey  = "config.json"
+	localPathDefaultKey = "DEFAULT_PATH_FOR_NON_LISTED_NODES"
+)
+
+// LocalPathConfigReconciler keeps the local-path provisioner's default storage path
+// pointed at the VM disk directory, repairing the ConfigMap if it drifts (for
+// example after the provisioner is reinstalled)
+type LocalPathConfigReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// DiskPath is the directory new local-path volumes must be created in
+	DiskPath string
+}
+
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;patch
+
+// Reconcile repairs the local-path provisioner config when its default path differs from DiskPath
+func (r *LocalPathConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, req.NamespacedName, cm); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	repaired, changed, err := repairLocalPathConfig(cm.Data[localPathConfigKey], r.DiskPath)
+	if err != nil {
+		logger.Error(err, "Cannot parse local-path provisioner config, leaving it unchanged")
+		return ctrl.Result{}, nil
+	}
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[localPathConfigKey] = repaired
+	if err := r.Update(ctx, cm); err != nil {
+		return ctrl.Result{}, err
+	}
+	logger.Info("Repaired local-path provisioner config drift", "path", r.DiskPath)
+	return ctrl.Result{}, nil
+}
+
+// repairLocalPathConfig returns configJSON with the default node path set to diskPath,
+// and whether anything had to change. Other settings and per-node entries are kept
+func repairLocalPathConfig(configJSON, diskPath string) (string, bool, error) {
+	config := map[string]interface{}{}
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
+			return "", false, fmt.Errorf("invalid %s: %w", localPathConfigKey, err)
+		}
+	}
+
+	nodePathMap, _ := config["nodePathMap"].([]interface{})
+	defaultEntry := map[string]interface{}{
+		"node":  localPathDefaultKey,
+		"paths": []interface{}{diskPath},
+	}
+
+	found := false
+	for i, entry := range nodePathMap {
+		e, ok := entry.(map[string]interface{})
+		if !ok || e["node"] != localPathDefaultKey {
+			continue
+		}
+		found = true
+		if paths, ok := e["paths"].([]interface{}); ok && len(paths) == 1 && paths[0] == diskPath {
+			return configJSON, false, nil
+		}
+		nodePathMap[i] = defaultEntry
+	}
+	if !found {
+		nodePathMap = append(nodePathMap, defaultEntry)
+	}
+	config["nodePathMap"] = nodePathMap
+
+	repaired, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return "", false, err
+	}
+	return string(repaired), true, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *LocalPathConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetNamespace() == localPathNamespace && obj.GetName() == localPathConfigMap
+		}))).
+		Named("localpathconfig").
+		Complete(r)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("LocalPathConfig Controller", func() {
+	const diskPath = "/mnt/vm-disks"
+
+	// upstreamConfig is the config shipped by the local-path provisioner manifest
+	const upstreamConfig = `{"nodePathMap":[{"node":"DEFAULT_PATH_FOR_NON_LISTED_NODES","paths":["/opt/local-path-provisioner"]}]}`
+
+	defaultPaths := func(configJSON string) []interface{} {
+		config := map[string]interface{}{}
+		Expect(json.Unmarshal([]byte(configJSON), &config)).To(Succeed())
+		for _, entry := range config["nodePathMap"].([]interface{}) {
+			e := entry.(map[string]interface{})
+			if e["node"] == localPathDefaultKey {
+				return e["paths"].([]interface{})
+			}
+		}
+		return nil
+	}
+
+	It("should leave a config that already uses the disk path unchanged", func() {
+		configJSON := `{"nodePathMap":[{"node":"DEFAULT_PATH_FOR_NON_LISTED_NODES","paths":["/mnt/vm-disks"]}]}`
+		repaired, changed, err := repairLocalPathConfig(configJSON, diskPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeFalse())
+		Expect(repaired).To(Equal(configJSON))
+	})
+
+	It("should detect and repair a reverted default path", func() {
+		repaired, changed, err := repairLocalPathConfig(upstreamConfig, diskPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(defaultPaths(repaired)).To(Equal([]interface{}{diskPath}))
+	})
+
+	It("should keep per-node entries and other settings", func() {
+		configJSON := `{"nodePathMap":[{"node":"worker-1","paths":["/data"]}],"setupCommand":"/bin/setup"}`
+		repaired, changed, err := repairLocalPathConfig(configJSON, diskPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeTrue())
+		Expect(defaultPaths(repaired)).To(Equal([]interface{}{diskPath}))
+		Expect(repaired).To(ContainSubstring(`"worker-1"`))
+		Expect(repaired).To(ContainSubstring(`"setupCommand"`))
+	})
+
+	It("should reject a config that is not valid JSON", func() {
+		_, _, err := repairLocalPathConfig("{not json", diskPath)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should update the drifted ConfigMap when reconciling", func() {
+		testScheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: localPathConfigMap, Namespace: localPathNamespace},
+			Data:       map[string]string{localPathConfigKey: upstreamConfig},
+		}
+		r := &LocalPathConfigReconciler{
+			Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(cm).Build(),
+			Scheme:   testScheme,
+			DiskPath: diskPath,
+		}
+
+		key := types.NamespacedName{Name: localPathConfigMap, Namespace: localPathNamespace}
+		_, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		updated := &corev1.ConfigMap{}
+		Expect(r.Get(context.Background(), key, updated)).To(Succeed())
+		Expect(defaultPaths(updated.Data[localPathConfigKey])).To(Equal([]interface{}{diskPath}))
+	})
+})
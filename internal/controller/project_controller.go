@@ -17,27 +17,44 @@ limitations under the License.
 package controller
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
 )
 
 // ProjectReconciler reconciles a Project object
 type ProjectReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder emits events for project lifecycle changes, exhausted quotas and
+	// reconcile errors
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=projects,verbs=get;list;watch;create;update;patch;delete
@@ -46,26 +63,57 @@ type ProjectReconciler struct {
 // +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=virtualmachines;llmmodels;services,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=groups,verbs=get;list;watch
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=resourcequotas;limitranges,verbs=get;list;watch;create;update;patch;delete
 
 const (
 	projectFinalizer = "llmcloud.llmcloud.io/finalizer"
+
+	// projectEgressPolicyName is the NetworkPolicy enforcing a project's egress rules
+	projectEgressPolicyName = "llmcloud-egress"
+
+	// projectIsolationPolicyName is the NetworkPolicy isolating a project from other projects
+	projectIsolationPolicyName = "llmcloud-isolation"
+
+	// projectResourceQuotaName is the ResourceQuota enforcing a project's resource quotas
+	projectResourceQuotaName = "llmcloud-quota"
+
+	// projectLimitRangeName is the LimitRange giving containers in a project with CPU or
+	// memory quotas default requests
+	projectLimitRangeName = "llmcloud-limits"
+
+	// projectTerminatingCondition reports the progress of deleting a project
+	projectTerminatingCondition = "Terminating"
+
+	// projectQuotaExceededCondition reports the resource quotas a project's usage has reached
+	projectQuotaExceededCondition = "QuotaExceeded"
+
+	// projectFinalizeInterval is how often a project being deleted is rechecked while
+	// its resources and namespace go away
+	projectFinalizeInterval = 5 * time.Second
 )
 
-func (r *ProjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ProjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	log := logf.FromContext(ctx)
 
 	project := &llmcloudv1alpha1.Project{}
 	if err := r.Get(ctx, req.NamespacedName, project); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	defer func() { recordReconcileError(r.Recorder, project, err) }()
 
 	if !project.DeletionTimestamp.IsZero() {
 		if controllerutil.ContainsFinalizer(project, projectFinalizer) {
-			if err := r.finalizeProject(ctx, project); err != nil {
+			done, err := r.finalizeProject(ctx, project)
+			if err != nil {
 				return ctrl.Result{}, err
 			}
-			controllerutil.RemoveFinalizer(project, projectFinalizer)
-			return ctrl.Result{}, r.Update(ctx, project)
+			if !done {
+				return ctrl.Result{RequeueAfter: projectFinalizeInterval}, nil
+			}
+			return ctrl.Result{}, removeFinalizer(ctx, r.Client, project, projectFinalizer)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -75,6 +123,16 @@ func (r *ProjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{Requeue: true}, r.Update(ctx, project)
 	}
 
+	// An invalid quota cannot be fixed by retrying, so report it and wait for the spec to change
+	if err := llmcloudv1alpha1.ValidateResourceQuotas(project.Spec.ResourceQuotas); err != nil {
+		log.Info("Invalid resource quotas", "project", project.Name, "reason", err.Error())
+		if cond := meta.FindStatusCondition(project.Status.Conditions, "Ready"); cond == nil || cond.Message != err.Error() {
+			recordEvent(r.Recorder, project, corev1.EventTypeWarning, "InvalidQuota", "%v", err)
+		}
+		r.updateStatus(ctx, project, "Error", err.Error())
+		return ctrl.Result{}, nil
+	}
+
 	namespace := fmt.Sprintf("project-%s", project.Name)
 	if err := r.reconcileNamespace(ctx, project, namespace); err != nil {
 		log.Error(err, "Failed to reconcile namespace")
@@ -88,6 +146,40 @@ func (r *ProjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
+	if err := r.reconcileEgressPolicy(ctx, project, namespace); err != nil {
+		log.Error(err, "Failed to reconcile egress policy")
+		r.updateStatus(ctx, project, "Error", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileIsolationPolicy(ctx, project, namespace); err != nil {
+		log.Error(err, "Failed to reconcile isolation policy")
+		r.updateStatus(ctx, project, "Error", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	exhausted, err := r.reconcileResourceQuota(ctx, project, namespace)
+	if err != nil {
+		log.Error(err, "Failed to reconcile resource quota")
+		r.updateStatus(ctx, project, "Error", err.Error())
+		return ctrl.Result{}, err
+	}
+	r.updateQuotaCondition(project, exhausted)
+
+	if err := r.reconcileLimitRange(ctx, project, namespace); err != nil {
+		log.Error(err, "Failed to reconcile limit range")
+		r.updateStatus(ctx, project, "Error", err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileWorkloadStatus(ctx, project, namespace); err != nil {
+		log.Error(err, "Failed to summarize project workloads")
+		return ctrl.Result{}, err
+	}
+
+	if project.Status.Phase != "Active" {
+		recordEvent(r.Recorder, project, corev1.EventTypeNormal, "Active", "Project namespace %s is ready", namespace)
+	}
 	project.Status.Namespace = namespace
 	project.Status.Phase = "Active"
 	meta.SetStatusCondition(&project.Status.Conditions, metav1.Condition{
@@ -105,12 +197,9 @@ func (r *ProjectReconciler) reconcileNamespace(ctx context.Context, project *llm
 	ns := &corev1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
-			Labels: map[string]string{
-				"llmcloud.io/project": project.Name,
-				"llmcloud.io/managed": "true",
-			},
 		},
 	}
+	setNamespaceMetadata(ns, project)
 
 	if err := controllerutil.SetControllerReference(project, ns, r.Scheme); err != nil {
 		return err
@@ -118,28 +207,55 @@ func (r *ProjectReconciler) reconcileNamespace(ctx context.Context, project *llm
 
 	existingNS := &corev1.Namespace{}
 	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, existingNS); err != nil {
-		if errors.IsNotFound(err) {
-			return r.Create(ctx, ns)
+		if !errors.IsNotFound(err) {
+			return err
 		}
-		return err
+		if err := r.Create(ctx, ns); err != nil {
+			return err
+		}
+		recordEvent(r.Recorder, project, corev1.EventTypeNormal, "NamespaceCreated", "Created namespace %s", namespace)
+		return nil
 	}
 
-	existingNS.Labels = ns.Labels
+	existingNS.Labels = nil
+	setNamespaceMetadata(existingNS, project)
 	return r.Update(ctx, existingNS)
 }
 
+// setNamespaceMetadata applies the labels and annotations of project to its namespace,
+// then the operator's own labels, so that the project's cannot replace them
+func setNamespaceMetadata(ns *corev1.Namespace, project *llmcloudv1alpha1.Project) {
+	syncProjectMetadata(&ns.Labels, &ns.Annotations, project, true)
+	if ns.Labels == nil {
+		ns.Labels = map[string]string{}
+	}
+	ns.Labels["llmcloud.io/project"] = project.Name
+	ns.Labels["llmcloud.io/managed"] = "true"
+}
+
+// reconcileRBAC binds each member's role in the project namespace. A group member is
+// bound for every user in the Group, or for nobody while the Group does not exist
 func (r *ProjectReconciler) reconcileRBAC(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) error {
 	for _, member := range project.Spec.Members {
+		name := fmt.Sprintf("%s-%s", project.Name, member.Username)
+		subjects := []rbacv1.Subject{{Kind: "User", Name: member.Username}}
+		if group := member.Group(); group != "" {
+			name = fmt.Sprintf("%s-group-%s", project.Name, group)
+			var err error
+			if subjects, err = r.groupSubjects(ctx, group); err != nil {
+				return err
+			}
+		}
 		rb := &rbacv1.RoleBinding{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      fmt.Sprintf("%s-%s", project.Name, member.Username),
+				Name:      name,
 				Namespace: namespace,
 				Labels: map[string]string{
 					"llmcloud.io/project": project.Name,
 					"llmcloud.io/managed": "true",
 				},
 			},
-			Subjects: []rbacv1.Subject{{Kind: "User", Name: member.Username}},
+			Subjects: subjects,
 			RoleRef: rbacv1.RoleRef{
 				APIGroup: "rbac.authorization.k8s.io",
 				Kind:     "ClusterRole",
@@ -167,6 +283,491 @@ func (r *ProjectReconciler) reconcileRBAC(ctx context.Context, project *llmcloud
 	return nil
 }
 
+// groupSubjects returns a RoleBinding subject for each user in the named Group, or
+// none while the Group does not exist
+func (r *ProjectReconciler) groupSubjects(ctx context.Context, name string) ([]rbacv1.Subject, error) {
+	group := &llmcloudv1alpha1.Group{}
+	if err := r.Get(ctx, client.ObjectKey{Name: name}, group); err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	subjects := []rbacv1.Subject{}
+	for _, username := range group.Spec.Members {
+		subjects = append(subjects, rbacv1.Subject{Kind: "User", Name: username})
+	}
+	return subjects, nil
+}
+
+// reconcileEgressPolicy creates or updates the project's egress NetworkPolicy, or
+// removes it once the project no longer restricts egress
+func (r *ProjectReconciler) reconcileEgressPolicy(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) error {
+	if project.Spec.Egress == nil {
+		return r.reconcileNetworkPolicy(ctx, project, namespace, projectEgressPolicyName, nil)
+	}
+	policy, err := buildEgressPolicy(project, namespace)
+	if err != nil {
+		return err
+	}
+	return r.reconcileNetworkPolicy(ctx, project, namespace, projectEgressPolicyName, policy)
+}
+
+// reconcileIsolationPolicy creates or updates the NetworkPolicy isolating the project
+// namespace, or removes it once the project's isolation mode is none
+func (r *ProjectReconciler) reconcileIsolationPolicy(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) error {
+	if project.Spec.IsolationMode == llmcloudv1alpha1.IsolationModeNone {
+		return r.reconcileNetworkPolicy(ctx, project, namespace, projectIsolationPolicyName, nil)
+	}
+	policy, err := buildIsolationPolicy(project, namespace)
+	if err != nil {
+		return err
+	}
+	return r.reconcileNetworkPolicy(ctx, project, namespace, projectIsolationPolicyName, policy)
+}
+
+// reconcileNetworkPolicy creates or updates policy in the project namespace, or deletes
+// the project's NetworkPolicy named name when policy is nil
+func (r *ProjectReconciler) reconcileNetworkPolicy(ctx context.Context, project *llmcloudv1alpha1.Project, namespace, name string,
+	policy *networkingv1.NetworkPolicy) error {
+	existing := &networkingv1.NetworkPolicy{}
+	err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	if policy == nil {
+		if found {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+	if err := controllerutil.SetControllerReference(project, policy, r.Scheme); err != nil {
+		return err
+	}
+
+	if !found {
+		return r.Create(ctx, policy)
+	}
+	existing.Labels = policy.Labels
+	existing.Spec = policy.Spec
+	return r.Update(ctx, existing)
+}
+
+// buildEgressPolicy returns a NetworkPolicy that denies all egress from the project
+// namespace except DNS, traffic within the namespace and the project's allowed destinations
+func buildEgressPolicy(project *llmcloudv1alpha1.Project, namespace string) (*networkingv1.NetworkPolicy, error) {
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	dnsPort := intstr.FromInt32(53)
+
+	rules := []networkingv1.NetworkPolicyEgressRule{
+		{
+			// DNS to any namespace, so cluster and upstream resolvers keep working
+			To: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+		{
+			To: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		},
+	}
+
+	egress := project.Spec.Egress
+	peers, err := allowedPeers(egress.AllowedCIDRs, egress.AllowedNamespaces)
+	if err != nil {
+		return nil, fmt.Errorf("invalid egress: %w", err)
+	}
+	for _, peer := range peers {
+		rules = append(rules, networkingv1.NetworkPolicyEgressRule{To: peer})
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: projectPolicyMeta(project, namespace, projectEgressPolicyName),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			Egress:      rules,
+		},
+	}, nil
+}
+
+// buildIsolationPolicy returns a NetworkPolicy that only admits traffic into the project
+// namespace from its own pods and from namespaces that are not projects, such as the
+// operator's and the ingress controller's, plus the project's allowed sources in custom
+// isolation mode
+func buildIsolationPolicy(project *llmcloudv1alpha1.Project, namespace string) (*networkingv1.NetworkPolicy, error) {
+	rules := []networkingv1.NetworkPolicyIngressRule{
+		{
+			From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		},
+		{
+			From: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{
+						Key:      "llmcloud.io/project",
+						Operator: metav1.LabelSelectorOpDoesNotExist,
+					}},
+				},
+			}},
+		},
+	}
+
+	if ingress := project.Spec.Ingress; ingress != nil && project.Spec.IsolationMode == llmcloudv1alpha1.IsolationModeCustom {
+		peers, err := allowedPeers(ingress.AllowedCIDRs, ingress.AllowedNamespaces)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ingress: %w", err)
+		}
+		for _, peer := range peers {
+			rules = append(rules, networkingv1.NetworkPolicyIngressRule{From: peer})
+		}
+	}
+
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: projectPolicyMeta(project, namespace, projectIsolationPolicyName),
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     rules,
+		},
+	}, nil
+}
+
+// allowedPeers returns the NetworkPolicy peers of one rule per kind of destination or
+// source: the CIDRs, then the namespaces
+func allowedPeers(cidrs, namespaces []string) ([][]networkingv1.NetworkPolicyPeer, error) {
+	var peers [][]networkingv1.NetworkPolicyPeer
+	if len(cidrs) > 0 {
+		var blocks []networkingv1.NetworkPolicyPeer
+		for _, cidr := range cidrs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q: %w", cidr, err)
+			}
+			blocks = append(blocks, networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}})
+		}
+		peers = append(peers, blocks)
+	}
+	if len(namespaces) > 0 {
+		peers = append(peers, []networkingv1.NetworkPolicyPeer{{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{
+					Key:      corev1.LabelMetadataName,
+					Operator: metav1.LabelSelectorOpIn,
+					Values:   namespaces,
+				}},
+			},
+		}})
+	}
+	return peers, nil
+}
+
+// projectPolicyMeta returns the metadata of the project's NetworkPolicy named name
+func projectPolicyMeta(project *llmcloudv1alpha1.Project, namespace, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels: map[string]string{
+			"llmcloud.io/project": project.Name,
+			"llmcloud.io/managed": "true",
+		},
+	}
+}
+
+// reconcileResourceQuota creates or updates the ResourceQuota enforcing the project's
+// resource quotas in its namespace, or removes it once the project has none. It returns
+// the quotas the namespace's usage has reached, as last observed in the quota's status
+func (r *ProjectReconciler) reconcileResourceQuota(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) ([]string, error) {
+	existing := &corev1.ResourceQuota{}
+	err := r.Get(ctx, client.ObjectKey{Name: projectResourceQuotaName, Namespace: namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, err
+	}
+	found := err == nil
+
+	hard, err := buildQuotaLimits(project.Spec.ResourceQuotas)
+	if err != nil {
+		return nil, err
+	}
+	if len(hard) == 0 {
+		if found {
+			return nil, client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil, nil
+	}
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      projectResourceQuotaName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"llmcloud.io/project": project.Name,
+				"llmcloud.io/managed": "true",
+			},
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}
+	if err := controllerutil.SetControllerReference(project, quota, r.Scheme); err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, r.Create(ctx, quota)
+	}
+	existing.Labels = quota.Labels
+	existing.Spec = quota.Spec
+	return exhaustedQuotas(existing), r.Update(ctx, existing)
+}
+
+// projectDefaultRequests are the requests given to containers without their own in a
+// project whose CPU or memory requests are bounded by a quota, which rejects pods that
+// leave them out. No default limits are set: one below a container's own request would
+// have the pod rejected as well
+var projectDefaultRequests = corev1.ResourceList{
+	corev1.ResourceCPU:    resource.MustParse("100m"),
+	corev1.ResourceMemory: resource.MustParse("128Mi"),
+}
+
+// reconcileLimitRange creates or updates the LimitRange giving containers in the
+// project's namespace default requests for the resources its quotas bound, or removes
+// it once they bound neither CPU nor memory
+func (r *ProjectReconciler) reconcileLimitRange(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) error {
+	existing := &corev1.LimitRange{}
+	err := r.Get(ctx, client.ObjectKey{Name: projectLimitRangeName, Namespace: namespace}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+
+	hard, err := buildQuotaLimits(project.Spec.ResourceQuotas)
+	if err != nil {
+		return err
+	}
+	defaults := corev1.ResourceList{}
+	for name, requests := range map[corev1.ResourceName]corev1.ResourceName{
+		corev1.ResourceCPU:    corev1.ResourceRequestsCPU,
+		corev1.ResourceMemory: corev1.ResourceRequestsMemory,
+	} {
+		if _, ok := hard[requests]; ok {
+			defaults[name] = projectDefaultRequests[name]
+		}
+	}
+	if len(defaults) == 0 {
+		if found {
+			return client.IgnoreNotFound(r.Delete(ctx, existing))
+		}
+		return nil
+	}
+
+	limitRange := &corev1.LimitRange{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      projectLimitRangeName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"llmcloud.io/project": project.Name,
+				"llmcloud.io/managed": "true",
+			},
+		},
+		Spec: corev1.LimitRangeSpec{
+			Limits: []corev1.LimitRangeItem{{
+				Type:           corev1.LimitTypeContainer,
+				DefaultRequest: defaults,
+			}},
+		},
+	}
+	if err := controllerutil.SetControllerReference(project, limitRange, r.Scheme); err != nil {
+		return err
+	}
+
+	if !found {
+		return r.Create(ctx, limitRange)
+	}
+	existing.Labels = limitRange.Labels
+	existing.Spec = limitRange.Spec
+	return r.Update(ctx, existing)
+}
+
+// exhaustedQuotas returns the resources of quota whose usage has reached their hard
+// limit, as "resource (used/hard)"
+func exhaustedQuotas(quota *corev1.ResourceQuota) []string {
+	var exhausted []string
+	for name, hard := range quota.Status.Hard {
+		if used, ok := quota.Status.Used[name]; ok && used.Cmp(hard) >= 0 {
+			exhausted = append(exhausted, fmt.Sprintf("%s (%s/%s)", name, used.String(), hard.String()))
+		}
+	}
+	slices.Sort(exhausted)
+	return exhausted
+}
+
+// updateQuotaCondition sets the QuotaExceeded condition of a project whose usage has
+// reached some of its quotas, recording a warning whenever those change, and removes
+// the condition once there is room again
+func (r *ProjectReconciler) updateQuotaCondition(project *llmcloudv1alpha1.Project, exhausted []string) {
+	if len(exhausted) == 0 {
+		meta.RemoveStatusCondition(&project.Status.Conditions, projectQuotaExceededCondition)
+		return
+	}
+	message := "Reached the quota of " + strings.Join(exhausted, ", ")
+	if meta.SetStatusCondition(&project.Status.Conditions, metav1.Condition{
+		Type:               projectQuotaExceededCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "QuotaExhausted",
+		Message:            message,
+		ObservedGeneration: project.Generation,
+	}) {
+		recordEvent(r.Recorder, project, corev1.EventTypeWarning, "QuotaExceeded", "%s", message)
+	}
+}
+
+// buildQuotaLimits converts project quotas to ResourceQuota limits. VMs and LLM models
+// are counted as objects, and CPU and memory bound the requests of the namespace's pods
+func buildQuotaLimits(quotas *llmcloudv1alpha1.ProjectResourceQuotas) (corev1.ResourceList, error) {
+	hard := corev1.ResourceList{}
+	if quotas == nil {
+		return hard, nil
+	}
+	if quotas.MaxVMs != nil {
+		hard[corev1.ResourceName("count/virtualmachines."+llmcloudv1alpha1.GroupVersion.Group)] = *resource.NewQuantity(int64(*quotas.MaxVMs), resource.DecimalSI)
+	}
+	if quotas.MaxLLMModels != nil {
+		hard[corev1.ResourceName("count/llmmodels."+llmcloudv1alpha1.GroupVersion.Group)] = *resource.NewQuantity(int64(*quotas.MaxLLMModels), resource.DecimalSI)
+	}
+	for name, value := range map[corev1.ResourceName]*string{
+		corev1.ResourceRequestsCPU:    quotas.MaxCPU,
+		corev1.ResourceRequestsMemory: quotas.MaxMemory,
+	} {
+		if value == nil {
+			continue
+		}
+		q, err := resource.ParseQuantity(*value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s quota %q: %w", name, *value, err)
+		}
+		hard[name] = q
+	}
+	return hard, nil
+}
+
+// reconcileWorkloadStatus counts the VMs, models and services in the project
+// namespace and records how many of each are ready
+func (r *ProjectReconciler) reconcileWorkloadStatus(ctx context.Context, project *llmcloudv1alpha1.Project, namespace string) error {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := r.List(ctx, &vms, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	var models llmcloudv1alpha1.LLMModelList
+	if err := r.List(ctx, &models, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	var services llmcloudv1alpha1.ServiceList
+	if err := r.List(ctx, &services, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	var readyVMs, readyModels, readyServices int32
+	for i := range vms.Items {
+		if vms.Items[i].Status.Ready {
+			readyVMs++
+		}
+	}
+	for i := range models.Items {
+		if isLLMModelReady(&models.Items[i]) {
+			readyModels++
+		}
+	}
+	for i := range services.Items {
+		if isServiceReady(&services.Items[i]) {
+			readyServices++
+		}
+	}
+
+	project.Status.VMCount = int32(len(vms.Items))
+	project.Status.LLMModelCount = int32(len(models.Items))
+	project.Status.ServiceCount = int32(len(services.Items))
+	project.Status.ReadyVMCount = readyVMs
+	project.Status.ReadyLLMModelCount = readyModels
+	project.Status.ReadyServiceCount = readyServices
+	project.Status.HealthyWorkloads = readyVMs + readyModels + readyServices
+
+	cpu, memory := WorkloadRequests(vms.Items, models.Items, services.Items)
+	project.Status.CPUUsage = cpu.String()
+	project.Status.MemoryUsage = memory.String()
+	return nil
+}
+
+// WorkloadRequests sums the CPU and memory requested by VMs, LLM models and services.
+// Model and service requests are multiplied by their replica count
+func WorkloadRequests(vms []llmcloudv1alpha1.VirtualMachine, models []llmcloudv1alpha1.LLMModel,
+	services []llmcloudv1alpha1.Service) (cpu, memory resource.Quantity) {
+	for _, vm := range vms {
+		cpu.Add(*resource.NewQuantity(int64(vm.Spec.CPUs), resource.DecimalSI))
+		addQuantity(&memory, vm.Spec.Memory, 1)
+	}
+	for _, model := range models {
+		addQuantity(&cpu, model.Spec.Resources.CPURequest(), model.Spec.Replicas)
+		addQuantity(&memory, model.Spec.Resources.MemoryRequest(), model.Spec.Replicas)
+	}
+	for _, svc := range services {
+		addQuantity(&cpu, svc.Spec.Resources.CPURequest(), svc.Spec.Replicas)
+		addQuantity(&memory, svc.Spec.Resources.MemoryRequest(), svc.Spec.Replicas)
+	}
+	return cpu, memory
+}
+
+// addQuantity adds value times replicas (at least one) to total, ignoring unparsable values
+func addQuantity(total *resource.Quantity, value string, replicas int32) {
+	if value == "" {
+		return
+	}
+	q, err := resource.ParseQuantity(value)
+	if err != nil {
+		return
+	}
+	for i := int32(0); i < max(replicas, 1); i++ {
+		total.Add(q)
+	}
+}
+
+// isLLMModelReady reports whether a model is running with all desired replicas ready
+func isLLMModelReady(model *llmcloudv1alpha1.LLMModel) bool {
+	return model.Status.Phase == llmcloudv1alpha1.LLMModelPhaseRunning &&
+		model.Status.ReadyReplicas >= max(model.Spec.Replicas, 1)
+}
+
+// isServiceReady reports whether a service is running with all desired replicas ready
+func isServiceReady(service *llmcloudv1alpha1.Service) bool {
+	return service.Status.Phase == llmcloudv1alpha1.ServicePhaseRunning &&
+		service.Status.ReadyReplicas >= max(service.Spec.Replicas, 1)
+}
+
+// projectForWorkload maps a workload in a project namespace to its owning Project
+func (r *ProjectReconciler) projectForWorkload(ctx context.Context, obj client.Object) []reconcile.Request {
+	name, ok := strings.CutPrefix(obj.GetNamespace(), "project-")
+	if !ok || name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: name}}}
+}
+
+// projectsForGroup maps a Group to the Projects it is a member of, so that their
+// RoleBindings follow its members
+func (r *ProjectReconciler) projectsForGroup(ctx context.Context, obj client.Object) []reconcile.Request {
+	var projects llmcloudv1alpha1.ProjectList
+	if err := r.List(ctx, &projects); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, project := range projects.Items {
+		for _, member := range project.Spec.Members {
+			if member.Group() == obj.GetName() {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: project.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
 func (r *ProjectReconciler) getRoleForMember(role string) string {
 	roleMap := map[string]string{"owner": "admin", "admin": "admin", "developer": "edit"}
 	if r, ok := roleMap[role]; ok {
@@ -175,8 +776,83 @@ func (r *ProjectReconciler) getRoleForMember(role string) string {
 	return "view"
 }
 
-func (r *ProjectReconciler) finalizeProject(ctx context.Context, project *llmcloudv1alpha1.Project) error {
-	return nil // Namespace auto-deleted by garbage collection via owner reference
+// finalizeProject deletes the VMs, LLM models and services in the project namespace,
+// waits for their finalizers to release what they hold outside it, and then deletes the
+// namespace. Leaving them to namespace garbage collection can wedge the deletion when a
+// finalizer needs the namespace. It reports whether everything is gone, and the progress
+// in the Terminating condition
+func (r *ProjectReconciler) finalizeProject(ctx context.Context, project *llmcloudv1alpha1.Project) (bool, error) {
+	namespace := cmp.Or(project.Status.Namespace, "project-"+project.Name)
+
+	var remaining []string
+	lists := []struct {
+		kind string
+		list client.ObjectList
+	}{
+		{"VirtualMachine", &llmcloudv1alpha1.VirtualMachineList{}},
+		{"LLMModel", &llmcloudv1alpha1.LLMModelList{}},
+		{"Service", &llmcloudv1alpha1.ServiceList{}},
+	}
+	for _, l := range lists {
+		if err := r.List(ctx, l.list, client.InNamespace(namespace)); err != nil {
+			return false, err
+		}
+		items, err := meta.ExtractList(l.list)
+		if err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			obj := item.(client.Object)
+			if obj.GetDeletionTimestamp().IsZero() {
+				if err := r.Delete(ctx, obj); client.IgnoreNotFound(err) != nil {
+					return false, err
+				}
+			}
+		}
+		if len(items) > 0 {
+			remaining = append(remaining, fmt.Sprintf("%d %s(s)", len(items), l.kind))
+		}
+	}
+	if len(remaining) > 0 {
+		return false, r.setTerminating(ctx, project, "DeletingResources",
+			"Waiting for "+strings.Join(remaining, ", ")+" to be deleted")
+	}
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return errors.IsNotFound(err), client.IgnoreNotFound(err)
+	}
+	if ns.DeletionTimestamp.IsZero() {
+		if err := r.Delete(ctx, ns); client.IgnoreNotFound(err) != nil {
+			return false, err
+		}
+	}
+	return false, r.setTerminating(ctx, project, "DeletingNamespace", "Waiting for namespace "+namespace+" to be deleted")
+}
+
+// setTerminating records the progress of deleting project, writing the status only when
+// it changes
+func (r *ProjectReconciler) setTerminating(ctx context.Context, project *llmcloudv1alpha1.Project, reason, message string) error {
+	project.Status.Phase = "Terminating"
+	if !meta.SetStatusCondition(&project.Status.Conditions, metav1.Condition{
+		Type:               projectTerminatingCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: project.Generation,
+	}) {
+		return nil
+	}
+	return client.IgnoreNotFound(r.Status().Update(ctx, project))
+}
+
+// removeFinalizer drops finalizer from obj with a merge patch rather than an update, so
+// it does not conflict with an uninstall stripping finalizers concurrently, and treats
+// an object that is already gone as finalized
+func removeFinalizer(ctx context.Context, c client.Client, obj client.Object, finalizer string) error {
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, finalizer)
+	return client.IgnoreNotFound(c.Patch(ctx, obj, patch))
 }
 
 func (r *ProjectReconciler) updateStatus(ctx context.Context, project *llmcloudv1alpha1.Project, phase, message string) {
@@ -192,5 +868,15 @@ func (r *ProjectReconciler) updateStatus(ctx context.Context, project *llmcloudv
 }
 
 func (r *ProjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).For(&llmcloudv1alpha1.Project{}).Named("project").Complete(r)
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&llmcloudv1alpha1.Project{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&corev1.ResourceQuota{}).
+		Owns(&corev1.LimitRange{}).
+		Watches(&llmcloudv1alpha1.VirtualMachine{}, handler.EnqueueRequestsFromMapFunc(r.projectForWorkload)).
+		Watches(&llmcloudv1alpha1.LLMModel{}, handler.EnqueueRequestsFromMapFunc(r.projectForWorkload)).
+		Watches(&llmcloudv1alpha1.Service{}, handler.EnqueueRequestsFromMapFunc(r.projectForWorkload)).
+		Watches(&llmcloudv1alpha1.Group{}, handler.EnqueueRequestsFromMapFunc(r.projectsForGroup)).
+		Named("project").
+		Complete(metrics.Reconciler("Project", r))
 }
@@ -18,11 +18,21 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -162,6 +172,255 @@ var _ = Describe("Project Controller", func() {
 		})
 	})
 
+	Context("When summarizing workload health", func() {
+		const projectName = "health-project"
+
+		ctx := context.Background()
+		projectKey := types.NamespacedName{Name: projectName}
+		serviceKey := types.NamespacedName{Name: "web", Namespace: "project-" + projectName}
+
+		BeforeEach(func() {
+			By("creating the project")
+			err := k8sClient.Create(ctx, &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: projectName},
+			})
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			service := &llmcloudv1alpha1.Service{}
+			if err := k8sClient.Get(ctx, serviceKey, service); err == nil {
+				Expect(k8sClient.Delete(ctx, service)).To(Succeed())
+			}
+			project := &llmcloudv1alpha1.Project{}
+			if err := k8sClient.Get(ctx, projectKey, project); err == nil {
+				project.Finalizers = []string{}
+				_ = k8sClient.Update(ctx, project)
+				Expect(k8sClient.Delete(ctx, project)).To(Succeed())
+			}
+		})
+
+		It("should not count a not-ready service as healthy", func() {
+			controllerReconciler := &ProjectReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("reconciling to create the project namespace")
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating a service that is not ready")
+			service := &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: serviceKey.Name, Namespace: serviceKey.Namespace},
+				Spec:       llmcloudv1alpha1.ServiceSpec{Type: "web", Image: "nginx:latest", Replicas: 1},
+			}
+			Expect(k8sClient.Create(ctx, service)).To(Succeed())
+			service.Status.Phase = llmcloudv1alpha1.ServicePhaseRunning
+			service.Status.ReadyReplicas = 0
+			Expect(k8sClient.Status().Update(ctx, service)).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			project := &llmcloudv1alpha1.Project{}
+			Expect(k8sClient.Get(ctx, projectKey, project)).To(Succeed())
+			Expect(project.Status.ServiceCount).To(Equal(int32(1)))
+			Expect(project.Status.ReadyServiceCount).To(Equal(int32(0)))
+			Expect(project.Status.HealthyWorkloads).To(Equal(int32(0)))
+
+			By("marking the service ready")
+			service.Status.ReadyReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, service)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, projectKey, project)).To(Succeed())
+			Expect(project.Status.ReadyServiceCount).To(Equal(int32(1)))
+			Expect(project.Status.HealthyWorkloads).To(Equal(int32(1)))
+		})
+
+		It("should sum the resources the workloads request", func() {
+			controllerReconciler := &ProjectReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("reconciling to create the project namespace")
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			By("creating a service with two replicas")
+			Expect(k8sClient.Create(ctx, &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: serviceKey.Name, Namespace: serviceKey.Namespace},
+				Spec: llmcloudv1alpha1.ServiceSpec{
+					Type: "web", Image: "nginx:latest", Replicas: 2,
+					Resources: llmcloudv1alpha1.ResourceRequirements{CPU: "500m", Memory: "256Mi"},
+				},
+			})).To(Succeed())
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			project := &llmcloudv1alpha1.Project{}
+			Expect(k8sClient.Get(ctx, projectKey, project)).To(Succeed())
+			Expect(project.Status.ServiceCount).To(Equal(int32(1)))
+			Expect(project.Status.CPUUsage).To(Equal("1"))
+			Expect(project.Status.MemoryUsage).To(Equal("512Mi"))
+		})
+	})
+
+	Context("When propagating project labels and annotations", func() {
+		const projectName = "labels-project"
+
+		ctx := context.Background()
+		projectKey := types.NamespacedName{Name: projectName}
+		serviceKey := types.NamespacedName{Name: "monitored", Namespace: "project-" + projectName}
+
+		BeforeEach(func() {
+			By("creating a project with labels and annotations")
+			err := k8sClient.Create(ctx, &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: projectName},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					Labels:      map[string]string{"team": "ml", "env": "prod"},
+					Annotations: map[string]string{"monitoring.example.com/scrape": "true"},
+				},
+			})
+			if err != nil && !errors.IsAlreadyExists(err) {
+				Expect(err).NotTo(HaveOccurred())
+			}
+		})
+
+		AfterEach(func() {
+			service := &llmcloudv1alpha1.Service{}
+			if err := k8sClient.Get(ctx, serviceKey, service); err == nil {
+				Expect(k8sClient.Delete(ctx, service)).To(Succeed())
+			}
+			project := &llmcloudv1alpha1.Project{}
+			if err := k8sClient.Get(ctx, projectKey, project); err == nil {
+				project.Finalizers = []string{}
+				_ = k8sClient.Update(ctx, project)
+				Expect(k8sClient.Delete(ctx, project)).To(Succeed())
+			}
+		})
+
+		It("should apply them to the namespace and its workloads", func() {
+			controllerReconciler := &ProjectReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("reconciling to create the project namespace")
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: serviceKey.Namespace}, ns)).To(Succeed())
+			Expect(ns.Labels).To(HaveKeyWithValue("team", "ml"))
+			Expect(ns.Labels).To(HaveKeyWithValue("llmcloud.io/project", projectName))
+			Expect(ns.Annotations).To(HaveKeyWithValue("monitoring.example.com/scrape", "true"))
+
+			By("creating a service that sets one of the labels itself")
+			Expect(k8sClient.Create(ctx, &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceKey.Name,
+					Namespace: serviceKey.Namespace,
+					Labels:    map[string]string{"env": "staging"},
+				},
+				Spec: llmcloudv1alpha1.ServiceSpec{Type: "web", Image: "nginx:latest", Replicas: 1},
+			})).To(Succeed())
+
+			serviceReconciler := &ServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			_, err := serviceReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			service := &llmcloudv1alpha1.Service{}
+			Expect(k8sClient.Get(ctx, serviceKey, service)).To(Succeed())
+			Expect(service.Labels).To(HaveKeyWithValue("team", "ml"))
+			Expect(service.Labels).To(HaveKeyWithValue("env", "staging"))
+			Expect(service.Annotations).To(HaveKeyWithValue("monitoring.example.com/scrape", "true"))
+		})
+
+		It("should remove the keys the project no longer sets", func() {
+			controllerReconciler := &ProjectReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+			serviceReconciler := &ServiceReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+
+			By("propagating the project metadata to the namespace and a service")
+			for i := 0; i < 2; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(k8sClient.Create(ctx, &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceKey.Name,
+					Namespace: serviceKey.Namespace,
+					Labels:    map[string]string{"env": "staging"},
+				},
+				Spec: llmcloudv1alpha1.ServiceSpec{Type: "web", Image: "nginx:latest", Replicas: 1},
+			})).To(Succeed())
+			_, err := serviceReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("removing a label, changing another and removing the annotation")
+			project := &llmcloudv1alpha1.Project{}
+			Expect(k8sClient.Get(ctx, projectKey, project)).To(Succeed())
+			project.Spec.Labels = map[string]string{"env": "dev"}
+			project.Spec.Annotations = nil
+			Expect(k8sClient.Update(ctx, project)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = serviceReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: serviceKey})
+			Expect(err).NotTo(HaveOccurred())
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: serviceKey.Namespace}, ns)).To(Succeed())
+			Expect(ns.Labels).NotTo(HaveKey("team"))
+			Expect(ns.Labels).To(HaveKeyWithValue("env", "dev"))
+			Expect(ns.Annotations).NotTo(HaveKey("monitoring.example.com/scrape"))
+
+			service := &llmcloudv1alpha1.Service{}
+			Expect(k8sClient.Get(ctx, serviceKey, service)).To(Succeed())
+			Expect(service.Labels).NotTo(HaveKey("team"))
+			Expect(service.Labels).To(HaveKeyWithValue("env", "staging"))
+			Expect(service.Annotations).NotTo(HaveKey("monitoring.example.com/scrape"))
+		})
+
+		It("should keep the operator's labels on the namespace", func() {
+			controllerReconciler := &ProjectReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			By("setting a reserved label the webhook would reject")
+			project := &llmcloudv1alpha1.Project{}
+			Expect(k8sClient.Get(ctx, projectKey, project)).To(Succeed())
+			project.Spec.Labels["llmcloud.io/managed"] = "false"
+			Expect(k8sClient.Update(ctx, project)).To(Succeed())
+			for i := 0; i < 3; i++ {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			ns := &corev1.Namespace{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: serviceKey.Namespace}, ns)).To(Succeed())
+			Expect(ns.Labels).To(HaveKeyWithValue("llmcloud.io/managed", "true"))
+			Expect(ns.Labels).To(HaveKeyWithValue("llmcloud.io/project", projectName))
+			Expect(ns.Labels).To(HaveKeyWithValue("team", "ml"))
+		})
+	})
+
 	Context("Helper functions", func() {
 		It("should map roles correctly", func() {
 			r := &ProjectReconciler{}
@@ -172,5 +431,439 @@ var _ = Describe("Project Controller", func() {
 			Expect(r.getRoleForMember("viewer")).To(Equal("view"))
 			Expect(r.getRoleForMember("unknown")).To(Equal("view"))
 		})
+
+		It("should report service readiness from phase and replicas", func() {
+			service := &llmcloudv1alpha1.Service{Spec: llmcloudv1alpha1.ServiceSpec{Replicas: 2}}
+			Expect(isServiceReady(service)).To(BeFalse())
+
+			service.Status.Phase = llmcloudv1alpha1.ServicePhaseRunning
+			service.Status.ReadyReplicas = 1
+			Expect(isServiceReady(service)).To(BeFalse())
+
+			service.Status.ReadyReplicas = 2
+			Expect(isServiceReady(service)).To(BeTrue())
+		})
+
+		It("should map project namespaces back to projects", func() {
+			r := &ProjectReconciler{}
+			svc := &llmcloudv1alpha1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-demo"}}
+			Expect(r.projectForWorkload(context.Background(), svc)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Name: "demo"}}))
+
+			svc.Namespace = "default"
+			Expect(r.projectForWorkload(context.Background(), svc)).To(BeEmpty())
+		})
+
+		It("should build an egress policy allowing only DNS and the configured destinations", func() {
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "locked"},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					Egress: &llmcloudv1alpha1.ProjectEgressPolicy{
+						AllowedCIDRs:      []string{"10.0.0.0/8", "192.168.1.0/24"},
+						AllowedNamespaces: []string{"monitoring"},
+					},
+				},
+			}
+
+			policy, err := buildEgressPolicy(project, "project-locked")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(policy.Namespace).To(Equal("project-locked"))
+			Expect(policy.Spec.PolicyTypes).To(ConsistOf(networkingv1.PolicyTypeEgress))
+			Expect(policy.Spec.PodSelector.MatchLabels).To(BeEmpty())
+			Expect(policy.Spec.Egress).To(HaveLen(4))
+
+			dns := policy.Spec.Egress[0]
+			Expect(dns.Ports).To(HaveLen(2))
+			Expect(dns.Ports[0].Port.IntValue()).To(Equal(53))
+
+			cidrs := policy.Spec.Egress[2].To
+			Expect(cidrs).To(HaveLen(2))
+			Expect(cidrs[0].IPBlock.CIDR).To(Equal("10.0.0.0/8"))
+			Expect(cidrs[1].IPBlock.CIDR).To(Equal("192.168.1.0/24"))
+
+			namespaces := policy.Spec.Egress[3].To[0].NamespaceSelector.MatchExpressions
+			Expect(namespaces).To(HaveLen(1))
+			Expect(namespaces[0].Values).To(ConsistOf("monitoring"))
+
+			project.Spec.Egress.AllowedCIDRs = []string{"not-a-cidr"}
+			_, err = buildEgressPolicy(project, "project-locked")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should create and remove the egress policy with the project setting", func() {
+			ctx := context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(networkingv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "locked", UID: "locked-uid"},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					Egress: &llmcloudv1alpha1.ProjectEgressPolicy{AllowedCIDRs: []string{"10.0.0.0/8"}},
+				},
+			}
+			r := &ProjectReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build(), Scheme: testScheme}
+			key := types.NamespacedName{Name: projectEgressPolicyName, Namespace: "project-locked"}
+
+			Expect(r.reconcileEgressPolicy(ctx, project, key.Namespace)).To(Succeed())
+			policy := &networkingv1.NetworkPolicy{}
+			Expect(r.Get(ctx, key, policy)).To(Succeed())
+			Expect(policy.Spec.Egress).To(HaveLen(3))
+
+			project.Spec.Egress = nil
+			Expect(r.reconcileEgressPolicy(ctx, project, key.Namespace)).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, policy))).To(BeTrue())
+		})
+
+		It("should isolate the project namespace according to its isolation mode", func() {
+			ctx := context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(networkingv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			project := &llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "tenant", UID: "tenant-uid"}}
+			r := &ProjectReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build(), Scheme: testScheme}
+			key := types.NamespacedName{Name: projectIsolationPolicyName, Namespace: "project-tenant"}
+
+			By("admitting only the project and namespaces that are not projects by default")
+			Expect(r.reconcileIsolationPolicy(ctx, project, key.Namespace)).To(Succeed())
+			policy := &networkingv1.NetworkPolicy{}
+			Expect(r.Get(ctx, key, policy)).To(Succeed())
+			Expect(policy.Spec.PolicyTypes).To(ConsistOf(networkingv1.PolicyTypeIngress))
+			Expect(policy.Spec.Ingress).To(HaveLen(2))
+			Expect(policy.Spec.Ingress[0].From[0].PodSelector).NotTo(BeNil())
+			notProjects := policy.Spec.Ingress[1].From[0].NamespaceSelector.MatchExpressions
+			Expect(notProjects).To(ConsistOf(metav1.LabelSelectorRequirement{
+				Key: "llmcloud.io/project", Operator: metav1.LabelSelectorOpDoesNotExist,
+			}))
+
+			By("admitting the listed sources in custom mode")
+			project.Spec.IsolationMode = llmcloudv1alpha1.IsolationModeCustom
+			project.Spec.Ingress = &llmcloudv1alpha1.ProjectIngressPolicy{
+				AllowedCIDRs:      []string{"192.168.0.0/16"},
+				AllowedNamespaces: []string{"project-shared"},
+			}
+			Expect(r.reconcileIsolationPolicy(ctx, project, key.Namespace)).To(Succeed())
+			Expect(r.Get(ctx, key, policy)).To(Succeed())
+			Expect(policy.Spec.Ingress).To(HaveLen(4))
+			Expect(policy.Spec.Ingress[2].From[0].IPBlock.CIDR).To(Equal("192.168.0.0/16"))
+			Expect(policy.Spec.Ingress[3].From[0].NamespaceSelector.MatchExpressions[0].Values).To(ConsistOf("project-shared"))
+
+			By("ignoring the listed sources in strict mode")
+			project.Spec.IsolationMode = llmcloudv1alpha1.IsolationModeStrict
+			Expect(r.reconcileIsolationPolicy(ctx, project, key.Namespace)).To(Succeed())
+			Expect(r.Get(ctx, key, policy)).To(Succeed())
+			Expect(policy.Spec.Ingress).To(HaveLen(2))
+
+			By("removing the policy when isolation is turned off")
+			project.Spec.IsolationMode = llmcloudv1alpha1.IsolationModeNone
+			Expect(r.reconcileIsolationPolicy(ctx, project, key.Namespace)).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, policy))).To(BeTrue())
+		})
+
+		It("should create and remove the resource quota with the project quotas", func() {
+			ctx := context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			maxVMs := int32(2)
+			cpu := "4"
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "limited", UID: "limited-uid"},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					ResourceQuotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxVMs: &maxVMs, MaxCPU: &cpu},
+				},
+			}
+			r := &ProjectReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build(), Scheme: testScheme}
+			key := types.NamespacedName{Name: projectResourceQuotaName, Namespace: "project-limited"}
+
+			Expect(r.reconcileResourceQuota(ctx, project, key.Namespace)).Error().NotTo(HaveOccurred())
+			quota := &corev1.ResourceQuota{}
+			Expect(r.Get(ctx, key, quota)).To(Succeed())
+			Expect(quota.Spec.Hard).To(HaveLen(2))
+			requestsCPU := quota.Spec.Hard[corev1.ResourceRequestsCPU]
+			Expect(requestsCPU.Cmp(resource.MustParse("4"))).To(Equal(0))
+			vms := quota.Spec.Hard[corev1.ResourceName("count/virtualmachines.llmcloud.llmcloud.io")]
+			Expect(vms.Value()).To(Equal(int64(2)))
+
+			project.Spec.ResourceQuotas = nil
+			Expect(r.reconcileResourceQuota(ctx, project, key.Namespace)).Error().NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(r.Get(ctx, key, quota))).To(BeTrue())
+		})
+
+		It("should give containers default requests for the resources the quotas bound", func() {
+			ctx := context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			cpu := "4"
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "limited", UID: "limited-uid"},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					ResourceQuotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxCPU: &cpu},
+				},
+			}
+			r := &ProjectReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build(), Scheme: testScheme}
+			key := types.NamespacedName{Name: projectLimitRangeName, Namespace: "project-limited"}
+
+			Expect(r.reconcileLimitRange(ctx, project, key.Namespace)).To(Succeed())
+			limitRange := &corev1.LimitRange{}
+			Expect(r.Get(ctx, key, limitRange)).To(Succeed())
+			Expect(limitRange.Spec.Limits).To(HaveLen(1))
+			Expect(limitRange.Spec.Limits[0].Type).To(Equal(corev1.LimitTypeContainer))
+			Expect(limitRange.Spec.Limits[0].DefaultRequest).To(HaveKey(corev1.ResourceCPU))
+			Expect(limitRange.Spec.Limits[0].DefaultRequest).NotTo(HaveKey(corev1.ResourceMemory))
+
+			By("bounding memory as well")
+			memory := "8Gi"
+			project.Spec.ResourceQuotas.MaxMemory = &memory
+			Expect(r.reconcileLimitRange(ctx, project, key.Namespace)).To(Succeed())
+			Expect(r.Get(ctx, key, limitRange)).To(Succeed())
+			Expect(limitRange.Spec.Limits[0].DefaultRequest).To(HaveKey(corev1.ResourceMemory))
+
+			By("dropping the CPU and memory quotas")
+			maxVMs := int32(2)
+			project.Spec.ResourceQuotas = &llmcloudv1alpha1.ProjectResourceQuotas{MaxVMs: &maxVMs}
+			Expect(r.reconcileLimitRange(ctx, project, key.Namespace)).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, limitRange))).To(BeTrue())
+		})
+
+		It("should report the quotas a project has reached", func() {
+			ctx := context.Background()
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			maxVMs := int32(2)
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "limited", UID: "limited-uid"},
+				Spec: llmcloudv1alpha1.ProjectSpec{
+					ResourceQuotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxVMs: &maxVMs},
+				},
+			}
+			vms := corev1.ResourceName("count/virtualmachines.llmcloud.llmcloud.io")
+			quota := &corev1.ResourceQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: projectResourceQuotaName, Namespace: "project-limited"},
+				Status: corev1.ResourceQuotaStatus{
+					Hard: corev1.ResourceList{vms: resource.MustParse("2"), corev1.ResourceRequestsCPU: resource.MustParse("4")},
+					Used: corev1.ResourceList{vms: resource.MustParse("2"), corev1.ResourceRequestsCPU: resource.MustParse("1")},
+				},
+			}
+			recorder := record.NewFakeRecorder(10)
+			r := &ProjectReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(testScheme).WithObjects(quota).Build(),
+				Scheme:   testScheme,
+				Recorder: recorder,
+			}
+
+			exhausted, err := r.reconcileResourceQuota(ctx, project, quota.Namespace)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exhausted).To(Equal([]string{"count/virtualmachines.llmcloud.llmcloud.io (2/2)"}))
+
+			r.updateQuotaCondition(project, exhausted)
+			cond := meta.FindStatusCondition(project.Status.Conditions, projectQuotaExceededCondition)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(recorder.Events).To(Receive(ContainSubstring("QuotaExceeded")))
+
+			By("reporting the same quotas again")
+			r.updateQuotaCondition(project, exhausted)
+			Expect(recorder.Events).NotTo(Receive())
+
+			By("freeing up room")
+			r.updateQuotaCondition(project, nil)
+			Expect(meta.FindStatusCondition(project.Status.Conditions, projectQuotaExceededCondition)).To(BeNil())
+		})
+	})
+
+	Context("When granting project membership to groups", func() {
+		ctx := context.Background()
+
+		newGroupReconciler := func(objs ...client.Object) *ProjectReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(rbacv1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &ProjectReconciler{Client: c, Scheme: testScheme}
+		}
+		mlTeam := &llmcloudv1alpha1.Group{
+			ObjectMeta: metav1.ObjectMeta{Name: "ml-team"},
+			Spec:       llmcloudv1alpha1.GroupSpec{Members: []string{"alice", "bob"}},
+		}
+		project := &llmcloudv1alpha1.Project{
+			ObjectMeta: metav1.ObjectMeta{Name: "ml"},
+			Spec: llmcloudv1alpha1.ProjectSpec{Members: []llmcloudv1alpha1.ProjectMember{
+				{Username: "carol", Role: "owner"},
+				{Username: "group:ml-team", Role: "developer"},
+			}},
+		}
+
+		It("should bind every user in the group to the member's role", func() {
+			r := newGroupReconciler(mlTeam.DeepCopy())
+			Expect(r.reconcileRBAC(ctx, project, "project-ml")).To(Succeed())
+
+			rb := &rbacv1.RoleBinding{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "ml-group-ml-team", Namespace: "project-ml"}, rb)).To(Succeed())
+			Expect(rb.RoleRef.Name).To(Equal("edit"))
+			Expect(rb.Subjects).To(ConsistOf(
+				rbacv1.Subject{Kind: "User", Name: "alice"},
+				rbacv1.Subject{Kind: "User", Name: "bob"},
+			))
+			Expect(r.Get(ctx, types.NamespacedName{Name: "ml-carol", Namespace: "project-ml"}, &rbacv1.RoleBinding{})).To(Succeed())
+		})
+
+		It("should bind nobody while the group does not exist", func() {
+			r := newGroupReconciler()
+			Expect(r.reconcileRBAC(ctx, project, "project-ml")).To(Succeed())
+
+			rb := &rbacv1.RoleBinding{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "ml-group-ml-team", Namespace: "project-ml"}, rb)).To(Succeed())
+			Expect(rb.Subjects).To(BeEmpty())
+		})
+
+		It("should reconcile the projects a group is a member of when it changes", func() {
+			other := &llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+			r := newGroupReconciler(project.DeepCopy(), other)
+
+			Expect(r.projectsForGroup(ctx, mlTeam)).To(ConsistOf(
+				reconcile.Request{NamespacedName: types.NamespacedName{Name: "ml"}},
+			))
+		})
+	})
+
+	Context("When deleting a project", func() {
+		ctx := context.Background()
+		projectKey := types.NamespacedName{Name: "doomed"}
+		vmKey := types.NamespacedName{Name: "db", Namespace: "project-doomed"}
+
+		It("should delete its resources, then its namespace, before releasing the project", func() {
+			now := metav1.Now()
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithStatusSubresource(&llmcloudv1alpha1.Project{}).
+				WithObjects(
+					&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{
+						Name: "doomed", DeletionTimestamp: &now, Finalizers: []string{projectFinalizer},
+					}},
+					&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "project-doomed"}},
+					&llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{
+						Name: "db", Namespace: "project-doomed", Finalizers: []string{vmFinalizer},
+					}},
+					&llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "chat", Namespace: "project-doomed"}},
+				).Build()
+			r := &ProjectReconciler{Client: c, Scheme: testScheme}
+			reconcileProject := func() reconcile.Result {
+				result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: projectKey})
+				Expect(err).NotTo(HaveOccurred())
+				return result
+			}
+			terminating := func() *metav1.Condition {
+				project := &llmcloudv1alpha1.Project{}
+				Expect(c.Get(ctx, projectKey, project)).To(Succeed())
+				Expect(project.Status.Phase).To(Equal("Terminating"))
+				return meta.FindStatusCondition(project.Status.Conditions, projectTerminatingCondition)
+			}
+
+			By("deleting the resources and waiting for the VM's finalizer")
+			Expect(reconcileProject().RequeueAfter).To(Equal(projectFinalizeInterval))
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(c.Get(ctx, vmKey, vm)).To(Succeed())
+			Expect(vm.DeletionTimestamp.IsZero()).To(BeFalse())
+			Expect(errors.IsNotFound(c.Get(ctx, types.NamespacedName{Name: "chat", Namespace: "project-doomed"},
+				&llmcloudv1alpha1.LLMModel{}))).To(BeTrue())
+			Expect(c.Get(ctx, types.NamespacedName{Name: "project-doomed"}, &corev1.Namespace{})).To(Succeed())
+			condition := terminating()
+			Expect(condition.Reason).To(Equal("DeletingResources"))
+			Expect(condition.Message).To(ContainSubstring("1 VirtualMachine"))
+
+			By("deleting the namespace once the VM is gone")
+			vm.Finalizers = nil
+			Expect(c.Update(ctx, vm)).To(Succeed())
+			Expect(reconcileProject().RequeueAfter).To(Equal(projectFinalizeInterval))
+			Expect(errors.IsNotFound(c.Get(ctx, types.NamespacedName{Name: "project-doomed"}, &corev1.Namespace{}))).To(BeTrue())
+			Expect(terminating().Reason).To(Equal("DeletingNamespace"))
+
+			By("releasing the project")
+			Expect(reconcileProject().RequeueAfter).To(BeZero())
+			Expect(errors.IsNotFound(c.Get(ctx, projectKey, &llmcloudv1alpha1.Project{}))).To(BeTrue())
+		})
+	})
+
+	Context("When collecting orphaned project namespaces", func() {
+		ctx := context.Background()
+
+		managedNamespace := func(project string) *corev1.Namespace {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "project-" + project,
+				Labels: map[string]string{"llmcloud.io/project": project, "llmcloud.io/managed": "true"},
+			}}
+		}
+		managedRoleBinding := func(project, user string) *rbacv1.RoleBinding {
+			return &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+				Name:      project + "-" + user,
+				Namespace: "project-" + project,
+				Labels:    map[string]string{"llmcloud.io/project": project, "llmcloud.io/managed": "true"},
+			}}
+		}
+
+		newGCReconciler := func(objs ...client.Object) *OrphanedProjectReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(rbacv1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &OrphanedProjectReconciler{Client: c, Scheme: testScheme, Interval: time.Minute}
+		}
+
+		reconcileNamespace := func(r *OrphanedProjectReconciler, name string) reconcile.Result {
+			result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: types.NamespacedName{Name: name}})
+			Expect(err).NotTo(HaveOccurred())
+			return result
+		}
+
+		It("should delete the namespace and RoleBindings of a deleted project", func() {
+			r := newGCReconciler(managedNamespace("gone"), managedRoleBinding("gone", "alice"))
+
+			Expect(reconcileNamespace(r, "project-gone").RequeueAfter).To(BeZero())
+			Expect(errors.IsNotFound(r.Get(ctx, types.NamespacedName{Name: "project-gone"}, &corev1.Namespace{}))).To(BeTrue())
+			Expect(errors.IsNotFound(r.Get(ctx, types.NamespacedName{Name: "gone-alice", Namespace: "project-gone"},
+				&rbacv1.RoleBinding{}))).To(BeTrue())
+		})
+
+		It("should keep the namespace of an existing project and check it again later", func() {
+			r := newGCReconciler(
+				&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "kept"}},
+				managedNamespace("kept"),
+				managedRoleBinding("kept", "bob"),
+			)
+
+			Expect(reconcileNamespace(r, "project-kept").RequeueAfter).To(Equal(time.Minute))
+			Expect(r.Get(ctx, types.NamespacedName{Name: "project-kept"}, &corev1.Namespace{})).To(Succeed())
+			Expect(r.Get(ctx, types.NamespacedName{Name: "kept-bob", Namespace: "project-kept"}, &rbacv1.RoleBinding{})).To(Succeed())
+		})
+
+		It("should keep the namespace of a project that is not cached yet", func() {
+			r := newGCReconciler(managedNamespace("new"))
+			r.APIReader = fake.NewClientBuilder().WithScheme(r.Scheme).
+				WithObjects(&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "new"}}).Build()
+
+			Expect(reconcileNamespace(r, "project-new").RequeueAfter).To(Equal(time.Minute))
+			Expect(r.Get(ctx, types.NamespacedName{Name: "project-new"}, &corev1.Namespace{})).To(Succeed())
+		})
+
+		It("should leave namespaces it does not manage alone", func() {
+			unlabelled := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "project-manual"}}
+			mismatched := managedNamespace("other")
+			mismatched.Name = "project-renamed"
+			r := newGCReconciler(unlabelled, mismatched)
+
+			reconcileNamespace(r, "project-manual")
+			reconcileNamespace(r, "project-renamed")
+			Expect(r.Get(ctx, types.NamespacedName{Name: "project-manual"}, &corev1.Namespace{})).To(Succeed())
+			Expect(r.Get(ctx, types.NamespacedName{Name: "project-renamed"}, &corev1.Namespace{})).To(Succeed())
+		})
 	})
 })
@@ -0,0 +1,160 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/audit"
+)
+
+// defaultOrphanSweepInterval is how often project namespaces are rechecked when
+// OrphanedProjectReconciler.Interval is unset
+const defaultOrphanSweepInterval = 10 * time.Minute
+
+// OrphanedProjectReconciler deletes project namespaces, and the RoleBindings managed in
+// them, whose Project no longer exists. Garbage collection through the namespace's
+// owner reference normally does this, but namespaces created before the reference was
+// set, or left behind by an interrupted uninstall, would otherwise linger
+type OrphanedProjectReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Interval is how often each project namespace is rechecked
+	Interval time.Duration
+
+	// Audit records the namespaces deleted, when set
+	Audit *audit.Recorder
+
+	// APIReader confirms past the cache that a Project is gone before its namespace is
+	// deleted, since a Project created together with its namespace may not be cached yet
+	// when the namespace is. The cached client is trusted when unset
+	APIReader client.Reader
+}
+
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;delete;deletecollection
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=projects,verbs=get;list;watch
+
+// Reconcile deletes a managed project namespace once its Project is gone, and otherwise
+// checks it again after Interval
+func (r *OrphanedProjectReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, req.NamespacedName, ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	projectName, ok := managedProjectName(ns)
+	if !ok || !ns.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultOrphanSweepInterval
+	}
+
+	// A Project that is being deleted still exists; its own finalizer handles the namespace
+	err := r.Get(ctx, client.ObjectKey{Name: projectName}, &llmcloudv1alpha1.Project{})
+	if err == nil {
+		return ctrl.Result{RequeueAfter: interval}, nil
+	}
+	if !errors.IsNotFound(err) {
+		return ctrl.Result{}, err
+	}
+	if r.APIReader != nil {
+		err := r.APIReader.Get(ctx, client.ObjectKey{Name: projectName}, &llmcloudv1alpha1.Project{})
+		if err == nil {
+			return ctrl.Result{RequeueAfter: interval}, nil
+		}
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+	}
+
+	logger.Info("Deleting orphaned project namespace", "namespace", ns.Name, "project", projectName)
+	if err := r.DeleteAllOf(ctx, &rbacv1.RoleBinding{}, client.InNamespace(ns.Name),
+		client.MatchingLabels{"llmcloud.io/project": projectName, "llmcloud.io/managed": "true"}); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.Delete(ctx, ns); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if r.Audit != nil {
+		r.Audit.Record(ctx, llmcloudv1alpha1.AuditEventSpec{
+			User:     audit.OperatorUser,
+			Action:   "delete",
+			Resource: "namespaces",
+			Name:     ns.Name,
+		})
+	}
+	return ctrl.Result{}, nil
+}
+
+// managedProjectName returns the Project a namespace was created for. Only namespaces
+// the project controller labelled, and named after that project, qualify, so that a
+// namespace merely called project-* is never deleted
+func managedProjectName(ns *corev1.Namespace) (string, bool) {
+	if ns.Labels["llmcloud.io/managed"] != "true" {
+		return "", false
+	}
+	name := ns.Labels["llmcloud.io/project"]
+	if name == "" || ns.Name != "project-"+name {
+		return "", false
+	}
+	return name, true
+}
+
+// namespaceForProject maps a Project to its namespace, so that deleting a Project is
+// noticed without waiting for the next sweep
+func namespaceForProject(_ context.Context, obj client.Object) []reconcile.Request {
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "project-" + obj.GetName()}}}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OrphanedProjectReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Namespace{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			_, ok := managedProjectName(obj.(*corev1.Namespace))
+			return ok
+		}))).
+		Watches(&llmcloudv1alpha1.Project{}, handler.EnqueueRequestsFromMapFunc(namespaceForProject),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			})).
+		Named("orphanedproject").
+		Complete(r)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=projects,verbs=get;list;watch
+
+const (
+	// projectLabelsAnnotation and projectAnnotationsAnnotation list the keys of the
+	// labels and annotations an object got from its Project, comma-separated, so that
+	// they are removed once the Project no longer sets them
+	projectLabelsAnnotation      = "llmcloud.io/project-labels"
+	projectAnnotationsAnnotation = "llmcloud.io/project-annotations"
+)
+
+// inheritProjectMetadata copies the labels and annotations of the Project owning obj's
+// namespace onto obj, keeping any value obj already sets for the same key, and removes
+// those it copied before that the Project no longer sets. obj is updated only when
+// something changed. Workloads outside project namespaces are left alone
+func inheritProjectMetadata(ctx context.Context, c client.Client, obj client.Object) error {
+	name, ok := strings.CutPrefix(obj.GetNamespace(), "project-")
+	if !ok || name == "" {
+		return nil
+	}
+	project := &llmcloudv1alpha1.Project{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, project); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+	if !syncProjectMetadata(&labels, &annotations, project, false) {
+		return nil
+	}
+	obj.SetLabels(labels)
+	obj.SetAnnotations(annotations)
+	return c.Update(ctx, obj)
+}
+
+// syncProjectMetadata applies the labels and annotations of project to labels and
+// annotations, recording the keys it applied in projectLabelsAnnotation and
+// projectAnnotationsAnnotation, and reports whether anything changed. A key applied
+// before that the project no longer sets is removed. With override, the project's
+// values replace those set otherwise; without, such keys are left alone
+func syncProjectMetadata(labels, annotations *map[string]string, project *llmcloudv1alpha1.Project, override bool) bool {
+	labelKeys, labelsChanged := syncInherited(labels, project.Spec.Labels,
+		managedKeys((*annotations)[projectLabelsAnnotation]), override)
+	annotationKeys, annotationsChanged := syncInherited(annotations, project.Spec.Annotations,
+		managedKeys((*annotations)[projectAnnotationsAnnotation]), override)
+	labelsRecorded := setManagedKeys(annotations, projectLabelsAnnotation, labelKeys)
+	annotationsRecorded := setManagedKeys(annotations, projectAnnotationsAnnotation, annotationKeys)
+	return labelsChanged || annotationsChanged || labelsRecorded || annotationsRecorded
+}
+
+// syncInherited sets the entries of from in into, unless into has the key and it is
+// neither managed nor override is set, and deletes the managed keys from no longer
+// sets. It returns the keys it manages now and whether into changed
+func syncInherited(into *map[string]string, from map[string]string, managed []string, override bool) ([]string, bool) {
+	changed := false
+	for _, k := range managed {
+		if _, ok := from[k]; ok {
+			continue
+		}
+		if _, ok := (*into)[k]; ok {
+			delete(*into, k)
+			changed = true
+		}
+	}
+	var keys []string
+	for k, v := range from {
+		current, ok := (*into)[k]
+		if ok && !override && !slices.Contains(managed, k) {
+			continue
+		}
+		keys = append(keys, k)
+		if ok && current == v {
+			continue
+		}
+		if *into == nil {
+			*into = map[string]string{}
+		}
+		(*into)[k] = v
+		changed = true
+	}
+	slices.Sort(keys)
+	return keys, changed
+}
+
+// managedKeys parses the value of projectLabelsAnnotation or projectAnnotationsAnnotation
+func managedKeys(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
+
+// setManagedKeys records keys in the annotation, removing it when there are none, and
+// reports whether annotations changed
+func setManagedKeys(annotations *map[string]string, annotation string, keys []string) bool {
+	value := strings.Join(keys, ",")
+	if current, ok := (*annotations)[annotation]; current == value && (ok || value == "") {
+		return false
+	}
+	if value == "" {
+		delete(*annotations, annotation)
+		return true
+	}
+	if *annotations == nil {
+		*annotations = map[string]string{}
+	}
+	(*annotations)[annotation] = value
+	return true
+}
+
+// workloadsForProject returns a map function that enqueues every workload of the list's
+// type in a Project's namespace, so that metadata added to the project is inherited
+func workloadsForProject(c client.Client, newList func() client.ObjectList) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		list := newList()
+		if err := c.List(ctx, list, client.InNamespace("project-"+obj.GetName())); err != nil {
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(items))
+		for _, item := range items {
+			if o, ok := item.(client.Object); ok {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(o)})
+			}
+		}
+		return requests
+	}
+}
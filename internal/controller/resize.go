@@ -0,0 +1,169 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// vmResizingCondition reports the restart applying changed CPUs or memory to a VM
+const vmResizingCondition = "Resizing"
+
+// Reasons of the Resizing condition
+const (
+	// vmResizeStopping is set while the old instance shuts down
+	vmResizeStopping = "Stopping"
+	// vmResizeStarting is set while the new instance starts
+	vmResizeStarting = "Starting"
+	// vmResizeDone is set once the VM runs with its new resources
+	vmResizeDone = "Resized"
+	// vmResizeRestartRequired is set on a Manual VM that keeps running with its old resources
+	vmResizeRestartRequired = "RestartRequired"
+)
+
+// vmiResources returns the CPU cores and memory a VMI was started with
+func vmiResources(vmi *unstructured.Unstructured) (int64, resource.Quantity) {
+	cores, _, _ := unstructured.NestedInt64(vmi.Object, "spec", "domain", "cpu", "cores")
+	return cores, NestedQuantity(vmi.Object, "spec", "domain", "resources", "requests", "memory")
+}
+
+// vmNeedsResize reports whether vmi runs with other CPUs or memory than vm asks for.
+// A VMI without resources in its spec and a memory size that does not parse are not
+// compared
+func vmNeedsResize(vm *llmcloudv1alpha1.VirtualMachine, vmi *unstructured.Unstructured) bool {
+	cores, memory := vmiResources(vmi)
+	if cores == 0 && memory.IsZero() {
+		return false
+	}
+	if cores != int64(max(vm.Spec.CPUs, 1)) {
+		return true
+	}
+	want, err := resource.ParseQuantity(vm.Spec.Memory)
+	return err == nil && want.Cmp(memory) != 0
+}
+
+// resizeStopping reports whether vm's instance is being stopped to apply new resources,
+// during which the KubeVirt VM is halted
+func resizeStopping(vm *llmcloudv1alpha1.VirtualMachine) bool {
+	cond := meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition)
+	return cond != nil && cond.Status == metav1.ConditionTrue && cond.Reason == vmResizeStopping
+}
+
+// vmPhase returns phase, or Resizing while vm is restarted to apply new resources
+func vmPhase(vm *llmcloudv1alpha1.VirtualMachine, phase string) string {
+	if meta.IsStatusConditionTrue(vm.Status.Conditions, vmResizingCondition) {
+		return llmcloudv1alpha1.PhaseResizing
+	}
+	return phase
+}
+
+// reconcileResize restarts a running VM whose CPUs or memory changed, as KubeVirt only
+// applies them to a new instance. The old instance is stopped gracefully by halting the
+// KubeVirt VM, which is then started again with the VM's RunStrategy. A Manual VM is
+// left running and reported as needing a restart. It returns whether a resize is in
+// progress, and must run before the KubeVirt VM is applied
+func (r *VirtualMachineReconciler) reconcileResize(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	vmi := &unstructured.Unstructured{}
+	vmi.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "kubevirt.io",
+		Version: "v1",
+		Kind:    "VirtualMachineInstance",
+	})
+	err := r.Get(ctx, client.ObjectKeyFromObject(vm), vmi)
+	if err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	found := err == nil
+	vmiPhase, _, _ := unstructured.NestedString(vmi.Object, "status", "phase")
+
+	cond := meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition)
+	resizing := cond != nil && cond.Status == metav1.ConditionTrue
+	resources := fmt.Sprintf("%d CPUs and %s of memory", max(vm.Spec.CPUs, 1), vm.Spec.Memory)
+	condition := metav1.Condition{
+		Type:               vmResizingCondition,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: vm.Generation,
+	}
+
+	switch {
+	case resizing && cond.Reason == vmResizeStopping:
+		if found {
+			return true, nil
+		}
+		condition.Reason = vmResizeStarting
+		condition.Message = "Starting the VM with " + resources
+	case resizing:
+		// A VM halted or made Manual meanwhile gets its new resources on the next start
+		stopped := !found && (vm.Spec.RunStrategy == "Halted" || vm.Spec.RunStrategy == "Manual")
+		if !stopped && (!found || vmiPhase != llmcloudv1alpha1.PhaseRunning || vmNeedsResize(vm, vmi)) {
+			return true, nil
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = vmResizeDone
+		condition.Message = "Restarted with " + resources
+		recordEvent(r.Recorder, vm, corev1.EventTypeNormal, vmResizeDone, "The VM runs with %s", resources)
+	case found && vmNeedsResize(vm, vmi):
+		if vmiPhase != llmcloudv1alpha1.PhaseRunning || vm.Status.RebootPhase != "" || vm.Spec.RunStrategy == "Halted" {
+			return false, nil
+		}
+		if vm.Spec.RunStrategy == "Manual" {
+			if cond != nil && cond.Reason == vmResizeRestartRequired {
+				return false, nil
+			}
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = vmResizeRestartRequired
+			condition.Message = "Stop and start the VM to run it with " + resources
+			recordEvent(r.Recorder, vm, corev1.EventTypeNormal, vmResizeRestartRequired, "%s", condition.Message)
+			break
+		}
+		condition.Reason = vmResizeStopping
+		condition.Message = "Stopping the VM to restart it with " + resources
+		recordEvent(r.Recorder, vm, corev1.EventTypeNormal, "Resizing", "Restarting the VM to apply %s", resources)
+	case cond != nil && cond.Reason == vmResizeRestartRequired:
+		// The Manual VM was restarted or stopped
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = vmResizeDone
+		condition.Message = "Restarted with " + resources
+	default:
+		return false, nil
+	}
+
+	log.Info("Resizing VM", "vm", vm.Name, "reason", condition.Reason)
+	meta.SetStatusCondition(&vm.Status.Conditions, condition)
+	if condition.Status == metav1.ConditionTrue {
+		vm.Status.Phase = llmcloudv1alpha1.PhaseResizing
+	}
+	if err := r.Status().Update(ctx, vm); err != nil {
+		return false, err
+	}
+	return condition.Status == metav1.ConditionTrue, nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// scheduleLookback bounds how far back the firings of a SchedulePolicy missed while the
+// operator was down are looked for
+const scheduleLookback = 7 * 24 * time.Hour
+
+// scheduleTiming returns the RunStrategy the latest firing of policy after last and
+// at or before now asks for, empty when none fired, and how long after now the next
+// firing is. A start and stop firing at the same time stop the VM
+func scheduleTiming(policy *llmcloudv1alpha1.VMSchedulePolicy, last, now time.Time) (string, time.Duration, error) {
+	loc, err := time.LoadLocation(policy.TimeZone)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid time zone %q: %w", policy.TimeZone, err)
+	}
+	// The schedules fire in the location of the times passed to them
+	last, now = last.In(loc), now.In(loc)
+	if earliest := now.Add(-scheduleLookback); last.Before(earliest) {
+		last = earliest
+	}
+
+	var runStrategy string
+	var latest time.Time
+	var wait time.Duration
+	for _, schedule := range []struct {
+		spec, runStrategy string
+	}{{policy.Start, "Always"}, {policy.Stop, "Halted"}} {
+		if schedule.spec == "" {
+			continue
+		}
+		sched, err := cron.ParseStandard(schedule.spec)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid schedule %q: %w", schedule.spec, err)
+		}
+		for t := sched.Next(last); !t.After(now); t = sched.Next(t) {
+			if !t.Before(latest) {
+				latest, runStrategy = t, schedule.runStrategy
+			}
+		}
+		if next := sched.Next(now).Sub(now); wait == 0 || next < wait {
+			wait = next
+		}
+	}
+	return runStrategy, wait, nil
+}
+
+// reconcileSchedulePolicy starts or stops the VM when its SchedulePolicy fired since it
+// was last applied, and returns the time until the next firing, or zero without a
+// policy. A policy first seen only records the time, so attaching one to an existing
+// VM does not replay its past firings. Starting or stopping the VM in between is left
+// alone
+func (r *VirtualMachineReconciler) reconcileSchedulePolicy(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (time.Duration, error) {
+	log := logf.FromContext(ctx)
+
+	if vm.Spec.SchedulePolicy == nil {
+		// Forget the last firing so a policy attached later starts afresh
+		if vm.Status.LastScheduleTime != nil {
+			return 0, r.setLastScheduleTime(ctx, vm, nil)
+		}
+		return 0, nil
+	}
+
+	now := time.Now()
+	if vm.Status.LastScheduleTime == nil {
+		_, wait, err := scheduleTiming(vm.Spec.SchedulePolicy, now, now)
+		if err != nil {
+			log.Error(err, "Skipping VM schedule policy", "vm", vm.Name)
+			return 0, nil
+		}
+		scheduleTime := metav1.NewTime(now)
+		return wait, r.setLastScheduleTime(ctx, vm, &scheduleTime)
+	}
+
+	runStrategy, wait, err := scheduleTiming(vm.Spec.SchedulePolicy, vm.Status.LastScheduleTime.Time, now)
+	if err != nil {
+		// An invalid policy will not fix itself by retrying
+		log.Error(err, "Skipping VM schedule policy", "vm", vm.Name)
+		return 0, nil
+	}
+	if runStrategy == "" {
+		return wait, nil
+	}
+
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(vm), latestVM); err != nil {
+		return 0, err
+	}
+	if latestVM.Spec.RunStrategy != runStrategy {
+		log.Info("Applying VM schedule policy", "vm", vm.Name, "runStrategy", runStrategy)
+		latestVM.Spec.RunStrategy = runStrategy
+		if err := r.Update(ctx, latestVM); err != nil {
+			return 0, err
+		}
+		if r.Recorder != nil {
+			message := "Started on schedule"
+			if runStrategy == "Halted" {
+				message = "Stopped on schedule"
+			}
+			r.Recorder.Event(latestVM, corev1.EventTypeNormal, "Scheduled", message)
+		}
+	}
+	scheduleTime := metav1.NewTime(now)
+	return wait, r.setLastScheduleTime(ctx, latestVM, &scheduleTime)
+}
+
+// setLastScheduleTime records when the SchedulePolicy of vm was last applied
+func (r *VirtualMachineReconciler) setLastScheduleTime(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, scheduleTime *metav1.Time) error {
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(vm), latestVM); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	latestVM.Status.LastScheduleTime = scheduleTime
+	return r.Status().Update(ctx, latestVM)
+}
@@ -19,18 +19,32 @@ package controller
 import (
 	"context"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
 )
 
 // ServiceReconciler reconciles a Service object
 type ServiceReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Images resolves the service image recorded in the status
+	Images ImagePolicy
+	// Recorder emits events for service lifecycle changes and reconcile errors
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=services,verbs=get;list;watch;create;update;patch;delete
@@ -38,7 +52,7 @@ type ServiceReconciler struct {
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=services/finalizers,verbs=update
 
 // Reconcile is part of the main kubernetes reconciliation loop
-func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
 	logger := log.FromContext(ctx)
 
 	// Fetch the Service instance
@@ -46,12 +60,50 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	if err := r.Get(ctx, req.NamespacedName, service); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	defer func() { recordReconcileError(r.Recorder, service, err) }()
 
 	logger.Info("Reconciling Service", "name", service.Name, "namespace", service.Namespace)
 
-	// Update status to Running if not set
-	if service.Status.Phase == "" {
-		service.Status.Phase = llmcloudv1alpha1.ServicePhasePending
+	if err := inheritProjectMetadata(ctx, r.Client, service); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if ok, err := checkReplicasLimit(ctx, r.Client, r.Recorder, service, &service.Status.Conditions, service.Spec.Replicas); !ok || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The spec is only defaulted in memory, so the preset of a managed type can change
+	// with the operator
+	llmcloudv1alpha1.ApplyServicePreset(&service.Spec)
+	image := r.Images.Resolve(service.Spec.Image)
+	rollout, err := r.reconcileServiceWorkload(ctx, service, image)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	phase := llmcloudv1alpha1.ServicePhasePending
+	if rollout.ReadyReplicas >= desiredServiceReplicas(service) {
+		phase = llmcloudv1alpha1.ServicePhaseRunning
+	}
+	endpoint := serviceEndpoint(service)
+	if phase == llmcloudv1alpha1.ServicePhaseRunning && service.Status.Phase != phase {
+		recordEvent(r.Recorder, service, corev1.EventTypeNormal, "Running", "All %d replicas are ready", desiredServiceReplicas(service))
+	}
+	credentials := ""
+	if _, ok := managedServiceFor(service); ok {
+		credentials = ServiceCredentialsSecretName(service)
+	}
+	changed := service.Status.Phase != phase || service.Status.Image != image || service.Status.Endpoint != endpoint ||
+		service.Status.CredentialsSecret != credentials
+	service.Status.Phase = phase
+	service.Status.Image = image
+	service.Status.Endpoint = endpoint
+	service.Status.CredentialsSecret = credentials
+	if mirrorRolloutStatus(service, rollout) {
+		changed = true
+	}
+
+	if changed {
 		if err := r.Status().Update(ctx, service); err != nil {
 			return ctrl.Result{}, err
 		}
@@ -60,9 +112,77 @@ func (r *ServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	return ctrl.Result{}, nil
 }
 
+// mirrorRolloutStatus copies the replica counts and Progressing condition of the
+// service's workload into its status, reporting whether anything changed
+func mirrorRolloutStatus(service *llmcloudv1alpha1.Service, rollout appsv1.DeploymentStatus) bool {
+	status := &service.Status
+	before := *status
+	status.ReadyReplicas = rollout.ReadyReplicas
+	status.UpdatedReplicas = rollout.UpdatedReplicas
+	status.AvailableReplicas = rollout.AvailableReplicas
+	status.UnavailableReplicas = rollout.UnavailableReplicas
+	changed := status.ReadyReplicas != before.ReadyReplicas ||
+		status.UpdatedReplicas != before.UpdatedReplicas ||
+		status.AvailableReplicas != before.AvailableReplicas ||
+		status.UnavailableReplicas != before.UnavailableReplicas
+
+	for _, c := range rollout.Conditions {
+		if c.Type != appsv1.DeploymentProgressing {
+			continue
+		}
+		if meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:               string(appsv1.DeploymentProgressing),
+			Status:             metav1.ConditionStatus(c.Status),
+			Reason:             c.Reason,
+			Message:            c.Message,
+			ObservedGeneration: service.Generation,
+		}) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// checkReplicasLimit reports whether replicas is within MaxReplicas. When it is not, a
+// ReplicasExceedLimit condition and warning event are recorded on obj so the workload is
+// not scaled until the spec is fixed; the condition is removed again once the replicas
+// are within the limit
+func checkReplicasLimit(ctx context.Context, c client.Client, recorder record.EventRecorder, obj client.Object,
+	conditions *[]metav1.Condition, replicas int32) (bool, error) {
+	if err := llmcloudv1alpha1.ValidateReplicas(replicas); err != nil {
+		log.FromContext(ctx).Info("Rejecting replicas", "name", obj.GetName(), "reason", err.Error())
+		changed := meta.SetStatusCondition(conditions, metav1.Condition{
+			Type:               "Ready",
+			Status:             metav1.ConditionFalse,
+			Reason:             "ReplicasExceedLimit",
+			Message:            err.Error(),
+			ObservedGeneration: obj.GetGeneration(),
+		})
+		if changed {
+			recordEvent(recorder, obj, corev1.EventTypeWarning, "ReplicasExceedLimit", "%v", err)
+			return false, c.Status().Update(ctx, obj)
+		}
+		return false, nil
+	}
+
+	if cond := meta.FindStatusCondition(*conditions, "Ready"); cond != nil && cond.Reason == "ReplicasExceedLimit" {
+		meta.RemoveStatusCondition(conditions, "Ready")
+		return true, c.Status().Update(ctx, obj)
+	}
+	return true, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&llmcloudv1alpha1.Service{}).
-		Complete(r)
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.CronJob{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.Ingress{}).
+		Watches(&llmcloudv1alpha1.Project{}, handler.EnqueueRequestsFromMapFunc(
+			workloadsForProject(mgr.GetClient(), func() client.ObjectList { return &llmcloudv1alpha1.ServiceList{} }))).
+		Complete(metrics.Reconciler("Service", r))
 }
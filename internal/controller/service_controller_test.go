@@ -0,0 +1,323 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("Service Controller", func() {
+	Context("When mirroring the Deployment rollout", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "web", Namespace: "default"}
+
+		newRolloutReconciler := func(objs ...client.Object) *ServiceReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(appsv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(networkingv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			service := &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: llmcloudv1alpha1.ServiceSpec{
+					Type:     "web",
+					Image:    "nginx:latest",
+					Replicas: 3,
+					Ports:    []llmcloudv1alpha1.ServicePort{{Name: "http", Port: 80, TargetPort: 8080}},
+				},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.Service{}).
+				WithObjects(append(objs, service)...).
+				Build()
+			return &ServiceReconciler{Client: c, Scheme: testScheme}
+		}
+
+		reconcileService := func(r *ServiceReconciler) *llmcloudv1alpha1.Service {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			service := &llmcloudv1alpha1.Service{}
+			Expect(r.Get(ctx, key, service)).To(Succeed())
+			return service
+		}
+
+		It("should mirror the Deployment's replica counts and Progressing condition", func() {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Status: appsv1.DeploymentStatus{
+					Replicas:            3,
+					UpdatedReplicas:     2,
+					ReadyReplicas:       2,
+					AvailableReplicas:   1,
+					UnavailableReplicas: 2,
+					Conditions: []appsv1.DeploymentCondition{{
+						Type:    appsv1.DeploymentProgressing,
+						Status:  corev1.ConditionTrue,
+						Reason:  "ReplicaSetUpdated",
+						Message: `ReplicaSet "web-abc" is progressing.`,
+					}},
+				},
+			}
+			r := newRolloutReconciler(deployment)
+
+			service := reconcileService(r)
+			Expect(service.Status.UpdatedReplicas).To(Equal(int32(2)))
+			Expect(service.Status.ReadyReplicas).To(Equal(int32(2)))
+			Expect(service.Status.AvailableReplicas).To(Equal(int32(1)))
+			Expect(service.Status.UnavailableReplicas).To(Equal(int32(2)))
+
+			progressing := meta.FindStatusCondition(service.Status.Conditions, "Progressing")
+			Expect(progressing).NotTo(BeNil())
+			Expect(progressing.Status).To(Equal(metav1.ConditionTrue))
+			Expect(progressing.Reason).To(Equal("ReplicaSetUpdated"))
+
+			By("completing the rollout")
+			Expect(r.Get(ctx, key, deployment)).To(Succeed())
+			deployment.Status.UpdatedReplicas = 3
+			deployment.Status.ReadyReplicas = 3
+			deployment.Status.AvailableReplicas = 3
+			deployment.Status.UnavailableReplicas = 0
+			deployment.Status.Conditions[0].Reason = "NewReplicaSetAvailable"
+			Expect(r.Status().Update(ctx, deployment)).To(Succeed())
+
+			service = reconcileService(r)
+			Expect(service.Status.Phase).To(Equal(llmcloudv1alpha1.ServicePhaseRunning))
+			Expect(service.Status.AvailableReplicas).To(Equal(int32(3)))
+			Expect(service.Status.UnavailableReplicas).To(BeZero())
+			Expect(meta.FindStatusCondition(service.Status.Conditions, "Progressing").Reason).To(Equal("NewReplicaSetAvailable"))
+		})
+
+		It("should record events for exceeding the replicas limit and for running", func() {
+			r := newRolloutReconciler()
+			recorder := record.NewFakeRecorder(10)
+			r.Recorder = recorder
+			service := &llmcloudv1alpha1.Service{}
+			Expect(r.Get(ctx, key, service)).To(Succeed())
+			service.Spec.Replicas = llmcloudv1alpha1.MaxReplicas + 1
+			Expect(r.Update(ctx, service)).To(Succeed())
+
+			reconcileService(r)
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning ReplicasExceedLimit")))
+			reconcileService(r)
+			Expect(recorder.Events).NotTo(Receive())
+
+			By("scaling back within the limit")
+			Expect(r.Get(ctx, key, service)).To(Succeed())
+			service.Spec.Replicas = 1
+			Expect(r.Update(ctx, service)).To(Succeed())
+			reconcileService(r)
+			deployment := &appsv1.Deployment{}
+			Expect(r.Get(ctx, key, deployment)).To(Succeed())
+			deployment.Status.ReadyReplicas = 1
+			Expect(r.Status().Update(ctx, deployment)).To(Succeed())
+			reconcileService(r)
+			Expect(recorder.Events).To(Receive(Equal("Normal Running All 1 replicas are ready")))
+		})
+
+		It("should leave the rollout status empty until the Deployment reports one", func() {
+			service := reconcileService(newRolloutReconciler())
+			Expect(service.Status.Phase).To(Equal(llmcloudv1alpha1.ServicePhasePending))
+			Expect(service.Status.UpdatedReplicas).To(BeZero())
+			Expect(meta.FindStatusCondition(service.Status.Conditions, "Progressing")).To(BeNil())
+		})
+
+		It("should run the image behind a Service and an optional Ingress", func() {
+			r := newRolloutReconciler()
+			service := reconcileService(r)
+			Expect(service.Status.Endpoint).To(Equal("http://web.default.svc:80"))
+
+			deployment := &appsv1.Deployment{}
+			Expect(r.Get(ctx, key, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(3)))
+			Expect(deployment.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+			container := deployment.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("nginx:latest"))
+			Expect(container.Ports[0].ContainerPort).To(Equal(int32(8080)))
+			Expect(metav1.IsControlledBy(deployment, service)).To(BeTrue())
+
+			svc := &corev1.Service{}
+			Expect(r.Get(ctx, key, svc)).To(Succeed())
+			Expect(svc.Spec.Ports[0].Port).To(Equal(int32(80)))
+			Expect(svc.Spec.Ports[0].TargetPort.IntValue()).To(Equal(8080))
+
+			By("adding an ingress")
+			service.Spec.Ingress = &llmcloudv1alpha1.ServiceIngress{Host: "web.example.com", TLSSecretName: "web-tls"}
+			service.Spec.Image = "nginx:1.27"
+			Expect(r.Update(ctx, service)).To(Succeed())
+			service = reconcileService(r)
+			Expect(service.Status.Endpoint).To(Equal("https://web.example.com/"))
+
+			ingress := &networkingv1.Ingress{}
+			Expect(r.Get(ctx, key, ingress)).To(Succeed())
+			Expect(ingress.Spec.Rules[0].Host).To(Equal("web.example.com"))
+			Expect(ingress.Spec.Rules[0].HTTP.Paths[0].Backend.Service.Port.Number).To(Equal(int32(80)))
+			Expect(r.Get(ctx, key, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("nginx:1.27"))
+
+			By("removing the ingress")
+			service.Spec.Ingress = nil
+			Expect(r.Update(ctx, service)).To(Succeed())
+			reconcileService(r)
+			Expect(errors.IsNotFound(r.Get(ctx, key, ingress))).To(BeTrue())
+		})
+	})
+
+	Context("When reconciling a managed service", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "db", Namespace: "default"}
+
+		newManagedReconciler := func(spec llmcloudv1alpha1.ServiceSpec) *ServiceReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(appsv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(batchv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(networkingv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			service := &llmcloudv1alpha1.Service{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec:       spec,
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.Service{}).
+				WithObjects(service).
+				Build()
+			return &ServiceReconciler{Client: c, Scheme: testScheme, Recorder: record.NewFakeRecorder(10)}
+		}
+
+		reconcileService := func(r *ServiceReconciler) *llmcloudv1alpha1.Service {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			service := &llmcloudv1alpha1.Service{}
+			Expect(r.Get(ctx, key, service)).To(Succeed())
+			return service
+		}
+
+		It("should run the preset as a StatefulSet with generated credentials and backups", func() {
+			r := newManagedReconciler(llmcloudv1alpha1.ServiceSpec{
+				Type:    llmcloudv1alpha1.ServiceTypePostgreSQL,
+				Managed: true,
+				Backup:  &llmcloudv1alpha1.ServiceBackup{Schedule: "0 2 * * *", Retention: 3},
+			})
+			service := reconcileService(r)
+			Expect(service.Status.Endpoint).To(Equal("postgresql://db.default.svc:5432/app"))
+			Expect(service.Status.CredentialsSecret).To(Equal("db-service-credentials"))
+			Expect(service.Status.Image).To(Equal("postgres:16-alpine"))
+
+			secret := &corev1.Secret{}
+			Expect(r.Get(ctx, types.NamespacedName{Name: "db-service-credentials", Namespace: key.Namespace}, secret)).To(Succeed())
+			Expect(string(secret.Data["username"])).To(Equal("postgres"))
+			Expect(string(secret.Data["database"])).To(Equal("app"))
+			password := string(secret.Data["password"])
+			Expect(password).NotTo(BeEmpty())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(r.Get(ctx, key, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(1)))
+			Expect(sts.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests.Storage().String()).To(Equal("10Gi"))
+			container := sts.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("postgres:16-alpine"))
+			Expect(container.VolumeMounts[0].MountPath).To(Equal("/var/lib/postgresql/data"))
+			Expect(container.Env).To(ContainElement(HaveField("Name", "POSTGRES_PASSWORD")))
+			Expect(errors.IsNotFound(r.Get(ctx, key, &appsv1.Deployment{}))).To(BeTrue())
+
+			svc := &corev1.Service{}
+			Expect(r.Get(ctx, key, svc)).To(Succeed())
+			Expect(svc.Spec.Ports[0].Port).To(Equal(int32(5432)))
+
+			backupsKey := types.NamespacedName{Name: "db-backups", Namespace: key.Namespace}
+			cronJob := &batchv1.CronJob{}
+			Expect(r.Get(ctx, backupsKey, cronJob)).To(Succeed())
+			Expect(cronJob.Spec.Schedule).To(Equal("0 2 * * *"))
+			Expect(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Env).To(ContainElement(
+				corev1.EnvVar{Name: "RETENTION", Value: "3"}))
+			Expect(cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Resources.Requests).To(HaveKey(corev1.ResourceMemory))
+			Expect(r.Get(ctx, backupsKey, &corev1.PersistentVolumeClaim{})).To(Succeed())
+
+			By("turning backups off")
+			service.Spec.Backup = nil
+			Expect(r.Update(ctx, service)).To(Succeed())
+			reconcileService(r)
+			Expect(errors.IsNotFound(r.Get(ctx, backupsKey, cronJob))).To(BeTrue())
+			Expect(r.Get(ctx, client.ObjectKeyFromObject(secret), secret)).To(Succeed())
+			Expect(string(secret.Data["password"])).To(Equal(password))
+		})
+
+		It("should run a service of a managed type's name as a Deployment unless it is managed", func() {
+			r := newManagedReconciler(llmcloudv1alpha1.ServiceSpec{
+				Type:     llmcloudv1alpha1.ServiceTypePostgreSQL,
+				Image:    "postgres:15",
+				Replicas: 1,
+			})
+			service := reconcileService(r)
+			Expect(service.Status.CredentialsSecret).To(BeEmpty())
+			Expect(r.Get(ctx, key, &appsv1.Deployment{})).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, &appsv1.StatefulSet{}))).To(BeTrue())
+
+			By("refusing to replace the Deployment once the service is marked managed")
+			service.Spec.Managed = true
+			Expect(r.Update(ctx, service)).To(Succeed())
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(HaveOccurred())
+			Expect(r.Get(ctx, key, &appsv1.Deployment{})).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, &appsv1.StatefulSet{}))).To(BeTrue())
+			Expect(errors.IsNotFound(r.Get(ctx, types.NamespacedName{Name: "db-service-credentials", Namespace: key.Namespace},
+				&corev1.Secret{}))).To(BeTrue())
+		})
+
+		It("should refuse to replace the StatefulSet of a managed service with a Deployment", func() {
+			r := newManagedReconciler(llmcloudv1alpha1.ServiceSpec{
+				Type:    llmcloudv1alpha1.ServiceTypePostgreSQL,
+				Managed: true,
+			})
+			service := reconcileService(r)
+			Expect(r.Get(ctx, key, &appsv1.StatefulSet{})).To(Succeed())
+
+			By("turning managed off without the webhook in the way")
+			service.Spec.Managed = false
+			service.Spec.Image = "postgres:16-alpine"
+			Expect(r.Update(ctx, service)).To(Succeed())
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).To(MatchError(ContainSubstring("already runs as a StatefulSet")))
+			Expect(r.Get(ctx, key, &appsv1.StatefulSet{})).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, &appsv1.Deployment{}))).To(BeTrue())
+			Expect(r.Recorder.(*record.FakeRecorder).Events).To(Receive(ContainSubstring("ManagedConflict")))
+		})
+	})
+})
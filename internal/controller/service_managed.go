@@ -0,0 +1,364 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/auth"
+)
+
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+
+const (
+	// servicePasswordLength is the length of the generated password of a managed service
+	servicePasswordLength = 24
+	// serviceDataVolume is the volume claim template of a managed service's data
+	serviceDataVolume = "data"
+	// serviceBackupPath is where the backup volume is mounted in a backup Job
+	serviceBackupPath = "/backups"
+)
+
+// managedService is how a managed service type runs: where its data lives, how the
+// container gets the generated credentials and how a backup is taken
+type managedService struct {
+	// scheme is the URL scheme of the service's endpoint
+	scheme string
+	// username is the administrative user, whose password is generated
+	username string
+	// database is the database created and connected to, for a type with databases
+	database string
+	// dataPath is where the data volume is mounted
+	dataPath string
+	// env is set on the container, and credentialEnv to keys of the credentials Secret
+	env           []corev1.EnvVar
+	credentialEnv []credentialEnvVar
+	// args are the container args unless the service sets its own
+	args []string
+	// backup writes a backup of the service at $HOST:$PORT to $FILE, authenticating
+	// with $USERNAME and $PASSWORD
+	backup string
+	// backupExtension is the file extension of the backups
+	backupExtension string
+}
+
+// credentialEnvVar sets the environment variable name to key of the credentials Secret
+type credentialEnvVar struct {
+	name, key string
+}
+
+// Keys of the credentials Secret of a managed service
+const (
+	serviceDatabaseKey = "database"
+)
+
+// managedServices are the managed service types of llmcloudv1alpha1.ServiceCatalog
+var managedServices = map[string]managedService{
+	llmcloudv1alpha1.ServiceTypePostgreSQL: {
+		scheme:   "postgresql",
+		username: "postgres",
+		database: "app",
+		dataPath: "/var/lib/postgresql/data",
+		// The volume's root may hold lost+found, which initdb refuses
+		env: []corev1.EnvVar{{Name: "PGDATA", Value: "/var/lib/postgresql/data/pgdata"}},
+		credentialEnv: []credentialEnvVar{
+			{"POSTGRES_USER", corev1.BasicAuthUsernameKey},
+			{"POSTGRES_PASSWORD", corev1.BasicAuthPasswordKey},
+			{"POSTGRES_DB", serviceDatabaseKey},
+		},
+		backup:          `PGPASSWORD="$PASSWORD" pg_dumpall -h "$HOST" -p "$PORT" -U "$USERNAME" | gzip > "$FILE"`,
+		backupExtension: "sql.gz",
+	},
+	llmcloudv1alpha1.ServiceTypeRedis: {
+		scheme:          "redis",
+		username:        "default",
+		dataPath:        "/data",
+		credentialEnv:   []credentialEnvVar{{"REDIS_PASSWORD", corev1.BasicAuthPasswordKey}},
+		args:            []string{"--requirepass", "$(REDIS_PASSWORD)", "--appendonly", "yes", "--dir", "/data"},
+		backup:          `redis-cli -h "$HOST" -p "$PORT" -a "$PASSWORD" --no-auth-warning --rdb "$FILE"`,
+		backupExtension: "rdb",
+	},
+	llmcloudv1alpha1.ServiceTypeMinIO: {
+		scheme:   "http",
+		username: "admin",
+		dataPath: "/data",
+		credentialEnv: []credentialEnvVar{
+			{"MINIO_ROOT_USER", corev1.BasicAuthUsernameKey},
+			{"MINIO_ROOT_PASSWORD", corev1.BasicAuthPasswordKey},
+		},
+		args: []string{"server", "/data", "--console-address", ":9001"},
+		backup: `export MC_CONFIG_DIR=/tmp/mc
+mc alias set source "http://$HOST:$PORT" "$USERNAME" "$PASSWORD" > /dev/null
+mc mirror --quiet source /tmp/mirror
+tar czf "$FILE" -C /tmp/mirror .`,
+		backupExtension: "tar.gz",
+	},
+}
+
+// managedServiceFor returns how service runs when it is managed. A service whose type
+// merely has the name of a managed type is not
+func managedServiceFor(service *llmcloudv1alpha1.Service) (managedService, bool) {
+	if !service.Spec.Managed {
+		return managedService{}, false
+	}
+	m, ok := managedServices[service.Spec.Type]
+	return m, ok
+}
+
+// serviceBackupScript runs the backup command of a managed service between the lines
+// below. A backup is written to a partial file first, so that a failed one neither
+// counts as a backup nor displaces a good one, and only the newest $RETENTION are kept
+const (
+	serviceBackupPrologue = `set -eo pipefail
+rm -f /backups/*.partial
+FILE="/backups/$(date -u +%Y%m%d-%H%M%S).$EXTENSION.partial"
+`
+	serviceBackupEpilogue = `
+mv "$FILE" "${FILE%.partial}"
+ls -1t /backups/*."$EXTENSION" | tail -n +$((RETENTION + 1)) | xargs -r rm -f
+`
+)
+
+// serviceBackupResources are what a backup Job of a managed service requests
+var serviceBackupResources = corev1.ResourceRequirements{
+	Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+	Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("512Mi")},
+}
+
+// ServiceCredentialsSecretName is the Secret holding the generated credentials of a
+// managed service
+func ServiceCredentialsSecretName(service *llmcloudv1alpha1.Service) string {
+	return service.Name + "-service-credentials"
+}
+
+// serviceBackupsName names the CronJob and the volume of a managed service's backups
+func serviceBackupsName(service *llmcloudv1alpha1.Service) string {
+	return service.Name + "-backups"
+}
+
+// reconcileManagedService creates or updates the credentials Secret, StatefulSet and
+// backup CronJob of a managed service, and returns the rollout status of the
+// StatefulSet. A Deployment the service already runs as is never replaced, since the
+// StatefulSet would start on an empty volume with new credentials
+func (r *ServiceReconciler) reconcileManagedService(ctx context.Context, service *llmcloudv1alpha1.Service, m managedService,
+	image string, resources corev1.ResourceRequirements, labels, selector map[string]string) (appsv1.DeploymentStatus, error) {
+	deployment := &appsv1.Deployment{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: service.Namespace, Name: service.Name}, deployment)
+	if err == nil && metav1.IsControlledBy(deployment, service) {
+		recordEvent(r.Recorder, service, corev1.EventTypeWarning, "ManagedConflict",
+			"The service runs as Deployment %s, which is not replaced by a managed service; create a new service instead", deployment.Name)
+		return appsv1.DeploymentStatus{}, fmt.Errorf("service %s already runs as a Deployment", service.Name)
+	}
+	if client.IgnoreNotFound(err) != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	if err := r.reconcileServiceCredentials(ctx, service, m); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	storage, err := resource.ParseQuantity(service.Spec.Storage.Size)
+	if err != nil {
+		return appsv1.DeploymentStatus{}, fmt.Errorf("invalid storage size %q: %w", service.Spec.Storage.Size, err)
+	}
+
+	container := r.serviceContainer(service, image, resources)
+	container.Env = append(managedServiceEnv(service, m), container.Env...)
+	if len(container.Args) == 0 {
+		container.Args = m.args
+	}
+	container.VolumeMounts = []corev1.VolumeMount{{Name: serviceDataVolume, MountPath: m.dataPath}}
+	container.ReadinessProbe = &corev1.Probe{ProbeHandler: corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt32(serviceTargetPort(service.Spec.Ports[0]))},
+	}}
+
+	sts := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: service.Name, Namespace: service.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, sts, func() error {
+		sts.Labels = labels
+		sts.Spec.Replicas = ptr.To(desiredServiceReplicas(service))
+		// The selector and volume claims of a StatefulSet cannot change
+		if sts.CreationTimestamp.IsZero() {
+			sts.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+			sts.Spec.ServiceName = service.Name
+			sts.Spec.VolumeClaimTemplates = []corev1.PersistentVolumeClaim{
+				servicePVC(serviceDataVolume, service.Namespace, labels, storage, service.Spec.Storage.StorageClass),
+			}
+			// The credentials are generated anew with the service, so its data goes with it
+			sts.Spec.PersistentVolumeClaimRetentionPolicy = &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenScaled:  appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+			}
+		}
+		sts.Spec.Template.Labels = labels
+		sts.Spec.Template.Spec.Containers = []corev1.Container{container}
+		return controllerutil.SetControllerReference(service, sts, r.Scheme)
+	}); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+
+	if err := r.reconcileServiceBackup(ctx, service, m, image, labels, storage); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	return appsv1.DeploymentStatus{
+		Replicas:            sts.Status.Replicas,
+		ReadyReplicas:       sts.Status.ReadyReplicas,
+		UpdatedReplicas:     sts.Status.UpdatedReplicas,
+		AvailableReplicas:   sts.Status.AvailableReplicas,
+		UnavailableReplicas: max(desiredServiceReplicas(service)-sts.Status.AvailableReplicas, 0),
+	}, nil
+}
+
+// reconcileServiceCredentials creates the Secret with the credentials of a managed
+// service. The password is generated once and kept
+func (r *ServiceReconciler) reconcileServiceCredentials(ctx context.Context, service *llmcloudv1alpha1.Service, m managedService) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: ServiceCredentialsSecretName(service), Namespace: service.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = map[string]string{"llmcloud.io/managed": "true", serviceLabel: service.Name}
+		if secret.CreationTimestamp.IsZero() {
+			secret.Type = corev1.SecretTypeBasicAuth
+		}
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		secret.Data[corev1.BasicAuthUsernameKey] = []byte(m.username)
+		if m.database != "" {
+			secret.Data[serviceDatabaseKey] = []byte(m.database)
+		}
+		if len(secret.Data[corev1.BasicAuthPasswordKey]) == 0 {
+			password, err := auth.GeneratePassword(servicePasswordLength)
+			if err != nil {
+				return err
+			}
+			secret.Data[corev1.BasicAuthPasswordKey] = []byte(password)
+		}
+		return controllerutil.SetControllerReference(service, secret, r.Scheme)
+	})
+	return err
+}
+
+// managedServiceEnv returns the environment of a managed service's container
+func managedServiceEnv(service *llmcloudv1alpha1.Service, m managedService) []corev1.EnvVar {
+	env := append([]corev1.EnvVar{}, m.env...)
+	for _, c := range m.credentialEnv {
+		env = append(env, credentialEnv(service, c.name, c.key))
+	}
+	return env
+}
+
+// credentialEnv sets the environment variable name to key of the service's credentials
+func credentialEnv(service *llmcloudv1alpha1.Service, name, key string) corev1.EnvVar {
+	return corev1.EnvVar{Name: name, ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: ServiceCredentialsSecretName(service)},
+		Key:                  key,
+	}}}
+}
+
+// servicePVC returns a ReadWriteOnce volume claim of size
+func servicePVC(name, namespace string, labels map[string]string, size resource.Quantity, storageClass string) corev1.PersistentVolumeClaim {
+	pvc := corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: size},
+			},
+		},
+	}
+	if storageClass != "" {
+		pvc.Spec.StorageClassName = &storageClass
+	}
+	return pvc
+}
+
+// reconcileServiceBackup creates or updates the CronJob backing up a managed service to
+// its backup volume, or deletes the CronJob once the service no longer asks for
+// backups. The volume and the backups on it are kept until the service is deleted
+func (r *ServiceReconciler) reconcileServiceBackup(ctx context.Context, service *llmcloudv1alpha1.Service, m managedService,
+	image string, labels map[string]string, storage resource.Quantity) error {
+	name := serviceBackupsName(service)
+	cronJob := &batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: service.Namespace}}
+	backup := service.Spec.Backup
+	if backup == nil {
+		return deleteOwned(ctx, r.Client, service, cronJob)
+	}
+
+	size := storage
+	if backup.Size != "" {
+		var err error
+		if size, err = resource.ParseQuantity(backup.Size); err != nil {
+			return fmt.Errorf("invalid backup size %q: %w", backup.Size, err)
+		}
+	}
+	pvc := servicePVC(name, service.Namespace, labels, size, service.Spec.Storage.StorageClass)
+	if err := controllerutil.SetControllerReference(service, &pvc, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, &pvc); client.IgnoreAlreadyExists(err) != nil {
+		return err
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "HOST", Value: fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace)},
+		{Name: "PORT", Value: strconv.Itoa(int(service.Spec.Ports[0].Port))},
+		{Name: "EXTENSION", Value: m.backupExtension},
+		{Name: "RETENTION", Value: strconv.Itoa(int(cmp.Or(backup.Retention, 7)))},
+		credentialEnv(service, "USERNAME", corev1.BasicAuthUsernameKey),
+		credentialEnv(service, "PASSWORD", corev1.BasicAuthPasswordKey),
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, cronJob, func() error {
+		cronJob.Labels = labels
+		cronJob.Spec.Schedule = backup.Schedule
+		cronJob.Spec.ConcurrencyPolicy = batchv1.ForbidConcurrent
+		jobSpec := &cronJob.Spec.JobTemplate.Spec
+		jobSpec.BackoffLimit = ptr.To(int32(2))
+		jobSpec.Template.Labels = map[string]string{"llmcloud.io/managed": "true"}
+		jobSpec.Template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+		jobSpec.Template.Spec.Containers = []corev1.Container{{
+			Name:            "backup",
+			Image:           image,
+			ImagePullPolicy: corev1.PullPolicy(r.Images.PullPolicy),
+			Command:         []string{"sh", "-c", serviceBackupPrologue + m.backup + serviceBackupEpilogue},
+			Env:             env,
+			VolumeMounts:    []corev1.VolumeMount{{Name: "backups", MountPath: serviceBackupPath}},
+			// A project's quota admits only pods that state what they use
+			Resources: serviceBackupResources,
+		}}
+		jobSpec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "backups",
+			VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: name,
+			}},
+		}}
+		return controllerutil.SetControllerReference(service, cronJob, r.Scheme)
+	})
+	return err
+}
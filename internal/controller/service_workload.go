@@ -0,0 +1,248 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// serviceLabel selects the pods of a service
+const serviceLabel = "llmcloud.io/service"
+
+// +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+
+// desiredServiceReplicas is the number of replicas a service runs, at least one
+func desiredServiceReplicas(service *llmcloudv1alpha1.Service) int32 {
+	return max(service.Spec.Replicas, 1)
+}
+
+// serviceTargetPort returns the container port traffic for port is sent to
+func serviceTargetPort(port llmcloudv1alpha1.ServicePort) int32 {
+	return cmp.Or(port.TargetPort, port.Port)
+}
+
+// serviceEndpoint is the URL a service is reached at: its ingress when it has one,
+// otherwise its first port in the cluster, with the scheme and database of a managed
+// type. Services without ports have no endpoint
+func serviceEndpoint(service *llmcloudv1alpha1.Service) string {
+	if ingress := service.Spec.Ingress; ingress != nil {
+		scheme := "http"
+		if ingress.TLSSecretName != "" {
+			scheme = "https"
+		}
+		return scheme + "://" + ingress.Host + cmp.Or(ingress.Path, "/")
+	}
+	if len(service.Spec.Ports) == 0 {
+		return ""
+	}
+	if m, ok := managedServiceFor(service); ok {
+		endpoint := fmt.Sprintf("%s://%s.%s.svc:%d", m.scheme, service.Name, service.Namespace, service.Spec.Ports[0].Port)
+		if m.database != "" {
+			endpoint += "/" + m.database
+		}
+		return endpoint
+	}
+	return fmt.Sprintf("http://%s.%s.svc:%d", service.Name, service.Namespace, service.Spec.Ports[0].Port)
+}
+
+// reconcileServiceWorkload creates or updates the Deployment running service, or the
+// StatefulSet of a managed type, the Service exposing its ports and its Ingress, all
+// owned by it, and returns the rollout status of the workload. Spec changes roll out
+// as a rolling update
+func (r *ServiceReconciler) reconcileServiceWorkload(ctx context.Context, service *llmcloudv1alpha1.Service, image string) (appsv1.DeploymentStatus, error) {
+	resources, err := service.Spec.Resources.PodResources()
+	if err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	labels := map[string]string{"llmcloud.io/managed": "true", serviceLabel: service.Name}
+	selector := map[string]string{serviceLabel: service.Name}
+
+	var status appsv1.DeploymentStatus
+	if m, ok := managedServiceFor(service); ok {
+		status, err = r.reconcileManagedService(ctx, service, m, image, resources, labels, selector)
+	} else {
+		status, err = r.reconcileServiceDeployment(ctx, service, image, resources, labels, selector)
+	}
+	if err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+
+	if err := r.reconcileServicePorts(ctx, service, labels, selector); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	if err := r.reconcileServiceIngress(ctx, service, labels); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	return status, nil
+}
+
+// reconcileServiceDeployment creates or updates the Deployment running service and
+// returns its status. A StatefulSet the service already runs as is never replaced,
+// since the Deployment would start without the data and credentials of the managed
+// service
+func (r *ServiceReconciler) reconcileServiceDeployment(ctx context.Context, service *llmcloudv1alpha1.Service, image string,
+	resources corev1.ResourceRequirements, labels, selector map[string]string) (appsv1.DeploymentStatus, error) {
+	sts := &appsv1.StatefulSet{}
+	err := r.Get(ctx, client.ObjectKey{Namespace: service.Namespace, Name: service.Name}, sts)
+	if err == nil && metav1.IsControlledBy(sts, service) {
+		recordEvent(r.Recorder, service, corev1.EventTypeWarning, "ManagedConflict",
+			"The service runs as managed StatefulSet %s, which is not replaced by a Deployment; create a new service instead", sts.Name)
+		return appsv1.DeploymentStatus{}, fmt.Errorf("service %s already runs as a StatefulSet", service.Name)
+	}
+	if client.IgnoreNotFound(err) != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: service.Name, Namespace: service.Namespace}}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, deployment, func() error {
+		deployment.Labels = labels
+		replicas := desiredServiceReplicas(service)
+		deployment.Spec.Replicas = &replicas
+		deployment.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+		if deployment.CreationTimestamp.IsZero() {
+			deployment.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+		}
+		deployment.Spec.Template.Labels = labels
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{r.serviceContainer(service, image, resources)}
+		return controllerutil.SetControllerReference(service, deployment, r.Scheme)
+	}); err != nil {
+		return appsv1.DeploymentStatus{}, err
+	}
+	return deployment.Status, nil
+}
+
+// serviceContainer returns the container running the service's image
+func (r *ServiceReconciler) serviceContainer(service *llmcloudv1alpha1.Service, image string, resources corev1.ResourceRequirements) corev1.Container {
+	container := corev1.Container{
+		Name:            "service",
+		Image:           image,
+		ImagePullPolicy: corev1.PullPolicy(r.Images.PullPolicy),
+		Command:         service.Spec.Command,
+		Args:            service.Spec.Args,
+		Resources:       resources,
+	}
+	for _, port := range service.Spec.Ports {
+		container.Ports = append(container.Ports, corev1.ContainerPort{
+			ContainerPort: serviceTargetPort(port),
+			Protocol:      corev1.Protocol(cmp.Or(port.Protocol, string(corev1.ProtocolTCP))),
+		})
+	}
+	for _, env := range service.Spec.Env {
+		envVar := corev1.EnvVar{Name: env.Name, Value: env.Value}
+		if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+			envVar.ValueFrom = &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: env.ValueFrom.SecretKeyRef.Name},
+				Key:                  env.ValueFrom.SecretKeyRef.Key,
+			}}
+		}
+		container.Env = append(container.Env, envVar)
+	}
+	return container
+}
+
+// reconcileServicePorts creates or updates the ClusterIP Service exposing the service's
+// ports, or deletes it once the service has none
+func (r *ServiceReconciler) reconcileServicePorts(ctx context.Context, service *llmcloudv1alpha1.Service, labels, selector map[string]string) error {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: service.Name, Namespace: service.Namespace}}
+	if len(service.Spec.Ports) == 0 {
+		return deleteOwned(ctx, r.Client, service, svc)
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		svc.Labels = labels
+		svc.Spec.Type = corev1.ServiceTypeClusterIP
+		svc.Spec.Selector = selector
+		svc.Spec.Ports = nil
+		for i, port := range service.Spec.Ports {
+			svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{
+				// Every port of a multi-port Service needs a name
+				Name:       cmp.Or(port.Name, fmt.Sprintf("port-%d", i)),
+				Port:       port.Port,
+				TargetPort: intstr.FromInt32(serviceTargetPort(port)),
+				Protocol:   corev1.Protocol(cmp.Or(port.Protocol, string(corev1.ProtocolTCP))),
+			})
+		}
+		return controllerutil.SetControllerReference(service, svc, r.Scheme)
+	})
+	return err
+}
+
+// reconcileServiceIngress creates or updates the Ingress routing the service's host and
+// path to its first port, or deletes it once the service no longer asks for one
+func (r *ServiceReconciler) reconcileServiceIngress(ctx context.Context, service *llmcloudv1alpha1.Service, labels map[string]string) error {
+	ingress := &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: service.Name, Namespace: service.Namespace}}
+	spec := service.Spec.Ingress
+	if spec == nil || len(service.Spec.Ports) == 0 {
+		return deleteOwned(ctx, r.Client, service, ingress)
+	}
+
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, ingress, func() error {
+		ingress.Labels = labels
+		ingress.Spec.IngressClassName = nil
+		if spec.ClassName != "" {
+			className := spec.ClassName
+			ingress.Spec.IngressClassName = &className
+		}
+		ingress.Spec.TLS = nil
+		if spec.TLSSecretName != "" {
+			ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{spec.Host}, SecretName: spec.TLSSecretName}}
+		}
+		pathType := networkingv1.PathTypePrefix
+		ingress.Spec.Rules = []networkingv1.IngressRule{{
+			Host: spec.Host,
+			IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+				Paths: []networkingv1.HTTPIngressPath{{
+					Path:     cmp.Or(spec.Path, "/"),
+					PathType: &pathType,
+					Backend: networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+						Name: service.Name,
+						Port: networkingv1.ServiceBackendPort{Number: service.Spec.Ports[0].Port},
+					}},
+				}},
+			}},
+		}}
+		return controllerutil.SetControllerReference(service, ingress, r.Scheme)
+	})
+	return err
+}
+
+// deleteOwned deletes obj if it exists and is controlled by owner
+func deleteOwned(ctx context.Context, c client.Client, owner, obj client.Object) error {
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if !metav1.IsControlledBy(obj, owner) {
+		return nil
+	}
+	if err := c.Delete(ctx, obj); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -18,40 +18,82 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
 )
 
 // UserReconciler reconciles a User object
 type UserReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder emits events when a user is disabled, locked out or active again
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=users,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=users/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=users/finalizers,verbs=update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the User object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
-//
-// For more details, check Reconcile and its Result here:
-// - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.22.1/pkg/reconcile
-func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = logf.FromContext(ctx)
-
-	// TODO(user): your logic here
-
-	return ctrl.Result{}, nil
+// userActiveCondition reports whether a user can log in
+const userActiveCondition = "Active"
+
+// Reconcile maintains the Active condition of a user: false while the user is disabled
+// or locked out after failed logins, true otherwise. An event is recorded whenever the
+// condition's reason changes, and a locked out user is reconciled again once the
+// lockout ends
+func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	log := logf.FromContext(ctx)
+
+	user := &llmcloudv1alpha1.User{}
+	if err := r.Get(ctx, req.NamespacedName, user); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	defer func() { recordReconcileError(r.Recorder, user, err) }()
+
+	cond := metav1.Condition{
+		Type:               userActiveCondition,
+		Status:             metav1.ConditionTrue,
+		Reason:             "Active",
+		Message:            "The user can log in",
+		ObservedGeneration: user.Generation,
+	}
+	eventType := corev1.EventTypeNormal
+	var requeue time.Duration
+	switch locked := user.Status.LockedUntil; {
+	case user.Spec.Disabled:
+		cond.Status, cond.Reason, cond.Message = metav1.ConditionFalse, "Disabled", "The user is disabled"
+	case locked != nil && time.Now().Before(locked.Time):
+		cond.Status, cond.Reason = metav1.ConditionFalse, "Locked"
+		cond.Message = fmt.Sprintf("Locked out until %s after %d failed logins",
+			locked.UTC().Format(time.RFC3339), user.Status.FailedLoginAttempts)
+		eventType = corev1.EventTypeWarning
+		requeue = time.Until(locked.Time)
+	}
+
+	previous := meta.FindStatusCondition(user.Status.Conditions, userActiveCondition)
+	if previous == nil || previous.Reason != cond.Reason {
+		log.Info("User state changed", "user", user.Name, "reason", cond.Reason)
+		recordEvent(r.Recorder, user, eventType, cond.Reason, "%s", cond.Message)
+	}
+	if meta.SetStatusCondition(&user.Status.Conditions, cond) {
+		if err := r.Status().Update(ctx, user); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeue}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
@@ -59,5 +101,5 @@ func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&llmcloudv1alpha1.User{}).
 		Named("user").
-		Complete(r)
+		Complete(metrics.Reconciler("User", r))
 }
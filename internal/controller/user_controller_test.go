@@ -18,11 +18,16 @@ package controller
 
 import (
 	"context"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -86,4 +91,58 @@ var _ = Describe("User Controller", func() {
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
 	})
+
+	Context("When tracking whether a user can log in", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "alice"}
+
+		It("should record the user being locked out, unlocked and disabled", func() {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			lockedUntil := metav1.NewTime(time.Now().Add(time.Minute))
+			user := &llmcloudv1alpha1.User{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name},
+				Spec:       llmcloudv1alpha1.UserSpec{Username: "alice"},
+				Status:     llmcloudv1alpha1.UserStatus{FailedLoginAttempts: 5, LockedUntil: &lockedUntil},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.User{}).
+				WithObjects(user).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &UserReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+
+			reconcileUser := func() (reconcile.Result, *metav1.Condition) {
+				result, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(c.Get(ctx, key, user)).To(Succeed())
+				return result, meta.FindStatusCondition(user.Status.Conditions, userActiveCondition)
+			}
+
+			result, cond := reconcileUser()
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("Locked"))
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, 5*time.Second))
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning Locked Locked out until")))
+
+			By("reconciling again while locked")
+			reconcileUser()
+			Expect(recorder.Events).NotTo(Receive())
+
+			By("ending the lockout")
+			user.Status.LockedUntil = nil
+			Expect(c.Status().Update(ctx, user)).To(Succeed())
+			_, cond = reconcileUser()
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Active")))
+
+			By("disabling the user")
+			user.Spec.Disabled = true
+			Expect(c.Update(ctx, user)).To(Succeed())
+			_, cond = reconcileUser()
+			Expect(cond.Reason).To(Equal("Disabled"))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Disabled")))
+		})
+	})
 })
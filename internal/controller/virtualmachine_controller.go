@@ -17,41 +17,136 @@ limitations under the License.
 package controller
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
 )
 
 // VirtualMachineReconciler reconciles a VirtualMachine object
 type VirtualMachineReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// CapturePhaseTransitions copies the VMI phase transition timestamps into the VM status
+	CapturePhaseTransitions bool
+
+	// Images is applied to the VM container disk
+	Images ImagePolicy
+
+	// Activity decides whether a VM with SuspendAfterIdle is idle; idle suspension is disabled when nil
+	Activity VMActivitySource
+
+	// CloudInit reads the guest's cloud-init status; it is not tracked when nil
+	CloudInit CloudInitStatusSource
+
+	// GuestAgent reads the hostname, filesystems and logged-in users of a running guest;
+	// only the OS and interfaces KubeVirt reports are recorded when nil
+	GuestAgent GuestAgentSource
+
+	// Recorder emits events for VM lifecycle changes made by the controller
+	Recorder record.EventRecorder
+
+	// ResyncInterval requeues every VM this often, correcting drift of the KubeVirt
+	// objects no watch event reported; VMs are only resynced on events when zero
+	ResyncInterval time.Duration
 }
 
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=virtualmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=virtualmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=virtualmachines/finalizers,verbs=update
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=sshkeys;users;vmimages,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cdi.kubevirt.io,resources=datavolumes/source,verbs=create
 // +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstances,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=kubevirt.io,resources=virtualmachineinstancemigrations,verbs=get;list;watch;create
+// +kubebuilder:rbac:groups=snapshot.kubevirt.io,resources=virtualmachinesnapshots,verbs=get;list;watch;create;delete
 
 const (
 	vmFinalizer = "llmcloud.llmcloud.io/vm-finalizer"
+
+	// vmBackupLabel marks VirtualMachineSnapshots taken by the backup schedule
+	// with the name of the VM they belong to
+	vmBackupLabel          = "llmcloud.io/backup-of"
+	defaultBackupRetention = 7
+
+	// vmRebootAnnotation requests a reboot. It is kept until the VM instance is
+	// Running again, so an interrupted reboot is retried
+	vmRebootAnnotation = "llmcloud.io/reboot"
+	// VMMigrateAnnotation asks the VirtualMachine controller to live-migrate a VM
+	VMMigrateAnnotation = "llmcloud.io/migrate"
+	// vmRebootTimeout is how long to wait for the VM instance to come back before
+	// issuing the reboot again
+	vmRebootTimeout = 5 * time.Minute
+)
+
+var (
+	vmSnapshotGVK  = schema.GroupVersionKind{Group: "snapshot.kubevirt.io", Version: "v1beta1", Kind: "VirtualMachineSnapshot"}
+	vmMigrationGVK = schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstanceMigration"}
 )
 
+// vmAction is a VM operation requested declaratively by setting an annotation to "true".
+// The annotation is kept until the object created for the request reaches a final phase,
+// so a request interrupted by an operator restart is not lost
+type vmAction struct {
+	annotation string
+	// suffix names the created objects: <vm>-<suffix>-<unix time>
+	suffix string
+	// event prefixes the reasons of the events recorded as the action progresses
+	event string
+	gvk   schema.GroupVersionKind
+	// pending returns the status field holding the name of the in-flight object
+	pending func(*llmcloudv1alpha1.VirtualMachineStatus) *string
+	build   func(vm *llmcloudv1alpha1.VirtualMachine, name string) *unstructured.Unstructured
+}
+
+var vmActions = []vmAction{
+	{
+		annotation: "llmcloud.io/snapshot",
+		suffix:     "snapshot",
+		event:      "Snapshot",
+		gvk:        vmSnapshotGVK,
+		pending:    func(s *llmcloudv1alpha1.VirtualMachineStatus) *string { return &s.PendingSnapshot },
+		build:      newVMSnapshot,
+	},
+	{
+		annotation: VMMigrateAnnotation,
+		suffix:     "migration",
+		event:      "Migration",
+		gvk:        vmMigrationGVK,
+		pending:    func(s *llmcloudv1alpha1.VirtualMachineStatus) *string { return &s.PendingMigration },
+		build:      newVMMigration,
+	},
+}
+
 func (r *VirtualMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := logf.FromContext(ctx)
 	log.Info("Reconciling VirtualMachine", "name", req.Name, "namespace", req.Namespace)
@@ -69,8 +164,7 @@ func (r *VirtualMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 			if err := r.finalizeVM(ctx, vm); err != nil {
 				return ctrl.Result{}, err
 			}
-			controllerutil.RemoveFinalizer(vm, vmFinalizer)
-			return ctrl.Result{}, r.Update(ctx, vm)
+			return ctrl.Result{}, removeFinalizer(ctx, r.Client, vm, vmFinalizer)
 		}
 		return ctrl.Result{}, nil
 	}
@@ -80,27 +174,42 @@ func (r *VirtualMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{Requeue: true}, r.Update(ctx, vm)
 	}
 
+	if err := inheritProjectMetadata(ctx, r.Client, vm); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// Handle reboot annotation
-	if vm.Annotations != nil && vm.Annotations["llmcloud.io/reboot"] == "true" {
-		if err := r.rebootVM(ctx, vm); err != nil {
+	rebootRequested := vm.Annotations[vmRebootAnnotation] == "true"
+	if rebootRequested {
+		if err := r.startReboot(ctx, vm); err != nil {
 			log.Error(err, "Failed to reboot VM")
 			return ctrl.Result{}, err
 		}
-		// Remove the annotation after handling
-		delete(vm.Annotations, "llmcloud.io/reboot")
-		if err := r.Update(ctx, vm); err != nil {
-			return ctrl.Result{}, err
-		}
-		log.Info("VM reboot initiated", "vm", vm.Name)
+	}
+
+	resizing, err := r.reconcileResize(ctx, vm)
+	if err != nil {
+		log.Error(err, "Failed to resize VM")
+		return ctrl.Result{}, err
 	}
 
 	log.Info("Reconciling KubeVirt VM", "vm", vm.Name)
 	if err := r.reconcileKubeVirtVM(ctx, vm); err != nil {
 		log.Error(err, "Failed to reconcile KubeVirt VM")
+		recordReconcileError(r.Recorder, vm, err)
 		r.updateVMStatus(ctx, vm, "Error", err.Error())
 		return ctrl.Result{}, err
 	}
 	log.Info("Successfully reconciled KubeVirt VM", "vm", vm.Name)
+	// The status is first set once the KubeVirt VM exists
+	if vm.Status.Phase == "" {
+		recordEvent(r.Recorder, vm, corev1.EventTypeNormal, "Created", "Created KubeVirt VirtualMachine %s", vm.Name)
+	}
+
+	if err := r.reconcileExposedPorts(ctx, vm); err != nil {
+		log.Error(err, "Failed to reconcile exposed ports")
+		return ctrl.Result{}, err
+	}
 
 	if err := r.updateVMStatusFromVMI(ctx, vm); err != nil {
 		// Ignore conflict errors - they will be retried on next reconcile
@@ -110,46 +219,376 @@ func (r *VirtualMachineReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 	}
 
-	return ctrl.Result{}, nil
+	if rebootRequested {
+		done, err := r.finishReboot(ctx, vm)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+		}
+	}
+	if resizing {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	actionPending := false
+	for _, action := range vmActions {
+		if vm.Annotations[action.annotation] != "true" {
+			continue
+		}
+		if err := r.reconcileAction(ctx, vm, action); err != nil {
+			log.Error(err, "Failed to handle VM action", "annotation", action.annotation)
+			return ctrl.Result{}, err
+		}
+		actionPending = true
+	}
+	if actionPending {
+		return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	}
+
+	nextBackup, err := r.reconcileBackups(ctx, vm)
+	if err != nil {
+		log.Error(err, "Failed to reconcile VM backups")
+		return ctrl.Result{}, err
+	}
+
+	nextIdleCheck, err := r.reconcileIdleSuspend(ctx, vm)
+	if err != nil {
+		log.Error(err, "Failed to check VM idleness")
+		return ctrl.Result{}, err
+	}
+	nextScheduledRun, err := r.reconcileSchedulePolicy(ctx, vm)
+	if err != nil {
+		log.Error(err, "Failed to apply VM schedule policy")
+		return ctrl.Result{}, err
+	}
+	var requeue time.Duration
+	for _, wait := range []time.Duration{nextBackup, nextIdleCheck, nextScheduledRun, r.ResyncInterval} {
+		if wait > 0 && (requeue == 0 || wait < requeue) {
+			requeue = wait
+		}
+	}
+
+	return ctrl.Result{RequeueAfter: requeue}, nil
 }
 
 func (r *VirtualMachineReconciler) reconcileKubeVirtVM(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
-	kvVM := r.buildKubeVirtVM(vm)
+	if err := r.recordDefaults(ctx, vm); err != nil {
+		return err
+	}
+	sshKeys, err := r.sshKeysForVM(ctx, vm)
+	if err != nil {
+		return err
+	}
+	files, err := r.secretFilesForVM(ctx, vm)
+	if err != nil {
+		return err
+	}
+	password, err := r.reconcileCredentialsSecret(ctx, vm)
+	if err != nil {
+		return err
+	}
+	if cloudInitInSecret(vm, files, password) {
+		userData := cloudInitUserData(vm, sshKeys, files, password)
+		if vm.Spec.CloudInitSecretRef != nil {
+			if userData, err = r.cloudInitFromSecret(ctx, vm); err != nil {
+				return err
+			}
+		}
+		if err := r.reconcileCloudInitSecret(ctx, vm, userData); err != nil {
+			return err
+		}
+	}
+	image, err := r.vmImage(ctx, vm)
+	if err != nil {
+		return err
+	}
+	kvVM := r.buildKubeVirtVM(vm, image, sshKeys, files, password)
 
 	// Use Server-Side Apply for idempotent create/update
 	// This will create if not exists, or update if exists
-	return r.Patch(ctx, kvVM, client.Apply, client.ForceOwnership, client.FieldOwner("llmcloud-operator"))
+	if err := r.Patch(ctx, kvVM, client.Apply, client.ForceOwnership, client.FieldOwner("llmcloud-operator")); err != nil {
+		return err
+	}
+	// dataVolumeTemplates only size new disks, so existing ones are resized directly
+	return r.expandDisks(ctx, vm)
+}
+
+// recordDefaults keeps the cluster defaults in effect when the VM is first reconciled in
+// its status, so that changing the LLMCloudConfig does not move the disks of existing
+// VMs to another storage class or their OS image to another registry
+func (r *VirtualMachineReconciler) recordDefaults(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	if vm.Status.Defaults != nil {
+		return nil
+	}
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(vm), latestVM); err != nil {
+		return err
+	}
+	if latestVM.Status.Defaults == nil {
+		latestVM.Status.Defaults = llmcloudv1alpha1.CurrentVMDefaults()
+		if err := r.Status().Update(ctx, latestVM); err != nil {
+			return err
+		}
+	}
+	vm.Status.Defaults = latestVM.Status.Defaults
+	return nil
+}
+
+// vmDefaults returns the cluster defaults of vm, or those in effect for a VM that has
+// none recorded yet
+func vmDefaults(vm *llmcloudv1alpha1.VirtualMachine) *llmcloudv1alpha1.VMDefaults {
+	if vm.Status.Defaults != nil {
+		return vm.Status.Defaults
+	}
+	return llmcloudv1alpha1.CurrentVMDefaults()
+}
+
+// sshKeysForVM returns the VM's inline SSH keys followed by the keys from the Secret
+// referenced by SSHKeysFrom and those of the SSHKeys in SSHKeyRefs. Blank lines and
+// comments in the Secret are skipped
+func (r *VirtualMachineReconciler) sshKeysForVM(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) ([]string, error) {
+	keys, err := r.sshKeysFromSecret(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	fromRefs, err := r.sshKeysFromRefs(ctx, vm)
+	if err != nil {
+		return nil, err
+	}
+	if len(fromRefs) == 0 {
+		return keys, nil
+	}
+	return append(append([]string{}, keys...), fromRefs...), nil
+}
+
+// sshKeysFromRefs returns the public keys of the SSHKeys the VM references. Each must be
+// usable in the VM's project: owned by it, or by a user with access to it
+func (r *VirtualMachineReconciler) sshKeysFromRefs(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) ([]string, error) {
+	if len(vm.Spec.SSHKeyRefs) == 0 {
+		return nil, nil
+	}
+	project := strings.TrimPrefix(vm.Namespace, "project-")
+
+	var users *llmcloudv1alpha1.UserList
+	keys := make([]string, 0, len(vm.Spec.SSHKeyRefs))
+	for _, name := range vm.Spec.SSHKeyRefs {
+		key := &llmcloudv1alpha1.SSHKey{}
+		if err := r.Get(ctx, client.ObjectKey{Name: name}, key); err != nil {
+			return nil, fmt.Errorf("failed to get SSH key %s: %w", name, err)
+		}
+
+		var owner *llmcloudv1alpha1.User
+		if key.Spec.User != "" {
+			if users == nil {
+				users = &llmcloudv1alpha1.UserList{}
+				if err := r.List(ctx, users); err != nil {
+					return nil, err
+				}
+			}
+			for i := range users.Items {
+				if users.Items[i].Spec.Username == key.Spec.User {
+					owner = &users.Items[i]
+				}
+			}
+		}
+		if !key.UsableIn(project, owner) {
+			return nil, fmt.Errorf("SSH key %s cannot be used in project %s", name, project)
+		}
+		keys = append(keys, key.Spec.PublicKey)
+	}
+	return keys, nil
 }
 
-func (r *VirtualMachineReconciler) buildKubeVirtVM(vm *llmcloudv1alpha1.VirtualMachine) *unstructured.Unstructured {
+// sshKeysFromSecret returns the VM's inline SSH keys followed by the keys from the
+// Secret referenced by SSHKeysFrom
+func (r *VirtualMachineReconciler) sshKeysFromSecret(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) ([]string, error) {
+	ref := vm.Spec.SSHKeysFrom
+	if ref == nil {
+		return vm.Spec.SSHKeys, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: vm.Namespace}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get SSH keys secret %s: %w", ref.Name, err)
+	}
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return nil, fmt.Errorf("SSH keys secret %s has no key %q", ref.Name, ref.Key)
+	}
+
+	var fromSecret []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fromSecret = append(fromSecret, line)
+	}
+	if err := llmcloudv1alpha1.ValidateSSHKeys(fromSecret); err != nil {
+		return nil, fmt.Errorf("SSH keys secret %s: %w", ref.Name, err)
+	}
+
+	return append(append([]string{}, vm.Spec.SSHKeys...), fromSecret...), nil
+}
+
+// cloudInitFile is a file written into the VM by cloud-init
+type cloudInitFile struct {
+	path        string
+	permissions string
+	content     []byte
+}
+
+// secretFilesForVM reads the Secret keys referenced by the VM's SecretFiles
+func (r *VirtualMachineReconciler) secretFilesForVM(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) ([]cloudInitFile, error) {
+	if err := llmcloudv1alpha1.ValidateSecretFiles(vm.Spec.SecretFiles, vm.Spec.CloudInit); err != nil {
+		return nil, err
+	}
+
+	var files []cloudInitFile
+	for _, file := range vm.Spec.SecretFiles {
+		ref := file.SecretRef
+		secret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: vm.Namespace}, secret); err != nil {
+			return nil, fmt.Errorf("failed to get secret %s for %s: %w", ref.Name, file.Path, err)
+		}
+		data, ok := secret.Data[ref.Key]
+		if !ok {
+			return nil, fmt.Errorf("secret %s has no key %q for %s", ref.Name, ref.Key, file.Path)
+		}
+		if len(data) > llmcloudv1alpha1.MaxSecretFileSize {
+			return nil, fmt.Errorf("secret %s key %q is %d bytes, more than the %d allowed for %s",
+				ref.Name, ref.Key, len(data), llmcloudv1alpha1.MaxSecretFileSize, file.Path)
+		}
+
+		permissions := file.Permissions
+		if permissions == "" {
+			permissions = "0600"
+		}
+		files = append(files, cloudInitFile{path: file.Path, permissions: permissions, content: data})
+	}
+	return files, nil
+}
+
+// cloudInitFromSecret reads the user data referenced by the VM's CloudInitSecretRef
+func (r *VirtualMachineReconciler) cloudInitFromSecret(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (string, error) {
+	ref := vm.Spec.CloudInitSecretRef
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKey{Name: ref.Name, Namespace: vm.Namespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get cloud-init secret %s: %w", ref.Name, err)
+	}
+	data, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("cloud-init secret %s has no key %q", ref.Name, ref.Key)
+	}
+	return string(data), nil
+}
+
+// cloudInitSecretName is the Secret holding the cloud-init user data of a VM whose user
+// data holds secrets
+func cloudInitSecretName(vm *llmcloudv1alpha1.VirtualMachine) string {
+	return vm.Name + "-cloudinit"
+}
+
+// cloudInitInSecret reports whether the VM's user data is passed through the Secret named
+// by cloudInitSecretName rather than inline: it comes from a Secret, or it holds secret
+// files or the default user's password
+func cloudInitInSecret(vm *llmcloudv1alpha1.VirtualMachine, files []cloudInitFile, password string) bool {
+	return vm.Spec.CloudInitSecretRef != nil || len(files) > 0 || password != ""
+}
+
+// reconcileCloudInitSecret stores the VM's user data in a Secret owned by the VM, so
+// secrets do not appear in the KubeVirt VirtualMachine spec
+func (r *VirtualMachineReconciler) reconcileCloudInitSecret(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, userData string) error {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: cloudInitSecretName(vm), Namespace: vm.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		secret.Labels = map[string]string{"llmcloud.io/managed": "true"}
+		secret.Data = map[string][]byte{"userdata": []byte(userData)}
+		return controllerutil.SetControllerReference(vm, secret, r.Scheme)
+	})
+	return err
+}
+
+// cloudInitUserData returns the VM's custom cloud-init, or a generated cloud-config
+// setting the default user's password and injecting the SSH keys and files. It is
+// empty when there is nothing to inject
+func cloudInitUserData(vm *llmcloudv1alpha1.VirtualMachine, sshKeys []string, files []cloudInitFile, password string) string {
+	if vm.Spec.CloudInit != "" || (len(sshKeys) == 0 && len(files) == 0 && password == "") {
+		return vm.Spec.CloudInit
+	}
+
+	// Quote every value so a stray comment or newline cannot alter the YAML structure
+	var b strings.Builder
+	b.WriteString("#cloud-config")
+	if password != "" {
+		b.WriteString("\npassword: " + strconv.Quote(password))
+		b.WriteString("\nchpasswd:\n  expire: false")
+	}
+	if len(sshKeys) > 0 {
+		b.WriteString("\nssh_authorized_keys:")
+		for _, key := range sshKeys {
+			b.WriteString("\n  - " + strconv.Quote(key))
+		}
+	}
+	if len(files) > 0 {
+		b.WriteString("\nwrite_files:")
+		for _, file := range files {
+			b.WriteString("\n  - path: " + strconv.Quote(file.path))
+			b.WriteString("\n    encoding: b64")
+			b.WriteString("\n    content: " + base64.StdEncoding.EncodeToString(file.content))
+			b.WriteString("\n    permissions: " + strconv.Quote(file.permissions))
+		}
+	}
+	return b.String()
+}
+
+// PreviewKubeVirtVM returns the KubeVirt VirtualMachine that would be created for vm
+// under the given image policy. SSH keys and files referenced from Secrets or SSHKeys,
+// and the VMImage referenced by ImageRef, are not resolved
+func PreviewKubeVirtVM(vm *llmcloudv1alpha1.VirtualMachine, images ImagePolicy) *unstructured.Unstructured {
+	r := &VirtualMachineReconciler{Images: images}
+	return r.buildKubeVirtVM(vm, nil, vm.Spec.SSHKeys, nil, "")
+}
+
+// vmDevices converts devices to KubeVirt gpus or hostDevices entries
+func vmDevices(devices []llmcloudv1alpha1.VMDevice) []interface{} {
+	out := make([]interface{}, 0, len(devices))
+	for _, device := range devices {
+		out = append(out, map[string]interface{}{
+			"name":       device.Name,
+			"deviceName": device.DeviceName,
+		})
+	}
+	return out
+}
+
+// buildKubeVirtVM returns the KubeVirt VirtualMachine for vm, booting image when the VM
+// references one and the built-in image of its OS when image is nil
+func (r *VirtualMachineReconciler) buildKubeVirtVM(vm *llmcloudv1alpha1.VirtualMachine, image *llmcloudv1alpha1.VMImage, sshKeys []string, files []cloudInitFile, password string) *unstructured.Unstructured {
 	runStrategy := vm.Spec.RunStrategy
 	if runStrategy == "" {
 		runStrategy = "Always"
 	}
-
-	cloudInitUserData := vm.Spec.CloudInit
-	if cloudInitUserData == "" && len(vm.Spec.SSHKeys) > 0 {
-		cloudInitUserData = fmt.Sprintf("#cloud-config\nssh_authorized_keys:\n%s",
-			strings.Join(vm.Spec.SSHKeys, "\n"))
+	if resizeStopping(vm) {
+		runStrategy = "Halted"
 	}
 
+	userData := cloudInitUserData(vm, sshKeys, files, password)
+
 	// Build disks and volumes based on configuration
+	bootVolume := r.bootVolume(vm, image)
 	disks := []interface{}{
 		map[string]interface{}{
-			"name": "containerdisk",
+			"name": bootVolume["name"],
 			"disk": map[string]interface{}{
 				"bus": "virtio",
 			},
 		},
 	}
-	volumes := []interface{}{
-		map[string]interface{}{
-			"name": "containerdisk",
-			"containerDisk": map[string]interface{}{
-				"image": llmcloudv1alpha1.GetImageForOS(vm.Spec.OS, vm.Spec.OSVersion),
-			},
-		},
-	}
+	volumes := []interface{}{bootVolume}
 
 	// Only add persistent disk if disk size is specified and > 0
 	// For demo/test images like cirros, we skip the persistent disk
@@ -168,19 +607,42 @@ func (r *VirtualMachineReconciler) buildKubeVirtVM(vm *llmcloudv1alpha1.VirtualM
 		})
 	}
 
+	// Additional disks are hotpluggable, so KubeVirt attaches new ones to the running
+	// instance instead of waiting for a restart. This requires the
+	// DeclarativeHotplugVolumes feature gate; without it they are attached on the next start
+	for _, disk := range vm.Spec.AdditionalDisks {
+		disks = append(disks, map[string]interface{}{
+			"name": disk.Name,
+			"disk": map[string]interface{}{
+				"bus": "scsi",
+			},
+		})
+		volumes = append(volumes, map[string]interface{}{
+			"name": disk.Name,
+			"dataVolume": map[string]interface{}{
+				"name":         additionalDiskVolumeName(vm, disk),
+				"hotpluggable": true,
+			},
+		})
+	}
+
 	// Only add cloudInit if we have data
-	if cloudInitUserData != "" {
+	if userData != "" || vm.Spec.CloudInitSecretRef != nil {
 		disks = append(disks, map[string]interface{}{
 			"name": "cloudinitdisk",
 			"disk": map[string]interface{}{
 				"bus": "virtio",
 			},
 		})
+		cloudInit := map[string]interface{}{"userData": userData}
+		if cloudInitInSecret(vm, files, password) {
+			cloudInit = map[string]interface{}{
+				"secretRef": map[string]interface{}{"name": cloudInitSecretName(vm)},
+			}
+		}
 		volumes = append(volumes, map[string]interface{}{
-			"name": "cloudinitdisk",
-			"cloudInitNoCloud": map[string]interface{}{
-				"userData": cloudInitUserData,
-			},
+			"name":             "cloudinitdisk",
+			"cloudInitNoCloud": cloudInit,
 		})
 	}
 
@@ -190,58 +652,80 @@ func (r *VirtualMachineReconciler) buildKubeVirtVM(vm *llmcloudv1alpha1.VirtualM
 		diskSize = "10Gi"
 	}
 
-	// Get storage class (local storage)
-	storageClass := vm.Spec.StorageClass
-	if storageClass == "" {
-		storageClass = "local-path"
+	storageClass := cmp.Or(vm.Spec.StorageClass, vmDefaults(vm).StorageClass)
+
+	domain := map[string]interface{}{
+		"cpu": map[string]interface{}{
+			"cores": vm.Spec.CPUs,
+		},
+		"resources": map[string]interface{}{
+			"requests": map[string]interface{}{
+				"memory": vm.Spec.Memory,
+			},
+		},
+		"devices": map[string]interface{}{
+			"disks": disks,
+		},
+	}
+	if vm.Spec.GuestMemory != "" {
+		domain["memory"] = map[string]interface{}{"guest": vm.Spec.GuestMemory}
+	}
+	devices := domain["devices"].(map[string]interface{})
+	if len(vm.Spec.GPUs) > 0 {
+		devices["gpus"] = vmDevices(vm.Spec.GPUs)
+	}
+	if len(vm.Spec.HostDevices) > 0 {
+		devices["hostDevices"] = vmDevices(vm.Spec.HostDevices)
+	}
+
+	templateSpec := map[string]interface{}{
+		"domain":  domain,
+		"volumes": volumes,
+	}
+	if len(vm.Spec.NodeSelector) > 0 {
+		nodeSelector := map[string]interface{}{}
+		for k, v := range vm.Spec.NodeSelector {
+			nodeSelector[k] = v
+		}
+		templateSpec["nodeSelector"] = nodeSelector
+	}
+	if len(vm.Spec.Tolerations) > 0 {
+		tolerations := make([]interface{}, 0, len(vm.Spec.Tolerations))
+		for i := range vm.Spec.Tolerations {
+			toleration, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&vm.Spec.Tolerations[i])
+			if err != nil {
+				continue
+			}
+			tolerations = append(tolerations, toleration)
+		}
+		templateSpec["tolerations"] = tolerations
 	}
 
 	// Build the VM spec
 	vmSpec := map[string]interface{}{
 		"runStrategy": runStrategy,
 		"template": map[string]interface{}{
-			"spec": map[string]interface{}{
-				"domain": map[string]interface{}{
-					"cpu": map[string]interface{}{
-						"cores": vm.Spec.CPUs,
-					},
-					"resources": map[string]interface{}{
-						"requests": map[string]interface{}{
-							"memory": vm.Spec.Memory,
-						},
-					},
-					"devices": map[string]interface{}{
-						"disks": disks,
-					},
-				},
-				"volumes": volumes,
-			},
+			"spec": templateSpec,
 		},
 	}
 
 	// Only add dataVolumeTemplates if we have a persistent disk
+	var dataVolumeTemplates []interface{}
+	if uploadedImage(image) {
+		dataVolumeTemplates = append(dataVolumeTemplates, cloneDataVolumeTemplate(rootDiskName(vm), image, storageClass))
+	}
 	if vm.Spec.DiskSize != "" && vm.Spec.DiskSize != "0" && vm.Spec.DiskSize != "0Gi" {
-		vmSpec["dataVolumeTemplates"] = []interface{}{
-			map[string]interface{}{
-				"metadata": map[string]interface{}{
-					"name": vm.Name + "-disk",
-				},
-				"spec": map[string]interface{}{
-					"source": map[string]interface{}{
-						"blank": map[string]interface{}{},
-					},
-					"storage": map[string]interface{}{
-						"accessModes": []interface{}{"ReadWriteOnce"},
-						"resources": map[string]interface{}{
-							"requests": map[string]interface{}{
-								"storage": diskSize,
-							},
-						},
-						"storageClassName": storageClass,
-					},
-				},
-			},
+		dataVolumeTemplates = append(dataVolumeTemplates, blankDataVolumeTemplate(vm.Name+"-disk", diskSize, storageClass))
+	}
+	for _, disk := range vm.Spec.AdditionalDisks {
+		diskClass := disk.StorageClass
+		if diskClass == "" {
+			diskClass = storageClass
 		}
+		dataVolumeTemplates = append(dataVolumeTemplates, blankDataVolumeTemplate(additionalDiskVolumeName(vm, disk), disk.Size, diskClass))
+	}
+	if len(dataVolumeTemplates) > 0 {
+		vmSpec["dataVolumeTemplates"] = dataVolumeTemplates
 	}
 
 	kvVM := &unstructured.Unstructured{
@@ -262,6 +746,166 @@ func (r *VirtualMachineReconciler) buildKubeVirtVM(vm *llmcloudv1alpha1.VirtualM
 	return kvVM
 }
 
+// bootVolume returns the volume the VM boots from: a clone of the volume of an uploaded
+// image, or the container disk of a registered image or of the VM's OS
+func (r *VirtualMachineReconciler) bootVolume(vm *llmcloudv1alpha1.VirtualMachine, image *llmcloudv1alpha1.VMImage) map[string]interface{} {
+	if uploadedImage(image) {
+		return map[string]interface{}{
+			"name": "rootdisk",
+			"dataVolume": map[string]interface{}{
+				"name": rootDiskName(vm),
+			},
+		}
+	}
+
+	source := vmDefaults(vm).ImageForOS(vm.Spec.OS, vm.Spec.OSVersion)
+	if image != nil && image.Spec.ContainerDisk != "" {
+		source = image.Spec.ContainerDisk
+	}
+	containerDisk := map[string]interface{}{
+		"image": r.Images.Resolve(source),
+	}
+	if r.Images.PullPolicy != "" {
+		containerDisk["imagePullPolicy"] = r.Images.PullPolicy
+	}
+	return map[string]interface{}{
+		"name":          "containerdisk",
+		"containerDisk": containerDisk,
+	}
+}
+
+// uploadedImage reports whether image is an uploaded image VMs can clone
+func uploadedImage(image *llmcloudv1alpha1.VMImage) bool {
+	return image != nil && image.Spec.Upload != nil && image.Status.Volume != nil
+}
+
+// rootDiskName is the name of the DataVolume, and PVC, holding the clone of the
+// uploaded image vm boots from
+func rootDiskName(vm *llmcloudv1alpha1.VirtualMachine) string {
+	return vm.Name + "-root"
+}
+
+// cloneDataVolumeTemplate returns a dataVolumeTemplate cloning the volume of an uploaded
+// image. Cloning across namespaces requires the operator to be allowed to create
+// datavolumes/source in the image's namespace
+func cloneDataVolumeTemplate(name string, image *llmcloudv1alpha1.VMImage, storageClass string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"pvc": map[string]interface{}{
+					"namespace": image.Status.Volume.Namespace,
+					"name":      image.Status.Volume.Name,
+				},
+			},
+			"storage": map[string]interface{}{
+				"accessModes": []interface{}{"ReadWriteOnce"},
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{
+						"storage": image.Spec.Upload.Size,
+					},
+				},
+				"storageClassName": storageClass,
+			},
+		},
+	}
+}
+
+// vmImage returns the VMImage the VM references, or nil when it boots the image of its
+// OS. A missing image, or one still waiting for its upload, fails the reconcile, which
+// is retried when the image changes
+func (r *VirtualMachineReconciler) vmImage(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (*llmcloudv1alpha1.VMImage, error) {
+	if vm.Spec.ImageRef == "" {
+		return nil, nil
+	}
+	image := &llmcloudv1alpha1.VMImage{}
+	if err := r.Get(ctx, client.ObjectKey{Name: vm.Spec.ImageRef}, image); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, fmt.Errorf("VM image %q not found", vm.Spec.ImageRef)
+		}
+		return nil, err
+	}
+	if !image.Ready() {
+		return nil, fmt.Errorf("VM image %q is not ready", vm.Spec.ImageRef)
+	}
+	return image, nil
+}
+
+// blankDataVolumeTemplate returns a dataVolumeTemplate for an empty disk of size
+func blankDataVolumeTemplate(name, size, storageClass string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name": name,
+		},
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"blank": map[string]interface{}{},
+			},
+			"storage": map[string]interface{}{
+				"accessModes": []interface{}{"ReadWriteOnce"},
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{
+						"storage": size,
+					},
+				},
+				"storageClassName": storageClass,
+			},
+		},
+	}
+}
+
+// additionalDiskVolumeName is the name of the DataVolume, and PVC, backing an
+// additional disk of vm
+func additionalDiskVolumeName(vm *llmcloudv1alpha1.VirtualMachine, disk llmcloudv1alpha1.VMDisk) string {
+	return vm.Name + "-" + disk.Name
+}
+
+// expandDisks grows the PVCs of the VM's disks whose requested size is below the size
+// in the spec. The PVCs are expanded in place, which needs a storage class that allows
+// volume expansion; KubeVirt makes the new size visible to the guest. PVCs that do not
+// exist yet are left to CDI, which creates them at the current size
+func (r *VirtualMachineReconciler) expandDisks(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	sizes := map[string]string{}
+	if vm.Spec.DiskSize != "" && vm.Spec.DiskSize != "0" && vm.Spec.DiskSize != "0Gi" {
+		sizes[vm.Name+"-disk"] = vm.Spec.DiskSize
+	}
+	for _, disk := range vm.Spec.AdditionalDisks {
+		sizes[additionalDiskVolumeName(vm, disk)] = disk.Size
+	}
+
+	for name, size := range sizes {
+		want, err := resource.ParseQuantity(size)
+		if err != nil {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: vm.Namespace, Name: name}, pvc); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		current := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if want.Cmp(current) <= 0 {
+			continue
+		}
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = corev1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = want
+		if err := r.Update(ctx, pvc); err != nil {
+			return fmt.Errorf("failed to expand disk %s: %w", name, err)
+		}
+		logf.FromContext(ctx).Info("Expanding VM disk", "pvc", name, "from", current.String(), "to", size)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(vm, corev1.EventTypeNormal, "DiskExpanding", "Expanding disk %s from %s to %s", name, current.String(), size)
+		}
+	}
+	return nil
+}
+
 func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
 	log := logf.FromContext(ctx)
 
@@ -284,8 +928,9 @@ func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm
 				return err
 			}
 
-			latestVM.Status.Phase = llmcloudv1alpha1.PhasePending
+			latestVM.Status.Phase = vmPhase(latestVM, llmcloudv1alpha1.PhasePending)
 			latestVM.Status.Ready = false
+			latestVM.Status.LauncherPod = ""
 			log.Info("Updating VM status (VMI not found)", "vm", vm.Name, "phase", latestVM.Status.Phase)
 			err := r.Status().Update(ctx, latestVM)
 			if err != nil {
@@ -316,7 +961,7 @@ func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm
 
 	if !found {
 		log.Info("VMI status not found yet, will retry", "vm", vm.Name)
-		latestVM.Status.Phase = llmcloudv1alpha1.PhasePending
+		latestVM.Status.Phase = vmPhase(latestVM, llmcloudv1alpha1.PhasePending)
 		latestVM.Status.Ready = false
 		return r.Status().Update(ctx, latestVM)
 	}
@@ -324,11 +969,11 @@ func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm
 	phase, ok := status["phase"].(string)
 	if !ok {
 		log.Info("VMI phase not available yet, setting to Pending", "vm", vm.Name, "status", status)
-		latestVM.Status.Phase = llmcloudv1alpha1.PhasePending
+		latestVM.Status.Phase = vmPhase(latestVM, llmcloudv1alpha1.PhasePending)
 		latestVM.Status.Ready = false
 	} else {
 		log.Info("Setting VM phase from VMI", "vm", vm.Name, "phase", phase)
-		latestVM.Status.Phase = phase
+		latestVM.Status.Phase = vmPhase(latestVM, phase)
 		latestVM.Status.Ready = (phase == "Running")
 	}
 
@@ -336,13 +981,22 @@ func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm
 		latestVM.Status.Node = node
 	}
 
-	if interfaces, ok := status["interfaces"].([]interface{}); ok && len(interfaces) > 0 {
-		if iface, ok := interfaces[0].(map[string]interface{}); ok {
-			if ip, ok := iface["ipAddress"].(string); ok {
-				latestVM.Status.IPAddress = ip
-			}
-		}
+	latestVM.Status.Interfaces = interfacesFromVMI(status)
+	if len(latestVM.Status.Interfaces) > 0 && len(latestVM.Status.Interfaces[0].IPAddresses) > 0 {
+		latestVM.Status.IPAddress = latestVM.Status.Interfaces[0].IPAddresses[0]
+	}
+
+	if r.CapturePhaseTransitions {
+		latestVM.Status.PhaseTransitions = phaseTransitionsFromVMI(status)
+	}
+	launcherPod, err := r.launcherPodForVMI(ctx, vmi)
+	if err != nil {
+		log.Error(err, "Failed to look up launcher pod", "vm", vm.Name)
+	} else {
+		latestVM.Status.LauncherPod = launcherPod
 	}
+	r.applyCloudInitStatus(ctx, latestVM, status)
+	r.applyGuestInfo(ctx, latestVM, status)
 
 	meta.SetStatusCondition(&latestVM.Status.Conditions, metav1.Condition{
 		Type:               "Ready",
@@ -362,12 +1016,73 @@ func (r *VirtualMachineReconciler) updateVMStatusFromVMI(ctx context.Context, vm
 	return nil
 }
 
+// launcherPodForVMI returns the name of the virt-launcher pod owned by the VMI. While a
+// migration is in flight there are two, so a running pod is preferred over one that is
+// still starting or already finished
+func (r *VirtualMachineReconciler) launcherPodForVMI(ctx context.Context, vmi *unstructured.Unstructured) (string, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(vmi.GetNamespace()),
+		client.MatchingLabels{"vm.kubevirt.io/name": vmi.GetName()}); err != nil {
+		return "", err
+	}
+
+	name := ""
+	for _, pod := range pods.Items {
+		if !metav1.IsControlledBy(&pod, vmi) {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodRunning {
+			return pod.Name, nil
+		}
+		if name == "" {
+			name = pod.Name
+		}
+	}
+	return name, nil
+}
+
+// phaseTransitionsFromVMI extracts status.phaseTransitionTimestamps from a VMI status,
+// skipping entries that are missing a phase or have an unparsable timestamp
+func phaseTransitionsFromVMI(status map[string]interface{}) []llmcloudv1alpha1.PhaseTransition {
+	entries, ok := status["phaseTransitionTimestamps"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var transitions []llmcloudv1alpha1.PhaseTransition
+	for _, entry := range entries {
+		e, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		phase, _ := e["phase"].(string)
+		timestamp, _ := e["phaseTransitionTimestamp"].(string)
+		if phase == "" || timestamp == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, timestamp)
+		if err != nil {
+			continue
+		}
+		transitions = append(transitions, llmcloudv1alpha1.PhaseTransition{
+			Phase:          phase,
+			TransitionTime: metav1.NewTime(t),
+		})
+	}
+	return transitions
+}
+
 func (r *VirtualMachineReconciler) finalizeVM(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
 	kvVM := &unstructured.Unstructured{}
 	kvVM.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"})
 	kvVM.SetName(vm.Name)
 	kvVM.SetNamespace(vm.Namespace)
-	return client.IgnoreNotFound(r.Delete(ctx, kvVM))
+	err := r.Delete(ctx, kvVM)
+	if errors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		// Already gone, or KubeVirt itself has been uninstalled
+		return nil
+	}
+	return err
 }
 
 func (r *VirtualMachineReconciler) updateVMStatus(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, phase, message string) {
@@ -423,6 +1138,358 @@ func (r *VirtualMachineReconciler) rebootVM(ctx context.Context, vm *llmcloudv1a
 	return nil
 }
 
+// reconcileBackups takes a VirtualMachineSnapshot when the VM's backup schedule is due
+// and prunes scheduled snapshots beyond the retention count. It returns the time until
+// the next scheduled backup, or zero when backups are disabled
+func (r *VirtualMachineReconciler) reconcileBackups(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (time.Duration, error) {
+	log := logf.FromContext(ctx)
+
+	if vm.Spec.BackupSchedule == "" {
+		return 0, nil
+	}
+
+	last := vm.CreationTimestamp.Time
+	if vm.Status.LastBackupTime != nil {
+		last = vm.Status.LastBackupTime.Time
+	}
+
+	now := time.Now()
+	due, wait, err := backupTiming(vm.Spec.BackupSchedule, last, now)
+	if err != nil {
+		// An invalid schedule will not fix itself by retrying
+		log.Error(err, "Skipping VM backups", "vm", vm.Name)
+		return 0, nil
+	}
+	if !due {
+		return wait, nil
+	}
+
+	snapshot := newVMSnapshot(vm, fmt.Sprintf("%s-backup-%d", vm.Name, now.Unix()))
+	snapshot.SetLabels(map[string]string{vmBackupLabel: vm.Name})
+	if err := r.Create(ctx, snapshot); err != nil && !errors.IsAlreadyExists(err) {
+		return 0, fmt.Errorf("failed to create VM snapshot: %w", err)
+	}
+	log.Info("VM backup snapshot created", "vm", vm.Name, "snapshot", snapshot.GetName())
+
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace}, latestVM); err != nil {
+		return 0, err
+	}
+	backupTime := metav1.NewTime(now)
+	latestVM.Status.LastBackupTime = &backupTime
+	if err := r.Status().Update(ctx, latestVM); err != nil {
+		return 0, err
+	}
+
+	if err := r.pruneBackups(ctx, vm); err != nil {
+		return 0, err
+	}
+
+	return wait, nil
+}
+
+// newVMSnapshot builds a VirtualMachineSnapshot of the VM's KubeVirt VM
+func newVMSnapshot(vm *llmcloudv1alpha1.VirtualMachine, name string) *unstructured.Unstructured {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(vmSnapshotGVK)
+	snapshot.SetName(name)
+	snapshot.SetNamespace(vm.Namespace)
+	snapshot.Object["spec"] = map[string]interface{}{
+		"source": map[string]interface{}{
+			"apiGroup": "kubevirt.io",
+			"kind":     "VirtualMachine",
+			"name":     vm.Name,
+		},
+	}
+	return snapshot
+}
+
+// newVMMigration builds a VirtualMachineInstanceMigration that live-migrates the VM's instance
+func newVMMigration(vm *llmcloudv1alpha1.VirtualMachine, name string) *unstructured.Unstructured {
+	migration := &unstructured.Unstructured{}
+	migration.SetGroupVersionKind(vmMigrationGVK)
+	migration.SetName(name)
+	migration.SetNamespace(vm.Namespace)
+	migration.Object["spec"] = map[string]interface{}{
+		"vmiName": vm.Name,
+	}
+	return migration
+}
+
+// pruneBackups deletes the oldest scheduled snapshots of a VM beyond its retention count
+func (r *VirtualMachineReconciler) pruneBackups(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	snapshots := &unstructured.UnstructuredList{}
+	snapshots.SetGroupVersionKind(vmSnapshotGVK.GroupVersion().WithKind("VirtualMachineSnapshotList"))
+	if err := r.List(ctx, snapshots, client.InNamespace(vm.Namespace), client.MatchingLabels{vmBackupLabel: vm.Name}); err != nil {
+		return fmt.Errorf("failed to list VM snapshots: %w", err)
+	}
+
+	for _, snapshot := range snapshotsToPrune(snapshots.Items, int(vm.Spec.BackupRetention)) {
+		if err := r.Delete(ctx, &snapshot); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete VM snapshot %s: %w", snapshot.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// backupTiming reports whether a backup is due at now given the time of the last
+// backup, and how long after now the following backup is scheduled
+func backupTiming(schedule string, last, now time.Time) (bool, time.Duration, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return false, 0, fmt.Errorf("invalid backup schedule %q: %w", schedule, err)
+	}
+	if next := sched.Next(last); next.After(now) {
+		return false, next.Sub(now), nil
+	}
+	return true, sched.Next(now).Sub(now), nil
+}
+
+// snapshotsToPrune returns the snapshots beyond the newest retention entries,
+// ordered by creation time. A retention of zero uses the default
+func snapshotsToPrune(snapshots []unstructured.Unstructured, retention int) []unstructured.Unstructured {
+	if retention <= 0 {
+		retention = defaultBackupRetention
+	}
+	if len(snapshots) <= retention {
+		return nil
+	}
+
+	sorted := make([]unstructured.Unstructured, len(snapshots))
+	copy(sorted, snapshots)
+	sort.Slice(sorted, func(i, j int) bool {
+		ti, tj := sorted[i].GetCreationTimestamp(), sorted[j].GetCreationTimestamp()
+		if !ti.Equal(&tj) {
+			return tj.Before(&ti)
+		}
+		return sorted[i].GetName() > sorted[j].GetName()
+	})
+	return sorted[retention:]
+}
+
+// startReboot reboots the VM and records the reboot in the VM status. A reboot that is
+// already in progress is only issued again once vmRebootTimeout has passed
+func (r *VirtualMachineReconciler) startReboot(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	log := logf.FromContext(ctx)
+
+	if vm.Status.RebootPhase == llmcloudv1alpha1.RebootPhaseRebooting && vm.Status.RebootStartTime != nil &&
+		time.Since(vm.Status.RebootStartTime.Time) < vmRebootTimeout {
+		return nil
+	}
+
+	if err := r.rebootVM(ctx, vm); err != nil {
+		recordEvent(r.Recorder, vm, corev1.EventTypeWarning, "RebootFailed", "Failed to reboot: %v", err)
+		return err
+	}
+	recordEvent(r.Recorder, vm, corev1.EventTypeNormal, "Rebooting", "Restarting the VM instance")
+
+	now := metav1.Now()
+	vm.Status.RebootPhase = llmcloudv1alpha1.RebootPhaseRebooting
+	vm.Status.RebootStartTime = &now
+	if err := r.Status().Update(ctx, vm); err != nil {
+		return err
+	}
+	log.Info("VM reboot initiated", "vm", vm.Name)
+	return nil
+}
+
+// finishReboot reports whether the VM instance has come back Running since the reboot
+// was issued, and if so removes the reboot annotation and clears the reboot status
+func (r *VirtualMachineReconciler) finishReboot(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) (bool, error) {
+	log := logf.FromContext(ctx)
+
+	vmi := &unstructured.Unstructured{}
+	vmi.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "kubevirt.io",
+		Version: "v1",
+		Kind:    "VirtualMachineInstance",
+	})
+	if err := r.Get(ctx, client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace}, vmi); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+
+	phase, _, _ := unstructured.NestedString(vmi.Object, "status", "phase")
+	if phase != llmcloudv1alpha1.PhaseRunning {
+		return false, nil
+	}
+	// A Running instance created before the reboot is the one being shut down
+	if start := vm.Status.RebootStartTime; start != nil {
+		created := vmi.GetCreationTimestamp()
+		if created.Time.Before(start.Truncate(time.Second)) {
+			return false, nil
+		}
+	}
+
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace}, latestVM); err != nil {
+		return false, err
+	}
+	delete(latestVM.Annotations, vmRebootAnnotation)
+	if err := r.Update(ctx, latestVM); err != nil {
+		return false, err
+	}
+	latestVM.Status.RebootPhase = ""
+	latestVM.Status.RebootStartTime = nil
+	if err := r.Status().Update(ctx, latestVM); err != nil {
+		return false, err
+	}
+	log.Info("VM reboot completed", "vm", vm.Name)
+	recordEvent(r.Recorder, vm, corev1.EventTypeNormal, "Rebooted", "The VM instance is running again")
+	return true, nil
+}
+
+// reconcileAction advances an annotation-requested VM action by one step: it creates the
+// action's object, then once that object has Succeeded or Failed removes the annotation.
+// An object that disappears before finishing is created again
+func (r *VirtualMachineReconciler) reconcileAction(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine, action vmAction) error {
+	log := logf.FromContext(ctx)
+
+	latestVM := &llmcloudv1alpha1.VirtualMachine{}
+	if err := r.Get(ctx, client.ObjectKey{Name: vm.Name, Namespace: vm.Namespace}, latestVM); err != nil {
+		return err
+	}
+	pending := action.pending(&latestVM.Status)
+
+	if *pending == "" {
+		obj := action.build(latestVM, fmt.Sprintf("%s-%s-%d", vm.Name, action.suffix, time.Now().Unix()))
+		if err := r.Create(ctx, obj); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("failed to create %s: %w", action.gvk.Kind, err)
+		}
+		*pending = obj.GetName()
+		log.Info("VM action started", "vm", vm.Name, "annotation", action.annotation, "object", obj.GetName())
+		recordEvent(r.Recorder, vm, corev1.EventTypeNormal, action.event+"Started", "Created %s %s", action.gvk.Kind, obj.GetName())
+		return r.Status().Update(ctx, latestVM)
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(action.gvk)
+	if err := r.Get(ctx, client.ObjectKey{Name: *pending, Namespace: vm.Namespace}, obj); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		log.Info("VM action object disappeared, retrying", "vm", vm.Name, "object", *pending)
+		*pending = ""
+		return r.Status().Update(ctx, latestVM)
+	}
+
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	if phase != "Succeeded" && phase != "Failed" {
+		return nil
+	}
+	if phase == "Failed" {
+		log.Info("VM action failed", "vm", vm.Name, "annotation", action.annotation, "object", obj.GetName())
+		recordEvent(r.Recorder, vm, corev1.EventTypeWarning, action.event+"Failed", "%s %s failed", action.gvk.Kind, obj.GetName())
+	} else {
+		log.Info("VM action completed", "vm", vm.Name, "annotation", action.annotation, "object", obj.GetName())
+		recordEvent(r.Recorder, vm, corev1.EventTypeNormal, action.event+"Succeeded", "%s %s succeeded", action.gvk.Kind, obj.GetName())
+	}
+
+	delete(latestVM.Annotations, action.annotation)
+	if err := r.Update(ctx, latestVM); err != nil {
+		return err
+	}
+	*action.pending(&latestVM.Status) = ""
+	return r.Status().Update(ctx, latestVM)
+}
+
+// vmsForSecret maps a Secret to the VMs that read SSH keys or secret files from it, so
+// that rotated keys and files are picked up
+func (r *VirtualMachineReconciler) vmsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := r.List(ctx, &vms, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, vm := range vms.Items {
+		uses := vm.Spec.SSHKeysFrom != nil && vm.Spec.SSHKeysFrom.Name == obj.GetName() ||
+			vm.Spec.CloudInitSecretRef != nil && vm.Spec.CloudInitSecretRef.Name == obj.GetName()
+		for _, file := range vm.Spec.SecretFiles {
+			uses = uses || file.SecretRef.Name == obj.GetName()
+		}
+		if uses {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vm)})
+		}
+	}
+	return requests
+}
+
+// vmsForSSHKey maps an SSHKey to the VMs referencing it, so that changed or rotated keys
+// are injected
+func (r *VirtualMachineReconciler) vmsForSSHKey(ctx context.Context, obj client.Object) []reconcile.Request {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := r.List(ctx, &vms); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, vm := range vms.Items {
+		if slices.Contains(vm.Spec.SSHKeyRefs, obj.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vm)})
+		}
+	}
+	return requests
+}
+
+// vmsForImage maps a VMImage to the VMs booting it, so that VMs waiting for an upload
+// are started once it completes
+func (r *VirtualMachineReconciler) vmsForImage(ctx context.Context, obj client.Object) []reconcile.Request {
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := r.List(ctx, &vms); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, vm := range vms.Items {
+		if vm.Spec.ImageRef == obj.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&vm)})
+		}
+	}
+	return requests
+}
+
+// vmForKubeVirtObject maps a KubeVirt VM the controller manages, or the VMI run for
+// it, to the VirtualMachine of the same name, so that edits to them and their status
+// changes are reconciled
+func vmForKubeVirtObject(_ context.Context, obj client.Object) []reconcile.Request {
+	managed := obj.GetLabels()["llmcloud.io/managed"] == "true"
+	for _, owner := range obj.GetOwnerReferences() {
+		managed = managed || owner.Kind == "VirtualMachine" && owner.Name == obj.GetName() &&
+			strings.HasPrefix(owner.APIVersion, "kubevirt.io/")
+	}
+	if !managed {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(obj)}}
+}
+
 func (r *VirtualMachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).For(&llmcloudv1alpha1.VirtualMachine{}).Named("virtualmachine").Complete(r)
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&llmcloudv1alpha1.VirtualMachine{}).
+		Owns(&corev1.Service{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.vmsForSecret)).
+		Watches(&llmcloudv1alpha1.SSHKey{}, handler.EnqueueRequestsFromMapFunc(r.vmsForSSHKey)).
+		Watches(&llmcloudv1alpha1.VMImage{}, handler.EnqueueRequestsFromMapFunc(r.vmsForImage)).
+		Watches(&llmcloudv1alpha1.Project{}, handler.EnqueueRequestsFromMapFunc(
+			workloadsForProject(mgr.GetClient(), func() client.ObjectList { return &llmcloudv1alpha1.VirtualMachineList{} })))
+
+	// Without KubeVirt installed there is nothing to watch, and VMs are left to fail
+	// reconciling rather than keeping the manager from starting
+	for _, kind := range []string{"VirtualMachine", "VirtualMachineInstance"} {
+		gvk := schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: kind}
+		if _, err := mgr.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				mgr.GetLogger().Info("KubeVirt is not installed, not watching for drift", "kind", kind)
+				continue
+			}
+			return err
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		b = b.Watches(obj, handler.EnqueueRequestsFromMapFunc(vmForKubeVirtObject))
+	}
+
+	return b.Named("virtualmachine").
+		Complete(metrics.Reconciler("VirtualMachine", r))
 }
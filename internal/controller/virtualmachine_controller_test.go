@@ -18,11 +18,28 @@ package controller
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -109,4 +126,1580 @@ var _ = Describe("VirtualMachine Controller", func() {
 			Expect(virtualmachine.Spec.RunStrategy).To(Equal("Always"))
 		})
 	})
+
+	Context("Helper functions", func() {
+		It("should capture VMI phase transition timestamps", func() {
+			status := map[string]interface{}{
+				"phase": "Running",
+				"phaseTransitionTimestamps": []interface{}{
+					map[string]interface{}{"phase": "Pending", "phaseTransitionTimestamp": "2025-01-01T10:00:00Z"},
+					map[string]interface{}{"phase": "Scheduling", "phaseTransitionTimestamp": "2025-01-01T10:00:05Z"},
+					map[string]interface{}{"phase": "Scheduled", "phaseTransitionTimestamp": "2025-01-01T10:00:20Z"},
+					map[string]interface{}{"phase": "Running", "phaseTransitionTimestamp": "2025-01-01T10:00:42Z"},
+					map[string]interface{}{"phase": "Broken", "phaseTransitionTimestamp": "not-a-time"},
+				},
+			}
+
+			transitions := phaseTransitionsFromVMI(status)
+			Expect(transitions).To(HaveLen(4))
+			Expect(transitions[0].Phase).To(Equal("Pending"))
+			Expect(transitions[3].Phase).To(Equal("Running"))
+			Expect(transitions[3].TransitionTime.Time).To(BeTemporally("==", time.Date(2025, 1, 1, 10, 0, 42, 0, time.UTC)))
+		})
+
+		It("should return no transitions when the VMI has none", func() {
+			Expect(phaseTransitionsFromVMI(map[string]interface{}{"phase": "Pending"})).To(BeEmpty())
+		})
+
+		It("should emit both the scheduled and the guest memory", func() {
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "overcommit", Namespace: "default"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:          "ubuntu",
+					DiskSize:    "0",
+					Memory:      "2Gi",
+					GuestMemory: "8Gi",
+				},
+			}
+			r := &VirtualMachineReconciler{}
+
+			obj := r.buildKubeVirtVM(vm, nil, nil, nil, "").Object
+			memory, _, _ := unstructured.NestedString(obj, "spec", "template", "spec", "domain", "resources", "requests", "memory")
+			Expect(memory).To(Equal("2Gi"))
+			guest, _, _ := unstructured.NestedString(obj, "spec", "template", "spec", "domain", "memory", "guest")
+			Expect(guest).To(Equal("8Gi"))
+
+			vm.Spec.GuestMemory = ""
+			_, found, _ := unstructured.NestedMap(r.buildKubeVirtVM(vm, nil, nil, nil, "").Object, "spec", "template", "spec", "domain", "memory")
+			Expect(found).To(BeFalse())
+		})
+
+		It("should pass GPUs through and schedule onto the selected nodes", func() {
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "gpu", Namespace: "default"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:           "ubuntu",
+					DiskSize:     "0",
+					GPUs:         []llmcloudv1alpha1.VMDevice{{Name: "gpu1", DeviceName: "nvidia.com/TU104GL_Tesla_T4"}},
+					NodeSelector: map[string]string{"gpu": "t4"},
+					Tolerations: []corev1.Toleration{{
+						Key: "nvidia.com/gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule,
+					}},
+				},
+			}
+			r := &VirtualMachineReconciler{}
+
+			obj := r.buildKubeVirtVM(vm, nil, nil, nil, "").Object
+			gpus, _, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "domain", "devices", "gpus")
+			Expect(gpus).To(ConsistOf(map[string]interface{}{"name": "gpu1", "deviceName": "nvidia.com/TU104GL_Tesla_T4"}))
+			_, found, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "domain", "devices", "hostDevices")
+			Expect(found).To(BeFalse())
+			selector, _, _ := unstructured.NestedStringMap(obj, "spec", "template", "spec", "nodeSelector")
+			Expect(selector).To(Equal(map[string]string{"gpu": "t4"}))
+			tolerations, _, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "tolerations")
+			Expect(tolerations).To(HaveLen(1))
+			Expect(tolerations[0]).To(HaveKeyWithValue("key", "nvidia.com/gpu"))
+		})
+
+		It("should boot the VMImage the VM references", func() {
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "desktop", Namespace: "default"},
+				Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", DiskSize: "0", ImageRef: "windows"},
+			}
+			r := &VirtualMachineReconciler{}
+			volumeSource := func(obj map[string]interface{}, name string) map[string]interface{} {
+				volumes, _, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "volumes")
+				for _, v := range volumes {
+					if volume := v.(map[string]interface{}); volume["name"] == name {
+						return volume
+					}
+				}
+				return nil
+			}
+
+			registered := &llmcloudv1alpha1.VMImage{Spec: llmcloudv1alpha1.VMImageSpec{ContainerDisk: "registry.local/windows:2022"}}
+			obj := r.buildKubeVirtVM(vm, registered, nil, nil, "").Object
+			image, _, _ := unstructured.NestedString(volumeSource(obj, "containerdisk"), "containerDisk", "image")
+			Expect(image).To(Equal("registry.local/windows:2022"))
+
+			uploaded := &llmcloudv1alpha1.VMImage{
+				Spec: llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "40Gi"}},
+				Status: llmcloudv1alpha1.VMImageStatus{
+					Phase:  llmcloudv1alpha1.VMImagePhaseReady,
+					Volume: &llmcloudv1alpha1.VMImageVolume{Namespace: "llmcloud-images", Name: "windows"},
+				},
+			}
+			obj = r.buildKubeVirtVM(vm, uploaded, nil, nil, "").Object
+			Expect(volumeSource(obj, "containerdisk")).To(BeNil())
+			name, _, _ := unstructured.NestedString(volumeSource(obj, "rootdisk"), "dataVolume", "name")
+			Expect(name).To(Equal("desktop-root"))
+			templates, _, _ := unstructured.NestedSlice(obj, "spec", "dataVolumeTemplates")
+			Expect(templates).NotTo(BeEmpty())
+			source, _, _ := unstructured.NestedStringMap(templates[0].(map[string]interface{}), "spec", "source", "pvc")
+			Expect(source).To(Equal(map[string]string{"namespace": "llmcloud-images", "name": "windows"}))
+		})
+
+		It("should hotplug additional disks backed by their own data volumes", func() {
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:       "ubuntu",
+					DiskSize: "20Gi",
+					AdditionalDisks: []llmcloudv1alpha1.VMDisk{
+						{Name: "data", Size: "100Gi"},
+						{Name: "fast", Size: "10Gi", StorageClass: "nvme"},
+					},
+				},
+			}
+			r := &VirtualMachineReconciler{}
+
+			obj := r.buildKubeVirtVM(vm, nil, nil, nil, "").Object
+			volumes, _, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "volumes")
+			Expect(volumes).To(ContainElement(map[string]interface{}{
+				"name":       "data",
+				"dataVolume": map[string]interface{}{"name": "db-data", "hotpluggable": true},
+			}))
+			disks, _, _ := unstructured.NestedSlice(obj, "spec", "template", "spec", "domain", "devices", "disks")
+			Expect(disks).To(ContainElement(map[string]interface{}{"name": "fast", "disk": map[string]interface{}{"bus": "scsi"}}))
+
+			templates, _, _ := unstructured.NestedSlice(obj, "spec", "dataVolumeTemplates")
+			Expect(templates).To(HaveLen(3))
+			fast := templates[2].(map[string]interface{})
+			name, _, _ := unstructured.NestedString(fast, "metadata", "name")
+			Expect(name).To(Equal("db-fast"))
+			storageClass, _, _ := unstructured.NestedString(fast, "spec", "storage", "storageClassName")
+			Expect(storageClass).To(Equal("nvme"))
+			size, _, _ := unstructured.NestedString(fast, "spec", "storage", "resources", "requests", "storage")
+			Expect(size).To(Equal("10Gi"))
+		})
+
+		It("should keep the storage class and OS registry a VM was created with", func() {
+			DeferCleanup(func() { llmcloudv1alpha1.SetRuntimeConfig(nil) })
+			llmcloudv1alpha1.SetRuntimeConfig(&llmcloudv1alpha1.LLMCloudConfigSpec{
+				DefaultStorageClass: "fast-ssd",
+				OSImageRegistry:     "mirror.local:5000",
+			})
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "pinned", Namespace: "default"},
+				Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "debian", OSVersion: "12", DiskSize: "20Gi"},
+			}
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm).
+				Build()
+			r := &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+			Expect(r.recordDefaults(ctx, vm)).To(Succeed())
+
+			llmcloudv1alpha1.SetRuntimeConfig(&llmcloudv1alpha1.LLMCloudConfigSpec{DefaultStorageClass: "slow-hdd"})
+			stored := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(c.Get(ctx, client.ObjectKeyFromObject(vm), stored)).To(Succeed())
+			Expect(stored.Status.Defaults).To(Equal(&llmcloudv1alpha1.VMDefaults{
+				StorageClass:    "fast-ssd",
+				OSImageRegistry: "mirror.local:5000",
+			}))
+
+			obj := r.buildKubeVirtVM(stored, nil, nil, nil, "").Object
+			templates, _, _ := unstructured.NestedSlice(obj, "spec", "dataVolumeTemplates")
+			Expect(templates).NotTo(BeEmpty())
+			storageClass, _, _ := unstructured.NestedString(templates[0].(map[string]interface{}), "spec", "storage", "storageClassName")
+			Expect(storageClass).To(Equal("fast-ssd"))
+			Expect(fmt.Sprint(obj)).To(ContainSubstring("mirror.local:5000/debian:12"))
+		})
+
+		It("should parse backup schedules and report when a backup is due", func() {
+			last := time.Date(2025, 1, 1, 2, 0, 0, 0, time.UTC)
+
+			due, wait, err := backupTiming("0 2 * * *", last, last.Add(12*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(due).To(BeFalse())
+			Expect(wait).To(Equal(12 * time.Hour))
+
+			due, wait, err = backupTiming("0 2 * * *", last, last.Add(25*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(due).To(BeTrue())
+			Expect(wait).To(Equal(23 * time.Hour))
+
+			_, _, err = backupTiming("every night", last, last)
+			Expect(err).To(HaveOccurred())
+			_, _, err = backupTiming("0 2 * *", last, last)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should prune the oldest snapshots beyond the retention count", func() {
+			base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			var snapshots []unstructured.Unstructured
+			for _, day := range []int{3, 0, 4, 1, 2} {
+				s := unstructured.Unstructured{}
+				s.SetName(fmt.Sprintf("vm-backup-%d", day))
+				s.SetCreationTimestamp(metav1.NewTime(base.AddDate(0, 0, day)))
+				snapshots = append(snapshots, s)
+			}
+
+			pruned := snapshotsToPrune(snapshots, 3)
+			Expect(pruned).To(HaveLen(2))
+			Expect(pruned[0].GetName()).To(Equal("vm-backup-1"))
+			Expect(pruned[1].GetName()).To(Equal("vm-backup-0"))
+
+			Expect(snapshotsToPrune(snapshots, 5)).To(BeEmpty())
+			Expect(snapshotsToPrune(snapshots, 0)).To(BeEmpty())
+		})
+
+		It("should map managed KubeVirt VMs and their VMIs to the VM", func() {
+			kvVM := &unstructured.Unstructured{}
+			kvVM.SetName("web")
+			kvVM.SetNamespace("project-team")
+			kvVM.SetLabels(map[string]string{"llmcloud.io/managed": "true"})
+			want := []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: "project-team", Name: "web"}}}
+			Expect(vmForKubeVirtObject(ctx, kvVM)).To(Equal(want))
+
+			vmi := &unstructured.Unstructured{}
+			vmi.SetName("web")
+			vmi.SetNamespace("project-team")
+			vmi.SetOwnerReferences([]metav1.OwnerReference{{APIVersion: "kubevirt.io/v1", Kind: "VirtualMachine", Name: "web"}})
+			Expect(vmForKubeVirtObject(ctx, vmi)).To(Equal(want))
+
+			unmanaged := &unstructured.Unstructured{}
+			unmanaged.SetName("other")
+			unmanaged.SetNamespace("project-team")
+			Expect(vmForKubeVirtObject(ctx, unmanaged)).To(BeEmpty())
+		})
+	})
+
+	Context("When rebooting a VM", func() {
+		ctx := context.Background()
+		key := client.ObjectKey{Name: "reboot-vm", Namespace: "default"}
+
+		newRebootReconciler := func() (*VirtualMachineReconciler, *llmcloudv1alpha1.VirtualMachine) {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        key.Name,
+					Namespace:   key.Namespace,
+					Annotations: map[string]string{vmRebootAnnotation: "true"},
+				},
+			}
+			kvVM := &unstructured.Unstructured{}
+			kvVM.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachine"})
+			kvVM.SetName(key.Name)
+			kvVM.SetNamespace(key.Namespace)
+			Expect(unstructured.SetNestedField(kvVM.Object, "Always", "spec", "runStrategy")).To(Succeed())
+
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm, kvVM).
+				Build()
+			Expect(c.Get(ctx, key, vm)).To(Succeed())
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}, vm
+		}
+
+		It("should keep the reboot annotation while the VMI has not come back", func() {
+			r, vm := newRebootReconciler()
+
+			Expect(r.startReboot(ctx, vm)).To(Succeed())
+			Expect(vm.Status.RebootPhase).To(Equal(llmcloudv1alpha1.RebootPhaseRebooting))
+
+			done, err := r.finishReboot(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(BeFalse())
+
+			latest := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, latest)).To(Succeed())
+			Expect(latest.Annotations).To(HaveKeyWithValue(vmRebootAnnotation, "true"))
+			Expect(latest.Status.RebootPhase).To(Equal(llmcloudv1alpha1.RebootPhaseRebooting))
+		})
+
+		It("should remove the reboot annotation once a new VMI is Running", func() {
+			r, vm := newRebootReconciler()
+			Expect(r.startReboot(ctx, vm)).To(Succeed())
+
+			vmi := &unstructured.Unstructured{}
+			vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+			vmi.SetName(key.Name)
+			vmi.SetNamespace(key.Namespace)
+			vmi.SetCreationTimestamp(metav1.NewTime(time.Now().Add(time.Second)))
+			Expect(unstructured.SetNestedField(vmi.Object, "Running", "status", "phase")).To(Succeed())
+			Expect(r.Create(ctx, vmi)).To(Succeed())
+
+			done, err := r.finishReboot(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(done).To(BeTrue())
+
+			latest := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, latest)).To(Succeed())
+			Expect(latest.Annotations).NotTo(HaveKey(vmRebootAnnotation))
+			Expect(latest.Status.RebootPhase).To(BeEmpty())
+			Expect(latest.Status.RebootStartTime).To(BeNil())
+		})
+	})
+
+	Context("When resizing a VM", func() {
+		ctx := context.Background()
+		key := client.ObjectKey{Name: "resize-vm", Namespace: "default"}
+
+		newVMI := func(cores int64, memory string) *unstructured.Unstructured {
+			vmi := &unstructured.Unstructured{}
+			vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+			vmi.SetName(key.Name)
+			vmi.SetNamespace(key.Namespace)
+			Expect(unstructured.SetNestedField(vmi.Object, cores, "spec", "domain", "cpu", "cores")).To(Succeed())
+			Expect(unstructured.SetNestedField(vmi.Object, memory, "spec", "domain", "resources", "requests", "memory")).To(Succeed())
+			Expect(unstructured.SetNestedField(vmi.Object, "Running", "status", "phase")).To(Succeed())
+			return vmi
+		}
+		newResizeReconciler := func(runStrategy string) (*VirtualMachineReconciler, *record.FakeRecorder, *llmcloudv1alpha1.VirtualMachine) {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS: "ubuntu", CPUs: 4, Memory: "8Gi", DiskSize: "0", RunStrategy: runStrategy,
+				},
+				Status: llmcloudv1alpha1.VirtualMachineStatus{Phase: llmcloudv1alpha1.PhaseRunning},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm, newVMI(2, "4Gi")).
+				Build()
+			Expect(c.Get(ctx, key, vm)).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme, Recorder: recorder}, recorder, vm
+		}
+		runStrategyOf := func(r *VirtualMachineReconciler, vm *llmcloudv1alpha1.VirtualMachine) string {
+			runStrategy, _, _ := unstructured.NestedString(r.buildKubeVirtVM(vm, nil, nil, nil, "").Object, "spec", "runStrategy")
+			return runStrategy
+		}
+
+		It("should compare the instance's resources with the spec", func() {
+			vm := &llmcloudv1alpha1.VirtualMachine{Spec: llmcloudv1alpha1.VirtualMachineSpec{CPUs: 2, Memory: "8Gi"}}
+			Expect(vmNeedsResize(vm, newVMI(2, "8192Mi"))).To(BeFalse())
+			Expect(vmNeedsResize(vm, newVMI(4, "8Gi"))).To(BeTrue())
+			Expect(vmNeedsResize(vm, newVMI(2, "4Gi"))).To(BeTrue())
+			Expect(vmNeedsResize(vm, &unstructured.Unstructured{Object: map[string]interface{}{}})).To(BeFalse())
+		})
+
+		It("should stop the instance and start it again with the new resources", func() {
+			r, recorder, vm := newResizeReconciler("Always")
+
+			resizing, err := r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resizing).To(BeTrue())
+			Expect(vm.Status.Phase).To(Equal(llmcloudv1alpha1.PhaseResizing))
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition).Reason).To(Equal(vmResizeStopping))
+			Expect(runStrategyOf(r, vm)).To(Equal("Halted"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Resizing")))
+
+			By("waiting while the old instance shuts down")
+			resizing, err = r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resizing).To(BeTrue())
+			Expect(runStrategyOf(r, vm)).To(Equal("Halted"))
+
+			By("starting once the old instance is gone")
+			Expect(r.Delete(ctx, newVMI(2, "4Gi"))).To(Succeed())
+			resizing, err = r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resizing).To(BeTrue())
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition).Reason).To(Equal(vmResizeStarting))
+			Expect(runStrategyOf(r, vm)).To(Equal("Always"))
+
+			By("finishing once the new instance is running")
+			Expect(r.Create(ctx, newVMI(4, "8Gi"))).To(Succeed())
+			resizing, err = r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resizing).To(BeFalse())
+			cond := meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition)
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(vmResizeDone))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Resized")))
+			Expect(vmPhase(vm, llmcloudv1alpha1.PhaseRunning)).To(Equal(llmcloudv1alpha1.PhaseRunning))
+		})
+
+		It("should only ask for a restart of a Manual VM", func() {
+			r, recorder, vm := newResizeReconciler("Manual")
+
+			resizing, err := r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resizing).To(BeFalse())
+			Expect(vm.Status.Phase).To(Equal(llmcloudv1alpha1.PhaseRunning))
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition).Reason).To(Equal(vmResizeRestartRequired))
+			Expect(runStrategyOf(r, vm)).To(Equal("Manual"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("RestartRequired")))
+
+			By("clearing the request once the instance is stopped")
+			Expect(r.Delete(ctx, newVMI(2, "4Gi"))).To(Succeed())
+			_, err = r.reconcileResize(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmResizingCondition).Reason).To(Equal(vmResizeDone))
+		})
+	})
+
+	Context("When reading SSH keys from a Secret", func() {
+		const sshKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGQdgUmX97JMXLbmXCll+bvnMY+OT7LrGdVP1JFp8L+J test@llmcloud"
+		ctx := context.Background()
+
+		newSecretReconciler := func(objs ...client.Object) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		vm := &llmcloudv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "keys-vm", Namespace: "default"},
+			Spec: llmcloudv1alpha1.VirtualMachineSpec{
+				OS:          "ubuntu",
+				DiskSize:    "0",
+				SSHKeysFrom: &llmcloudv1alpha1.SecretKeySelector{Name: "team-keys", Key: "authorized_keys"},
+			},
+		}
+
+		It("should inject the keys from the Secret into cloud-init", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-keys", Namespace: "default"},
+				Data: map[string][]byte{
+					"authorized_keys": []byte("# team keys\n" + sshKey + "\n\n" + sshKey + " second\n"),
+				},
+			}
+			r := newSecretReconciler(secret)
+
+			keys, err := r.sshKeysForVM(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{sshKey, sshKey + " second"}))
+
+			volumes, _, _ := unstructured.NestedSlice(r.buildKubeVirtVM(vm, nil, keys, nil, "").Object, "spec", "template", "spec", "volumes")
+			var userData string
+			for _, v := range volumes {
+				if userData, _, _ = unstructured.NestedString(v.(map[string]interface{}), "cloudInitNoCloud", "userData"); userData != "" {
+					break
+				}
+			}
+			Expect(userData).To(Equal(fmt.Sprintf("#cloud-config\nssh_authorized_keys:\n  - %q\n  - %q", sshKey, sshKey+" second")))
+		})
+
+		It("should fail when the Secret is missing or holds invalid keys", func() {
+			_, err := newSecretReconciler().sshKeysForVM(ctx, vm)
+			Expect(err).To(HaveOccurred())
+
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-keys", Namespace: "default"},
+				Data:       map[string][]byte{"authorized_keys": []byte("not a key\n")},
+			}
+			_, err = newSecretReconciler(secret).sshKeysForVM(ctx, vm)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("When referencing SSHKey resources", func() {
+		const sshKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGQdgUmX97JMXLbmXCll+bvnMY+OT7LrGdVP1JFp8L+J test@llmcloud"
+		ctx := context.Background()
+
+		newKeyReconciler := func(objs ...client.Object) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+		newVM := func(refs ...string) *llmcloudv1alpha1.VirtualMachine {
+			return &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "keys-vm", Namespace: "project-team"},
+				Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", SSHKeys: []string{sshKey + " inline"}, SSHKeyRefs: refs},
+			}
+		}
+		alice := &llmcloudv1alpha1.User{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice"},
+			Spec:       llmcloudv1alpha1.UserSpec{Username: "alice", Projects: []string{"team"}},
+		}
+		userKey := &llmcloudv1alpha1.SSHKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "alice-laptop"},
+			Spec:       llmcloudv1alpha1.SSHKeySpec{PublicKey: sshKey + " alice", User: "alice"},
+		}
+		projectKey := &llmcloudv1alpha1.SSHKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "team-deploy"},
+			Spec:       llmcloudv1alpha1.SSHKeySpec{PublicKey: sshKey + " deploy", Project: "team"},
+		}
+		otherKey := &llmcloudv1alpha1.SSHKey{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-deploy"},
+			Spec:       llmcloudv1alpha1.SSHKeySpec{PublicKey: sshKey + " other", Project: "other"},
+		}
+
+		It("should merge the referenced keys after the inline ones", func() {
+			r := newKeyReconciler(alice, userKey, projectKey)
+			keys, err := r.sshKeysForVM(ctx, newVM("alice-laptop", "team-deploy"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]string{sshKey + " inline", sshKey + " alice", sshKey + " deploy"}))
+		})
+
+		It("should reject keys of other projects and of users without access", func() {
+			r := newKeyReconciler(userKey, otherKey)
+			_, err := r.sshKeysForVM(ctx, newVM("other-deploy"))
+			Expect(err).To(HaveOccurred())
+			_, err = r.sshKeysForVM(ctx, newVM("alice-laptop"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should enqueue the VMs referencing a key", func() {
+			vm := newVM("team-deploy")
+			r := newKeyReconciler(vm, projectKey)
+			Expect(r.vmsForSSHKey(ctx, projectKey)).To(HaveLen(1))
+			Expect(r.vmsForSSHKey(ctx, otherKey)).To(BeEmpty())
+		})
+	})
+
+	Context("When writing Secret keys as cloud-init files", func() {
+		ctx := context.Background()
+
+		newFilesReconciler := func(objs ...client.Object) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		newFilesVM := func() *llmcloudv1alpha1.VirtualMachine {
+			return &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "files-vm", Namespace: "default", UID: "files-vm-uid"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:       "ubuntu",
+					DiskSize: "0",
+					SecretFiles: []llmcloudv1alpha1.SecretFile{
+						{
+							SecretRef:   llmcloudv1alpha1.SecretKeySelector{Name: "tls", Key: "tls.crt"},
+							Path:        "/etc/app/tls.crt",
+							Permissions: "0644",
+						},
+						{
+							SecretRef: llmcloudv1alpha1.SecretKeySelector{Name: "tls", Key: "tls.key"},
+							Path:      "/etc/app/tls.key",
+						},
+					},
+				},
+			}
+		}
+
+		tlsSecret := func(key []byte) *corev1.Secret {
+			return &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+				Data:       map[string][]byte{"tls.crt": []byte("CERT\n"), "tls.key": key},
+			}
+		}
+
+		It("should generate write_files entries from the referenced Secret", func() {
+			vm := newFilesVM()
+			r := newFilesReconciler(tlsSecret([]byte("KEY\n")))
+
+			files, err := r.secretFilesForVM(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(files).To(HaveLen(2))
+
+			Expect(cloudInitUserData(vm, nil, files, "")).To(Equal("#cloud-config\nwrite_files:" +
+				"\n  - path: \"/etc/app/tls.crt\"\n    encoding: b64\n    content: Q0VSVAo=\n    permissions: \"0644\"" +
+				"\n  - path: \"/etc/app/tls.key\"\n    encoding: b64\n    content: S0VZCg==\n    permissions: \"0600\""))
+
+			By("keeping the file contents out of the KubeVirt VM")
+			volumes, _, _ := unstructured.NestedSlice(r.buildKubeVirtVM(vm, nil, nil, files, "").Object, "spec", "template", "spec", "volumes")
+			cloudInit := volumes[len(volumes)-1].(map[string]interface{})["cloudInitNoCloud"].(map[string]interface{})
+			Expect(cloudInit).NotTo(HaveKey("userData"))
+			Expect(cloudInit).To(HaveKeyWithValue("secretRef", map[string]interface{}{"name": "files-vm-cloudinit"}))
+
+			Expect(r.reconcileCloudInitSecret(ctx, vm, cloudInitUserData(vm, nil, files, ""))).To(Succeed())
+			secret := &corev1.Secret{}
+			Expect(r.Get(ctx, client.ObjectKey{Name: "files-vm-cloudinit", Namespace: "default"}, secret)).To(Succeed())
+			Expect(string(secret.Data["userdata"])).To(ContainSubstring("write_files:"))
+			Expect(secret.OwnerReferences).To(HaveLen(1))
+		})
+
+		It("should fail for missing keys and oversized values", func() {
+			vm := newFilesVM()
+
+			_, err := newFilesReconciler().secretFilesForVM(ctx, vm)
+			Expect(err).To(HaveOccurred())
+
+			secret := tlsSecret(nil)
+			delete(secret.Data, "tls.key")
+			_, err = newFilesReconciler(secret).secretFilesForVM(ctx, vm)
+			Expect(err).To(MatchError(ContainSubstring(`no key "tls.key"`)))
+
+			_, err = newFilesReconciler(tlsSecret(make([]byte, llmcloudv1alpha1.MaxSecretFileSize+1))).secretFilesForVM(ctx, vm)
+			Expect(err).To(MatchError(ContainSubstring("more than")))
+		})
+	})
+
+	Context("When generating the default user's credentials", func() {
+		ctx := context.Background()
+
+		newCredentialsReconciler := func(objs ...client.Object) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		newCredentialsVM := func() *llmcloudv1alpha1.VirtualMachine {
+			return &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "creds-vm", Namespace: "default", UID: "creds-vm-uid"},
+				Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "centos", DiskSize: "0"},
+			}
+		}
+
+		It("should store a password once and set it through the cloud-init Secret", func() {
+			vm := newCredentialsVM()
+			r := newCredentialsReconciler()
+
+			password, err := r.reconcileCredentialsSecret(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(password).To(HaveLen(vmPasswordLength))
+			secret := &corev1.Secret{}
+			Expect(r.Get(ctx, client.ObjectKey{Name: "creds-vm-credentials", Namespace: "default"}, secret)).To(Succeed())
+			Expect(secret.Type).To(Equal(corev1.SecretTypeBasicAuth))
+			Expect(string(secret.Data["username"])).To(Equal("cloud-user"))
+			Expect(string(secret.Data["password"])).To(Equal(password))
+			Expect(secret.OwnerReferences).To(HaveLen(1))
+
+			By("keeping the password on later reconciles")
+			again, err := r.reconcileCredentialsSecret(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(again).To(Equal(password))
+
+			Expect(cloudInitUserData(vm, nil, nil, password)).To(Equal(
+				fmt.Sprintf("#cloud-config\npassword: %q\nchpasswd:\n  expire: false", password)))
+			volumes, _, _ := unstructured.NestedSlice(r.buildKubeVirtVM(vm, nil, nil, nil, password).Object, "spec", "template", "spec", "volumes")
+			cloudInit := volumes[len(volumes)-1].(map[string]interface{})["cloudInitNoCloud"].(map[string]interface{})
+			Expect(cloudInit).To(HaveKeyWithValue("secretRef", map[string]interface{}{"name": "creds-vm-cloudinit"}))
+		})
+
+		It("should not generate credentials for a custom cloud-init", func() {
+			vm := newCredentialsVM()
+			vm.Spec.CloudInit = "#cloud-config\n"
+			r := newCredentialsReconciler()
+
+			password, err := r.reconcileCredentialsSecret(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(password).To(BeEmpty())
+			err = r.Get(ctx, client.ObjectKey{Name: "creds-vm-credentials", Namespace: "default"}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should copy the user data referenced by cloudInitSecretRef", func() {
+			vm := newCredentialsVM()
+			vm.Spec.CloudInitSecretRef = &llmcloudv1alpha1.SecretKeySelector{Name: "init", Key: "user-data"}
+			r := newCredentialsReconciler(vm.DeepCopy(), &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "init", Namespace: "default"},
+				Data:       map[string][]byte{"user-data": []byte("#cloud-config\nhostname: secret\n")},
+			})
+
+			userData, err := r.cloudInitFromSecret(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(userData).To(Equal("#cloud-config\nhostname: secret\n"))
+			Expect(r.vmsForSecret(ctx, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "init", Namespace: "default"}})).To(HaveLen(1))
+
+			volumes, _, _ := unstructured.NestedSlice(r.buildKubeVirtVM(vm, nil, nil, nil, "").Object, "spec", "template", "spec", "volumes")
+			cloudInit := volumes[len(volumes)-1].(map[string]interface{})["cloudInitNoCloud"].(map[string]interface{})
+			Expect(cloudInit).To(HaveKeyWithValue("secretRef", map[string]interface{}{"name": "creds-vm-cloudinit"}))
+
+			vm.Spec.CloudInitSecretRef.Key = "missing"
+			_, err = r.cloudInitFromSecret(ctx, vm)
+			Expect(err).To(MatchError(ContainSubstring(`no key "missing"`)))
+		})
+	})
+
+	Context("When exposing ports", func() {
+		ctx := context.Background()
+
+		It("should create a Service for the exposed ports and delete it once none are", func() {
+			testScheme := runtime.NewScheme()
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			Expect(networkingv1.AddToScheme(testScheme)).To(Succeed())
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			r := &VirtualMachineReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme).Build(), Scheme: testScheme}
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: "web-uid"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:           "ubuntu",
+					ExposedPorts: []llmcloudv1alpha1.VMPort{{Name: "http", Port: 80}, {Port: 53, Protocol: "UDP"}},
+				},
+			}
+			key := client.ObjectKey{Name: "web-ports", Namespace: "default"}
+
+			Expect(r.reconcileExposedPorts(ctx, vm)).To(Succeed())
+			svc := &corev1.Service{}
+			Expect(r.Get(ctx, key, svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+			Expect(svc.Spec.Selector).To(Equal(map[string]string{"vm.kubevirt.io/name": "web"}))
+			Expect(svc.Spec.Ports).To(HaveLen(2))
+			Expect(svc.Spec.Ports[1].Name).To(Equal("port-1"))
+			Expect(svc.Spec.Ports[1].Protocol).To(Equal(corev1.ProtocolUDP))
+			Expect(svc.Spec.Ports[1].TargetPort.IntValue()).To(Equal(53))
+			policy := &networkingv1.NetworkPolicy{}
+			Expect(r.Get(ctx, key, policy)).To(Succeed())
+			Expect(policy.Spec.PodSelector.MatchLabels).To(Equal(svc.Spec.Selector))
+			Expect(policy.Spec.Ingress).To(HaveLen(1))
+			Expect(policy.Spec.Ingress[0].From).To(BeEmpty())
+			Expect(policy.Spec.Ingress[0].Ports).To(HaveLen(2))
+			Expect(*policy.Spec.Ingress[0].Ports[1].Protocol).To(Equal(corev1.ProtocolUDP))
+
+			By("keeping the allocated node ports")
+			svc.Spec.Ports[0].NodePort = 30080
+			Expect(r.Update(ctx, svc)).To(Succeed())
+			vm.Spec.ExposeType = "LoadBalancer"
+			Expect(r.reconcileExposedPorts(ctx, vm)).To(Succeed())
+			Expect(r.Get(ctx, key, svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeLoadBalancer))
+			Expect(svc.Spec.Ports[0].NodePort).To(Equal(int32(30080)))
+
+			vm.Spec.ExposedPorts = nil
+			Expect(r.reconcileExposedPorts(ctx, vm)).To(Succeed())
+			Expect(errors.IsNotFound(r.Get(ctx, key, svc))).To(BeTrue())
+			Expect(errors.IsNotFound(r.Get(ctx, key, policy))).To(BeTrue())
+		})
+	})
+
+	Context("When handling action annotations", func() {
+		ctx := context.Background()
+		key := client.ObjectKey{Name: "action-vm", Namespace: "default"}
+
+		newActionReconciler := func(annotation string) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        key.Name,
+					Namespace:   key.Namespace,
+					Annotations: map[string]string{annotation: "true"},
+				},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm).
+				Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		// runAction reconciles the action and returns the VM and the object created for it
+		runAction := func(r *VirtualMachineReconciler, action vmAction) (*llmcloudv1alpha1.VirtualMachine, *unstructured.Unstructured) {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(r.reconcileAction(ctx, vm, action)).To(Succeed())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+
+			name := *action.pending(&vm.Status)
+			if name == "" {
+				return vm, nil
+			}
+			obj := &unstructured.Unstructured{}
+			obj.SetGroupVersionKind(action.gvk)
+			Expect(r.Get(ctx, client.ObjectKey{Name: name, Namespace: key.Namespace}, obj)).To(Succeed())
+			return vm, obj
+		}
+
+		completeAction := func(r *VirtualMachineReconciler, obj *unstructured.Unstructured, phase string) {
+			Expect(unstructured.SetNestedField(obj.Object, phase, "status", "phase")).To(Succeed())
+			Expect(r.Update(ctx, obj)).To(Succeed())
+		}
+
+		It("should create a snapshot and clear the annotation once it succeeds", func() {
+			action := vmActions[0]
+			r := newActionReconciler("llmcloud.io/snapshot")
+
+			vm, snapshot := runAction(r, action)
+			Expect(snapshot).NotTo(BeNil())
+			Expect(snapshot.GetKind()).To(Equal("VirtualMachineSnapshot"))
+			source, _, _ := unstructured.NestedString(snapshot.Object, "spec", "source", "name")
+			Expect(source).To(Equal(key.Name))
+			Expect(vm.Annotations).To(HaveKey("llmcloud.io/snapshot"))
+
+			By("keeping the annotation while the snapshot is in progress")
+			completeAction(r, snapshot, "InProgress")
+			vm, _ = runAction(r, action)
+			Expect(vm.Annotations).To(HaveKey("llmcloud.io/snapshot"))
+			Expect(vm.Status.PendingSnapshot).To(Equal(snapshot.GetName()))
+
+			completeAction(r, snapshot, "Succeeded")
+			vm, _ = runAction(r, action)
+			Expect(vm.Annotations).NotTo(HaveKey("llmcloud.io/snapshot"))
+			Expect(vm.Status.PendingSnapshot).To(BeEmpty())
+		})
+
+		It("should create a migration and clear the annotation once it finishes", func() {
+			action := vmActions[1]
+			r := newActionReconciler("llmcloud.io/migrate")
+
+			_, migration := runAction(r, action)
+			Expect(migration).NotTo(BeNil())
+			Expect(migration.GetKind()).To(Equal("VirtualMachineInstanceMigration"))
+			vmiName, _, _ := unstructured.NestedString(migration.Object, "spec", "vmiName")
+			Expect(vmiName).To(Equal(key.Name))
+
+			completeAction(r, migration, "Failed")
+			vm, _ := runAction(r, action)
+			Expect(vm.Annotations).NotTo(HaveKey("llmcloud.io/migrate"))
+			Expect(vm.Status.PendingMigration).To(BeEmpty())
+		})
+
+		It("should recreate the action object if it disappears before finishing", func() {
+			action := vmActions[1]
+			r := newActionReconciler("llmcloud.io/migrate")
+
+			_, migration := runAction(r, action)
+			Expect(r.Delete(ctx, migration)).To(Succeed())
+
+			vm, _ := runAction(r, action)
+			Expect(vm.Annotations).To(HaveKey("llmcloud.io/migrate"))
+			Expect(vm.Status.PendingMigration).To(BeEmpty())
+		})
+	})
+
+	Context("When finalizers are stripped concurrently", func() {
+		ctx := context.Background()
+		key := client.ObjectKey{Name: "deleting-vm", Namespace: "default"}
+
+		newDeletingReconciler := func(finalizers ...string) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			now := metav1.Now()
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              key.Name,
+					Namespace:         key.Namespace,
+					Finalizers:        finalizers,
+					DeletionTimestamp: &now,
+				},
+			}
+			c := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(vm).Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		stripFinalizers := func(r *VirtualMachineReconciler) {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			vm.Finalizers = nil
+			Expect(r.Update(ctx, vm)).To(Succeed())
+		}
+
+		It("should finalize a VM whose KubeVirt VM is already gone", func() {
+			r := newDeletingReconciler(vmFinalizer)
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(r.Get(ctx, key, &llmcloudv1alpha1.VirtualMachine{}))).To(BeTrue())
+		})
+
+		It("should not fail when the VM was deleted before its finalizer was removed", func() {
+			r := newDeletingReconciler(vmFinalizer)
+			stale := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, stale)).To(Succeed())
+
+			stripFinalizers(r)
+
+			Expect(removeFinalizer(ctx, r.Client, stale, vmFinalizer)).To(Succeed())
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("should remove its finalizer from a stale copy without a conflict", func() {
+			r := newDeletingReconciler(vmFinalizer, "example.com/other")
+			stale := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, stale)).To(Succeed())
+
+			latest := stale.DeepCopy()
+			latest.Annotations = map[string]string{"touched": "true"}
+			Expect(r.Update(ctx, latest)).To(Succeed())
+
+			Expect(removeFinalizer(ctx, r.Client, stale, vmFinalizer)).To(Succeed())
+			Expect(r.Get(ctx, key, latest)).To(Succeed())
+			Expect(latest.Finalizers).To(Equal([]string{"example.com/other"}))
+		})
+	})
+
+	Context("When recording cloud-init status", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "cloudinit-vm", Namespace: "default"}
+
+		newCloudInitReconciler := func(agentConnected bool, source CloudInitStatusSource) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			}
+			vmi := &unstructured.Unstructured{}
+			vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+			vmi.SetName(key.Name)
+			vmi.SetNamespace(key.Namespace)
+			vmi.SetUID("cloudinit-vmi-uid")
+			Expect(unstructured.SetNestedField(vmi.Object, "Running", "status", "phase")).To(Succeed())
+			controller := true
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "virt-launcher-cloudinit-vm-abcde",
+					Namespace: key.Namespace,
+					Labels:    map[string]string{"vm.kubevirt.io/name": key.Name},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "kubevirt.io/v1",
+						Kind:       "VirtualMachineInstance",
+						Name:       key.Name,
+						UID:        "cloudinit-vmi-uid",
+						Controller: &controller,
+					}},
+				},
+				Status: corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+			agentStatus := "False"
+			if agentConnected {
+				agentStatus = "True"
+			}
+			Expect(unstructured.SetNestedSlice(vmi.Object, []interface{}{
+				map[string]interface{}{"type": "AgentConnected", "status": agentStatus},
+			}, "status", "conditions")).To(Succeed())
+
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm, vmi, pod).
+				Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme, CloudInit: source}
+		}
+
+		updateStatus := func(r *VirtualMachineReconciler) *llmcloudv1alpha1.VirtualMachine {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(r.updateVMStatusFromVMI(ctx, vm)).To(Succeed())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			return vm
+		}
+
+		It("should mark cloud-init complete when the guest reports it is done", func() {
+			vm := updateStatus(newCloudInitReconciler(true, stubCloudInit("done")))
+
+			Expect(vm.Status.CloudInitComplete).To(BeTrue())
+			cond := meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal("Succeeded"))
+		})
+
+		It("should report a cloud-init failure", func() {
+			vm := updateStatus(newCloudInitReconciler(true, stubCloudInit("error")))
+
+			Expect(vm.Status.CloudInitComplete).To(BeFalse())
+			cond := meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal("Failed"))
+		})
+
+		It("should not check cloud-init before the guest agent is connected", func() {
+			vm := updateStatus(newCloudInitReconciler(false, stubCloudInit("done")))
+
+			Expect(vm.Status.CloudInitComplete).To(BeFalse())
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition)).To(BeNil())
+		})
+
+		It("should keep a final result once it has been recorded", func() {
+			r := newCloudInitReconciler(true, stubCloudInit("done"))
+			updateStatus(r)
+
+			r.CloudInit = stubCloudInit("running")
+			vm := updateStatus(r)
+			Expect(vm.Status.CloudInitComplete).To(BeTrue())
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition).Reason).To(Equal("Succeeded"))
+		})
+
+		It("should report cloud-init as running until it leaves its result", func() {
+			vm := updateStatus(newCloudInitReconciler(true, stubCloudInit("running")))
+
+			Expect(vm.Status.CloudInitComplete).To(BeFalse())
+			Expect(meta.FindStatusCondition(vm.Status.Conditions, vmCloudInitCondition).Reason).To(Equal("Running"))
+		})
+
+		It("should fall back to the reported status when the agent cannot read files", func() {
+			r := newCloudInitReconciler(true, &GuestAgentCloudInitStatus{
+				Exec: func(context.Context, string, string, []string) ([]byte, error) {
+					return nil, fmt.Errorf("error: Guest agent command failed: command guest-file-open has been disabled")
+				},
+			})
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			vm.Annotations = map[string]string{llmcloudv1alpha1.CloudInitStatusAnnotation: "done"}
+			Expect(r.Update(ctx, vm)).To(Succeed())
+
+			vm = updateStatus(r)
+			Expect(vm.Status.CloudInitComplete).To(BeTrue())
+		})
+	})
+
+	Context("When recording guest agent info", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "guest-vm", Namespace: "default"}
+
+		newGuestReconciler := func(agentConnected bool, source GuestAgentSource) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			}
+			vmi := &unstructured.Unstructured{}
+			vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+			vmi.SetName(key.Name)
+			vmi.SetNamespace(key.Namespace)
+			agentStatus := "False"
+			if agentConnected {
+				agentStatus = "True"
+			}
+			Expect(unstructured.SetNestedMap(vmi.Object, map[string]interface{}{
+				"phase": "Running",
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "AgentConnected", "status": agentStatus},
+				},
+				"guestOSInfo": map[string]interface{}{"name": "Ubuntu", "prettyName": "Ubuntu 22.04.3 LTS"},
+				"interfaces": []interface{}{
+					map[string]interface{}{
+						"name": "default", "interfaceName": "eth0", "mac": "02:00:00:00:00:01",
+						"ipAddress": "10.0.2.2", "ipAddresses": []interface{}{"10.0.2.2", "fd10:0:2::2"},
+					},
+					map[string]interface{}{"interfaceName": "docker0", "ipAddresses": []interface{}{"172.17.0.1"}},
+				},
+			}, "status")).To(Succeed())
+
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm, vmi).
+				Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme, GuestAgent: source}
+		}
+
+		updateStatus := func(r *VirtualMachineReconciler) *llmcloudv1alpha1.VirtualMachine {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(r.updateVMStatusFromVMI(ctx, vm)).To(Succeed())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			return vm
+		}
+
+		It("should record every interface with all of its addresses", func() {
+			vm := updateStatus(newGuestReconciler(true, nil))
+
+			Expect(vm.Status.IPAddress).To(Equal("10.0.2.2"))
+			Expect(vm.Status.Interfaces).To(Equal([]llmcloudv1alpha1.VMInterface{
+				{Name: "default", InterfaceName: "eth0", MAC: "02:00:00:00:00:01", IPAddresses: []string{"10.0.2.2", "fd10:0:2::2"}},
+				{InterfaceName: "docker0", IPAddresses: []string{"172.17.0.1"}},
+			}))
+			Expect(vm.Status.Guest).NotTo(BeNil())
+			Expect(vm.Status.Guest.OS.PrettyName).To(Equal("Ubuntu 22.04.3 LTS"))
+			Expect(vm.Status.Guest.UpdatedAt).To(BeNil())
+		})
+
+		It("should record what the guest agent reports", func() {
+			agent := &stubGuestAgent{info: llmcloudv1alpha1.GuestInfo{
+				Hostname:    "web-1",
+				Filesystems: []llmcloudv1alpha1.GuestFilesystem{{MountPoint: "/", UsedBytes: 1 << 30, TotalBytes: 10 << 30}},
+				Users:       []llmcloudv1alpha1.GuestUser{{Name: "ubuntu"}},
+			}}
+			vm := updateStatus(newGuestReconciler(true, agent))
+
+			Expect(vm.Status.Guest.Hostname).To(Equal("web-1"))
+			Expect(vm.Status.Guest.Filesystems).To(HaveLen(1))
+			Expect(vm.Status.Guest.Users[0].Name).To(Equal("ubuntu"))
+			Expect(vm.Status.Guest.OS.Name).To(Equal("Ubuntu"))
+			Expect(vm.Status.Guest.UpdatedAt).NotTo(BeNil())
+		})
+
+		It("should not ask the guest agent again until the info is stale", func() {
+			agent := &stubGuestAgent{info: llmcloudv1alpha1.GuestInfo{Hostname: "web-1"}}
+			r := newGuestReconciler(true, agent)
+			updateStatus(r)
+			updateStatus(r)
+			Expect(agent.calls).To(Equal(1))
+
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			vm.Status.Guest.UpdatedAt = &metav1.Time{Time: time.Now().Add(-2 * guestInfoRefreshInterval)}
+			Expect(r.Status().Update(ctx, vm)).To(Succeed())
+			agent.err = fmt.Errorf("guest agent did not answer")
+			vm = updateStatus(r)
+			Expect(agent.calls).To(Equal(2))
+			Expect(vm.Status.Guest.Hostname).To(Equal("web-1"))
+		})
+
+		It("should clear the guest info while the guest agent is disconnected", func() {
+			agent := &stubGuestAgent{info: llmcloudv1alpha1.GuestInfo{Hostname: "web-1"}}
+			vm := updateStatus(newGuestReconciler(false, agent))
+
+			Expect(vm.Status.Guest).To(BeNil())
+			Expect(agent.calls).To(BeZero())
+			Expect(vm.Status.Interfaces).To(HaveLen(2))
+		})
+
+		It("should read the guestosinfo subresource from KubeVirt", func() {
+			var requested string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = r.URL.Path
+				_, _ = w.Write([]byte(`{"hostname": "web-1", "os": {"name": "Ubuntu", "machine": "x86_64"},
+					"timezone": "UTC, 0",
+					"userList": [{"userName": "ubuntu", "loginTime": 1748770200.5}],
+					"fsInfo": {"disks": [{"diskName": "vda1", "mountPoint": "/", "fileSystemType": "ext4",
+						"usedBytes": 1073741824, "totalBytes": 10737418240}]}}`))
+			}))
+			defer server.Close()
+
+			agent, err := NewKubeVirtGuestAgent(&rest.Config{Host: server.URL})
+			Expect(err).NotTo(HaveOccurred())
+			vm := &llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "project-ml"}}
+			info, err := agent.GuestInfo(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(requested).To(Equal("/apis/subresources.kubevirt.io/v1/namespaces/project-ml/virtualmachineinstances/web/guestosinfo"))
+			Expect(info.Hostname).To(Equal("web-1"))
+			Expect(info.OS.Machine).To(Equal("x86_64"))
+			Expect(info.Filesystems).To(Equal([]llmcloudv1alpha1.GuestFilesystem{
+				{MountPoint: "/", Disk: "vda1", Type: "ext4", UsedBytes: 1 << 30, TotalBytes: 10 << 30},
+			}))
+			Expect(info.Users).To(HaveLen(1))
+			Expect(info.Users[0].LoginTime.Unix()).To(Equal(int64(1748770200)))
+		})
+
+		It("should fail when KubeVirt cannot reach the guest agent", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "guest agent is not connected", http.StatusConflict)
+			}))
+			defer server.Close()
+
+			agent, err := NewKubeVirtGuestAgent(&rest.Config{Host: server.URL})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = agent.GuestInfo(ctx, &llmcloudv1alpha1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "web"}})
+			Expect(err).To(MatchError(ContainSubstring("guest agent is not connected")))
+		})
+	})
+
+	Context("When recording the launcher pod", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "launcher-vm", Namespace: "default"}
+
+		newLauncherReconciler := func(pods ...*corev1.Pod) *VirtualMachineReconciler {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			}
+			vmi := &unstructured.Unstructured{}
+			vmi.SetGroupVersionKind(schema.GroupVersionKind{Group: "kubevirt.io", Version: "v1", Kind: "VirtualMachineInstance"})
+			vmi.SetName(key.Name)
+			vmi.SetNamespace(key.Namespace)
+			vmi.SetUID("vmi-uid")
+			Expect(unstructured.SetNestedField(vmi.Object, "Running", "status", "phase")).To(Succeed())
+
+			objs := []client.Object{vm, vmi}
+			for _, pod := range pods {
+				objs = append(objs, pod)
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(objs...).
+				Build()
+			return &VirtualMachineReconciler{Client: c, Scheme: testScheme}
+		}
+
+		launcherPod := func(name string, ownerUID types.UID, phase corev1.PodPhase) *corev1.Pod {
+			controller := true
+			return &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: key.Namespace,
+					Labels:    map[string]string{"vm.kubevirt.io/name": key.Name},
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "kubevirt.io/v1",
+						Kind:       "VirtualMachineInstance",
+						Name:       key.Name,
+						UID:        ownerUID,
+						Controller: &controller,
+					}},
+				},
+				Status: corev1.PodStatus{Phase: phase},
+			}
+		}
+
+		updateStatus := func(r *VirtualMachineReconciler) *llmcloudv1alpha1.VirtualMachine {
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(r.updateVMStatusFromVMI(ctx, vm)).To(Succeed())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			return vm
+		}
+
+		It("should record the pod owned by the VMI", func() {
+			vm := updateStatus(newLauncherReconciler(
+				launcherPod("virt-launcher-launcher-vm-abcde", "vmi-uid", corev1.PodRunning),
+				launcherPod("virt-launcher-launcher-vm-old12", "previous-vmi-uid", corev1.PodRunning),
+			))
+			Expect(vm.Status.LauncherPod).To(Equal("virt-launcher-launcher-vm-abcde"))
+		})
+
+		It("should prefer the running pod during a migration", func() {
+			vm := updateStatus(newLauncherReconciler(
+				launcherPod("virt-launcher-launcher-vm-target", "vmi-uid", corev1.PodPending),
+				launcherPod("virt-launcher-launcher-vm-source", "vmi-uid", corev1.PodRunning),
+			))
+			Expect(vm.Status.LauncherPod).To(Equal("virt-launcher-launcher-vm-source"))
+		})
+
+		It("should leave the launcher pod empty when there is none", func() {
+			vm := updateStatus(newLauncherReconciler())
+			Expect(vm.Status.LauncherPod).To(BeEmpty())
+		})
+	})
+
+	Context("When resizing disks", func() {
+		ctx := context.Background()
+
+		newPVC := func(name, size string) *corev1.PersistentVolumeClaim {
+			return &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+					},
+				},
+			}
+		}
+
+		It("should expand PVCs smaller than the disk sizes in the spec", func() {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS:       "ubuntu",
+					DiskSize: "40Gi",
+					AdditionalDisks: []llmcloudv1alpha1.VMDisk{
+						{Name: "data", Size: "100Gi"},
+						{Name: "new", Size: "10Gi"},
+					},
+				},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithObjects(vm, newPVC("db-disk", "20Gi"), newPVC("db-data", "100Gi")).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &VirtualMachineReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+
+			Expect(r.expandDisks(ctx, vm)).To(Succeed())
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(c.Get(ctx, types.NamespacedName{Name: "db-disk", Namespace: "default"}, pvc)).To(Succeed())
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("40Gi"))
+			Expect(c.Get(ctx, types.NamespacedName{Name: "db-data", Namespace: "default"}, pvc)).To(Succeed())
+			Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("100Gi"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Expanding disk db-disk from 20Gi to 40Gi")))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+	})
+
+	Context("When suspending idle VMs", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "idle-vm", Namespace: "default"}
+
+		newIdleReconciler := func(active bool, lastActive time.Time) (*VirtualMachineReconciler, *record.FakeRecorder) {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+
+			activeTime := metav1.NewTime(lastActive)
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS: "ubuntu", CPUs: 1, Memory: "1Gi", DiskSize: "10Gi",
+					RunStrategy:      "Always",
+					SuspendAfterIdle: &metav1.Duration{Duration: time.Hour},
+				},
+				Status: llmcloudv1alpha1.VirtualMachineStatus{Ready: true, LastActiveTime: &activeTime},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			return &VirtualMachineReconciler{
+				Client:   c,
+				Scheme:   testScheme,
+				Activity: stubActivity(active),
+				Recorder: recorder,
+			}, recorder
+		}
+
+		It("should decide from the last activity whether the idle period has passed", func() {
+			now := time.Now()
+			suspend, wait := idleTiming(now.Add(-2*time.Hour), now, time.Hour)
+			Expect(suspend).To(BeTrue())
+			Expect(wait).To(BeZero())
+
+			suspend, wait = idleTiming(now.Add(-58*time.Minute), now, time.Hour)
+			Expect(suspend).To(BeFalse())
+			Expect(wait).To(Equal(2 * time.Minute))
+
+			suspend, wait = idleTiming(now, now, time.Hour)
+			Expect(suspend).To(BeFalse())
+			Expect(wait).To(Equal(idleCheckInterval))
+		})
+
+		It("should halt a VM that has been idle for longer than the period", func() {
+			r, recorder := newIdleReconciler(false, time.Now().Add(-2*time.Hour))
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+
+			_, err := r.reconcileIdleSuspend(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Halted"))
+			cond := meta.FindStatusCondition(vm.Status.Conditions, vmSuspendedCondition)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal("Idle"))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Suspended")))
+		})
+
+		It("should keep an active VM running and record its activity", func() {
+			lastActive := time.Now().Add(-2 * time.Hour)
+			r, recorder := newIdleReconciler(true, lastActive)
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+
+			_, err := r.reconcileIdleSuspend(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Always"))
+			Expect(vm.Status.LastActiveTime.Time).To(BeTemporally(">", lastActive))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should not halt an idle VM before the period has passed", func() {
+			r, _ := newIdleReconciler(false, time.Now().Add(-30*time.Minute))
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+
+			wait, err := r.reconcileIdleSuspend(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(Equal(idleCheckInterval))
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Always"))
+		})
+
+		It("should start a fresh idle period once a suspended VM is started again", func() {
+			r, _ := newIdleReconciler(false, time.Now().Add(-2*time.Hour))
+			vm := &llmcloudv1alpha1.VirtualMachine{}
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			meta.SetStatusCondition(&vm.Status.Conditions, metav1.Condition{
+				Type: vmSuspendedCondition, Status: metav1.ConditionTrue, Reason: "Idle",
+			})
+			Expect(r.Status().Update(ctx, vm)).To(Succeed())
+
+			_, err := r.reconcileIdleSuspend(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Always"))
+			Expect(meta.IsStatusConditionTrue(vm.Status.Conditions, vmSuspendedCondition)).To(BeFalse())
+			Expect(time.Since(vm.Status.LastActiveTime.Time)).To(BeNumerically("<", time.Minute))
+		})
+	})
+
+	Context("When applying schedule policies", func() {
+		ctx := context.Background()
+		key := types.NamespacedName{Name: "scheduled-vm", Namespace: "default"}
+		policy := &llmcloudv1alpha1.VMSchedulePolicy{Start: "0 8 * * *", Stop: "0 20 * * *", TimeZone: "Europe/Berlin"}
+
+		It("should pick the latest firing in the policy's time zone", func() {
+			berlin, err := time.LoadLocation("Europe/Berlin")
+			Expect(err).NotTo(HaveOccurred())
+			day := time.Date(2025, time.June, 2, 0, 0, 0, 0, berlin)
+
+			runStrategy, wait, err := scheduleTiming(policy, day.Add(7*time.Hour), day.Add(9*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runStrategy).To(Equal("Always"))
+			Expect(wait).To(Equal(11 * time.Hour))
+
+			runStrategy, wait, err = scheduleTiming(policy, day.Add(9*time.Hour), day.Add(12*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runStrategy).To(BeEmpty())
+			Expect(wait).To(Equal(8 * time.Hour))
+
+			// Both fired while the operator was down; the stop was the latest
+			runStrategy, _, err = scheduleTiming(policy, day.Add(7*time.Hour), day.Add(21*time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runStrategy).To(Equal("Halted"))
+
+			_, _, err = scheduleTiming(&llmcloudv1alpha1.VMSchedulePolicy{Stop: "nightly"}, day, day)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("should stop a VM when its stop schedule fired and record when", func() {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			lastSchedule := metav1.NewTime(time.Now().Add(-25 * time.Hour))
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS: "ubuntu", CPUs: 1, Memory: "1Gi", DiskSize: "10Gi",
+					RunStrategy:    "Always",
+					SchedulePolicy: &llmcloudv1alpha1.VMSchedulePolicy{Stop: "0 20 * * *"},
+				},
+				Status: llmcloudv1alpha1.VirtualMachineStatus{LastScheduleTime: &lastSchedule},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &VirtualMachineReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			wait, err := r.reconcileSchedulePolicy(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeNumerically(">", 0))
+			Expect(wait).To(BeNumerically("<=", 24*time.Hour))
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Halted"))
+			Expect(time.Since(vm.Status.LastScheduleTime.Time)).To(BeNumerically("<", time.Minute))
+			Expect(recorder.Events).To(Receive(ContainSubstring("Stopped on schedule")))
+
+			// A VM started again by hand stays running until the schedule fires again
+			vm.Spec.RunStrategy = "Always"
+			Expect(r.Update(ctx, vm)).To(Succeed())
+			_, err = r.reconcileSchedulePolicy(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Always"))
+		})
+
+		It("should not replay past firings when a policy is attached to an old VM", func() {
+			testScheme := runtime.NewScheme()
+			Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+			vm := &llmcloudv1alpha1.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: key.Name, Namespace: key.Namespace,
+					CreationTimestamp: metav1.NewTime(time.Now().Add(-30 * 24 * time.Hour)),
+				},
+				Spec: llmcloudv1alpha1.VirtualMachineSpec{
+					OS: "ubuntu", CPUs: 1, Memory: "1Gi", DiskSize: "10Gi",
+					RunStrategy:    "Always",
+					SchedulePolicy: &llmcloudv1alpha1.VMSchedulePolicy{Stop: "0 20 * * *"},
+				},
+			}
+			c := fake.NewClientBuilder().
+				WithScheme(testScheme).
+				WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}).
+				WithObjects(vm).
+				Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &VirtualMachineReconciler{Client: c, Scheme: testScheme, Recorder: recorder}
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			wait, err := r.reconcileSchedulePolicy(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeNumerically(">", 0))
+			Expect(wait).To(BeNumerically("<=", 24*time.Hour))
+
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Spec.RunStrategy).To(Equal("Always"))
+			Expect(vm.Status.LastScheduleTime).NotTo(BeNil())
+			Expect(recorder.Events).NotTo(Receive())
+
+			// Detaching the policy forgets when it was last applied
+			vm.Spec.SchedulePolicy = nil
+			Expect(r.Update(ctx, vm)).To(Succeed())
+			_, err = r.reconcileSchedulePolicy(ctx, vm)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Get(ctx, key, vm)).To(Succeed())
+			Expect(vm.Status.LastScheduleTime).To(BeNil())
+		})
+	})
 })
+
+// stubActivity reports a fixed activity state for every VM
+type stubActivity bool
+
+func (a stubActivity) IsActive(context.Context, *llmcloudv1alpha1.VirtualMachine) (bool, error) {
+	return bool(a), nil
+}
+
+// stubCloudInit reads the cloud-init status through a stubbed guest agent, which answers
+// "virsh qemu-agent-command" like the agent of a guest where cloud-init is done, failed
+// or still running, and so has no result yet
+func stubCloudInit(status string) *GuestAgentCloudInitStatus {
+	files := map[string]string{}
+	switch status {
+	case "done":
+		files[cloudInitResultPath] = `{"v1": {"datasource": "DataSourceNoCloud", "errors": []}}`
+	case "error":
+		files[cloudInitResultPath] = `{"v1": {"datasource": "DataSourceNoCloud", "errors": ["('scripts_user', RuntimeError('Runparts: 1 failures'))"]}}`
+	}
+	return &GuestAgentCloudInitStatus{
+		Exec: func(_ context.Context, namespace, pod string, command []string) ([]byte, error) {
+			Expect(pod).To(Equal("virt-launcher-cloudinit-vm-abcde"))
+			Expect(command[:3]).To(Equal([]string{"virsh", "qemu-agent-command", namespace + "_cloudinit-vm"}))
+			var req struct {
+				Execute   string `json:"execute"`
+				Arguments struct {
+					Path   string `json:"path"`
+					Handle int    `json:"handle"`
+				} `json:"arguments"`
+			}
+			Expect(json.Unmarshal([]byte(command[3]), &req)).To(Succeed())
+
+			switch req.Execute {
+			case "guest-file-open":
+				if _, ok := files[req.Arguments.Path]; !ok {
+					return nil, fmt.Errorf("error: internal error: unable to execute QEMU agent command 'guest-file-open': "+
+						"failed to open file '%s' (mode: 'r'): No such file or directory", req.Arguments.Path)
+				}
+				return []byte(`{"return": 1000}`), nil
+			case "guest-file-read":
+				Expect(req.Arguments.Handle).To(Equal(1000))
+				content := base64.StdEncoding.EncodeToString([]byte(files[cloudInitResultPath]))
+				return []byte(`{"return": {"count": 1, "buf-b64": "` + content + `", "eof": true}}`), nil
+			case "guest-file-close":
+				return []byte(`{"return": {}}`), nil
+			}
+			return nil, fmt.Errorf("unexpected guest agent command %s", req.Execute)
+		},
+	}
+}
+
+// stubGuestAgent reports fixed guest info, or err, and counts how often it is asked
+type stubGuestAgent struct {
+	info  llmcloudv1alpha1.GuestInfo
+	err   error
+	calls int
+}
+
+func (a *stubGuestAgent) GuestInfo(context.Context, *llmcloudv1alpha1.VirtualMachine) (*llmcloudv1alpha1.GuestInfo, error) {
+	a.calls++
+	if a.err != nil {
+		return nil, a.err
+	}
+	return a.info.DeepCopy(), nil
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+	"github.com/rusik69/llmcloud-operator/internal/metrics"
+)
+
+// DefaultVMImageNamespace holds the volumes of uploaded VM images unless configured otherwise
+const DefaultVMImageNamespace = "llmcloud-images"
+
+// vmImageLabel marks the DataVolume of an uploaded image with the image's name
+const vmImageLabel = "llmcloud.io/image"
+
+var dataVolumeGVK = schema.GroupVersionKind{Group: "cdi.kubevirt.io", Version: "v1beta1", Kind: "DataVolume"}
+
+// VMImageReconciler reconciles a VMImage object. Container disk images are ready as
+// soon as they are registered; uploaded images get a CDI DataVolume waiting for the
+// upload, whose progress is reported in the image's status
+type VMImageReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Namespace holds the DataVolumes of uploaded images, defaulting to DefaultVMImageNamespace
+	Namespace string
+}
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=vmimages,verbs=get;list;watch
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=vmimages/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cdi.kubevirt.io,resources=datavolumes,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch;create
+
+// Reconcile creates the DataVolume of an uploaded image and reports its phase
+func (r *VMImageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	image := &llmcloudv1alpha1.VMImage{}
+	if err := r.Get(ctx, req.NamespacedName, image); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := llmcloudv1alpha1.VMImageStatus{Phase: llmcloudv1alpha1.VMImagePhaseReady}
+	if image.Spec.Upload != nil {
+		dv, err := r.reconcileDataVolume(ctx, image)
+		if err != nil {
+			log.Error(err, "Failed to reconcile the DataVolume of the image")
+			return ctrl.Result{}, err
+		}
+		status = dataVolumeStatus(dv)
+	}
+
+	if equalVMImageStatus(&status, &image.Status) {
+		return ctrl.Result{}, nil
+	}
+	image.Status = status
+	return ctrl.Result{}, r.Status().Update(ctx, image)
+}
+
+// reconcileDataVolume returns the DataVolume the image is uploaded to, creating it and
+// its namespace when missing. It is owned by the image, so deleting the image deletes
+// the uploaded disk; the disks of VMs cloned from it are kept
+func (r *VMImageReconciler) reconcileDataVolume(ctx context.Context, image *llmcloudv1alpha1.VMImage) (*unstructured.Unstructured, error) {
+	namespace := cmp.Or(r.Namespace, DefaultVMImageNamespace)
+	dv := &unstructured.Unstructured{}
+	dv.SetGroupVersionKind(dataVolumeGVK)
+	err := r.Get(ctx, client.ObjectKey{Namespace: namespace, Name: image.Name}, dv)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return dv, err
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   namespace,
+		Labels: map[string]string{"llmcloud.io/managed": "true"},
+	}}
+	if err := client.IgnoreAlreadyExists(r.Create(ctx, ns)); err != nil {
+		return nil, err
+	}
+
+	dv = uploadDataVolume(image, namespace)
+	if err := controllerutil.SetControllerReference(image, dv, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, dv); err != nil {
+		return nil, err
+	}
+	return dv, nil
+}
+
+// uploadDataVolume returns a DataVolume waiting for the upload of image through the CDI
+// upload proxy, which converts qcow2 images to raw
+func uploadDataVolume(image *llmcloudv1alpha1.VMImage, namespace string) *unstructured.Unstructured {
+	storageClass := cmp.Or(image.Spec.Upload.StorageClass, llmcloudv1alpha1.RuntimeConfig().DefaultStorageClass,
+		llmcloudv1alpha1.DefaultStorageClass)
+	dv := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"source": map[string]interface{}{
+				"upload": map[string]interface{}{},
+			},
+			"storage": map[string]interface{}{
+				"accessModes": []interface{}{"ReadWriteOnce"},
+				"resources": map[string]interface{}{
+					"requests": map[string]interface{}{
+						"storage": image.Spec.Upload.Size,
+					},
+				},
+				"storageClassName": storageClass,
+			},
+		},
+	}}
+	dv.SetGroupVersionKind(dataVolumeGVK)
+	dv.SetName(image.Name)
+	dv.SetNamespace(namespace)
+	dv.SetLabels(map[string]string{"llmcloud.io/managed": "true", vmImageLabel: image.Name})
+	return dv
+}
+
+// dataVolumeStatus returns the status of an uploaded image whose DataVolume is dv
+func dataVolumeStatus(dv *unstructured.Unstructured) llmcloudv1alpha1.VMImageStatus {
+	phase, _, _ := unstructured.NestedString(dv.Object, "status", "phase")
+	progress, _, _ := unstructured.NestedString(dv.Object, "status", "progress")
+	status := llmcloudv1alpha1.VMImageStatus{
+		Phase:    llmcloudv1alpha1.VMImagePhasePending,
+		Progress: progress,
+		Volume:   &llmcloudv1alpha1.VMImageVolume{Namespace: dv.GetNamespace(), Name: dv.GetName()},
+	}
+	switch phase {
+	case "UploadReady":
+		status.Phase = llmcloudv1alpha1.VMImagePhaseUploadReady
+	case "Succeeded":
+		status.Phase = llmcloudv1alpha1.VMImagePhaseReady
+	case "Failed":
+		status.Phase = llmcloudv1alpha1.VMImagePhaseFailed
+		conditions, _, _ := unstructured.NestedSlice(dv.Object, "status", "conditions")
+		for _, c := range conditions {
+			if condition, ok := c.(map[string]interface{}); ok && condition["type"] == "Running" {
+				status.Message, _ = condition["message"].(string)
+			}
+		}
+	}
+	return status
+}
+
+// equalVMImageStatus reports whether a and b report the same state
+func equalVMImageStatus(a, b *llmcloudv1alpha1.VMImageStatus) bool {
+	if a.Phase != b.Phase || a.Progress != b.Progress || a.Message != b.Message {
+		return false
+	}
+	if a.Volume == nil || b.Volume == nil {
+		return a.Volume == b.Volume
+	}
+	return *a.Volume == *b.Volume
+}
+
+// SetupWithManager sets up the controller with the Manager. Without CDI installed there
+// are no DataVolumes to watch, and uploaded images are left to fail reconciling
+func (r *VMImageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).For(&llmcloudv1alpha1.VMImage{})
+	if _, err := mgr.GetRESTMapper().RESTMapping(dataVolumeGVK.GroupKind(), dataVolumeGVK.Version); err == nil {
+		dv := &unstructured.Unstructured{}
+		dv.SetGroupVersionKind(dataVolumeGVK)
+		b = b.Owns(dv)
+	} else if meta.IsNoMatchError(err) {
+		mgr.GetLogger().Info("CDI is not installed, not watching DataVolumes of uploaded images")
+	} else {
+		return err
+	}
+	return b.Named("vmimage").
+		Complete(metrics.Reconciler("VMImage", r))
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var _ = Describe("VMImage Controller", func() {
+	var (
+		c   client.Client
+		r   *VMImageReconciler
+		ctx = context.Background()
+	)
+
+	setup := func(objs ...client.Object) {
+		testScheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(testScheme)).To(Succeed())
+		Expect(llmcloudv1alpha1.AddToScheme(testScheme)).To(Succeed())
+		c = fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).
+			WithStatusSubresource(&llmcloudv1alpha1.VMImage{}).Build()
+		r = &VMImageReconciler{Client: c, Scheme: testScheme, Namespace: "images"}
+	}
+	reconcileImage := func(name string) *llmcloudv1alpha1.VMImage {
+		key := types.NamespacedName{Name: name}
+		_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		image := &llmcloudv1alpha1.VMImage{}
+		Expect(c.Get(ctx, key, image)).To(Succeed())
+		return image
+	}
+	getDataVolume := func(name string) *unstructured.Unstructured {
+		dv := &unstructured.Unstructured{}
+		dv.SetGroupVersionKind(dataVolumeGVK)
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: "images", Name: name}, dv)).To(Succeed())
+		return dv
+	}
+
+	It("should mark container disk images ready", func() {
+		setup(&llmcloudv1alpha1.VMImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "noble"},
+			Spec:       llmcloudv1alpha1.VMImageSpec{ContainerDisk: "registry.local/ubuntu:24.04"},
+		})
+
+		image := reconcileImage("noble")
+		Expect(image.Status.Phase).To(Equal(llmcloudv1alpha1.VMImagePhaseReady))
+		Expect(image.Status.Volume).To(BeNil())
+		Expect(image.Ready()).To(BeTrue())
+	})
+
+	It("should create a DataVolume waiting for the upload and report its progress", func() {
+		setup(&llmcloudv1alpha1.VMImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "windows"},
+			Spec:       llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "40Gi", StorageClass: "fast"}},
+		})
+
+		image := reconcileImage("windows")
+		Expect(image.Status.Phase).To(Equal(llmcloudv1alpha1.VMImagePhasePending))
+		Expect(image.Status.Volume).To(Equal(&llmcloudv1alpha1.VMImageVolume{Namespace: "images", Name: "windows"}))
+		Expect(image.Ready()).To(BeFalse())
+		Expect(c.Get(ctx, types.NamespacedName{Name: "images"}, &corev1.Namespace{})).To(Succeed())
+
+		dv := getDataVolume("windows")
+		Expect(dv.GetOwnerReferences()).To(HaveLen(1))
+		Expect(dv.GetOwnerReferences()[0].Name).To(Equal("windows"))
+		_, upload, _ := unstructured.NestedMap(dv.Object, "spec", "source", "upload")
+		Expect(upload).To(BeTrue())
+		size, _, _ := unstructured.NestedString(dv.Object, "spec", "storage", "resources", "requests", "storage")
+		Expect(size).To(Equal("40Gi"))
+		class, _, _ := unstructured.NestedString(dv.Object, "spec", "storage", "storageClassName")
+		Expect(class).To(Equal("fast"))
+
+		Expect(unstructured.SetNestedField(dv.Object, "UploadReady", "status", "phase")).To(Succeed())
+		Expect(c.Update(ctx, dv)).To(Succeed())
+		Expect(reconcileImage("windows").Status.Phase).To(Equal(llmcloudv1alpha1.VMImagePhaseUploadReady))
+
+		dv = getDataVolume("windows")
+		Expect(unstructured.SetNestedField(dv.Object, "Succeeded", "status", "phase")).To(Succeed())
+		Expect(unstructured.SetNestedField(dv.Object, "100.0%", "status", "progress")).To(Succeed())
+		Expect(c.Update(ctx, dv)).To(Succeed())
+		image = reconcileImage("windows")
+		Expect(image.Status.Phase).To(Equal(llmcloudv1alpha1.VMImagePhaseReady))
+		Expect(image.Status.Progress).To(Equal("100.0%"))
+		Expect(image.Ready()).To(BeTrue())
+	})
+
+	It("should report why an upload failed", func() {
+		dv := uploadDataVolume(&llmcloudv1alpha1.VMImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "broken"},
+			Spec:       llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "1Gi"}},
+		}, "images")
+		dv.Object["status"] = map[string]interface{}{
+			"phase": "Failed",
+			"conditions": []interface{}{
+				map[string]interface{}{"type": "Bound", "message": "PVC bound"},
+				map[string]interface{}{"type": "Running", "message": "Unable to process data: invalid image"},
+			},
+		}
+
+		status := dataVolumeStatus(dv)
+		Expect(status.Phase).To(Equal(llmcloudv1alpha1.VMImagePhaseFailed))
+		Expect(status.Message).To(Equal("Unable to process data: invalid image"))
+	})
+})
@@ -0,0 +1,300 @@
+// Package kubeops applies, patches and deletes objects by resource name or from
+// manifests, like kubectl does, with client-go instead of a kubectl binary
+package kubeops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// fieldManager owns the fields set by Apply
+const fieldManager = "llmcloud-deploy"
+
+// Client talks to one cluster
+type Client struct {
+	// Clientset is for the built-in types
+	Clientset kubernetes.Interface
+
+	dynamic dynamic.Interface
+	mapper  meta.RESTMapper
+	// reset forgets the discovered resources, so that new CRDs are found
+	reset func()
+}
+
+// NewFromKubeconfig returns a client for the cluster of the kubeconfig file
+func NewFromKubeconfig(path string) (*Client, error) {
+	config, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return New(config)
+}
+
+// New returns a client for the cluster of config
+func New(config *rest.Config) (*Client, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create clientset: %w", err)
+	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	return &Client{Clientset: clientset, dynamic: dynamicClient, mapper: mapper, reset: mapper.Reset}, nil
+}
+
+// mapping finds the resource of a kind, discovering the API again once if the kind
+// is unknown, as its CRD may have just been created
+func (c *Client) mapping(gvk schema.GroupVersionKind) (*meta.RESTMapping, error) {
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) && c.reset != nil {
+		c.reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
+
+// resource resolves a resource name as kubectl takes it: "pods", "pod" or
+// "services.llmcloud.llmcloud.io". An unknown resource returns a NoMatch error, which
+// meta.IsNoMatchError recognizes
+func (c *Client) resource(name string) (schema.GroupVersionResource, bool, error) {
+	resolve := func() (schema.GroupVersionResource, error) {
+		fullySpecified, groupResource := schema.ParseResourceArg(strings.ToLower(name))
+		if fullySpecified != nil {
+			if gvr, err := c.mapper.ResourceFor(*fullySpecified); err == nil {
+				return gvr, nil
+			}
+		}
+		return c.mapper.ResourceFor(groupResource.WithVersion(""))
+	}
+	gvr, err := resolve()
+	if meta.IsNoMatchError(err) && c.reset != nil {
+		c.reset()
+		gvr, err = resolve()
+	}
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	gvk, err := c.mapper.KindFor(gvr)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return schema.GroupVersionResource{}, false, err
+	}
+	return gvr, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+// namespaced returns the client for the resource in namespace, or across all
+// namespaces when it is empty, ignoring namespace for cluster-scoped resources
+func (c *Client) namespaced(gvr schema.GroupVersionResource, isNamespaced bool, namespace string) dynamic.ResourceInterface {
+	if !isNamespaced {
+		return c.dynamic.Resource(gvr)
+	}
+	return c.dynamic.Resource(gvr).Namespace(namespace)
+}
+
+// Apply applies the manifest at source, a URL, a YAML file or a directory of YAML
+// files, with server-side apply
+func (c *Client) Apply(ctx context.Context, source string) error {
+	data, err := readManifests(ctx, source)
+	if err != nil {
+		return err
+	}
+	return c.ApplyYAML(ctx, data)
+}
+
+// ApplyYAML applies the objects of a multi-document YAML manifest with server-side
+// apply, taking over fields other managers set, like kubectl apply --server-side
+// --force-conflicts
+func (c *Client) ApplyYAML(ctx context.Context, data []byte) error {
+	objects, err := Decode(data)
+	if err != nil {
+		return err
+	}
+	return c.ApplyObjects(ctx, objects)
+}
+
+// ApplyObjects applies objects in order, like ApplyYAML
+func (c *Client) ApplyObjects(ctx context.Context, objects []*unstructured.Unstructured) error {
+	for _, obj := range objects {
+		if err := c.applyObject(ctx, obj); err != nil {
+			return fmt.Errorf("failed to apply %s %s: %w", obj.GetKind(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) applyObject(ctx context.Context, obj *unstructured.Unstructured) error {
+	mapping, err := c.mapping(obj.GroupVersionKind())
+	if err != nil {
+		return err
+	}
+	namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	if namespaced && obj.GetNamespace() == "" {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	_, err = c.namespaced(mapping.Resource, namespaced, obj.GetNamespace()).
+		Apply(ctx, obj.GetName(), obj, metav1.ApplyOptions{FieldManager: fieldManager, Force: true})
+	return err
+}
+
+// List returns the objects of a resource in namespace, or in all namespaces when it
+// is empty
+func (c *Client) List(ctx context.Context, resource, namespace string) ([]unstructured.Unstructured, error) {
+	gvr, isNamespaced, err := c.resource(resource)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.namespaced(gvr, isNamespaced, namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// Patch patches an object of a resource. A missing object is not an error
+func (c *Client) Patch(ctx context.Context, resource, namespace, name string, patchType types.PatchType, data []byte) error {
+	gvr, isNamespaced, err := c.resource(resource)
+	if err != nil {
+		return err
+	}
+	_, err = c.namespaced(gvr, isNamespaced, namespace).Patch(ctx, name, patchType, data, metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// Delete deletes an object of a resource. A missing object is not an error
+func (c *Client) Delete(ctx context.Context, resource, namespace, name string, opts metav1.DeleteOptions) error {
+	gvr, isNamespaced, err := c.resource(resource)
+	if err != nil {
+		return err
+	}
+	err = c.namespaced(gvr, isNamespaced, namespace).Delete(ctx, name, opts)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteAll deletes every object of a resource in namespace, or in all namespaces
+// when it is empty, without waiting for them to be gone
+func (c *Client) DeleteAll(ctx context.Context, resource, namespace string, opts metav1.DeleteOptions) error {
+	gvr, isNamespaced, err := c.resource(resource)
+	if err != nil {
+		return err
+	}
+	list, err := c.namespaced(gvr, isNamespaced, namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, obj := range list.Items {
+		err := c.namespaced(gvr, isNamespaced, obj.GetNamespace()).Delete(ctx, obj.GetName(), opts)
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readManifests reads the YAML at source, joining the .yaml, .yml and .json files of a
+// directory
+func readManifests(ctx context.Context, source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", source, err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to download %s: %s", source, resp.Status)
+		}
+		return io.ReadAll(resp.Body)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(source)
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, err
+	}
+	var manifests bytes.Buffer
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".yaml", ".yml", ".json":
+		default:
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(source, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		manifests.WriteString("\n---\n")
+		manifests.Write(data)
+	}
+	return manifests.Bytes(), nil
+}
+
+// Decode splits a multi-document YAML manifest into its objects, skipping empty
+// documents
+func Decode(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var content json.RawMessage
+		if err := decoder.Decode(&content); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		if trimmed := bytes.TrimSpace(content); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+			continue
+		}
+		// Decoding as unstructured keeps integers integers, where JSON makes them floats
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(content); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		if obj.GetName() == "" {
+			return nil, fmt.Errorf("invalid manifest: %s without a name", obj.GetKind())
+		}
+		objects = append(objects, obj)
+	}
+}
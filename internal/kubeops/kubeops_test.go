@@ -0,0 +1,147 @@
+package kubeops
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+var (
+	configMaps = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	namespaces = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	projects   = schema.GroupVersionResource{Group: "llmcloud.llmcloud.io", Version: "v1alpha1", Resource: "projects"}
+)
+
+func newTestClient(objects ...runtime.Object) *Client {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "llmcloud.llmcloud.io", Version: "v1alpha1", Kind: "Project"}, meta.RESTScopeRoot)
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		configMaps: "ConfigMapList",
+		namespaces: "NamespaceList",
+		projects:   "ProjectList",
+	}, objects...)
+	// The fake client only applies to existing objects; the API server creates missing ones
+	dynamicClient.PrependReactor("patch", "*", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		patch := action.(clienttesting.PatchAction)
+		if patch.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patch.GetPatch()); err != nil {
+			return true, nil, err
+		}
+		tracker := dynamicClient.Tracker()
+		if _, err := tracker.Get(patch.GetResource(), patch.GetNamespace(), patch.GetName()); apierrors.IsNotFound(err) {
+			return true, obj, tracker.Create(patch.GetResource(), obj, patch.GetNamespace())
+		}
+		return true, obj, tracker.Update(patch.GetResource(), obj, patch.GetNamespace())
+	})
+	return &Client{dynamic: dynamicClient, mapper: mapper}
+}
+
+func object(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestApply(t *testing.T) {
+	c := newTestClient()
+	dir := t.TempDir()
+	manifest := `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: kubevirt
+---
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  mode: fast
+`
+	if err := os.WriteFile(filepath.Join(dir, "manifest.yaml"), []byte(manifest), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := c.Apply(ctx, dir); err != nil {
+		t.Fatalf("Failed to apply: %v", err)
+	}
+	if _, err := c.dynamic.Resource(namespaces).Get(ctx, "kubevirt", metav1.GetOptions{}); err != nil {
+		t.Errorf("Expected the namespace to be created: %v", err)
+	}
+	cm, err := c.dynamic.Resource(configMaps).Namespace("default").Get(ctx, "settings", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the config map in the default namespace: %v", err)
+	}
+	if mode, _, _ := unstructured.NestedString(cm.Object, "data", "mode"); mode != "fast" {
+		t.Errorf("Expected the applied data, got %v", cm.Object)
+	}
+
+	if err := c.ApplyYAML(ctx, []byte("apiVersion: v1\nkind: Widget\nmetadata:\n  name: w\n")); !meta.IsNoMatchError(err) {
+		t.Errorf("Expected an unknown kind to fail with NoMatch, got %v", err)
+	}
+}
+
+func TestPatchAndDelete(t *testing.T) {
+	c := newTestClient(
+		object("v1", "ConfigMap", "local-path-storage", "local-path-config"),
+		object("llmcloud.llmcloud.io/v1alpha1", "Project", "", "a"),
+		object("llmcloud.llmcloud.io/v1alpha1", "Project", "", "b"),
+	)
+	ctx := context.Background()
+
+	err := c.Patch(ctx, "configmap", "local-path-storage", "local-path-config", types.MergePatchType,
+		[]byte(`{"data":{"config.json":"{}"}}`))
+	if err != nil {
+		t.Fatalf("Failed to patch: %v", err)
+	}
+	cm, _ := c.dynamic.Resource(configMaps).Namespace("local-path-storage").Get(ctx, "local-path-config", metav1.GetOptions{})
+	if data, _, _ := unstructured.NestedString(cm.Object, "data", "config.json"); data != "{}" {
+		t.Errorf("Expected the patched data, got %v", cm.Object)
+	}
+	if err := c.Patch(ctx, "configmaps", "default", "missing", types.MergePatchType, []byte(`{}`)); err != nil {
+		t.Errorf("Expected a missing object to be ignored, got %v", err)
+	}
+
+	items, err := c.List(ctx, "projects.llmcloud.llmcloud.io", "")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Expected 2 projects, got %d (%v)", len(items), err)
+	}
+	if err := c.DeleteAll(ctx, "projects.v1alpha1.llmcloud.llmcloud.io", "", metav1.DeleteOptions{}); err != nil {
+		t.Fatalf("Failed to delete projects: %v", err)
+	}
+	if items, _ := c.List(ctx, "projects", ""); len(items) != 0 {
+		t.Errorf("Expected no projects left, got %d", len(items))
+	}
+	if err := c.Delete(ctx, "project", "", "a", metav1.DeleteOptions{}); err != nil {
+		t.Errorf("Expected a missing object to be ignored, got %v", err)
+	}
+
+	if err := c.DeleteAll(ctx, "datavolumes", "", metav1.DeleteOptions{}); !meta.IsNoMatchError(err) {
+		t.Errorf("Expected an unknown resource to fail with NoMatch, got %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+// Package metering counts the requests and tokens served through the inference gateway
+// and adds them to the usage in the status of the LLMModels and of their Projects
+package metering
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var log = logf.Log.WithName("metering")
+
+// defaultFlushInterval is how often usage is written when FlushInterval is zero
+const defaultFlushInterval = time.Minute
+
+// flushTimeout bounds the final flush when the meter stops
+const flushTimeout = 10 * time.Second
+
+// +kubebuilder:rbac:groups=llmcloud.llmcloud.io,resources=llmmodels/status;projects/status,verbs=get;update;patch
+
+// usageKey identifies the usage of a model, or of a project when model is empty, on a day
+type usageKey struct {
+	namespace, model string
+	day              time.Time
+}
+
+// Meter collects the usage of the models in memory, and adds it to their statuses
+// every FlushInterval. Every API server replica meters the requests it proxies, so a
+// Meter runs without leader election
+type Meter struct {
+	Client client.Client
+
+	// FlushInterval is how often counted usage is written, a minute when zero
+	FlushInterval time.Duration
+
+	mu       sync.Mutex
+	pending  map[usageKey]llmcloudv1alpha1.UsageCounts
+	rollouts map[rolloutKey]rolloutCounts
+}
+
+// rolloutKey identifies the requests served by one side of a model's rollout to canary
+type rolloutKey struct {
+	namespace, model, canary string
+	toCanary                 bool
+}
+
+// rolloutCounts are the requests served by one side of a rollout, and how many failed
+type rolloutCounts struct {
+	requests, errors int64
+}
+
+// NeedLeaderElection reports that every replica writes the usage it counted
+func (m *Meter) NeedLeaderElection() bool {
+	return false
+}
+
+// Record counts usage of a model, and of its project when it is in a project's
+// namespace
+func (m *Meter) Record(namespace, model string, counts llmcloudv1alpha1.UsageCounts) {
+	day := today()
+	m.add(usageKey{namespace: namespace, model: model, day: day}, counts)
+	if strings.HasPrefix(namespace, "project-") {
+		m.add(usageKey{namespace: namespace, day: day}, counts)
+	}
+}
+
+func (m *Meter) add(key usageKey, counts llmcloudv1alpha1.UsageCounts) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.pending == nil {
+		m.pending = map[usageKey]llmcloudv1alpha1.UsageCounts{}
+	}
+	total := m.pending[key]
+	total.Add(counts)
+	m.pending[key] = total
+}
+
+// RecordRollout counts a request for a model with a rollout to canary, served by the
+// canary when toCanary is set, and whether it failed. The counts are added to the
+// model's rollout status as long as it is about the same canary
+func (m *Meter) RecordRollout(namespace, model, canary string, toCanary, failed bool) {
+	counts := rolloutCounts{requests: 1}
+	if failed {
+		counts.errors = 1
+	}
+	m.addRollout(rolloutKey{namespace: namespace, model: model, canary: canary, toCanary: toCanary}, counts)
+}
+
+func (m *Meter) addRollout(key rolloutKey, counts rolloutCounts) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.rollouts == nil {
+		m.rollouts = map[rolloutKey]rolloutCounts{}
+	}
+	total := m.rollouts[key]
+	total.requests += counts.requests
+	total.errors += counts.errors
+	m.rollouts[key] = total
+}
+
+// Count wraps the body of a model's response, recording a request and the tokens the
+// model reports in the body once it has been read and closed
+func (m *Meter) Count(namespace, model string, body io.ReadCloser) io.ReadCloser {
+	return newTokenCounter(body, func(counts llmcloudv1alpha1.UsageCounts) {
+		m.Record(namespace, model, counts)
+	})
+}
+
+// Start writes the counted usage every FlushInterval until ctx is done, and once more
+// when it is, so that no usage is lost on shutdown
+func (m *Meter) Start(ctx context.Context) error {
+	interval := m.FlushInterval
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			defer cancel()
+			m.Flush(flushCtx)
+			return nil
+		case <-ticker.C:
+			m.Flush(ctx)
+		}
+	}
+}
+
+// Flush adds the usage counted so far to the statuses of the models and projects.
+// Usage that cannot be written is kept for the next flush, unless its model or project
+// no longer exists
+func (m *Meter) Flush(ctx context.Context) {
+	m.mu.Lock()
+	pending, rollouts := m.pending, m.rollouts
+	m.pending, m.rollouts = nil, nil
+	m.mu.Unlock()
+
+	for key, counts := range pending {
+		var err error
+		if key.model == "" {
+			project := strings.TrimPrefix(key.namespace, "project-")
+			err = m.update(ctx, &llmcloudv1alpha1.Project{}, client.ObjectKey{Name: project}, func(obj client.Object) {
+				status := &obj.(*llmcloudv1alpha1.Project).Status
+				if status.InferenceUsage == nil {
+					status.InferenceUsage = &llmcloudv1alpha1.InferenceUsage{}
+				}
+				status.InferenceUsage.Record(key.day, counts)
+			})
+		} else {
+			err = m.update(ctx, &llmcloudv1alpha1.LLMModel{}, client.ObjectKey{Namespace: key.namespace, Name: key.model}, func(obj client.Object) {
+				status := &obj.(*llmcloudv1alpha1.LLMModel).Status
+				if status.Usage == nil {
+					status.Usage = &llmcloudv1alpha1.InferenceUsage{}
+				}
+				status.Usage.Record(key.day, counts)
+			})
+		}
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "failed to record usage", "namespace", key.namespace, "model", key.model)
+			m.add(key, counts)
+		}
+	}
+
+	for key, counts := range rollouts {
+		err := m.update(ctx, &llmcloudv1alpha1.LLMModel{}, client.ObjectKey{Namespace: key.namespace, Name: key.model}, func(obj client.Object) {
+			status := obj.(*llmcloudv1alpha1.LLMModel).Status.Rollout
+			if status == nil || status.Canary != key.canary {
+				return
+			}
+			if key.toCanary {
+				status.CanaryRequests += counts.requests
+				status.CanaryErrors += counts.errors
+			} else {
+				status.StableRequests += counts.requests
+				status.StableErrors += counts.errors
+			}
+		})
+		if client.IgnoreNotFound(err) != nil {
+			log.Error(err, "failed to record rollout requests", "namespace", key.namespace, "model", key.model)
+			m.addRollout(key, counts)
+		}
+	}
+}
+
+// update applies change to the latest status of the object, retrying on conflicts
+// with the controllers and other replicas updating it
+func (m *Meter) update(ctx context.Context, obj client.Object, key client.ObjectKey, change func(client.Object)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.Client.Get(ctx, key, obj); err != nil {
+			return err
+		}
+		change(obj)
+		return m.Client.Status().Update(ctx, obj)
+	})
+}
+
+// today returns the start of the current UTC day
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
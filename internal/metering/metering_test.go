@@ -0,0 +1,145 @@
+package metering
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestTokenCounter(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want llmcloudv1alpha1.UsageCounts
+	}{
+		{
+			name: "openai",
+			body: "{\n  \"choices\": [],\n  \"usage\": {\"prompt_tokens\": 12, \"completion_tokens\": 30, \"total_tokens\": 42}\n}",
+			want: llmcloudv1alpha1.UsageCounts{Requests: 1, PromptTokens: 12, CompletionTokens: 30},
+		},
+		{
+			name: "openai stream",
+			body: "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}],\"usage\":null}\n\n" +
+				"data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":2}}\n\ndata: [DONE]\n\n",
+			want: llmcloudv1alpha1.UsageCounts{Requests: 1, PromptTokens: 7, CompletionTokens: 2},
+		},
+		{
+			name: "ollama stream",
+			body: "{\"response\":\"hi\",\"done\":false}\n{\"response\":\"\",\"done\":true,\"prompt_eval_count\":26,\"eval_count\":298}",
+			want: llmcloudv1alpha1.UsageCounts{Requests: 1, PromptTokens: 26, CompletionTokens: 298},
+		},
+		{name: "no usage", body: "not json", want: llmcloudv1alpha1.UsageCounts{Requests: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got llmcloudv1alpha1.UsageCounts
+			counter := newTokenCounter(io.NopCloser(strings.NewReader(tt.body)), func(counts llmcloudv1alpha1.UsageCounts) {
+				got = counts
+			})
+			// Read in small pieces, as streamed responses arrive
+			buf := make([]byte, 5)
+			var read strings.Builder
+			for {
+				n, err := counter.Read(buf)
+				read.Write(buf[:n])
+				if err != nil {
+					break
+				}
+			}
+			_ = counter.Close()
+			if read.String() != tt.body {
+				t.Errorf("Expected the body passed through unchanged, got %q", read.String())
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMeterFlush(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}, &llmcloudv1alpha1.Project{}).
+		WithObjects(
+			&llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "team"}},
+			&llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "project-team"}},
+		).Build()
+	m := &Meter{Client: c}
+
+	m.Record("project-team", "llama", llmcloudv1alpha1.UsageCounts{Requests: 1, PromptTokens: 10, CompletionTokens: 5})
+	m.Record("project-team", "llama", llmcloudv1alpha1.UsageCounts{Requests: 1, PromptTokens: 3})
+	// The usage of a deleted model still counts for its project
+	m.Record("project-team", "deleted", llmcloudv1alpha1.UsageCounts{Requests: 1, CompletionTokens: 1})
+	m.Flush(context.Background())
+
+	model := &llmcloudv1alpha1.LLMModel{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "project-team", Name: "llama"}, model); err != nil {
+		t.Fatalf("Failed to get model: %v", err)
+	}
+	want := llmcloudv1alpha1.UsageCounts{Requests: 2, PromptTokens: 13, CompletionTokens: 5}
+	if model.Status.Usage == nil || model.Status.Usage.Total != want {
+		t.Fatalf("Expected model usage %+v, got %+v", want, model.Status.Usage)
+	}
+	if len(model.Status.Usage.Daily) != 1 || model.Status.Usage.Daily[0].UsageCounts != want {
+		t.Errorf("Expected the usage in today's bucket, got %+v", model.Status.Usage.Daily)
+	}
+
+	project := &llmcloudv1alpha1.Project{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "team"}, project); err != nil {
+		t.Fatalf("Failed to get project: %v", err)
+	}
+	want = llmcloudv1alpha1.UsageCounts{Requests: 3, PromptTokens: 13, CompletionTokens: 6}
+	if project.Status.InferenceUsage == nil || project.Status.InferenceUsage.Total != want {
+		t.Errorf("Expected project usage %+v, got %+v", want, project.Status.InferenceUsage)
+	}
+	if len(m.pending) != 0 {
+		t.Errorf("Expected nothing pending after the flush, got %v", m.pending)
+	}
+
+	// Flushing again adds only what was counted since
+	m.Flush(context.Background())
+	_ = c.Get(context.Background(), client.ObjectKey{Name: "team"}, project)
+	if project.Status.InferenceUsage.Total.Requests != 3 {
+		t.Errorf("Expected the usage to be added once, got %d requests", project.Status.InferenceUsage.Total.Requests)
+	}
+}
+
+func TestMeterFlushRollout(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&llmcloudv1alpha1.LLMModel{}).
+		WithObjects(&llmcloudv1alpha1.LLMModel{
+			ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "project-team"},
+			Status: llmcloudv1alpha1.LLMModelStatus{Rollout: &llmcloudv1alpha1.ModelRolloutStatus{
+				Canary: "llama-v2", Phase: llmcloudv1alpha1.RolloutPhaseProgressing, CanaryRequests: 1,
+			}},
+		}).Build()
+	m := &Meter{Client: c}
+
+	m.RecordRollout("project-team", "llama", "llama-v2", true, false)
+	m.RecordRollout("project-team", "llama", "llama-v2", true, true)
+	m.RecordRollout("project-team", "llama", "llama-v2", false, false)
+	// Requests for an earlier canary are not added to the current rollout
+	m.RecordRollout("project-team", "llama", "llama-v1", true, true)
+	m.Flush(context.Background())
+
+	model := &llmcloudv1alpha1.LLMModel{}
+	if err := c.Get(context.Background(), client.ObjectKey{Name: "llama", Namespace: "project-team"}, model); err != nil {
+		t.Fatal(err)
+	}
+	got := model.Status.Rollout
+	if got.CanaryRequests != 3 || got.CanaryErrors != 1 || got.StableRequests != 1 || got.StableErrors != 0 {
+		t.Errorf("Unexpected rollout counts %+v", got)
+	}
+}
@@ -0,0 +1,113 @@
+package metering
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// maxBufferedBody is the largest response kept whole to find the token usage in, for
+// responses that do not report it on a line of their own
+const maxBufferedBody = 1 << 20
+
+// reportedUsage holds the token counts a model server reports: the usage object of the
+// OpenAI API, or the counts of the ollama API, which come with the last streamed chunk
+type reportedUsage struct {
+	Usage *struct {
+		PromptTokens     int64 `json:"prompt_tokens"`
+		CompletionTokens int64 `json:"completion_tokens"`
+	} `json:"usage"`
+	PromptEvalCount int64 `json:"prompt_eval_count"`
+	EvalCount       int64 `json:"eval_count"`
+}
+
+// parseUsage returns the token counts in a JSON document, reporting whether it has any
+func parseUsage(data []byte) (prompt, completion int64, ok bool) {
+	var reported reportedUsage
+	if json.Unmarshal(data, &reported) != nil {
+		return 0, 0, false
+	}
+	if reported.Usage != nil {
+		return reported.Usage.PromptTokens, reported.Usage.CompletionTokens, true
+	}
+	if reported.PromptEvalCount > 0 || reported.EvalCount > 0 {
+		return reported.PromptEvalCount, reported.EvalCount, true
+	}
+	return 0, 0, false
+}
+
+// tokenCounter passes a response body through, looking for the token usage in each of
+// its lines, which are server-sent events for streamed OpenAI responses and JSON
+// documents for streamed ollama ones. Other responses are parsed whole on Close
+type tokenCounter struct {
+	io.ReadCloser
+	done func(llmcloudv1alpha1.UsageCounts)
+
+	line []byte
+	body bytes.Buffer
+	// truncated is set once the body no longer fits in maxBufferedBody
+	truncated bool
+	counts    llmcloudv1alpha1.UsageCounts
+	found     bool
+	once      sync.Once
+}
+
+func newTokenCounter(body io.ReadCloser, done func(llmcloudv1alpha1.UsageCounts)) *tokenCounter {
+	return &tokenCounter{ReadCloser: body, done: done}
+}
+
+func (c *tokenCounter) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	data := p[:n]
+	if c.body.Len()+n > maxBufferedBody {
+		c.truncated = true
+	} else if !c.truncated {
+		c.body.Write(data)
+	}
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			if len(c.line)+len(data) <= maxBufferedBody {
+				c.line = append(c.line, data...)
+			}
+			break
+		}
+		c.scanLine(append(c.line, data[:i]...))
+		c.line = c.line[:0]
+		data = data[i+1:]
+	}
+	return n, err
+}
+
+// scanLine keeps the token counts of a line that reports them. The last report wins,
+// as streams report the usage of the whole response at their end
+func (c *tokenCounter) scanLine(line []byte) {
+	line = bytes.TrimSpace(line)
+	line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+	if len(line) == 0 || line[0] != '{' {
+		return
+	}
+	if prompt, completion, ok := parseUsage(line); ok {
+		c.counts.PromptTokens, c.counts.CompletionTokens = prompt, completion
+		c.found = true
+	}
+}
+
+// Close closes the body and records the request with the tokens found in it
+func (c *tokenCounter) Close() error {
+	err := c.ReadCloser.Close()
+	c.once.Do(func() {
+		c.scanLine(c.line)
+		if !c.found && !c.truncated {
+			if prompt, completion, ok := parseUsage(c.body.Bytes()); ok {
+				c.counts.PromptTokens, c.counts.CompletionTokens = prompt, completion
+			}
+		}
+		c.counts.Requests = 1
+		c.done(c.counts)
+	})
+	return err
+}
@@ -0,0 +1,157 @@
+// Package metrics defines the operator's own Prometheus metrics. They are registered on
+// controller-runtime's registry, so the manager's metrics endpoint serves them along
+// with its defaults
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// collectTimeout bounds the listing of resources on each scrape
+const collectTimeout = 10 * time.Second
+
+var log = logf.Log.WithName("metrics")
+
+var (
+	// LoginAttempts counts logins to the API server by result: success or failure
+	LoginAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "llmcloud_login_attempts_total",
+		Help: "Number of login attempts to the API server, by result",
+	}, []string{"result"})
+
+	// APIRequestDuration observes API server requests by route pattern, method and
+	// response status code
+	APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llmcloud_api_request_duration_seconds",
+		Help:    "Latency of API server requests, by route, method and status code",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	// ReconcileDuration observes reconciles by the kind of the reconciled resource and
+	// result: success, requeue or error
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "llmcloud_reconcile_duration_seconds",
+		Help:    "Duration of reconciles, by resource kind and result",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"kind", "result"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(LoginAttempts, APIRequestDuration, ReconcileDuration)
+}
+
+// resourcesDesc describes the number of VMs, models and services by project and phase
+var resourcesDesc = prometheus.NewDesc(
+	"llmcloud_resources",
+	"Number of VMs, models and services, by kind, project and phase",
+	[]string{"kind", "project", "phase"}, nil,
+)
+
+// ResourceCollector reports the number of VMs, models and services in each project and
+// phase, listing them through Client on every scrape. Client should read from a cache
+type ResourceCollector struct {
+	Client client.Reader
+}
+
+// Describe implements prometheus.Collector
+func (c *ResourceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourcesDesc
+}
+
+// Collect implements prometheus.Collector. A kind that cannot be listed is left out of
+// the scrape rather than reported as zero
+func (c *ResourceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	var vms llmcloudv1alpha1.VirtualMachineList
+	if err := c.Client.List(ctx, &vms); err != nil {
+		log.Error(err, "failed to list VMs")
+	} else {
+		counts := map[[2]string]int{}
+		for _, vm := range vms.Items {
+			counts[[2]string{project(vm.Namespace), vm.Status.Phase}]++
+		}
+		report(ch, "VirtualMachine", counts)
+	}
+
+	var models llmcloudv1alpha1.LLMModelList
+	if err := c.Client.List(ctx, &models); err != nil {
+		log.Error(err, "failed to list models")
+	} else {
+		counts := map[[2]string]int{}
+		for _, model := range models.Items {
+			counts[[2]string{project(model.Namespace), model.Status.Phase}]++
+		}
+		report(ch, "LLMModel", counts)
+	}
+
+	var services llmcloudv1alpha1.ServiceList
+	if err := c.Client.List(ctx, &services); err != nil {
+		log.Error(err, "failed to list services")
+	} else {
+		counts := map[[2]string]int{}
+		for _, service := range services.Items {
+			counts[[2]string{project(service.Namespace), service.Status.Phase}]++
+		}
+		report(ch, "Service", counts)
+	}
+}
+
+// report sends a gauge for each project and phase pair in counts
+func report(ch chan<- prometheus.Metric, kind string, counts map[[2]string]int) {
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(resourcesDesc, prometheus.GaugeValue, float64(count), kind, key[0], key[1])
+	}
+}
+
+// project is the project owning namespace, or the namespace itself when it is not a
+// project namespace
+func project(namespace string) string {
+	if name, ok := strings.CutPrefix(namespace, "project-"); ok {
+		return name
+	}
+	return namespace
+}
+
+// ObserveAPIRequest records an API server request to route that took since start
+func ObserveAPIRequest(route, method string, code int, start time.Time) {
+	APIRequestDuration.WithLabelValues(route, method, strconv.Itoa(code)).Observe(time.Since(start).Seconds())
+}
+
+// ObserveLogin counts a login attempt that succeeded or failed
+func ObserveLogin(success bool) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	LoginAttempts.WithLabelValues(result).Inc()
+}
+
+// Reconciler wraps r to observe the duration of its reconciles as kind
+func Reconciler(kind string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		start := time.Now()
+		result, err := r.Reconcile(ctx, req)
+		outcome := "success"
+		switch {
+		case err != nil:
+			outcome = "error"
+		case !result.IsZero():
+			outcome = "requeue"
+		}
+		ReconcileDuration.WithLabelValues(kind, outcome).Observe(time.Since(start).Seconds())
+		return result, err
+	})
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestResourceCollector(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	vm := func(namespace, name, phase string) *llmcloudv1alpha1.VirtualMachine {
+		return &llmcloudv1alpha1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Status:     llmcloudv1alpha1.VirtualMachineStatus{Phase: phase},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		vm("project-team", "web", "Running"),
+		vm("project-team", "db", "Running"),
+		vm("project-team", "batch", "Stopped"),
+		&llmcloudv1alpha1.LLMModel{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "project-ml", Name: "mistral"},
+			Status:     llmcloudv1alpha1.LLMModelStatus{Phase: "Ready"},
+		},
+	).WithStatusSubresource(&llmcloudv1alpha1.VirtualMachine{}, &llmcloudv1alpha1.LLMModel{}).Build()
+
+	expected := `
+# HELP llmcloud_resources Number of VMs, models and services, by kind, project and phase
+# TYPE llmcloud_resources gauge
+llmcloud_resources{kind="LLMModel",phase="Ready",project="ml"} 1
+llmcloud_resources{kind="VirtualMachine",phase="Running",project="team"} 2
+llmcloud_resources{kind="VirtualMachine",phase="Stopped",project="team"} 1
+`
+	if err := testutil.CollectAndCompare(&ResourceCollector{Client: c}, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	results := map[string]struct {
+		result reconcile.Result
+		err    error
+	}{
+		"success": {},
+		"requeue": {result: reconcile.Result{RequeueAfter: 1}},
+		"error":   {err: errors.New("boom")},
+	}
+	for outcome, tt := range results {
+		r := Reconciler("Test", reconcile.Func(func(context.Context, reconcile.Request) (reconcile.Result, error) {
+			return tt.result, tt.err
+		}))
+		if _, err := r.Reconcile(context.Background(), reconcile.Request{}); err != tt.err {
+			t.Errorf("Expected the wrapped reconciler's error %v, got %v", tt.err, err)
+		}
+		var m dto.Metric
+		if err := ReconcileDuration.WithLabelValues("Test", outcome).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatalf("Failed to read histogram: %v", err)
+		}
+		if n := m.GetHistogram().GetSampleCount(); n != 1 {
+			t.Errorf("Expected one %s observation, got %d", outcome, n)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package sshexec runs commands on remote hosts over SSH, without an ssh binary
+package sshexec
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyPolicy decides which host keys are trusted
+type HostKeyPolicy string
+
+const (
+	// HostKeyStrict only accepts hosts whose key is in the known hosts file
+	HostKeyStrict HostKeyPolicy = "strict"
+	// HostKeyAcceptNew trusts and records the key of a host missing from the known hosts
+	// file, but rejects a host whose key changed, like ssh's StrictHostKeyChecking=accept-new
+	HostKeyAcceptNew HostKeyPolicy = "accept-new"
+	// HostKeyInsecure accepts any host key
+	HostKeyInsecure HostKeyPolicy = "insecure"
+)
+
+// defaultKeyFiles are the private keys tried when Config.KeyFiles is empty, relative
+// to the home directory
+var defaultKeyFiles = []string{".ssh/id_ed25519", ".ssh/id_ecdsa", ".ssh/id_rsa"}
+
+// Config configures how hosts are authenticated and authenticate us
+type Config struct {
+	// User logs in to destinations that name none. Defaults to $USER
+	User string
+	// KeyFiles are the private keys to log in with. When empty, and there are no
+	// PrivateKeys, the agent at $SSH_AUTH_SOCK and the default keys in ~/.ssh are used
+	KeyFiles []string
+	// PrivateKeys are unencrypted PEM private keys to log in with, like KeyFiles
+	PrivateKeys [][]byte
+	// Password logs in when no key is accepted
+	Password string
+	// KnownHostsFile holds the trusted host keys. Defaults to ~/.ssh/known_hosts
+	KnownHostsFile string
+	// HostKeyPolicy defaults to HostKeyAcceptNew
+	HostKeyPolicy HostKeyPolicy
+	// Timeout bounds establishing a connection. Defaults to 10s
+	Timeout time.Duration
+}
+
+// BindFlags registers flags for c on fs, each name starting with prefix
+func (c *Config) BindFlags(fs *flag.FlagSet, prefix string) {
+	fs.Func(prefix+"ssh-key", "Private key file to log in with (repeatable; defaults to the SSH agent and ~/.ssh keys)",
+		func(value string) error {
+			c.KeyFiles = append(c.KeyFiles, value)
+			return nil
+		})
+	fs.StringVar(&c.Password, prefix+"ssh-password", os.Getenv("SSH_PASSWORD"), "Password to log in with when no key is accepted")
+	fs.StringVar(&c.KnownHostsFile, prefix+"ssh-known-hosts", "", "Known hosts file (defaults to ~/.ssh/known_hosts)")
+	fs.Func(prefix+"ssh-host-key-policy", "Which host keys to trust: strict, accept-new (default) or insecure",
+		func(value string) error {
+			switch policy := HostKeyPolicy(value); policy {
+			case HostKeyStrict, HostKeyAcceptNew, HostKeyInsecure:
+				c.HostKeyPolicy = policy
+				return nil
+			}
+			return fmt.Errorf("unknown host key policy %q", value)
+		})
+}
+
+// Client is a connection to one host
+type Client struct {
+	client *ssh.Client
+}
+
+// Dial connects to destination, given as [user@]host[:port]
+func Dial(destination string, config Config) (*Client, error) {
+	user, address := splitDestination(destination, config.User)
+	hostKeyCallback, err := config.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+	auth, err := config.authMethods()
+	if err != nil {
+		return nil, err
+	}
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cmp.Or(config.Timeout, 10*time.Second),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", destination, err)
+	}
+	return &Client{client: client}, nil
+}
+
+// splitDestination splits [user@]host[:port] into the user and the address to dial
+func splitDestination(destination, defaultUser string) (user, address string) {
+	user = cmp.Or(defaultUser, os.Getenv("USER"))
+	host := destination
+	if idx := strings.LastIndex(destination, "@"); idx != -1 {
+		user, host = destination[:idx], destination[idx+1:]
+	}
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "22")
+	}
+	return user, host
+}
+
+// authMethods returns the ways to log in: keys first, then the password
+func (c *Config) authMethods() ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+	var signers []ssh.Signer
+	if len(c.KeyFiles) > 0 || len(c.PrivateKeys) > 0 {
+		for _, file := range c.KeyFiles {
+			signer, err := loadKey(file)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+		for _, key := range c.PrivateKeys {
+			signer, err := ssh.ParsePrivateKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("invalid SSH key: %w", err)
+			}
+			signers = append(signers, signer)
+		}
+	} else {
+		if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+			if conn, err := net.Dial("unix", sock); err == nil {
+				methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			}
+		}
+		home, _ := os.UserHomeDir()
+		for _, file := range defaultKeyFiles {
+			// Missing and passphrase-protected default keys are skipped; the agent holds those
+			if signer, err := loadKey(filepath.Join(home, file)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) > 0 {
+		methods = append(methods, ssh.PublicKeys(signers...))
+	}
+	if c.Password != "" {
+		methods = append(methods, ssh.Password(c.Password))
+	}
+	if len(methods) == 0 {
+		return nil, errors.New("no SSH key, agent or password to log in with")
+	}
+	return methods, nil
+}
+
+// loadKey reads an unencrypted private key
+func loadKey(file string) (ssh.Signer, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read SSH key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH key %s: %w", file, err)
+	}
+	return signer, nil
+}
+
+// hostKeyCallback checks host keys against the known hosts file, as the policy says
+func (c *Config) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	policy := cmp.Or(c.HostKeyPolicy, HostKeyAcceptNew)
+	if policy == HostKeyInsecure {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+	file := c.KnownHostsFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find known hosts: %w", err)
+		}
+		file = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		if policy == HostKeyStrict {
+			return nil, fmt.Errorf("known hosts file %s does not exist", file)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(file, nil, 0600); err != nil {
+			return nil, err
+		}
+	}
+	known, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known hosts: %w", err)
+	}
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if policy != HostKeyAcceptNew || !errors.As(err, &keyErr) || len(keyErr.Want) > 0 {
+			return err
+		}
+		// An unknown host is trusted from now on; a changed key never is
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		_, err = fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key))
+		return err
+	}, nil
+}
+
+// Pool keeps one connection per destination, dialed on first use. The zero value
+// dials with the zero Config
+type Pool struct {
+	Config Config
+
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// Client returns the connection to destination, dialing it if there is none yet
+func (p *Pool) Client(destination string) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[destination]; ok {
+		return client, nil
+	}
+	client, err := Dial(destination, p.Config)
+	if err != nil {
+		return nil, err
+	}
+	if p.clients == nil {
+		p.clients = make(map[string]*Client)
+	}
+	p.clients[destination] = client
+	return client, nil
+}
+
+// Close closes every connection of the pool
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for destination, client := range p.clients {
+		_ = client.Close()
+		delete(p.clients, destination)
+	}
+}
+
+// Close closes the connection
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+// Run runs command in a shell on the host, streaming its output to stdout and stderr.
+// A command exiting with a non-zero status returns an *ssh.ExitError
+func (c *Client) Run(command string, stdout, stderr io.Writer) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = session.Close() }()
+	session.Stdout = stdout
+	session.Stderr = stderr
+	return session.Run(command)
+}
+
+// Output runs command and returns its standard output. On failure the error carries
+// what the command wrote to standard error
+func (c *Client) Output(command string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.Run(command, &stdout, &stderr); err != nil {
+		return nil, commandError(err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// CombinedOutput runs command and returns its standard output and standard error
+func (c *Client) CombinedOutput(command string) ([]byte, error) {
+	var output bytes.Buffer
+	err := c.Run(command, &output, &output)
+	return output.Bytes(), err
+}
+
+// Upload writes content to path on the host, with mode. The directory must exist
+func (c *Client) Upload(path string, content io.Reader, mode os.FileMode) error {
+	session, err := c.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = session.Close() }()
+	session.Stdin = content
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	command := fmt.Sprintf("cat > %s && chmod %o %s", Quote(path), mode.Perm(), Quote(path))
+	if err := session.Run(command); err != nil {
+		return commandError(fmt.Errorf("failed to upload %s: %w", path, err), stderr.String())
+	}
+	return nil
+}
+
+// Quote quotes s as a single shell word
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commandError adds what a failed command wrote to standard error to err
+func commandError(err error, stderr string) error {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("%w: %s", err, stderr)
+	}
+	return err
+}
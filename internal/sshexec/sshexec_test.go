@@ -0,0 +1,272 @@
+package sshexec
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server that runs a few fake commands: "fail" exits with status 1,
+// "cat > ..." stores its input in uploads, anything else echoes the command
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu      sync.Mutex
+	uploads map[string]string
+}
+
+func newTestServer(t *testing.T, authorized ssh.PublicKey, password string) *testServer {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("Failed to create host key: %v", err)
+	}
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(_ ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if password != "" && string(pass) == password {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if authorized != nil && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	s := &testServer{addr: l.Addr().String(), hostKey: hostKey, uploads: map[string]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, config)
+		}
+	}()
+	return s
+}
+
+func (s *testServer) serve(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer func() { _ = channel.Close() }()
+			for req := range requests {
+				if req.Type != "exec" {
+					_ = req.Reply(false, nil)
+					continue
+				}
+				_ = req.Reply(true, nil)
+				command := string(req.Payload[4:])
+				status := s.run(command, channel)
+				_, _ = channel.SendRequest("exit-status", false, binary.BigEndian.AppendUint32(nil, status))
+				return
+			}
+		}()
+	}
+}
+
+func (s *testServer) run(command string, channel ssh.Channel) uint32 {
+	switch {
+	case command == "fail":
+		_, _ = io.WriteString(channel.Stderr(), "boom")
+		return 1
+	case strings.HasPrefix(command, "cat > "):
+		data, _ := io.ReadAll(channel)
+		s.mu.Lock()
+		s.uploads[strings.Fields(command)[2]] = string(data)
+		s.mu.Unlock()
+		return 0
+	default:
+		_, _ = io.WriteString(channel, command)
+		return 0
+	}
+}
+
+// writeKey writes a new private key to dir, returning its file and public key
+func writeKey(t *testing.T, dir string) (string, ssh.PublicKey) {
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	file := filepath.Join(dir, "id_ed25519")
+	if err := os.WriteFile(file, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	signer, _ := ssh.NewSignerFromKey(priv)
+	return file, signer.PublicKey()
+}
+
+func TestDialWithKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, publicKey := writeKey(t, dir)
+	server := newTestServer(t, publicKey, "")
+	config := Config{KeyFiles: []string{keyFile}, KnownHostsFile: filepath.Join(dir, "known_hosts")}
+
+	client, err := Dial("admin@"+server.addr, config)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	output, err := client.Output("hostname")
+	if err != nil || string(output) != "hostname" {
+		t.Errorf("Expected the command's output, got %q (%v)", output, err)
+	}
+	_, err = client.Output("fail")
+	var exitErr *ssh.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitStatus() != 1 || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected the exit status and standard error of a failed command, got %v", err)
+	}
+
+	if err := client.Upload("/tmp/manager", strings.NewReader("binary"), 0755); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	if got := server.uploads["'/tmp/manager'"]; got != "binary" {
+		t.Errorf("Expected the uploaded content, got %q", got)
+	}
+}
+
+func TestDialWithPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	keyFile, publicKey := writeKey(t, dir)
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t, publicKey, "")
+
+	client, err := Dial("admin@"+server.addr, Config{PrivateKeys: [][]byte{key}, KnownHostsFile: filepath.Join(dir, "known_hosts")})
+	if err != nil {
+		t.Fatalf("Failed to dial with an in-memory key: %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestDialWithPassword(t *testing.T) {
+	server := newTestServer(t, nil, "s3cret")
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+	if _, err := Dial("admin@"+server.addr, Config{KeyFiles: nil, Password: "wrong", KnownHostsFile: knownHosts}); err == nil {
+		t.Error("Expected a wrong password to be rejected")
+	}
+	client, err := Dial("admin@"+server.addr, Config{Password: "s3cret", KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatalf("Failed to dial with password: %v", err)
+	}
+	_ = client.Close()
+}
+
+func TestHostKeyPolicies(t *testing.T) {
+	server := newTestServer(t, nil, "pw")
+	knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+
+	if _, err := Dial(server.addr, Config{Password: "pw", KnownHostsFile: knownHosts, HostKeyPolicy: HostKeyStrict}); err == nil {
+		t.Error("Expected strict checking to reject a host without known hosts")
+	}
+
+	// accept-new records the unknown host, which strict checking then accepts
+	client, err := Dial(server.addr, Config{Password: "pw", KnownHostsFile: knownHosts})
+	if err != nil {
+		t.Fatalf("Expected a new host to be accepted: %v", err)
+	}
+	_ = client.Close()
+	client, err = Dial(server.addr, Config{Password: "pw", KnownHostsFile: knownHosts, HostKeyPolicy: HostKeyStrict})
+	if err != nil {
+		t.Fatalf("Expected the recorded host to be trusted: %v", err)
+	}
+	_ = client.Close()
+
+	// Another server on the same address has a different key, which is never trusted
+	other := newTestServer(t, nil, "pw")
+	data, _ := os.ReadFile(knownHosts)
+	line := strings.Replace(string(data), hostPort(server.addr), hostPort(other.addr), 1)
+	if err := os.WriteFile(knownHosts, []byte(line), 0600); err != nil {
+		t.Fatalf("Failed to write known hosts: %v", err)
+	}
+	if _, err := Dial(other.addr, Config{Password: "pw", KnownHostsFile: knownHosts}); err == nil {
+		t.Error("Expected a changed host key to be rejected")
+	}
+	if client, err := Dial(other.addr, Config{Password: "pw", KnownHostsFile: knownHosts, HostKeyPolicy: HostKeyInsecure}); err != nil {
+		t.Errorf("Expected insecure checking to accept any key: %v", err)
+	} else {
+		_ = client.Close()
+	}
+}
+
+// hostPort is how known_hosts names an address with a non-default port
+func hostPort(addr string) string {
+	host, port, _ := net.SplitHostPort(addr)
+	return "[" + host + "]:" + port
+}
+
+func TestSplitDestination(t *testing.T) {
+	tests := map[string][2]string{
+		"admin@10.0.0.1":      {"admin", "10.0.0.1:22"},
+		"admin@10.0.0.1:2222": {"admin", "10.0.0.1:2222"},
+		"host.example.com":    {"deploy", "host.example.com:22"},
+		"admin@[::1]:2222":    {"admin", "[::1]:2222"},
+		"admin@::1":           {"admin", "[::1]:22"},
+	}
+	for destination, want := range tests {
+		user, address := splitDestination(destination, "deploy")
+		if user != want[0] || address != want[1] {
+			t.Errorf("%s: expected %v, got %s %s", destination, want, user, address)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := Quote("it's"); got != `'it'\''s'` {
+		t.Errorf("Expected a single-quoted word, got %s", got)
+	}
+}
+
+func TestPoolReusesConnections(t *testing.T) {
+	server := newTestServer(t, nil, "pw")
+	pool := &Pool{Config: Config{Password: "pw", HostKeyPolicy: HostKeyInsecure}}
+	defer pool.Close()
+
+	first, err := pool.Client(server.addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	second, err := pool.Client(server.addr)
+	if err != nil || second != first {
+		t.Errorf("Expected the connection to be reused, got %p and %p (%v)", first, second, err)
+	}
+	pool.Close()
+	if third, err := pool.Client(server.addr); err != nil || third == first {
+		t.Errorf("Expected a new connection after closing the pool (%v)", err)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var clusternodelog = logf.Log.WithName("clusternode-resource")
+
+// SetupClusterNodeWebhookWithManager registers the webhook for ClusterNode in the manager.
+func SetupClusterNodeWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.ClusterNode{}).
+		WithValidator(&ClusterNodeCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-clusternode,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=clusternodes,verbs=create;update,versions=v1alpha1,name=vclusternode-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ClusterNodeCustomValidator validates the host, node name and credentials of ClusterNodes
+type ClusterNodeCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ClusterNodeCustomValidator{}
+
+// ValidateCreate rejects ClusterNodes without a host to join or with an invalid node name
+func (v *ClusterNodeCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	node, ok := obj.(*llmcloudv1alpha1.ClusterNode)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterNode object but got %T", obj)
+	}
+	clusternodelog.Info("Validation for ClusterNode upon creation", "name", node.GetName())
+
+	return nil, invalid("ClusterNode", node.Name, llmcloudv1alpha1.ValidateClusterNodeSpec(&node.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate
+func (v *ClusterNodeCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	node, ok := newObj.(*llmcloudv1alpha1.ClusterNode)
+	if !ok {
+		return nil, fmt.Errorf("expected a ClusterNode object but got %T", newObj)
+	}
+	clusternodelog.Info("Validation for ClusterNode upon update", "name", node.GetName())
+
+	return nil, invalid("ClusterNode", node.Name, llmcloudv1alpha1.ValidateClusterNodeSpec(&node.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *ClusterNodeCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
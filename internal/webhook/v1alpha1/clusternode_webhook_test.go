@@ -0,0 +1,55 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestClusterNodeValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec llmcloudv1alpha1.ClusterNodeSpec
+		want []string
+	}{
+		{name: "host", spec: llmcloudv1alpha1.ClusterNodeSpec{Host: "ubuntu@10.0.0.21:2222", Role: "worker"}},
+		{
+			name: "credentials",
+			spec: llmcloudv1alpha1.ClusterNodeSpec{
+				Host:              "gpu-1",
+				CredentialsSecret: &corev1.SecretReference{Name: "gpu-1-ssh", Namespace: "llmcloud-operator-system"},
+			},
+		},
+		{name: "removed without host", spec: llmcloudv1alpha1.ClusterNodeSpec{NodeName: "old-1", State: "Removed"}},
+		{name: "no host", spec: llmcloudv1alpha1.ClusterNodeSpec{NodeName: "new-1"}, want: []string{"spec.host"}},
+		{name: "nothing to remove", spec: llmcloudv1alpha1.ClusterNodeSpec{State: "Removed"}, want: []string{"spec.nodeName"}},
+		{name: "bad host", spec: llmcloudv1alpha1.ClusterNodeSpec{Host: "gpu-1; reboot"}, want: []string{"spec.host"}},
+		{name: "bad node name", spec: llmcloudv1alpha1.ClusterNodeSpec{Host: "gpu-1", NodeName: "GPU_1"}, want: []string{"spec.nodeName"}},
+		{
+			name: "secret without namespace",
+			spec: llmcloudv1alpha1.ClusterNodeSpec{Host: "gpu-1", CredentialsSecret: &corev1.SecretReference{Name: "gpu-1-ssh"}},
+			want: []string{"spec.credentialsSecret.namespace"},
+		},
+	}
+	v := &ClusterNodeCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := &llmcloudv1alpha1.ClusterNode{ObjectMeta: metav1.ObjectMeta{Name: "node"}, Spec: tt.spec}
+			_, err := v.ValidateCreate(context.Background(), node)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Expected a valid ClusterNode, got %v", err)
+				}
+				return
+			}
+			if got := invalidFields(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateCreate reported fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
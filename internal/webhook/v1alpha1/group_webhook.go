@@ -0,0 +1,74 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var grouplog = logf.Log.WithName("group-resource")
+
+// SetupGroupWebhookWithManager registers the webhook for Group in the manager.
+func SetupGroupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.Group{}).
+		WithValidator(&GroupCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-group,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=groups,verbs=create;update,versions=v1alpha1,name=vgroup-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// GroupCustomValidator validates the members of Groups
+type GroupCustomValidator struct{}
+
+var _ webhook.CustomValidator = &GroupCustomValidator{}
+
+// ValidateCreate rejects Groups with empty, duplicate or nested group members
+func (v *GroupCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	group, ok := obj.(*llmcloudv1alpha1.Group)
+	if !ok {
+		return nil, fmt.Errorf("expected a Group object but got %T", obj)
+	}
+	grouplog.Info("Validation for Group upon creation", "name", group.GetName())
+
+	return nil, invalid("Group", group.Name, llmcloudv1alpha1.ValidateGroupSpec(&group.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate
+func (v *GroupCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	group, ok := newObj.(*llmcloudv1alpha1.Group)
+	if !ok {
+		return nil, fmt.Errorf("expected a Group object but got %T", newObj)
+	}
+	grouplog.Info("Validation for Group upon update", "name", group.GetName())
+
+	return nil, invalid("Group", group.Name, llmcloudv1alpha1.ValidateGroupSpec(&group.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *GroupCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
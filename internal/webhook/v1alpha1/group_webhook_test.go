@@ -0,0 +1,43 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestGroupValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []string
+		want    []string
+	}{
+		{name: "valid", members: []string{"alice", "bob"}},
+		{name: "empty", members: []string{"alice", ""}, want: []string{"spec.members[1]"}},
+		{name: "nested", members: []string{"group:admins"}, want: []string{"spec.members[0]"}},
+		{name: "duplicate", members: []string{"alice", "alice"}, want: []string{"spec.members[1]"}},
+	}
+	v := &GroupCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			group := &llmcloudv1alpha1.Group{
+				ObjectMeta: metav1.ObjectMeta{Name: "ml-team"},
+				Spec:       llmcloudv1alpha1.GroupSpec{Members: tt.members},
+			}
+			_, err := v.ValidateCreate(context.Background(), group)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Expected a valid Group, got %v", err)
+				}
+				return
+			}
+			if got := invalidFields(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateCreate reported fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
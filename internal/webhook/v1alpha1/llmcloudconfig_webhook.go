@@ -0,0 +1,73 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var llmcloudconfiglog = logf.Log.WithName("llmcloudconfig-resource")
+
+// SetupLLMCloudConfigWebhookWithManager registers the webhook for LLMCloudConfig in the manager.
+func SetupLLMCloudConfigWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.LLMCloudConfig{}).
+		WithValidator(&LLMCloudConfigCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-llmcloudconfig,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=llmcloudconfigs,verbs=create;update,versions=v1alpha1,name=vllmcloudconfig-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// LLMCloudConfigCustomValidator validates the name and settings of LLMCloudConfigs
+type LLMCloudConfigCustomValidator struct{}
+
+var _ webhook.CustomValidator = &LLMCloudConfigCustomValidator{}
+
+// ValidateCreate rejects LLMCloudConfigs not named default and invalid settings
+func (v *LLMCloudConfigCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	config, ok := obj.(*llmcloudv1alpha1.LLMCloudConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected an LLMCloudConfig object but got %T", obj)
+	}
+	llmcloudconfiglog.Info("Validation for LLMCloudConfig upon creation", "name", config.GetName())
+
+	return nil, invalid("LLMCloudConfig", config.Name, llmcloudv1alpha1.ValidateLLMCloudConfig(config))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate
+func (v *LLMCloudConfigCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	config, ok := newObj.(*llmcloudv1alpha1.LLMCloudConfig)
+	if !ok {
+		return nil, fmt.Errorf("expected an LLMCloudConfig object but got %T", newObj)
+	}
+	llmcloudconfiglog.Info("Validation for LLMCloudConfig upon update", "name", config.GetName())
+
+	return nil, invalid("LLMCloudConfig", config.Name, llmcloudv1alpha1.ValidateLLMCloudConfig(config))
+}
+
+// ValidateDelete allows all deletions
+func (v *LLMCloudConfigCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
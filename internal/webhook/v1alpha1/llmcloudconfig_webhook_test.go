@@ -0,0 +1,29 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestLLMCloudConfigValidate(t *testing.T) {
+	v := &LLMCloudConfigCustomValidator{}
+	config := &llmcloudv1alpha1.LLMCloudConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec:       llmcloudv1alpha1.LLMCloudConfigSpec{AllowedOS: []string{"ubuntu"}},
+	}
+	if _, err := v.ValidateCreate(context.Background(), config); err != nil {
+		t.Errorf("Expected a valid LLMCloudConfig, got %v", err)
+	}
+
+	config.Name = "staging"
+	config.Spec.AllowedOS = []string{"windows"}
+	_, err := v.ValidateUpdate(context.Background(), config, config)
+	if got, want := invalidFields(t, err), []string{"metadata.name", "spec.allowedOS[0]"}; !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+}
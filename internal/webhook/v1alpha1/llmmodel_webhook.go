@@ -0,0 +1,87 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var llmmodellog = logf.Log.WithName("llmmodel-resource")
+
+// SetupLLMModelWebhookWithManager registers the webhook for LLMModel in the manager.
+func SetupLLMModelWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.LLMModel{}).
+		WithValidator(&LLMModelCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-llmmodel,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=llmmodels,verbs=create;update,versions=v1alpha1,name=vllmmodel-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// LLMModelCustomValidator validates the replicas and resources of LLMModels, and
+// enforces the MaxLLMModels quota of their project
+type LLMModelCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &LLMModelCustomValidator{}
+
+// ValidateCreate rejects models with an invalid spec or beyond their project's quota
+func (v *LLMModelCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	model, ok := obj.(*llmcloudv1alpha1.LLMModel)
+	if !ok {
+		return nil, fmt.Errorf("expected a LLMModel object but got %T", obj)
+	}
+	llmmodellog.Info("Validation for LLMModel upon creation", "name", model.GetName())
+
+	if err := invalid("LLMModel", model.Name, llmcloudv1alpha1.ValidateLLMModelSpec(&model.Spec, field.NewPath("spec"))); err != nil {
+		return nil, err
+	}
+	return nil, checkProjectQuota(ctx, v.Client, model.Namespace, "llmmodels", &llmcloudv1alpha1.LLMModelList{},
+		func(q *llmcloudv1alpha1.ProjectResourceQuotas) *int32 { return q.MaxLLMModels })
+}
+
+// ValidateUpdate rejects an update that makes the spec invalid; the quota only limits
+// creation
+func (v *LLMModelCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	model, ok := newObj.(*llmcloudv1alpha1.LLMModel)
+	if !ok {
+		return nil, fmt.Errorf("expected a LLMModel object but got %T", newObj)
+	}
+	old, ok := oldObj.(*llmcloudv1alpha1.LLMModel)
+	if !ok {
+		return nil, fmt.Errorf("expected a LLMModel object but got %T", oldObj)
+	}
+	llmmodellog.Info("Validation for LLMModel upon update", "name", model.GetName())
+
+	return nil, invalid("LLMModel", model.Name, llmcloudv1alpha1.ValidateLLMModelUpdate(&old.Spec, &model.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *LLMModelCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
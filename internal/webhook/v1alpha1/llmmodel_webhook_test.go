@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func newQuotaClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	maxVMs, maxModels := int32(1), int32(1)
+	project := &llmcloudv1alpha1.Project{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Spec: llmcloudv1alpha1.ProjectSpec{
+			ResourceQuotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxVMs: &maxVMs, MaxLLMModels: &maxModels},
+		},
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(objs, project)...).Build()
+}
+
+func TestLLMModelValidateReportsAllViolations(t *testing.T) {
+	model := &llmcloudv1alpha1.LLMModel{
+		ObjectMeta: metav1.ObjectMeta{Name: "mistral", Namespace: "default"},
+		Spec: llmcloudv1alpha1.LLMModelSpec{
+			ModelName: "mistral",
+			Provider:  llmcloudv1alpha1.ProviderHuggingFace,
+			Replicas:  llmcloudv1alpha1.MaxReplicas + 1,
+			Resources: llmcloudv1alpha1.ResourceRequirements{CPU: "-1"},
+		},
+	}
+
+	v := &LLMModelCustomValidator{}
+	want := []string{"spec.modelName", "spec.image", "spec.replicas", "spec.resources.cpu"}
+
+	_, err := v.ValidateCreate(context.Background(), model)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateCreate reported fields %v, want %v", got, want)
+	}
+	old := &llmcloudv1alpha1.LLMModel{ObjectMeta: model.ObjectMeta, Spec: llmcloudv1alpha1.LLMModelSpec{ModelName: "llama3"}}
+	_, err = v.ValidateUpdate(context.Background(), old, model)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+}
+
+func TestLLMModelValidateCreateQuota(t *testing.T) {
+	spec := llmcloudv1alpha1.LLMModelSpec{ModelName: "llama3"}
+	existing := &llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "project-team"}, Spec: spec}
+	model := &llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "project-team"}, Spec: spec}
+
+	v := &LLMModelCustomValidator{Client: newQuotaClient()}
+	if _, err := v.ValidateCreate(context.Background(), model); err != nil {
+		t.Fatalf("Expected the first model to be allowed, got %v", err)
+	}
+
+	v = &LLMModelCustomValidator{Client: newQuotaClient(existing)}
+	if _, err := v.ValidateCreate(context.Background(), model); !apierrors.IsForbidden(err) {
+		t.Errorf("Expected a Forbidden error beyond the quota, got %v", err)
+	}
+	if _, err := v.ValidateUpdate(context.Background(), existing, existing); err != nil {
+		t.Errorf("Expected updates not to count against the quota, got %v", err)
+	}
+
+	outside := &llmcloudv1alpha1.LLMModel{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}, Spec: spec}
+	if _, err := v.ValidateCreate(context.Background(), outside); err != nil {
+		t.Errorf("Expected models outside projects to be unlimited, got %v", err)
+	}
+}
+
+func TestVirtualMachineValidateCreateQuota(t *testing.T) {
+	existing := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "first", Namespace: "project-team"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu"},
+	}
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "second", Namespace: "project-team"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu"},
+	}
+
+	v := &VirtualMachineCustomValidator{Client: newQuotaClient(existing)}
+	if _, err := v.ValidateCreate(context.Background(), vm); !apierrors.IsForbidden(err) {
+		t.Errorf("Expected a Forbidden error beyond the quota, got %v", err)
+	}
+}
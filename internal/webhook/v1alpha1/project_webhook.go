@@ -0,0 +1,80 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var projectlog = logf.Log.WithName("project-resource")
+
+// SetupProjectWebhookWithManager registers the webhook for Project in the manager.
+func SetupProjectWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.Project{}).
+		WithValidator(&ProjectCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-project,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=projects,verbs=create;update,versions=v1alpha1,name=vproject-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ProjectCustomValidator validates Project names on creation and the spec on
+// creation and update
+type ProjectCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ProjectCustomValidator{}
+
+// ValidateCreate rejects projects with reserved or invalid names or an invalid spec
+func (v *ProjectCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	project, ok := obj.(*llmcloudv1alpha1.Project)
+	if !ok {
+		return nil, fmt.Errorf("expected a Project object but got %T", obj)
+	}
+	projectlog.Info("Validation for Project upon creation", "name", project.GetName())
+
+	var allErrs field.ErrorList
+	if err := llmcloudv1alpha1.ValidateProjectName(project.Name); err != nil {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata", "name"), project.Name, err.Error()))
+	}
+	allErrs = append(allErrs, llmcloudv1alpha1.ValidateProjectSpec(&project.Spec, field.NewPath("spec"))...)
+	return nil, invalid("Project", project.Name, allErrs)
+}
+
+// ValidateUpdate rejects an invalid spec; the name is immutable
+func (v *ProjectCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	project, ok := newObj.(*llmcloudv1alpha1.Project)
+	if !ok {
+		return nil, fmt.Errorf("expected a Project object but got %T", newObj)
+	}
+	projectlog.Info("Validation for Project upon update", "name", project.GetName())
+
+	return nil, invalid("Project", project.Name, llmcloudv1alpha1.ValidateProjectSpec(&project.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *ProjectCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,109 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestProjectValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		project string
+		wantErr bool
+	}{
+		{name: "valid name", project: "team-a"},
+		{name: "reserved default", project: "default", wantErr: true},
+		{name: "reserved kube-system", project: "kube-system", wantErr: true},
+		{name: "reserved kube prefix", project: "kube-anything", wantErr: true},
+		{name: "operator namespace", project: "llmcloud-operator-system", wantErr: true},
+		{name: "uppercase", project: "TeamA", wantErr: true},
+		{name: "too long for namespace", project: "a123456789012345678901234567890123456789012345678901234567890", wantErr: true},
+	}
+
+	v := &ProjectCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: tt.project}}
+			_, err := v.ValidateCreate(context.Background(), project)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate(%q) error = %v, wantErr %v", tt.project, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectValidateCreateWrongType(t *testing.T) {
+	v := &ProjectCustomValidator{}
+	if _, err := v.ValidateCreate(context.Background(), &llmcloudv1alpha1.User{}); err == nil {
+		t.Error("Expected error for non-Project object")
+	}
+}
+
+func TestProjectValidateResourceQuotas(t *testing.T) {
+	quantity := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		quotas  *llmcloudv1alpha1.ProjectResourceQuotas
+		wantErr bool
+	}{
+		{name: "no quotas"},
+		{name: "valid quantities", quotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxCPU: quantity("500m"), MaxMemory: quantity("20Gi")}},
+		{name: "memory with space", quotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxMemory: quantity("20 GB")}, wantErr: true},
+		{name: "cpu word", quotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxCPU: quantity("ten")}, wantErr: true},
+	}
+
+	v := &ProjectCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project := &llmcloudv1alpha1.Project{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+				Spec:       llmcloudv1alpha1.ProjectSpec{ResourceQuotas: tt.quotas},
+			}
+			if _, err := v.ValidateCreate(context.Background(), project); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := v.ValidateUpdate(context.Background(), project, project); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateUpdate error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProjectValidateReportsAllViolations(t *testing.T) {
+	quantity := func(s string) *string { return &s }
+	project := &llmcloudv1alpha1.Project{
+		ObjectMeta: metav1.ObjectMeta{Name: "kube-team"},
+		Spec: llmcloudv1alpha1.ProjectSpec{
+			ResourceQuotas: &llmcloudv1alpha1.ProjectResourceQuotas{MaxCPU: quantity("ten"), MaxMemory: quantity("20 GB")},
+			Members:        []llmcloudv1alpha1.ProjectMember{{Username: "alice"}, {Username: "group:ML_Team"}},
+			Egress: &llmcloudv1alpha1.ProjectEgressPolicy{
+				AllowedCIDRs:      []string{"10.0.0.0/8", "10.0.0.300/8"},
+				AllowedNamespaces: []string{"Monitoring"},
+			},
+		},
+	}
+
+	v := &ProjectCustomValidator{}
+	_, err := v.ValidateCreate(context.Background(), project)
+	want := []string{
+		"metadata.name",
+		"spec.resourceQuotas.maxCPU",
+		"spec.resourceQuotas.maxMemory",
+		"spec.members[1].username",
+		"spec.egress.allowedCIDRs[1]",
+		"spec.egress.allowedNamespaces[0]",
+	}
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateCreate reported fields %v, want %v", got, want)
+	}
+
+	_, err = v.ValidateUpdate(context.Background(), project, project)
+	if got := invalidFields(t, err); !slices.Equal(got, want[1:]) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want[1:])
+	}
+}
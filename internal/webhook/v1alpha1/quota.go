@@ -0,0 +1,62 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// checkProjectQuota rejects creating another object in namespace once the project owning
+// it holds max of them, where max is picked from the project's quotas. Namespaces outside
+// a project, projects without the quota and a nil client are not limited
+func checkProjectQuota(ctx context.Context, c client.Client, namespace string, resource string, list client.ObjectList,
+	max func(*llmcloudv1alpha1.ProjectResourceQuotas) *int32) error {
+	name, ok := strings.CutPrefix(namespace, "project-")
+	if c == nil || !ok {
+		return nil
+	}
+
+	project := &llmcloudv1alpha1.Project{}
+	if err := c.Get(ctx, client.ObjectKey{Name: name}, project); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if project.Spec.ResourceQuotas == nil {
+		return nil
+	}
+	limit := max(project.Spec.ResourceQuotas)
+	if limit == nil {
+		return nil
+	}
+
+	if err := c.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+	if count := meta.LenList(list); count >= int(*limit) {
+		return apierrors.NewForbidden(schema.GroupResource{Group: llmcloudv1alpha1.GroupVersion.Group, Resource: resource}, "",
+			fmt.Errorf("project %s already has %d of its %d allowed %s", name, count, *limit, resource))
+	}
+	return nil
+}
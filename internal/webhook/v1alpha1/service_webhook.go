@@ -0,0 +1,80 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var servicelog = logf.Log.WithName("service-resource")
+
+// SetupServiceWebhookWithManager registers the webhook for Service in the manager.
+func SetupServiceWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.Service{}).
+		WithValidator(&ServiceCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-service,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=services,verbs=create;update,versions=v1alpha1,name=vservice-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// ServiceCustomValidator validates the replicas, ports and environment variables of Services
+type ServiceCustomValidator struct{}
+
+var _ webhook.CustomValidator = &ServiceCustomValidator{}
+
+// ValidateCreate rejects Services with an invalid spec
+func (v *ServiceCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	service, ok := obj.(*llmcloudv1alpha1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service object but got %T", obj)
+	}
+	servicelog.Info("Validation for Service upon creation", "name", service.GetName())
+
+	return nil, invalid("Service", service.Name, llmcloudv1alpha1.ValidateServiceSpec(&service.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate, and rejects changes to the
+// type or storage of a managed service
+func (v *ServiceCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	service, ok := newObj.(*llmcloudv1alpha1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service object but got %T", newObj)
+	}
+	old, ok := oldObj.(*llmcloudv1alpha1.Service)
+	if !ok {
+		return nil, fmt.Errorf("expected a Service object but got %T", oldObj)
+	}
+	servicelog.Info("Validation for Service upon update", "name", service.GetName())
+
+	return nil, invalid("Service", service.Name,
+		llmcloudv1alpha1.ValidateServiceUpdate(&old.Spec, &service.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *ServiceCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,117 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestServiceValidateReportsAllViolations(t *testing.T) {
+	service := &llmcloudv1alpha1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: llmcloudv1alpha1.ServiceSpec{
+			Type:     "web",
+			Image:    "nginx:latest",
+			Replicas: llmcloudv1alpha1.MaxReplicas + 1,
+			Ports: []llmcloudv1alpha1.ServicePort{
+				{Name: "http", Port: 80},
+				{Name: "http", Port: 70000, Protocol: "HTTP"},
+				{Name: "alt", Port: 80, Protocol: "TCP"},
+			},
+			Env: []llmcloudv1alpha1.EnvVar{{Name: "1BAD=NAME"}},
+		},
+	}
+
+	v := &ServiceCustomValidator{}
+	want := []string{
+		"spec.replicas",
+		"spec.ports[1].port",
+		"spec.ports[1].protocol",
+		"spec.ports[1].name",
+		"spec.ports[2].port",
+		"spec.env[0].name",
+	}
+
+	_, err := v.ValidateCreate(context.Background(), service)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateCreate reported fields %v, want %v", got, want)
+	}
+	old := &llmcloudv1alpha1.Service{
+		ObjectMeta: service.ObjectMeta,
+		Spec:       llmcloudv1alpha1.ServiceSpec{Type: "web", Image: "nginx:latest"},
+	}
+	_, err = v.ValidateUpdate(context.Background(), old, service)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+	// Violations the stored object already has do not block its updates
+	if _, err := v.ValidateUpdate(context.Background(), service, service); err != nil {
+		t.Errorf("Expected existing violations to be tolerated, got %v", err)
+	}
+}
+
+func TestServiceValidateCreateValid(t *testing.T) {
+	service := &llmcloudv1alpha1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: llmcloudv1alpha1.ServiceSpec{
+			Type:     "web",
+			Image:    "nginx:latest",
+			Replicas: 2,
+			Ports:    []llmcloudv1alpha1.ServicePort{{Name: "http", Port: 80, TargetPort: 8080, Protocol: "TCP"}},
+			Env:      []llmcloudv1alpha1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}},
+		},
+	}
+
+	v := &ServiceCustomValidator{}
+	if _, err := v.ValidateCreate(context.Background(), service); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestServiceValidateIngress(t *testing.T) {
+	service := &llmcloudv1alpha1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: llmcloudv1alpha1.ServiceSpec{
+			Type:    "web",
+			Image:   "nginx:latest",
+			Ingress: &llmcloudv1alpha1.ServiceIngress{Host: "Web_Example", Path: "app"},
+		},
+	}
+
+	v := &ServiceCustomValidator{}
+	want := []string{"spec.ingress", "spec.ingress.host", "spec.ingress.path"}
+	_, err := v.ValidateCreate(context.Background(), service)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateCreate reported fields %v, want %v", got, want)
+	}
+
+	service.Spec.Ports = []llmcloudv1alpha1.ServicePort{{Name: "http", Port: 80}}
+	service.Spec.Ingress = &llmcloudv1alpha1.ServiceIngress{Host: "web.example.com", Path: "/app"}
+	if _, err := v.ValidateCreate(context.Background(), service); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestServiceValidateManaged(t *testing.T) {
+	old := &llmcloudv1alpha1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Spec:       llmcloudv1alpha1.ServiceSpec{Type: llmcloudv1alpha1.ServiceTypePostgreSQL, Managed: true},
+	}
+
+	v := &ServiceCustomValidator{}
+	if _, err := v.ValidateCreate(context.Background(), old); err != nil {
+		t.Errorf("Expected a managed service without an image to be valid, got %v", err)
+	}
+	service := old.DeepCopy()
+	service.Spec.Type = llmcloudv1alpha1.ServiceTypeRedis
+	service.Spec.Replicas = 2
+	want := []string{"spec.replicas", "spec.type"}
+	_, err := v.ValidateUpdate(context.Background(), old, service)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var sshkeylog = logf.Log.WithName("sshkey-resource")
+
+// SetupSSHKeyWebhookWithManager registers the webhook for SSHKey in the manager.
+func SetupSSHKeyWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.SSHKey{}).
+		WithValidator(&SSHKeyCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-sshkey,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=sshkeys,verbs=create;update,versions=v1alpha1,name=vsshkey-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// SSHKeyCustomValidator validates the public key and owner of SSHKeys
+type SSHKeyCustomValidator struct{}
+
+var _ webhook.CustomValidator = &SSHKeyCustomValidator{}
+
+// ValidateCreate rejects SSHKeys with an invalid key or without exactly one owner
+func (v *SSHKeyCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	key, ok := obj.(*llmcloudv1alpha1.SSHKey)
+	if !ok {
+		return nil, fmt.Errorf("expected a SSHKey object but got %T", obj)
+	}
+	sshkeylog.Info("Validation for SSHKey upon creation", "name", key.GetName())
+
+	return nil, invalid("SSHKey", key.Name, llmcloudv1alpha1.ValidateSSHKeySpec(&key.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate
+func (v *SSHKeyCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	key, ok := newObj.(*llmcloudv1alpha1.SSHKey)
+	if !ok {
+		return nil, fmt.Errorf("expected a SSHKey object but got %T", newObj)
+	}
+	sshkeylog.Info("Validation for SSHKey upon update", "name", key.GetName())
+
+	return nil, invalid("SSHKey", key.Name, llmcloudv1alpha1.ValidateSSHKeySpec(&key.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *SSHKeyCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
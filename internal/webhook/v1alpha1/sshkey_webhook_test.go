@@ -0,0 +1,44 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestSSHKeyValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec llmcloudv1alpha1.SSHKeySpec
+		want []string
+	}{
+		{name: "user key", spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey, User: "alice"}},
+		{name: "project key", spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey, Project: "team"}},
+		{name: "no owner", spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: testSSHKey}, want: []string{"spec"}},
+		{
+			name: "two owners and a bad key",
+			spec: llmcloudv1alpha1.SSHKeySpec{PublicKey: "ssh-rsa nope", User: "alice", Project: "team"},
+			want: []string{"spec.publicKey", "spec.project"},
+		},
+	}
+	v := &SSHKeyCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &llmcloudv1alpha1.SSHKey{ObjectMeta: metav1.ObjectMeta{Name: "laptop"}, Spec: tt.spec}
+			_, err := v.ValidateCreate(context.Background(), key)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Expected a valid SSHKey, got %v", err)
+				}
+				return
+			}
+			if got := invalidFields(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateCreate reported fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var userlog = logf.Log.WithName("user-resource")
+
+// SetupUserWebhookWithManager registers the webhook for User in the manager.
+func SetupUserWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.User{}).
+		WithValidator(&UserCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=users,verbs=create;update,versions=v1alpha1,name=vuser-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// UserCustomValidator validates usernames and rejects collisions with existing users
+type UserCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &UserCustomValidator{}
+
+// ValidateCreate rejects reserved or invalid usernames and duplicates
+func (v *UserCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	user, ok := obj.(*llmcloudv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object but got %T", obj)
+	}
+	userlog.Info("Validation for User upon creation", "name", user.GetName())
+
+	return nil, v.validateUser(ctx, user)
+}
+
+// ValidateUpdate applies the same checks when the username changes, and checks the
+// API keys on every update
+func (v *UserCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldUser, ok := oldObj.(*llmcloudv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object for the oldObj but got %T", oldObj)
+	}
+	user, ok := newObj.(*llmcloudv1alpha1.User)
+	if !ok {
+		return nil, fmt.Errorf("expected a User object for the newObj but got %T", newObj)
+	}
+	if oldUser.Spec.Username == user.Spec.Username {
+		return nil, invalid("User", user.Name, llmcloudv1alpha1.ValidateAPIKeys(user.Spec.APIKeys, field.NewPath("spec", "apiKeys")))
+	}
+	userlog.Info("Validation for User upon update", "name", user.GetName())
+
+	return nil, v.validateUser(ctx, user)
+}
+
+// ValidateDelete allows all deletions
+func (v *UserCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *UserCustomValidator) validateUser(ctx context.Context, user *llmcloudv1alpha1.User) error {
+	if err := llmcloudv1alpha1.ValidateUsername(user.Spec.Username); err != nil {
+		return err
+	}
+	if err := invalid("User", user.Name, llmcloudv1alpha1.ValidateAPIKeys(user.Spec.APIKeys, field.NewPath("spec", "apiKeys"))); err != nil {
+		return err
+	}
+	if v.Client == nil {
+		return nil
+	}
+
+	var users llmcloudv1alpha1.UserList
+	if err := v.Client.List(ctx, &users); err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+	for _, existing := range users.Items {
+		if existing.Name != user.Name && existing.Spec.Username == user.Spec.Username {
+			return fmt.Errorf("username %q is already used by user %s", user.Spec.Username, existing.Name)
+		}
+	}
+	return nil
+}
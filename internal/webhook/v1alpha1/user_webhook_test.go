@@ -0,0 +1,79 @@
+package v1alpha1
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func newUser(name, username string) *llmcloudv1alpha1.User {
+	return &llmcloudv1alpha1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       llmcloudv1alpha1.UserSpec{Username: username, PasswordHash: "hash"},
+	}
+}
+
+func TestUserValidateCreate(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = llmcloudv1alpha1.AddToScheme(scheme)
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newUser("root", "root")).Build()
+	v := &UserCustomValidator{Client: c}
+
+	tests := []struct {
+		name     string
+		username string
+		wantErr  bool
+	}{
+		{name: "valid username", username: "alice"},
+		{name: "valid with separators", username: "bob.smith_2"},
+		{name: "reserved admin", username: "admin", wantErr: true},
+		{name: "reserved system prefix", username: "system:serviceaccount", wantErr: true},
+		{name: "collides with existing root", username: "root", wantErr: true},
+		{name: "uppercase", username: "Alice", wantErr: true},
+		{name: "too short", username: "ab", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCreate(context.Background(), newUser("new-user", tt.username))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCreate(%q) error = %v, wantErr %v", tt.username, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserValidateUpdate(t *testing.T) {
+	v := &UserCustomValidator{}
+
+	if _, err := v.ValidateUpdate(context.Background(), newUser("u", "alice"), newUser("u", "alice")); err != nil {
+		t.Errorf("Expected unchanged username to pass, got %v", err)
+	}
+	if _, err := v.ValidateUpdate(context.Background(), newUser("u", "alice"), newUser("u", "admin")); err == nil {
+		t.Error("Expected rename to a reserved username to be rejected")
+	}
+}
+
+func TestUserValidateAPIKeys(t *testing.T) {
+	v := &UserCustomValidator{}
+	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	user := newUser("u", "alice")
+	user.Spec.APIKeys = []llmcloudv1alpha1.APIKey{
+		{Name: "ci", Hash: hash, Scopes: []string{"read", "write"}},
+		{Name: "deploy", Hash: hash, Scopes: []string{"admin"}},
+	}
+
+	_, err := v.ValidateUpdate(context.Background(), newUser("u", "alice"), user)
+	fields := invalidFields(t, err)
+	if len(fields) != 2 || fields[0] != "spec.apiKeys[1].scopes[0]" || fields[1] != "spec.apiKeys[1].hash" {
+		t.Errorf("Expected the unknown scope and duplicate hash, got %v", fields)
+	}
+	if _, err := v.ValidateCreate(context.Background(), user); err == nil {
+		t.Error("Expected the keys to be checked on create")
+	}
+}
@@ -0,0 +1,27 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// invalidFields returns the field paths reported by an Invalid status error
+func invalidFields(t *testing.T, err error) []string {
+	t.Helper()
+	if !apierrors.IsInvalid(err) {
+		t.Fatalf("Expected an Invalid error, got %v", err)
+	}
+	var fields []string
+	for _, cause := range err.(apierrors.APIStatus).Status().Details.Causes {
+		fields = append(fields, cause.Field)
+	}
+	return fields
+}
+
+func TestInvalidWithoutErrors(t *testing.T) {
+	if err := invalid("Project", "team-a", field.ErrorList{}); err != nil {
+		t.Errorf("Expected no error for an empty error list, got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var virtualmachinelog = logf.Log.WithName("virtualmachine-resource")
+
+// SetupVirtualMachineWebhookWithManager registers the webhook for VirtualMachine in the manager.
+func SetupVirtualMachineWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.VirtualMachine{}).
+		WithValidator(&VirtualMachineCustomValidator{Client: mgr.GetClient()}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-virtualmachine,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=virtualmachines,verbs=create;update,versions=v1alpha1,name=vvirtualmachine-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// VirtualMachineCustomValidator validates SSH keys, secret files, the backup schedule,
+// the template and the image of VirtualMachines, and enforces the MaxVMs quota of their
+// project
+type VirtualMachineCustomValidator struct {
+	Client client.Client
+}
+
+var _ webhook.CustomValidator = &VirtualMachineCustomValidator{}
+
+// ValidateCreate rejects VMs with an invalid spec or beyond their project's quota
+func (v *VirtualMachineCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	vm, ok := obj.(*llmcloudv1alpha1.VirtualMachine)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtualMachine object but got %T", obj)
+	}
+	virtualmachinelog.Info("Validation for VirtualMachine upon creation", "name", vm.GetName())
+
+	if err := invalid("VirtualMachine", vm.Name, llmcloudv1alpha1.ValidateVirtualMachineSpec(&vm.Spec, field.NewPath("spec"))); err != nil {
+		return nil, err
+	}
+	if err := v.checkTemplateRef(ctx, vm); err != nil {
+		return nil, err
+	}
+	if err := v.checkImageRef(ctx, vm); err != nil {
+		return nil, err
+	}
+	return nil, checkProjectQuota(ctx, v.Client, vm.Namespace, "virtualmachines", &llmcloudv1alpha1.VirtualMachineList{},
+		func(q *llmcloudv1alpha1.ProjectResourceQuotas) *int32 { return q.MaxVMs })
+}
+
+// ValidateUpdate applies the spec checks of ValidateCreate and rejects shrinking disks
+func (v *VirtualMachineCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldVM, ok := oldObj.(*llmcloudv1alpha1.VirtualMachine)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtualMachine object for the oldObj but got %T", oldObj)
+	}
+	vm, ok := newObj.(*llmcloudv1alpha1.VirtualMachine)
+	if !ok {
+		return nil, fmt.Errorf("expected a VirtualMachine object for the newObj but got %T", newObj)
+	}
+	virtualmachinelog.Info("Validation for VirtualMachine upon update", "name", vm.GetName())
+
+	return nil, invalid("VirtualMachine", vm.Name,
+		llmcloudv1alpha1.ValidateVirtualMachineUpdate(&vm.Spec, &oldVM.Spec, field.NewPath("spec")))
+}
+
+// checkTemplateRef rejects a new VM referencing a VMTemplate that does not exist. Without
+// a client the reference is not checked
+func (v *VirtualMachineCustomValidator) checkTemplateRef(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	if vm.Spec.TemplateRef == "" || v.Client == nil {
+		return nil
+	}
+	err := v.Client.Get(ctx, client.ObjectKey{Name: vm.Spec.TemplateRef}, &llmcloudv1alpha1.VMTemplate{})
+	if apierrors.IsNotFound(err) {
+		return invalid("VirtualMachine", vm.Name,
+			field.ErrorList{field.NotFound(field.NewPath("spec", "templateRef"), vm.Spec.TemplateRef)})
+	}
+	return err
+}
+
+// checkImageRef rejects a new VM referencing a VMImage that does not exist. Without a
+// client the reference is not checked
+func (v *VirtualMachineCustomValidator) checkImageRef(ctx context.Context, vm *llmcloudv1alpha1.VirtualMachine) error {
+	if vm.Spec.ImageRef == "" || v.Client == nil {
+		return nil
+	}
+	err := v.Client.Get(ctx, client.ObjectKey{Name: vm.Spec.ImageRef}, &llmcloudv1alpha1.VMImage{})
+	if apierrors.IsNotFound(err) {
+		return invalid("VirtualMachine", vm.Name,
+			field.ErrorList{field.NotFound(field.NewPath("spec", "imageRef"), vm.Spec.ImageRef)})
+	}
+	return err
+}
+
+// ValidateDelete allows all deletions
+func (v *VirtualMachineCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+const testSSHKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGQdgUmX97JMXLbmXCll+bvnMY+OT7LrGdVP1JFp8L+J test@llmcloud"
+
+func TestVirtualMachineValidateReportsAllViolations(t *testing.T) {
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "default"},
+		Spec: llmcloudv1alpha1.VirtualMachineSpec{
+			OS:             "ubuntu",
+			SSHKeys:        []string{"not-a-key", testSSHKey, "also not a key"},
+			BackupSchedule: "every day",
+			SecretFiles: []llmcloudv1alpha1.SecretFile{
+				{SecretRef: llmcloudv1alpha1.SecretKeySelector{Name: "creds", Key: "token"}, Path: "relative/path"},
+				{SecretRef: llmcloudv1alpha1.SecretKeySelector{Name: "creds", Key: "token"}, Path: "/etc/token", Permissions: "999"},
+			},
+		},
+	}
+
+	v := &VirtualMachineCustomValidator{}
+	want := []string{
+		"spec.sshKeys[0]",
+		"spec.sshKeys[2]",
+		"spec.secretFiles[0].path",
+		"spec.secretFiles[1].permissions",
+		"spec.backupSchedule",
+	}
+
+	_, err := v.ValidateCreate(context.Background(), vm)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateCreate reported fields %v, want %v", got, want)
+	}
+	_, err = v.ValidateUpdate(context.Background(), vm, vm)
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+}
+
+func TestVirtualMachineValidateCreateValid(t *testing.T) {
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "default"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", BackupSchedule: "0 3 * * *"},
+	}
+
+	v := &VirtualMachineCustomValidator{}
+	if _, err := v.ValidateCreate(context.Background(), vm); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestVirtualMachineValidateCreateWrongType(t *testing.T) {
+	v := &VirtualMachineCustomValidator{}
+	if _, err := v.ValidateCreate(context.Background(), &llmcloudv1alpha1.Service{}); err == nil {
+		t.Error("Expected error for non-VirtualMachine object")
+	}
+}
+
+func TestVirtualMachineValidateUpdateDiskResize(t *testing.T) {
+	oldVM := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "default"},
+		Spec: llmcloudv1alpha1.VirtualMachineSpec{
+			OS:              "ubuntu",
+			DiskSize:        "20Gi",
+			AdditionalDisks: []llmcloudv1alpha1.VMDisk{{Name: "data", Size: "50Gi"}},
+		},
+	}
+	v := &VirtualMachineCustomValidator{}
+
+	grown := oldVM.DeepCopy()
+	grown.Spec.DiskSize = "40Gi"
+	grown.Spec.AdditionalDisks = []llmcloudv1alpha1.VMDisk{{Name: "data", Size: "100Gi"}, {Name: "scratch", Size: "10Gi"}}
+	if _, err := v.ValidateUpdate(context.Background(), oldVM, grown); err != nil {
+		t.Errorf("Expected growing and adding disks to be allowed, got %v", err)
+	}
+
+	shrunk := oldVM.DeepCopy()
+	shrunk.Spec.DiskSize = "10Gi"
+	shrunk.Spec.AdditionalDisks[0].Size = "50G"
+	_, err := v.ValidateUpdate(context.Background(), oldVM, shrunk)
+	want := []string{"spec.diskSize", "spec.additionalDisks[0].size"}
+	if got := invalidFields(t, err); !slices.Equal(got, want) {
+		t.Errorf("ValidateUpdate reported fields %v, want %v", got, want)
+	}
+}
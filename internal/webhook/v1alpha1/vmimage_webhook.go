@@ -0,0 +1,80 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var vmimagelog = logf.Log.WithName("vmimage-resource")
+
+// SetupVMImageWebhookWithManager registers the webhook for VMImage in the manager.
+func SetupVMImageWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.VMImage{}).
+		WithValidator(&VMImageCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-vmimage,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=vmimages,verbs=create;update,versions=v1alpha1,name=vvmimage-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// VMImageCustomValidator validates the source of VMImages
+type VMImageCustomValidator struct{}
+
+var _ webhook.CustomValidator = &VMImageCustomValidator{}
+
+// ValidateCreate rejects VMImages without exactly one source or with an invalid size
+func (v *VMImageCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	image, ok := obj.(*llmcloudv1alpha1.VMImage)
+	if !ok {
+		return nil, fmt.Errorf("expected a VMImage object but got %T", obj)
+	}
+	vmimagelog.Info("Validation for VMImage upon creation", "name", image.GetName())
+
+	return nil, invalid("VMImage", image.Name, llmcloudv1alpha1.ValidateVMImageSpec(&image.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the checks of ValidateCreate and rejects changes to the volume
+// of an uploaded image
+func (v *VMImageCustomValidator) ValidateUpdate(_ context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldImage, ok := oldObj.(*llmcloudv1alpha1.VMImage)
+	if !ok {
+		return nil, fmt.Errorf("expected a VMImage object for the oldObj but got %T", oldObj)
+	}
+	image, ok := newObj.(*llmcloudv1alpha1.VMImage)
+	if !ok {
+		return nil, fmt.Errorf("expected a VMImage object for the newObj but got %T", newObj)
+	}
+	vmimagelog.Info("Validation for VMImage upon update", "name", image.GetName())
+
+	return nil, invalid("VMImage", image.Name,
+		llmcloudv1alpha1.ValidateVMImageUpdate(&oldImage.Spec, &image.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *VMImageCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
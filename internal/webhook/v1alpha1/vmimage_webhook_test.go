@@ -0,0 +1,53 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestVMImageValidate(t *testing.T) {
+	v := &VMImageCustomValidator{}
+	image := &llmcloudv1alpha1.VMImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "windows"},
+		Spec:       llmcloudv1alpha1.VMImageSpec{Upload: &llmcloudv1alpha1.VMImageUpload{Size: "40Gi"}},
+	}
+	if _, err := v.ValidateCreate(context.Background(), image); err != nil {
+		t.Errorf("Expected a valid VMImage, got %v", err)
+	}
+
+	_, err := v.ValidateCreate(context.Background(), &llmcloudv1alpha1.VMImage{ObjectMeta: metav1.ObjectMeta{Name: "empty"}})
+	if got := invalidFields(t, err); !slices.Equal(got, []string{"spec"}) {
+		t.Errorf("Expected an image without a source to be rejected, got fields %v", got)
+	}
+
+	updated := image.DeepCopy()
+	updated.Spec.Upload.StorageClass = "fast"
+	_, err = v.ValidateUpdate(context.Background(), image, updated)
+	if got := invalidFields(t, err); !slices.Equal(got, []string{"spec.upload"}) {
+		t.Errorf("Expected the upload to be immutable, got fields %v", got)
+	}
+}
+
+func TestVirtualMachineValidateImageRef(t *testing.T) {
+	windows := &llmcloudv1alpha1.VMImage{ObjectMeta: metav1.ObjectMeta{Name: "windows"}}
+	v := &VirtualMachineCustomValidator{Client: newQuotaClient(windows)}
+
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "default"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", ImageRef: "windows"},
+	}
+	if _, err := v.ValidateCreate(context.Background(), vm); err != nil {
+		t.Errorf("Expected an existing image to be accepted, got %v", err)
+	}
+
+	vm.Spec.ImageRef = "plan9"
+	_, err := v.ValidateCreate(context.Background(), vm)
+	if got := invalidFields(t, err); !slices.Equal(got, []string{"spec.imageRef"}) {
+		t.Errorf("Expected a missing image to be rejected, got fields %v", got)
+	}
+}
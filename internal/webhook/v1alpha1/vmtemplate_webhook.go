@@ -0,0 +1,74 @@
+/*
+Copyright 2025 rusik69.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+var vmtemplatelog = logf.Log.WithName("vmtemplate-resource")
+
+// SetupVMTemplateWebhookWithManager registers the webhook for VMTemplate in the manager.
+func SetupVMTemplateWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&llmcloudv1alpha1.VMTemplate{}).
+		WithValidator(&VMTemplateCustomValidator{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-llmcloud-llmcloud-io-v1alpha1-vmtemplate,mutating=false,failurePolicy=fail,sideEffects=None,groups=llmcloud.llmcloud.io,resources=vmtemplates,verbs=create;update,versions=v1alpha1,name=vvmtemplate-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// VMTemplateCustomValidator validates the sizes and OS version of VMTemplates
+type VMTemplateCustomValidator struct{}
+
+var _ webhook.CustomValidator = &VMTemplateCustomValidator{}
+
+// ValidateCreate rejects VMTemplates with an invalid size or OS version
+func (v *VMTemplateCustomValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	template, ok := obj.(*llmcloudv1alpha1.VMTemplate)
+	if !ok {
+		return nil, fmt.Errorf("expected a VMTemplate object but got %T", obj)
+	}
+	vmtemplatelog.Info("Validation for VMTemplate upon creation", "name", template.GetName())
+
+	return nil, invalid("VMTemplate", template.Name, llmcloudv1alpha1.ValidateVMTemplateSpec(&template.Spec, field.NewPath("spec")))
+}
+
+// ValidateUpdate applies the same checks as ValidateCreate
+func (v *VMTemplateCustomValidator) ValidateUpdate(_ context.Context, _, newObj runtime.Object) (admission.Warnings, error) {
+	template, ok := newObj.(*llmcloudv1alpha1.VMTemplate)
+	if !ok {
+		return nil, fmt.Errorf("expected a VMTemplate object but got %T", newObj)
+	}
+	vmtemplatelog.Info("Validation for VMTemplate upon update", "name", template.GetName())
+
+	return nil, invalid("VMTemplate", template.Name, llmcloudv1alpha1.ValidateVMTemplateSpec(&template.Spec, field.NewPath("spec")))
+}
+
+// ValidateDelete allows all deletions
+func (v *VMTemplateCustomValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package v1alpha1
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func TestVMTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		spec llmcloudv1alpha1.VMTemplateSpec
+		want []string
+	}{
+		{name: "full", spec: llmcloudv1alpha1.VMTemplateSpec{CPUs: 2, Memory: "4Gi", DiskSize: "20Gi", OS: "ubuntu", OSVersion: "24.04"}},
+		{name: "size only", spec: llmcloudv1alpha1.VMTemplateSpec{CPUs: 8, Memory: "32Gi"}},
+		{
+			name: "bad sizes",
+			spec: llmcloudv1alpha1.VMTemplateSpec{CPUs: 128, Memory: "lots", DiskSize: "0"},
+			want: []string{"spec.cpus", "spec.memory", "spec.diskSize"},
+		},
+		{name: "version without OS", spec: llmcloudv1alpha1.VMTemplateSpec{OSVersion: "24.04"}, want: []string{"spec.osVersion"}},
+		{name: "unsupported version", spec: llmcloudv1alpha1.VMTemplateSpec{OS: "debian", OSVersion: "7"}, want: []string{"spec.osVersion"}},
+	}
+	v := &VMTemplateCustomValidator{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			template := &llmcloudv1alpha1.VMTemplate{ObjectMeta: metav1.ObjectMeta{Name: "small"}, Spec: tt.spec}
+			_, err := v.ValidateCreate(context.Background(), template)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Expected a valid VMTemplate, got %v", err)
+				}
+				return
+			}
+			if got := invalidFields(t, err); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateCreate reported fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVirtualMachineValidateTemplateRef(t *testing.T) {
+	small := &llmcloudv1alpha1.VMTemplate{ObjectMeta: metav1.ObjectMeta{Name: "small"}}
+	v := &VirtualMachineCustomValidator{Client: newQuotaClient(small)}
+
+	vm := &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-vm", Namespace: "default"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu", TemplateRef: "small"},
+	}
+	if _, err := v.ValidateCreate(context.Background(), vm); err != nil {
+		t.Errorf("Expected an existing template to be accepted, got %v", err)
+	}
+
+	vm.Spec.TemplateRef = "huge"
+	_, err := v.ValidateCreate(context.Background(), vm)
+	if got := invalidFields(t, err); !slices.Equal(got, []string{"spec.templateRef"}) {
+		t.Errorf("Expected a missing template to be rejected, got fields %v", got)
+	}
+}
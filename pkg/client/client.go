@@ -0,0 +1,245 @@
+// Package client is a Go client of the llmcloud HTTP API. It logs in, refreshes tokens,
+// manages projects, VMs, LLM models and services with the API's types, runs actions and
+// streams watch events, retrying idempotent requests that fail transiently
+package client
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxRetries is how often a request is retried when Client.MaxRetries is unset
+	DefaultMaxRetries = 3
+	// DefaultRetryBackoff is the wait before the first retry when Client.RetryBackoff is
+	// unset. It doubles with every retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+)
+
+// Client calls the llmcloud API at BaseURL. It authenticates with the token of Login,
+// or with an API key when APIKey is set. A Client is safe for concurrent use
+type Client struct {
+	// BaseURL is the address of the API server, e.g. "https://llmcloud.example.com"
+	BaseURL string
+
+	// HTTPClient sends the requests, http.DefaultClient when nil
+	HTTPClient *http.Client
+
+	// APIKey authenticates the requests in the X-API-Key header instead of a token
+	APIKey string
+
+	// MaxRetries is how often GET, PUT and DELETE requests are retried after a network
+	// error, a 429 or a 502, 503 or 504 response. Negative disables retries
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry, unless the response says how long
+	// to wait in Retry-After
+	RetryBackoff time.Duration
+
+	mu    sync.Mutex
+	token string
+}
+
+// New returns a client of the API at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Token returns the token the client authenticates with
+func (c *Client) Token() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token
+}
+
+// SetToken makes the client authenticate with token, e.g. one saved from an earlier Login
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// Error is an error response of the API, decoded from its RFC 7807 problem details
+type Error struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int    `json:"status"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	// Reason is a machine-readable CamelCase cause, e.g. "NotFound" or "AlreadyExists"
+	Reason string `json:"reason"`
+	// Details lists the invalid fields of a rejected object
+	Details []Cause `json:"details"`
+}
+
+// Cause is a field of the request that caused an Error
+type Cause struct {
+	Field   string `json:"field"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, cmp.Or(e.Title, http.StatusText(e.StatusCode)), e.Detail)
+}
+
+// IsNotFound reports whether err is an Error for a missing object
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsConflict reports whether err is an Error for an object that already exists or was
+// modified concurrently
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+// IsPasswordChangeRequired reports whether err is an Error refusing a login until the
+// user changes the password with ChangePassword
+func IsPasswordChangeRequired(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Reason == "PasswordChangeRequired"
+}
+
+func hasStatus(err error, status int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// do sends a request with method to path, with query if set, encoding in as the JSON
+// body unless it is nil and decoding the response into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	resp, err := c.send(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// send sends a request, retrying it while it fails transiently if the method is
+// idempotent, and returns the response if it succeeded or its Error
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	retries := cmp.Or(c.MaxRetries, DefaultMaxRetries)
+	switch method {
+	case http.MethodGet, http.MethodPut, http.MethodDelete:
+	default:
+		retries = 0
+	}
+	backoff := cmp.Or(c.RetryBackoff, DefaultRetryBackoff)
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendOnce(ctx, method, path, query, body)
+		if attempt >= retries || !retryable(resp, err) {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= 400 {
+				defer func() { _ = resp.Body.Close() }()
+				return nil, decodeError(resp)
+			}
+			return resp, nil
+		}
+
+		wait := backoff << attempt
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+				wait = time.Duration(seconds) * time.Second
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		contentType := "application/json"
+		if method == http.MethodPatch {
+			contentType = "application/merge-patch+json"
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	} else if token := c.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return cmp.Or(c.HTTPClient, http.DefaultClient).Do(req)
+}
+
+// retryable reports whether a request that got resp or err may succeed when retried
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// decodeError returns the Error of a failed response
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, Title: http.StatusText(resp.StatusCode)}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/problem+json" || json.Unmarshal(body, apiErr) != nil {
+		apiErr.Detail = strings.TrimSpace(string(body))
+	}
+	apiErr.StatusCode = resp.StatusCode
+	return apiErr
+}
+
+// apiPath joins segments into a path under /api/v1, escaping each
+func apiPath(segments ...string) string {
+	var b strings.Builder
+	b.WriteString("/api/v1")
+	for _, s := range segments {
+		b.WriteString("/")
+		b.WriteString(url.PathEscape(s))
+	}
+	return b.String()
+}
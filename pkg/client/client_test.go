@@ -0,0 +1,305 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+func writeProblem(w http.ResponseWriter, status int, reason, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"type": "about:blank", "title": http.StatusText(status), "status": status, "reason": reason, "detail": detail,
+	})
+}
+
+func TestLoginAndRefresh(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["password"] == "expired" {
+			writeProblem(w, http.StatusForbidden, "PasswordChangeRequired", "The password must be changed")
+			return
+		}
+		if req["password"] != "secret" {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Invalid credentials")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Session{Token: "first", Username: req["username"], Projects: []string{"team"}})
+	})
+	mux.HandleFunc("POST /api/v1/auth/password", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["password"] != "expired" || req["newPassword"] != "secret" {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Invalid credentials")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Session{Token: "changed", Username: req["username"]})
+	})
+	mux.HandleFunc("POST /api/v1/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer first" {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Invalid or expired token")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Session{Token: "second", Username: "alice"})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New(srv.URL + "/")
+	ctx := context.Background()
+
+	_, err := c.Login(ctx, "alice", "wrong")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Detail != "Invalid credentials" {
+		t.Fatalf("Expected the problem of a failed login, got %v", err)
+	}
+
+	if _, err := c.Login(ctx, "alice", "expired"); !IsPasswordChangeRequired(err) {
+		t.Fatalf("Expected the login to require a password change, got %v", err)
+	}
+	if _, err := c.ChangePassword(ctx, "alice", "expired", "secret"); err != nil || c.Token() != "changed" {
+		t.Fatalf("Expected to log in with the changed password's token, got %q, %v", c.Token(), err)
+	}
+
+	session, err := c.Login(ctx, "alice", "secret")
+	if err != nil || session.Username != "alice" || c.Token() != "first" {
+		t.Fatalf("Expected to log in with the returned token, got %+v, %v", session, err)
+	}
+	if _, err := c.Refresh(ctx); err != nil || c.Token() != "second" {
+		t.Errorf("Expected the refreshed token, got %q, %v", c.Token(), err)
+	}
+}
+
+func TestResources(t *testing.T) {
+	vms := map[string]llmcloudv1alpha1.VirtualMachine{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/namespaces/project-team/vms", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "llmc_key" {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Invalid or expired API key")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			list := llmcloudv1alpha1.VirtualMachineList{}
+			for _, vm := range vms {
+				list.Items = append(list.Items, vm)
+			}
+			_ = json.NewEncoder(w).Encode(list)
+		case http.MethodPost:
+			var vm llmcloudv1alpha1.VirtualMachine
+			_ = json.NewDecoder(r.Body).Decode(&vm)
+			vm.Namespace = "project-team"
+			vms[vm.Name] = vm
+			_ = json.NewEncoder(w).Encode(vm)
+		}
+	})
+	mux.HandleFunc("/api/v1/namespaces/project-team/vms/{name}", func(w http.ResponseWriter, r *http.Request) {
+		vm, ok := vms[r.PathValue("name")]
+		if !ok {
+			writeProblem(w, http.StatusNotFound, "NotFound", "not found")
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			_ = json.NewEncoder(w).Encode(vm)
+		case http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/merge-patch+json" {
+				writeProblem(w, http.StatusUnsupportedMediaType, "", "expected a merge patch")
+				return
+			}
+			var patch llmcloudv1alpha1.VirtualMachine
+			_ = json.NewDecoder(r.Body).Decode(&patch)
+			vm.Spec.Description = patch.Spec.Description
+			vms[vm.Name] = vm
+			_ = json.NewEncoder(w).Encode(vm)
+		case http.MethodDelete:
+			delete(vms, vm.Name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New(srv.URL)
+	c.APIKey = "llmc_key"
+	ctx := context.Background()
+
+	created, err := c.VMs("project-team").Create(ctx, &llmcloudv1alpha1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "db"},
+		Spec:       llmcloudv1alpha1.VirtualMachineSpec{OS: "ubuntu"},
+	})
+	if err != nil || created.Namespace != "project-team" {
+		t.Fatalf("Expected the created VM, got %+v, %v", created, err)
+	}
+	list, err := c.VMs("project-team").List(ctx)
+	if err != nil || len(list.Items) != 1 {
+		t.Fatalf("Expected the VM to be listed, got %+v, %v", list, err)
+	}
+	patched, err := c.VMs("project-team").Patch(ctx, "db", map[string]any{"spec": map[string]any{"description": "primary"}})
+	if err != nil || patched.Spec.Description != "primary" {
+		t.Errorf("Expected the patched description, got %+v, %v", patched, err)
+	}
+	if err := c.VMs("project-team").Delete(ctx, "db"); err != nil {
+		t.Errorf("Expected the VM to be deleted, got %v", err)
+	}
+	if vm, err := c.VMs("project-team").Get(ctx, "db"); vm != nil || !IsNotFound(err) {
+		t.Errorf("Expected the deleted VM not to be found, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var gets, posts int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			posts++
+			writeProblem(w, http.StatusServiceUnavailable, "", "busy")
+			return
+		}
+		if gets++; gets < 3 {
+			w.Header().Set("Retry-After", "0")
+			writeProblem(w, http.StatusTooManyRequests, "", "slow down")
+			return
+		}
+		_ = json.NewEncoder(w).Encode(llmcloudv1alpha1.Project{ObjectMeta: metav1.ObjectMeta{Name: "team"}})
+	}))
+	defer srv.Close()
+	c := New(srv.URL)
+	c.RetryBackoff = time.Millisecond
+	ctx := context.Background()
+
+	if project, err := c.GetProject(ctx, "team"); err != nil || project.Name != "team" || gets != 3 {
+		t.Errorf("Expected the GET to succeed on its third attempt, got %v after %d", err, gets)
+	}
+	if _, err := c.CreateProject(ctx, ProjectRequest{Name: "team"}); err == nil || posts != 1 {
+		t.Errorf("Expected a POST not to be retried, got %v after %d", err, posts)
+	}
+
+	gets = 0
+	c.MaxRetries = 1
+	if _, err := c.GetProject(ctx, "team"); err == nil || gets != 2 {
+		t.Errorf("Expected the GET to give up after one retry, got %v after %d", err, gets)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/watch/namespaces/project-team/models" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": watching models in project-team\n\n")
+		fmt.Fprint(w, `data: {"type":"ADDED","object":{"metadata":{"name":"chat"}}}`+"\n\n")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, `data: {"type":"DELETED","object":{"metadata":{"name":"chat"}}}`+"\n\n")
+	}))
+	defer srv.Close()
+
+	watcher, err := New(srv.URL).Models("project-team").Watch(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	defer func() { _ = watcher.Close() }()
+	var events []string
+	for {
+		event, err := watcher.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		events = append(events, event.Type+" "+event.Object.Name)
+	}
+	if len(events) != 2 || events[0] != "ADDED chat" || events[1] != "DELETED chat" {
+		t.Errorf("Expected the added and deleted model, got %v", events)
+	}
+}
+
+func TestLogsAndUsage(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/logs/project-team/models/llama", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.RawQuery; got != "follow=true&tailLines=5" {
+			writeProblem(w, http.StatusBadRequest, "BadRequest", "unexpected query "+got)
+			return
+		}
+		_, _ = w.Write([]byte("loaded\n"))
+	})
+	mux.HandleFunc("GET /api/v1/usage/projects/team", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"project":"team","total":{"requests":3},"models":[{"name":"llama","total":{"requests":3}}]}`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New(srv.URL)
+	ctx := context.Background()
+
+	tail := int64(5)
+	logs, err := c.Logs(ctx, "project-team", "models", "llama", LogOptions{Follow: true, TailLines: &tail})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(logs)
+	_ = logs.Close()
+	if string(body) != "loaded\n" {
+		t.Errorf("Unexpected log %q", body)
+	}
+	if _, err := c.Logs(ctx, "project-team", "models", "other", LogOptions{}); !IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+
+	usage, err := c.ProjectUsage(ctx, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Project != "team" || usage.Total.Requests != 3 || len(usage.Models) != 1 || usage.Models[0].Total.Requests != 3 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
+
+func TestBulk(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/bulk/vms/stop", func(w http.ResponseWriter, r *http.Request) {
+		var req BulkRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		response := BulkResponse{Resource: "vms", Action: "stop"}
+		for _, item := range req.Items {
+			result := BulkResult{Namespace: item.Namespace, Name: item.Name, Status: http.StatusOK}
+			if item.Name == "missing" {
+				result.Status, result.Error = http.StatusNotFound, "not found"
+				response.Failed++
+			} else {
+				response.Succeeded++
+			}
+			response.Results = append(response.Results, result)
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	c := New(srv.URL)
+
+	response, err := c.Bulk(context.Background(), "vms", VMActionStop, BulkRequest{Items: []BulkItem{
+		{Namespace: "project-team", Name: "web"},
+		{Namespace: "project-team", Name: "missing"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Succeeded != 1 || response.Failed != 1 || response.Results[1].Status != http.StatusNotFound {
+		t.Errorf("Unexpected bulk response %+v", response)
+	}
+	if _, err := c.Bulk(context.Background(), "vms", BulkDelete, BulkRequest{}); !IsNotFound(err) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// LogOptions selects the log lines returned by Logs
+type LogOptions struct {
+	// Follow keeps streaming new lines until the context is done or the pod stops
+	Follow bool
+	// TailLines starts with the last TailLines lines instead of the whole log, if set
+	TailLines *int64
+	// Container is the container of the pod to read, its default one when empty
+	Container string
+}
+
+// Logs streams the log of the newest pod of a VM, model or service. resource is "vms",
+// "models" or "services". The caller closes the returned reader
+func (c *Client) Logs(ctx context.Context, namespace, resource, name string, opts LogOptions) (io.ReadCloser, error) {
+	query := url.Values{}
+	if opts.Follow {
+		query.Set("follow", "true")
+	}
+	if opts.TailLines != nil {
+		query.Set("tailLines", strconv.FormatInt(*opts.TailLines, 10))
+	}
+	if opts.Container != "" {
+		query.Set("container", opts.Container)
+	}
+	resp, err := c.send(ctx, http.MethodGet, apiPath("logs", namespace, resource, name), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
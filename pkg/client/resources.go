@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	llmcloudv1alpha1 "github.com/rusik69/llmcloud-operator/api/v1alpha1"
+)
+
+// Session is the caller's identity returned by Login and Refresh
+type Session struct {
+	Token    string   `json:"token"`
+	Username string   `json:"username"`
+	IsAdmin  bool     `json:"isAdmin"`
+	Projects []string `json:"projects"`
+}
+
+// Login authenticates with a username and password, and makes the client use the
+// returned token
+func (c *Client) Login(ctx context.Context, username, password string) (*Session, error) {
+	req := map[string]string{"username": username, "password": password}
+	return c.session(ctx, apiPath("auth", "login"), req)
+}
+
+// ChangePassword changes the password of username, authenticating with the current
+// one, and makes the client use the returned token. A login refused with an error for
+// which IsPasswordChangeRequired holds continues with it
+func (c *Client) ChangePassword(ctx context.Context, username, password, newPassword string) (*Session, error) {
+	req := map[string]string{"username": username, "password": password, "newPassword": newPassword}
+	return c.session(ctx, apiPath("auth", "password"), req)
+}
+
+// Refresh exchanges the client's token for a fresh one reflecting the user's current
+// projects and admin flag, revoking the old token
+func (c *Client) Refresh(ctx context.Context) (*Session, error) {
+	return c.session(ctx, apiPath("auth", "refresh"), nil)
+}
+
+func (c *Client) session(ctx context.Context, path string, req any) (*Session, error) {
+	var session Session
+	if err := c.do(ctx, http.MethodPost, path, nil, req, &session); err != nil {
+		return nil, err
+	}
+	c.SetToken(session.Token)
+	return &session, nil
+}
+
+// Logout revokes the client's token
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, apiPath("auth", "logout"), nil, nil, nil); err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+// ProjectRequest creates a project
+type ProjectRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// TTL deletes the project once it has passed, if set
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+}
+
+// ListProjects returns the projects the caller is a member of, or every project for an
+// admin
+func (c *Client) ListProjects(ctx context.Context) (*llmcloudv1alpha1.ProjectList, error) {
+	return call[llmcloudv1alpha1.ProjectList](ctx, c, http.MethodGet, apiPath("projects"), nil)
+}
+
+// GetProject returns the project name
+func (c *Client) GetProject(ctx context.Context, name string) (*llmcloudv1alpha1.Project, error) {
+	return call[llmcloudv1alpha1.Project](ctx, c, http.MethodGet, apiPath("projects", name), nil)
+}
+
+// CreateProject creates a project, whose workloads live in the namespace
+// project-<name>
+func (c *Client) CreateProject(ctx context.Context, req ProjectRequest) (*llmcloudv1alpha1.Project, error) {
+	return call[llmcloudv1alpha1.Project](ctx, c, http.MethodPost, apiPath("projects"), req)
+}
+
+// DeleteProject deletes the project name and everything in it
+func (c *Client) DeleteProject(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, apiPath("projects", name), nil, nil, nil)
+}
+
+// call sends a request with the JSON body in, unless it is nil, and returns the
+// response decoded as a T
+func call[T any](ctx context.Context, c *Client, method, path string, in any) (*T, error) {
+	var out T
+	if err := c.do(ctx, method, path, nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Resources manages the objects of type T, listed as L, of one resource in a namespace
+type Resources[T, L any] struct {
+	c                   *Client
+	namespace, resource string
+}
+
+// VMs manages the VMs in namespace. Deleting a VM keeps its data disk
+func (c *Client) VMs(namespace string) Resources[llmcloudv1alpha1.VirtualMachine, llmcloudv1alpha1.VirtualMachineList] {
+	return Resources[llmcloudv1alpha1.VirtualMachine, llmcloudv1alpha1.VirtualMachineList]{c, namespace, "vms"}
+}
+
+// Models manages the LLM models in namespace
+func (c *Client) Models(namespace string) Resources[llmcloudv1alpha1.LLMModel, llmcloudv1alpha1.LLMModelList] {
+	return Resources[llmcloudv1alpha1.LLMModel, llmcloudv1alpha1.LLMModelList]{c, namespace, "models"}
+}
+
+// Services manages the services in namespace
+func (c *Client) Services(namespace string) Resources[llmcloudv1alpha1.Service, llmcloudv1alpha1.ServiceList] {
+	return Resources[llmcloudv1alpha1.Service, llmcloudv1alpha1.ServiceList]{c, namespace, "services"}
+}
+
+func (r Resources[T, L]) path(name ...string) string {
+	return apiPath(append([]string{"namespaces", r.namespace, r.resource}, name...)...)
+}
+
+// List returns the objects in the namespace
+func (r Resources[T, L]) List(ctx context.Context) (*L, error) {
+	return call[L](ctx, r.c, http.MethodGet, r.path(), nil)
+}
+
+// Get returns the object name
+func (r Resources[T, L]) Get(ctx context.Context, name string) (*T, error) {
+	return call[T](ctx, r.c, http.MethodGet, r.path(name), nil)
+}
+
+// Create creates obj, returning it as stored
+func (r Resources[T, L]) Create(ctx context.Context, obj *T) (*T, error) {
+	return call[T](ctx, r.c, http.MethodPost, r.path(), obj)
+}
+
+// Update replaces the object name with obj, returning it as stored. The update
+// conflicts unless obj has the current resourceVersion, or none
+func (r Resources[T, L]) Update(ctx context.Context, name string, obj *T) (*T, error) {
+	return call[T](ctx, r.c, http.MethodPut, r.path(name), obj)
+}
+
+// Patch applies patch to the object name as a JSON merge patch, returning it as stored
+func (r Resources[T, L]) Patch(ctx context.Context, name string, patch any) (*T, error) {
+	return call[T](ctx, r.c, http.MethodPatch, r.path(name), patch)
+}
+
+// Delete deletes the object name
+func (r Resources[T, L]) Delete(ctx context.Context, name string) error {
+	return r.c.do(ctx, http.MethodDelete, r.path(name), nil, nil, nil)
+}
+
+// Watch streams the changes to the objects in the namespace, starting with an ADDED
+// event for every existing object
+func (r Resources[T, L]) Watch(ctx context.Context) (*Watcher[T], error) {
+	return watch[T](ctx, r.c, apiPath("watch", "namespaces", r.namespace, r.resource))
+}
+
+// VM actions
+const (
+	VMActionStart   = "start"
+	VMActionStop    = "stop"
+	VMActionReboot  = "reboot"
+	VMActionMigrate = "migrate"
+)
+
+// VMAction runs action, one of the VMAction constants, on the VM namespace/name
+func (c *Client) VMAction(ctx context.Context, namespace, name, action string) error {
+	return c.do(ctx, http.MethodPost, apiPath("actions", "vm", namespace, name, action), nil, nil, nil)
+}
+
+// RetryModel retries downloading and deploying the LLM model namespace/name after it failed
+func (c *Client) RetryModel(ctx context.Context, namespace, name string) error {
+	return c.do(ctx, http.MethodPost, apiPath("actions", "model", namespace, name, "retry"), nil, nil, nil)
+}
+
+// Model actions
+const (
+	ModelActionStart = "start"
+	ModelActionStop  = "stop"
+)
+
+// ModelAction runs action, one of the ModelAction constants, on the LLM model namespace/name
+func (c *Client) ModelAction(ctx context.Context, namespace, name, action string) error {
+	return c.do(ctx, http.MethodPost, apiPath("actions", "model", namespace, name, action), nil, nil, nil)
+}
+
+// BulkDelete is the action of Bulk deleting the items
+const BulkDelete = "delete"
+
+// BulkItem names a VM or LLM model acted on by Bulk
+type BulkItem struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// BulkRequest lists the items Bulk acts on
+type BulkRequest struct {
+	Items []BulkItem `json:"items"`
+	// DeleteData also deletes the data disks of deleted VMs, which are kept otherwise
+	DeleteData bool `json:"deleteData,omitempty"`
+}
+
+// BulkResult is the outcome of Bulk for one item: the HTTP status acting on it alone
+// would have returned, and the error message when it failed
+type BulkResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Status    int    `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BulkResponse holds a result for each item of a BulkRequest, in the same order
+type BulkResponse struct {
+	Resource  string       `json:"resource"`
+	Action    string       `json:"action"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []BulkResult `json:"results"`
+}
+
+// Bulk runs action on all the items of req at once. resource is "vms" or "models", and
+// action one of their action constants or BulkDelete. Items may fail individually
+// without an error being returned, which their results report
+func (c *Client) Bulk(ctx context.Context, resource, action string, req BulkRequest) (*BulkResponse, error) {
+	return call[BulkResponse](ctx, c, http.MethodPost, apiPath("bulk", resource, action), req)
+}
+
+// VMExposure describes how the exposed ports of a VM are reached: through the node ports
+// allocated for them on any node, or the external addresses of a LoadBalancer
+type VMExposure struct {
+	Service   string                    `json:"service"`
+	Type      string                    `json:"type"`
+	Ports     []llmcloudv1alpha1.VMPort `json:"ports"`
+	Addresses []string                  `json:"addresses,omitempty"`
+}
+
+// GetVMExposure returns how the exposed ports of the VM namespace/name are reached
+func (c *Client) GetVMExposure(ctx context.Context, namespace, name string) (*VMExposure, error) {
+	return call[VMExposure](ctx, c, http.MethodGet, apiPath("namespaces", namespace, "vms", name, "expose"), nil)
+}
+
+// UsageReport is the metered inference usage of a project and of each of its models
+type UsageReport struct {
+	Project                         string `json:"project"`
+	llmcloudv1alpha1.InferenceUsage `json:",inline"`
+	Models                          []ModelUsage `json:"models"`
+}
+
+// ModelUsage is the metered inference usage of a model
+type ModelUsage struct {
+	Name                            string `json:"name"`
+	llmcloudv1alpha1.InferenceUsage `json:",inline"`
+}
+
+// ProjectUsage returns the inference usage metered for the project name
+func (c *Client) ProjectUsage(ctx context.Context, name string) (*UsageReport, error) {
+	return call[UsageReport](ctx, c, http.MethodGet, apiPath("usage", "projects", name), nil)
+}
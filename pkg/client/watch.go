@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Event is a change to a watched object. Type is ADDED, MODIFIED or DELETED
+type Event[T any] struct {
+	Type   string `json:"type"`
+	Object *T     `json:"object"`
+}
+
+// Watcher reads the events of a watch from the API's Server-Sent Events stream
+type Watcher[T any] struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+func watch[T any](ctx context.Context, c *Client, path string) (*Watcher[T], error) {
+	resp, err := c.send(ctx, http.MethodGet, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	// An event carries a whole object, which may be larger than a line's default limit
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	return &Watcher[T]{body: resp.Body, scanner: scanner}, nil
+}
+
+// Next blocks until the next event. It returns io.EOF once the server ends the stream,
+// e.g. because the client fell behind or the server is shutting down, after which the
+// caller lists the objects again and starts a new watch
+func (w *Watcher[T]) Next() (Event[T], error) {
+	var data strings.Builder
+	for w.scanner.Scan() {
+		line := w.scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var event Event[T]
+			err := json.Unmarshal([]byte(data.String()), &event)
+			return event, err
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+		// Comments, such as keepalives, and other fields are ignored
+	}
+	if err := w.scanner.Err(); err != nil {
+		return Event[T]{}, err
+	}
+	return Event[T]{}, io.EOF
+}
+
+// Close ends the watch
+func (w *Watcher[T]) Close() error {
+	return w.body.Close()
+}
@@ -41,7 +41,8 @@ export const projectsApi = {
   list: () => api.get('/projects'),
   get: (name) => api.get(`/projects/${name}`),
   create: (data) => api.post('/projects', data),
-  delete: (name) => api.delete(`/projects/${name}`)
+  delete: (name) => api.delete(`/projects/${name}`),
+  setQuota: (name, quotas) => api.put(`/projects/${name}/quota`, quotas)
 }
 
 export const vmsApi = {
@@ -49,9 +50,11 @@ export const vmsApi = {
   get: (namespace, name) => api.get(`/namespaces/${namespace}/vms/${name}`),
   create: (namespace, data) => api.post(`/namespaces/${namespace}/vms`, data),
   delete: (namespace, name) => api.delete(`/namespaces/${namespace}/vms/${name}`),
+  setDescription: (namespace, name, description) => api.patch(`/namespaces/${namespace}/vms/${name}`, { description }),
   start: (namespace, name) => api.post(`/actions/vm/${namespace}/${name}/start`),
   stop: (namespace, name) => api.post(`/actions/vm/${namespace}/${name}/stop`),
   reboot: (namespace, name) => api.post(`/actions/vm/${namespace}/${name}/reboot`),
+  migrate: (namespace, name) => api.post(`/actions/vm/${namespace}/${name}/migrate`),
   describe: (namespace, name) => api.get(`/describe/vm/${namespace}/${name}`),
   events: (namespace, name) => api.get(`/events/vm/${namespace}/${name}`)
 }
@@ -60,7 +63,16 @@ export const modelsApi = {
   list: (namespace) => api.get(`/namespaces/${namespace}/models`),
   get: (namespace, name) => api.get(`/namespaces/${namespace}/models/${name}`),
   create: (namespace, data) => api.post(`/namespaces/${namespace}/models`, data),
-  delete: (namespace, name) => api.delete(`/namespaces/${namespace}/models/${name}`)
+  delete: (namespace, name) => api.delete(`/namespaces/${namespace}/models/${name}`),
+  start: (namespace, name) => api.post(`/actions/model/${namespace}/${name}/start`),
+  stop: (namespace, name) => api.post(`/actions/model/${namespace}/${name}/stop`)
+}
+
+// Acts on many VMs or models at once; items are [{ namespace, name }] and each gets
+// its own result in the response
+export const bulkApi = {
+  vms: (action, items, deleteData = false) => api.post(`/bulk/vms/${action}`, { items, deleteData }),
+  models: (action, items) => api.post(`/bulk/models/${action}`, { items })
 }
 
 export const servicesApi = {
@@ -70,6 +82,13 @@ export const servicesApi = {
   delete: (namespace, name) => api.delete(`/namespaces/${namespace}/services/${name}`)
 }
 
+export const sshKeysApi = {
+  list: () => api.get('/sshkeys'),
+  get: (name) => api.get(`/sshkeys/${name}`),
+  create: (data) => api.post('/sshkeys', data),
+  delete: (name) => api.delete(`/sshkeys/${name}`)
+}
+
 export const nodesApi = {
   list: (namespace) => api.get(`/namespaces/${namespace}/nodes`),
   get: (namespace, name) => api.get(`/namespaces/${namespace}/nodes/${name}`),
@@ -86,5 +105,8 @@ export const namespacesApi = {
 }
 
 export const authApi = {
-  login: (username, password) => api.post('/auth/login', { username, password })
+  login: (username, password) => api.post('/auth/login', { username, password }),
+  refresh: () => api.post('/auth/refresh'),
+  logout: () => api.post('/auth/logout'),
+  oidcStatus: () => api.get('/auth/oidc')
 }